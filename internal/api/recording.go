@@ -0,0 +1,338 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aexon/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// TERMINAL SESSION RECORDING
+// ============================================================================
+
+// Recordings are stored as asciinema v2 cast files under
+// <RecordingConfig.Dir>/<instance>/<session-id>.cast.
+// Only terminal output is recorded: the echo already shows what was typed,
+// and skipping raw input keeps unechoed secrets (passwords) out of the file.
+
+const (
+	defaultRecordingsDir = "recordings/terminal"
+	recordingQueueSize   = 1024
+	castVersion          = 2
+	defaultCastWidth     = 80
+	defaultCastHeight    = 24
+)
+
+var recordingIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// RecordingConfig says where recordings are stored and whether every
+// session is recorded (Always) or only those opened with ?record=true
+type RecordingConfig struct {
+	Dir    string
+	Always bool
+}
+
+// DefaultRecordingConfig records on request under recordings/terminal
+func DefaultRecordingConfig() RecordingConfig {
+	return RecordingConfig{Dir: defaultRecordingsDir}
+}
+
+var (
+	recordingMu     sync.RWMutex
+	recordingConfig = DefaultRecordingConfig()
+)
+
+// SetRecordingConfig replaces the recording settings (set from config at startup)
+func SetRecordingConfig(cfg RecordingConfig) {
+	recordingMu.Lock()
+	defer recordingMu.Unlock()
+	recordingConfig = cfg
+}
+
+func currentRecordingConfig() RecordingConfig {
+	recordingMu.RLock()
+	defer recordingMu.RUnlock()
+	return recordingConfig
+}
+
+// RecordingsDir returns the directory where terminal recordings are stored
+func RecordingsDir() string {
+	return currentRecordingConfig().Dir
+}
+
+// shouldRecord reports whether a session must be recorded: either the client
+// asked for it with ?record=true or the admin policy records every session.
+func shouldRecord(c *gin.Context) bool {
+	if currentRecordingConfig().Always {
+		return true
+	}
+	return c.Query("record") == "true"
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	// Non-standard keys, ignored by asciinema players
+	User     string `json:"axion_user"`
+	Instance string `json:"axion_instance"`
+}
+
+type castEvent struct {
+	at   time.Duration
+	kind string
+	data string
+}
+
+// TerminalRecorder writes a session to a cast file without blocking the live
+// stream: events are queued and written by a background goroutine. When the
+// queue is full the event is dropped and counted rather than slowing the session.
+type TerminalRecorder struct {
+	path    string
+	file    *os.File
+	start   time.Time
+	events  chan castEvent
+	done    chan struct{}
+	dropped atomic.Uint64
+	once    sync.Once
+
+	// mu orders enqueue against Close: no send after events is closed
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewTerminalRecorder creates the cast file and writes its header
+func NewTerminalRecorder(instanceName, sessionID, username string) (*TerminalRecorder, error) {
+	if !recordingIDPattern.MatchString(instanceName) || !recordingIDPattern.MatchString(sessionID) {
+		return nil, fmt.Errorf("invalid recording name %s/%s", instanceName, sessionID)
+	}
+
+	dir := filepath.Join(RecordingsDir(), instanceName)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("create recordings dir: %w", err)
+	}
+
+	path := filepath.Join(dir, sessionID+".cast")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+
+	start := time.Now()
+	header, _ := json.Marshal(castHeader{
+		Version:   castVersion,
+		Width:     defaultCastWidth,
+		Height:    defaultCastHeight,
+		Timestamp: start.Unix(),
+		Title:     fmt.Sprintf("%s on %s", username, instanceName),
+		Env:       map[string]string{"SHELL": "/bin/bash", "TERM": "xterm-256color"},
+		User:      username,
+		Instance:  instanceName,
+	})
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("write recording header: %w", err)
+	}
+
+	r := &TerminalRecorder{
+		path:   path,
+		file:   file,
+		start:  start,
+		events: make(chan castEvent, recordingQueueSize),
+		done:   make(chan struct{}),
+	}
+	go r.writeLoop()
+	return r, nil
+}
+
+// Output records data sent to the client
+func (r *TerminalRecorder) Output(p []byte) {
+	r.enqueue("o", string(p))
+}
+
+// Resize records a terminal resize
+func (r *TerminalRecorder) Resize(cols, rows int) {
+	r.enqueue("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (r *TerminalRecorder) enqueue(kind, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.events <- castEvent{at: time.Since(r.start), kind: kind, data: data}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func (r *TerminalRecorder) writeLoop() {
+	defer close(r.done)
+
+	w := bufio.NewWriter(r.file)
+	for evt := range r.events {
+		line, err := json.Marshal([]interface{}{evt.at.Seconds(), evt.kind, evt.data})
+		if err != nil {
+			continue
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("[Recording] Flush failed for %s: %v", r.path, err)
+	}
+}
+
+// Close stops accepting events, drains the queue and closes the file
+func (r *TerminalRecorder) Close() {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.events)
+		r.mu.Unlock()
+		<-r.done
+		r.file.Close()
+
+		if dropped := r.dropped.Load(); dropped > 0 {
+			log.Printf("[Recording] %s: %d events dropped (queue full)", r.path, dropped)
+		}
+	})
+}
+
+// ============================================================================
+// RECORDING HANDLERS
+// ============================================================================
+
+// TerminalRecording describes a stored recording
+type TerminalRecording struct {
+	ID        string    `json:"id"`
+	Instance  string    `json:"instance"`
+	User      string    `json:"user"`
+	StartedAt time.Time `json:"started_at"`
+	SizeBytes int64     `json:"size_bytes"`
+}
+
+// RegisterTerminalRecordingRoutes registers the recording list/download routes.
+// Admins see every recording; other users only their own.
+func RegisterTerminalRecordingRoutes(r *gin.RouterGroup) {
+	r.GET("/instances/:name/terminal-recordings", auth.AuthMiddleware(), ListTerminalRecordings)
+	r.GET("/instances/:name/terminal-recordings/:id", auth.AuthMiddleware(), DownloadTerminalRecording)
+}
+
+func ListTerminalRecordings(c *gin.Context) {
+	instanceName := c.Param("name")
+	if !recordingIDPattern.MatchString(instanceName) {
+		c.JSON(400, gin.H{"error": "invalid instance name"})
+		return
+	}
+
+	dir := filepath.Join(RecordingsDir(), instanceName)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(500, gin.H{"error": "failed to list recordings", "details": err.Error()})
+		return
+	}
+
+	recordings := []TerminalRecording{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cast") {
+			continue
+		}
+		rec, err := readRecordingInfo(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if !canAccessRecording(c, rec) {
+			continue
+		}
+		recordings = append(recordings, *rec)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].StartedAt.After(recordings[j].StartedAt)
+	})
+
+	c.JSON(200, gin.H{"instance": instanceName, "recordings": recordings})
+}
+
+func DownloadTerminalRecording(c *gin.Context) {
+	instanceName := c.Param("name")
+	id := c.Param("id")
+	if !recordingIDPattern.MatchString(instanceName) || !recordingIDPattern.MatchString(id) {
+		c.JSON(400, gin.H{"error": "invalid recording id"})
+		return
+	}
+
+	path := filepath.Join(RecordingsDir(), instanceName, strings.TrimSuffix(id, ".cast")+".cast")
+	rec, err := readRecordingInfo(path)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "recording not found"})
+		return
+	}
+	// Same answer as a missing file so recordings of other users are not revealed
+	if !canAccessRecording(c, rec) {
+		c.JSON(404, gin.H{"error": "recording not found"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.FileAttachment(path, rec.ID+".cast")
+}
+
+func canAccessRecording(c *gin.Context, rec *TerminalRecording) bool {
+	if c.GetString("role") == "admin" {
+		return true
+	}
+	username := c.GetString("username")
+	return username != "" && username == rec.User
+}
+
+func readRecordingInfo(path string) (*TerminalRecording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+
+	var header castHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, fmt.Errorf("invalid cast header: %w", err)
+	}
+
+	return &TerminalRecording{
+		ID:        strings.TrimSuffix(filepath.Base(path), ".cast"),
+		Instance:  header.Instance,
+		User:      header.User,
+		StartedAt: time.Unix(header.Timestamp, 0),
+		SizeBytes: info.Size(),
+	}, nil
+}
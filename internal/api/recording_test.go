@@ -0,0 +1,36 @@
+package api
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestTerminalRecorderOutputAfterClose(t *testing.T) {
+	prev := currentRecordingConfig()
+	SetRecordingConfig(RecordingConfig{Dir: t.TempDir()})
+	t.Cleanup(func() { SetRecordingConfig(prev) })
+
+	rec, err := NewTerminalRecorder("web-1", "session-1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The terminal pumps keep writing while the session closes; none of them
+	// may send on the closed queue
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				rec.Output([]byte("x"))
+			}
+		}()
+	}
+	rec.Close()
+	wg.Wait()
+
+	rec.Output([]byte("late"))
+	rec.Resize(120, 40)
+	rec.Close()
+}
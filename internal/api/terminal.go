@@ -38,6 +38,7 @@ const (
 	ErrCodeConnectionClosed
 	ErrCodeWriteFailed
 	ErrCodeUnexpectedMessage
+	ErrCodeInsufficientScope
 )
 
 type TerminalError struct {
@@ -152,9 +153,10 @@ func (m *TerminalMetrics) Snapshot() map[string]interface{} {
 // ============================================================================ 
 
 type wsWriter struct {
-	conn   *websocket.Conn
-	mu     sync.Mutex
-	closed atomic.Bool
+	conn     *websocket.Conn
+	mu       sync.Mutex
+	closed   atomic.Bool
+	recorder *TerminalRecorder
 }
 
 func newWSWriter(conn *websocket.Conn) *wsWriter {
@@ -177,6 +179,9 @@ func (w *wsWriter) Write(p []byte) (n int, err error) {
 	}
 
 	globalMetrics.messagesSent.Add(1)
+	if w.recorder != nil {
+		w.recorder.Output(p)
+	}
 	return len(p), nil
 }
 
@@ -218,6 +223,7 @@ type TerminalSession struct {
 	stdinWriter     *io.PipeWriter
 	stdoutWriter    *wsWriter
 	instanceService *lxc.InstanceService
+	recorder        *TerminalRecorder
 	
 	ctx             context.Context
 	cancel          context.CancelFunc
@@ -258,6 +264,12 @@ func NewTerminalSession(instanceName string, conn *websocket.Conn, instanceServi
 	return session
 }
 
+// EnableRecording attaches a recorder to the session. Must be called before Start.
+func (s *TerminalSession) EnableRecording(recorder *TerminalRecorder) {
+	s.recorder = recorder
+	s.stdoutWriter.recorder = recorder
+}
+
 func (s *TerminalSession) Start() error {
 	if !s.state.CompareAndSwap(sessionStateCreated, sessionStateRunning) {
 		return errors.New("session already started")
@@ -416,6 +428,9 @@ func (s *TerminalSession) handleResize(cols, rows int) error {
 	}
 
 	globalMetrics.resizeCommands.Add(1)
+	if s.recorder != nil {
+		s.recorder.Resize(cols, rows)
+	}
 	log.Printf("[Session %s] Terminal resized to %dx%d", s.instanceName, cols, rows)
 	return nil
 }
@@ -512,6 +527,10 @@ func (s *TerminalSession) Close() {
 			log.Printf("[Session %s] Shutdown timeout", s.instanceName)
 		}
 
+		if s.recorder != nil {
+			s.recorder.Close()
+		}
+
 		s.state.Store(sessionStateClosed)
 		globalMetrics.sessionsActive.Add(-1)
 	})
@@ -535,7 +554,8 @@ func TerminalHandler(c *gin.Context, instanceService *lxc.InstanceService) {
 		return
 	}
 
-	if _, err := auth.ValidateToken(token); err != nil {
+	claims, err := auth.ValidateToken(token)
+	if err != nil {
 		globalMetrics.authFailures.Add(1)
 		log.Printf("[Terminal] Auth failed for instance %s: %v", instanceName, err)
 		c.JSON(401, gin.H{
@@ -545,6 +565,17 @@ func TerminalHandler(c *gin.Context, instanceService *lxc.InstanceService) {
 		return
 	}
 
+	// A scoped token needs the exec operation on this very instance
+	if !claims.AllowsOperation(instanceName, auth.OpExec) {
+		globalMetrics.authFailures.Add(1)
+		log.Printf("[Terminal] Scoped token of %s denied for instance %s", claims.Username, instanceName)
+		c.JSON(403, gin.H{
+			"error": "token does not grant exec on this instance",
+			"code":  ErrCodeInsufficientScope,
+		})
+		return
+	}
+
 	// Upgrade to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -559,6 +590,17 @@ func TerminalHandler(c *gin.Context, instanceService *lxc.InstanceService) {
 
 	// Create session
 	session := NewTerminalSession(instanceName, conn, instanceService)
+
+	// Optional recording; a failure here must not block the live session
+	if shouldRecord(c) {
+		recorder, err := NewTerminalRecorder(instanceName, sessionID, claims.Username)
+		if err != nil {
+			log.Printf("[Terminal] Recording disabled for session %s: %v", sessionID, err)
+		} else {
+			session.EnableRecording(recorder)
+			log.Printf("[Terminal] Recording session %s for user %s", sessionID, claims.Username)
+		}
+	}
 	
 	// CRITICAL: Register session for graceful shutdown tracking
 	RegisterSession(sessionID, session)
@@ -73,10 +73,17 @@ var telemetryUpgrader = websocket.Upgrader{
 
 const (
 	telemetryMetricsInterval     = 1 * time.Second
+	// Limites do ?interval= pedido pelo cliente: fora de [min,max] é ajustado,
+	// acima de telemetryIntervalReject (ou <= 0) é recusado
+	telemetryMinInterval         = 500 * time.Millisecond
+	telemetryMaxInterval         = 60 * time.Second
+	telemetryIntervalReject      = 1 * time.Hour
 	telemetryWriteTimeout        = 5 * time.Second
 	telemetryPingInterval        = 30 * time.Second
 	telemetryPongTimeout         = 60 * time.Second
 	telemetryChannelBufferSize   = 256
+	// Job updates are never dropped; a client that lets this many pile up is disconnected
+	telemetryCriticalQueueLimit  = 4 * telemetryChannelBufferSize
 	telemetryMaxReconnectAttempts = 3
 	telemetryShutdownTimeout     = 10 * time.Second
 )
@@ -146,6 +153,96 @@ func NewMessage(msgType MessageType, data interface{}) *TelemetryMessage {
 	}
 }
 
+// ============================================================================
+// CLIENT SEND QUEUE
+// ============================================================================
+
+// Drop policy: each client has its own bounded queue, so a slow consumer never
+// blocks the publisher or other clients. When the queue is full the OLDEST
+// droppable message (metrics, host telemetry, non-job events) is discarded to
+// make room. job_update events are never dropped; if a client accumulates
+// telemetryCriticalQueueLimit of them it is considered stuck and disconnected.
+
+type queuedMessage struct {
+	msg      *TelemetryMessage
+	critical bool
+}
+
+type sendQueue struct {
+	mu       sync.Mutex
+	items    []queuedMessage
+	capacity int
+	closed   bool
+	notify   chan struct{}
+}
+
+func newSendQueue(capacity int) *sendQueue {
+	return &sendQueue{
+		items:    make([]queuedMessage, 0, capacity),
+		capacity: capacity,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// push enqueues msg and returns how many messages were dropped to make room,
+// and false when the client exceeded the critical backlog limit.
+func (q *sendQueue) push(msg *TelemetryMessage, critical bool) (dropped int, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, true
+	}
+
+	if len(q.items) >= q.capacity {
+		if idx := q.oldestDroppable(); idx >= 0 {
+			q.items = append(q.items[:idx], q.items[idx+1:]...)
+			dropped++
+		} else if !critical {
+			// Queue is full of job updates: the new telemetry sample is the one to go
+			return 1, true
+		}
+	}
+
+	if critical && len(q.items) >= telemetryCriticalQueueLimit {
+		return dropped, false
+	}
+
+	q.items = append(q.items, queuedMessage{msg: msg, critical: critical})
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return dropped, true
+}
+
+func (q *sendQueue) oldestDroppable() int {
+	for i, item := range q.items {
+		if !item.critical {
+			return i
+		}
+	}
+	return -1
+}
+
+// drain removes and returns everything queued so far
+func (q *sendQueue) drain() []queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := q.items
+	q.items = make([]queuedMessage, 0, q.capacity)
+	return items
+}
+
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.items = nil
+}
+
 // ============================================================================
 // TELEMETRY CLIENT
 // ============================================================================
@@ -153,9 +250,11 @@ func NewMessage(msgType MessageType, data interface{}) *TelemetryMessage {
 type TelemetryClient struct {
 	id              string
 	conn            *websocket.Conn
-	sendChan        chan *TelemetryMessage
+	queue           *sendQueue
+	dropped         atomic.Uint64 // mensagens descartadas para este cliente
 	instanceService *lxc.InstanceService
 	metricProcessor func([]lxc.InstanceMetric)
+	interval        time.Duration // cadência de amostragem desta conexão
 	
 	ctx             context.Context
 	cancel          context.CancelFunc
@@ -179,15 +278,21 @@ func NewTelemetryClient(
 	conn *websocket.Conn,
 	instanceService *lxc.InstanceService,
 	metricProcessor func([]lxc.InstanceMetric),
+	interval time.Duration,
 ) *TelemetryClient {
 	ctx, cancel := context.WithCancel(context.Background())
 	
+	if interval <= 0 {
+		interval = telemetryMetricsInterval
+	}
+
 	client := &TelemetryClient{
 		id:              id,
 		conn:            conn,
-		sendChan:        make(chan *TelemetryMessage, telemetryChannelBufferSize),
+		queue:           newSendQueue(telemetryChannelBufferSize),
 		instanceService: instanceService,
 		metricProcessor: metricProcessor,
+		interval:        interval,
 		ctx:             ctx,
 		cancel:          cancel,
 	}
@@ -230,7 +335,7 @@ func (c *TelemetryClient) metricsPoller() {
 	defer c.wg.Done()
 	defer log.Printf("[Telemetry] Client %s metrics poller stopped", c.id)
 
-	ticker := time.NewTicker(telemetryMetricsInterval)
+	ticker := time.NewTicker(c.interval)
 	defer ticker.Stop()
 
 	for {
@@ -266,19 +371,9 @@ func (c *TelemetryClient) collectInstanceMetrics() error {
 	}
 
 	// Send to client
-	msg := NewMessage(MessageTypeInstanceMetrics, metrics)
-	
-	select {
-	case c.sendChan <- msg:
-		globalTelemetryMetrics.metricsCollected.Add(1)
-		return nil
-	case <-c.ctx.Done():
-		return c.ctx.Err()
-	default:
-		globalTelemetryMetrics.bufferOverflows.Add(1)
-		globalTelemetryMetrics.messagesDropped.Add(1)
-		return fmt.Errorf("buffer full, metrics dropped")
-	}
+	c.enqueue(NewMessage(MessageTypeInstanceMetrics, metrics), false)
+	globalTelemetryMetrics.metricsCollected.Add(1)
+	return nil
 }
 
 func (c *TelemetryClient) collectHostStats() error {
@@ -287,18 +382,23 @@ func (c *TelemetryClient) collectHostStats() error {
 		return fmt.Errorf("GetHostStats failed: %w", err)
 	}
 
-	msg := NewMessage(MessageTypeHostTelemetry, hostStats)
-	
-	select {
-	case c.sendChan <- msg:
-		globalTelemetryMetrics.hostStatsCollected.Add(1)
-		return nil
-	case <-c.ctx.Done():
-		return c.ctx.Err()
-	default:
+	c.enqueue(NewMessage(MessageTypeHostTelemetry, hostStats), false)
+	globalTelemetryMetrics.hostStatsCollected.Add(1)
+	return nil
+}
+
+// enqueue applies the queue drop policy and accounts for dropped messages.
+// Never blocks the caller.
+func (c *TelemetryClient) enqueue(msg *TelemetryMessage, critical bool) {
+	dropped, ok := c.queue.push(msg, critical)
+	if dropped > 0 {
+		c.dropped.Add(uint64(dropped))
+		globalTelemetryMetrics.messagesDropped.Add(uint64(dropped))
 		globalTelemetryMetrics.bufferOverflows.Add(1)
-		globalTelemetryMetrics.messagesDropped.Add(1)
-		return fmt.Errorf("buffer full, host stats dropped")
+	}
+	if !ok {
+		log.Printf("[Telemetry] Client %s too slow (%d pending job updates), disconnecting", c.id, telemetryCriticalQueueLimit)
+		go c.Close()
 	}
 }
 
@@ -309,21 +409,19 @@ func (c *TelemetryClient) writeLoop() {
 
 	for {
 		select {
-		case msg, ok := <-c.sendChan:
-			if !ok {
-				return
-			}
-
-			c.conn.SetWriteDeadline(time.Now().Add(telemetryWriteTimeout))
-			if err := c.conn.WriteJSON(msg); err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					log.Printf("[Telemetry] Client %s write error: %v", c.id, err)
-					globalTelemetryMetrics.writeFailures.Add(1)
+		case <-c.queue.notify:
+			for _, item := range c.queue.drain() {
+				c.conn.SetWriteDeadline(time.Now().Add(telemetryWriteTimeout))
+				if err := c.conn.WriteJSON(item.msg); err != nil {
+					if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+						log.Printf("[Telemetry] Client %s write error: %v", c.id, err)
+						globalTelemetryMetrics.writeFailures.Add(1)
+					}
+					return
 				}
-				return
-			}
 
-			globalTelemetryMetrics.messagesSent.Add(1)
+				globalTelemetryMetrics.messagesSent.Add(1)
+			}
 
 		case <-c.ctx.Done():
 			return
@@ -369,16 +467,13 @@ func (c *TelemetryClient) SendEvent(event interface{}) {
 		return
 	}
 
-	msg := NewMessage(MessageTypeEvent, event)
-	
-	select {
-	case c.sendChan <- msg:
-		globalTelemetryMetrics.eventsDispatched.Add(1)
-	case <-c.ctx.Done():
-	default:
-		globalTelemetryMetrics.messagesDropped.Add(1)
-		globalTelemetryMetrics.bufferOverflows.Add(1)
+	critical := false
+	if evt, ok := event.(events.Event); ok && evt.Type == events.JobUpdate {
+		critical = true
 	}
+
+	c.enqueue(NewMessage(MessageTypeEvent, event), critical)
+	globalTelemetryMetrics.eventsDispatched.Add(1)
 }
 
 func (c *TelemetryClient) Close() {
@@ -395,8 +490,8 @@ func (c *TelemetryClient) Close() {
 		// Unregister from broadcaster
 		unregisterTelemetryClient(c)
 
-		// Close send channel
-		close(c.sendChan)
+		// Discard anything still queued
+		c.queue.close()
 
 		// Wait for goroutines with timeout
 		done := make(chan struct{})
@@ -600,11 +695,43 @@ func ShutdownAllTelemetryClients(ctx context.Context) error {
 // HTTP HANDLER
 // ============================================================================
 
+// parseTelemetryInterval interprets ?interval= (e.g. "5s", "250ms").
+// Empty means the default cadence; values outside the server limits are
+// clamped, while non-positive or absurd values are rejected.
+func parseTelemetryInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return telemetryMetricsInterval, nil
+	}
+
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: use a duration such as 5s", raw)
+	}
+	if interval <= 0 || interval > telemetryIntervalReject {
+		return 0, fmt.Errorf("interval %q out of range (%s-%s)", raw, telemetryMinInterval, telemetryMaxInterval)
+	}
+
+	if interval < telemetryMinInterval {
+		return telemetryMinInterval, nil
+	}
+	if interval > telemetryMaxInterval {
+		return telemetryMaxInterval, nil
+	}
+	return interval, nil
+}
+
 func StreamTelemetry(
 	c *gin.Context,
 	instanceService *lxc.InstanceService,
 	metricProcessor func([]lxc.InstanceMetric),
 ) {
+	// Validate before upgrading so the client gets a plain HTTP error
+	interval, err := parseTelemetryInterval(c.Query("interval"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Upgrade to WebSocket
 	conn, err := telemetryUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -615,10 +742,10 @@ func StreamTelemetry(
 
 	// Generate unique client ID
 	clientID := fmt.Sprintf("telemetry-%d", time.Now().UnixNano())
-	log.Printf("[Telemetry] New client: %s", clientID)
+	log.Printf("[Telemetry] New client: %s (interval %s)", clientID, interval)
 
 	// Create client
-	client := NewTelemetryClient(clientID, conn, instanceService, metricProcessor)
+	client := NewTelemetryClient(clientID, conn, instanceService, metricProcessor, interval)
 
 	if err := client.Start(); err != nil {
 		log.Printf("[Telemetry] Client %s start failed: %v", clientID, err)
@@ -637,6 +764,7 @@ func StreamTelemetry(
 
 func GetTelemetryMetrics(c *gin.Context) {
 	metrics := globalTelemetryMetrics.Snapshot()
+	metrics["bus_events_dropped"] = events.Dropped()
 	metrics["active_client_count"] = GetActiveTelemetryCount()
 	metrics["active_client_ids"] = GetActiveTelemetryIDs()
 	
@@ -652,6 +780,8 @@ func ListTelemetryClientsHandler(c *gin.Context) {
 		State    uint32 `json:"state"`
 		StateStr string `json:"state_str"`
 		LastPong string `json:"last_pong"`
+		Dropped  uint64 `json:"dropped"`
+		Interval string `json:"interval"`
 	}
 	
 	clients := make([]ClientInfo, 0, len(telemetryClients))
@@ -676,6 +806,8 @@ func ListTelemetryClientsHandler(c *gin.Context) {
 			State:    state,
 			StateStr: stateStr,
 			LastPong: lastPong.Format(time.RFC3339),
+			Dropped:  client.dropped.Load(),
+			Interval: client.interval.String(),
 		})
 	}
 	
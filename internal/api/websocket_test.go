@@ -0,0 +1,83 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendQueueDropsOldestTelemetryKeepsJobUpdates(t *testing.T) {
+	q := newSendQueue(3)
+
+	job := NewMessage(MessageTypeEvent, "job")
+	first := NewMessage(MessageTypeInstanceMetrics, 1)
+
+	q.push(first, false)
+	q.push(job, true)
+	q.push(NewMessage(MessageTypeInstanceMetrics, 2), false)
+
+	// Full: the oldest telemetry sample must go, the job update must stay
+	dropped, ok := q.push(NewMessage(MessageTypeInstanceMetrics, 3), false)
+	if !ok || dropped != 1 {
+		t.Fatalf("push on full queue: dropped=%d ok=%v, want 1/true", dropped, ok)
+	}
+
+	items := q.drain()
+	if len(items) != 3 {
+		t.Fatalf("expected 3 queued messages, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.msg == first {
+			t.Error("oldest telemetry message should have been dropped")
+		}
+	}
+	if items[0].msg != job {
+		t.Error("job update should be preserved in order")
+	}
+}
+
+func TestSendQueueNeverDropsCriticalMessages(t *testing.T) {
+	q := newSendQueue(2)
+
+	q.push(NewMessage(MessageTypeEvent, "a"), true)
+	q.push(NewMessage(MessageTypeEvent, "b"), true)
+
+	if dropped, _ := q.push(NewMessage(MessageTypeHostTelemetry, nil), false); dropped != 1 {
+		t.Errorf("telemetry should be rejected when only job updates are queued, dropped=%d", dropped)
+	}
+	if dropped, ok := q.push(NewMessage(MessageTypeEvent, "c"), true); dropped != 0 || !ok {
+		t.Errorf("job update must be accepted beyond capacity, dropped=%d ok=%v", dropped, ok)
+	}
+	if n := len(q.drain()); n != 3 {
+		t.Errorf("expected 3 job updates queued, got %d", n)
+	}
+}
+
+func TestParseTelemetryInterval(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{raw: "", want: telemetryMetricsInterval},
+		{raw: "5s", want: 5 * time.Second},
+		{raw: "10ms", want: telemetryMinInterval},
+		{raw: "5m", want: telemetryMaxInterval},
+		{raw: "0s", wantErr: true},
+		{raw: "-1s", wantErr: true},
+		{raw: "48h", wantErr: true},
+		{raw: "fast", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := parseTelemetryInterval(tc.raw)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("interval %q: expected error, got %s", tc.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("interval %q = %s, %v; want %s", tc.raw, got, err, tc.want)
+		}
+	}
+}
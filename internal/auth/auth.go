@@ -4,11 +4,12 @@ import (
 	"aexon/internal/db"
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -36,6 +38,8 @@ const (
 	ErrCodeRateLimitExceeded
 	ErrCodeSecretNotConfigured
 	ErrCodeClaimsMissing
+	ErrCodePasswordChangeRequired
+	ErrCodeInsufficientScope
 )
 
 type AuthError struct {
@@ -94,6 +98,9 @@ const (
 	bcryptCost           = 12
 	minPasswordLength    = 8
 	tokenIssuer          = "axion-control-plane"
+
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
 )
 
 type Config struct {
@@ -104,39 +111,71 @@ type Config struct {
 	MaxLoginAttempts  int
 	RateLimitWindow   time.Duration
 	RequireStrongPass bool
+	Password          PasswordPolicy
 }
 
-func DefaultConfig() *Config {
-	secret := getSecret()
-	if len(secret) < 32 {
-		log.Printf("[SECURITY WARNING] JWT secret is weak (length: %d). Use at least 32 characters in production!", len(secret))
+// PasswordPolicy controls how passwords are stored and when they expire.
+// RotationInterval == 0 disables forced rotation.
+type PasswordPolicy struct {
+	HashAlgorithm    string
+	BcryptCost       int
+	MinLength        int
+	RotationInterval time.Duration
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		HashAlgorithm: HashAlgorithmBcrypt,
+		BcryptCost:    bcryptCost,
+		MinLength:     minPasswordLength,
 	}
+}
 
+// Validate rejects settings the token and hashing code cannot apply
+func (c *Config) Validate() error {
+	if len(c.SecretKey) == 0 {
+		return errors.New("JWT secret is empty")
+	}
+	p := c.Password
+	if p.HashAlgorithm != HashAlgorithmBcrypt && p.HashAlgorithm != HashAlgorithmArgon2id {
+		return fmt.Errorf("unknown hash algorithm %q (use %s or %s)", p.HashAlgorithm, HashAlgorithmBcrypt, HashAlgorithmArgon2id)
+	}
+	if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, p.BcryptCost)
+	}
+	if p.MinLength < minPasswordLength {
+		return fmt.Errorf("minimum password length must be at least %d, got %d", minPasswordLength, p.MinLength)
+	}
+	return nil
+}
+
+// DefaultConfig is the configuration used when Init gets nil. Its secret is
+// the insecure development fallback; the process config sets JWT_SECRET.
+func DefaultConfig() *Config {
 	return &Config{
-		SecretKey:         []byte(secret),
+		SecretKey:         []byte(insecureSecret),
 		TokenDuration:     defaultTokenDuration,
 		RefreshDuration:   refreshTokenDuration,
 		EnableRateLimit:   true,
 		MaxLoginAttempts:  maxLoginAttempts,
 		RateLimitWindow:   rateLimitWindow,
-		RequireStrongPass: getEnv("REQUIRE_STRONG_PASSWORD", "true") == "true",
+		RequireStrongPass: true,
+		Password:          DefaultPasswordPolicy(),
 	}
 }
 
-func getSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
+// insecureSecret signs tokens when JWT_SECRET is not set
+const insecureSecret = "axion-insecure-secret-key-change-me"
+
+// warnWeakSecret logs when tokens are signed with the fallback or a short secret
+func warnWeakSecret(secret []byte) {
+	if string(secret) == insecureSecret {
 		log.Println("[SECURITY WARNING] JWT_SECRET not set! Using insecure fallback. Set JWT_SECRET environment variable in production!")
-		return "axion-insecure-secret-key-change-me"
+		return
 	}
-	return secret
-}
-
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if len(secret) < 32 {
+		log.Printf("[SECURITY WARNING] JWT secret is weak (length: %d). Use at least 32 characters in production!", len(secret))
 	}
-	return fallback
 }
 
 // ============================================================================
@@ -179,6 +218,10 @@ type AxionClaims struct {
 	Role        string   `json:"role"`
 	Permissions []string `json:"permissions,omitempty"`
 	TokenType   string   `json:"token_type"` // "access" or "refresh"
+	// MustChangePassword limits the token to changing the password
+	MustChangePassword bool `json:"mcp,omitempty"`
+	// Instances limits the token to these instances; Permissions lists the allowed operations (see scoped.go)
+	Instances []string `json:"instances,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -370,6 +413,7 @@ func InitAuthService(cfg *Config) *AuthService {
 		if cfg == nil {
 			cfg = DefaultConfig()
 		}
+		warnWeakSecret(cfg.SecretKey)
 		globalAuthService = &AuthService{
 			config: cfg,
 			repo:   db.NewUserRepository(db.GetService()),
@@ -390,14 +434,19 @@ func GetAuthService() *AuthService {
 }
 
 func (s *AuthService) GenerateAccessToken(userID, username, role string, permissions []string) (string, error) {
+	return s.generateAccessToken(userID, username, role, permissions, false)
+}
+
+func (s *AuthService) generateAccessToken(userID, username, role string, permissions []string, mustChangePassword bool) (string, error) {
 	tokenID := generateTokenID()
 
 	claims := AxionClaims{
-		UserID:      userID,
-		Username:    username,
-		Role:        role,
-		Permissions: permissions,
-		TokenType:   "access",
+		UserID:             userID,
+		Username:           username,
+		Role:               role,
+		Permissions:        permissions,
+		TokenType:          "access",
+		MustChangePassword: mustChangePassword,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.TokenDuration)),
@@ -496,24 +545,136 @@ func (s *AuthService) RefreshAccessToken(refreshTokenString string) (string, err
 
 	globalAuthMetrics.refreshTokensUsed.Add(1)
 
+	// Re-check the user so a refresh can't bypass a pending password change
+	user, err := s.repo.GetByEmail(context.Background(), claims.Username)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", NewAuthError(ErrCodeTokenInvalid, "user no longer exists", nil)
+	}
+
 	// Generate new access token
-	return s.GenerateAccessToken(claims.UserID, claims.Username, "admin", nil)
+	return s.generateAccessToken(claims.UserID, claims.Username, user.Role, nil, s.PasswordChangeRequired(user))
 }
 
 // ============================================================================
 // PASSWORD UTILITIES
 // ============================================================================
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+// argon2id parameters (RFC 9106, low-memory profile)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// HashPassword hashes password with the algorithm configured in the policy
+func (p PasswordPolicy) HashPassword(password string) (string, error) {
+	if p.HashAlgorithm == HashAlgorithmArgon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	cost := p.BcryptCost
+	if cost == 0 {
+		cost = bcryptCost
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	return string(bytes), err
 }
 
+// NeedsRehash reports whether a stored hash was produced by a different
+// algorithm (or bcrypt cost) than the one currently configured.
+func (p PasswordPolicy) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return p.HashAlgorithm != HashAlgorithmArgon2id
+	}
+	if p.HashAlgorithm != HashAlgorithmBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || (p.BcryptCost != 0 && cost != p.BcryptCost)
+}
+
+// Validate enforces the minimum length and, if requireStrong, the complexity rules
+func (p PasswordPolicy) Validate(password string, requireStrong bool) error {
+	minLen := p.MinLength
+	if minLen < minPasswordLength {
+		minLen = minPasswordLength
+	}
+	if len(password) < minLen {
+		return NewAuthError(ErrCodePasswordTooWeak,
+			fmt.Sprintf("password must be at least %d characters", minLen), nil)
+	}
+	if requireStrong {
+		return ValidatePasswordStrength(password)
+	}
+	return nil
+}
+
+func HashPassword(password string) (string, error) {
+	return GetAuthService().config.Password.HashPassword(password)
+}
+
 func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return checkArgon2Hash(password, hash)
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+func checkArgon2Hash(password, hash string) bool {
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, computed) == 1
+}
+
+// PasswordChangeRequired reports whether the user must set a new password
+// before doing anything else (explicit flag or rotation interval exceeded).
+func (s *AuthService) PasswordChangeRequired(user *db.User) bool {
+	if user.MustChangePassword {
+		return true
+	}
+	interval := s.config.Password.RotationInterval
+	return interval > 0 && time.Since(user.PasswordChangedAt) > interval
+}
+
 func ValidatePasswordStrength(password string) error {
 	if len(password) < minPasswordLength {
 		return NewAuthError(ErrCodePasswordTooWeak,
@@ -557,6 +718,16 @@ func generateTokenID() string {
 // ============================================================================
 
 func AuthMiddleware() gin.HandlerFunc {
+	return authMiddleware(false)
+}
+
+// PasswordChangeMiddleware is AuthMiddleware, but it also accepts tokens
+// issued with a pending password change. Only the password change route uses it.
+func PasswordChangeMiddleware() gin.HandlerFunc {
+	return authMiddleware(true)
+}
+
+func authMiddleware(allowPendingPasswordChange bool) gin.HandlerFunc {
 	service := GetAuthService()
 
 	return func(c *gin.Context) {
@@ -601,6 +772,25 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims.MustChangePassword && !allowPendingPasswordChange {
+			c.AbortWithStatusJSON(403, gin.H{
+				"error": "password change required",
+				"code":  ErrCodePasswordChangeRequired,
+			})
+			return
+		}
+
+		if claims.IsScoped() {
+			if reason := authorizeScoped(claims, c.Request.Method, c.FullPath(), c.Param("name")); reason != "" {
+				c.AbortWithStatusJSON(403, gin.H{
+					"error": reason,
+					"code":  ErrCodeInsufficientScope,
+				})
+				return
+			}
+			c.Set("instances", claims.Instances)
+		}
+
 		// Set claims in context for use in handlers
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
@@ -667,15 +857,17 @@ func (s *AuthService) SeedAdmin(ctx context.Context) error {
 
 	log.Println("[Auth] Seeding default admin user...")
 
-	hash, err := HashPassword("admin")
+	hash, err := s.config.Password.HashPassword("admin")
 	if err != nil {
 		return err
 	}
 
+	// Default credentials must be replaced on first login
 	admin := &db.User{
-		Email:        "admin@admin",
-		PasswordHash: hash,
-		Role:         "admin",
+		Email:              "admin@admin",
+		PasswordHash:       hash,
+		Role:               "admin",
+		MustChangePassword: true,
 	}
 
 	if err := s.repo.Create(ctx, admin); err != nil {
@@ -756,11 +948,22 @@ func LoginHandler(c *gin.Context) {
 	rateLimiter.Reset(clientIP)
 	globalAuthMetrics.loginSuccesses.Add(1)
 
+	// Upgrade hash transparently if the configured algorithm changed
+	if service.config.Password.NeedsRehash(user.PasswordHash) {
+		if hash, err := service.config.Password.HashPassword(req.Password); err == nil {
+			if err := service.repo.UpdatePasswordHash(c.Request.Context(), user.ID, hash); err != nil {
+				log.Printf("[Auth] Failed to rehash password for user %d: %v", user.ID, err)
+			}
+		}
+	}
+
+	mustChange := service.PasswordChangeRequired(user)
+
 	// Generate tokens
 	// UserID is now user.ID (int), converts to string
 	uidStr := fmt.Sprintf("%d", user.ID)
 
-	accessToken, err := service.GenerateAccessToken(uidStr, user.Email, user.Role, []string{"*"})
+	accessToken, err := service.generateAccessToken(uidStr, user.Email, user.Role, []string{"*"}, mustChange)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to generate token"})
 		return
@@ -782,6 +985,7 @@ func LoginHandler(c *gin.Context) {
 			"email": user.Email,
 			"role":  user.Role,
 		},
+		"must_change_password": mustChange,
 	})
 }
 
@@ -810,14 +1014,12 @@ func RegisterHandler(c *gin.Context) {
 	}
 
 	// Hash Password
-	if service.config.RequireStrongPass {
-		if err := ValidatePasswordStrength(req.Password); err != nil {
-			c.JSON(400, gin.H{"error": err.Error(), "code": ErrCodePasswordTooWeak})
-			return
-		}
+	if err := service.config.Password.Validate(req.Password, service.config.RequireStrongPass); err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "code": ErrCodePasswordTooWeak})
+		return
 	}
 
-	hash, err := HashPassword(req.Password)
+	hash, err := service.config.Password.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "failed to process password"})
 		return
@@ -843,6 +1045,88 @@ func RegisterHandler(c *gin.Context) {
 	})
 }
 
+// ChangePasswordHandler handles POST /users/:id/password. Users may only
+// change their own password and must present the current one.
+func ChangePasswordHandler(c *gin.Context) {
+	service := GetAuthService()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if c.GetString("user_id") != strconv.Itoa(id) {
+		c.JSON(403, gin.H{"error": "cannot change another user's password"})
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request"})
+		return
+	}
+
+	user, err := service.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("[Auth] Change password DB error: %v", err)
+		c.JSON(500, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	}
+
+	if !CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		c.JSON(401, gin.H{
+			"error": "invalid credentials",
+			"code":  ErrCodeInvalidCredentials,
+		})
+		return
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(400, gin.H{"error": "new password must differ from the current one", "code": ErrCodePasswordTooWeak})
+		return
+	}
+
+	if err := service.config.Password.Validate(req.NewPassword, service.config.RequireStrongPass); err != nil {
+		c.JSON(400, gin.H{"error": err.Error(), "code": ErrCodePasswordTooWeak})
+		return
+	}
+
+	hash, err := service.config.Password.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to process password"})
+		return
+	}
+
+	if err := service.repo.UpdatePassword(c.Request.Context(), id, hash); err != nil {
+		log.Printf("[Auth] Update password error: %v", err)
+		c.JSON(500, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	// Issue a fresh token so a client stuck on a forced change can continue
+	accessToken, err := service.GenerateAccessToken(strconv.Itoa(user.ID), user.Email, user.Role, []string{"*"})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":       "password updated",
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(service.config.TokenDuration.Seconds()),
+	})
+}
+
 func RefreshTokenHandler(c *gin.Context) {
 	service := GetAuthService()
 
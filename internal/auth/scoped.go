@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"aexon/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ============================================================================
+// INSTANCE-SCOPED TOKENS (DEPLOY KEYS)
+// ============================================================================
+
+// A scoped token only reaches routes of the instances listed in its claims,
+// and only the operations it was issued for. Anything else, including fleet
+// routes such as GET /instances, is rejected with 403.
+
+const (
+	defaultScopedTokenTTL = 90 * 24 * time.Hour
+	maxScopedTokenTTL     = 365 * 24 * time.Hour
+	instanceRoutePrefix   = "/instances/:name"
+)
+
+// Operations a scoped token can be granted
+const (
+	OpRead     = "read"
+	OpSnapshot = "snapshot"
+	OpRestore  = "restore"
+	OpAction   = "action"
+	OpExec     = "exec"
+	OpFiles    = "files"
+)
+
+// scopedRoutes maps "METHOD <route after /instances/:name>" to the operation
+// it requires. Routes missing here are closed to scoped tokens.
+var scopedRoutes = map[string]string{
+	"GET ":                          OpRead,
+	"GET /snapshots":                OpRead,
+	"GET /snapshots/diff":           OpRead,
+	"GET /snapshot-policy/preview":  OpRead,
+	"GET /metrics":                  OpRead,
+	"GET /metrics/history":          OpRead,
+	"GET /usage":                    OpRead,
+	"GET /logs":                     OpRead,
+	"GET /logs/export":              OpRead,
+	"GET /console/log":              OpRead,
+	"GET /ip":                       OpRead,
+	"GET /ready":                    OpRead,
+	"POST /sync":                    OpRead, // Only refreshes observed state
+	"POST /snapshots":               OpSnapshot,
+	"DELETE /snapshots/:snap":       OpSnapshot,
+	"POST /snapshots/:snap/restore": OpRestore,
+	"POST /action":                  OpAction,
+	"POST /operations/:id/cancel":   OpAction,
+	"POST /exec":                    OpExec,
+	"GET /files":                    OpFiles,
+	"GET /file":                     OpFiles,
+	"POST /files":                   OpFiles,
+	"DELETE /files":                 OpFiles,
+}
+
+// scopedJobRoute lets a scoped token poll its own jobs; the handler checks
+// that the job targets one of the token's instances (see AllowsInstance).
+const scopedJobRoute = "/jobs/:id"
+
+// ScopedOperations lists the operations accepted by POST /tokens
+func ScopedOperations() []string {
+	seen := map[string]bool{}
+	for _, op := range scopedRoutes {
+		seen[op] = true
+	}
+	ops := make([]string, 0, len(seen))
+	for op := range seen {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// IsScoped reports whether the token is restricted to a set of instances
+func (c *AxionClaims) IsScoped() bool {
+	return len(c.Instances) > 0
+}
+
+// AllowsOperation reports whether the token may perform op on instance.
+// Unscoped tokens may do anything; routes outside AuthMiddleware (the
+// terminal WebSocket) use it to apply the same scope checks.
+func (c *AxionClaims) AllowsOperation(instance, op string) bool {
+	if !c.IsScoped() {
+		return true
+	}
+	return contains(c.Instances, instance) && contains(c.Permissions, op)
+}
+
+// authorizeScoped checks a scoped token against the matched route. It returns
+// "" when allowed, otherwise the reason for the 403.
+func authorizeScoped(claims *AxionClaims, method, fullPath, instance string) string {
+	if method == "GET" && strings.HasSuffix(fullPath, scopedJobRoute) {
+		return ""
+	}
+
+	idx := strings.Index(fullPath, instanceRoutePrefix)
+	if idx < 0 {
+		return "token is scoped to specific instances"
+	}
+	op, ok := scopedRoutes[method+" "+fullPath[idx+len(instanceRoutePrefix):]]
+	if !ok {
+		return "operation not available to scoped tokens"
+	}
+	if !contains(claims.Instances, instance) {
+		return "token is not valid for this instance"
+	}
+	if !contains(claims.Permissions, op) {
+		return fmt.Sprintf("token does not grant %q", op)
+	}
+	return ""
+}
+
+// AllowsInstance reports whether the request's token may act on an instance.
+// Unscoped tokens may; handlers use it for resources found by another key
+// (e.g. a job's target).
+func AllowsInstance(c *gin.Context, instance string) bool {
+	scope, ok := c.Get("instances")
+	if !ok {
+		return true
+	}
+	instances, _ := scope.([]string)
+	return len(instances) == 0 || contains(instances, instance)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueTokenHandler creates a scoped token for CI/deploy use:
+//
+//	POST /tokens {"instances": ["ci-runner"], "operations": ["snapshot", "restore"], "ttl": "720h"}
+//
+// Users may only scope tokens to instances they own; admins to any instance.
+// Scoped tokens cannot issue tokens themselves.
+func IssueTokenHandler(c *gin.Context) {
+	service := GetAuthService()
+
+	var req struct {
+		Instances  []string `json:"instances" binding:"required"`
+		Operations []string `json:"operations" binding:"required"`
+		TTL        string   `json:"ttl"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request"})
+		return
+	}
+	if len(req.Instances) == 0 || len(req.Operations) == 0 {
+		c.JSON(422, gin.H{"error": "instances and operations must not be empty"})
+		return
+	}
+
+	allowed := ScopedOperations()
+	for _, op := range req.Operations {
+		if !contains(allowed, op) {
+			c.JSON(422, gin.H{"error": fmt.Sprintf("unknown operation %q", op), "allowed": allowed})
+			return
+		}
+	}
+
+	ttl := defaultScopedTokenTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > maxScopedTokenTTL {
+			c.JSON(422, gin.H{"error": fmt.Sprintf("ttl must be a positive duration up to %s", maxScopedTokenTTL)})
+			return
+		}
+		ttl = d
+	}
+
+	username := c.GetString("username")
+	isAdmin := c.GetString("role") == "admin"
+	for _, name := range req.Instances {
+		inst, err := db.GetInstance(name)
+		if err != nil {
+			if errors.Is(err, db.ErrInstanceNotFound) {
+				c.JSON(404, gin.H{"error": "instance not found", "instance": name})
+				return
+			}
+			c.JSON(500, gin.H{"error": "internal error"})
+			return
+		}
+		if !isAdmin && inst.Owner != username {
+			c.JSON(403, gin.H{"error": "not the owner of this instance", "instance": name})
+			return
+		}
+	}
+
+	token, expiresAt, err := service.generateScopedToken(c.GetString("user_id"), username, c.GetString("role"), req.Instances, req.Operations, ttl)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	log.Printf("[Auth] Scoped token issued by %s for %v (%v)", username, req.Instances, req.Operations)
+	c.JSON(201, gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"instances":  req.Instances,
+		"operations": req.Operations,
+		"expires_at": expiresAt,
+	})
+}
+
+func (s *AuthService) generateScopedToken(userID, username, role string, instances, operations []string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := AxionClaims{
+		UserID:      userID,
+		Username:    username,
+		Role:        role,
+		Permissions: operations,
+		Instances:   instances,
+		TokenType:   "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        generateTokenID(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer,
+			Subject:   userID,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(s.config.SecretKey)
+	return signed, expiresAt, err
+}
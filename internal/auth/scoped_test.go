@@ -0,0 +1,49 @@
+package auth
+
+import "testing"
+
+func TestAuthorizeScoped(t *testing.T) {
+	claims := &AxionClaims{
+		Instances:   []string{"ci-runner"},
+		Permissions: []string{OpSnapshot, OpRestore},
+	}
+
+	cases := []struct {
+		method, path, instance string
+		allowed                bool
+	}{
+		{"POST", "/api/v1/instances/:name/snapshots", "ci-runner", true},
+		{"POST", "/api/v1/instances/:name/snapshots/:snap/restore", "ci-runner", true},
+		{"GET", "/api/v1/jobs/:id", "", true},
+		{"POST", "/api/v1/instances/:name/snapshots", "web-1", false},
+		{"DELETE", "/api/v1/instances/:name", "ci-runner", false},
+		{"POST", "/api/v1/instances/:name/exec", "ci-runner", false},
+		{"GET", "/api/v1/instances", "", false},
+		{"POST", "/api/v1/tokens", "", false},
+	}
+
+	for _, tc := range cases {
+		reason := authorizeScoped(claims, tc.method, tc.path, tc.instance)
+		if (reason == "") != tc.allowed {
+			t.Errorf("%s %s on %q: allowed=%v, reason %q", tc.method, tc.path, tc.instance, tc.allowed, reason)
+		}
+	}
+}
+
+func TestAllowsOperation(t *testing.T) {
+	scoped := &AxionClaims{Instances: []string{"ci-runner"}, Permissions: []string{OpExec}}
+	readOnly := &AxionClaims{Instances: []string{"ci-runner"}, Permissions: []string{OpRead}}
+
+	if !scoped.AllowsOperation("ci-runner", OpExec) {
+		t.Error("exec on a granted instance should be allowed")
+	}
+	if scoped.AllowsOperation("web-1", OpExec) {
+		t.Error("exec on another instance should be denied")
+	}
+	if readOnly.AllowsOperation("ci-runner", OpExec) {
+		t.Error("a read-only token should not open a terminal")
+	}
+	if !(&AxionClaims{}).AllowsOperation("web-1", OpExec) {
+		t.Error("unscoped tokens are not restricted")
+	}
+}
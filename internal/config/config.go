@@ -0,0 +1,562 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"aexon/internal/api"
+	"aexon/internal/auth"
+	"aexon/internal/db"
+	"aexon/internal/monitor"
+	"aexon/internal/provider/axhv"
+	"aexon/internal/provider/lxc"
+	"aexon/internal/scheduler"
+	"aexon/internal/service"
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// APPLICATION CONFIGURATION
+// ============================================================================
+
+// MaxDeleteGracePeriod caps AXION_DELETE_GRACE so a synchronous delete,
+// shutdown wait plus the AxHV delete, ends well inside the HTTP server's 30s
+// WriteTimeout
+const MaxDeleteGracePeriod = 20 * time.Second
+
+// Config is the process configuration, loaded once at startup by Load and
+// injected into the components that need it.
+type Config struct {
+	DB  *db.Config
+	LXD lxc.ConnectionConfig
+	// LXDRequired makes startup fail when LXD cannot be reached. It is implied
+	// by a remote AXION_LXD_URL; otherwise a missing LXD only disables job features.
+	LXDRequired bool
+	// LXDRemoteName names the primary connection among LXDRemotes
+	LXDRemoteName string
+	// LXDRemotes are extra LXD remotes/clusters managed alongside the primary
+	// one, from AXION_LXD_REMOTES="name=https://host:8443,...". They share
+	// AXION_CERT_PATH/AXION_KEY_PATH as client certificate.
+	LXDRemotes []lxc.RemoteConfig
+
+	// Startup connection retries for the DB and a required LXD: Attempts tries,
+	// starting ConnectInterval apart and doubling up to utils.MaxRetryInterval
+	ConnectAttempts int
+	ConnectInterval time.Duration
+
+	// HTTP listeners. AdminAddr empty keeps admin routes on ListenAddr.
+	ListenAddr string
+	AdminAddr  string
+
+	Workers int
+	// SyncConcurrency bounds how many instances the startup sync reconciles at once
+	SyncConcurrency int
+
+	// Instance count caps; 0 means unlimited
+	MaxInstances        int
+	MaxInstancesPerUser int
+
+	// Provider-wide ceiling on the vCPUs and RAM (MB) allocated to all
+	// instances (AXION_QUOTA_CPU / AXION_QUOTA_RAM_MB); 0 means unlimited
+	QuotaCPU   int
+	QuotaRAMMB int
+
+	// DeleteGracePeriod is how long DELETE /instances/:name waits for a clean
+	// shutdown before killing the VM, at most MaxDeleteGracePeriod;
+	// ?force=true skips the wait
+	DeleteGracePeriod time.Duration
+
+	// Free space the ISO storage must keep for GET /ready to pass
+	StorageMinFreeMB int
+
+	// Usage thresholds for ResourceAlert events from the metrics collector
+	Alerts monitor.AlertThresholds
+
+	JobRetention   db.JobRetention
+	ImportDefaults scheduler.ImportDefaults
+	RestartCrashed bool
+
+	// LogExportPaths are the files read by the log export on instances without
+	// systemd (AXION_LOG_EXPORT_PATHS, comma-separated); empty uses lxc.DefaultLogPaths
+	LogExportPaths []string
+
+	// BackupEncryptionKey is the server key for "symmetric" backup
+	// encryption (AXION_BACKUP_ENCRYPTION_KEY, base64); nil disables that mode
+	BackupEncryptionKey []byte
+
+	// ImagePolicy maps images to AxHV kernel/rootfs paths and decides whether
+	// an unmapped image fails (strict, the default) or boots a fallback rootfs
+	ImagePolicy axhv.ImagePolicy
+
+	// SecretsKey seals the values of the secrets store (AXION_SECRETS_KEY,
+	// base64, 32 bytes); nil disables {{secret:name}} references
+	SecretsKey []byte
+
+	// Host ports port forwarding may claim, per protocol
+	// (AXION_PORT_RANGE_TCP / AXION_PORT_RANGE_UDP, "min-max")
+	TCPPortRange lxc.PortRange
+	UDPPortRange lxc.PortRange
+
+	// GitCredentials authenticate config_repo fetches of private repositories,
+	// by host (AXION_GIT_CREDENTIALS="github.com=user:token,...")
+	GitCredentials map[string]service.GitCredential
+
+	// DevicePolicy limits passthrough devices to the types in AXION_DEVICE_TYPES
+	// (default gpu,usb) and disk sources below AXION_DEVICE_DISK_SOURCES
+	DevicePolicy service.DevicePolicy
+
+	// TierPolicies are the resource limits per user plan
+	// (AXION_TIER_POLICIES, JSON); by default only "free" is limited
+	TierPolicies types.TierPolicies
+
+	// AllowedImages are the glob patterns an image must match to be created,
+	// pulled or booted (AXION_ALLOWED_IMAGES, default "ubuntu*,alpine*")
+	AllowedImages []string
+
+	// SeedURL is the base URL AxHV VMs fetch their cloud-init seed from
+	// (AXION_SEED_URL); by default the guest gateway on the API port
+	SeedURL string
+
+	// MetricsToken, when set, is the bearer token Prometheus must send to
+	// scrape GET /metrics (AXION_METRICS_TOKEN); empty leaves it open
+	MetricsToken string
+
+	// FileExplorerRoot confines the file explorer to this directory inside
+	// the instances (AXION_FILE_EXPLORER_ROOT, default "/")
+	FileExplorerRoot string
+
+	// LXDDir is LXD's data directory on this host, read by the snapshot diff
+	// (AXION_LXD_DIR, default the snap install's)
+	LXDDir string
+
+	// TerminalRecording stores cast files under AXION_TERMINAL_RECORDINGS_DIR;
+	// AXION_TERMINAL_RECORD=always records every session, not only ?record=true
+	TerminalRecording api.RecordingConfig
+
+	// Auth holds the JWT secret (JWT_SECRET) and the password policy
+	// (PASSWORD_HASH_ALGORITHM, BCRYPT_COST, PASSWORD_MIN_LENGTH,
+	// PASSWORD_ROTATION_DAYS, REQUIRE_STRONG_PASSWORD)
+	Auth *auth.Config
+}
+
+// ValidationError lists every invalid or missing setting found by Load
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load reads the configuration from the environment. When AXION_CONFIG_FILE
+// names a file of KEY=VALUE lines, its values are applied first for keys not
+// already set in the environment, so explicit env vars win. Every problem is
+// collected and returned together as a *ValidationError.
+func Load() (*Config, error) {
+	l := &loader{}
+
+	if path := os.Getenv("AXION_CONFIG_FILE"); path != "" {
+		if err := applyEnvFile(path); err != nil {
+			l.fail("AXION_CONFIG_FILE", err.Error())
+		}
+	}
+
+	dbDefaults := db.DefaultConfig()
+	cfg := &Config{
+		DB: &db.Config{
+			Host:            l.string("DB_HOST", dbDefaults.Host),
+			Port:            l.int("DB_PORT", dbDefaults.Port, 1),
+			User:            l.string("DB_USER", dbDefaults.User),
+			Password:        l.string("DB_PASSWORD", dbDefaults.Password),
+			Database:        l.string("DB_NAME", dbDefaults.Database),
+			SSLMode:         l.string("DB_SSLMODE", dbDefaults.SSLMode),
+			MaxOpenConns:    l.int("DB_MAX_OPEN_CONNS", dbDefaults.MaxOpenConns, 1),
+			MaxIdleConns:    l.int("DB_MAX_IDLE_CONNS", dbDefaults.MaxIdleConns, 0),
+			ConnMaxLifetime: l.duration("DB_CONN_MAX_LIFETIME", dbDefaults.ConnMaxLifetime),
+			ConnMaxIdleTime: l.duration("DB_CONN_MAX_IDLE_TIME", dbDefaults.ConnMaxIdleTime),
+			ConnectTimeout:  l.duration("DB_CONNECT_TIMEOUT", dbDefaults.ConnectTimeout),
+			QueryTimeout:    l.duration("DB_QUERY_TIMEOUT", dbDefaults.QueryTimeout),
+		},
+		LXD: lxc.ConnectionConfig{
+			URL:      l.string("AXION_LXD_URL", ""),
+			CertPath: l.string("AXION_CERT_PATH", ""),
+			KeyPath:  l.string("AXION_KEY_PATH", ""),
+		},
+		LXDRequired:         l.bool("AXION_LXD_REQUIRED", false),
+		LXDRemoteName:       l.string("AXION_LXD_REMOTE_NAME", "local"),
+		ConnectAttempts:     l.int("AXION_CONNECT_ATTEMPTS", 10, 1),
+		ConnectInterval:     l.duration("AXION_CONNECT_INTERVAL", 2*time.Second),
+		ListenAddr:          l.addr("AXION_LISTEN_ADDR", ":8500"),
+		AdminAddr:           l.addr("AXION_ADMIN_ADDR", ""),
+		Workers:             l.int("AXION_WORKERS", 2, 1),
+		SyncConcurrency:     l.int("AXION_SYNC_CONCURRENCY", scheduler.DefaultSyncConcurrency, 1),
+		MaxInstances:        l.int("AXION_MAX_INSTANCES", 0, 0),
+		MaxInstancesPerUser: l.int("AXION_MAX_INSTANCES_PER_USER", 0, 0),
+		QuotaCPU:            l.int("AXION_QUOTA_CPU", 0, 0),
+		QuotaRAMMB:          l.int("AXION_QUOTA_RAM_MB", 0, 0),
+		StorageMinFreeMB:    l.int("AXION_STORAGE_MIN_FREE_MB", 1024, 0),
+		DeleteGracePeriod:   l.duration("AXION_DELETE_GRACE", 15*time.Second),
+		RestartCrashed:      l.bool("AXION_RESTART_CRASHED", false),
+		MetricsToken:        l.string("AXION_METRICS_TOKEN", ""),
+		FileExplorerRoot:    l.string("AXION_FILE_EXPLORER_ROOT", lxc.DefaultExplorerRoot),
+		LXDDir:              l.string("AXION_LXD_DIR", lxc.DefaultLXDDir),
+	}
+
+	defaults := monitor.DefaultAlertThresholds()
+	cfg.Alerts = monitor.AlertThresholds{
+		CPUPercent:    l.percent("AXION_ALERT_CPU_PERCENT", defaults.CPUPercent),
+		MemoryPercent: l.percent("AXION_ALERT_MEMORY_PERCENT", defaults.MemoryPercent),
+		DiskPercent:   l.percent("AXION_ALERT_DISK_PERCENT", defaults.DiskPercent),
+	}
+
+	if err := cfg.DB.Validate(); err != nil {
+		l.fail("DB_*", err.Error())
+	}
+	cfg.validateLXD(l)
+	cfg.LXDRemotes = cfg.parseLXDRemotes(l, l.string("AXION_LXD_REMOTES", ""))
+	if cfg.LXD.URL != "" {
+		cfg.LXDRequired = true
+	}
+	if cfg.ConnectInterval <= 0 {
+		l.fail("AXION_CONNECT_INTERVAL", "must be greater than zero")
+	}
+	if cfg.DeleteGracePeriod > MaxDeleteGracePeriod {
+		l.fail("AXION_DELETE_GRACE", fmt.Sprintf("must be at most %s, got %s", MaxDeleteGracePeriod, cfg.DeleteGracePeriod))
+	}
+	if cfg.AdminAddr != "" && cfg.AdminAddr == cfg.ListenAddr {
+		l.fail("AXION_ADMIN_ADDR", "must differ from AXION_LISTEN_ADDR")
+	}
+	cfg.SeedURL = l.seedURL("AXION_SEED_URL", cfg.ListenAddr)
+
+	base := l.duration("JOB_RETENTION", 7*24*time.Hour)
+	cfg.JobRetention = db.JobRetention{
+		Completed: l.duration("JOB_RETENTION_COMPLETED", base),
+		Failed:    l.duration("JOB_RETENTION_FAILED", base),
+		Canceled:  l.duration("JOB_RETENTION_CANCELED", base),
+	}
+
+	for _, path := range strings.Split(l.string("AXION_LOG_EXPORT_PATHS", ""), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			cfg.LogExportPaths = append(cfg.LogExportPaths, path)
+		}
+	}
+
+	if encoded := l.string("AXION_BACKUP_ENCRYPTION_KEY", ""); encoded != "" {
+		key, err := service.DecodeBackupKey(encoded)
+		if err != nil {
+			l.fail("AXION_BACKUP_ENCRYPTION_KEY", err.Error())
+		}
+		cfg.BackupEncryptionKey = key
+	}
+
+	imageDefaults := axhv.DefaultImagePolicy()
+	cfg.ImagePolicy = axhv.ImagePolicy{
+		Fallback:      strings.ToLower(l.string("AXION_IMAGE_FALLBACK", imageDefaults.Fallback)),
+		KernelPath:    l.string("AXION_AXHV_KERNEL", imageDefaults.KernelPath),
+		ImagesDir:     l.string("AXION_AXHV_IMAGES_DIR", imageDefaults.ImagesDir),
+		DefaultRootfs: l.string("AXION_AXHV_DEFAULT_ROOTFS", imageDefaults.DefaultRootfs),
+	}
+	if err := cfg.ImagePolicy.Validate(); err != nil {
+		l.fail("AXION_IMAGE_FALLBACK", err.Error())
+	}
+
+	tierPolicies, err := types.ParseTierPolicies(l.string("AXION_TIER_POLICIES", ""))
+	if err != nil {
+		l.fail("AXION_TIER_POLICIES", err.Error())
+	}
+	cfg.TierPolicies = tierPolicies
+
+	allowedImages, err := service.ParseAllowedImages(l.string("AXION_ALLOWED_IMAGES", ""))
+	if err != nil {
+		l.fail("AXION_ALLOWED_IMAGES", err.Error())
+	}
+	cfg.AllowedImages = allowedImages
+
+	if encoded := l.string("AXION_SECRETS_KEY", ""); encoded != "" {
+		key, err := service.DecodeSecretsKey(encoded)
+		if err != nil {
+			l.fail("AXION_SECRETS_KEY", err.Error())
+		}
+		cfg.SecretsKey = key
+	}
+
+	cfg.TCPPortRange = l.portRange("AXION_PORT_RANGE_TCP")
+	cfg.UDPPortRange = l.portRange("AXION_PORT_RANGE_UDP")
+
+	gitCredentials, err := service.ParseGitCredentials(l.string("AXION_GIT_CREDENTIALS", ""))
+	if err != nil {
+		l.fail("AXION_GIT_CREDENTIALS", err.Error())
+	}
+	cfg.GitCredentials = gitCredentials
+
+	devicePolicy, err := service.ParseDevicePolicy(l.string("AXION_DEVICE_TYPES", ""), l.string("AXION_DEVICE_DISK_SOURCES", ""))
+	if err != nil {
+		l.fail("AXION_DEVICE_TYPES/AXION_DEVICE_DISK_SOURCES", err.Error())
+	}
+	cfg.DevicePolicy = devicePolicy
+
+	recordingDefaults := api.DefaultRecordingConfig()
+	cfg.TerminalRecording = api.RecordingConfig{
+		Dir:    l.string("AXION_TERMINAL_RECORDINGS_DIR", recordingDefaults.Dir),
+		Always: strings.EqualFold(l.string("AXION_TERMINAL_RECORD", ""), "always"),
+	}
+
+	cfg.Auth = l.authConfig()
+
+	importDefaults, err := scheduler.LoadImportDefaults()
+	if err != nil {
+		l.fail("AXION_IMPORT_BACKUP_*", err.Error())
+	}
+	cfg.ImportDefaults = importDefaults
+
+	if len(l.problems) > 0 {
+		return nil, &ValidationError{Problems: l.problems}
+	}
+	return cfg, nil
+}
+
+// authConfig reads the JWT secret and the password policy. Without
+// JWT_SECRET tokens are signed with the insecure development fallback, which
+// auth warns about at startup.
+func (l *loader) authConfig() *auth.Config {
+	c := auth.DefaultConfig()
+	if secret := l.string("JWT_SECRET", ""); secret != "" {
+		c.SecretKey = []byte(secret)
+	}
+	c.RequireStrongPass = l.bool("REQUIRE_STRONG_PASSWORD", c.RequireStrongPass)
+	c.Password.HashAlgorithm = strings.ToLower(l.string("PASSWORD_HASH_ALGORITHM", c.Password.HashAlgorithm))
+	c.Password.BcryptCost = l.int("BCRYPT_COST", c.Password.BcryptCost, 0)
+	c.Password.MinLength = l.int("PASSWORD_MIN_LENGTH", c.Password.MinLength, 0)
+	c.Password.RotationInterval = time.Duration(l.int("PASSWORD_ROTATION_DAYS", 0, 0)) * 24 * time.Hour
+	if err := c.Validate(); err != nil {
+		l.fail("PASSWORD_*", err.Error())
+	}
+	return c
+}
+
+// validateLXD requires the TLS settings to be all set (remote/cluster) or all
+// empty (local unix socket), with readable certificate files.
+func (c *Config) validateLXD(l *loader) {
+	set := 0
+	for _, v := range []string{c.LXD.URL, c.LXD.CertPath, c.LXD.KeyPath} {
+		if v != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return
+	}
+	if set != 3 {
+		l.fail("AXION_LXD_URL", "AXION_LXD_URL, AXION_CERT_PATH and AXION_KEY_PATH must be set together")
+		return
+	}
+	for key, path := range map[string]string{"AXION_CERT_PATH": c.LXD.CertPath, "AXION_KEY_PATH": c.LXD.KeyPath} {
+		if _, err := os.Stat(path); err != nil {
+			l.fail(key, err.Error())
+		}
+	}
+}
+
+// parseLXDRemotes reads "name=url" pairs separated by commas. Names must be
+// unique, including the primary's, and remotes need the TLS client cert.
+func (c *Config) parseLXDRemotes(l *loader, raw string) []lxc.RemoteConfig {
+	if raw == "" {
+		return nil
+	}
+	if c.LXD.CertPath == "" || c.LXD.KeyPath == "" {
+		l.fail("AXION_LXD_REMOTES", "remotes require AXION_CERT_PATH and AXION_KEY_PATH")
+		return nil
+	}
+
+	seen := map[string]bool{c.LXDRemoteName: true}
+	var remotes []lxc.RemoteConfig
+	for _, entry := range strings.Split(raw, ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		name, url = strings.TrimSpace(name), strings.TrimSpace(url)
+		if !ok || name == "" || !strings.HasPrefix(url, "https://") {
+			l.fail("AXION_LXD_REMOTES", fmt.Sprintf("%q must be name=https://host:port", entry))
+			continue
+		}
+		if seen[name] {
+			l.fail("AXION_LXD_REMOTES", fmt.Sprintf("duplicate remote name %q", name))
+			continue
+		}
+		seen[name] = true
+		remotes = append(remotes, lxc.RemoteConfig{
+			Name:             name,
+			ConnectionConfig: lxc.ConnectionConfig{URL: url, CertPath: c.LXD.CertPath, KeyPath: c.LXD.KeyPath},
+		})
+	}
+	return remotes
+}
+
+// ============================================================================
+// PARSING
+// ============================================================================
+
+// loader parses env values strictly: a set but malformed value is a problem,
+// never silently replaced by the default.
+type loader struct {
+	problems []string
+}
+
+func (l *loader) fail(key, msg string) {
+	l.problems = append(l.problems, fmt.Sprintf("%s: %s", key, msg))
+}
+
+func (l *loader) string(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func (l *loader) int(key string, def, min int) int {
+	raw := l.string(key, "")
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		l.fail(key, fmt.Sprintf("not an integer: %q", raw))
+		return def
+	}
+	if n < min {
+		l.fail(key, fmt.Sprintf("must be >= %d, got %d", min, n))
+		return def
+	}
+	return n
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	raw := l.string(key, "")
+	if raw == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.fail(key, fmt.Sprintf("not a boolean: %q", raw))
+		return def
+	}
+	return b
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	raw := l.string(key, "")
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		l.fail(key, fmt.Sprintf("not a duration: %q", raw))
+		return def
+	}
+	if d < 0 {
+		l.fail(key, fmt.Sprintf("must not be negative, got %s", d))
+		return def
+	}
+	return d
+}
+
+// percent accepts 0-100; 0 disables the corresponding check
+func (l *loader) percent(key string, def float64) float64 {
+	raw := l.string(key, "")
+	if raw == "" {
+		return def
+	}
+	p, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.fail(key, fmt.Sprintf("not a number: %q", raw))
+		return def
+	}
+	if p < 0 || p > 100 {
+		l.fail(key, fmt.Sprintf("must be between 0 and 100, got %g", p))
+		return def
+	}
+	return p
+}
+
+func (l *loader) addr(key, def string) string {
+	raw := l.string(key, def)
+	if raw == "" {
+		return ""
+	}
+	if _, port, err := net.SplitHostPort(raw); err != nil {
+		l.fail(key, fmt.Sprintf("not a host:port address: %q", raw))
+	} else if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		l.fail(key, fmt.Sprintf("invalid port in %q", raw))
+	}
+	return raw
+}
+
+// seedURL reads an http(s) base URL, defaulting to the AxHV guest gateway on
+// the port of listenAddr
+func (l *loader) seedURL(key, listenAddr string) string {
+	raw := l.string(key, "")
+	if raw == "" {
+		_, port, err := net.SplitHostPort(listenAddr)
+		if err != nil {
+			return ""
+		}
+		return "http://" + net.JoinHostPort(axhv.GuestGateway, port)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.fail(key, fmt.Sprintf("not an http(s) URL: %q", raw))
+		return ""
+	}
+	return strings.TrimSuffix(raw, "/")
+}
+
+// portRange reads a "min-max" host port range, lxc.DefaultPortRange when unset
+func (l *loader) portRange(key string) lxc.PortRange {
+	raw := l.string(key, "")
+	if raw == "" {
+		return lxc.DefaultPortRange
+	}
+	r, err := lxc.ParsePortRange(raw)
+	if err != nil {
+		l.fail(key, err.Error())
+		return lxc.DefaultPortRange
+	}
+	return r
+}
+
+// applyEnvFile sets KEY=VALUE pairs from path for keys not already in the
+// environment. Blank lines and # comments are ignored; values may be quoted.
+func applyEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
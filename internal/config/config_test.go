@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aexon/internal/types"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ListenAddr != ":8500" {
+		t.Errorf("ListenAddr = %q, want :8500", cfg.ListenAddr)
+	}
+	if cfg.Workers != 2 {
+		t.Errorf("Workers = %d, want 2", cfg.Workers)
+	}
+	if cfg.JobRetention.Failed != 7*24*time.Hour {
+		t.Errorf("JobRetention.Failed = %s, want 168h", cfg.JobRetention.Failed)
+	}
+	if cfg.SeedURL != "http://172.16.0.1:8500" {
+		t.Errorf("SeedURL = %q, want the guest gateway on the API port", cfg.SeedURL)
+	}
+	if cfg.TerminalRecording.Dir == "" || cfg.TerminalRecording.Always {
+		t.Errorf("TerminalRecording = %+v, want on-request recording in the default dir", cfg.TerminalRecording)
+	}
+	if cfg.Auth == nil || cfg.Auth.Password.HashAlgorithm != "bcrypt" || len(cfg.Auth.SecretKey) == 0 {
+		t.Errorf("Auth = %+v, want the default policy and the fallback secret", cfg.Auth)
+	}
+	if len(cfg.AllowedImages) != 2 || cfg.AllowedImages[0] != "ubuntu*" {
+		t.Errorf("AllowedImages = %v, want the default allowlist", cfg.AllowedImages)
+	}
+}
+
+func TestLoadAggregatesProblems(t *testing.T) {
+	t.Setenv("AXION_WORKERS", "0")
+	t.Setenv("DB_PORT", "abc")
+	t.Setenv("JOB_RETENTION_FAILED", "soon")
+	t.Setenv("AXION_RESTART_CRASHED", "maybe")
+	t.Setenv("AXION_LXD_URL", "https://10.0.0.1:8443")
+	t.Setenv("AXION_IMAGE_FALLBACK", "ubuntu")
+	t.Setenv("AXION_DELETE_GRACE", "2m")
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "md5")
+	t.Setenv("AXION_ALLOWED_IMAGES", "ubuntu*,[debian")
+	t.Setenv("AXION_TIER_POLICIES", `{"pro":{"max_vcpu":-1}}`)
+
+	_, err := Load()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	for _, key := range []string{"AXION_WORKERS", "DB_PORT", "JOB_RETENTION_FAILED", "AXION_RESTART_CRASHED", "AXION_LXD_URL", "AXION_IMAGE_FALLBACK", "AXION_DELETE_GRACE", "PASSWORD_*", "AXION_ALLOWED_IMAGES", "AXION_TIER_POLICIES"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("error does not mention %s:\n%v", key, err)
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "axion.env")
+	content := "# comment\nAXION_WORKERS=8\nexport AXION_LISTEN_ADDR=\"127.0.0.1:9000\"\n\nAXION_MAX_INSTANCES=50\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AXION_CONFIG_FILE", path)
+	// The environment wins over the file
+	t.Setenv("AXION_MAX_INSTANCES", "10")
+	// Keys the file sets must be restored after the test
+	for _, key := range []string{"AXION_WORKERS", "AXION_LISTEN_ADDR"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Workers != 8 {
+		t.Errorf("Workers = %d, want 8", cfg.Workers)
+	}
+	if cfg.ListenAddr != "127.0.0.1:9000" {
+		t.Errorf("ListenAddr = %q, want 127.0.0.1:9000", cfg.ListenAddr)
+	}
+	if cfg.MaxInstances != 10 {
+		t.Errorf("MaxInstances = %d, want 10 from the environment", cfg.MaxInstances)
+	}
+}
+
+func TestLoadLXDRemotes(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	for _, path := range []string{cert, key} {
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("AXION_LXD_URL", "https://10.0.0.1:8443")
+	t.Setenv("AXION_CERT_PATH", cert)
+	t.Setenv("AXION_KEY_PATH", key)
+	t.Setenv("AXION_LXD_REMOTE_NAME", "eu")
+	t.Setenv("AXION_LXD_REMOTES", "us=https://10.1.0.1:8443, asia=https://10.2.0.1:8443")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.LXDRemotes) != 2 || cfg.LXDRemotes[0].Name != "us" || cfg.LXDRemotes[1].URL != "https://10.2.0.1:8443" {
+		t.Fatalf("LXDRemotes = %+v", cfg.LXDRemotes)
+	}
+	if cfg.LXDRemotes[0].CertPath != cert {
+		t.Errorf("remotes must share the client certificate, got %q", cfg.LXDRemotes[0].CertPath)
+	}
+
+	t.Setenv("AXION_LXD_REMOTES", "eu=https://10.1.0.1:8443,bad")
+	_, err = Load()
+	if err == nil || !strings.Contains(err.Error(), "duplicate remote name") || !strings.Contains(err.Error(), `"bad"`) {
+		t.Errorf("expected duplicate and malformed remotes to be rejected, got %v", err)
+	}
+}
+
+func TestLoadTierPolicies(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.TierPolicies.ForPlan(""); got != types.FreeTierPolicy() {
+		t.Errorf("default free policy = %+v, want %+v", got, types.FreeTierPolicy())
+	}
+	if got := cfg.TierPolicies.ForPlan("pro"); got.Name != "pro" || got.MaxVcpu != 0 || got.MaxTcpPorts != 0 {
+		t.Errorf("unconfigured paid plan = %+v, want no limits", got)
+	}
+
+	t.Setenv("AXION_TIER_POLICIES", `{"free":{"max_tcp_ports":5,"max_udp_ports":2},"pro":{"max_vcpu":8,"max_bandwidth_mbps":500}}`)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.TierPolicies.ForPlan("free"); got.Name != "free" || got.MaxTcpPorts != 5 || got.MaxUdpPorts != 2 {
+		t.Errorf("free = %+v, want the configured ports", got)
+	}
+	if got := cfg.TierPolicies.ForPlan("pro"); got.Name != "pro" || got.MaxVcpu != 8 || got.MaxBandwidthMbps != 500 {
+		t.Errorf("pro = %+v, want the configured caps", got)
+	}
+
+	t.Setenv("AXION_TIER_POLICIES", `{"pro":{"max_cpus":8}}`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "AXION_TIER_POLICIES:") {
+		t.Errorf("expected an unknown field to be rejected, got %v", err)
+	}
+}
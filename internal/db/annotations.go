@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// InstanceAnnotations is the instance metadata attached to published events.
+// Tags are those of the stack the instance belongs to, if any.
+type InstanceAnnotations struct {
+	Type  string
+	Owner string
+	Tags  []string
+}
+
+// GetAnnotations returns the annotations of an instance, or sql.ErrNoRows
+func (r *InstanceRepository) GetAnnotations(ctx context.Context, name string) (*InstanceAnnotations, error) {
+	query := `
+		SELECT i.type, i.owner, COALESCE(s.tags, '[]'::jsonb)
+		FROM instances i
+		LEFT JOIN stack_members m ON m.instance_name = i.name
+		LEFT JOIN stacks s ON s.id = m.stack_id
+		WHERE i.name = $1
+		LIMIT 1
+	`
+
+	var a InstanceAnnotations
+	var tags []byte
+	if err := r.db.QueryRowContext(ctx, query, name).Scan(&a.Type, &a.Owner, &tags); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tags, &a.Tags); err != nil {
+		return nil, fmt.Errorf("decode tags of %s: %w", name, err)
+	}
+	return &a, nil
+}
+
+func GetInstanceAnnotations(name string) (*InstanceAnnotations, error) {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.GetAnnotations(ctx, name)
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ============================================================================
+// BACKUP ENCRYPTION SETTINGS
+// ============================================================================
+
+// GetBackupEncryption returns the backup encryption mode and recipient public
+// key of an instance. Instances without a row are not encrypted ("none").
+func (r *InstanceRepository) GetBackupEncryption(ctx context.Context, name string) (mode, recipient string, err error) {
+	query := `SELECT mode, recipient FROM instance_backup_encryption WHERE instance_name = $1`
+
+	err = r.db.QueryRowContext(ctx, query, name).Scan(&mode, &recipient)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "none", "", nil
+	}
+	return mode, recipient, err
+}
+
+// SetBackupEncryption stores the backup encryption setting; mode "none" (or
+// empty) removes it. Only the public key is stored, never a private key.
+func (r *InstanceRepository) SetBackupEncryption(ctx context.Context, name, mode, recipient string) error {
+	if mode == "" || mode == "none" {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM instance_backup_encryption WHERE instance_name = $1`, name)
+		return err
+	}
+
+	query := `
+		INSERT INTO instance_backup_encryption (instance_name, mode, recipient, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (instance_name) DO UPDATE
+		SET mode = EXCLUDED.mode,
+		    recipient = EXCLUDED.recipient,
+		    updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, name, mode, recipient)
+	return err
+}
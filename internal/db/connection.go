@@ -59,6 +59,9 @@ func NewDBError(code DBErrorCode, msg string, err error) *DBError {
 // CONFIGURATION
 // ============================================================================
 
+// Config describes the PostgreSQL connection. PostgreSQL is the only engine:
+// the schema and the repositories use JSONB, advisory locks, SELECT ... FOR
+// UPDATE SKIP LOCKED and the pg_* catalogs.
 type Config struct {
 	Host            string
 	Port            int
@@ -74,20 +77,22 @@ type Config struct {
 	QueryTimeout    time.Duration
 }
 
+// DefaultConfig holds the defaults internal/config applies to unset DB_*
+// variables
 func DefaultConfig() *Config {
 	return &Config{
-		Host:            getEnv("DB_HOST", "localhost"),
-		Port:            getEnvInt("DB_PORT", 5432),
-		User:            getEnv("DB_USER", "axion"),
-		Password:        getEnv("DB_PASSWORD", "axion_password"),
-		Database:        getEnv("DB_NAME", "axion_db"),
-		SSLMode:         getEnv("DB_SSLMODE", "disable"),
-		MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
-		MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
-		ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
-		ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 10*time.Minute),
-		ConnectTimeout:  getEnvDuration("DB_CONNECT_TIMEOUT", 10*time.Second),
-		QueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 30*time.Second),
+		Host:            "localhost",
+		Port:            5432,
+		User:            "axion",
+		Password:        "axion_password",
+		Database:        "axion_db",
+		SSLMode:         "disable",
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 5 * time.Minute,
+		ConnMaxIdleTime: 10 * time.Minute,
+		ConnectTimeout:  10 * time.Second,
+		QueryTimeout:    30 * time.Second,
 	}
 }
 
@@ -107,8 +112,10 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// DSN pins the session timezone to UTC so NOW()/CURRENT_TIMESTAMP and every
+// TIMESTAMPTZ read back by the driver are UTC, regardless of the server default.
 func (c *Config) DSN() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=%d",
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=%d timezone=UTC",
 		c.Host, c.Port, c.User, c.Password, c.Database, c.SSLMode, int(c.ConnectTimeout.Seconds()))
 }
 
@@ -195,34 +202,34 @@ const (
 
 var (
 	globalService *Service
-	globalOnce    sync.Once
+	globalMu      sync.Mutex
 )
 
+// InitService connects the global service. A failed attempt leaves nothing
+// behind, so callers may retry until the database is reachable.
 func InitService(cfg *Config) (*Service, error) {
-	var initErr error
-	
-	globalOnce.Do(func() {
-		if cfg == nil {
-			cfg = DefaultConfig()
-		}
+	globalMu.Lock()
+	defer globalMu.Unlock()
 
-		if err := cfg.Validate(); err != nil {
-			initErr = err
-			return
-		}
+	if globalService != nil {
+		return globalService, nil
+	}
 
-		globalService, initErr = connect(cfg)
-		if initErr != nil {
-			return
-		}
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
 
-		log.Printf("[DB] Connected: host=%s database=%s", cfg.Host, cfg.Database)
-	})
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	if initErr != nil {
-		return nil, initErr
+	service, err := connect(cfg)
+	if err != nil {
+		return nil, err
 	}
+	globalService = service
 
+	log.Printf("[DB] Connected: host=%s database=%s", cfg.Host, cfg.Database)
 	return globalService, nil
 }
 
@@ -503,17 +510,6 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func getEnvInt(key string, fallback int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := fmt.Sscanf(value, "%d", new(int)); err == nil && intVal == 1 {
-			var result int
-			fmt.Sscanf(value, "%d", &result)
-			return result
-		}
-	}
-	return fallback
-}
-
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// INSTANCE DEVICES (host passthrough)
+// ============================================================================
+
+// ErrDeviceExists is returned when an instance already has a device with that name
+var ErrDeviceExists = errors.New("device already exists")
+
+type DeviceRepository struct {
+	db *Service
+}
+
+func NewDeviceRepository(db *Service) *DeviceRepository {
+	return &DeviceRepository{db: db}
+}
+
+const deviceColumns = `instance_name, device_name, device_type, config, created_at`
+
+func scanDevice(row rowScanner) (*types.InstanceDevice, error) {
+	var device types.InstanceDevice
+	var config []byte
+	if err := row.Scan(&device.Instance, &device.Name, &device.Type, &config, &device.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(config, &device.Config); err != nil {
+		return nil, fmt.Errorf("device %s/%s: invalid config: %w", device.Instance, device.Name, err)
+	}
+	return &device, nil
+}
+
+// Create stores a device; a duplicate name on the same instance returns ErrDeviceExists
+func (r *DeviceRepository) Create(ctx context.Context, device *types.InstanceDevice) error {
+	config, err := json.Marshal(device.Config)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO instance_devices (instance_name, device_name, device_type, config)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (instance_name, device_name) DO NOTHING
+		RETURNING created_at
+	`
+	err = r.db.QueryRowContext(ctx, query, device.Instance, device.Name, device.Type, config).Scan(&device.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrDeviceExists
+	}
+	return err
+}
+
+// Delete removes a device, or returns sql.ErrNoRows
+func (r *DeviceRepository) Delete(ctx context.Context, instanceName, deviceName string) error {
+	query := `DELETE FROM instance_devices WHERE instance_name = $1 AND device_name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, instanceName, deviceName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// List returns the devices of one instance
+func (r *DeviceRepository) List(ctx context.Context, instanceName string) ([]types.InstanceDevice, error) {
+	query := `SELECT ` + deviceColumns + ` FROM instance_devices WHERE instance_name = $1 ORDER BY device_name`
+	return r.query(ctx, query, instanceName)
+}
+
+// ListAll returns every persisted device, used by the startup reconciliation
+func (r *DeviceRepository) ListAll(ctx context.Context) ([]types.InstanceDevice, error) {
+	query := `SELECT ` + deviceColumns + ` FROM instance_devices ORDER BY instance_name, device_name`
+	return r.query(ctx, query)
+}
+
+func (r *DeviceRepository) query(ctx context.Context, query string, args ...interface{}) ([]types.InstanceDevice, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []types.InstanceDevice{}
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *device)
+	}
+	return devices, rows.Err()
+}
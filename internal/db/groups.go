@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// INSTANCE GROUPS
+// ============================================================================
+
+// Group is a named, logical set of instances that can be operated on together
+type Group struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Members     []string  `json:"members"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type GroupRepository struct {
+	db *Service
+}
+
+func NewGroupRepository(db *Service) *GroupRepository {
+	return &GroupRepository{db: db}
+}
+
+func (r *GroupRepository) Create(ctx context.Context, group *Group) error {
+	query := `
+		INSERT INTO instance_groups (name, description)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRowContext(ctx, query, group.Name, group.Description).
+		Scan(&group.ID, &group.CreatedAt)
+}
+
+// Get returns the group with its member names, or sql.ErrNoRows
+func (r *GroupRepository) Get(ctx context.Context, id string) (*Group, error) {
+	query := `SELECT id, name, COALESCE(description, ''), created_at FROM instance_groups WHERE id = $1`
+
+	var group Group
+	if err := r.db.QueryRowContext(ctx, query, id).
+		Scan(&group.ID, &group.Name, &group.Description, &group.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	members, err := r.ListMembers(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	group.Members = members
+
+	return &group, nil
+}
+
+func (r *GroupRepository) List(ctx context.Context) ([]Group, error) {
+	query := `
+		SELECT g.id, g.name, COALESCE(g.description, ''), g.created_at,
+		       COALESCE(array_to_string(array_agg(m.instance_name ORDER BY m.instance_name)
+		                FILTER (WHERE m.instance_name IS NOT NULL), ','), '')
+		FROM instance_groups g
+		LEFT JOIN instance_group_members m ON m.group_id = g.id
+		GROUP BY g.id
+		ORDER BY g.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var group Group
+		var members string
+		if err := rows.Scan(&group.ID, &group.Name, &group.Description, &group.CreatedAt, &members); err != nil {
+			return nil, err
+		}
+		group.Members = splitMembers(members)
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *GroupRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM instance_groups WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AddMember is idempotent; adding an existing member is a no-op
+func (r *GroupRepository) AddMember(ctx context.Context, groupID, instanceName string) error {
+	query := `
+		INSERT INTO instance_group_members (group_id, instance_name)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, groupID, instanceName); err != nil {
+		return fmt.Errorf("add member %s: %w", instanceName, err)
+	}
+	return nil
+}
+
+func (r *GroupRepository) RemoveMember(ctx context.Context, groupID, instanceName string) error {
+	query := `DELETE FROM instance_group_members WHERE group_id = $1 AND instance_name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, groupID, instanceName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *GroupRepository) ListMembers(ctx context.Context, groupID string) ([]string, error) {
+	query := `SELECT instance_name FROM instance_group_members WHERE group_id = $1 ORDER BY instance_name`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		members = append(members, name)
+	}
+
+	return members, rows.Err()
+}
+
+func splitMembers(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"path"
+)
+
+// ============================================================================
+// IMAGE DEFAULTS
+// ============================================================================
+
+// ImageDefaults is the sizing applied when a create request omits limits.
+// Image is a path.Match pattern, like the image allowlist ("alpine*").
+type ImageDefaults struct {
+	Image     string `json:"image"`
+	CPU       int    `json:"cpu"`
+	MemoryMiB int    `json:"memory_mib"`
+	DiskGB    int    `json:"disk_gb"`
+}
+
+// GlobalImageDefaults is used when no image-specific entry matches
+var GlobalImageDefaults = ImageDefaults{Image: "*", CPU: 1, MemoryMiB: 512, DiskGB: 10}
+
+type ImageDefaultsRepository struct {
+	db *Service
+}
+
+func NewImageDefaultsRepository(db *Service) *ImageDefaultsRepository {
+	return &ImageDefaultsRepository{db: db}
+}
+
+func (r *ImageDefaultsRepository) List(ctx context.Context) ([]ImageDefaults, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT image, cpu, memory_mib, disk_gb FROM image_defaults ORDER BY image`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defaults := []ImageDefaults{}
+	for rows.Next() {
+		var d ImageDefaults
+		if err := rows.Scan(&d.Image, &d.CPU, &d.MemoryMiB, &d.DiskGB); err != nil {
+			return nil, err
+		}
+		defaults = append(defaults, d)
+	}
+	return defaults, rows.Err()
+}
+
+// Resolve returns the defaults for an image and whether an image-specific entry
+// matched. An exact entry wins, then the longest matching pattern; otherwise
+// GlobalImageDefaults is returned.
+func (r *ImageDefaultsRepository) Resolve(ctx context.Context, image string) (ImageDefaults, bool, error) {
+	entries, err := r.List(ctx)
+	if err != nil {
+		return GlobalImageDefaults, false, err
+	}
+	d, ok := MatchImageDefaults(entries, image)
+	return d, ok, nil
+}
+
+// MatchImageDefaults picks the entry that applies to image
+func MatchImageDefaults(entries []ImageDefaults, image string) (ImageDefaults, bool) {
+	var best *ImageDefaults
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Image == image {
+			return *entry, true
+		}
+		if ok, err := path.Match(entry.Image, image); err == nil && ok {
+			if best == nil || len(entry.Image) > len(best.Image) {
+				best = entry
+			}
+		}
+	}
+	if best == nil {
+		return GlobalImageDefaults, false
+	}
+	return *best, true
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	"aexon/internal/pagination"
 	"aexon/internal/types"
 )
 
@@ -15,6 +17,10 @@ import (
 // INSTANCE REPOSITORY
 // ============================================================================
 
+// ErrInstanceNotFound is returned (wrapped with the name) when no instance row
+// matches. Check it with errors.Is.
+var ErrInstanceNotFound = errors.New("instance not found")
+
 type InstanceRepository struct {
 	db *Service
 }
@@ -28,16 +34,35 @@ func NewInstanceRepository(db *Service) *InstanceRepository {
 // ============================================================================
 
 func (r *InstanceRepository) Create(ctx context.Context, instance *types.Instance) error {
-	limitsJSON, err := json.Marshal(instance.Limits)
+	limitsJSON, err := json.Marshal(DurableLimits(instance.Limits))
 	if err != nil {
 		return fmt.Errorf("marshal limits: %w", err)
 	}
 
+	var probeJSON sql.NullString
+	if instance.ReadinessProbe != nil {
+		data, err := json.Marshal(instance.ReadinessProbe)
+		if err != nil {
+			return fmt.Errorf("marshal readiness probe: %w", err)
+		}
+		probeJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	var sourceJSON sql.NullString
+	if instance.ConfigSource != nil {
+		data, err := json.Marshal(instance.ConfigSource)
+		if err != nil {
+			return fmt.Errorf("marshal config source: %w", err)
+		}
+		sourceJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
 	query := `
 		INSERT INTO instances (
 			name, image, limits, user_data, type,
-			backup_schedule, backup_retention, backup_enabled
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			backup_schedule, backup_retention, backup_enabled,
+			description, storage_pool, owner, readiness_probe, config_source
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
 	_, err = r.db.ExecContext(ctx, query,
@@ -49,6 +74,11 @@ func (r *InstanceRepository) Create(ctx context.Context, instance *types.Instanc
 		instance.BackupSchedule,
 		instance.BackupRetention,
 		instance.BackupEnabled,
+		instance.Description,
+		instance.StoragePool,
+		instance.Owner,
+		probeJSON,
+		sourceJSON,
 	)
 
 	return err
@@ -58,9 +88,13 @@ func (r *InstanceRepository) Get(ctx context.Context, name string) (*types.Insta
 	query := `
 		SELECT i.name, i.image, i.limits, i.user_data, i.type,
 		       i.backup_schedule, i.backup_retention, i.backup_enabled,
-		       COALESCE(l.ip, '') as ip_address
+		       i.description, i.desired_state, i.storage_pool, i.cloud_init_status, i.owner,
+		       i.config_source,
+		       COALESCE(l.ip, NULLIF(v.ipv4, ''), '') as ip_address,
+		       COALESCE(NULLIF(v.status, ''), 'UNKNOWN') as status
 		FROM instances i
 		LEFT JOIN ip_leases l ON l.instance_name = i.name
+		LEFT JOIN instance_volatile v ON v.instance_name = i.name
 		WHERE i.name = $1
 	`
 
@@ -68,6 +102,7 @@ func (r *InstanceRepository) Get(ctx context.Context, name string) (*types.Insta
 
 	var instance types.Instance
 	var limitsJSON string
+	var sourceJSON sql.NullString
 
 	err := row.Scan(
 		&instance.Name,
@@ -78,12 +113,19 @@ func (r *InstanceRepository) Get(ctx context.Context, name string) (*types.Insta
 		&instance.BackupSchedule,
 		&instance.BackupRetention,
 		&instance.BackupEnabled,
+		&instance.Description,
+		&instance.DesiredState,
+		&instance.StoragePool,
+		&instance.CloudInitStatus,
+		&instance.Owner,
+		&sourceJSON,
 		&instance.IpAddress, // Fetch IP
+		&instance.Status,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("instance not found: %s", name)
+			return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
 		}
 		return nil, err
 	}
@@ -91,29 +133,72 @@ func (r *InstanceRepository) Get(ctx context.Context, name string) (*types.Insta
 	if err := json.Unmarshal([]byte(limitsJSON), &instance.Limits); err != nil {
 		return nil, fmt.Errorf("unmarshal limits: %w", err)
 	}
+	if instance.ConfigSource, err = decodeConfigSource(sourceJSON); err != nil {
+		return nil, err
+	}
 
 	// Set default retention if zero
 	if instance.BackupRetention == 0 {
 		instance.BackupRetention = 7
 	}
 
-	// Default status
-	instance.Status = "UNKNOWN"
-
 	return &instance, nil
 }
 
+// decodeConfigSource reads the config_source column; NULL means the
+// instance was not provisioned from a Git repository
+func decodeConfigSource(raw sql.NullString) (*types.ConfigSource, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+	var source types.ConfigSource
+	if err := json.Unmarshal([]byte(raw.String), &source); err != nil {
+		return nil, fmt.Errorf("unmarshal config source: %w", err)
+	}
+	return &source, nil
+}
+
+// InstanceListing is what GET /instances accepts for sorting and paging.
+// Without ?limit= every instance is returned, as before pagination existed.
+var InstanceListing = pagination.Resource{
+	Columns: map[string]string{
+		"name":       "i.name",
+		"image":      "i.image",
+		"type":       "i.type",
+		"owner":      "i.owner",
+		"status":     "status",
+		"created_at": "i.created_at",
+	},
+	Tiebreak:     "i.name",
+	DefaultSort:  "name",
+	DefaultOrder: "asc",
+	MaxLimit:     500,
+}
+
 func (r *InstanceRepository) List(ctx context.Context) ([]types.Instance, error) {
+	return r.list(ctx, "ORDER BY i.name")
+}
+
+// ListPage returns one page of instances; p must come from pagination.Parse
+// with InstanceListing
+func (r *InstanceRepository) ListPage(ctx context.Context, p pagination.Params) ([]types.Instance, error) {
+	return r.list(ctx, p.OrderBy()+" LIMIT $1 OFFSET $2", p.LimitArg(), p.Offset)
+}
+
+func (r *InstanceRepository) list(ctx context.Context, tail string, args ...interface{}) ([]types.Instance, error) {
 	query := `
 		SELECT i.name, i.image, i.limits, i.user_data, i.type,
 		       i.backup_schedule, i.backup_retention, i.backup_enabled,
-		       COALESCE(l.ip, '') as ip_address
+		       i.description, i.desired_state, i.storage_pool, i.cloud_init_status, i.owner,
+		       i.config_source,
+		       COALESCE(l.ip, NULLIF(v.ipv4, ''), '') as ip_address,
+		       COALESCE(NULLIF(v.status, ''), 'UNKNOWN') as status
 		FROM instances i
 		LEFT JOIN ip_leases l ON l.instance_name = i.name
-		ORDER BY i.name
-	`
+		LEFT JOIN instance_volatile v ON v.instance_name = i.name
+	` + tail
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +209,7 @@ func (r *InstanceRepository) List(ctx context.Context) ([]types.Instance, error)
 	for rows.Next() {
 		var instance types.Instance
 		var limitsJSON string
+		var sourceJSON sql.NullString
 
 		err := rows.Scan(
 			&instance.Name,
@@ -134,7 +220,14 @@ func (r *InstanceRepository) List(ctx context.Context) ([]types.Instance, error)
 			&instance.BackupSchedule,
 			&instance.BackupRetention,
 			&instance.BackupEnabled,
+			&instance.Description,
+			&instance.DesiredState,
+			&instance.StoragePool,
+			&instance.CloudInitStatus,
+			&instance.Owner,
+			&sourceJSON,
 			&instance.IpAddress,
+			&instance.Status,
 		)
 
 		if err != nil {
@@ -145,15 +238,15 @@ func (r *InstanceRepository) List(ctx context.Context) ([]types.Instance, error)
 			log.Printf("[Instances] Failed to unmarshal limits for %s: %v", instance.Name, err)
 			instance.Limits = make(map[string]string)
 		}
+		if instance.ConfigSource, err = decodeConfigSource(sourceJSON); err != nil {
+			log.Printf("[Instances] Failed to unmarshal config source for %s: %v", instance.Name, err)
+		}
 
 		// Set default retention
 		if instance.BackupRetention == 0 {
 			instance.BackupRetention = 7
 		}
 
-		// Default status (we'll update this with real AxHV status in ListInstances handler)
-		instance.Status = "UNKNOWN"
-
 		instances = append(instances, instance)
 	}
 
@@ -161,7 +254,7 @@ func (r *InstanceRepository) List(ctx context.Context) ([]types.Instance, error)
 }
 
 func (r *InstanceRepository) Update(ctx context.Context, instance *types.Instance) error {
-	limitsJSON, err := json.Marshal(instance.Limits)
+	limitsJSON, err := json.Marshal(DurableLimits(instance.Limits))
 	if err != nil {
 		return fmt.Errorf("marshal limits: %w", err)
 	}
@@ -174,7 +267,8 @@ func (r *InstanceRepository) Update(ctx context.Context, instance *types.Instanc
 		    type = $5,
 		    backup_schedule = $6,
 		    backup_retention = $7,
-		    backup_enabled = $8
+		    backup_enabled = $8,
+		    description = $9
 		WHERE name = $1
 	`
 
@@ -187,6 +281,7 @@ func (r *InstanceRepository) Update(ctx context.Context, instance *types.Instanc
 		instance.BackupSchedule,
 		instance.BackupRetention,
 		instance.BackupEnabled,
+		instance.Description,
 	)
 
 	if err != nil {
@@ -199,7 +294,7 @@ func (r *InstanceRepository) Update(ctx context.Context, instance *types.Instanc
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("instance not found: %s", instance.Name)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instance.Name)
 	}
 
 	return nil
@@ -219,12 +314,56 @@ func (r *InstanceRepository) Delete(ctx context.Context, name string) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("instance not found: %s", name)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
 	}
 
 	return nil
 }
 
+// ErrInstanceExists is returned by Rename when the new name is taken
+var ErrInstanceExists = errors.New("instance already exists")
+
+// Rename moves an instance to newName in one transaction: the row itself
+// (tables referencing it follow through ON UPDATE CASCADE), its IP lease and
+// the pending jobs that target it. Jobs already running or finished keep the
+// old name, as does the metrics history.
+func (r *InstanceRepository) Rename(ctx context.Context, oldName, newName string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM instances WHERE name = $1)`, newName).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: %s", ErrInstanceExists, newName)
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE instances SET name = $2 WHERE name = $1`, oldName, newName)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, oldName)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE ip_leases SET instance_name = $2 WHERE instance_name = $1`, oldName, newName); err != nil {
+		return fmt.Errorf("move ip lease: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE jobs SET target = $2 WHERE target = $1 AND status = $3`, oldName, newName, types.JobPending); err != nil {
+		return fmt.Errorf("retarget pending jobs: %w", err)
+	}
+
+	return tx.Commit()
+}
+
 // ============================================================================
 // BACKUP OPERATIONS
 // ============================================================================
@@ -249,7 +388,7 @@ func (r *InstanceRepository) UpdateBackupConfig(ctx context.Context, name string
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("instance not found: %s", name)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
 	}
 
 	return nil
@@ -300,8 +439,10 @@ func (r *InstanceRepository) GetWithBackupInfo(ctx context.Context, name string,
 // LIMITS UPDATE
 // ============================================================================
 
+// UpdateLimits replaces the stored limits. Volatile keys (status, volatile.*)
+// are dropped: observed state goes through UpdateVolatile instead.
 func (r *InstanceRepository) UpdateLimits(ctx context.Context, name string, limits map[string]string) error {
-	limitsJSON, err := json.Marshal(limits)
+	limitsJSON, err := json.Marshal(DurableLimits(limits))
 	if err != nil {
 		return fmt.Errorf("marshal limits: %w", err)
 	}
@@ -319,7 +460,98 @@ func (r *InstanceRepository) UpdateLimits(ctx context.Context, name string, limi
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("instance not found: %s", name)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+// SetDesiredState records the last user-requested state (RUNNING or STOPPED),
+// which lets the status distinguish an intentional stop from a crash
+func (r *InstanceRepository) SetDesiredState(ctx context.Context, name string, state string) error {
+	query := `UPDATE instances SET desired_state = $1 WHERE name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, state, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+// SetCloudInitStatus records the provisioning state reported by cloud-init
+func (r *InstanceRepository) SetCloudInitStatus(ctx context.Context, name string, status string) error {
+	query := `UPDATE instances SET cloud_init_status = $1 WHERE name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, status, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+// UpdateUserData replaces the stored cloud-init user_data
+func (r *InstanceRepository) UpdateUserData(ctx context.Context, name string, userData string) error {
+	query := `UPDATE instances SET user_data = $1 WHERE name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, userData, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+// SetGitConfig stores the user_data fetched from the instance's config
+// source together with the source, whose Commit records what was read
+func (r *InstanceRepository) SetGitConfig(ctx context.Context, name string, userData string, source types.ConfigSource) error {
+	data, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("marshal config source: %w", err)
+	}
+
+	query := `UPDATE instances SET user_data = $1, config_source = $2 WHERE name = $3`
+
+	result, err := r.db.ExecContext(ctx, query, userData, string(data), name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
 	}
 
 	return nil
@@ -348,7 +580,7 @@ func (r *InstanceRepository) CreateBatch(ctx context.Context, instances []*types
 	`
 
 	for _, instance := range instances {
-		limitsJSON, err := json.Marshal(instance.Limits)
+		limitsJSON, err := json.Marshal(DurableLimits(instance.Limits))
 		if err != nil {
 			return fmt.Errorf("marshal limits for %s: %w", instance.Name, err)
 		}
@@ -415,6 +647,15 @@ func (r *InstanceRepository) Count(ctx context.Context) (int, error) {
 	return count, err
 }
 
+// CountByOwner counts the instances created by a user
+func (r *InstanceRepository) CountByOwner(ctx context.Context, owner string) (int, error) {
+	query := `SELECT COUNT(*) FROM instances WHERE owner = $1`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, owner).Scan(&count)
+	return count, err
+}
+
 func (r *InstanceRepository) ListByType(ctx context.Context, instanceType string) ([]types.Instance, error) {
 	query := `
 		SELECT name, image, limits, user_data, type,
@@ -551,8 +792,38 @@ func UpdateInstanceBackupConfig(name string, enabled bool, schedule string, rete
 	return repo.UpdateBackupConfig(ctx, name, enabled, schedule, retention)
 }
 
-func UpdateInstanceStatusAndLimits(name string, limits map[string]string) error {
+func UpdateInstanceLimits(name string, limits map[string]string) error {
 	ctx := context.Background()
 	repo := NewInstanceRepository(GetService())
 	return repo.UpdateLimits(ctx, name, limits)
 }
+
+func UpdateInstanceVolatile(name string, state VolatileState) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.UpdateVolatile(ctx, name, state)
+}
+
+func UpdateInstanceVolatileStatus(name string, status string) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.UpdateVolatileStatus(ctx, name, status)
+}
+
+func SetInstanceDesiredState(name string, state string) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.SetDesiredState(ctx, name, state)
+}
+
+func SetInstanceCloudInitStatus(name string, status string) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.SetCloudInitStatus(ctx, name, status)
+}
+
+func SetInstanceGitConfig(name string, userData string, source types.ConfigSource) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.SetGitConfig(ctx, name, userData, source)
+}
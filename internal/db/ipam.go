@@ -4,17 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"time"
 )
 
+// ErrPoolFull is returned when a network has no address left to hand out
+var ErrPoolFull = errors.New("POOL_FULL")
+
 type Network struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	CIDR      string    `json:"cidr"`
-	Gateway   string    `json:"gateway"`
+	Gateway   string    `json:"gateway"` // Optional on create: defaults to the first host address
 	DNS1      string    `json:"dns1"`
 	VlanID    int       `json:"vlan_id"`
 	IsPublic  bool      `json:"is_public"`
@@ -36,7 +40,7 @@ func (s *Service) AllocateIP(ctx context.Context, instanceName string) (string,
 
 	// 3. Try allocation in each network
 	for _, net := range networks {
-		ip, err := s.tryAllocateInNetwork(ctx, net, instanceName)
+		ip, err := s.tryAllocateInNetwork(ctx, net, instanceName, false)
 		if err == nil {
 			log.Printf("[IPAM] Allocated %s from network %s (%s)", ip, net.Name, net.CIDR)
 			return ip, nil
@@ -50,20 +54,56 @@ func (s *Service) AllocateIP(ctx context.Context, instanceName string) (string,
 
 // AllocateInNetwork allocates an IP in a specific network pool.
 func (s *Service) AllocateInNetwork(ctx context.Context, networkID string, instanceName string) (string, error) {
-	var net Network
-	query := `SELECT id, name, cidr, gateway, dns1, vlan_id, is_public FROM networks WHERE id = $1`
-	err := s.QueryRowContext(ctx, query, networkID).Scan(&net.ID, &net.Name, &net.CIDR, &net.Gateway, &net.DNS1, &net.VlanID, &net.IsPublic)
+	net, err := s.getNetwork(ctx, networkID)
 	if err != nil {
 		return "", fmt.Errorf("network not found: %w", err)
 	}
 
-	ip, err := s.tryAllocateInNetwork(ctx, net, instanceName)
+	ip, err := s.tryAllocateInNetwork(ctx, net, instanceName, false)
 	if err != nil {
 		return "", fmt.Errorf("allocation failed in pool %s: %w", net.Name, err)
 	}
 	return ip, nil
 }
 
+// IPPreview is the address an allocation would get right now
+type IPPreview struct {
+	IP      string  `json:"ip"`
+	Network Network `json:"network"`
+}
+
+// PreviewIP runs the same network selection and first-free scan as AllocateIP
+// (AllocateInNetwork when networkID is set) but rolls the reservation back, so
+// no address is held and a concurrent create may still take the previewed one.
+// Returns sql.ErrNoRows for an unknown network and ErrPoolFull when nothing is free.
+func (s *Service) PreviewIP(ctx context.Context, networkID string, instanceName string) (*IPPreview, error) {
+	var networks []Network
+	if networkID != "" {
+		n, err := s.getNetwork(ctx, networkID)
+		if err != nil {
+			return nil, err
+		}
+		networks = []Network{n}
+	} else {
+		var err error
+		networks, err = s.getAvailableNetworks(ctx, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch networks: %w", err)
+		}
+	}
+
+	for _, n := range networks {
+		ip, err := s.tryAllocateInNetwork(ctx, n, instanceName, true)
+		if err == nil {
+			return &IPPreview{IP: ip, Network: n}, nil
+		}
+		if !errors.Is(err, ErrPoolFull) {
+			return nil, fmt.Errorf("preview failed in pool %s: %w", n.Name, err)
+		}
+	}
+	return nil, ErrPoolFull
+}
+
 func (s *Service) getAvailableNetworks(ctx context.Context, isPro bool) ([]Network, error) {
 	query := `SELECT id, name, cidr, gateway, dns1, vlan_id, is_public FROM networks WHERE is_public = $1 ORDER BY created_at ASC`
 
@@ -110,15 +150,8 @@ func (s *Service) GetNetworksWithStats(ctx context.Context) ([]NetworkStats, err
 			return nil, err
 		}
 
-		// Calculate Total IPs
-		_, ipNet, _ := net.ParseCIDR(n.CIDR)
-		if ipNet != nil {
-			ones, _ := ipNet.Mask.Size()
-			n.TotalIPs = 1 << (32 - ones)
-			if n.TotalIPs > 2 {
-				n.TotalIPs -= 3 // Network, Gateway, Broadcast
-			}
-		}
+		// Total IPs is what the allocator can hand out, so usage matches reality
+		n.TotalIPs, _ = PoolCapacity(n.CIDR)
 
 		// Count Used IPs
 		countQuery := `SELECT COUNT(*) FROM ip_leases WHERE network_id = $1 AND instance_name IS NOT NULL`
@@ -135,27 +168,128 @@ func (s *Service) GetNetworksWithStats(ctx context.Context) ([]NetworkStats, err
 }
 
 func (s *Service) CreateNetwork(ctx context.Context, n Network) error {
-	query := `INSERT INTO networks (name, cidr, gateway, is_public) VALUES ($1, $2, $3, $4)`
-	_, err := s.ExecContext(ctx, query, n.Name, n.CIDR, n.Gateway, n.IsPublic)
+	query := `INSERT INTO networks (name, cidr, gateway, dns1, is_public) VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), '1.1.1.1'), $5)`
+	_, err := s.ExecContext(ctx, query, n.Name, n.CIDR, n.Gateway, n.DNS1, n.IsPublic)
 	return err
 }
 
-func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, instanceName string) (string, error) {
-	// 1. Calculate Range
-	startIP, endIP, err := CidrToRange(netDef.CIDR)
+// NetworkFieldError identifies the network field that failed validation
+type NetworkFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *NetworkFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidateNetwork checks a network definition before it is stored: the CIDR must be
+// an IPv4 network, the gateway a host address inside it (not the network or
+// broadcast address) and the DNS server, when set, a valid IPv4 address.
+func ValidateNetwork(n Network) error {
+	_, ipnet, err := net.ParseCIDR(n.CIDR)
+	if err != nil {
+		return &NetworkFieldError{Field: "cidr", Message: "invalid CIDR notation"}
+	}
+	if ipnet.IP.To4() == nil {
+		return &NetworkFieldError{Field: "cidr", Message: "only IPv4 networks are supported"}
+	}
+
+	gateway := net.ParseIP(n.Gateway).To4()
+	if gateway == nil {
+		return &NetworkFieldError{Field: "gateway", Message: "must be a valid IPv4 address"}
+	}
+	if !ipnet.Contains(gateway) {
+		return &NetworkFieldError{Field: "gateway", Message: fmt.Sprintf("%s is outside %s", n.Gateway, ipnet.String())}
+	}
+
+	// /31 and /32 have no network or broadcast address (RFC 3021)
+	if ones, _ := ipnet.Mask.Size(); ones < 31 {
+		mask := binary.BigEndian.Uint32(ipnet.Mask)
+		network := binary.BigEndian.Uint32(ipnet.IP.To4())
+		broadcast := network | ^mask
+		gw := binary.BigEndian.Uint32(gateway)
+		if gw == network {
+			return &NetworkFieldError{Field: "gateway", Message: "cannot be the network address"}
+		}
+		if gw == broadcast {
+			return &NetworkFieldError{Field: "gateway", Message: "cannot be the broadcast address"}
+		}
+	}
+
+	if n.DNS1 != "" && net.ParseIP(n.DNS1).To4() == nil {
+		return &NetworkFieldError{Field: "dns1", Message: "must be a valid IPv4 address"}
+	}
+
+	return nil
+}
+
+// ValidateNetworkExpansion checks that newCIDR can replace oldCIDR in place: it
+// must be a strictly larger IPv4 network containing the old one and every
+// existing lease, and must not overlap any of the other networks.
+func ValidateNetworkExpansion(oldCIDR, newCIDR string, leases []string, others []Network) error {
+	_, oldNet, err := net.ParseCIDR(oldCIDR)
+	if err != nil {
+		return fmt.Errorf("stored CIDR %q is invalid: %w", oldCIDR, err)
+	}
+	ip, newNet, err := net.ParseCIDR(newCIDR)
+	if err != nil || ip.To4() == nil {
+		return &NetworkFieldError{Field: "cidr", Message: "must be an IPv4 CIDR"}
+	}
+	if !ip.Equal(newNet.IP) {
+		return &NetworkFieldError{Field: "cidr", Message: fmt.Sprintf("%s has host bits set; use %s", newCIDR, newNet.String())}
+	}
+
+	oldOnes, _ := oldNet.Mask.Size()
+	newOnes, _ := newNet.Mask.Size()
+	if newOnes >= oldOnes {
+		return &NetworkFieldError{Field: "cidr", Message: fmt.Sprintf("prefix /%d must be shorter than the current /%d", newOnes, oldOnes)}
+	}
+	if !newNet.Contains(oldNet.IP) {
+		return &NetworkFieldError{Field: "cidr", Message: fmt.Sprintf("%s does not contain the current range %s", newNet.String(), oldNet.String())}
+	}
+
+	for _, lease := range leases {
+		if leaseIP := net.ParseIP(lease); leaseIP == nil || !newNet.Contains(leaseIP) {
+			return &NetworkFieldError{Field: "cidr", Message: fmt.Sprintf("lease %s would fall outside %s", lease, newNet.String())}
+		}
+	}
+
+	for _, other := range others {
+		_, otherNet, err := net.ParseCIDR(other.CIDR)
+		if err != nil {
+			continue
+		}
+		// Two CIDR blocks overlap only if one contains the other's base address
+		if newNet.Contains(otherNet.IP) || otherNet.Contains(newNet.IP) {
+			return &NetworkFieldError{Field: "cidr", Message: fmt.Sprintf("%s overlaps network %s (%s)", newNet.String(), other.Name, other.CIDR)}
+		}
+	}
+
+	return nil
+}
+
+// tryAllocateInNetwork reserves the first free address of netDef. With dryRun
+// the reservation is rolled back instead of committed.
+func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, instanceName string, dryRun bool) (string, error) {
+	// 1. Calculate Range (skips the network address, the gateway slot and broadcast)
+	currentIP, endIP, err := AllocatableRange(netDef.CIDR)
 	if err != nil {
 		return "", err
 	}
 
 	// DEBUG: Log the calculated range
-	log.Printf("[IPAM-DEBUG] Network=%s CIDR=%s StartIP=%d EndIP=%d TotalIPs=%d", netDef.Name, netDef.CIDR, startIP, endIP, endIP-startIP)
-
-	// Start searching from Start + 2 (Skipping Network .0 and Gateway .1)
-	currentIP := startIP + 2
+	log.Printf("[IPAM-DEBUG] Network=%s CIDR=%s FirstIP=%d EndIP=%d Allocatable=%d", netDef.Name, netDef.CIDR, currentIP, endIP, endIP-currentIP)
 
-	// 2. Fetch ALL used IPs in this network (ignoring placeholders)
-	query := `SELECT ip FROM ip_leases WHERE network_id = $1 AND instance_name IS NOT NULL`
-	rows, err := s.QueryContext(ctx, query, netDef.ID)
+	// 2. Fetch ALL used IPs in this network (ignoring placeholders), plus the
+	// ones still held for another instance name by the reuse grace window
+	query := `
+		SELECT ip FROM ip_leases
+		WHERE network_id = $1
+		  AND (instance_name IS NOT NULL OR (released_at > $2 AND released_name <> $3))
+	`
+	graceCutoff := time.Now().UTC().Add(-IPReuseGrace())
+	rows, err := s.QueryContext(ctx, query, netDef.ID, graceCutoff, instanceName)
 	if err != nil {
 		return "", err
 	}
@@ -193,8 +327,11 @@ func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, inst
 			if existsGlobal {
 				// Row exists - try to claim it for THIS network
 				res, err := tx.ExecContext(ctx,
-					"UPDATE ip_leases SET instance_name = $1, allocated_at = $2, network_id = $3 WHERE ip = $4 AND instance_name IS NULL",
-					instanceName, time.Now(), netDef.ID, ipStr)
+					`UPDATE ip_leases
+					 SET instance_name = $1, allocated_at = $2, network_id = $3, released_name = NULL, released_at = NULL
+					 WHERE ip = $4 AND instance_name IS NULL
+					   AND (released_at IS NULL OR released_at <= $5 OR released_name = $1)`,
+					instanceName, time.Now().UTC(), netDef.ID, ipStr, graceCutoff)
 				if err != nil {
 					log.Printf("[IPAM-DEBUG] UPDATE failed for %s: %v", ipStr, err)
 					tx.Rollback()
@@ -210,7 +347,7 @@ func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, inst
 				// Insert new lease
 				_, err := tx.ExecContext(ctx,
 					"INSERT INTO ip_leases (ip, instance_name, allocated_at, network_id) VALUES ($1, $2, $3, $4)",
-					ipStr, instanceName, time.Now(), netDef.ID)
+					ipStr, instanceName, time.Now().UTC(), netDef.ID)
 				if err != nil {
 					log.Printf("[IPAM-DEBUG] INSERT failed for %s: %v", ipStr, err)
 					tx.Rollback()
@@ -218,6 +355,11 @@ func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, inst
 				}
 			}
 
+			if dryRun {
+				tx.Rollback()
+				return ipStr, nil
+			}
+
 			if err := tx.Commit(); err != nil {
 				log.Printf("[IPAM-DEBUG] COMMIT failed for %s: %v", ipStr, err)
 				continue
@@ -229,21 +371,70 @@ func (s *Service) tryAllocateInNetwork(ctx context.Context, netDef Network, inst
 	}
 
 	log.Printf("[IPAM-DEBUG] POOL_FULL after checking %d IPs", attemptCount)
-	return "", fmt.Errorf("POOL_FULL")
+	return "", ErrPoolFull
+}
+
+// getNetwork loads a single network definition. Returns sql.ErrNoRows if missing.
+func (s *Service) getNetwork(ctx context.Context, networkID string) (Network, error) {
+	var n Network
+	query := `SELECT id, name, cidr, gateway, dns1, vlan_id, is_public FROM networks WHERE id = $1`
+	err := s.QueryRowContext(ctx, query, networkID).Scan(&n.ID, &n.Name, &n.CIDR, &n.Gateway, &n.DNS1, &n.VlanID, &n.IsPublic)
+	return n, err
+}
+
+// FreeIPCount returns how many addresses tryAllocateInNetwork could still hand out.
+// With an empty networkID it sums every pool AllocateIP would consider.
+func (s *Service) FreeIPCount(ctx context.Context, networkID string) (int, error) {
+	var networks []Network
+	if networkID != "" {
+		n, err := s.getNetwork(ctx, networkID)
+		if err != nil {
+			return 0, err
+		}
+		networks = []Network{n}
+	} else {
+		var err error
+		networks, err = s.getAvailableNetworks(ctx, false)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	free := 0
+	for _, n := range networks {
+		capacity, err := PoolCapacity(n.CIDR)
+		if err != nil || capacity == 0 {
+			continue
+		}
+
+		var used int
+		countQuery := `SELECT COUNT(*) FROM ip_leases WHERE network_id = $1 AND instance_name IS NOT NULL`
+		if err := s.QueryRowContext(ctx, countQuery, n.ID).Scan(&used); err != nil {
+			return 0, err
+		}
+		if capacity > used {
+			free += capacity - used
+		}
+	}
+
+	return free, nil
 }
 
 // ReleaseIP frees the IP assigned to an instance.
+// The previous owner is remembered so that recreating an instance with the
+// same name can get its address back (see ReclaimIP).
 func (s *Service) ReleaseIP(ctx context.Context, instanceName string) error {
 	// We just clear the ownership. We keep the row (switch to Pre-populated mode basically)
 	// Or we could Delete if we want to stay Sparse.
 	// For "Hybrid" stability, keeping it NULL is fine and safer for logs.
 	query := `
         UPDATE ip_leases 
-        SET instance_name = NULL, allocated_at = NULL 
+        SET instance_name = NULL, allocated_at = NULL,
+            released_name = instance_name, released_at = $2
         WHERE instance_name = $1
     `
 
-	_, err := s.ExecContext(ctx, query, instanceName)
+	_, err := s.ExecContext(ctx, query, instanceName, time.Now().UTC())
 	if err != nil {
 		return fmt.Errorf("failed to release IP for instance %s: %w", instanceName, err)
 	}
@@ -251,6 +442,217 @@ func (s *Service) ReleaseIP(ctx context.Context, instanceName string) error {
 	return nil
 }
 
+// IPReassignment is the outcome of ReassignIP
+type IPReassignment struct {
+	IP        string `json:"ip"`
+	NetworkID string `json:"network_id"`
+	// OldIP is empty when the instance had no lease
+	OldIP        string `json:"previous_ip,omitempty"`
+	OldNetworkID string `json:"previous_network_id,omitempty"`
+}
+
+// ReassignIP replaces the lease of an instance in a single transaction: the
+// current address is released (held for the instance by the reuse grace window,
+// see RestoreIP) and requestedIP, or the first free address when empty, is
+// claimed in networkID. Returns sql.ErrNoRows for an unknown network,
+// *NetworkFieldError for a requested IP that cannot be used and ErrPoolFull.
+func (s *Service) ReassignIP(ctx context.Context, instanceName, networkID, requestedIP string) (*IPReassignment, error) {
+	netDef, err := s.getNetwork(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	first, end, err := AllocatableRange(netDef.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	var wanted uint32
+	if requestedIP != "" {
+		ip := net.ParseIP(requestedIP).To4()
+		if ip == nil {
+			return nil, &NetworkFieldError{Field: "ip", Message: "must be a valid IPv4 address"}
+		}
+		wanted = binary.BigEndian.Uint32(ip)
+		if wanted < first || wanted >= end {
+			return nil, &NetworkFieldError{Field: "ip", Message: fmt.Sprintf("%s is not allocatable in %s (%s-%s)", requestedIP, netDef.CIDR, IntToIP(first), IntToIP(end-1))}
+		}
+	}
+
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &IPReassignment{NetworkID: netDef.ID}
+	err = tx.QueryRowContext(ctx,
+		`SELECT ip, COALESCE(network_id::text, '') FROM ip_leases WHERE instance_name = $1 ORDER BY allocated_at ASC NULLS LAST LIMIT 1 FOR UPDATE`,
+		instanceName).Scan(&result.OldIP, &result.OldNetworkID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if requestedIP != "" && IntToIP(wanted) == result.OldIP {
+		return nil, &NetworkFieldError{Field: "ip", Message: fmt.Sprintf("%s is already the address of %s", requestedIP, instanceName)}
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE ip_leases
+		SET instance_name = NULL, allocated_at = NULL, released_name = instance_name, released_at = $2
+		WHERE instance_name = $1`, instanceName, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release current lease: %w", err)
+	}
+
+	graceCutoff := now.Add(-IPReuseGrace())
+	claim := func(ip string) (bool, error) {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO ip_leases (ip, instance_name, allocated_at, network_id) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (ip) DO UPDATE
+			SET instance_name = EXCLUDED.instance_name, allocated_at = EXCLUDED.allocated_at,
+			    network_id = EXCLUDED.network_id, released_name = NULL, released_at = NULL
+			WHERE ip_leases.instance_name IS NULL
+			  AND (ip_leases.released_at IS NULL OR ip_leases.released_at <= $5 OR ip_leases.released_name = $2)`,
+			ip, instanceName, now, netDef.ID, graceCutoff)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	if requestedIP != "" {
+		ok, err := claim(IntToIP(wanted))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &NetworkFieldError{Field: "ip", Message: fmt.Sprintf("%s is in use", requestedIP)}
+		}
+		result.IP = IntToIP(wanted)
+	} else {
+		used, err := queryStrings(ctx, tx, `
+			SELECT ip FROM ip_leases
+			WHERE network_id = $1
+			  AND (instance_name IS NOT NULL OR (released_at > $2 AND released_name <> $3))`,
+			netDef.ID, graceCutoff, instanceName)
+		if err != nil {
+			return nil, err
+		}
+		usedMap := make(map[string]bool, len(used)+1)
+		for _, ip := range used {
+			usedMap[ip] = true
+		}
+		// The point is a new address: never hand the old one straight back
+		usedMap[result.OldIP] = true
+
+		for i := first; i < end && result.IP == ""; i++ {
+			ip := IntToIP(i)
+			if usedMap[ip] {
+				continue
+			}
+			ok, err := claim(ip)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result.IP = ip
+			}
+		}
+		if result.IP == "" {
+			return nil, ErrPoolFull
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[IPAM] Reassigned %s from %q to %s (%s)", instanceName, result.OldIP, result.IP, netDef.Name)
+	return result, nil
+}
+
+// RestoreIP undoes a ReassignIP: the instance's current lease is released and
+// ip, which it held before, is claimed back. Fails if someone else took ip.
+func (s *Service) RestoreIP(ctx context.Context, instanceName, ip string) error {
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE ip_leases
+		SET instance_name = NULL, allocated_at = NULL, released_name = NULL, released_at = NULL
+		WHERE instance_name = $1`, instanceName)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE ip_leases
+		SET instance_name = $1, allocated_at = $2, released_name = NULL, released_at = NULL
+		WHERE ip = $3 AND instance_name IS NULL`, instanceName, now, ip)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s is no longer available", ip)
+	}
+	return tx.Commit()
+}
+
+// IPReuseGrace is how long a released IP stays reserved to the name of the
+// instance that held it. Within the window no other instance is given the
+// address, so deleting and recreating an instance keeps its IP. Configured
+// with AXION_IP_REUSE_GRACE (a Go duration, "0" disables); default 15m.
+func IPReuseGrace() time.Duration {
+	grace := getEnvDuration("AXION_IP_REUSE_GRACE", 15*time.Minute)
+	if grace < 0 {
+		return 0
+	}
+	return grace
+}
+
+// ReclaimIP gives instanceName back the IP it held before being deleted, if
+// that IP is still free. Within the grace window the IP is always reclaimed;
+// anyAge (reuse_ip on create) also accepts older releases as long as nobody
+// took the address since. networkID restricts the search to one network.
+// Returns "" when there is nothing to reclaim.
+func (s *Service) ReclaimIP(ctx context.Context, instanceName, networkID string, anyAge bool) (string, error) {
+	cutoff := time.Now().UTC().Add(-IPReuseGrace())
+	if anyAge {
+		cutoff = time.Time{}
+	}
+
+	query := `
+		UPDATE ip_leases
+		SET instance_name = $1, allocated_at = $2, released_name = NULL, released_at = NULL
+		WHERE ip = (
+			SELECT ip FROM ip_leases
+			WHERE released_name = $1 AND instance_name IS NULL AND released_at > $3
+			  AND ($4 = '' OR network_id::text = $4)
+			ORDER BY released_at DESC
+			LIMIT 1
+			FOR UPDATE
+		) AND instance_name IS NULL
+		RETURNING ip
+	`
+
+	var ip string
+	err := s.QueryRowContext(ctx, query, instanceName, time.Now().UTC(), cutoff, networkID).Scan(&ip)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to reclaim IP for instance %s: %w", instanceName, err)
+	}
+
+	log.Printf("[IPAM] Reclaimed %s for %s", ip, instanceName)
+	return ip, nil
+}
+
 // GetInstanceIP retrieves the IP assigned to an instance.
 func (s *Service) GetInstanceIP(ctx context.Context, instanceName string) (string, error) {
 	query := `SELECT ip FROM ip_leases WHERE instance_name = $1`
@@ -267,6 +669,45 @@ func (s *Service) GetInstanceIP(ctx context.Context, instanceName string) (strin
 	return ip, nil
 }
 
+// InstanceLease is the lease of an instance joined with its network
+type InstanceLease struct {
+	IP          string     `json:"ip"`
+	NetworkID   string     `json:"network_id"`
+	NetworkName string     `json:"network_name"`
+	CIDR        string     `json:"cidr"`
+	Gateway     string     `json:"gateway"`
+	DNS         string     `json:"dns"`
+	AllocatedAt *time.Time `json:"allocated_at"`
+}
+
+// GetInstanceLease returns the lease held by an instance with its network details,
+// or sql.ErrNoRows when the instance has no lease.
+func (s *Service) GetInstanceLease(ctx context.Context, instanceName string) (*InstanceLease, error) {
+	query := `
+		SELECT l.ip, COALESCE(n.id::text, ''), COALESCE(n.name, ''), COALESCE(n.cidr, ''),
+		       COALESCE(n.gateway, ''), COALESCE(n.dns1, ''), l.allocated_at
+		FROM ip_leases l
+		LEFT JOIN networks n ON n.id = l.network_id
+		WHERE l.instance_name = $1
+		ORDER BY l.allocated_at ASC NULLS LAST
+		LIMIT 1
+	`
+
+	var lease InstanceLease
+	var allocatedAt sql.NullTime
+	err := s.QueryRowContext(ctx, query, instanceName).Scan(
+		&lease.IP, &lease.NetworkID, &lease.NetworkName, &lease.CIDR,
+		&lease.Gateway, &lease.DNS, &allocatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if allocatedAt.Valid {
+		lease.AllocatedAt = &allocatedAt.Time
+	}
+	return &lease, nil
+}
+
 // --- Extended Types for Admin UI ---
 
 type IpLease struct {
@@ -299,14 +740,7 @@ func (s *Service) GetNetworkDetails(ctx context.Context, id string) (*NetworkDet
 
 	// 2. Calculate Stats (Total/Used)
 	// (Reusing logic from GetNetworksWithStats basically, but for single ID)
-	_, ipNet, _ := net.ParseCIDR(n.CIDR)
-	if ipNet != nil {
-		ones, _ := ipNet.Mask.Size()
-		details.Stats.TotalIPs = 1 << (32 - ones)
-		if details.Stats.TotalIPs > 2 {
-			details.Stats.TotalIPs -= 3
-		}
-	}
+	details.Stats.TotalIPs, _ = PoolCapacity(n.CIDR)
 	details.Stats.Network = n // Copy base info
 
 	// 3. Fetch Leases
@@ -387,8 +821,87 @@ func (s *Service) DeleteNetwork(ctx context.Context, id string) error {
 	return nil
 }
 
+// ExpandNetwork widens a network's CIDR in place (e.g. /24 to /23). Existing
+// leases and the gateway stay valid because the new range contains the old one.
+// Returns sql.ErrNoRows for an unknown network and *NetworkFieldError when the
+// new CIDR is rejected.
+func (s *Service) ExpandNetwork(ctx context.Context, id string, newCIDR string) (*Network, error) {
+	tx, err := s.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var n Network
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, name, cidr, gateway, dns1, vlan_id, is_public, created_at FROM networks WHERE id = $1 FOR UPDATE`, id).
+		Scan(&n.ID, &n.Name, &n.CIDR, &n.Gateway, &n.DNS1, &n.VlanID, &n.IsPublic, &n.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := queryStrings(ctx, tx, `SELECT ip FROM ip_leases WHERE network_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT name, cidr FROM networks WHERE id <> $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	var others []Network
+	for rows.Next() {
+		var other Network
+		if err := rows.Scan(&other.Name, &other.CIDR); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		others = append(others, other)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateNetworkExpansion(n.CIDR, newCIDR, leases, others); err != nil {
+		return nil, err
+	}
+
+	_, newNet, _ := net.ParseCIDR(newCIDR)
+	if _, err := tx.ExecContext(ctx, `UPDATE networks SET cidr = $1 WHERE id = $2`, newNet.String(), id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[IPAM] Expanded network %s from %s to %s", n.Name, n.CIDR, newNet.String())
+	n.CIDR = newNet.String()
+	return &n, nil
+}
+
+func queryStrings(ctx context.Context, tx *Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
 // --- Helpers ---
 
+// CidrToRange returns the network and broadcast addresses of an IPv4 CIDR.
+// Host bits in the input are ignored: 10.0.0.5/24 gives 10.0.0.0 and 10.0.0.255.
 func CidrToRange(cidr string) (uint32, uint32, error) {
 	ip, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
@@ -396,8 +909,7 @@ func CidrToRange(cidr string) (uint32, uint32, error) {
 	}
 
 	// Forçar conversão para 4 bytes (IPv4)
-	ip4 := ip.To4()
-	if ip4 == nil {
+	if ip.To4() == nil {
 		return 0, 0, fmt.Errorf("IPv6 not supported in this pool")
 	}
 
@@ -406,17 +918,52 @@ func CidrToRange(cidr string) (uint32, uint32, error) {
 
 	// Recreate clean 32-bit mask
 	mask := binary.BigEndian.Uint32(net.CIDRMask(ones, 32))
-	start := binary.BigEndian.Uint32(ip4)
+	start := binary.BigEndian.Uint32(ip.To4()) & mask
 
 	// Calculate end by inverting mask
-	end := (start & mask) | (mask ^ 0xffffffff)
-
-	// DEBUG:
-	// log.Printf("[IPAM-DEBUG] CIDR=%s Start=%d End=%d Total=%d", cidr, start, end, end-start)
+	end := start | ^mask
 
 	return start, end, nil
 }
 
+// DefaultGateway is the first usable host of an IPv4 CIDR, the slot
+// AllocatableRange reserves for the gateway: 10.0.0.0/24 gives 10.0.0.1. A /31
+// or /32 has no network address, so its first address is used.
+func DefaultGateway(cidr string) (string, error) {
+	start, end, err := CidrToRange(cidr)
+	if err != nil {
+		return "", &NetworkFieldError{Field: "cidr", Message: "invalid CIDR notation"}
+	}
+	if end-start < 2 {
+		return IntToIP(start), nil
+	}
+	return IntToIP(start + 1), nil
+}
+
+// AllocatableRange returns the addresses the allocator hands out as [first, end):
+// the network address and the one after it (the gateway slot) are skipped and
+// end is the broadcast address. A /31 or /32 has nothing to allocate (first == end).
+func AllocatableRange(cidr string) (uint32, uint32, error) {
+	start, end, err := CidrToRange(cidr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end-start < 2 {
+		return end, end, nil
+	}
+	return start + 2, end, nil
+}
+
+// PoolCapacity is the number of addresses AllocatableRange yields; network
+// stats use it so the displayed total is what can actually be allocated.
+func PoolCapacity(cidr string) (int, error) {
+	first, end, err := AllocatableRange(cidr)
+	if err != nil {
+		return 0, err
+	}
+	return int(end - first), nil
+}
+
 func IntToIP(nn uint32) string {
 	ip := make(net.IP, 4)
 	binary.BigEndian.PutUint32(ip, nn)
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// IPAM AUDIT (read-only)
+// ============================================================================
+
+// LeaseRecord is an allocated row of ip_leases
+type LeaseRecord struct {
+	IP        string `json:"ip"`
+	Instance  string `json:"instance"`
+	NetworkID string `json:"network_id,omitempty"`
+}
+
+// IPMismatch is an instance whose recorded address differs from its lease
+type IPMismatch struct {
+	Instance string `json:"instance"`
+	LeaseIP  string `json:"lease_ip"`
+	ActualIP string `json:"actual_ip"`
+	Source   string `json:"source"` // "db" (instance limits) or "lxd" (live state)
+}
+
+// DuplicateIP is an address claimed by more than one instance in the same source
+type DuplicateIP struct {
+	IP        string   `json:"ip"`
+	Instances []string `json:"instances"`
+	Source    string   `json:"source"` // "lease", "db" or "lxd"
+}
+
+// IPAMAudit is the consistency report between ip_leases, instances and LXD
+type IPAMAudit struct {
+	GeneratedAt           time.Time     `json:"generated_at"`
+	LXDChecked            bool          `json:"lxd_checked"`
+	OrphanLeases          []LeaseRecord `json:"orphan_leases"`
+	InstancesWithoutLease []string      `json:"instances_without_lease"`
+	Mismatches            []IPMismatch  `json:"mismatches"`
+	DuplicateIPs          []DuplicateIP `json:"duplicate_ips"`
+	Healthy               bool          `json:"healthy"`
+}
+
+// IPAMAuditInput holds the three views being cross-referenced. StoredIPs maps every
+// known instance to its last observed IPv4 in instance_volatile ("" when none). LiveIPs maps
+// LXD instances to their live IPv4 and is nil when LXD was not queried.
+type IPAMAuditInput struct {
+	Leases    []LeaseRecord
+	StoredIPs map[string]string
+	LiveIPs   map[string]string
+}
+
+// ListAllocatedLeases returns every lease currently owned by an instance
+func (s *Service) ListAllocatedLeases(ctx context.Context) ([]LeaseRecord, error) {
+	query := `
+		SELECT ip, instance_name, COALESCE(network_id::text, '')
+		FROM ip_leases
+		WHERE instance_name IS NOT NULL
+		ORDER BY instance_name, ip
+	`
+
+	rows, err := s.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := []LeaseRecord{}
+	for rows.Next() {
+		var lease LeaseRecord
+		if err := rows.Scan(&lease.IP, &lease.Instance, &lease.NetworkID); err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
+
+// AuditIPAM cross-references the inputs without touching anything
+func AuditIPAM(in IPAMAuditInput) IPAMAudit {
+	report := IPAMAudit{
+		GeneratedAt:           time.Now().UTC(),
+		LXDChecked:            in.LiveIPs != nil,
+		OrphanLeases:          []LeaseRecord{},
+		InstancesWithoutLease: []string{},
+		Mismatches:            []IPMismatch{},
+		DuplicateIPs:          []DuplicateIP{},
+	}
+
+	leaseByInstance := make(map[string][]string)
+	leaseOwners := make(map[string][]string)
+	for _, lease := range in.Leases {
+		leaseByInstance[lease.Instance] = append(leaseByInstance[lease.Instance], lease.IP)
+		leaseOwners[lease.IP] = append(leaseOwners[lease.IP], lease.Instance)
+
+		_, inDB := in.StoredIPs[lease.Instance]
+		_, inLXD := in.LiveIPs[lease.Instance]
+		if !inDB && !inLXD {
+			report.OrphanLeases = append(report.OrphanLeases, lease)
+		}
+	}
+
+	for _, name := range sortedKeys(in.StoredIPs) {
+		leases := leaseByInstance[name]
+		if len(leases) == 0 {
+			report.InstancesWithoutLease = append(report.InstancesWithoutLease, name)
+			continue
+		}
+		if stored := in.StoredIPs[name]; stored != "" && !contains(leases, stored) {
+			report.Mismatches = append(report.Mismatches, IPMismatch{Instance: name, LeaseIP: leases[0], ActualIP: stored, Source: "db"})
+		}
+	}
+
+	for _, name := range sortedKeys(in.LiveIPs) {
+		leases := leaseByInstance[name]
+		if live := in.LiveIPs[name]; live != "" && len(leases) > 0 && !contains(leases, live) {
+			report.Mismatches = append(report.Mismatches, IPMismatch{Instance: name, LeaseIP: leases[0], ActualIP: live, Source: "lxd"})
+		}
+	}
+
+	report.DuplicateIPs = append(report.DuplicateIPs, findDuplicates(leaseOwners, "lease")...)
+	report.DuplicateIPs = append(report.DuplicateIPs, findDuplicates(invert(in.StoredIPs), "db")...)
+	report.DuplicateIPs = append(report.DuplicateIPs, findDuplicates(invert(in.LiveIPs), "lxd")...)
+
+	report.Healthy = len(report.OrphanLeases) == 0 && len(report.InstancesWithoutLease) == 0 &&
+		len(report.Mismatches) == 0 && len(report.DuplicateIPs) == 0
+	return report
+}
+
+func findDuplicates(owners map[string][]string, source string) []DuplicateIP {
+	var dups []DuplicateIP
+	for _, ip := range sortedKeys(owners) {
+		if names := owners[ip]; len(names) > 1 {
+			sort.Strings(names)
+			dups = append(dups, DuplicateIP{IP: ip, Instances: names, Source: source})
+		}
+	}
+	return dups
+}
+
+func invert(ips map[string]string) map[string][]string {
+	owners := make(map[string][]string)
+	for name, ip := range ips {
+		if ip != "" {
+			owners[ip] = append(owners[ip], name)
+		}
+	}
+	return owners
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateNetwork(t *testing.T) {
+	cases := []struct {
+		name    string
+		network Network
+		field   string
+	}{
+		{name: "valid", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", DNS1: "1.1.1.1"}},
+		{name: "valid without dns", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.0.254"}},
+		{name: "bad cidr", network: Network{CIDR: "10.0.0.0/33", Gateway: "10.0.0.1"}, field: "cidr"},
+		{name: "ipv6", network: Network{CIDR: "fd00::/64", Gateway: "fd00::1"}, field: "cidr"},
+		{name: "gateway outside", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.1.1"}, field: "gateway"},
+		{name: "gateway network address", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.0.0"}, field: "gateway"},
+		{name: "gateway broadcast", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.0.255"}, field: "gateway"},
+		{name: "gateway garbage", network: Network{CIDR: "10.0.0.0/24", Gateway: "gw"}, field: "gateway"},
+		{name: "dns invalid", network: Network{CIDR: "10.0.0.0/24", Gateway: "10.0.0.1", DNS1: "dns.example"}, field: "dns1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetwork(tc.network)
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var fieldErr *NetworkFieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("expected NetworkFieldError, got %v", err)
+			}
+			if fieldErr.Field != tc.field {
+				t.Errorf("field = %q, want %q", fieldErr.Field, tc.field)
+			}
+		})
+	}
+}
+
+func TestAuditIPAM(t *testing.T) {
+	report := AuditIPAM(IPAMAuditInput{
+		Leases: []LeaseRecord{
+			{IP: "10.0.0.2", Instance: "web"},
+			{IP: "10.0.0.3", Instance: "db"},
+			{IP: "10.0.0.4", Instance: "ghost"},
+		},
+		StoredIPs: map[string]string{
+			"web":     "10.0.0.2",
+			"db":      "10.0.0.9",
+			"cache":   "10.0.0.2",
+			"no-ipv4": "",
+		},
+		LiveIPs: map[string]string{"web": "10.0.0.5"},
+	})
+
+	if len(report.OrphanLeases) != 1 || report.OrphanLeases[0].Instance != "ghost" {
+		t.Errorf("orphan leases = %+v, want ghost", report.OrphanLeases)
+	}
+	if len(report.InstancesWithoutLease) != 2 || report.InstancesWithoutLease[0] != "cache" {
+		t.Errorf("instances without lease = %v, want [cache no-ipv4]", report.InstancesWithoutLease)
+	}
+	if len(report.Mismatches) != 2 {
+		t.Fatalf("mismatches = %+v, want db (db) and web (lxd)", report.Mismatches)
+	}
+	if m := report.Mismatches[0]; m.Instance != "db" || m.Source != "db" || m.ActualIP != "10.0.0.9" {
+		t.Errorf("unexpected db mismatch %+v", m)
+	}
+	if m := report.Mismatches[1]; m.Instance != "web" || m.Source != "lxd" {
+		t.Errorf("unexpected lxd mismatch %+v", m)
+	}
+	if len(report.DuplicateIPs) != 1 || report.DuplicateIPs[0].IP != "10.0.0.2" {
+		t.Errorf("duplicates = %+v, want 10.0.0.2 shared by cache and web", report.DuplicateIPs)
+	}
+	if report.Healthy {
+		t.Error("report should not be healthy")
+	}
+}
+
+func TestValidateNetworkExpansion(t *testing.T) {
+	others := []Network{{Name: "dmz", CIDR: "10.0.4.0/24"}}
+	leases := []string{"10.0.0.2", "10.0.0.254"}
+
+	cases := []struct {
+		name    string
+		newCIDR string
+		wantErr bool
+	}{
+		{name: "grow /24 to /23", newCIDR: "10.0.0.0/23"},
+		{name: "grow /24 to /22", newCIDR: "10.0.0.0/22"},
+		{name: "same prefix", newCIDR: "10.0.0.0/24", wantErr: true},
+		{name: "shrink", newCIDR: "10.0.0.0/25", wantErr: true},
+		{name: "different base", newCIDR: "10.0.2.0/23", wantErr: true},
+		{name: "host bits set", newCIDR: "10.0.0.1/23", wantErr: true},
+		{name: "overlaps other network", newCIDR: "10.0.0.0/21", wantErr: true},
+		{name: "ipv6", newCIDR: "fd00::/64", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNetworkExpansion("10.0.0.0/24", tc.newCIDR, leases, others)
+			if tc.wantErr && err == nil {
+				t.Fatalf("expected %s to be rejected", tc.newCIDR)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	if err := ValidateNetworkExpansion("10.0.0.0/24", "10.0.0.0/23", []string{"10.0.9.9"}, nil); err == nil {
+		t.Error("a lease outside the new range must be rejected")
+	}
+}
+
+func TestCidrToRange(t *testing.T) {
+	cases := []struct {
+		cidr       string
+		start, end string
+	}{
+		{cidr: "10.0.0.0/24", start: "10.0.0.0", end: "10.0.0.255"},
+		{cidr: "10.0.0.77/24", start: "10.0.0.0", end: "10.0.0.255"},
+		{cidr: "192.168.4.0/22", start: "192.168.4.0", end: "192.168.7.255"},
+		{cidr: "10.0.0.8/30", start: "10.0.0.8", end: "10.0.0.11"},
+		{cidr: "10.0.0.8/31", start: "10.0.0.8", end: "10.0.0.9"},
+		{cidr: "10.0.0.8/32", start: "10.0.0.8", end: "10.0.0.8"},
+		{cidr: "0.0.0.0/0", start: "0.0.0.0", end: "255.255.255.255"},
+	}
+
+	for _, tc := range cases {
+		start, end, err := CidrToRange(tc.cidr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.cidr, err)
+		}
+		if IntToIP(start) != tc.start || IntToIP(end) != tc.end {
+			t.Errorf("%s: got %s-%s, want %s-%s", tc.cidr, IntToIP(start), IntToIP(end), tc.start, tc.end)
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0", "10.0.0.0/33", "fd00::/64"} {
+		if _, _, err := CidrToRange(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestDefaultGateway(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.0/24":    "10.0.0.1",
+		"10.0.0.77/24":   "10.0.0.1",
+		"192.168.4.0/22": "192.168.4.1",
+		"10.0.0.8/31":    "10.0.0.8",
+		"10.0.0.8/32":    "10.0.0.8",
+	}
+	for cidr, want := range cases {
+		got, err := DefaultGateway(cidr)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %s", cidr, got, err, want)
+			continue
+		}
+		if err := ValidateNetwork(Network{CIDR: cidr, Gateway: got}); err != nil {
+			t.Errorf("%s: derived gateway %s fails validation: %v", cidr, got, err)
+		}
+	}
+
+	if _, err := DefaultGateway("fd00::/64"); err == nil {
+		t.Error("IPv6 CIDR: expected an error")
+	}
+}
+
+func TestAllocatableRangeMatchesCapacity(t *testing.T) {
+	cases := []struct {
+		cidr     string
+		first    string
+		last     string
+		capacity int
+	}{
+		{cidr: "10.0.0.0/24", first: "10.0.0.2", last: "10.0.0.254", capacity: 253},
+		{cidr: "10.0.0.0/23", first: "10.0.0.2", last: "10.0.1.254", capacity: 509},
+		{cidr: "10.0.0.77/24", first: "10.0.0.2", last: "10.0.0.254", capacity: 253},
+		{cidr: "10.0.0.0/29", first: "10.0.0.2", last: "10.0.0.6", capacity: 5},
+		{cidr: "10.0.0.0/30", first: "10.0.0.2", last: "10.0.0.2", capacity: 1},
+		{cidr: "10.0.0.0/31", capacity: 0},
+		{cidr: "10.0.0.0/32", capacity: 0},
+		{cidr: "255.255.255.255/32", capacity: 0},
+	}
+
+	for _, tc := range cases {
+		first, end, err := AllocatableRange(tc.cidr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.cidr, err)
+		}
+
+		// Walk the range exactly like tryAllocateInNetwork does
+		var walked []string
+		for i := first; i < end; i++ {
+			walked = append(walked, IntToIP(i))
+		}
+
+		capacity, err := PoolCapacity(tc.cidr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.cidr, err)
+		}
+		if capacity != tc.capacity || len(walked) != capacity {
+			t.Errorf("%s: capacity = %d, walked %d addresses, want %d", tc.cidr, capacity, len(walked), tc.capacity)
+			continue
+		}
+		if capacity > 0 && (walked[0] != tc.first || walked[len(walked)-1] != tc.last) {
+			t.Errorf("%s: allocatable %s-%s, want %s-%s", tc.cidr, walked[0], walked[len(walked)-1], tc.first, tc.last)
+		}
+	}
+}
+
+func TestIntToIP(t *testing.T) {
+	cases := map[uint32]string{
+		0:          "0.0.0.0",
+		0x0A000001: "10.0.0.1",
+		0xC0A80AFF: "192.168.10.255",
+		0xFFFFFFFF: "255.255.255.255",
+	}
+	for n, want := range cases {
+		if got := IntToIP(n); got != want {
+			t.Errorf("IntToIP(%#x) = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -4,10 +4,13 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"aexon/internal/pagination"
 	"aexon/internal/types"
 )
 
@@ -27,6 +30,84 @@ type Job struct {
 	FinishedAt   *time.Time      `json:"finished_at,omitempty"`
 	AttemptCount int             `json:"attempt_count"`
 	RequestedBy  *string         `json:"requested_by,omitempty"`
+	// Progress (0-100) is only reported by job types that expose it
+	Progress        int    `json:"progress"`
+	ProgressMessage string `json:"progress_message,omitempty"`
+	// Result is a JSON document stored by job types that produce output (e.g. exec)
+	Result json.RawMessage `json:"result,omitempty"`
+	// LXDOperation is the ID of the LXD operation the job is waiting on, if any
+	LXDOperation string `json:"lxd_operation,omitempty"`
+	// ErrorCode classifies a failure the user can act on (e.g. "storage_pool_full")
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+const jobColumns = `id, type, target, payload, status, error,
+		       created_at, started_at, finished_at,
+		       attempt_count, requested_by,
+		       progress, COALESCE(progress_message, ''),
+		       COALESCE(result, ''), COALESCE(lxd_operation, ''),
+		       COALESCE(error_code, '')`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var job Job
+	var errStr sql.NullString
+	var reqByStr sql.NullString
+	var startedAt sql.NullTime
+	var finishedAt sql.NullTime
+	var result string
+
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Target,
+		&job.Payload,
+		&job.Status,
+		&errStr,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&job.AttemptCount,
+		&reqByStr,
+		&job.Progress,
+		&job.ProgressMessage,
+		&result,
+		&job.LXDOperation,
+		&job.ErrorCode,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable fields
+	if errStr.Valid {
+		s := errStr.String
+		job.Error = &s
+	}
+	if reqByStr.Valid {
+		s := reqByStr.String
+		job.RequestedBy = &s
+	}
+	// The driver hands back the session's offset as an unnamed zone; UTC
+	// keeps the API serializing "Z" whatever the connection reported
+	job.CreatedAt = job.CreatedAt.UTC()
+	if startedAt.Valid {
+		t := startedAt.Time.UTC()
+		job.StartedAt = &t
+	}
+	if finishedAt.Valid {
+		t := finishedAt.Time.UTC()
+		job.FinishedAt = &t
+	}
+	if result != "" {
+		job.Result = json.RawMessage(result)
+	}
+
+	return &job, nil
 }
 
 type JobRepository struct {
@@ -67,37 +148,67 @@ func (r *JobRepository) Create(ctx context.Context, job *Job) error {
 	return err
 }
 
-func (r *JobRepository) Get(ctx context.Context, id string) (*Job, error) {
+// CreateDeduplicated creates the job unless an active (pending or in-progress) job
+// with the same type and target already exists - and, when matchPayload is set,
+// the same payload. The check and the insert run under a transaction-scoped
+// advisory lock on type+target, so two concurrent requests cannot both pass it.
+// It returns the job that represents the request and whether it was newly created.
+func (r *JobRepository) CreateDeduplicated(ctx context.Context, job *Job, matchPayload bool) (*Job, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, string(job.Type)+"|"+job.Target); err != nil {
+		return nil, false, err
+	}
+
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
-		WHERE id = $1
+		WHERE type = $1 AND target = $2 AND status IN ($3, $4)
+		  AND ($5 = FALSE OR payload = $6)
+		ORDER BY created_at ASC
+		LIMIT 1
 	`
+	existing, err := scanJob(tx.QueryRowContext(ctx, query,
+		job.Type, job.Target, types.JobPending, types.JobInProgress, matchPayload, job.Payload))
+	if err == nil {
+		return existing, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	job.CreatedAt = time.Now().UTC()
+	job.Status = types.JobPending
+	job.AttemptCount = 0
 
-	var job Job
-	var errStr sql.NullString
-	var reqByStr sql.NullString
-	var startedAt sql.NullTime
-	var finishedAt sql.NullTime
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO jobs (
+			id, type, target, payload, status,
+			created_at, attempt_count, requested_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, job.ID, job.Type, job.Target, job.Payload, job.Status, job.CreatedAt, job.AttemptCount, job.RequestedBy)
+	if err != nil {
+		return nil, false, err
+	}
 
-	err := row.Scan(
-		&job.ID,
-		&job.Type,
-		&job.Target,
-		&job.Payload,
-		&job.Status,
-		&errStr,
-		&job.CreatedAt,
-		&startedAt,
-		&finishedAt,
-		&job.AttemptCount,
-		&reqByStr,
-	)
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return job, true, nil
+}
 
+func (r *JobRepository) Get(ctx context.Context, id string) (*Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id = $1
+	`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("job not found: %s", id)
@@ -105,36 +216,48 @@ func (r *JobRepository) Get(ctx context.Context, id string) (*Job, error) {
 		return nil, err
 	}
 
-	// Handle nullable fields
-	if errStr.Valid {
-		s := errStr.String
-		job.Error = &s
-	}
-	if reqByStr.Valid {
-		s := reqByStr.String
-		job.RequestedBy = &s
-	}
-	if startedAt.Valid {
-		job.StartedAt = &startedAt.Time
-	}
-	if finishedAt.Valid {
-		job.FinishedAt = &finishedAt.Time
-	}
+	return job, nil
+}
 
-	return &job, nil
+// JobListing is what GET /jobs accepts for sorting and paging
+var JobListing = pagination.Resource{
+	Columns: map[string]string{
+		"created_at":  "created_at",
+		"finished_at": "finished_at",
+		"type":        "type",
+		"target":      "target",
+		"status":      "status",
+	},
+	Tiebreak:     "id",
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+	DefaultLimit: 50,
+	MaxLimit:     500,
 }
 
 func (r *JobRepository) List(ctx context.Context, limit int) ([]Job, error) {
+	return r.list(ctx, "ORDER BY created_at DESC LIMIT $1", limit)
+}
+
+// ListPage returns one page of jobs; p must come from pagination.Parse with JobListing
+func (r *JobRepository) ListPage(ctx context.Context, p pagination.Params) ([]Job, error) {
+	return r.list(ctx, p.OrderBy()+" LIMIT $1 OFFSET $2", p.LimitArg(), p.Offset)
+}
+
+// Count returns the number of jobs kept in the history
+func (r *JobRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs`).Scan(&count)
+	return count, err
+}
+
+func (r *JobRepository) list(ctx context.Context, tail string, args ...interface{}) ([]Job, error) {
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
-		ORDER BY created_at DESC
-		LIMIT $1
-	`
+	` + tail
 
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -143,46 +266,11 @@ func (r *JobRepository) List(ctx context.Context, limit int) ([]Job, error) {
 	var jobs []Job
 
 	for rows.Next() {
-		var job Job
-		var errStr sql.NullString
-		var reqByStr sql.NullString
-		var startedAt sql.NullTime
-		var finishedAt sql.NullTime
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Type,
-			&job.Target,
-			&job.Payload,
-			&job.Status,
-			&errStr,
-			&job.CreatedAt,
-			&startedAt,
-			&finishedAt,
-			&job.AttemptCount,
-			&reqByStr,
-		)
-
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if errStr.Valid {
-			s := errStr.String
-			job.Error = &s
-		}
-		if reqByStr.Valid {
-			s := reqByStr.String
-			job.RequestedBy = &s
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			job.FinishedAt = &finishedAt.Time
-		}
-
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
@@ -192,19 +280,38 @@ func (r *JobRepository) List(ctx context.Context, limit int) ([]Job, error) {
 // STATUS UPDATES
 // ============================================================================
 
+// ErrJobNotPending is returned by MarkStarted when the job is no longer
+// PENDING, e.g. it was canceled while waiting in the queue.
+var ErrJobNotPending = errors.New("job is not pending")
+
+// ErrJobNotRunning is returned by MarkCompleted and MarkFailed when the job
+// is no longer IN_PROGRESS, i.e. it was canceled while running. The CANCELED
+// status is kept.
+var ErrJobNotRunning = errors.New("job is no longer in progress")
+
+// ErrJobFinished is returned by MarkCanceled when the job already reached a
+// terminal status.
+var ErrJobFinished = errors.New("job already finished")
+
+// MarkStarted moves a PENDING job to IN_PROGRESS.
 func (r *JobRepository) MarkStarted(ctx context.Context, id string) error {
 	query := `
 		UPDATE jobs
 		SET status = $1,
 		    started_at = $2,
-		    attempt_count = attempt_count + 1
-		WHERE id = $3
+		    attempt_count = attempt_count + 1,
+		    progress = 0,
+		    progress_message = NULL,
+		    lxd_operation = NULL,
+		    error_code = NULL
+		WHERE id = $3 AND status = $4
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
 		types.JobInProgress,
 		time.Now().UTC(),
 		id,
+		types.JobPending,
 	)
 
 	if err != nil {
@@ -217,25 +324,74 @@ func (r *JobRepository) MarkStarted(ctx context.Context, id string) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found: %s", id)
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrJobNotPending
 	}
 
 	return nil
 }
 
+// UpdateProgress records intermediate progress for a running job. percent is clamped to 0-100.
+func (r *JobRepository) UpdateProgress(ctx context.Context, id string, percent int, message string) error {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	query := `UPDATE jobs SET progress = $1, progress_message = $2 WHERE id = $3`
+
+	_, err := r.db.ExecContext(ctx, query, percent, message, id)
+	return err
+}
+
+// SetOperation records the LXD operation the job is waiting on, so it can be cancelled
+func (r *JobRepository) SetOperation(ctx context.Context, id string, operationID string) error {
+	query := `UPDATE jobs SET lxd_operation = $1 WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, query, operationID, id)
+	return err
+}
+
+// SetErrorCode classifies the job's failure (see lxc.CapacityError)
+func (r *JobRepository) SetErrorCode(ctx context.Context, id string, code string) error {
+	query := `UPDATE jobs SET error_code = $1 WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, query, code, id)
+	return err
+}
+
+// SetResult stores the job's output document
+func (r *JobRepository) SetResult(ctx context.Context, id string, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal job result: %w", err)
+	}
+
+	query := `UPDATE jobs SET result = $1 WHERE id = $2`
+
+	_, err = r.db.ExecContext(ctx, query, string(data), id)
+	return err
+}
+
+// MarkCompleted moves an IN_PROGRESS job to COMPLETED.
 func (r *JobRepository) MarkCompleted(ctx context.Context, id string) error {
 	query := `
 		UPDATE jobs
 		SET status = $1,
 		    finished_at = $2,
-		    error = NULL
-		WHERE id = $3
+		    error = NULL,
+		    progress = CASE WHEN progress > 0 THEN 100 ELSE progress END
+		WHERE id = $3 AND status = $4
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
 		types.JobCompleted,
 		time.Now().UTC(),
 		id,
+		types.JobInProgress,
 	)
 
 	if err != nil {
@@ -248,12 +404,14 @@ func (r *JobRepository) MarkCompleted(ctx context.Context, id string) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found: %s", id)
+		return r.notRunning(ctx, id)
 	}
 
 	return nil
 }
 
+// MarkFailed records the failure of an IN_PROGRESS job: FAILED when isFatal,
+// otherwise back to PENDING for the retry.
 func (r *JobRepository) MarkFailed(ctx context.Context, id string, errorMsg string, isFatal bool) error {
 	status := types.JobPending
 	if isFatal {
@@ -269,17 +427,17 @@ func (r *JobRepository) MarkFailed(ctx context.Context, id string, errorMsg stri
 			SET status = $1,
 			    error = $2,
 			    finished_at = $3
-			WHERE id = $4
+			WHERE id = $4 AND status = $5
 		`
-		args = []interface{}{status, errorMsg, time.Now().UTC(), id}
+		args = []interface{}{status, errorMsg, time.Now().UTC(), id, types.JobInProgress}
 	} else {
 		query = `
 			UPDATE jobs
 			SET status = $1,
 			    error = $2
-			WHERE id = $3
+			WHERE id = $3 AND status = $4
 		`
-		args = []interface{}{status, errorMsg, id}
+		args = []interface{}{status, errorMsg, id, types.JobInProgress}
 	}
 
 	result, err := r.db.ExecContext(ctx, query, args...)
@@ -293,19 +451,29 @@ func (r *JobRepository) MarkFailed(ctx context.Context, id string, errorMsg stri
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found: %s", id)
+		return r.notRunning(ctx, id)
 	}
 
 	return nil
 }
 
+// notRunning explains why a status update out of IN_PROGRESS matched no row
+func (r *JobRepository) notRunning(ctx context.Context, id string) error {
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrJobNotRunning
+}
+
+// MarkCanceled moves a PENDING or IN_PROGRESS job to CANCELED. Stopping a
+// running job is up to the worker; this only records the transition.
 func (r *JobRepository) MarkCanceled(ctx context.Context, id string, reason string) error {
 	query := `
 		UPDATE jobs
 		SET status = $1,
 		    error = $2,
 		    finished_at = $3
-		WHERE id = $4
+		WHERE id = $4 AND status IN ($5, $6)
 	`
 
 	result, err := r.db.ExecContext(ctx, query,
@@ -313,6 +481,8 @@ func (r *JobRepository) MarkCanceled(ctx context.Context, id string, reason stri
 		reason,
 		time.Now().UTC(),
 		id,
+		types.JobPending,
+		types.JobInProgress,
 	)
 
 	if err != nil {
@@ -325,7 +495,10 @@ func (r *JobRepository) MarkCanceled(ctx context.Context, id string, reason stri
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("job not found: %s", id)
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrJobFinished
 	}
 
 	return nil
@@ -376,9 +549,7 @@ func (r *JobRepository) RecoverStuckJobs(ctx context.Context, timeout time.Durat
 
 func (r *JobRepository) GetStuckJobs(ctx context.Context, timeout time.Duration) ([]Job, error) {
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE status = $1
 		  AND started_at < $2
@@ -396,46 +567,11 @@ func (r *JobRepository) GetStuckJobs(ctx context.Context, timeout time.Duration)
 	var jobs []Job
 
 	for rows.Next() {
-		var job Job
-		var errStr sql.NullString
-		var reqByStr sql.NullString
-		var startedAt sql.NullTime
-		var finishedAt sql.NullTime
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Type,
-			&job.Target,
-			&job.Payload,
-			&job.Status,
-			&errStr,
-			&job.CreatedAt,
-			&startedAt,
-			&finishedAt,
-			&job.AttemptCount,
-			&reqByStr,
-		)
-
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if errStr.Valid {
-			s := errStr.String
-			job.Error = &s
-		}
-		if reqByStr.Valid {
-			s := reqByStr.String
-			job.RequestedBy = &s
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			job.FinishedAt = &finishedAt.Time
-		}
-
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
@@ -447,9 +583,7 @@ func (r *JobRepository) GetStuckJobs(ctx context.Context, timeout time.Duration)
 
 func (r *JobRepository) GetByStatus(ctx context.Context, status types.JobStatus, limit int) ([]Job, error) {
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE status = $1
 		ORDER BY created_at DESC
@@ -465,46 +599,11 @@ func (r *JobRepository) GetByStatus(ctx context.Context, status types.JobStatus,
 	var jobs []Job
 
 	for rows.Next() {
-		var job Job
-		var errStr sql.NullString
-		var reqByStr sql.NullString
-		var startedAt sql.NullTime
-		var finishedAt sql.NullTime
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Type,
-			&job.Target,
-			&job.Payload,
-			&job.Status,
-			&errStr,
-			&job.CreatedAt,
-			&startedAt,
-			&finishedAt,
-			&job.AttemptCount,
-			&reqByStr,
-		)
-
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if errStr.Valid {
-			s := errStr.String
-			job.Error = &s
-		}
-		if reqByStr.Valid {
-			s := reqByStr.String
-			job.RequestedBy = &s
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			job.FinishedAt = &finishedAt.Time
-		}
-
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
@@ -512,9 +611,7 @@ func (r *JobRepository) GetByStatus(ctx context.Context, status types.JobStatus,
 
 func (r *JobRepository) GetByTarget(ctx context.Context, target string, limit int) ([]Job, error) {
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE target = $1
 		ORDER BY created_at DESC
@@ -530,46 +627,11 @@ func (r *JobRepository) GetByTarget(ctx context.Context, target string, limit in
 	var jobs []Job
 
 	for rows.Next() {
-		var job Job
-		var errStr sql.NullString
-		var reqByStr sql.NullString
-		var startedAt sql.NullTime
-		var finishedAt sql.NullTime
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Type,
-			&job.Target,
-			&job.Payload,
-			&job.Status,
-			&errStr,
-			&job.CreatedAt,
-			&startedAt,
-			&finishedAt,
-			&job.AttemptCount,
-			&reqByStr,
-		)
-
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if errStr.Valid {
-			s := errStr.String
-			job.Error = &s
-		}
-		if reqByStr.Valid {
-			s := reqByStr.String
-			job.RequestedBy = &s
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			job.FinishedAt = &finishedAt.Time
-		}
-
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 
 	return jobs, rows.Err()
@@ -578,53 +640,16 @@ func (r *JobRepository) GetByTarget(ctx context.Context, target string, limit in
 func (r *JobRepository) GetLastBackupJob(ctx context.Context, instanceName string) (*Job, error) {
 	// Try by target first
 	query := `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE type = $1 AND target = $2
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, types.JobTypeCreateSnapshot, instanceName)
-
-	var job Job
-	var errStr sql.NullString
-	var reqByStr sql.NullString
-	var startedAt sql.NullTime
-	var finishedAt sql.NullTime
-
-	err := row.Scan(
-		&job.ID,
-		&job.Type,
-		&job.Target,
-		&job.Payload,
-		&job.Status,
-		&errStr,
-		&job.CreatedAt,
-		&startedAt,
-		&finishedAt,
-		&job.AttemptCount,
-		&reqByStr,
-	)
-
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, types.JobTypeCreateSnapshot, instanceName))
 	if err == nil {
-		if errStr.Valid {
-			s := errStr.String
-			job.Error = &s
-		}
-		if reqByStr.Valid {
-			s := reqByStr.String
-			job.RequestedBy = &s
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if finishedAt.Valid {
-			job.FinishedAt = &finishedAt.Time
-		}
-		return &job, nil
+		return job, nil
 	}
 
 	if err != sql.ErrNoRows {
@@ -633,31 +658,14 @@ func (r *JobRepository) GetLastBackupJob(ctx context.Context, instanceName strin
 
 	// Fallback: search in payload (less efficient)
 	query = `
-		SELECT id, type, target, payload, status, error,
-		       created_at, started_at, finished_at,
-		       attempt_count, requested_by
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE type = $1 AND payload LIKE $2
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	row = r.db.QueryRowContext(ctx, query, types.JobTypeCreateSnapshot, "%"+instanceName+"%")
-
-	err = row.Scan(
-		&job.ID,
-		&job.Type,
-		&job.Target,
-		&job.Payload,
-		&job.Status,
-		&errStr,
-		&job.CreatedAt,
-		&startedAt,
-		&finishedAt,
-		&job.AttemptCount,
-		&reqByStr,
-	)
-
+	job, err = scanJob(r.db.QueryRowContext(ctx, query, types.JobTypeCreateSnapshot, "%"+instanceName+"%"))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No backup found
@@ -665,22 +673,7 @@ func (r *JobRepository) GetLastBackupJob(ctx context.Context, instanceName strin
 		return nil, err
 	}
 
-	if errStr.Valid {
-		s := errStr.String
-		job.Error = &s
-	}
-	if reqByStr.Valid {
-		s := reqByStr.String
-		job.RequestedBy = &s
-	}
-	if startedAt.Valid {
-		job.StartedAt = &startedAt.Time
-	}
-	if finishedAt.Valid {
-		job.FinishedAt = &finishedAt.Time
-	}
-
-	return &job, nil
+	return job, nil
 }
 
 func (r *JobRepository) CountByStatus(ctx context.Context, status types.JobStatus) (int, error) {
@@ -691,6 +684,32 @@ func (r *JobRepository) CountByStatus(ctx context.Context, status types.JobStatu
 	return count, err
 }
 
+// JobCount is the number of jobs of one type in one status
+type JobCount struct {
+	Type   string
+	Status string
+	Count  int
+}
+
+// CountByTypeAndStatus counts the jobs still in the table per type and status
+func (r *JobRepository) CountByTypeAndStatus(ctx context.Context) ([]JobCount, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT type, status, COUNT(*) FROM jobs GROUP BY type, status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []JobCount
+	for rows.Next() {
+		var jc JobCount
+		if err := rows.Scan(&jc.Type, &jc.Status, &jc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, jc)
+	}
+	return counts, rows.Err()
+}
+
 func (r *JobRepository) GetStatistics(ctx context.Context) (map[string]int, error) {
 	query := `
 		SELECT status, COUNT(*) as count
@@ -724,17 +743,34 @@ func (r *JobRepository) GetStatistics(ctx context.Context) (map[string]int, erro
 // CLEANUP OPERATIONS
 // ============================================================================
 
-func (r *JobRepository) DeleteOldJobs(ctx context.Context, olderThan time.Duration) (int, error) {
+// JobRetention defines how long finished jobs are kept, per final status.
+// Failures are usually worth keeping longer for debugging.
+type JobRetention struct {
+	Completed time.Duration
+	Failed    time.Duration
+	Canceled  time.Duration
+}
+
+// DeleteOldJobs removes finished jobs past the retention window of their status
+func (r *JobRepository) DeleteOldJobs(ctx context.Context, retention JobRetention) (int, error) {
 	query := `
 		DELETE FROM jobs
 		WHERE finished_at IS NOT NULL
-		  AND finished_at < $1
+		  AND (
+		        (status = $1 AND finished_at < $2)
+		     OR (status = $3 AND finished_at < $4)
+		     OR (status NOT IN ($1, $3) AND finished_at < $5)
+		  )
 		RETURNING id
 	`
 
-	cutoff := time.Now().UTC().Add(-olderThan)
+	now := time.Now().UTC()
 
-	rows, err := r.db.QueryContext(ctx, query, cutoff)
+	rows, err := r.db.QueryContext(ctx, query,
+		types.JobCompleted, now.Add(-retention.Completed),
+		types.JobFailed, now.Add(-retention.Failed),
+		now.Add(-retention.Canceled),
+	)
 	if err != nil {
 		return 0, err
 	}
@@ -750,7 +786,8 @@ func (r *JobRepository) DeleteOldJobs(ctx context.Context, olderThan time.Durati
 	}
 
 	if count > 0 {
-		log.Printf("[Jobs] Deleted %d old jobs (older than %v)", count, olderThan)
+		log.Printf("[Jobs] Deleted %d old jobs (retention completed=%v failed=%v canceled=%v)",
+			count, retention.Completed, retention.Failed, retention.Canceled)
 	}
 
 	return count, rows.Err()
@@ -817,6 +854,36 @@ func MarkJobFailed(id string, errorMsg string, isFatal bool) error {
 	return repo.MarkFailed(ctx, id, errorMsg, isFatal)
 }
 
+func MarkJobCanceled(id string, reason string) error {
+	ctx := context.Background()
+	repo := NewJobRepository(GetService())
+	return repo.MarkCanceled(ctx, id, reason)
+}
+
+func UpdateJobProgress(id string, percent int, message string) error {
+	ctx := context.Background()
+	repo := NewJobRepository(GetService())
+	return repo.UpdateProgress(ctx, id, percent, message)
+}
+
+func SetJobOperation(id string, operationID string) error {
+	ctx := context.Background()
+	repo := NewJobRepository(GetService())
+	return repo.SetOperation(ctx, id, operationID)
+}
+
+func SetJobErrorCode(id string, code string) error {
+	ctx := context.Background()
+	repo := NewJobRepository(GetService())
+	return repo.SetErrorCode(ctx, id, code)
+}
+
+func SetJobResult(id string, result interface{}) error {
+	ctx := context.Background()
+	repo := NewJobRepository(GetService())
+	return repo.SetResult(ctx, id, result)
+}
+
 func RecoverStuckJobs() error {
 	ctx := context.Background()
 	repo := NewJobRepository(GetService())
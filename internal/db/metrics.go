@@ -4,7 +4,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -19,6 +21,57 @@ type Metric struct {
 	CPUPercent   float64   `json:"cpu_percent"`
 	MemoryUsage  int64     `json:"memory_usage"`
 	DiskUsage    int64     `json:"disk_usage"`
+	// Cumulative interface counters as reported by LXD
+	NetworkRxBytes int64 `json:"network_rx_bytes"`
+	NetworkTxBytes int64 `json:"network_tx_bytes"`
+	// Rates derived from the previous sample (see ComputeNetworkRates); not stored
+	NetworkRxRate float64 `json:"network_rx_bytes_per_sec"`
+	NetworkTxRate float64 `json:"network_tx_bytes_per_sec"`
+}
+
+const metricColumns = `id, instance_name, timestamp,
+		       cpu_percent, memory_usage, disk_usage,
+		       network_rx_bytes, network_tx_bytes`
+
+func scanMetric(row rowScanner) (Metric, error) {
+	var m Metric
+	err := row.Scan(
+		&m.ID,
+		&m.InstanceName,
+		&m.Timestamp,
+		&m.CPUPercent,
+		&m.MemoryUsage,
+		&m.DiskUsage,
+		&m.NetworkRxBytes,
+		&m.NetworkTxBytes,
+	)
+	return m, err
+}
+
+// ComputeNetworkRates fills the per-second rx/tx rates of a chronologically
+// ordered series from the deltas between consecutive samples. The first sample
+// has no predecessor and keeps a zero rate. When a counter goes backwards (the
+// instance restarted and its interfaces were recreated) the current value is
+// taken as the delta, since it is the traffic seen since the reset.
+func ComputeNetworkRates(metrics []Metric) {
+	for i := 1; i < len(metrics); i++ {
+		prev, cur := metrics[i-1], &metrics[i]
+
+		elapsed := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+
+		cur.NetworkRxRate = float64(counterDelta(prev.NetworkRxBytes, cur.NetworkRxBytes)) / elapsed
+		cur.NetworkTxRate = float64(counterDelta(prev.NetworkTxBytes, cur.NetworkTxBytes)) / elapsed
+	}
+}
+
+func counterDelta(prev, cur int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
 }
 
 type AggregatedMetric struct {
@@ -52,8 +105,9 @@ func (r *MetricsRepository) Insert(ctx context.Context, metric *Metric) error {
 	query := `
 		INSERT INTO metrics (
 			instance_name, timestamp,
-			cpu_percent, memory_usage, disk_usage
-		) VALUES ($1, $2, $3, $4, $5)
+			cpu_percent, memory_usage, disk_usage,
+			network_rx_bytes, network_tx_bytes
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
 	// Always use UTC for timestamps
@@ -68,15 +122,32 @@ func (r *MetricsRepository) Insert(ctx context.Context, metric *Metric) error {
 		metric.CPUPercent,
 		metric.MemoryUsage,
 		metric.DiskUsage,
+		metric.NetworkRxBytes,
+		metric.NetworkTxBytes,
 	)
 
 	return err
 }
 
+// metricsBatchSize caps the rows per INSERT statement: 7 bind parameters per
+// row keeps a full batch well under PostgreSQL's 65535-parameter limit
+const metricsBatchSize = 1000
+
+// InsertBatch stores a collection cycle with one multi-row INSERT per
+// metricsBatchSize samples, all in the same transaction. Samples without a
+// timestamp share the time of the call.
 func (r *MetricsRepository) InsertBatch(ctx context.Context, metrics []Metric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
+	now := time.Now().UTC()
+
+	// A single statement is already atomic
+	if len(metrics) <= metricsBatchSize {
+		query, args := buildMetricsInsert(metrics, now)
+		_, err := r.db.ExecContext(ctx, query, args...)
+		return err
+	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -84,33 +155,52 @@ func (r *MetricsRepository) InsertBatch(ctx context.Context, metrics []Metric) e
 	}
 	defer tx.Rollback()
 
-	query := `
-		INSERT INTO metrics (
-			instance_name, timestamp,
-			cpu_percent, memory_usage, disk_usage
-		) VALUES ($1, $2, $3, $4, $5)
-	`
+	for start := 0; start < len(metrics); start += metricsBatchSize {
+		end := start + metricsBatchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		query, args := buildMetricsInsert(metrics[start:end], now)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
 
-	for _, metric := range metrics {
-		timestamp := time.Now().UTC()
+	return tx.Commit()
+}
+
+// buildMetricsInsert renders a multi-row INSERT for metrics
+func buildMetricsInsert(metrics []Metric, now time.Time) (string, []interface{}) {
+	const columns = 7
+	values := make([]string, 0, len(metrics))
+	args := make([]interface{}, 0, len(metrics)*columns)
+
+	for i, metric := range metrics {
+		timestamp := now
 		if !metric.Timestamp.IsZero() {
 			timestamp = metric.Timestamp.UTC()
 		}
 
-		_, err := tx.ExecContext(ctx, query,
+		n := i * columns
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args,
 			metric.InstanceName,
 			timestamp,
 			metric.CPUPercent,
 			metric.MemoryUsage,
 			metric.DiskUsage,
+			metric.NetworkRxBytes,
+			metric.NetworkTxBytes,
 		)
-
-		if err != nil {
-			return err
-		}
 	}
 
-	return tx.Commit()
+	query := `
+		INSERT INTO metrics (
+			instance_name, timestamp,
+			cpu_percent, memory_usage, disk_usage,
+			network_rx_bytes, network_tx_bytes
+		) VALUES ` + strings.Join(values, ", ")
+	return query, args
 }
 
 // ============================================================================
@@ -119,8 +209,7 @@ func (r *MetricsRepository) InsertBatch(ctx context.Context, metrics []Metric) e
 
 func (r *MetricsRepository) GetByInstance(ctx context.Context, instanceName string, interval string) ([]Metric, error) {
 	query := `
-		SELECT id, instance_name, timestamp,
-		       cpu_percent, memory_usage, disk_usage
+		SELECT ` + metricColumns + `
 		FROM metrics
 		WHERE instance_name = $1
 		  AND timestamp > NOW() - $2::interval
@@ -136,17 +225,7 @@ func (r *MetricsRepository) GetByInstance(ctx context.Context, instanceName stri
 	var metrics []Metric
 
 	for rows.Next() {
-		var m Metric
-
-		err := rows.Scan(
-			&m.ID,
-			&m.InstanceName,
-			&m.Timestamp,
-			&m.CPUPercent,
-			&m.MemoryUsage,
-			&m.DiskUsage,
-		)
-
+		m, err := scanMetric(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -154,13 +233,17 @@ func (r *MetricsRepository) GetByInstance(ctx context.Context, instanceName stri
 		metrics = append(metrics, m)
 	}
 
-	return metrics, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ComputeNetworkRates(metrics)
+	return metrics, nil
 }
 
 func (r *MetricsRepository) GetByTimeRange(ctx context.Context, instanceName string, start, end time.Time) ([]Metric, error) {
 	query := `
-		SELECT id, instance_name, timestamp,
-		       cpu_percent, memory_usage, disk_usage
+		SELECT ` + metricColumns + `
 		FROM metrics
 		WHERE instance_name = $1
 		  AND timestamp BETWEEN $2 AND $3
@@ -176,17 +259,7 @@ func (r *MetricsRepository) GetByTimeRange(ctx context.Context, instanceName str
 	var metrics []Metric
 
 	for rows.Next() {
-		var m Metric
-
-		err := rows.Scan(
-			&m.ID,
-			&m.InstanceName,
-			&m.Timestamp,
-			&m.CPUPercent,
-			&m.MemoryUsage,
-			&m.DiskUsage,
-		)
-
+		m, err := scanMetric(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -194,13 +267,17 @@ func (r *MetricsRepository) GetByTimeRange(ctx context.Context, instanceName str
 		metrics = append(metrics, m)
 	}
 
-	return metrics, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ComputeNetworkRates(metrics)
+	return metrics, nil
 }
 
 func (r *MetricsRepository) GetLatest(ctx context.Context, instanceName string, limit int) ([]Metric, error) {
 	query := `
-		SELECT id, instance_name, timestamp,
-		       cpu_percent, memory_usage, disk_usage
+		SELECT ` + metricColumns + `
 		FROM metrics
 		WHERE instance_name = $1
 		ORDER BY timestamp DESC
@@ -216,23 +293,16 @@ func (r *MetricsRepository) GetLatest(ctx context.Context, instanceName string,
 	var metrics []Metric
 
 	for rows.Next() {
-		var m Metric
-
-		err := rows.Scan(
-			&m.ID,
-			&m.InstanceName,
-			&m.Timestamp,
-			&m.CPUPercent,
-			&m.MemoryUsage,
-			&m.DiskUsage,
-		)
-
+		m, err := scanMetric(rows)
 		if err != nil {
 			return nil, err
 		}
 
 		metrics = append(metrics, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	// Reverse to get chronological order
 	for i := 0; i < len(metrics)/2; i++ {
@@ -240,7 +310,8 @@ func (r *MetricsRepository) GetLatest(ctx context.Context, instanceName string,
 		metrics[i], metrics[j] = metrics[j], metrics[i]
 	}
 
-	return metrics, rows.Err()
+	ComputeNetworkRates(metrics)
+	return metrics, nil
 }
 
 // ============================================================================
@@ -342,8 +413,7 @@ func (r *MetricsRepository) getAggregatedFallback(ctx context.Context, instanceN
 
 func (r *MetricsRepository) GetPeakUsage(ctx context.Context, instanceName string, interval string) (*Metric, error) {
 	query := `
-		SELECT id, instance_name, timestamp,
-		       cpu_percent, memory_usage, disk_usage
+		SELECT ` + metricColumns + `
 		FROM metrics
 		WHERE instance_name = $1
 		  AND timestamp > NOW() - $2::interval
@@ -351,19 +421,7 @@ func (r *MetricsRepository) GetPeakUsage(ctx context.Context, instanceName strin
 		LIMIT 1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, instanceName, interval)
-
-	var m Metric
-
-	err := row.Scan(
-		&m.ID,
-		&m.InstanceName,
-		&m.Timestamp,
-		&m.CPUPercent,
-		&m.MemoryUsage,
-		&m.DiskUsage,
-	)
-
+	m, err := scanMetric(r.db.QueryRowContext(ctx, query, instanceName, interval))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -497,61 +555,275 @@ func (r *MetricsRepository) GetInstanceMetricCounts(ctx context.Context) (map[st
 }
 
 // ============================================================================
-// DOWNSAMPLING (for long-term storage optimization)
+// ROLLUPS (long-term storage)
 // ============================================================================
 
-func (r *MetricsRepository) DownsampleOldMetrics(ctx context.Context, olderThan time.Duration, sampleInterval time.Duration) (int, error) {
-	// This creates hourly averages for old data
+// Raw samples are kept for MetricsRawRetention, then averaged into
+// metrics_hourly; hourly rows are averaged into metrics_daily after
+// MetricsHourlyRetention and daily rows expire after MetricsDailyRetention.
+const (
+	MetricsRawRetention    = 7 * 24 * time.Hour
+	MetricsHourlyRetention = 90 * 24 * time.Hour
+	MetricsDailyRetention  = 2 * 365 * 24 * time.Hour
+)
+
+// Metric resolutions served by GetSeries
+const (
+	MetricsResolutionRaw    = "raw"
+	MetricsResolutionHourly = "hourly"
+	MetricsResolutionDaily  = "daily"
+)
+
+// MetricsResolutionFor picks the finest resolution still fully stored for a
+// range reaching span into the past.
+func MetricsResolutionFor(span time.Duration) string {
+	switch {
+	case span <= MetricsRawRetention:
+		return MetricsResolutionRaw
+	case span <= MetricsHourlyRetention:
+		return MetricsResolutionHourly
+	default:
+		return MetricsResolutionDaily
+	}
+}
+
+// rollupMerge combines a new rollup row with an existing one for the same
+// bucket (samples inserted late), weighting the averages by sample count.
+const rollupMerge = `
+		ON CONFLICT (instance_name, bucket) DO UPDATE SET
+			avg_cpu = (%[1]s.avg_cpu * %[1]s.sample_count + EXCLUDED.avg_cpu * EXCLUDED.sample_count) / (%[1]s.sample_count + EXCLUDED.sample_count),
+			max_cpu = GREATEST(%[1]s.max_cpu, EXCLUDED.max_cpu),
+			avg_memory = (%[1]s.avg_memory * %[1]s.sample_count + EXCLUDED.avg_memory * EXCLUDED.sample_count) / (%[1]s.sample_count + EXCLUDED.sample_count),
+			max_memory = GREATEST(%[1]s.max_memory, EXCLUDED.max_memory),
+			avg_disk = (%[1]s.avg_disk * %[1]s.sample_count + EXCLUDED.avg_disk * EXCLUDED.sample_count) / (%[1]s.sample_count + EXCLUDED.sample_count),
+			max_disk = GREATEST(%[1]s.max_disk, EXCLUDED.max_disk),
+			network_rx_bytes = GREATEST(%[1]s.network_rx_bytes, EXCLUDED.network_rx_bytes),
+			network_tx_bytes = GREATEST(%[1]s.network_tx_bytes, EXCLUDED.network_tx_bytes),
+			sample_count = %[1]s.sample_count + EXCLUDED.sample_count
+	`
+
+// RollupMetrics downsamples raw metrics older than MetricsRawRetention into
+// hourly rows and hourly rows older than MetricsHourlyRetention into daily
+// rows, deleting what was rolled up, then drops expired daily rows. Cutoffs
+// are aligned to bucket boundaries so only complete buckets are rolled up.
+// Network counters keep the highest value seen in the bucket.
+func (r *MetricsRepository) RollupMetrics(ctx context.Context, now time.Time) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
 	defer tx.Rollback()
 
-	cutoff := time.Now().UTC().Add(-olderThan)
-
-	// Insert downsampled data into a temporary aggregation table
-	query := `
-		CREATE TEMP TABLE IF NOT EXISTS metrics_downsampled AS
-		SELECT 
-			instance_name,
-			date_trunc('hour', timestamp) AS hour,
-			AVG(cpu_percent) AS avg_cpu,
-			AVG(memory_usage) AS avg_memory,
-			AVG(disk_usage) AS avg_disk
+	hourlyCutoff := now.UTC().Add(-MetricsRawRetention).Truncate(time.Hour)
+	hourly := `
+		INSERT INTO metrics_hourly (
+			instance_name, bucket, avg_cpu, max_cpu, avg_memory, max_memory,
+			avg_disk, max_disk, network_rx_bytes, network_tx_bytes, sample_count
+		)
+		SELECT instance_name, date_trunc('hour', timestamp),
+		       COALESCE(AVG(cpu_percent), 0), COALESCE(MAX(cpu_percent), 0),
+		       COALESCE(AVG(memory_usage), 0), COALESCE(MAX(memory_usage), 0),
+		       COALESCE(AVG(disk_usage), 0), COALESCE(MAX(disk_usage), 0),
+		       MAX(network_rx_bytes), MAX(network_tx_bytes), COUNT(*)
 		FROM metrics
 		WHERE timestamp < $1
-		GROUP BY instance_name, hour
-	`
+		GROUP BY 1, 2
+	` + fmt.Sprintf(rollupMerge, "metrics_hourly")
+	if _, err := tx.ExecContext(ctx, hourly, hourlyCutoff); err != nil {
+		return fmt.Errorf("roll up hourly metrics: %w", err)
+	}
+	raw, err := tx.ExecContext(ctx, `DELETE FROM metrics WHERE timestamp < $1`, hourlyCutoff)
+	if err != nil {
+		return fmt.Errorf("delete rolled up metrics: %w", err)
+	}
 
-	_, err = tx.ExecContext(ctx, query, cutoff)
+	dailyCutoff := now.UTC().Add(-MetricsHourlyRetention).Truncate(24 * time.Hour)
+	daily := `
+		INSERT INTO metrics_daily (
+			instance_name, bucket, avg_cpu, max_cpu, avg_memory, max_memory,
+			avg_disk, max_disk, network_rx_bytes, network_tx_bytes, sample_count
+		)
+		SELECT instance_name, date_trunc('day', bucket),
+		       SUM(avg_cpu * sample_count) / SUM(sample_count), MAX(max_cpu),
+		       SUM(avg_memory * sample_count) / SUM(sample_count), MAX(max_memory),
+		       SUM(avg_disk * sample_count) / SUM(sample_count), MAX(max_disk),
+		       MAX(network_rx_bytes), MAX(network_tx_bytes), SUM(sample_count)
+		FROM metrics_hourly
+		WHERE bucket < $1
+		GROUP BY 1, 2
+	` + fmt.Sprintf(rollupMerge, "metrics_daily")
+	if _, err := tx.ExecContext(ctx, daily, dailyCutoff); err != nil {
+		return fmt.Errorf("roll up daily metrics: %w", err)
+	}
+	hourlyRows, err := tx.ExecContext(ctx, `DELETE FROM metrics_hourly WHERE bucket < $1`, dailyCutoff)
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("delete rolled up hourly metrics: %w", err)
 	}
 
-	// Delete old raw metrics
-	deleteQuery := `DELETE FROM metrics WHERE timestamp < $1 RETURNING id`
-	rows, err := tx.QueryContext(ctx, deleteQuery, cutoff)
+	expired, err := tx.ExecContext(ctx, `DELETE FROM metrics_daily WHERE bucket < $1`, now.UTC().Add(-MetricsDailyRetention))
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("delete expired daily metrics: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	rawCount, _ := raw.RowsAffected()
+	hourlyCount, _ := hourlyRows.RowsAffected()
+	expiredCount, _ := expired.RowsAffected()
+	if rawCount+hourlyCount+expiredCount > 0 {
+		log.Printf("[Metrics] Rolled up %d raw samples and %d hourly rows, expired %d daily rows",
+			rawCount, hourlyCount, expiredCount)
+	}
+	return nil
+}
+
+// GetSeries returns the metrics of an instance since start at the given
+// resolution. Coarser resolutions average every tier that still holds data in
+// the range (e.g. hourly rollups plus recent raw samples), so the series has
+// no gap where the raw retention ends. Values are averages per bucket.
+func (r *MetricsRepository) GetSeries(ctx context.Context, instanceName string, start time.Time, resolution string) ([]Metric, error) {
+	if resolution == MetricsResolutionRaw {
+		return r.GetByTimeRange(ctx, instanceName, start, time.Now().UTC())
+	}
+
+	rollupSource := func(table string) string {
+		return `SELECT bucket AS ts, avg_cpu, avg_memory, avg_disk, network_rx_bytes, network_tx_bytes, sample_count
+			FROM ` + table + ` WHERE instance_name = $1 AND bucket >= $2`
+	}
+	rawSource := `SELECT timestamp AS ts, COALESCE(cpu_percent, 0), COALESCE(memory_usage, 0), COALESCE(disk_usage, 0),
+			network_rx_bytes, network_tx_bytes, 1
+			FROM metrics WHERE instance_name = $1 AND timestamp >= $2`
+
+	var unit string
+	var sources []string
+	switch resolution {
+	case MetricsResolutionHourly:
+		unit = "hour"
+		sources = []string{rollupSource("metrics_hourly"), rawSource}
+	case MetricsResolutionDaily:
+		unit = "day"
+		sources = []string{rollupSource("metrics_daily"), rollupSource("metrics_hourly"), rawSource}
+	default:
+		return nil, fmt.Errorf("unknown metrics resolution %q", resolution)
+	}
+
+	query := `
+		SELECT date_trunc('` + unit + `', ts) AS bucket,
+		       SUM(avg_cpu * sample_count) / SUM(sample_count),
+		       SUM(avg_memory * sample_count) / SUM(sample_count),
+		       SUM(avg_disk * sample_count) / SUM(sample_count),
+		       MAX(network_rx_bytes), MAX(network_tx_bytes)
+		FROM (` + strings.Join(sources, " UNION ALL ") + `) AS s(ts, avg_cpu, avg_memory, avg_disk, network_rx_bytes, network_tx_bytes, sample_count)
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, instanceName, start.UTC())
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
-	count := 0
+	var metrics []Metric
 	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return count, err
+		m := Metric{InstanceName: instanceName}
+		var memory, disk float64
+		if err := rows.Scan(&m.Timestamp, &m.CPUPercent, &memory, &disk, &m.NetworkRxBytes, &m.NetworkTxBytes); err != nil {
+			return nil, err
 		}
-		count++
+		m.MemoryUsage = int64(memory)
+		m.DiskUsage = int64(disk)
+		metrics = append(metrics, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return count, err
+	ComputeNetworkRates(metrics)
+	return metrics, nil
+}
+
+// MetricsQueryMaxPoints caps the buckets returned by Query
+const MetricsQueryMaxPoints = 1000
+
+// MetricsBucket is the average of the samples of one Query step
+type MetricsBucket struct {
+	Timestamp   time.Time
+	CPUPercent  float64
+	MemoryUsage int64
+	DiskUsage   int64
+}
+
+// MetricsQueryStep returns the step Query uses for [from, to): step rounded
+// up to whole seconds and widened until the window fits in
+// MetricsQueryMaxPoints buckets. step <= 0 picks the finest such step, never
+// below MetricsSampleInterval.
+func MetricsQueryStep(from, to time.Time, step time.Duration) time.Duration {
+	if step <= 0 {
+		step = MetricsSampleInterval
+	}
+	if min := (to.Sub(from) + MetricsQueryMaxPoints - 1) / MetricsQueryMaxPoints; step < min {
+		step = min
 	}
+	if rem := step % time.Second; rem != 0 {
+		step += time.Second - rem
+	}
+	return step
+}
+
+// Query averages the cpu, memory and disk samples of an instance in
+// [from, to) per step (see MetricsQueryStep). Buckets are aligned to
+// multiples of step since the Unix epoch and empty ones are omitted. Rolled
+// up tiers are read as well, weighted by sample count, so windows past the
+// raw retention still return data at the rollup resolution.
+func (r *MetricsRepository) Query(ctx context.Context, instanceName string, from, to time.Time, step time.Duration) ([]MetricsBucket, error) {
+	step = MetricsQueryStep(from, to, step)
+
+	rollupSource := func(table string) string {
+		return `SELECT bucket AS ts, avg_cpu, avg_memory, avg_disk, sample_count
+			FROM ` + table + ` WHERE instance_name = $1 AND bucket >= $2 AND bucket < $3`
+	}
+	sources := []string{
+		rollupSource("metrics_daily"),
+		rollupSource("metrics_hourly"),
+		`SELECT timestamp AS ts, COALESCE(cpu_percent, 0), COALESCE(memory_usage, 0), COALESCE(disk_usage, 0), 1
+			FROM metrics WHERE instance_name = $1 AND timestamp >= $2 AND timestamp < $3`,
+	}
+
+	query := `
+		SELECT to_timestamp((floor(extract(epoch FROM ts) / $4) * $4)::double precision) AS bucket,
+		       SUM(avg_cpu * sample_count) / SUM(sample_count),
+		       SUM(avg_memory * sample_count) / SUM(sample_count),
+		       SUM(avg_disk * sample_count) / SUM(sample_count)
+		FROM (` + strings.Join(sources, " UNION ALL ") + `) AS s(ts, avg_cpu, avg_memory, avg_disk, sample_count)
+		GROUP BY bucket
+		ORDER BY bucket ASC
+		LIMIT ` + fmt.Sprint(MetricsQueryMaxPoints)
+
+	rows, err := r.db.QueryContext(ctx, query, instanceName, from.UTC(), to.UTC(), int64(step/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	log.Printf("[Metrics] Downsampled %d old metrics (older than %v)", count, olderThan)
-	return count, nil
+	buckets := []MetricsBucket{}
+	for rows.Next() {
+		var b MetricsBucket
+		var memory, disk float64
+		if err := rows.Scan(&b.Timestamp, &b.CPUPercent, &memory, &disk); err != nil {
+			return nil, err
+		}
+		b.Timestamp = b.Timestamp.UTC()
+		b.MemoryUsage = int64(memory)
+		b.DiskUsage = int64(disk)
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
 }
 
 // ============================================================================
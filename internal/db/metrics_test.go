@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeNetworkRatesHandlesCounterReset(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := []Metric{
+		{Timestamp: base, NetworkRxBytes: 1000, NetworkTxBytes: 500},
+		{Timestamp: base.Add(10 * time.Second), NetworkRxBytes: 3000, NetworkTxBytes: 1500},
+		// Instance restarted: counters start over
+		{Timestamp: base.Add(20 * time.Second), NetworkRxBytes: 400, NetworkTxBytes: 100},
+	}
+
+	ComputeNetworkRates(series)
+
+	if series[0].NetworkRxRate != 0 {
+		t.Errorf("first sample should have no rate, got %v", series[0].NetworkRxRate)
+	}
+	if series[1].NetworkRxRate != 200 || series[1].NetworkTxRate != 100 {
+		t.Errorf("unexpected rates %v/%v, want 200/100", series[1].NetworkRxRate, series[1].NetworkTxRate)
+	}
+	if series[2].NetworkRxRate != 40 || series[2].NetworkTxRate != 10 {
+		t.Errorf("after reset expected 40/10, got %v/%v", series[2].NetworkRxRate, series[2].NetworkTxRate)
+	}
+}
+
+func TestMetricsResolutionFor(t *testing.T) {
+	cases := []struct {
+		span time.Duration
+		want string
+	}{
+		{time.Hour, MetricsResolutionRaw},
+		{MetricsRawRetention, MetricsResolutionRaw},
+		{30 * 24 * time.Hour, MetricsResolutionHourly},
+		{MetricsHourlyRetention, MetricsResolutionHourly},
+		{365 * 24 * time.Hour, MetricsResolutionDaily},
+	}
+
+	for _, tc := range cases {
+		if got := MetricsResolutionFor(tc.span); got != tc.want {
+			t.Errorf("MetricsResolutionFor(%v) = %q, want %q", tc.span, got, tc.want)
+		}
+	}
+}
+
+func TestBuildMetricsInsert(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	stamped := time.Date(2025, 1, 1, 9, 0, 0, 0, time.FixedZone("BRT", -3*3600))
+	query, args := buildMetricsInsert([]Metric{
+		{InstanceName: "web", CPUPercent: 1.5},
+		{InstanceName: "db", Timestamp: stamped, MemoryUsage: 42},
+	}, now)
+
+	if !strings.Contains(query, "($1, $2, $3, $4, $5, $6, $7), ($8, $9, $10, $11, $12, $13, $14)") {
+		t.Errorf("unexpected placeholders in %s", query)
+	}
+	if len(args) != 14 {
+		t.Fatalf("got %d args, want 14", len(args))
+	}
+	if args[0] != "web" || args[1] != now {
+		t.Errorf("first row should default to now, got %v %v", args[0], args[1])
+	}
+	if ts := args[8].(time.Time); ts.Location() != time.UTC || !ts.Equal(stamped) {
+		t.Errorf("second row timestamp = %v, want %v in UTC", ts, stamped)
+	}
+}
+
+// benchMetricsRepository connects to the DefaultConfig database; the
+// benchmarks write real rows, so they only run with AXION_BENCH_DB=true
+func benchMetricsRepository(b *testing.B) *MetricsRepository {
+	b.Helper()
+	if os.Getenv("AXION_BENCH_DB") != "true" {
+		b.Skip("set AXION_BENCH_DB=true to benchmark against a local database")
+	}
+	service, err := connect(DefaultConfig())
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(func() { service.Close() })
+	return NewMetricsRepository(service)
+}
+
+// benchCycle is one collection cycle for n instances
+func benchCycle(n int) []Metric {
+	samples := make([]Metric, n)
+	for i := range samples {
+		samples[i] = Metric{InstanceName: fmt.Sprintf("bench-metrics-%d", i), CPUPercent: float64(i), MemoryUsage: int64(i) << 20}
+	}
+	return samples
+}
+
+func cleanupBenchMetrics(b *testing.B, repo *MetricsRepository) {
+	b.Cleanup(func() {
+		repo.db.ExecContext(context.Background(), `DELETE FROM metrics WHERE instance_name LIKE 'bench-metrics-%'`)
+	})
+}
+
+func BenchmarkMetricsInsert(b *testing.B) {
+	repo := benchMetricsRepository(b)
+	cleanupBenchMetrics(b, repo)
+	ctx := context.Background()
+
+	for _, n := range []int{10, 100, 1000} {
+		samples := benchCycle(n)
+
+		b.Run(fmt.Sprintf("single/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := range samples {
+					if err := repo.Insert(ctx, &samples[j]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("batch/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := repo.InsertBatch(ctx, samples); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsQueryStep(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		span time.Duration
+		step time.Duration
+		want time.Duration
+	}{
+		{name: "default", span: time.Hour, want: MetricsSampleInterval},
+		{name: "explicit", span: time.Hour, step: 5 * time.Minute, want: 5 * time.Minute},
+		{name: "sub-second rounded up", span: time.Minute, step: 1500 * time.Millisecond, want: 2 * time.Second},
+		{name: "widened to the cap", span: 30 * 24 * time.Hour, step: time.Minute, want: 43*time.Minute + 12*time.Second},
+		{name: "default widened", span: 7 * 24 * time.Hour, want: 10*time.Minute + 5*time.Second},
+	}
+
+	for _, tc := range cases {
+		got := MetricsQueryStep(from, from.Add(tc.span), tc.step)
+		if got != tc.want {
+			t.Errorf("%s: step = %s, want %s", tc.name, got, tc.want)
+		}
+		if points := tc.span / got; points > MetricsQueryMaxPoints {
+			t.Errorf("%s: %d points exceed the cap", tc.name, points)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -242,6 +243,637 @@ var migrations = []Migration{
 			ADD CONSTRAINT fk_instance FOREIGN KEY (instance_name) REFERENCES instances(name) ON DELETE SET NULL;
 		`,
 	},
+	{
+		Version:     12,
+		Description: "Add password rotation tracking to users",
+		Up: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN IF EXISTS must_change_password;
+			ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+		`,
+	},
+	{
+		Version:     13,
+		Description: "Create instance groups",
+		Up: `
+			CREATE TABLE IF NOT EXISTS instance_groups (
+				id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+				name TEXT UNIQUE NOT NULL,
+				description TEXT,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS instance_group_members (
+				group_id UUID NOT NULL REFERENCES instance_groups(id) ON DELETE CASCADE,
+				instance_name TEXT NOT NULL REFERENCES instances(name) ON DELETE CASCADE,
+				PRIMARY KEY (group_id, instance_name)
+			);
+			CREATE INDEX IF NOT EXISTS idx_group_members_instance ON instance_group_members(instance_name);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS instance_group_members CASCADE;
+			DROP TABLE IF EXISTS instance_groups CASCADE;
+		`,
+	},
+	{
+		Version:     14,
+		Description: "Add progress tracking to jobs",
+		Up: `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS progress INTEGER NOT NULL DEFAULT 0 CHECK (progress BETWEEN 0 AND 100);
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS progress_message TEXT;
+		`,
+		Down: `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS progress_message;
+			ALTER TABLE jobs DROP COLUMN IF EXISTS progress;
+		`,
+	},
+	{
+		// Existing naive values were written either by CURRENT_TIMESTAMP or by
+		// Go-side inserts; both are interpreted as UTC during the conversion.
+		Version:     15,
+		Description: "Convert timestamp columns to TIMESTAMPTZ (UTC)",
+		Up: `
+			ALTER TABLE jobs
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN started_at TYPE TIMESTAMPTZ USING started_at AT TIME ZONE 'UTC',
+				ALTER COLUMN finished_at TYPE TIMESTAMPTZ USING finished_at AT TIME ZONE 'UTC';
+			ALTER TABLE instances
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE metrics
+				ALTER COLUMN timestamp TYPE TIMESTAMPTZ USING timestamp AT TIME ZONE 'UTC';
+			ALTER TABLE schema_migrations
+				ALTER COLUMN applied_at TYPE TIMESTAMPTZ USING applied_at AT TIME ZONE 'UTC';
+			ALTER TABLE branding_settings
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE ip_leases
+				ALTER COLUMN allocated_at TYPE TIMESTAMPTZ USING allocated_at AT TIME ZONE 'UTC';
+			ALTER TABLE users
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+				ALTER COLUMN password_changed_at TYPE TIMESTAMPTZ USING password_changed_at AT TIME ZONE 'UTC';
+			ALTER TABLE networks
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_groups
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+		`,
+		Down: `
+			ALTER TABLE jobs
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN started_at TYPE TIMESTAMP USING started_at AT TIME ZONE 'UTC',
+				ALTER COLUMN finished_at TYPE TIMESTAMP USING finished_at AT TIME ZONE 'UTC';
+			ALTER TABLE instances
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE metrics
+				ALTER COLUMN timestamp TYPE TIMESTAMP USING timestamp AT TIME ZONE 'UTC';
+			ALTER TABLE schema_migrations
+				ALTER COLUMN applied_at TYPE TIMESTAMP USING applied_at AT TIME ZONE 'UTC';
+			ALTER TABLE branding_settings
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE ip_leases
+				ALTER COLUMN allocated_at TYPE TIMESTAMP USING allocated_at AT TIME ZONE 'UTC';
+			ALTER TABLE users
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+				ALTER COLUMN password_changed_at TYPE TIMESTAMP USING password_changed_at AT TIME ZONE 'UTC';
+			ALTER TABLE networks
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_groups
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+		`,
+	},
+	{
+		// Cumulative interface counters; rates are derived at query time
+		Version:     16,
+		Description: "Add network counters to metrics",
+		Up: `
+			ALTER TABLE metrics ADD COLUMN IF NOT EXISTS network_rx_bytes BIGINT NOT NULL DEFAULT 0;
+			ALTER TABLE metrics ADD COLUMN IF NOT EXISTS network_tx_bytes BIGINT NOT NULL DEFAULT 0;
+		`,
+		Down: `
+			ALTER TABLE metrics DROP COLUMN IF EXISTS network_tx_bytes;
+			ALTER TABLE metrics DROP COLUMN IF EXISTS network_rx_bytes;
+		`,
+	},
+	addColumnMigration(17, "Add description to instances", ColumnBackfill{
+		Table:   "instances",
+		Column:  "description",
+		Type:    "TEXT",
+		Default: "''",
+		NotNull: true,
+	}),
+	addColumnMigration(18, "Add plan to users", ColumnBackfill{
+		Table:   "users",
+		Column:  "plan",
+		Type:    "TEXT",
+		Default: "'free'",
+		NotNull: true,
+	}),
+	{
+		Version:     19,
+		Description: "Create instance devices",
+		Up: `
+			CREATE TABLE IF NOT EXISTS instance_devices (
+				instance_name TEXT NOT NULL REFERENCES instances(name) ON DELETE CASCADE,
+				device_name TEXT NOT NULL,
+				device_type TEXT NOT NULL,
+				config JSONB NOT NULL DEFAULT '{}',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (instance_name, device_name)
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS instance_devices CASCADE;
+		`,
+	},
+	{
+		Version:     20,
+		Description: "Create per-image creation defaults",
+		Up: `
+			CREATE TABLE IF NOT EXISTS image_defaults (
+				image TEXT PRIMARY KEY,
+				cpu INTEGER NOT NULL CHECK (cpu > 0),
+				memory_mib INTEGER NOT NULL CHECK (memory_mib > 0),
+				disk_gb INTEGER NOT NULL CHECK (disk_gb > 0)
+			);
+
+			INSERT INTO image_defaults (image, cpu, memory_mib, disk_gb) VALUES
+				('ubuntu*', 1, 512, 10),
+				('alpine*', 1, 128, 2)
+			ON CONFLICT DO NOTHING;
+		`,
+		Down: `
+			DROP TABLE IF EXISTS image_defaults CASCADE;
+		`,
+	},
+	// New instances are created to run. Existing ones take the last observed
+	// status (still kept in limits at this version); '' means unknown, which
+	// is never reported as a crash.
+	addColumnMigration(21, "Add desired state to instances", ColumnBackfill{
+		Table:    "instances",
+		Column:   "desired_state",
+		Type:     "TEXT",
+		Default:  "'RUNNING'",
+		Backfill: "CASE WHEN upper(limits->>'status') IN ('RUNNING', 'STOPPED') THEN upper(limits->>'status') ELSE '' END",
+		NotNull:  true,
+	}),
+	{
+		Version:     22,
+		Description: "Add result to jobs",
+		Up: `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result TEXT;
+		`,
+		Down: `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS result;
+		`,
+	},
+	addColumnMigration(23, "Add storage pool to instances", ColumnBackfill{
+		Table:   "instances",
+		Column:  "storage_pool",
+		Type:    "TEXT",
+		Default: "''",
+		NotNull: true,
+	}),
+	addColumnMigration(24, "Add cloud-init status to instances", ColumnBackfill{
+		Table:   "instances",
+		Column:  "cloud_init_status",
+		Type:    "TEXT",
+		Default: "''",
+		NotNull: true,
+	}),
+	addColumnMigration(25, "Add owner to instances", ColumnBackfill{
+		Table:   "instances",
+		Column:  "owner",
+		Type:    "TEXT",
+		Default: "''",
+		NotNull: true,
+	}),
+	{
+		Version:     26,
+		Description: "Remember the previous owner of released IP leases",
+		Up: `
+			ALTER TABLE ip_leases ADD COLUMN IF NOT EXISTS released_name TEXT;
+			ALTER TABLE ip_leases ADD COLUMN IF NOT EXISTS released_at TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS idx_ip_leases_released_name ON ip_leases(released_name);
+		`,
+		Down: `
+			DROP INDEX IF EXISTS idx_ip_leases_released_name;
+			ALTER TABLE ip_leases DROP COLUMN IF EXISTS released_at;
+			ALTER TABLE ip_leases DROP COLUMN IF EXISTS released_name;
+		`,
+	},
+	{
+		Version:     27,
+		Description: "Create hourly and daily metrics rollups",
+		Up: `
+			CREATE TABLE IF NOT EXISTS metrics_hourly (
+				instance_name TEXT NOT NULL,
+				bucket TIMESTAMP NOT NULL,
+				avg_cpu DOUBLE PRECISION NOT NULL DEFAULT 0,
+				max_cpu DOUBLE PRECISION NOT NULL DEFAULT 0,
+				avg_memory DOUBLE PRECISION NOT NULL DEFAULT 0,
+				max_memory BIGINT NOT NULL DEFAULT 0,
+				avg_disk DOUBLE PRECISION NOT NULL DEFAULT 0,
+				max_disk BIGINT NOT NULL DEFAULT 0,
+				network_rx_bytes BIGINT NOT NULL DEFAULT 0,
+				network_tx_bytes BIGINT NOT NULL DEFAULT 0,
+				sample_count INTEGER NOT NULL CHECK (sample_count > 0),
+				PRIMARY KEY (instance_name, bucket)
+			);
+
+			CREATE TABLE IF NOT EXISTS metrics_daily (LIKE metrics_hourly INCLUDING ALL);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS metrics_daily;
+			DROP TABLE IF EXISTS metrics_hourly;
+		`,
+	},
+	{
+		Version:     28,
+		Description: "Create global settings table",
+		Up: `
+			CREATE TABLE IF NOT EXISTS settings (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_by TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `DROP TABLE IF EXISTS settings;`,
+	},
+	{
+		Version:     29,
+		Description: "Move observed status and IPs out of instance limits",
+		Up: `
+			CREATE TABLE IF NOT EXISTS instance_volatile (
+				instance_name TEXT PRIMARY KEY REFERENCES instances(name) ON DELETE CASCADE,
+				status TEXT NOT NULL DEFAULT '',
+				ipv4 TEXT NOT NULL DEFAULT '',
+				ipv6 TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			INSERT INTO instance_volatile (instance_name, status, ipv4, ipv6)
+			SELECT name,
+			       COALESCE(limits->>'status', ''),
+			       COALESCE(limits->>'volatile.ipv4', limits->>'volatile.ip_address', ''),
+			       COALESCE(limits->>'volatile.ipv6', '')
+			FROM instances
+			ON CONFLICT (instance_name) DO NOTHING;
+
+			UPDATE instances SET limits = (
+				SELECT COALESCE(jsonb_object_agg(key, value), '{}'::jsonb)
+				FROM jsonb_each(limits)
+				WHERE key <> 'status' AND key NOT LIKE 'volatile.%'
+			)
+			WHERE EXISTS (
+				SELECT 1 FROM jsonb_object_keys(limits) k
+				WHERE k = 'status' OR k LIKE 'volatile.%'
+			);
+		`,
+		Down: `
+			UPDATE instances i SET limits = i.limits || jsonb_strip_nulls(jsonb_build_object(
+				'status', NULLIF(v.status, ''),
+				'volatile.ipv4', NULLIF(v.ipv4, ''),
+				'volatile.ipv6', NULLIF(v.ipv6, '')
+			))
+			FROM instance_volatile v
+			WHERE v.instance_name = i.name;
+
+			DROP TABLE IF EXISTS instance_volatile;
+		`,
+	},
+	{
+		Version:     30,
+		Description: "Create scheduled tasks",
+		Up: `
+			CREATE TABLE IF NOT EXISTS scheduled_tasks (
+				id SERIAL PRIMARY KEY,
+				instance_name TEXT NOT NULL REFERENCES instances(name) ON DELETE CASCADE,
+				cron_expr TEXT NOT NULL,
+				command JSONB NOT NULL,
+				timeout TEXT NOT NULL DEFAULT '',
+				enabled BOOLEAN NOT NULL DEFAULT TRUE,
+				next_run_at TIMESTAMP,
+				last_run_at TIMESTAMP,
+				last_job_id TEXT,
+				created_by TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_due ON scheduled_tasks(next_run_at) WHERE enabled;
+			CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_instance ON scheduled_tasks(instance_name);
+		`,
+		Down: `DROP TABLE IF EXISTS scheduled_tasks;`,
+	},
+	{
+		Version:     31,
+		Description: "Create instance backup encryption settings",
+		Up: `
+			CREATE TABLE IF NOT EXISTS instance_backup_encryption (
+				instance_name TEXT PRIMARY KEY REFERENCES instances(name) ON DELETE CASCADE,
+				mode TEXT NOT NULL,
+				recipient TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `DROP TABLE IF EXISTS instance_backup_encryption;`,
+	},
+	{
+		Version:     32,
+		Description: "Create encrypted secrets store",
+		Up: `
+			CREATE TABLE IF NOT EXISTS secrets (
+				name TEXT PRIMARY KEY,
+				ciphertext BYTEA NOT NULL,
+				created_by TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Down: `DROP TABLE IF EXISTS secrets;`,
+	},
+	{
+		Version:     33,
+		Description: "Track the LXD operation behind long-running jobs",
+		Up: `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lxd_operation TEXT;
+		`,
+		Down: `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS lxd_operation;
+		`,
+	},
+	{
+		Version:     34,
+		Description: "Store point-in-time usage reports",
+		Up: `
+			CREATE TABLE IF NOT EXISTS reports (
+				id BIGSERIAL PRIMARY KEY,
+				generated_at TIMESTAMP NOT NULL,
+				generated_by TEXT NOT NULL DEFAULT '',
+				instance_count INTEGER NOT NULL DEFAULT 0,
+				data JSONB NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_reports_generated_at ON reports(generated_at DESC);
+		`,
+		Down: `DROP TABLE IF EXISTS reports;`,
+	},
+	{
+		Version:     35,
+		Description: "Add readiness probes to instances",
+		Up: `
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS readiness_probe JSONB;
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS ready BOOLEAN;
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS ready_checked_at TIMESTAMP;
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS ready_message TEXT;
+		`,
+		Down: `
+			ALTER TABLE instances DROP COLUMN IF EXISTS ready_message;
+			ALTER TABLE instances DROP COLUMN IF EXISTS ready_checked_at;
+			ALTER TABLE instances DROP COLUMN IF EXISTS ready;
+			ALTER TABLE instances DROP COLUMN IF EXISTS readiness_probe;
+		`,
+	},
+	{
+		Version:     36,
+		Description: "Create instance stacks",
+		Up: `
+			CREATE TABLE IF NOT EXISTS stacks (
+				id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+				name TEXT UNIQUE NOT NULL,
+				network_id TEXT NOT NULL DEFAULT '',
+				tags JSONB NOT NULL DEFAULT '[]',
+				owner TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS stack_members (
+				stack_id UUID NOT NULL REFERENCES stacks(id) ON DELETE CASCADE,
+				instance_name TEXT NOT NULL REFERENCES instances(name) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				depends_on JSONB NOT NULL DEFAULT '[]',
+				PRIMARY KEY (stack_id, instance_name)
+			);
+			CREATE INDEX IF NOT EXISTS idx_stack_members_instance ON stack_members(instance_name);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS stack_members CASCADE;
+			DROP TABLE IF EXISTS stacks CASCADE;
+		`,
+	},
+	{
+		Version:     37,
+		Description: "Classify job failures with an error code",
+		Up: `
+			ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error_code TEXT;
+		`,
+		Down: `
+			ALTER TABLE jobs DROP COLUMN IF EXISTS error_code;
+		`,
+	},
+	{
+		Version:     38,
+		Description: "Add GFS retention policies to instance backups",
+		Up: `
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS retention_policy JSONB;
+		`,
+		Down: `
+			ALTER TABLE instances DROP COLUMN IF EXISTS retention_policy;
+		`,
+	},
+	{
+		Version:     39,
+		Description: "Cascade instance renames to referencing tables",
+		// Every foreign key to instances(name) keeps its ON DELETE and gains
+		// ON UPDATE CASCADE, so renaming the primary key carries the children
+		Up: `
+			DO $$
+			DECLARE fk RECORD;
+			BEGIN
+				FOR fk IN
+					SELECT conname, conrelid::regclass AS tbl, pg_get_constraintdef(oid) AS def
+					FROM pg_constraint
+					WHERE contype = 'f' AND confrelid = 'instances'::regclass AND confupdtype <> 'c'
+				LOOP
+					EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.tbl, fk.conname);
+					EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s ON UPDATE CASCADE', fk.tbl, fk.conname, fk.def);
+				END LOOP;
+			END $$;
+		`,
+		Down: `
+			DO $$
+			DECLARE fk RECORD;
+			BEGIN
+				FOR fk IN
+					SELECT conname, conrelid::regclass AS tbl, pg_get_constraintdef(oid) AS def
+					FROM pg_constraint
+					WHERE contype = 'f' AND confrelid = 'instances'::regclass AND confupdtype = 'c'
+				LOOP
+					EXECUTE format('ALTER TABLE %s DROP CONSTRAINT %I', fk.tbl, fk.conname);
+					EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s', fk.tbl, fk.conname, replace(fk.def, ' ON UPDATE CASCADE', ''));
+				END LOOP;
+			END $$;
+		`,
+	},
+	{
+		Version:     40,
+		Description: "Record the Git config source of instances",
+		Up: `
+			ALTER TABLE instances ADD COLUMN IF NOT EXISTS config_source JSONB;
+		`,
+		Down: `
+			ALTER TABLE instances DROP COLUMN IF EXISTS config_source;
+		`,
+	},
+	{
+		Version:     41,
+		Description: "Track host ports claimed by port forwards",
+		Up: `
+			CREATE TABLE IF NOT EXISTS port_forwards (
+				host_port INTEGER NOT NULL CHECK (host_port BETWEEN 1 AND 65535),
+				protocol TEXT NOT NULL CHECK (protocol IN ('tcp', 'udp')),
+				instance_name TEXT NOT NULL REFERENCES instances(name) ON DELETE CASCADE ON UPDATE CASCADE,
+				container_port INTEGER NOT NULL CHECK (container_port BETWEEN 1 AND 65535),
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (host_port, protocol)
+			);
+			CREATE INDEX IF NOT EXISTS idx_port_forwards_instance ON port_forwards(instance_name);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS port_forwards CASCADE;
+		`,
+	},
+	{
+		// Same conversion as migration 15 for the timestamp columns added
+		// since; their values were written as UTC.
+		Version:     42,
+		Description: "Convert later timestamp columns to TIMESTAMPTZ (UTC)",
+		Up: `
+			ALTER TABLE ip_leases
+				ALTER COLUMN released_at TYPE TIMESTAMPTZ USING released_at AT TIME ZONE 'UTC';
+			ALTER TABLE metrics_hourly
+				ALTER COLUMN bucket TYPE TIMESTAMPTZ USING bucket AT TIME ZONE 'UTC';
+			ALTER TABLE metrics_daily
+				ALTER COLUMN bucket TYPE TIMESTAMPTZ USING bucket AT TIME ZONE 'UTC';
+			ALTER TABLE settings
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_volatile
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE scheduled_tasks
+				ALTER COLUMN next_run_at TYPE TIMESTAMPTZ USING next_run_at AT TIME ZONE 'UTC',
+				ALTER COLUMN last_run_at TYPE TIMESTAMPTZ USING last_run_at AT TIME ZONE 'UTC',
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_backup_encryption
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE secrets
+				ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE reports
+				ALTER COLUMN generated_at TYPE TIMESTAMPTZ USING generated_at AT TIME ZONE 'UTC';
+			ALTER TABLE instances
+				ALTER COLUMN ready_checked_at TYPE TIMESTAMPTZ USING ready_checked_at AT TIME ZONE 'UTC';
+		`,
+		Down: `
+			ALTER TABLE ip_leases
+				ALTER COLUMN released_at TYPE TIMESTAMP USING released_at AT TIME ZONE 'UTC';
+			ALTER TABLE metrics_hourly
+				ALTER COLUMN bucket TYPE TIMESTAMP USING bucket AT TIME ZONE 'UTC';
+			ALTER TABLE metrics_daily
+				ALTER COLUMN bucket TYPE TIMESTAMP USING bucket AT TIME ZONE 'UTC';
+			ALTER TABLE settings
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_volatile
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE scheduled_tasks
+				ALTER COLUMN next_run_at TYPE TIMESTAMP USING next_run_at AT TIME ZONE 'UTC',
+				ALTER COLUMN last_run_at TYPE TIMESTAMP USING last_run_at AT TIME ZONE 'UTC',
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+			ALTER TABLE instance_backup_encryption
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE secrets
+				ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+				ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC';
+			ALTER TABLE reports
+				ALTER COLUMN generated_at TYPE TIMESTAMP USING generated_at AT TIME ZONE 'UTC';
+			ALTER TABLE instances
+				ALTER COLUMN ready_checked_at TYPE TIMESTAMP USING ready_checked_at AT TIME ZONE 'UTC';
+		`,
+	},
+}
+
+// ============================================================================
+// SAFE COLUMN ADDITION
+// ============================================================================
+
+// ColumnBackfill describes a column added to a table that may already hold
+// rows. Adding "NOT NULL" directly fails on populated tables unless every row
+// can take the default, so the column is added nullable, existing rows are
+// backfilled in batches and only then is NOT NULL enforced.
+type ColumnBackfill struct {
+	Table     string
+	Column    string
+	Type      string // SQL type, e.g. "TEXT", "INTEGER"
+	Default   string // SQL expression for new rows; empty for no default
+	Backfill  string // SQL expression for existing rows; falls back to Default
+	BatchSize int    // rows per UPDATE; 1000 if zero
+	NotNull   bool   // enforce NOT NULL once every row has a value
+}
+
+// Up returns the SQL that adds, backfills and optionally constrains the column.
+// It runs inside the migration transaction, so batching bounds the size of
+// each UPDATE rather than committing progress.
+func (c ColumnBackfill) Up() string {
+	backfill := c.Backfill
+	if backfill == "" {
+		backfill = c.Default
+	}
+	batch := c.BatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n", c.Table, c.Column, c.Type)
+	if c.Default != "" {
+		fmt.Fprintf(&b, "ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;\n", c.Table, c.Column, c.Default)
+	}
+	if backfill != "" {
+		fmt.Fprintf(&b, `DO $$
+DECLARE
+	updated INTEGER;
+BEGIN
+	LOOP
+		UPDATE %[1]s SET %[2]s = %[3]s
+		WHERE ctid = ANY(ARRAY(SELECT ctid FROM %[1]s WHERE %[2]s IS NULL LIMIT %[4]d));
+		GET DIAGNOSTICS updated = ROW_COUNT;
+		EXIT WHEN updated = 0;
+	END LOOP;
+END $$;
+`, c.Table, c.Column, backfill, batch)
+	}
+	if c.NotNull {
+		fmt.Fprintf(&b, "ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;\n", c.Table, c.Column)
+	}
+	return b.String()
+}
+
+func (c ColumnBackfill) Down() string {
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", c.Table, c.Column)
+}
+
+func addColumnMigration(version int, description string, col ColumnBackfill) Migration {
+	return Migration{
+		Version:     version,
+		Description: description,
+		Up:          col.Up(),
+		Down:        col.Down(),
+	}
 }
 
 // ============================================================================
@@ -388,6 +1020,16 @@ func RollbackMigration(ctx context.Context, db *Service, targetVersion int) erro
 // ============================================================================
 
 func GetNextRunTime(schedule string) (*time.Time, error) {
+	runs, err := GetNextRunTimes(schedule, time.Now().UTC(), 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// GetNextRunTimes returns the next n activations of schedule after from.
+// An empty schedule has no runs.
+func GetNextRunTimes(schedule string, from time.Time, n int) ([]time.Time, error) {
 	if schedule == "" {
 		return nil, nil
 	}
@@ -407,29 +1049,30 @@ func GetNextRunTime(schedule string) (*time.Time, error) {
 		return nil, fmt.Errorf("failed to parse cron schedule '%s': %w", schedule, err)
 	}
 
-	nextRun := sched.Next(time.Now().UTC())
-	return &nextRun, nil
+	runs := make([]time.Time, 0, n)
+	for next := from; len(runs) < n; {
+		next = sched.Next(next)
+		if next.IsZero() {
+			break // Schedules such as "0 0 30 2 *" never fire
+		}
+		runs = append(runs, next)
+	}
+	return runs, nil
 }
 
 // ============================================================================
 // MAINTENANCE TASKS
 // ============================================================================
 
-func RunMaintenance(ctx context.Context, db *Service) error {
+// RunMaintenance deletes finished jobs past retention, recovers stuck jobs
+// and vacuums. The caller schedules it; metrics are rolled up hourly by the
+// historical collector.
+func RunMaintenance(ctx context.Context, db *Service, retention JobRetention) error {
 	log.Println("[Maintenance] Starting database maintenance...")
 
-	// Clean old metrics (older than 30 days)
-	metricsRepo := NewMetricsRepository(db)
-	deletedMetrics, err := metricsRepo.DeleteOlderThan(ctx, 30*24*time.Hour)
-	if err != nil {
-		log.Printf("[Maintenance] Error cleaning old metrics: %v", err)
-	} else if deletedMetrics > 0 {
-		log.Printf("[Maintenance] Deleted %d old metrics", deletedMetrics)
-	}
-
-	// Clean old jobs (completed/failed older than 7 days)
+	// Clean old jobs (per-status retention, 7 days by default)
 	jobsRepo := NewJobRepository(db)
-	deletedJobs, err := jobsRepo.DeleteOldJobs(ctx, 7*24*time.Hour)
+	deletedJobs, err := jobsRepo.DeleteOldJobs(ctx, retention)
 	if err != nil {
 		log.Printf("[Maintenance] Error cleaning old jobs: %v", err)
 	} else if deletedJobs > 0 {
@@ -455,26 +1098,6 @@ func RunMaintenance(ctx context.Context, db *Service) error {
 	return nil
 }
 
-func StartMaintenanceScheduler(ctx context.Context, db *Service, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	log.Printf("[Maintenance] Scheduler started (interval: %v)", interval)
-
-	for {
-		select {
-		case <-ticker.C:
-			if err := RunMaintenance(ctx, db); err != nil {
-				log.Printf("[Maintenance] Error during maintenance: %v", err)
-			}
-
-		case <-ctx.Done():
-			log.Println("[Maintenance] Scheduler stopped")
-			return
-		}
-	}
-}
-
 // ============================================================================
 // UTILITY FUNCTIONS
 // ============================================================================
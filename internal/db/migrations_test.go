@@ -0,0 +1,39 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrationVersionsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration at index %d has version %d, want %d", i, m.Version, i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d must define both Up and Down", m.Version)
+		}
+	}
+}
+
+func TestColumnBackfillEnforcesNotNullLast(t *testing.T) {
+	up := ColumnBackfill{
+		Table:     "instances",
+		Column:    "owner",
+		Type:      "TEXT",
+		Default:   "'admin'",
+		BatchSize: 50,
+		NotNull:   true,
+	}.Up()
+
+	add := strings.Index(up, "ADD COLUMN IF NOT EXISTS owner TEXT;")
+	fill := strings.Index(up, "LIMIT 50")
+	notNull := strings.Index(up, "SET NOT NULL")
+
+	if add < 0 || fill < 0 || notNull < 0 {
+		t.Fatalf("missing expected statements in:\n%s", up)
+	}
+	if !(add < fill && fill < notNull) {
+		t.Errorf("expected add -> backfill -> NOT NULL order, got:\n%s", up)
+	}
+}
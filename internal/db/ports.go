@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ============================================================================
+// PORT FORWARDS (host port allocation)
+// ============================================================================
+
+// A host port is claimed here when POST /instances/:name/ports is accepted,
+// before the add_port job creates the LXD proxy device, so two requests can
+// never be given the same port. The claim is released by the remove_port job,
+// by a failed add_port job, or by deleting the instance (ON DELETE CASCADE).
+// Proxy devices that predate the table are adopted at startup (Adopt), so an
+// automatic pick never lands on a port LXD already forwards.
+
+var (
+	// ErrPortInUse is returned when the host port is already claimed for the protocol
+	ErrPortInUse = errors.New("host port already allocated")
+	// ErrNoFreePort is returned when every port of the range is claimed
+	ErrNoFreePort = errors.New("no free host port in range")
+)
+
+// reserveAttempts bounds the retries of an automatic pick that lost a race
+const reserveAttempts = 3
+
+type PortForward struct {
+	HostPort      int       `json:"host_port"`
+	Protocol      string    `json:"protocol"`
+	InstanceName  string    `json:"instance_name"`
+	ContainerPort int       `json:"container_port"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type PortForwardRepository struct {
+	db *Service
+}
+
+func NewPortForwardRepository(db *Service) *PortForwardRepository {
+	return &PortForwardRepository{db: db}
+}
+
+// Reserve claims pf.HostPort for pf.Protocol. With HostPort 0 the lowest free
+// port in [min, max] is picked and written back to pf.
+func (r *PortForwardRepository) Reserve(ctx context.Context, pf *PortForward, min, max int) error {
+	if pf.HostPort != 0 {
+		query := `
+			INSERT INTO port_forwards (host_port, protocol, instance_name, container_port)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (host_port, protocol) DO NOTHING
+			RETURNING created_at
+		`
+		err := r.db.QueryRowContext(ctx, query, pf.HostPort, pf.Protocol, pf.InstanceName, pf.ContainerPort).Scan(&pf.CreatedAt)
+		if err == sql.ErrNoRows {
+			return ErrPortInUse
+		}
+		return err
+	}
+
+	// The free-port scan and the insert are one statement; a concurrent
+	// claim of the same port makes it insert nothing, so pick again
+	query := `
+		INSERT INTO port_forwards (host_port, protocol, instance_name, container_port)
+		SELECT p, $3, $4, $5
+		FROM generate_series($1::int, $2::int) AS p
+		WHERE NOT EXISTS (
+			SELECT 1 FROM port_forwards f WHERE f.host_port = p AND f.protocol = $3
+		)
+		ORDER BY p
+		LIMIT 1
+		ON CONFLICT (host_port, protocol) DO NOTHING
+		RETURNING host_port, created_at
+	`
+	for attempt := 0; attempt < reserveAttempts; attempt++ {
+		err := r.db.QueryRowContext(ctx, query, min, max, pf.Protocol, pf.InstanceName, pf.ContainerPort).
+			Scan(&pf.HostPort, &pf.CreatedAt)
+		if err != sql.ErrNoRows {
+			return err
+		}
+		if free, err := r.hasFree(ctx, pf.Protocol, min, max); err != nil {
+			return err
+		} else if !free {
+			return ErrNoFreePort
+		}
+	}
+	return ErrNoFreePort
+}
+
+func (r *PortForwardRepository) hasFree(ctx context.Context, protocol string, min, max int) (bool, error) {
+	var claimed int
+	query := `SELECT COUNT(*) FROM port_forwards WHERE protocol = $1 AND host_port BETWEEN $2 AND $3`
+	if err := r.db.QueryRowContext(ctx, query, protocol, min, max).Scan(&claimed); err != nil {
+		return false, err
+	}
+	return claimed < max-min+1, nil
+}
+
+// Adopt records a forward found on LXD. Ports already claimed and instances
+// Axion does not know are skipped; it reports whether a row was added.
+func (r *PortForwardRepository) Adopt(ctx context.Context, pf PortForward) (bool, error) {
+	query := `
+		INSERT INTO port_forwards (host_port, protocol, instance_name, container_port)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM instances WHERE name = $3)
+		ON CONFLICT (host_port, protocol) DO NOTHING
+	`
+	result, err := r.db.ExecContext(ctx, query, pf.HostPort, pf.Protocol, pf.InstanceName, pf.ContainerPort)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Release frees a claimed host port; releasing an unclaimed one is not an error
+func (r *PortForwardRepository) Release(ctx context.Context, hostPort int, protocol string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM port_forwards WHERE host_port = $1 AND protocol = $2`, hostPort, protocol)
+	return err
+}
+
+// ReleasePortForward frees a claimed host port (used by the worker)
+func ReleasePortForward(hostPort int, protocol string) error {
+	ctx := context.Background()
+	repo := NewPortForwardRepository(GetService())
+	return repo.Release(ctx, hostPort, protocol)
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// READINESS
+// ============================================================================
+
+// SetReadinessProbe stores the probe of an instance, or removes it when nil.
+// Either way the previous result is cleared: it described another probe.
+func (r *InstanceRepository) SetReadinessProbe(ctx context.Context, name string, probe *types.ReadinessProbe) error {
+	var probeJSON sql.NullString
+	if probe != nil {
+		data, err := json.Marshal(probe)
+		if err != nil {
+			return fmt.Errorf("marshal readiness probe: %w", err)
+		}
+		probeJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := `
+		UPDATE instances
+		SET readiness_probe = $1, ready = NULL, ready_checked_at = NULL, ready_message = NULL
+		WHERE name = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, probeJSON, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+// GetReadiness returns the probe of an instance and its last result
+func (r *InstanceRepository) GetReadiness(ctx context.Context, name string) (*types.Readiness, error) {
+	query := `
+		SELECT readiness_probe, ready, ready_checked_at, COALESCE(ready_message, '')
+		FROM instances
+		WHERE name = $1
+	`
+
+	var probeJSON sql.NullString
+	var ready sql.NullBool
+	var checkedAt sql.NullTime
+	readiness := &types.Readiness{}
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&probeJSON, &ready, &checkedAt, &readiness.Message)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if probeJSON.Valid {
+		readiness.Probe = &types.ReadinessProbe{}
+		if err := json.Unmarshal([]byte(probeJSON.String), readiness.Probe); err != nil {
+			return nil, fmt.Errorf("unmarshal readiness probe of %s: %w", name, err)
+		}
+	}
+	if ready.Valid {
+		readiness.Ready = &ready.Bool
+	}
+	if checkedAt.Valid {
+		t := checkedAt.Time.UTC()
+		readiness.CheckedAt = &t
+	}
+	return readiness, nil
+}
+
+// SetReady records the result of a readiness evaluation
+func (r *InstanceRepository) SetReady(ctx context.Context, name string, ready bool, message string) error {
+	query := `
+		UPDATE instances
+		SET ready = $1, ready_checked_at = $2, ready_message = NULLIF($3, '')
+		WHERE name = $4
+	`
+	result, err := r.db.ExecContext(ctx, query, ready, time.Now().UTC(), message, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+func GetInstanceReadiness(name string) (*types.Readiness, error) {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.GetReadiness(ctx, name)
+}
+
+func SetInstanceReady(name string, ready bool, message string) error {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.SetReady(ctx, name, ready, message)
+}
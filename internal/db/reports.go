@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// USAGE REPORTS (point in time)
+// ============================================================================
+
+// ErrReportNotFound is returned when a stored report id does not exist
+var ErrReportNotFound = errors.New("report not found")
+
+// ReportUsage is the latest metric sample of an instance at report time
+type ReportUsage struct {
+	SampledAt      time.Time `json:"sampled_at"`
+	CPUPercent     float64   `json:"cpu_percent"`
+	MemoryUsage    int64     `json:"memory_usage"`
+	DiskUsage      int64     `json:"disk_usage"`
+	NetworkRxBytes int64     `json:"network_rx_bytes"`
+	NetworkTxBytes int64     `json:"network_tx_bytes"`
+}
+
+// ReportInstance is one instance in a usage report
+type ReportInstance struct {
+	Name   string            `json:"name"`
+	Owner  string            `json:"owner"`
+	Type   string            `json:"type"`
+	Image  string            `json:"image"`
+	Status string            `json:"status"`
+	IP     string            `json:"ip"`
+	Node   string            `json:"node"`
+	Limits map[string]string `json:"limits"`
+	// Usage is nil when the instance has never been sampled
+	Usage *ReportUsage `json:"usage"`
+}
+
+// UsageReport is the configuration and latest usage of every instance, read
+// from a single database snapshot so all rows describe the same moment.
+type UsageReport struct {
+	ID          int64            `json:"id,omitempty"` // Set once persisted
+	GeneratedAt time.Time        `json:"generated_at"`
+	GeneratedBy string           `json:"generated_by"`
+	Instances   []ReportInstance `json:"instances"`
+}
+
+// ReportSummary lists a stored report without its rows
+type ReportSummary struct {
+	ID            int64     `json:"id"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	GeneratedBy   string    `json:"generated_by"`
+	InstanceCount int       `json:"instance_count"`
+}
+
+type ReportRepository struct {
+	db *Service
+}
+
+func NewReportRepository(db *Service) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// Capture builds a report of every instance in one read-only repeatable-read
+// transaction. node is recorded for every row (single-node deployments).
+func (r *ReportRepository) Capture(ctx context.Context, generatedBy, node string) (*UsageReport, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &UsageReport{GeneratedBy: generatedBy, Instances: []ReportInstance{}}
+	if err := tx.QueryRowContext(ctx, `SELECT CURRENT_TIMESTAMP`).Scan(&report.GeneratedAt); err != nil {
+		return nil, err
+	}
+	report.GeneratedAt = report.GeneratedAt.UTC()
+
+	query := `
+		SELECT i.name, i.owner, i.type, i.image, i.limits, i.desired_state,
+		       COALESCE(NULLIF(v.status, ''), 'UNKNOWN'),
+		       COALESCE(l.ip, NULLIF(v.ipv4, ''), ''),
+		       m.timestamp, m.cpu_percent, m.memory_usage, m.disk_usage,
+		       m.network_rx_bytes, m.network_tx_bytes
+		FROM instances i
+		LEFT JOIN ip_leases l ON l.instance_name = i.name
+		LEFT JOIN instance_volatile v ON v.instance_name = i.name
+		LEFT JOIN LATERAL (
+			SELECT timestamp, cpu_percent, COALESCE(memory_usage, 0) AS memory_usage,
+			       COALESCE(disk_usage, 0) AS disk_usage,
+			       network_rx_bytes, network_tx_bytes
+			FROM metrics
+			WHERE instance_name = i.name
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) m ON true
+		ORDER BY i.name
+	`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		inst := ReportInstance{Node: node}
+		var limitsJSON, desired string
+		var sampledAt sql.NullTime
+		var cpu sql.NullFloat64
+		var mem, disk, rx, tx sql.NullInt64
+
+		if err := rows.Scan(&inst.Name, &inst.Owner, &inst.Type, &inst.Image, &limitsJSON, &desired,
+			&inst.Status, &inst.IP, &sampledAt, &cpu, &mem, &disk, &rx, &tx); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(limitsJSON), &inst.Limits); err != nil {
+			log.Printf("[Reports] Failed to unmarshal limits for %s: %v", inst.Name, err)
+		}
+		inst.Limits = DurableLimits(inst.Limits)
+		inst.Status = types.ClassifyStatus(inst.Status, desired)
+		if sampledAt.Valid {
+			inst.Usage = &ReportUsage{
+				SampledAt:      sampledAt.Time.UTC(),
+				CPUPercent:     cpu.Float64,
+				MemoryUsage:    mem.Int64,
+				DiskUsage:      disk.Int64,
+				NetworkRxBytes: rx.Int64,
+				NetworkTxBytes: tx.Int64,
+			}
+		}
+		report.Instances = append(report.Instances, inst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Save persists the report for later comparison and sets its ID
+func (r *ReportRepository) Save(ctx context.Context, report *UsageReport) error {
+	data, err := json.Marshal(report.Instances)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+
+	query := `
+		INSERT INTO reports (generated_at, generated_by, instance_count, data)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	return r.db.QueryRowContext(ctx, query, report.GeneratedAt, report.GeneratedBy, len(report.Instances), string(data)).Scan(&report.ID)
+}
+
+// Get loads a stored report
+func (r *ReportRepository) Get(ctx context.Context, id int64) (*UsageReport, error) {
+	query := `SELECT id, generated_at, generated_by, data FROM reports WHERE id = $1`
+
+	report := &UsageReport{}
+	var data string
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&report.ID, &report.GeneratedAt, &report.GeneratedBy, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.GeneratedAt = report.GeneratedAt.UTC()
+	if err := json.Unmarshal([]byte(data), &report.Instances); err != nil {
+		return nil, fmt.Errorf("unmarshal report %d: %w", id, err)
+	}
+	return report, nil
+}
+
+// List returns the most recent stored reports, newest first
+func (r *ReportRepository) List(ctx context.Context, limit int) ([]ReportSummary, error) {
+	query := `
+		SELECT id, generated_at, generated_by, instance_count
+		FROM reports
+		ORDER BY generated_at DESC, id DESC
+		LIMIT $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []ReportSummary{}
+	for rows.Next() {
+		var s ReportSummary
+		if err := rows.Scan(&s.ID, &s.GeneratedAt, &s.GeneratedBy, &s.InstanceCount); err != nil {
+			return nil, err
+		}
+		s.GeneratedAt = s.GeneratedAt.UTC()
+		reports = append(reports, s)
+	}
+	return reports, rows.Err()
+}
+
+// reportCSVHeader is the column order of WriteCSV
+var reportCSVHeader = []string{
+	"generated_at", "instance", "owner", "type", "image", "status", "ip", "node",
+	"limits_cpu", "limits_memory", "limits_disk",
+	"sampled_at", "cpu_percent", "memory_usage", "disk_usage", "network_rx_bytes", "network_tx_bytes",
+}
+
+// WriteCSV writes one row per instance. Usage columns are empty for
+// instances that have never been sampled.
+func (r *UsageReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportCSVHeader); err != nil {
+		return err
+	}
+
+	generatedAt := r.GeneratedAt.UTC().Format(time.RFC3339)
+	for _, inst := range r.Instances {
+		row := []string{
+			generatedAt, inst.Name, inst.Owner, inst.Type, inst.Image, inst.Status, inst.IP, inst.Node,
+			inst.Limits["limits.cpu"], inst.Limits["limits.memory"], inst.Limits["limits.disk"],
+			"", "", "", "", "", "",
+		}
+		if u := inst.Usage; u != nil {
+			copy(row[11:], []string{
+				u.SampledAt.UTC().Format(time.RFC3339),
+				strconv.FormatFloat(u.CPUPercent, 'f', 2, 64),
+				strconv.FormatInt(u.MemoryUsage, 10),
+				strconv.FormatInt(u.DiskUsage, 10),
+				strconv.FormatInt(u.NetworkRxBytes, 10),
+				strconv.FormatInt(u.NetworkTxBytes, 10),
+			})
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageReportWriteCSV(t *testing.T) {
+	at := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	report := &UsageReport{
+		GeneratedAt: at,
+		Instances: []ReportInstance{
+			{
+				Name: "web", Owner: "alice", Type: "container", Image: "ubuntu/22.04", Status: "RUNNING",
+				IP: "10.0.0.5", Node: "host1",
+				Limits: map[string]string{"limits.cpu": "2", "limits.memory": "1GB"},
+				Usage: &ReportUsage{
+					SampledAt: at.Add(-30 * time.Second), CPUPercent: 12.5,
+					MemoryUsage: 1024, DiskUsage: 2048, NetworkRxBytes: 10, NetworkTxBytes: 20,
+				},
+			},
+			// Never sampled, with a comma that must be quoted
+			{Name: "new", Owner: "bob, jr", Type: "virtual-machine", Status: "STOPPED"},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		strings.Join(reportCSVHeader, ","),
+		"2025-06-01T10:00:00Z,web,alice,container,ubuntu/22.04,RUNNING,10.0.0.5,host1,2,1GB,,2025-06-01T09:59:30Z,12.50,1024,2048,10,20",
+		`2025-06-01T10:00:00Z,new,"bob, jr",virtual-machine,,STOPPED,,,,,,,,,,,`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines:\n%s", len(lines), out.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d:\n got %s\nwant %s", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// BACKUP RETENTION POLICY
+// ============================================================================
+
+// GetRetentionPolicy returns the retention policy of an instance's scheduled
+// backups; nil means the simple backup_retention count
+func (r *InstanceRepository) GetRetentionPolicy(ctx context.Context, name string) (*types.RetentionPolicy, error) {
+	var policyJSON sql.NullString
+	err := r.db.QueryRowContext(ctx, `SELECT retention_policy FROM instances WHERE name = $1`, name).Scan(&policyJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+	if err != nil || !policyJSON.Valid {
+		return nil, err
+	}
+
+	policy := &types.RetentionPolicy{}
+	if err := json.Unmarshal([]byte(policyJSON.String), policy); err != nil {
+		return nil, fmt.Errorf("unmarshal retention policy of %s: %w", name, err)
+	}
+	return policy, nil
+}
+
+// SetRetentionPolicy stores the retention policy; nil or simple clears it so
+// backup_retention applies again
+func (r *InstanceRepository) SetRetentionPolicy(ctx context.Context, name string, policy *types.RetentionPolicy) error {
+	var policyJSON sql.NullString
+	if policy != nil && policy.Mode != types.RetentionSimple {
+		data, err := json.Marshal(policy)
+		if err != nil {
+			return fmt.Errorf("marshal retention policy: %w", err)
+		}
+		policyJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE instances SET retention_policy = $1 WHERE name = $2`, policyJSON, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, name)
+	}
+
+	return nil
+}
+
+func GetInstanceRetentionPolicy(name string) (*types.RetentionPolicy, error) {
+	ctx := context.Background()
+	repo := NewInstanceRepository(GetService())
+	return repo.GetRetentionPolicy(ctx, name)
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// SCHEDULED TASKS
+// ============================================================================
+
+// ErrScheduledTaskNotFound is returned when no task matches the id
+var ErrScheduledTaskNotFound = errors.New("scheduled task not found")
+
+// ScheduledTask runs Command in an instance on a cron schedule. Each run is an
+// ordinary exec job, so results show up in the job history (LastJobID).
+type ScheduledTask struct {
+	ID        int        `json:"id"`
+	Instance  string     `json:"instance"`
+	Cron      string     `json:"cron"`
+	Command   []string   `json:"command"`
+	Timeout   string     `json:"timeout,omitempty"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastJobID *string    `json:"last_job_id,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ScheduledTaskRepository struct {
+	db *Service
+}
+
+func NewScheduledTaskRepository(db *Service) *ScheduledTaskRepository {
+	return &ScheduledTaskRepository{db: db}
+}
+
+const scheduledTaskColumns = `id, instance_name, cron_expr, command, timeout, enabled,
+	next_run_at, last_run_at, last_job_id, created_by, created_at`
+
+func scanScheduledTask(row interface{ Scan(...interface{}) error }) (*ScheduledTask, error) {
+	var t ScheduledTask
+	var command []byte
+	err := row.Scan(&t.ID, &t.Instance, &t.Cron, &command, &t.Timeout, &t.Enabled,
+		&t.NextRunAt, &t.LastRunAt, &t.LastJobID, &t.CreatedBy, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(command, &t.Command); err != nil {
+		return nil, fmt.Errorf("unmarshal command of task %d: %w", t.ID, err)
+	}
+	return &t, nil
+}
+
+// Create stores a task; its first run is computed from the cron expression
+func (r *ScheduledTaskRepository) Create(ctx context.Context, task *ScheduledTask) error {
+	next, err := GetNextRunTime(task.Cron)
+	if err != nil {
+		return err
+	}
+	command, err := json.Marshal(task.Command)
+	if err != nil {
+		return fmt.Errorf("marshal command: %w", err)
+	}
+
+	query := `
+		INSERT INTO scheduled_tasks (instance_name, cron_expr, command, timeout, enabled, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + scheduledTaskColumns
+
+	created, err := scanScheduledTask(r.db.QueryRowContext(ctx, query,
+		task.Instance, task.Cron, string(command), task.Timeout, task.Enabled, next, task.CreatedBy))
+	if err != nil {
+		return err
+	}
+	*task = *created
+	return nil
+}
+
+func (r *ScheduledTaskRepository) Get(ctx context.Context, id int) (*ScheduledTask, error) {
+	query := `SELECT ` + scheduledTaskColumns + ` FROM scheduled_tasks WHERE id = $1`
+
+	task, err := scanScheduledTask(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrScheduledTaskNotFound, id)
+	}
+	return task, err
+}
+
+// List returns the tasks of an instance, or every task when instance is empty
+func (r *ScheduledTaskRepository) List(ctx context.Context, instance string) ([]ScheduledTask, error) {
+	query := `SELECT ` + scheduledTaskColumns + ` FROM scheduled_tasks
+		WHERE ($1 = '' OR instance_name = $1) ORDER BY id`
+	return r.query(ctx, query, instance)
+}
+
+// ListDue returns the enabled tasks whose next run is at or before now
+func (r *ScheduledTaskRepository) ListDue(ctx context.Context, now time.Time) ([]ScheduledTask, error) {
+	query := `SELECT ` + scheduledTaskColumns + ` FROM scheduled_tasks
+		WHERE enabled AND next_run_at IS NOT NULL AND next_run_at <= $1 ORDER BY next_run_at`
+	return r.query(ctx, query, now.UTC())
+}
+
+func (r *ScheduledTaskRepository) query(ctx context.Context, query string, args ...interface{}) ([]ScheduledTask, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []ScheduledTask{}
+	for rows.Next() {
+		task, err := scanScheduledTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, rows.Err()
+}
+
+// Update replaces the schedule, command and enabled flag, recomputing the next run
+func (r *ScheduledTaskRepository) Update(ctx context.Context, task *ScheduledTask) error {
+	next, err := GetNextRunTime(task.Cron)
+	if err != nil {
+		return err
+	}
+	command, err := json.Marshal(task.Command)
+	if err != nil {
+		return fmt.Errorf("marshal command: %w", err)
+	}
+
+	query := `
+		UPDATE scheduled_tasks
+		SET cron_expr = $2, command = $3, timeout = $4, enabled = $5, next_run_at = $6
+		WHERE id = $1
+		RETURNING ` + scheduledTaskColumns
+
+	updated, err := scanScheduledTask(r.db.QueryRowContext(ctx, query,
+		task.ID, task.Cron, string(command), task.Timeout, task.Enabled, next))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrScheduledTaskNotFound, task.ID)
+	}
+	if err != nil {
+		return err
+	}
+	*task = *updated
+	return nil
+}
+
+func (r *ScheduledTaskRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM scheduled_tasks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %d", ErrScheduledTaskNotFound, id)
+	}
+	return nil
+}
+
+// Claim moves a due task to its next run. It only succeeds for the caller
+// that still sees the old next_run_at, so a run is never fired twice even
+// with several control planes polling the same table.
+func (r *ScheduledTaskRepository) Claim(ctx context.Context, task *ScheduledTask, next *time.Time) (bool, error) {
+	query := `
+		UPDATE scheduled_tasks
+		SET next_run_at = $3, last_run_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND next_run_at = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, task.ID, task.NextRunAt, next)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// SetLastJob records the job created for the latest run
+func (r *ScheduledTaskRepository) SetLastJob(ctx context.Context, id int, jobID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE scheduled_tasks SET last_job_id = $2 WHERE id = $1`, id, jobID)
+	return err
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ============================================================================
+// SECRETS
+// ============================================================================
+
+// Secret is the metadata of a stored secret. The value never leaves the
+// repository in plaintext: callers store and read the sealed ciphertext.
+type Secret struct {
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrSecretOwned is returned when a user writes a secret name created by someone else
+var ErrSecretOwned = errors.New("secret belongs to another user")
+
+// Secrets belong to the user who created them. Methods taking an owner only
+// touch that user's secrets; an empty owner (admins) reaches every secret.
+
+type SecretRepository struct {
+	db *Service
+}
+
+func NewSecretRepository(db *Service) *SecretRepository {
+	return &SecretRepository{db: db}
+}
+
+// Put stores the sealed value of a secret, replacing an existing one of the
+// same owner (rotation). Reports whether the secret was created; returns
+// ErrSecretOwned if the name is taken by another user.
+func (r *SecretRepository) Put(ctx context.Context, name string, ciphertext []byte, createdBy, owner string) (bool, error) {
+	query := `
+		INSERT INTO secrets (name, ciphertext, created_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE
+		SET ciphertext = EXCLUDED.ciphertext,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE $4 = '' OR secrets.created_by = $4
+		RETURNING (xmax = 0)
+	`
+
+	var created bool
+	err := r.db.QueryRowContext(ctx, query, name, ciphertext, createdBy, owner).Scan(&created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrSecretOwned
+	}
+	return created, err
+}
+
+// GetCiphertext returns the sealed value of a secret and who created it, or sql.ErrNoRows
+func (r *SecretRepository) GetCiphertext(ctx context.Context, name string) ([]byte, string, error) {
+	var ciphertext []byte
+	var createdBy string
+	err := r.db.QueryRowContext(ctx, `SELECT ciphertext, created_by FROM secrets WHERE name = $1`, name).Scan(&ciphertext, &createdBy)
+	return ciphertext, createdBy, err
+}
+
+// List returns the metadata of the owner's secrets, ordered by name
+func (r *SecretRepository) List(ctx context.Context, owner string) ([]Secret, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT name, created_by, created_at, updated_at FROM secrets
+		WHERE $1 = '' OR created_by = $1
+		ORDER BY name`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := []Secret{}
+	for rows.Next() {
+		var s Secret
+		if err := rows.Scan(&s.Name, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
+
+// ListCiphertexts returns the sealed value of every secret by name
+func (r *SecretRepository) ListCiphertexts(ctx context.Context) (map[string][]byte, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT name, ciphertext FROM secrets`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]byte)
+	for rows.Next() {
+		var name string
+		var ciphertext []byte
+		if err := rows.Scan(&name, &ciphertext); err != nil {
+			return nil, err
+		}
+		out[name] = ciphertext
+	}
+	return out, rows.Err()
+}
+
+// Delete removes one of the owner's secrets. Returns false if there was none.
+func (r *SecretRepository) Delete(ctx context.Context, name, owner string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM secrets WHERE name = $1 AND ($2 = '' OR created_by = $2)`, name, owner)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
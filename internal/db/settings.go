@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ============================================================================
+// GLOBAL SETTINGS
+// ============================================================================
+
+// Setting keys
+const (
+	SettingBackupsPaused = "backups_paused"
+)
+
+// Setting is a persisted operator-level switch
+type Setting struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SettingsRepository struct {
+	db *Service
+}
+
+func NewSettingsRepository(db *Service) *SettingsRepository {
+	return &SettingsRepository{db: db}
+}
+
+// Get returns a setting, or sql.ErrNoRows when it was never set
+func (r *SettingsRepository) Get(ctx context.Context, key string) (*Setting, error) {
+	query := `SELECT key, value, updated_by, updated_at FROM settings WHERE key = $1`
+
+	var s Setting
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&s.Key, &s.Value, &s.UpdatedBy, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SettingsRepository) Set(ctx context.Context, key, value, updatedBy string) error {
+	query := `
+		INSERT INTO settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			value = EXCLUDED.value,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.ExecContext(ctx, query, key, value, updatedBy, time.Now().UTC())
+	return err
+}
+
+// BackupPauseState reports whether scheduled backups are globally paused
+type BackupPauseState struct {
+	Paused    bool       `json:"paused"`
+	ChangedBy string     `json:"changed_by,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+// GetBackupPauseState reads the global backup pause flag; unset means not paused
+func (r *SettingsRepository) GetBackupPauseState(ctx context.Context) (BackupPauseState, error) {
+	s, err := r.Get(ctx, SettingBackupsPaused)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BackupPauseState{}, nil
+	}
+	if err != nil {
+		return BackupPauseState{}, err
+	}
+
+	paused, _ := strconv.ParseBool(s.Value)
+	return BackupPauseState{Paused: paused, ChangedBy: s.UpdatedBy, ChangedAt: &s.UpdatedAt}, nil
+}
+
+func (r *SettingsRepository) SetBackupsPaused(ctx context.Context, paused bool, updatedBy string) error {
+	return r.Set(ctx, SettingBackupsPaused, strconv.FormatBool(paused), updatedBy)
+}
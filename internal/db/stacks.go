@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ============================================================================
+// INSTANCE STACKS
+// ============================================================================
+
+// Stack is a set of instances created, and torn down, as a unit
+type Stack struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	NetworkID string        `json:"network_id"`
+	Tags      []string      `json:"tags"`
+	Owner     string        `json:"owner"`
+	Members   []StackMember `json:"members"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// StackMember is an instance of a stack; Position is its place in the boot order
+type StackMember struct {
+	Instance  string   `json:"instance"`
+	Position  int      `json:"position"`
+	DependsOn []string `json:"depends_on"`
+}
+
+type StackRepository struct {
+	db *Service
+}
+
+func NewStackRepository(db *Service) *StackRepository {
+	return &StackRepository{db: db}
+}
+
+// Create inserts the stack and its members in one transaction. The member
+// instances must already exist.
+func (r *StackRepository) Create(ctx context.Context, stack *Stack) error {
+	tags, err := json.Marshal(nonNilStrings(stack.Tags))
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO stacks (name, network_id, tags, owner)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, query, stack.Name, stack.NetworkID, tags, stack.Owner).
+		Scan(&stack.ID, &stack.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, m := range stack.Members {
+		deps, err := json.Marshal(nonNilStrings(m.DependsOn))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO stack_members (stack_id, instance_name, position, depends_on)
+			VALUES ($1, $2, $3, $4)
+		`, stack.ID, m.Instance, m.Position, deps); err != nil {
+			return fmt.Errorf("add member %s: %w", m.Instance, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get returns the stack with its members in boot order, or sql.ErrNoRows
+func (r *StackRepository) Get(ctx context.Context, id string) (*Stack, error) {
+	query := `SELECT id, name, network_id, tags, owner, created_at FROM stacks WHERE id = $1`
+
+	var stack Stack
+	var tags []byte
+	if err := r.db.QueryRowContext(ctx, query, id).
+		Scan(&stack.ID, &stack.Name, &stack.NetworkID, &tags, &stack.Owner, &stack.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(tags, &stack.Tags); err != nil {
+		return nil, fmt.Errorf("decode tags of stack %s: %w", id, err)
+	}
+
+	members, err := r.ListMembers(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	stack.Members = members
+
+	return &stack, nil
+}
+
+// Exists reports whether a stack with this name exists
+func (r *StackRepository) Exists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM stacks WHERE name = $1)`, name).Scan(&exists)
+	return exists, err
+}
+
+// List returns every stack without its members
+func (r *StackRepository) List(ctx context.Context) ([]Stack, error) {
+	query := `SELECT id, name, network_id, tags, owner, created_at FROM stacks ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stacks := []Stack{}
+	for rows.Next() {
+		var stack Stack
+		var tags []byte
+		if err := rows.Scan(&stack.ID, &stack.Name, &stack.NetworkID, &tags, &stack.Owner, &stack.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(tags, &stack.Tags); err != nil {
+			return nil, fmt.Errorf("decode tags of stack %s: %w", stack.ID, err)
+		}
+		stacks = append(stacks, stack)
+	}
+
+	return stacks, rows.Err()
+}
+
+// ListMembers returns the remaining members of a stack in boot order
+func (r *StackRepository) ListMembers(ctx context.Context, stackID string) ([]StackMember, error) {
+	query := `
+		SELECT instance_name, position, depends_on
+		FROM stack_members
+		WHERE stack_id = $1
+		ORDER BY position
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, stackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []StackMember{}
+	for rows.Next() {
+		var m StackMember
+		var deps []byte
+		if err := rows.Scan(&m.Instance, &m.Position, &deps); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(deps, &m.DependsOn); err != nil {
+			return nil, fmt.Errorf("decode dependencies of %s: %w", m.Instance, err)
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *StackRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM stacks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
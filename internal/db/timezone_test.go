@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// withLocalZone runs fn with time.Local set to a non-UTC zone so any
+// accidental use of local time shows up as a non-zero offset.
+func withLocalZone(t *testing.T, fn func()) {
+	t.Helper()
+	orig := time.Local
+	time.Local = time.FixedZone("BRT", -3*60*60)
+	defer func() { time.Local = orig }()
+	fn()
+}
+
+func TestGetNextRunTimeIsUTC(t *testing.T) {
+	withLocalZone(t, func() {
+		next, err := GetNextRunTime("@daily")
+		if err != nil {
+			t.Fatalf("GetNextRunTime failed: %v", err)
+		}
+		if next.Location() != time.UTC {
+			t.Errorf("expected UTC location, got %v", next.Location())
+		}
+		if next.Hour() != 0 || next.Minute() != 0 {
+			t.Errorf("@daily should fire at 00:00 UTC, got %s", next.Format(time.RFC3339))
+		}
+	})
+}
+
+func TestDSNPinsUTCSession(t *testing.T) {
+	cfg := &Config{Host: "localhost", Port: 5432, User: "u", Database: "d", SSLMode: "disable", ConnectTimeout: 5 * time.Second}
+	if !strings.Contains(cfg.DSN(), "timezone=UTC") {
+		t.Errorf("DSN must pin session timezone to UTC: %s", cfg.DSN())
+	}
+}
+
+// fakeRow feeds scanJob the values a driver would return, in column order
+type fakeRow []interface{}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("scan: %d destinations for %d columns", len(dest), len(r))
+	}
+	for i, d := range dest {
+		if scanner, ok := d.(sql.Scanner); ok {
+			if err := scanner.Scan(r[i]); err != nil {
+				return err
+			}
+			continue
+		}
+		if r[i] == nil {
+			continue
+		}
+		target := reflect.ValueOf(d).Elem()
+		target.Set(reflect.ValueOf(r[i]).Convert(target.Type()))
+	}
+	return nil
+}
+
+func TestJobTimestampsSerializeAsUTC(t *testing.T) {
+	withLocalZone(t, func() {
+		// What lib/pq produces for a TIMESTAMPTZ when it does not map the
+		// offset to the session zone: an unnamed +00 zone, serialized "+00:00"
+		created, err := pq.ParseTimestamp(nil, "2026-03-01 12:00:00.5+00")
+		if err != nil {
+			t.Fatal(err)
+		}
+		started, err := pq.ParseTimestamp(nil, "2026-03-01 09:00:01-03")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		job, err := scanJob(fakeRow{
+			"j1", "create_instance", "web-1", "{}", "RUNNING", nil,
+			created, started, nil,
+			int64(1), "alice", int64(0), "", "", "", "",
+		})
+		if err != nil {
+			t.Fatalf("scanJob failed: %v", err)
+		}
+		if !job.CreatedAt.Equal(created) || !job.StartedAt.Equal(started) {
+			t.Errorf("timestamps changed instant: %v / %v", job.CreatedAt, job.StartedAt)
+		}
+
+		data, err := json.Marshal(job)
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unmarshal failed: %v", err)
+		}
+
+		for _, field := range []string{"created_at", "started_at"} {
+			ts, _ := decoded[field].(string)
+			if !strings.HasSuffix(ts, "Z") {
+				t.Errorf("%s should be RFC3339 UTC with Z suffix, got %q", field, ts)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ============================================================================
+// USAGE (chargeback)
+// ============================================================================
+
+// MetricsSampleInterval is how often the historical collector samples each
+// running instance. Usage accounting counts every sample as one interval.
+const MetricsSampleInterval = time.Minute
+
+// UsageTotals is the resource consumption of one instance over a window.
+//
+// Counting rules:
+//   - only running time is counted: the collector samples running instances
+//     once per MetricsSampleInterval, so each sample (raw, or each sample
+//     folded into an hourly/daily rollup) is one interval of running time.
+//     Stopped periods have no samples and cost nothing, disk included;
+//   - partial hours are fractional (90 samples = 1.5 hours), never rounded up;
+//   - disk GB-hours use the disk usage measured in each sample;
+//   - network bytes are the growth of the interface counters between
+//     consecutive samples; a counter reset (restart) counts the new value.
+//
+// At the window edges raw samples are filtered by timestamp and rollups by
+// the start of their bucket, so rolled-up periods have hour/day granularity.
+type UsageTotals struct {
+	InstanceName   string  `json:"instance"`
+	Owner          string  `json:"owner"`
+	Samples        int64   `json:"samples"`
+	RunningHours   float64 `json:"running_hours"`
+	DiskGBHours    float64 `json:"disk_gb_hours"`
+	NetworkRxBytes int64   `json:"network_rx_bytes"`
+	NetworkTxBytes int64   `json:"network_tx_bytes"`
+}
+
+// Usage aggregates the metrics of every instance (or only instanceName when
+// not empty) in [from, to). Deleted instances still appear, with an empty owner.
+func (r *MetricsRepository) Usage(ctx context.Context, from, to time.Time, instanceName string) ([]UsageTotals, error) {
+	query := `
+		WITH points AS (
+			SELECT instance_name, timestamp AS ts, 1::bigint AS n,
+			       COALESCE(disk_usage, 0)::float8 AS disk_sum,
+			       network_rx_bytes AS rx, network_tx_bytes AS tx
+			FROM metrics
+			WHERE timestamp >= $1 AND timestamp < $2 AND ($3 = '' OR instance_name = $3)
+			UNION ALL
+			SELECT instance_name, bucket, sample_count, avg_disk * sample_count, network_rx_bytes, network_tx_bytes
+			FROM metrics_hourly
+			WHERE bucket >= $1 AND bucket < $2 AND ($3 = '' OR instance_name = $3)
+			UNION ALL
+			SELECT instance_name, bucket, sample_count, avg_disk * sample_count, network_rx_bytes, network_tx_bytes
+			FROM metrics_daily
+			WHERE bucket >= $1 AND bucket < $2 AND ($3 = '' OR instance_name = $3)
+		), deltas AS (
+			SELECT instance_name, n, disk_sum, rx, tx,
+			       rx - LAG(rx) OVER w AS drx,
+			       tx - LAG(tx) OVER w AS dtx
+			FROM points
+			WINDOW w AS (PARTITION BY instance_name ORDER BY ts)
+		)
+		SELECT d.instance_name, COALESCE(MAX(i.owner), ''),
+		       SUM(d.n), SUM(d.disk_sum),
+		       SUM(CASE WHEN d.drx < 0 THEN d.rx ELSE COALESCE(d.drx, 0) END),
+		       SUM(CASE WHEN d.dtx < 0 THEN d.tx ELSE COALESCE(d.dtx, 0) END)
+		FROM deltas d
+		LEFT JOIN instances i ON i.name = d.instance_name
+		GROUP BY d.instance_name
+		ORDER BY d.instance_name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from.UTC(), to.UTC(), instanceName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	intervalHours := MetricsSampleInterval.Hours()
+	usage := []UsageTotals{}
+	for rows.Next() {
+		var u UsageTotals
+		var diskByteSamples float64
+		if err := rows.Scan(&u.InstanceName, &u.Owner, &u.Samples, &diskByteSamples, &u.NetworkRxBytes, &u.NetworkTxBytes); err != nil {
+			return nil, err
+		}
+		u.RunningHours = float64(u.Samples) * intervalHours
+		u.DiskGBHours = diskByteSamples / (1 << 30) * intervalHours
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
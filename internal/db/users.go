@@ -8,12 +8,15 @@ import (
 
 // User represents a system user
 type User struct {
-	ID           int       `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Never return hash in JSON
-	Role         string    `json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 int       `json:"id"`
+	Email              string    `json:"email"`
+	PasswordHash       string    `json:"-"` // Never return hash in JSON
+	Role               string    `json:"role"`
+	Plan               string    `json:"plan"`
+	PasswordChangedAt  time.Time `json:"password_changed_at"`
+	MustChangePassword bool      `json:"must_change_password"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // UserRepository handles user persistence
@@ -26,41 +29,82 @@ func NewUserRepository(service *Service) *UserRepository {
 	return &UserRepository{service: service}
 }
 
+const userColumns = `id, email, password_hash, role, plan, password_changed_at, must_change_password, created_at, updated_at`
+
+func scanUser(row *sql.Row) (*User, error) {
+	var user User
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.Plan,
+		&user.PasswordChangedAt, &user.MustChangePassword,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 // Create creates a new user via DB Transaction
 func (r *UserRepository) Create(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (email, password_hash, role)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (email, password_hash, role, plan, must_change_password)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), 'free'), $5)
+		RETURNING id, plan, password_changed_at, created_at, updated_at
 	`
 
-	err := r.service.QueryRowContext(ctx, query, user.Email, user.PasswordHash, user.Role).
-		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := r.service.QueryRowContext(ctx, query, user.Email, user.PasswordHash, user.Role, user.Plan, user.MustChangePassword).
+		Scan(&user.ID, &user.Plan, &user.PasswordChangedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	return err
 }
 
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+	return scanUser(r.service.QueryRowContext(ctx, query, email))
+}
+
+// GetByID retrieves a user by id
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.service.QueryRowContext(ctx, query, id))
+}
+
+// UpdatePassword stores a new password hash, resets the rotation clock and
+// clears the forced-change flag.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
 	query := `
-		SELECT id, email, password_hash, role, created_at, updated_at
-		FROM users
-		WHERE email = $1
+		UPDATE users
+		SET password_hash = $1, password_changed_at = NOW(), must_change_password = FALSE, updated_at = NOW()
+		WHERE id = $2
 	`
 
-	var user User
-	err := r.service.QueryRowContext(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil // Not found
+	result, err := r.service.ExecContext(ctx, query, passwordHash, id)
+	if err != nil {
+		return err
 	}
+
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	return &user, nil
+// UpdatePasswordHash replaces the stored hash without touching the rotation
+// clock. Used to transparently upgrade hashes when the algorithm changes.
+func (r *UserRepository) UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.service.ExecContext(ctx, query, passwordHash, id)
+	return err
 }
 
 // Count returns the total number of users
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// VOLATILE INSTANCE STATE
+// ============================================================================
+
+// VolatileState is what the sync observes on the provider: status and IPs.
+// It lives in instance_volatile, apart from the limits JSON, so that limits
+// only holds durable configuration and is not rewritten on every sync cycle.
+type VolatileState struct {
+	Status    string    `json:"status"`
+	IPv4      string    `json:"ipv4,omitempty"`
+	IPv6      string    `json:"ipv6,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsVolatileLimitKey reports whether a limits key is observed state rather
+// than configuration: "status" and LXD's "volatile.*" keys.
+func IsVolatileLimitKey(key string) bool {
+	return key == "status" || strings.HasPrefix(key, "volatile.")
+}
+
+// DurableLimits returns a copy of limits without volatile keys
+func DurableLimits(limits map[string]string) map[string]string {
+	out := make(map[string]string, len(limits))
+	for k, v := range limits {
+		if !IsVolatileLimitKey(k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// UpdateVolatile records the observed status and IPs. The row is only
+// written when something changed, so an idle sync does not touch the table.
+func (r *InstanceRepository) UpdateVolatile(ctx context.Context, name string, state VolatileState) error {
+	query := `
+		INSERT INTO instance_volatile (instance_name, status, ipv4, ipv6, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (instance_name) DO UPDATE
+		SET status = EXCLUDED.status,
+		    ipv4 = EXCLUDED.ipv4,
+		    ipv6 = EXCLUDED.ipv6,
+		    updated_at = EXCLUDED.updated_at
+		WHERE (instance_volatile.status, instance_volatile.ipv4, instance_volatile.ipv6)
+		      IS DISTINCT FROM (EXCLUDED.status, EXCLUDED.ipv4, EXCLUDED.ipv6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, name, state.Status, state.IPv4, state.IPv6)
+	return err
+}
+
+// UpdateVolatileStatus records only the observed status, keeping the last known IPs
+func (r *InstanceRepository) UpdateVolatileStatus(ctx context.Context, name string, status string) error {
+	query := `
+		INSERT INTO instance_volatile (instance_name, status, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (instance_name) DO UPDATE
+		SET status = EXCLUDED.status,
+		    updated_at = EXCLUDED.updated_at
+		WHERE instance_volatile.status IS DISTINCT FROM EXCLUDED.status
+	`
+
+	_, err := r.db.ExecContext(ctx, query, name, status)
+	return err
+}
+
+// ListVolatile returns the observed state of every instance that has one
+func (r *InstanceRepository) ListVolatile(ctx context.Context) (map[string]VolatileState, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT instance_name, status, ipv4, ipv6, updated_at FROM instance_volatile`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]VolatileState)
+	for rows.Next() {
+		var name string
+		var state VolatileState
+		if err := rows.Scan(&name, &state.Status, &state.IPv4, &state.IPv6, &state.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out[name] = state
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,21 @@
+package db
+
+import "testing"
+
+func TestDurableLimitsDropsVolatileKeys(t *testing.T) {
+	limits := map[string]string{
+		"limits.cpu":           "2",
+		"limits.memory":        "1GB",
+		"status":               "RUNNING",
+		"volatile.ipv4":        "10.0.0.5",
+		"volatile.eth0.hwaddr": "00:16:3e:aa:bb:cc",
+	}
+
+	got := DurableLimits(limits)
+	if len(got) != 2 || got["limits.cpu"] != "2" || got["limits.memory"] != "1GB" {
+		t.Errorf("DurableLimits = %v, want only limits.cpu and limits.memory", got)
+	}
+	if len(limits) != 5 {
+		t.Error("DurableLimits must not modify its input")
+	}
+}
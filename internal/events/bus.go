@@ -1,32 +1,143 @@
 package events
 
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
 // EventType define os tipos de eventos do sistema.
 type EventType string
 
 const (
 	JobUpdate   EventType = "job_update"
 	StateChange EventType = "state_change"
+	// InstanceLifecycle vem do stream de eventos do LXD (inclusive mudanças feitas fora do Axion)
+	InstanceLifecycle EventType = "instance_lifecycle"
+	// ResourceAlert é publicado pelo coletor de métricas quando uma instância cruza um limite de uso
+	ResourceAlert EventType = "resource_alert"
+)
+
+// Estados de um ResourceAlert: disparado ao cruzar o limite para cima, resolvido ao voltar abaixo.
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
 )
 
+// ResourceAlertPayload é o payload de um evento ResourceAlert.
+// Value e Threshold são percentuais (0-100) do limite da instância.
+type ResourceAlertPayload struct {
+	Instance  string  `json:"instance"`
+	Metric    string  `json:"metric"` // cpu, memory ou disk
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	State     string  `json:"state"`
+}
+
 // Event representa uma mensagem no barramento de eventos.
 type Event struct {
-	Type      EventType   `json:"type"`
-	JobID     string      `json:"job_id,omitempty"`
-	Target    string      `json:"target,omitempty"`
-	Payload   interface{} `json:"payload"`
-	Timestamp int64       `json:"timestamp"`
+	Type      EventType     `json:"type"`
+	JobID     string        `json:"job_id,omitempty"`
+	Target    string        `json:"target,omitempty"`
+	Instance  *InstanceMeta `json:"instance,omitempty"` // Preenchido pelo Enricher a partir de Target
+	Payload   interface{}   `json:"payload"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// MaxEventTags limita as tags copiadas para cada evento.
+const MaxEventTags = 8
+
+// InstanceMeta resume a instância alvo de um evento, para que consumidores do
+// WebSocket não precisem buscá-la a cada evento.
+type InstanceMeta struct {
+	Type  string   `json:"type,omitempty"`
+	Node  string   `json:"node,omitempty"`
+	Owner string   `json:"owner,omitempty"`
+	Tags  []string `json:"tags,omitempty"` // No máximo MaxEventTags
+}
+
+// Enricher devolve os metadados da instância de nome target, ou nil se não
+// houver. É chamado em todo Publish, no goroutine de quem publica: não pode
+// bloquear (nada de banco ou LXD), só responder de cache.
+type Enricher func(target string) *InstanceMeta
+
+var enricher atomic.Pointer[Enricher]
+
+// SetEnricher registra a função que anota os eventos com Target; nil desativa.
+func SetEnricher(e Enricher) {
+	if e == nil {
+		enricher.Store(nil)
+		return
+	}
+	enricher.Store(&e)
 }
 
 // GlobalBus é o canal onde todos os eventos são publicados.
 // O buffer de 1000 evita bloqueios se o consumidor (WebSocket) for lento.
 var GlobalBus = make(chan Event, 1000)
 
+var dropped atomic.Uint64
+
 // Publish envia um evento para o barramento.
 func Publish(evt Event) {
-	// Non-blocking publish para não travar o emissor se o bus estiver cheio
+	if evt.Instance == nil && evt.Target != "" {
+		if e := enricher.Load(); e != nil {
+			evt.Instance = (*e)(evt.Target)
+		}
+	}
+
+	// Non-blocking publish para não travar o emissor se o bus estiver cheio.
+	// A política por cliente (descartar telemetria antes de job_update) fica no broadcaster.
 	select {
 	case GlobalBus <- evt:
 	default:
-		// Logar drop de evento em produção
+		if dropped.Add(1)%100 == 1 {
+			log.Printf("[Events] Bus cheio, evento %s descartado (total: %d)", evt.Type, dropped.Load())
+		}
+	}
+
+	subsMu.RLock()
+	defer subsMu.RUnlock()
+	for sub := range subs {
+		if !sub.match(evt) {
+			continue
+		}
+		// Assinante lento perde o evento; quem assina relê o estado de qualquer forma
+		select {
+		case sub.ch <- evt:
+		default:
+		}
 	}
 }
+
+type subscriber struct {
+	ch    chan Event
+	match func(Event) bool
+}
+
+var (
+	subsMu sync.RWMutex
+	subs   = map[*subscriber]struct{}{}
+)
+
+// Subscribe entrega cópias dos eventos publicados que passam em match, sem
+// competir com o consumidor do GlobalBus. A função devolvida cancela a
+// assinatura e deve sempre ser chamada.
+func Subscribe(match func(Event) bool, buffer int) (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, buffer), match: match}
+
+	subsMu.Lock()
+	subs[sub] = struct{}{}
+	subsMu.Unlock()
+
+	return sub.ch, func() {
+		subsMu.Lock()
+		delete(subs, sub)
+		subsMu.Unlock()
+	}
+}
+
+// Dropped retorna quantos eventos foram descartados por bus cheio.
+func Dropped() uint64 {
+	return dropped.Load()
+}
@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"math"
+	"runtime"
+	"time"
+
+	"aexon/internal/events"
+	"aexon/internal/provider/lxc"
+	"aexon/internal/utils"
+)
+
+// Metric names used in ResourceAlert events
+const (
+	AlertMetricCPU    = "cpu"
+	AlertMetricMemory = "memory"
+	AlertMetricDisk   = "disk"
+)
+
+// AlertThresholds are usage percentages of an instance's own limits above
+// which a ResourceAlert is published. A zero threshold disables that metric.
+type AlertThresholds struct {
+	CPUPercent    float64
+	MemoryPercent float64
+	DiskPercent   float64
+}
+
+// DefaultAlertThresholds alerts at 90% of every limit
+func DefaultAlertThresholds() AlertThresholds {
+	return AlertThresholds{CPUPercent: 90, MemoryPercent: 90, DiskPercent: 90}
+}
+
+type cpuSample struct {
+	seconds int64
+	at      time.Time
+}
+
+// alertTracker turns metric samples into ResourceAlert events. Alerts are
+// edge-triggered: one "firing" event when a metric crosses its threshold and
+// one "resolved" event when it drops back below, not one per sample.
+type alertTracker struct {
+	thresholds AlertThresholds
+	lastCPU    map[string]cpuSample
+	firing     map[alertKey]bool
+}
+
+type alertKey struct {
+	instance string
+	metric   string
+}
+
+func newAlertTracker(thresholds AlertThresholds) *alertTracker {
+	return &alertTracker{
+		thresholds: thresholds,
+		lastCPU:    make(map[string]cpuSample),
+		firing:     make(map[alertKey]bool),
+	}
+}
+
+// observe evaluates one sampling round and returns the events to publish.
+// Instances missing from the round (stopped or deleted) have their state
+// dropped and any firing alert resolved.
+func (t *alertTracker) observe(instances []lxc.InstanceMetric, now time.Time) []events.Event {
+	var out []events.Event
+	seen := make(map[string]bool, len(instances))
+
+	for _, inst := range instances {
+		seen[inst.Name] = true
+
+		if cpu, ok := t.cpuPercent(inst, now); ok {
+			out = t.check(out, inst.Name, AlertMetricCPU, cpu, t.thresholds.CPUPercent, now)
+		}
+		if limit := utils.ParseMemoryToBytes(inst.Config["limits.memory"]); limit > 0 {
+			out = t.check(out, inst.Name, AlertMetricMemory, percentOf(inst.MemoryUsageBytes, limit), t.thresholds.MemoryPercent, now)
+		}
+		if limit := utils.ParseMemoryToBytes(inst.Devices["root"]["size"]); limit > 0 {
+			out = t.check(out, inst.Name, AlertMetricDisk, percentOf(inst.DiskUsageBytes, limit), t.thresholds.DiskPercent, now)
+		}
+	}
+
+	for name := range t.lastCPU {
+		if !seen[name] {
+			delete(t.lastCPU, name)
+		}
+	}
+	for key := range t.firing {
+		if !seen[key.instance] {
+			out = append(out, alertEvent(key.instance, key.metric, 0, 0, events.AlertResolved, now))
+			delete(t.firing, key)
+		}
+	}
+
+	return out
+}
+
+func (t *alertTracker) cpuPercent(inst lxc.InstanceMetric, now time.Time) (float64, bool) {
+	return cpuPercentSince(t.lastCPU, inst, now)
+}
+
+// cpuPercentSince derives usage from the cumulative CPU seconds of two
+// consecutive samples, relative to limits.cpu (or every host core when
+// unlimited), and records inst as the latest sample in last.
+func cpuPercentSince(last map[string]cpuSample, inst lxc.InstanceMetric, now time.Time) (float64, bool) {
+	prev, ok := last[inst.Name]
+	last[inst.Name] = cpuSample{seconds: inst.CPUUsageSeconds, at: now}
+	if !ok || inst.CPUUsageSeconds < prev.seconds {
+		return 0, false
+	}
+
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	cores := utils.ParseCpuCores(inst.Config["limits.cpu"])
+	if cores <= 0 {
+		cores = runtime.NumCPU()
+	}
+	return float64(inst.CPUUsageSeconds-prev.seconds) / (elapsed * float64(cores)) * 100, true
+}
+
+func (t *alertTracker) check(out []events.Event, instance, metric string, value, threshold float64, now time.Time) []events.Event {
+	if threshold <= 0 {
+		return out
+	}
+
+	key := alertKey{instance: instance, metric: metric}
+	switch {
+	case value >= threshold && !t.firing[key]:
+		t.firing[key] = true
+		out = append(out, alertEvent(instance, metric, value, threshold, events.AlertFiring, now))
+	case value < threshold && t.firing[key]:
+		delete(t.firing, key)
+		out = append(out, alertEvent(instance, metric, value, threshold, events.AlertResolved, now))
+	}
+	return out
+}
+
+func alertEvent(instance, metric string, value, threshold float64, state string, now time.Time) events.Event {
+	return events.Event{
+		Type:   events.ResourceAlert,
+		Target: instance,
+		Payload: events.ResourceAlertPayload{
+			Instance:  instance,
+			Metric:    metric,
+			Value:     math.Round(value*10) / 10,
+			Threshold: threshold,
+			State:     state,
+		},
+		Timestamp: now.Unix(),
+	}
+}
+
+func percentOf(used, limit int64) float64 {
+	return float64(used) / float64(limit) * 100
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"aexon/internal/events"
+	"aexon/internal/provider/lxc"
+)
+
+func alertInstance(memUsed int64) lxc.InstanceMetric {
+	return lxc.InstanceMetric{
+		Name:             "web-1",
+		MemoryUsageBytes: memUsed,
+		Config:           map[string]string{"limits.memory": "1GB", "limits.cpu": "2"},
+	}
+}
+
+func TestAlertTrackerEdgeTriggered(t *testing.T) {
+	tracker := newAlertTracker(AlertThresholds{MemoryPercent: 80})
+	now := time.Now()
+	gb := int64(1024 * 1024 * 1024)
+
+	if evts := tracker.observe([]lxc.InstanceMetric{alertInstance(gb / 2)}, now); len(evts) != 0 {
+		t.Fatalf("expected no alert below threshold, got %v", evts)
+	}
+
+	evts := tracker.observe([]lxc.InstanceMetric{alertInstance(gb * 9 / 10)}, now)
+	if len(evts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(evts))
+	}
+	payload := evts[0].Payload.(events.ResourceAlertPayload)
+	if evts[0].Type != events.ResourceAlert || payload.Metric != AlertMetricMemory || payload.State != events.AlertFiring {
+		t.Errorf("unexpected event: %+v", evts[0])
+	}
+	if payload.Value != 90 || payload.Threshold != 80 {
+		t.Errorf("value/threshold = %v/%v, want 90/80", payload.Value, payload.Threshold)
+	}
+
+	// Still above: no repeated event
+	if evts := tracker.observe([]lxc.InstanceMetric{alertInstance(gb * 95 / 100)}, now); len(evts) != 0 {
+		t.Errorf("expected no repeated alert, got %v", evts)
+	}
+
+	evts = tracker.observe([]lxc.InstanceMetric{alertInstance(gb / 2)}, now)
+	if len(evts) != 1 || evts[0].Payload.(events.ResourceAlertPayload).State != events.AlertResolved {
+		t.Errorf("expected a resolved event, got %v", evts)
+	}
+}
+
+func TestAlertTrackerCPUFromDeltas(t *testing.T) {
+	tracker := newAlertTracker(AlertThresholds{CPUPercent: 90})
+	start := time.Now()
+
+	inst := alertInstance(0)
+	inst.CPUUsageSeconds = 1000
+	if evts := tracker.observe([]lxc.InstanceMetric{inst}, start); len(evts) != 0 {
+		t.Fatalf("first sample has no delta, got %v", evts)
+	}
+
+	// 2 cores fully busy for 60s = 120 CPU seconds
+	inst.CPUUsageSeconds = 1120
+	evts := tracker.observe([]lxc.InstanceMetric{inst}, start.Add(time.Minute))
+	if len(evts) != 1 || evts[0].Payload.(events.ResourceAlertPayload).Metric != AlertMetricCPU {
+		t.Fatalf("expected a cpu alert, got %v", evts)
+	}
+
+	// Instance stopped: the firing alert is resolved
+	evts = tracker.observe(nil, start.Add(2*time.Minute))
+	if len(evts) != 1 || evts[0].Payload.(events.ResourceAlertPayload).State != events.AlertResolved {
+		t.Errorf("expected a resolved event for the stopped instance, got %v", evts)
+	}
+}
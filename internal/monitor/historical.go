@@ -1,37 +1,73 @@
 package monitor
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
 	"log"
 	"strings"
 	"time"
 
+	"aexon/internal/db"
+	"aexon/internal/events"
 	"aexon/internal/provider/lxc"
 )
 
-// StartHistoricalCollector starts a ticker to collect and store instance metrics periodically.
-func StartHistoricalCollector(dbConn *sql.DB, lxd *lxc.InstanceService) {
+// StartHistoricalCollector collects and stores instance metrics periodically
+// until ctx is canceled. Every sample is also checked against thresholds,
+// publishing ResourceAlert events on the event bus when an instance crosses one.
+func StartHistoricalCollector(ctx context.Context, repo *db.MetricsRepository, lxd *lxc.InstanceService, thresholds AlertThresholds) {
 	log.Println("[Metrics] Starting historical metrics collector...")
 
-	metricsTicker := time.NewTicker(1 * time.Minute)
+	c := &historicalCollector{
+		list:   lxd.ListInstances,
+		store:  repo.InsertBatch,
+		rollup: repo.RollupMetrics,
+		alerts: newAlertTracker(thresholds),
+	}
+
+	metricsTicker := time.NewTicker(db.MetricsSampleInterval)
 	defer metricsTicker.Stop()
 
-	retentionTicker := time.NewTicker(1 * time.Hour)
-	defer retentionTicker.Stop()
+	rollupTicker := time.NewTicker(MetricsRollupInterval)
+	defer rollupTicker.Stop()
+
+	c.run(ctx, metricsTicker.C, rollupTicker.C)
+}
+
+// MetricsRollupInterval is how often raw samples past db.MetricsRawRetention
+// are downsampled; hourly keeps each run to about one bucket per instance
+const MetricsRollupInterval = time.Hour
 
+// run collects on every samples tick and rolls old metrics up on every
+// rollups tick until ctx is canceled.
+func (c *historicalCollector) run(ctx context.Context, samples, rollups <-chan time.Time) {
 	for {
 		select {
-		case <-metricsTicker.C:
-			collectAndStoreMetrics(dbConn, lxd)
-		case <-retentionTicker.C:
-			applyRetentionPolicy(dbConn)
+		case <-samples:
+			c.collect(ctx)
+		case now := <-rollups:
+			if err := c.rollup(ctx, now); err != nil {
+				log.Printf("[Metrics] ERROR: Failed to roll up metrics: %v", err)
+			}
+		case <-ctx.Done():
+			log.Println("[Metrics] Historical collector stopped")
+			return
 		}
 	}
 }
 
-func collectAndStoreMetrics(dbConn *sql.DB, lxd *lxc.InstanceService) {
-	instances, err := lxd.ListInstances()
+// historicalCollector holds what the collector loop needs; list, store and
+// rollup are the LXD and database calls, swapped out in tests.
+type historicalCollector struct {
+	list   InstanceLister
+	store  func(ctx context.Context, samples []db.Metric) error
+	rollup func(ctx context.Context, now time.Time) error
+	alerts *alertTracker
+}
+
+// collect samples the running instances, publishes alert transitions and
+// writes the whole cycle with a single batch insert.
+func (c *historicalCollector) collect(ctx context.Context) {
+	instances, err := c.list()
 	if err != nil {
 		log.Printf("[Metrics] ERROR: Failed to list instances for metrics collection: %v", err)
 		return
@@ -39,45 +75,39 @@ func collectAndStoreMetrics(dbConn *sql.DB, lxd *lxc.InstanceService) {
 
 	runningInstances := []lxc.InstanceMetric{}
 	for _, inst := range instances {
-		if inst.Status == "Running" {
+		// ListInstances normaliza o status para maiúsculas
+		if strings.EqualFold(inst.Status, "Running") {
 			runningInstances = append(runningInstances, inst)
 		}
 	}
 
+	for _, evt := range c.alerts.observe(runningInstances, time.Now()) {
+		events.Publish(evt)
+	}
+
 	if len(runningInstances) == 0 {
 		return
 	}
 
-	// Bulk insert implementation
-	valueStrings := make([]string, 0, len(runningInstances))
-	valueArgs := make([]interface{}, 0, len(runningInstances)*4)
-	i := 1
+	// One statement for the whole cycle instead of an INSERT per instance
+	samples := make([]db.Metric, 0, len(runningInstances))
 	for _, inst := range runningInstances {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d)", i, i+1, i+2, i+3))
 		// Note: CPU usage is cumulative seconds. To get a percentage, you'd need to compare deltas.
 		// For simplicity here, we're storing a raw value that could represent load or usage over time.
 		// A more advanced implementation would calculate the delta since the last collection.
-		valueArgs = append(valueArgs, inst.Name, float64(inst.CPUUsageSeconds), inst.MemoryUsageBytes, inst.DiskUsageBytes)
-		i += 4
+		samples = append(samples, db.Metric{
+			InstanceName:   inst.Name,
+			CPUPercent:     float64(inst.CPUUsageSeconds),
+			MemoryUsage:    inst.MemoryUsageBytes,
+			DiskUsage:      inst.DiskUsageBytes,
+			NetworkRxBytes: inst.NetworkUsageRxBytes,
+			NetworkTxBytes: inst.NetworkUsageTxBytes,
+		})
 	}
 
-	stmt := fmt.Sprintf("INSERT INTO metrics (instance_name, cpu_percent, memory_usage, disk_usage) VALUES %s",
-		strings.Join(valueStrings, ","))
-
-	_, err = dbConn.Exec(stmt, valueArgs...)
-	if err != nil {
+	if err := c.store(ctx, samples); err != nil {
 		log.Printf("[Metrics] ERROR: Failed to bulk insert metrics: %v", err)
 	} else {
 		log.Printf("[Metrics] Stored metrics for %d running instances.", len(runningInstances))
 	}
 }
-
-func applyRetentionPolicy(dbConn *sql.DB) {
-	log.Println("[Metrics] Applying retention policy...")
-	_, err := dbConn.Exec("DELETE FROM metrics WHERE timestamp < NOW() - INTERVAL '30 days'")
-	if err != nil {
-		log.Printf("[Metrics] ERROR: Failed to apply retention policy: %v", err)
-	} else {
-		log.Println("[Metrics] Retention policy applied successfully.")
-	}
-}
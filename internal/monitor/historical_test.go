@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+)
+
+func TestHistoricalCollectorWritesOneBatchPerCycle(t *testing.T) {
+	var batches [][]db.Metric
+	c := &historicalCollector{
+		list: func() ([]lxc.InstanceMetric, error) {
+			return []lxc.InstanceMetric{
+				{Name: "web-1", Status: "RUNNING", MemoryUsageBytes: 512},
+				{Name: "web-2", Status: "STOPPED"},
+				{Name: "db-1", Status: "RUNNING", DiskUsageBytes: 2048},
+			}, nil
+		},
+		store: func(_ context.Context, samples []db.Metric) error {
+			batches = append(batches, samples)
+			return nil
+		},
+		alerts: newAlertTracker(AlertThresholds{}),
+	}
+
+	c.collect(context.Background())
+	if len(batches) != 1 {
+		t.Fatalf("store called %d times, want one batch", len(batches))
+	}
+	if len(batches[0]) != 2 || batches[0][0].InstanceName != "web-1" || batches[0][1].DiskUsage != 2048 {
+		t.Errorf("batch = %+v, want the two running instances", batches[0])
+	}
+
+	c.list = func() ([]lxc.InstanceMetric, error) {
+		return []lxc.InstanceMetric{{Name: "web-2", Status: "STOPPED"}}, nil
+	}
+	c.collect(context.Background())
+	if len(batches) != 1 {
+		t.Errorf("a cycle without running instances should not write, got %d batches", len(batches))
+	}
+}
+
+func TestHistoricalCollectorRecordsNetworkCounters(t *testing.T) {
+	var stored []db.Metric
+	c := &historicalCollector{
+		list: func() ([]lxc.InstanceMetric, error) {
+			return []lxc.InstanceMetric{{Name: "web-1", Status: "RUNNING", NetworkUsageRxBytes: 4096, NetworkUsageTxBytes: 1024}}, nil
+		},
+		store: func(_ context.Context, samples []db.Metric) error {
+			stored = samples
+			return nil
+		},
+		alerts: newAlertTracker(AlertThresholds{}),
+	}
+
+	c.collect(context.Background())
+	if len(stored) != 1 || stored[0].NetworkRxBytes != 4096 || stored[0].NetworkTxBytes != 1024 {
+		t.Errorf("stored = %+v, want the instance's rx/tx counters", stored)
+	}
+}
+
+func TestHistoricalCollectorRollsUpOnTick(t *testing.T) {
+	rolled := make(chan time.Time, 1)
+	c := &historicalCollector{
+		rollup: func(_ context.Context, now time.Time) error {
+			rolled <- now
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rollups := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		c.run(ctx, nil, rollups)
+		close(done)
+	}()
+
+	tick := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	rollups <- tick
+	select {
+	case got := <-rolled:
+		if !got.Equal(tick) {
+			t.Errorf("rollup at %s, want the tick time %s", got, tick)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rollup tick did not roll metrics up")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector did not stop on cancel")
+	}
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusSnapshotTTL is how long scrapes reuse the last snapshot before
+// LXD and the jobs table are read again
+const PrometheusSnapshotTTL = 5 * time.Second
+
+// prometheusRefreshWait bounds how long a scrape waits for a refresh before
+// it is answered with the previous snapshot
+const prometheusRefreshWait = 2 * time.Second
+
+var (
+	promCPUDesc = prometheus.NewDesc("axeon_instance_cpu_percent",
+		"CPU usage of the instance between the last two snapshots, relative to limits.cpu (or every host core).",
+		[]string{"instance"}, nil)
+	promMemoryDesc = prometheus.NewDesc("axeon_instance_memory_bytes",
+		"Memory used by the instance.", []string{"instance"}, nil)
+	promDiskDesc = prometheus.NewDesc("axeon_instance_disk_bytes",
+		"Root disk used by the instance.", []string{"instance"}, nil)
+	promJobsDesc = prometheus.NewDesc("axeon_jobs",
+		"Jobs in the jobs table by type and status (finished jobs leave it with the job retention).",
+		[]string{"type", "status"}, nil)
+)
+
+// InstanceLister returns the live instances to export
+type InstanceLister func() ([]lxc.InstanceMetric, error)
+
+// JobCounter returns the job totals to export
+type JobCounter func(ctx context.Context) ([]db.JobCount, error)
+
+// PrometheusCollector exports instance gauges and job counters. A scrape never
+// waits on a slow LXD for more than prometheusRefreshWait: the refresh keeps
+// running in the background and the scrape gets the last good snapshot.
+type PrometheusCollector struct {
+	listInstances InstanceLister
+	countJobs     JobCounter
+	ttl           time.Duration
+	wait          time.Duration
+
+	mu         sync.Mutex
+	snapshot   promSnapshot
+	refreshing chan struct{} // closed when the running refresh ends; nil when idle
+
+	// lastCPU is only touched by refresh, which never runs concurrently
+	lastCPU map[string]cpuSample
+}
+
+type promSnapshot struct {
+	at        time.Time
+	instances []promInstance
+	jobs      []db.JobCount
+}
+
+type promInstance struct {
+	name   string
+	cpu    float64
+	hasCPU bool // false until two samples exist
+	memory int64
+	disk   int64
+}
+
+// NewPrometheusCollector builds the collector; listInstances may be nil when
+// LXD is not available, in which case only job counters are exported.
+func NewPrometheusCollector(listInstances InstanceLister, countJobs JobCounter) *PrometheusCollector {
+	return &PrometheusCollector{
+		listInstances: listInstances,
+		countJobs:     countJobs,
+		ttl:           PrometheusSnapshotTTL,
+		wait:          prometheusRefreshWait,
+		lastCPU:       make(map[string]cpuSample),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- promCPUDesc
+	ch <- promMemoryDesc
+	ch <- promDiskDesc
+	ch <- promJobsDesc
+}
+
+// Collect implements prometheus.Collector
+func (p *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	snap := p.current()
+	for _, inst := range snap.instances {
+		if inst.hasCPU {
+			ch <- prometheus.MustNewConstMetric(promCPUDesc, prometheus.GaugeValue, inst.cpu, inst.name)
+		}
+		ch <- prometheus.MustNewConstMetric(promMemoryDesc, prometheus.GaugeValue, float64(inst.memory), inst.name)
+		ch <- prometheus.MustNewConstMetric(promDiskDesc, prometheus.GaugeValue, float64(inst.disk), inst.name)
+	}
+	for _, jc := range snap.jobs {
+		ch <- prometheus.MustNewConstMetric(promJobsDesc, prometheus.GaugeValue, float64(jc.Count), jc.Type, jc.Status)
+	}
+}
+
+// current returns the cached snapshot while it is younger than ttl. Otherwise
+// it starts a refresh (one at a time) and waits for it up to wait, falling
+// back to the last good snapshot.
+func (p *PrometheusCollector) current() promSnapshot {
+	p.mu.Lock()
+	if !p.snapshot.at.IsZero() && time.Since(p.snapshot.at) < p.ttl {
+		defer p.mu.Unlock()
+		return p.snapshot
+	}
+	done := p.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		p.refreshing = done
+		go p.refresh(done)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(p.wait):
+		log.Printf("[Metrics] Prometheus refresh slower than %s, serving the previous snapshot", p.wait)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshot
+}
+
+// refresh reads LXD and the jobs table. A source that fails keeps its values
+// from the previous snapshot.
+func (p *PrometheusCollector) refresh(done chan struct{}) {
+	defer func() {
+		p.mu.Lock()
+		p.refreshing = nil
+		p.mu.Unlock()
+		close(done)
+	}()
+
+	p.mu.Lock()
+	next := p.snapshot
+	p.mu.Unlock()
+	next.at = time.Now()
+
+	if p.listInstances != nil {
+		if instances, err := p.listInstances(); err != nil {
+			log.Printf("[Metrics] Prometheus: failed to list instances: %v", err)
+		} else {
+			next.instances = p.sampleInstances(instances, next.at)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if jobs, err := p.countJobs(ctx); err != nil {
+		log.Printf("[Metrics] Prometheus: failed to count jobs: %v", err)
+	} else {
+		next.jobs = jobs
+	}
+
+	p.mu.Lock()
+	p.snapshot = next
+	p.mu.Unlock()
+}
+
+func (p *PrometheusCollector) sampleInstances(instances []lxc.InstanceMetric, now time.Time) []promInstance {
+	out := make([]promInstance, 0, len(instances))
+	seen := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		seen[inst.Name] = true
+		cpu, ok := cpuPercentSince(p.lastCPU, inst, now)
+		out = append(out, promInstance{
+			name:   inst.Name,
+			cpu:    cpu,
+			hasCPU: ok,
+			memory: inst.MemoryUsageBytes,
+			disk:   inst.DiskUsageBytes,
+		})
+	}
+	for name := range p.lastCPU {
+		if !seen[name] {
+			delete(p.lastCPU, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+)
+
+func TestPrometheusCollectorServesLastSnapshotWhenLXDIsSlow(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := false
+	lists := 0
+	list := func() ([]lxc.InstanceMetric, error) {
+		lists++
+		if slow {
+			<-release
+		}
+		return []lxc.InstanceMetric{{Name: "web-1", MemoryUsageBytes: 512, DiskUsageBytes: 2048}}, nil
+	}
+	count := func(context.Context) ([]db.JobCount, error) {
+		return []db.JobCount{{Type: "create_instance", Status: "COMPLETED", Count: 3}}, nil
+	}
+
+	p := NewPrometheusCollector(list, count)
+	p.wait = 50 * time.Millisecond
+
+	first := p.current()
+	if len(first.instances) != 1 || first.instances[0].memory != 512 || len(first.jobs) != 1 {
+		t.Fatalf("unexpected first snapshot: %+v", first)
+	}
+
+	// Within the TTL LXD is not asked again
+	p.current()
+	if lists != 1 {
+		t.Fatalf("LXD listed %d times within the TTL, want 1", lists)
+	}
+
+	p.ttl = 0
+	slow = true
+	start := time.Now()
+	stale := p.current()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("scrape blocked for %s on a slow LXD", elapsed)
+	}
+	if len(stale.instances) != 1 || !stale.at.Equal(first.at) {
+		t.Errorf("expected the previous snapshot, got %+v", stale)
+	}
+}
+
+func TestPrometheusCollectorCPUFromConsecutiveSnapshots(t *testing.T) {
+	p := NewPrometheusCollector(nil, func(context.Context) ([]db.JobCount, error) { return nil, nil })
+	now := time.Now()
+	inst := lxc.InstanceMetric{Name: "web-1", CPUUsageSeconds: 100, Config: map[string]string{"limits.cpu": "2"}}
+
+	if got := p.sampleInstances([]lxc.InstanceMetric{inst}, now); got[0].hasCPU {
+		t.Fatalf("first sample should have no CPU rate, got %+v", got[0])
+	}
+
+	inst.CPUUsageSeconds = 110
+	got := p.sampleInstances([]lxc.InstanceMetric{inst}, now.Add(10*time.Second))
+	if !got[0].hasCPU || got[0].cpu != 50 {
+		t.Errorf("cpu = %v (ok %v), want 50", got[0].cpu, got[0].hasCPU)
+	}
+
+	p.sampleInstances(nil, now.Add(20*time.Second))
+	if len(p.lastCPU) != 0 {
+		t.Errorf("gone instances should be forgotten, have %v", p.lastCPU)
+	}
+}
@@ -0,0 +1,123 @@
+// Package pagination parses and validates ?limit=&offset=&sort=&order= for
+// list endpoints. Sorting is restricted to an allowlist per resource that maps
+// API names to SQL expressions, so user input never reaches an ORDER BY.
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resource describes what a list endpoint accepts
+type Resource struct {
+	// Columns maps each sortable API name to its SQL expression
+	Columns map[string]string
+	// Tiebreak is appended to every ORDER BY so pages are stable
+	Tiebreak string
+
+	DefaultSort  string
+	DefaultOrder string // "asc" or "desc"
+	// DefaultLimit applies when ?limit= is absent; 0 returns every row
+	DefaultLimit int
+	// MaxLimit clamps larger limits; it does not apply to a 0 DefaultLimit
+	MaxLimit int
+}
+
+// Params is a validated page request. Its ORDER BY only ever contains
+// expressions from the resource's allowlist.
+type Params struct {
+	Limit  int // 0 means no limit
+	Offset int
+	Sort   string
+	Order  string
+
+	orderBy string
+}
+
+// Error is a rejected query parameter
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Param, e.Message)
+}
+
+// Parse reads the pagination parameters from query against r
+func Parse(query url.Values, r Resource) (Params, error) {
+	p := Params{
+		Limit: r.DefaultLimit,
+		Sort:  r.DefaultSort,
+		Order: r.DefaultOrder,
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return Params{}, &Error{Param: "limit", Message: "must be a positive integer"}
+		}
+		p.Limit = limit
+	}
+	if r.MaxLimit > 0 && p.Limit > r.MaxLimit {
+		p.Limit = r.MaxLimit
+	}
+
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return Params{}, &Error{Param: "offset", Message: "must be a non-negative integer"}
+		}
+		p.Offset = offset
+	}
+
+	if raw := query.Get("sort"); raw != "" {
+		p.Sort = raw
+	}
+	column, ok := r.Columns[p.Sort]
+	if !ok {
+		return Params{}, &Error{Param: "sort", Message: fmt.Sprintf("%q is not sortable (valid: %s)", p.Sort, strings.Join(r.SortNames(), ", "))}
+	}
+
+	if raw := query.Get("order"); raw != "" {
+		p.Order = strings.ToLower(raw)
+	}
+	switch p.Order {
+	case "asc", "desc":
+	default:
+		return Params{}, &Error{Param: "order", Message: "must be asc or desc"}
+	}
+
+	p.orderBy = "ORDER BY " + column + " " + strings.ToUpper(p.Order)
+	if r.Tiebreak != "" && r.Tiebreak != column {
+		p.orderBy += ", " + r.Tiebreak
+	}
+	return p, nil
+}
+
+// SortNames lists the accepted ?sort= values
+func (r Resource) SortNames() []string {
+	names := make([]string, 0, len(r.Columns))
+	for name := range r.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OrderBy returns the ORDER BY clause built from the allowlist
+func (p Params) OrderBy() string {
+	return p.orderBy
+}
+
+// LimitArg is the value for a "LIMIT $n" placeholder: nil (no limit) when
+// Limit is 0, which PostgreSQL treats as LIMIT ALL
+func (p Params) LimitArg() interface{} {
+	if p.Limit == 0 {
+		return nil
+	}
+	return p.Limit
+}
@@ -0,0 +1,73 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+var testResource = Resource{
+	Columns:      map[string]string{"name": "i.name", "created": "i.created_at"},
+	Tiebreak:     "i.name",
+	DefaultSort:  "name",
+	DefaultOrder: "asc",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+func TestParseDefaults(t *testing.T) {
+	p, err := Parse(url.Values{}, testResource)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Limit != 50 || p.Offset != 0 {
+		t.Errorf("limit/offset = %d/%d, want 50/0", p.Limit, p.Offset)
+	}
+	if got := p.OrderBy(); got != "ORDER BY i.name ASC" {
+		t.Errorf("OrderBy = %q", got)
+	}
+}
+
+func TestParseSortAndClamp(t *testing.T) {
+	p, err := Parse(url.Values{"sort": {"created"}, "order": {"DESC"}, "limit": {"1000"}, "offset": {"20"}}, testResource)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Limit != 200 {
+		t.Errorf("Limit = %d, want clamped to 200", p.Limit)
+	}
+	if p.Offset != 20 {
+		t.Errorf("Offset = %d, want 20", p.Offset)
+	}
+	if got := p.OrderBy(); got != "ORDER BY i.created_at DESC, i.name" {
+		t.Errorf("OrderBy = %q", got)
+	}
+}
+
+func TestParseRejectsInvalidParams(t *testing.T) {
+	cases := map[string]url.Values{
+		"sort":   {"sort": {"name; DROP TABLE instances"}},
+		"order":  {"order": {"sideways"}},
+		"limit":  {"limit": {"-1"}},
+		"offset": {"offset": {"abc"}},
+	}
+	for param, query := range cases {
+		_, err := Parse(query, testResource)
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Param != param {
+			t.Errorf("%s: expected *Error for %s, got %v", param, param, err)
+		}
+	}
+}
+
+func TestLimitArgUnlimited(t *testing.T) {
+	r := testResource
+	r.DefaultLimit = 0
+	p, err := Parse(url.Values{}, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LimitArg() != nil {
+		t.Errorf("LimitArg = %v, want nil for no limit", p.LimitArg())
+	}
+}
@@ -2,14 +2,91 @@ package axhv
 
 import (
 	"fmt"
+	"log"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"aexon/internal/provider/axhv/pb"
+	"aexon/internal/service"
 	"aexon/internal/types"
 	"aexon/internal/utils"
 )
 
+// GuestGateway is the host side of the AxHV guest network
+const GuestGateway = "172.16.0.1"
+
+// SeedBootArgs points cloud-init's NoCloud datasource at the seed Axion serves
+// for the VM (GET /seed/<name>/user-data...). AxHV has no user_data field,
+// so the kernel command line is how a VM finds its configuration.
+func SeedBootArgs(seedURL, name string) string {
+	return fmt.Sprintf("ds=nocloud-net;s=%s/seed/%s/", seedURL, name)
+}
+
+// Image fallback policies for images without a rootfs mapping
+const (
+	// ImageFallbackStrict rejects the image, so the wrong OS is never booted
+	ImageFallbackStrict = "strict"
+	// ImageFallbackLenient boots DefaultRootfs and logs a warning (development)
+	ImageFallbackLenient = "lenient"
+)
+
+// ImagePolicy says where AxHV kernels and root filesystems live and what to
+// do with an allowed image that has no rootfs mapping.
+type ImagePolicy struct {
+	Fallback      string
+	KernelPath    string
+	ImagesDir     string
+	DefaultRootfs string // used by the lenient fallback only
+}
+
+// DefaultImagePolicy is strict, with the standard AxHV host layout
+func DefaultImagePolicy() ImagePolicy {
+	return ImagePolicy{
+		Fallback:      ImageFallbackStrict,
+		KernelPath:    "/var/lib/axhv/kernels/vmlinux-distro",
+		ImagesDir:     "/var/lib/axhv/images",
+		DefaultRootfs: "/var/lib/axhv/images/ubuntu-rootfs.ext4",
+	}
+}
+
+// Validate checks the fallback mode and that the lenient mode has a rootfs
+func (p ImagePolicy) Validate() error {
+	switch p.Fallback {
+	case ImageFallbackStrict:
+	case ImageFallbackLenient:
+		if p.DefaultRootfs == "" {
+			return fmt.Errorf("the %s image fallback needs a default rootfs", ImageFallbackLenient)
+		}
+	default:
+		return fmt.Errorf("image fallback must be %q or %q, got %q", ImageFallbackStrict, ImageFallbackLenient, p.Fallback)
+	}
+	if p.KernelPath == "" || p.ImagesDir == "" {
+		return fmt.Errorf("kernel path and images directory are required")
+	}
+	return nil
+}
+
+var (
+	imagePolicyMu sync.RWMutex
+	imagePolicy   = DefaultImagePolicy()
+)
+
+// SetImagePolicy replaces the policy used to map images (set from config at startup)
+func SetImagePolicy(p ImagePolicy) {
+	imagePolicyMu.Lock()
+	defer imagePolicyMu.Unlock()
+	imagePolicy = p
+}
+
+func currentImagePolicy() ImagePolicy {
+	imagePolicyMu.RLock()
+	defer imagePolicyMu.RUnlock()
+	return imagePolicy
+}
+
 // MapCreateRequestV2 maps values directly without parsing from strings.
 // This is the preferred method when the frontend sends numeric values.
 func MapCreateRequestV2(name string, image string, vcpu int, memoryMiB int, diskGB int, bandwidthMbps int, ip string, gateway string, ports map[string]string, password string) (*pb.CreateVmRequest, error) {
@@ -36,20 +113,7 @@ func MapCreateRequestV2(name string, image string, vcpu int, memoryMiB int, disk
 	}
 
 	// Parse Ports from limits map
-	portMap := make(map[uint32]uint32)
-	if portsStr, ok := ports["ports"]; ok {
-		rules := strings.Split(portsStr, ",")
-		for _, rule := range rules {
-			parts := strings.Split(rule, ":")
-			if len(parts) == 2 {
-				hostPort, _ := strconv.Atoi(parts[0])
-				guestPort, _ := strconv.Atoi(parts[1])
-				if hostPort > 0 && guestPort > 0 {
-					portMap[uint32(hostPort)] = uint32(guestPort)
-				}
-			}
-		}
-	}
+	tcpPorts, udpPorts := parsePortMap(ports["ports"])
 
 	pbReq := &pb.CreateVmRequest{
 		Id:                 name,
@@ -61,17 +125,18 @@ func MapCreateRequestV2(name string, image string, vcpu int, memoryMiB int, disk
 		GuestGateway:       gateway,
 		KernelPath:         kernelPath,
 		RootfsPath:         rootfsPath,
-		PortMapTcp:         portMap,
+		PortMapTcp:         tcpPorts,
+		PortMapUdp:         udpPorts,
 		RootPassword:       password,
 	}
 
-	// Note: Free tier limits are NOT applied here - caller can enforce if needed
+	// Note: tier limits are NOT applied here - caller enforces them with ApplyTierPolicy
 	return pbReq, nil
 }
 
 // MapCreateRequest maps the internal CreateInstanceRequest to the protobuf CreateVmRequest.
-// It also enforces Free Tier limitations.
-func MapCreateRequest(req types.Instance, ip string, gateway string) (*pb.CreateVmRequest, error) {
+// It also enforces the limits of the given tier policy.
+func MapCreateRequest(req types.Instance, ip string, gateway string, policy types.TierPolicy) (*pb.CreateVmRequest, error) {
 
 	// Parse Limits
 	cpu := utils.ParseCpuCores(req.Limits["cpu"])
@@ -85,31 +150,16 @@ func MapCreateRequest(req types.Instance, ip string, gateway string) (*pb.Create
 	}
 
 	disk := uint32(10) // Default 10GB if not specified
-	if val, ok := req.Limits["disk"]; ok {
-		// Simplified parsing, assuming GB integer for now or implementing parser
-		d, _ := strconv.Atoi(val)
-		if d > 0 {
-			disk = uint32(d)
+	if val, ok := req.Limits["disk"]; ok && val != "" {
+		d, err := utils.ParseDiskToGB(val)
+		if err != nil {
+			return nil, err
 		}
+		disk = uint32(d)
 	}
 
 	// Parse Ports
-	portMap := make(map[uint32]uint32)
-	if val, ok := req.Limits["ports"]; ok {
-		// Input: "2202:22,8080:80" (hostPort:guestPort)
-		rules := strings.Split(val, ",")
-		for _, rule := range rules {
-			parts := strings.Split(rule, ":")
-			if len(parts) == 2 {
-				hostPort, _ := strconv.Atoi(parts[0])
-				guestPort, _ := strconv.Atoi(parts[1])
-
-				if hostPort > 0 && guestPort > 0 {
-					portMap[uint32(hostPort)] = uint32(guestPort)
-				}
-			}
-		}
-	}
+	tcpPorts, udpPorts := parsePortMap(req.Limits["ports"])
 
 	// Map Image to Paths
 	kernelPath, rootfsPath, err := mapImageToPaths(req.Image)
@@ -126,83 +176,120 @@ func MapCreateRequest(req types.Instance, ip string, gateway string) (*pb.Create
 		GuestGateway: gateway,
 		KernelPath:   kernelPath,
 		RootfsPath:   rootfsPath,
-		PortMapTcp:   portMap,
+		PortMapTcp:   tcpPorts,
+		PortMapUdp:   udpPorts,
 	}
 
-	// Enforce Free Tier Limits (Hardcoded enforcement for now as requested)
-	// In a real scenario, we might check req.Plan or User context.
-	// Assuming all creations via this path are subject to these rules for the task context "Free Tier Enforcement".
-
-	applyFreeTierLimits(pbReq)
+	if err := ApplyTierPolicy(pbReq, policy); err != nil {
+		return nil, err
+	}
 
 	return pbReq, nil
 }
 
+// parsePortMap splits the "ports" limit into TCP and UDP forwards.
+// Input: "2202:22,8080:80,5353:53/udp" (hostPort:guestPort, optional /tcp or
+// /udp suffix, TCP when omitted). Malformed rules are ignored.
+func parsePortMap(val string) (tcp, udp map[uint32]uint32) {
+	tcp = make(map[uint32]uint32)
+	udp = make(map[uint32]uint32)
+	if val == "" {
+		return tcp, udp
+	}
+
+	for _, rule := range strings.Split(val, ",") {
+		rule, proto, _ := strings.Cut(strings.TrimSpace(rule), "/")
+		parts := strings.Split(rule, ":")
+		if len(parts) != 2 {
+			continue
+		}
+		hostPort, _ := strconv.Atoi(parts[0])
+		guestPort, _ := strconv.Atoi(parts[1])
+		if hostPort <= 0 || guestPort <= 0 {
+			continue
+		}
+
+		switch strings.ToLower(proto) {
+		case "", "tcp":
+			tcp[uint32(hostPort)] = uint32(guestPort)
+		case "udp":
+			udp[uint32(hostPort)] = uint32(guestPort)
+		}
+	}
+	return tcp, udp
+}
+
+// ResolveImage returns the kernel and rootfs paths AxHV would boot for an image name.
+func ResolveImage(imageName string) (kernelPath string, rootfsPath string, err error) {
+	return mapImageToPaths(imageName)
+}
+
 func mapImageToPaths(imageName string) (string, string, error) {
-	// Base directories for AxHV
-	kernelDir := "/var/lib/axhv/kernels"
-	imagesDir := "/var/lib/axhv/images"
+	if !service.IsImageAllowed(imageName) {
+		return "", "", fmt.Errorf("%w: %s", service.ErrImageNotAllowed, imageName)
+	}
 
-	// Use the default kernel
-	kernelPath := fmt.Sprintf("%s/vmlinux-distro", kernelDir)
+	policy := currentImagePolicy()
+	kernelPath := policy.KernelPath
 
 	// Normalize image name and map to rootfs
 	switch {
 	case strings.Contains(imageName, "ubuntu"):
-		return kernelPath,
-			fmt.Sprintf("%s/ubuntu-rootfs.ext4", imagesDir),
-			nil
+		return kernelPath, path.Join(policy.ImagesDir, "ubuntu-rootfs.ext4"), nil
 	case strings.Contains(imageName, "alpine"):
 		// Alpine might use same kernel but different rootfs
-		return kernelPath,
-			fmt.Sprintf("%s/alpine-rootfs.ext4", imagesDir),
-			nil
+		return kernelPath, path.Join(policy.ImagesDir, "alpine-rootfs.ext4"), nil
+	case policy.Fallback == ImageFallbackLenient:
+		log.Printf("[AxHV] WARNING: image %q has no rootfs mapping, booting fallback %s", imageName, policy.DefaultRootfs)
+		return kernelPath, policy.DefaultRootfs, nil
 	default:
-		// Default to ubuntu
-		return kernelPath,
-			fmt.Sprintf("%s/ubuntu-rootfs.ext4", imagesDir),
-			nil
+		// Allowed but without a rootfs on this host: fail here instead of booting the wrong OS
+		return "", "", fmt.Errorf("image %q has no AxHV rootfs mapping (AXION_IMAGE_FALLBACK=strict)", imageName)
 	}
 }
 
-func applyFreeTierLimits(req *pb.CreateVmRequest) {
-	// Bandwidth: 0 = unlimited (no traffic shaping)
-	// Removed: req.BandwidthLimitMbps = 10
-
-	// 2. Port Limits
-	// As we don't have ports in the generic input yet (usually added later),
-	// we initialize the maps to empty or filtered if they were passed.
-	// If the request had ports (e.g. from a rich request object), we would filter them here.
-	// Since types.Instance doesn't strictly have a list of initial ports in its basic struct
-	// (usually added via AddPort), we ensure the map is initialized to allow strict validation if we were to add them.
-
-	// Example of restricting if we were populating from a source that had ports:
-	limitTcp := 3
-	limitUdp := 1
-
-	if len(req.PortMapTcp) > limitTcp {
-		newMap := make(map[uint32]uint32)
-		i := 0
-		for k, v := range req.PortMapTcp {
-			if i >= limitTcp {
-				break
-			}
-			newMap[k] = v
-			i++
-		}
-		req.PortMapTcp = newMap
-	}
-
-	if len(req.PortMapUdp) > limitUdp {
-		newMap := make(map[uint32]uint32)
-		i := 0
-		for k, v := range req.PortMapUdp {
-			if i >= limitUdp {
-				break
-			}
-			newMap[k] = v
-			i++
-		}
-		req.PortMapUdp = newMap
+// ApplyTierPolicy enforces a tier policy on a mapped request.
+// Sizing above the policy caps is rejected, bandwidth is clamped to the cap and
+// port maps larger than the allowed number of entries are rejected. Zero caps mean no limit.
+func ApplyTierPolicy(req *pb.CreateVmRequest, policy types.TierPolicy) error {
+	if policy.MaxVcpu > 0 && req.Vcpu > uint32(policy.MaxVcpu) {
+		return fmt.Errorf("plan %q allows at most %d vCPU, requested %d", policy.Name, policy.MaxVcpu, req.Vcpu)
+	}
+	if policy.MaxMemoryMiB > 0 && req.MemoryMib > uint32(policy.MaxMemoryMiB) {
+		return fmt.Errorf("plan %q allows at most %d MiB of memory, requested %d", policy.Name, policy.MaxMemoryMiB, req.MemoryMib)
+	}
+	if policy.MaxDiskGB > 0 && req.DiskSizeGb > uint32(policy.MaxDiskGB) {
+		return fmt.Errorf("plan %q allows at most %d GB of disk, requested %d", policy.Name, policy.MaxDiskGB, req.DiskSizeGb)
+	}
+
+	// Bandwidth: 0 = unlimited (no traffic shaping), so a capped plan always gets shaped
+	if policy.MaxBandwidthMbps > 0 && (req.BandwidthLimitMbps == 0 || req.BandwidthLimitMbps > uint32(policy.MaxBandwidthMbps)) {
+		req.BandwidthLimitMbps = uint32(policy.MaxBandwidthMbps)
+	}
+
+	if err := checkPortLimit(req.PortMapTcp, policy.MaxTcpPorts, "TCP", policy.Name); err != nil {
+		return err
+	}
+	return checkPortLimit(req.PortMapUdp, policy.MaxUdpPorts, "UDP", policy.Name)
+}
+
+// checkPortLimit rejects a port map larger than the limit, naming the host ports
+// beyond the cap (in ascending order) instead of silently dropping forwards.
+func checkPortLimit(ports map[uint32]uint32, limit int, proto string, plan string) error {
+	if limit <= 0 || len(ports) <= limit {
+		return nil
+	}
+
+	hostPorts := make([]uint32, 0, len(ports))
+	for hostPort := range ports {
+		hostPorts = append(hostPorts, hostPort)
+	}
+	sort.Slice(hostPorts, func(i, j int) bool { return hostPorts[i] < hostPorts[j] })
+
+	excess := make([]string, 0, len(hostPorts)-limit)
+	for _, hostPort := range hostPorts[limit:] {
+		excess = append(excess, strconv.Itoa(int(hostPort)))
 	}
+	return fmt.Errorf("plan %q allows at most %d %s port forwards, requested %d (over the limit: %s)",
+		plan, limit, proto, len(ports), strings.Join(excess, ", "))
 }
@@ -0,0 +1,214 @@
+package axhv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"aexon/internal/provider/axhv/pb"
+	"aexon/internal/service"
+	"aexon/internal/types"
+)
+
+func TestMapCreateRequestDiskParsing(t *testing.T) {
+	cases := []struct {
+		disk    string
+		want    uint32
+		wantErr bool
+	}{
+		{disk: "20GB", want: 20},
+		{disk: "20", want: 20},
+		{disk: "20480MB", want: 20},
+		{disk: "20g", want: 20},
+		{disk: "garbage", wantErr: true},
+		{disk: "20XB", wantErr: true},
+		{disk: "0", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.disk, func(t *testing.T) {
+			inst := types.Instance{
+				Name:   "vm-test",
+				Image:  "ubuntu",
+				Limits: map[string]string{"disk": tc.disk},
+			}
+
+			req, err := MapCreateRequest(inst, "10.0.0.2", "10.0.0.1", types.FreeTierPolicy())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for disk %q, got DiskSizeGb=%d", tc.disk, req.DiskSizeGb)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.DiskSizeGb != tc.want {
+				t.Errorf("DiskSizeGb = %d, want %d", req.DiskSizeGb, tc.want)
+			}
+		})
+	}
+}
+
+func TestMapCreateRequestDiskDefault(t *testing.T) {
+	inst := types.Instance{Name: "vm-test", Image: "ubuntu", Limits: map[string]string{}}
+
+	req, err := MapCreateRequest(inst, "10.0.0.2", "10.0.0.1", types.FreeTierPolicy())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.DiskSizeGb != 10 {
+		t.Errorf("DiskSizeGb = %d, want default 10", req.DiskSizeGb)
+	}
+}
+
+func TestMapCreateRequestRejectsExcessPorts(t *testing.T) {
+	inst := types.Instance{
+		Name:   "vm-test",
+		Image:  "ubuntu",
+		Limits: map[string]string{"ports": "9000:90,2202:22,8080:80,8443:443,3000:3000"},
+	}
+
+	// Run several times: the error must not depend on map iteration order
+	for i := 0; i < 10; i++ {
+		_, err := MapCreateRequest(inst, "10.0.0.2", "10.0.0.1", types.FreeTierPolicy())
+		if err == nil {
+			t.Fatal("expected error for 5 TCP ports on the free tier")
+		}
+		if !strings.HasSuffix(err.Error(), "(over the limit: 8443, 9000)") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	req, err := MapCreateRequest(inst, "10.0.0.2", "10.0.0.1", types.UnlimitedTierPolicy())
+	if err != nil {
+		t.Fatalf("unlimited policy should accept all ports: %v", err)
+	}
+	if len(req.PortMapTcp) != 5 {
+		t.Errorf("PortMapTcp has %d entries, want 5", len(req.PortMapTcp))
+	}
+}
+
+func TestMapCreateRequestSplitsUDPPorts(t *testing.T) {
+	inst := types.Instance{
+		Name:   "vm-test",
+		Image:  "ubuntu",
+		Limits: map[string]string{"ports": "2202:22,5353:53/udp,2202:22/udp,8080:80/tcp,9000:90/sctp"},
+	}
+
+	req, err := MapCreateRequest(inst, "10.0.0.2", "10.0.0.1", types.UnlimitedTierPolicy())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(req.PortMapTcp) != 2 || req.PortMapTcp[2202] != 22 || req.PortMapTcp[8080] != 80 {
+		t.Errorf("PortMapTcp = %v, want 2202:22 and 8080:80", req.PortMapTcp)
+	}
+	if len(req.PortMapUdp) != 2 || req.PortMapUdp[5353] != 53 || req.PortMapUdp[2202] != 22 {
+		t.Errorf("PortMapUdp = %v, want 5353:53 and 2202:22", req.PortMapUdp)
+	}
+}
+
+func TestApplyTierPolicy(t *testing.T) {
+	pro := types.TierPolicy{Name: "pro", MaxVcpu: 4, MaxMemoryMiB: 4096, MaxDiskGB: 50, MaxBandwidthMbps: 100, MaxTcpPorts: 2}
+
+	cases := []struct {
+		name          string
+		req           *pb.CreateVmRequest
+		policy        types.TierPolicy
+		wantErr       string
+		wantBandwidth uint32
+	}{
+		{name: "within caps", req: &pb.CreateVmRequest{Vcpu: 4, MemoryMib: 4096, DiskSizeGb: 50, BandwidthLimitMbps: 50}, policy: pro, wantBandwidth: 50},
+		{name: "vcpu over cap", req: &pb.CreateVmRequest{Vcpu: 8}, policy: pro, wantErr: "at most 4 vCPU"},
+		{name: "memory over cap", req: &pb.CreateVmRequest{MemoryMib: 8192}, policy: pro, wantErr: "at most 4096 MiB"},
+		{name: "disk over cap", req: &pb.CreateVmRequest{DiskSizeGb: 100}, policy: pro, wantErr: "at most 50 GB"},
+		{name: "unshaped bandwidth gets the cap", req: &pb.CreateVmRequest{}, policy: pro, wantBandwidth: 100},
+		{name: "bandwidth clamped", req: &pb.CreateVmRequest{BandwidthLimitMbps: 1000}, policy: pro, wantBandwidth: 100},
+		{name: "tcp ports over cap", req: &pb.CreateVmRequest{PortMapTcp: map[uint32]uint32{2202: 22, 8080: 80, 8443: 443}}, policy: pro, wantErr: "over the limit: 8443"},
+		{name: "udp ports over free cap", req: &pb.CreateVmRequest{PortMapUdp: map[uint32]uint32{53: 53, 5353: 53}}, policy: types.FreeTierPolicy(), wantErr: "at most 1 UDP"},
+		{name: "unlimited", req: &pb.CreateVmRequest{Vcpu: 64, MemoryMib: 1 << 20, PortMapTcp: map[uint32]uint32{1: 1, 2: 2, 3: 3, 4: 4}}, policy: types.UnlimitedTierPolicy()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ApplyTierPolicy(tc.req, tc.policy)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.req.BandwidthLimitMbps != tc.wantBandwidth {
+				t.Errorf("BandwidthLimitMbps = %d, want %d", tc.req.BandwidthLimitMbps, tc.wantBandwidth)
+			}
+		})
+	}
+}
+
+func TestMapImageToPathsRejectsDisallowedImage(t *testing.T) {
+	service.SetAllowedImages([]string{"ubuntu*"})
+	t.Cleanup(func() { service.SetAllowedImages(service.DefaultAllowedImages) })
+
+	if _, _, err := mapImageToPaths("ubuntu-22.04"); err != nil {
+		t.Fatalf("ubuntu should be allowed: %v", err)
+	}
+	if _, _, err := mapImageToPaths("alpine"); !errors.Is(err, service.ErrImageNotAllowed) {
+		t.Errorf("expected ErrImageNotAllowed for alpine, got %v", err)
+	}
+	if _, _, err := mapImageToPaths("images:ubuntu/22.04"); err == nil {
+		t.Error("remote image should not match a local-only pattern")
+	}
+}
+
+func TestMapImageToPathsFallbackPolicy(t *testing.T) {
+	service.SetAllowedImages([]string{"*"})
+	t.Cleanup(func() {
+		service.SetAllowedImages(service.DefaultAllowedImages)
+		SetImagePolicy(DefaultImagePolicy())
+	})
+
+	if _, _, err := mapImageToPaths("debian-12"); err == nil {
+		t.Fatal("strict policy must reject an image without a rootfs mapping")
+	}
+
+	policy := DefaultImagePolicy()
+	policy.Fallback = ImageFallbackLenient
+	policy.KernelPath = "/opt/axhv/vmlinux"
+	policy.DefaultRootfs = "/opt/axhv/fallback.ext4"
+	SetImagePolicy(policy)
+
+	kernel, rootfs, err := mapImageToPaths("debian-12")
+	if err != nil {
+		t.Fatalf("lenient policy should fall back: %v", err)
+	}
+	if kernel != "/opt/axhv/vmlinux" || rootfs != "/opt/axhv/fallback.ext4" {
+		t.Errorf("got %s, %s; want the configured kernel and fallback rootfs", kernel, rootfs)
+	}
+
+	// Known images keep their own rootfs under the lenient policy too
+	if _, rootfs, _ := mapImageToPaths("alpine-3.19"); rootfs != "/var/lib/axhv/images/alpine-rootfs.ext4" {
+		t.Errorf("alpine rootfs = %s", rootfs)
+	}
+}
+
+func TestImagePolicyValidate(t *testing.T) {
+	if err := DefaultImagePolicy().Validate(); err != nil {
+		t.Fatalf("default policy: %v", err)
+	}
+
+	lenient := DefaultImagePolicy()
+	lenient.Fallback = ImageFallbackLenient
+	lenient.DefaultRootfs = ""
+	if err := lenient.Validate(); err == nil {
+		t.Error("lenient policy without a default rootfs must be rejected")
+	}
+
+	unknown := DefaultImagePolicy()
+	unknown.Fallback = "ubuntu"
+	if err := unknown.Validate(); err == nil {
+		t.Error("unknown fallback mode must be rejected")
+	}
+}
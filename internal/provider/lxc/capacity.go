@@ -0,0 +1,84 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ============================================================================
+// ERROS DE CAPACIDADE
+// ============================================================================
+
+// Códigos de CapacityError, gravados em jobs.error_code
+const (
+	CapacityStoragePoolFull    = "storage_pool_full"
+	CapacityInsufficientMemory = "insufficient_memory"
+	CapacityProjectLimit       = "project_limit_reached"
+)
+
+// CapacityError é uma recusa do LXD por falta de recurso, traduzida para um
+// código estável e uma mensagem acionável. Err guarda o erro original.
+type CapacityError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("%s (LXD: %v)", e.Message, e.Err)
+}
+
+func (e *CapacityError) Unwrap() error {
+	return e.Err
+}
+
+// capacityPatterns mapeia mensagens do LXD (e dos drivers de storage por baixo
+// dele) para códigos. A primeira regra que casa vence.
+var capacityPatterns = []struct {
+	pattern *regexp.Regexp
+	code    string
+	message func(pool string) string
+}{
+	{
+		pattern: regexp.MustCompile(`(?i)no space left on device|out of space|insufficient free space|not enough (free )?space|disk quota exceeded`),
+		code:    CapacityStoragePoolFull,
+		message: func(pool string) string {
+			if pool == "" {
+				return "the default storage pool is full"
+			}
+			return fmt.Sprintf("storage pool '%s' is full", pool)
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)cannot allocate memory|out of memory|(insufficient|not enough) (host )?memory|failed to allocate memory`),
+		code:    CapacityInsufficientMemory,
+		message: func(string) string { return "insufficient host memory" },
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)reached maximum number of instances|exceed(s|ed)? .*project limit|project .*limit.* exceeded`),
+		code:    CapacityProjectLimit,
+		message: func(string) string { return "LXD project limit reached" },
+	},
+}
+
+// ClassifyCapacityError devolve um *CapacityError quando err é uma recusa por
+// capacidade conhecida; caso contrário devolve err sem alterar. pool nomeia o
+// pool de storage envolvido ("" para o padrão).
+func ClassifyCapacityError(err error, pool string) error {
+	if err == nil {
+		return nil
+	}
+	var capErr *CapacityError
+	if errors.As(err, &capErr) {
+		return err
+	}
+
+	msg := err.Error()
+	for _, p := range capacityPatterns {
+		if p.pattern.MatchString(msg) {
+			return &CapacityError{Code: p.code, Message: p.message(pool), Err: err}
+		}
+	}
+	return err
+}
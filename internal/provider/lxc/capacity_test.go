@@ -0,0 +1,49 @@
+package lxc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyCapacityError(t *testing.T) {
+	cases := []struct {
+		raw     string
+		pool    string
+		code    string
+		message string
+	}{
+		{raw: "Failed creating instance from image: Unpack failed: write /var/lib/lxd/x: no space left on device", pool: "fast", code: CapacityStoragePoolFull, message: "storage pool 'fast' is full"},
+		{raw: `Failed to run: lvcreate: Volume group "vg0" has insufficient free space (10 extents): 2560 required.`, code: CapacityStoragePoolFull, message: "the default storage pool is full"},
+		{raw: "cannot create dataset: out of space", pool: "zfs", code: CapacityStoragePoolFull, message: "storage pool 'zfs' is full"},
+		{raw: "Failed to start device: qemu-system-x86_64: cannot set up guest memory 'pc.ram': Cannot allocate memory", code: CapacityInsufficientMemory, message: "insufficient host memory"},
+		{raw: `Reached maximum number of instances in project "default"`, code: CapacityProjectLimit, message: "LXD project limit reached"},
+	}
+
+	for _, tc := range cases {
+		err := ClassifyCapacityError(errors.New(tc.raw), tc.pool)
+		var capErr *CapacityError
+		if !errors.As(err, &capErr) {
+			t.Errorf("%q: not classified", tc.raw)
+			continue
+		}
+		if capErr.Code != tc.code || capErr.Message != tc.message {
+			t.Errorf("%q: got %s/%q, want %s/%q", tc.raw, capErr.Code, capErr.Message, tc.code, tc.message)
+		}
+	}
+}
+
+func TestClassifyCapacityErrorLeavesOtherErrors(t *testing.T) {
+	if ClassifyCapacityError(nil, "") != nil {
+		t.Error("nil error classified")
+	}
+
+	original := errors.New("Instance not found")
+	if err := ClassifyCapacityError(original, "default"); err != original {
+		t.Errorf("unrelated error changed: %v", err)
+	}
+
+	classified := ClassifyCapacityError(errors.New("no space left on device"), "a")
+	if again := ClassifyCapacityError(classified, "b"); again != classified {
+		t.Errorf("classified twice: %v", again)
+	}
+}
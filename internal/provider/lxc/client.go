@@ -1,14 +1,21 @@
 package lxc
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"aexon/internal/service"
 	"aexon/internal/utils"
 
 	lxd "github.com/canonical/lxd/client"
@@ -23,6 +30,7 @@ type InstanceService struct {
 }
 
 type InstanceMetric struct {
+	Remote            string                       `json:"remote,omitempty"` // Remote LXD de origem (Fleet)
 	Location          string                       `json:"location"`
 	Name              string                       `json:"name"`
 	Type              string                       `json:"type"`
@@ -41,18 +49,69 @@ const (
 	MaxGlobalRAMMB = 8192
 )
 
+// ProgressFunc recebe o progresso (0-100) de operações longas do LXD.
+type ProgressFunc func(percent int, message string)
+
+var progressPercentRegex = regexp.MustCompile(`(\d+)%`)
+
+// progressStages mapeia chaves de metadata do LXD para uma faixa do progresso total
+var progressStages = []struct {
+	key      string
+	label    string
+	from, to int
+}{
+	{"download_progress", "downloading image", 0, 70},
+	{"create_instance_from_image_unpack_progress", "unpacking image", 70, 90},
+}
+
+// trackProgress encaminha o progresso reportado pelo LXD para o callback.
+// É best-effort: se o handler não puder ser registrado, a operação segue normalmente.
+func trackProgress(op lxd.Operation, progress ProgressFunc) {
+	if progress == nil {
+		return
+	}
+
+	_, err := op.AddHandler(func(o api.Operation) {
+		for _, stage := range progressStages {
+			raw, ok := o.Metadata[stage.key].(string)
+			if !ok {
+				continue
+			}
+			m := progressPercentRegex.FindStringSubmatch(raw)
+			if m == nil {
+				continue
+			}
+			pct, _ := strconv.Atoi(m[1])
+			overall := stage.from + (stage.to-stage.from)*pct/100
+			progress(overall, fmt.Sprintf("%s %d%%", stage.label, pct))
+		}
+	})
+	if err != nil {
+		log.Printf("[LXD Provider] Progresso indisponível para operação: %v", err)
+	}
+}
+
 type FileEntry struct {
-	Name string `json:"name"`
-	Type string `json:"type"` // "file" or "directory"
+	Name   string `json:"name"`
+	Type   string `json:"type"`             // "file", "directory" or "symlink"
+	Target string `json:"target,omitempty"` // Alvo, quando Type == "symlink"
+}
+
+// ConnectionConfig define como conectar ao LXD. Com URL, CertPath e KeyPath
+// preenchidos usa TLS (cluster); caso contrário, o socket Unix local.
+type ConnectionConfig struct {
+	URL      string
+	CertPath string
+	KeyPath  string
 }
 
-func NewClient() (*InstanceService, error) {
-	// Check for TLS environment variables
-	lxdURL := os.Getenv("AXION_LXD_URL")
-	certPath := os.Getenv("AXION_CERT_PATH")
-	keyPath := os.Getenv("AXION_KEY_PATH")
+// NewClientWithConfig conecta ao LXD com uma configuração já carregada.
+func NewClientWithConfig(cfg ConnectionConfig) (*InstanceService, error) {
+	lxdURL := cfg.URL
+	certPath := cfg.CertPath
+	keyPath := cfg.KeyPath
 
-	// If all TLS environment variables are set, use TLS connection
+	// If all TLS settings are set, use TLS connection
 	if lxdURL != "" && certPath != "" && keyPath != "" {
 		// Read certificate and key files
 		cert, err := os.ReadFile(certPath)
@@ -113,10 +172,12 @@ func NewClient() (*InstanceService, error) {
 	}, nil
 }
 
-// CheckPortAvailability verifica se a porta do host está livre e dentro do range permitido.
-func (s *InstanceService) CheckPortAvailability(hostPort int) error {
-	if hostPort < 10000 || hostPort > 60000 {
-		return fmt.Errorf("porta %d inválida. Permitido apenas entre 10000 e 60000", hostPort)
+// CheckPortAvailability verifica se a porta do host está livre no protocolo e
+// dentro do intervalo configurado (SetPortRanges). TCP e UDP na mesma porta
+// não conflitam.
+func (s *InstanceService) CheckPortAvailability(hostPort int, protocol string) error {
+	if allowed := PortRangeFor(protocol); !allowed.Contains(hostPort) {
+		return fmt.Errorf("porta %d inválida. Permitido apenas entre %d e %d (%s)", hostPort, allowed.Min, allowed.Max, protocol)
 	}
 
 	instances, err := s.server.GetInstancesFull(api.InstanceTypeContainer)
@@ -124,13 +185,11 @@ func (s *InstanceService) CheckPortAvailability(hostPort int) error {
 		return fmt.Errorf("falha ao verificar uso de portas: %w", err)
 	}
 
-	targetListen := fmt.Sprintf("0.0.0.0:%d", hostPort)
-
 	for _, inst := range instances {
 		for _, dev := range inst.Devices {
 			if dev["type"] == "proxy" {
-				if strings.Contains(dev["listen"], targetListen) {
-					return fmt.Errorf("porta %d já está em uso pelo container '%s'", hostPort, inst.Name)
+				if proxyListens(dev["listen"], protocol, hostPort) {
+					return fmt.Errorf("porta %d/%s já está em uso pelo container '%s'", hostPort, protocol, inst.Name)
 				}
 			}
 		}
@@ -171,6 +230,66 @@ func (s *InstanceService) ExecInteractive(name string, cmd []string, stdin io.Re
 	return nil
 }
 
+// ExecResult é o resultado capturado de um comando não-interativo.
+type ExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// ExecCommand executa um comando até o fim dentro do container e captura a saída.
+// Um exit code diferente de zero NÃO é tratado como erro; cabe ao chamador decidir.
+func (s *InstanceService) ExecCommand(name string, cmd []string) (*ExecResult, error) {
+	return s.ExecCommandContext(context.Background(), name, cmd)
+}
+
+// ExecCommandContext é ExecCommand com prazo: quando ctx expira a operação do
+// LXD é cancelada e o erro de ctx é devolvido.
+func (s *InstanceService) ExecCommandContext(ctx context.Context, name string, cmd []string) (*ExecResult, error) {
+	req := api.InstanceExecPost{
+		Command:     cmd,
+		WaitForWS:   true,
+		Interactive: false,
+		Environment: map[string]string{
+			"HOME": "/root",
+		},
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := lxd.InstanceExecArgs{
+		Stdin:    bytes.NewReader(nil),
+		Stdout:   &stdout,
+		Stderr:   &stderr,
+		DataDone: make(chan bool),
+	}
+
+	op, err := s.server.ExecInstance(name, req, &args)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao executar comando em '%s': %w", name, err)
+	}
+
+	if err := op.WaitContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			op.Cancel()
+			return nil, fmt.Errorf("execução em '%s' interrompida: %w", name, ctx.Err())
+		}
+		return nil, fmt.Errorf("erro durante execução em '%s': %w", name, err)
+	}
+
+	// Garante que todo o stdout/stderr foi consumido antes de ler os buffers
+	<-args.DataDone
+
+	result := &ExecResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+	if code, ok := op.Get().Metadata["return"].(float64); ok {
+		result.ExitCode = int(code)
+	}
+
+	return result, nil
+}
+
 func (s *InstanceService) CheckGlobalQuota(requestCPU int, requestRAMMB int64) error {
 	instances, err := s.server.GetInstancesFull(api.InstanceTypeContainer)
 	if err != nil {
@@ -384,8 +503,86 @@ func (s *InstanceService) UpdateInstanceLimits(name string, memoryLimit string,
 	}
 }
 
+// UpdateRootDiskLimits aplica limits.read/limits.write no device root ("" = ilimitado).
+// Cada valor é "<n>iops" ou um tamanho por segundo ("50MB"). limits.max é removido
+// para não sobrepor os limites por direção.
+func (s *InstanceService) UpdateRootDiskLimits(name string, read string, write string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está processando um comando. Tente novamente em alguns segundos", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("falha ao obter configuração atual de %s: %w", name, err)
+	}
+
+	// O root pode vir do profile; nesse caso é sobrescrito no nível da instância
+	root, ok := inst.Devices["root"]
+	if !ok {
+		expanded, found := inst.ExpandedDevices["root"]
+		if !found {
+			return fmt.Errorf("instância %s não possui device root", name)
+		}
+		root = make(map[string]string, len(expanded))
+		for k, v := range expanded {
+			root[k] = v
+		}
+	}
+
+	setOrDelete := func(key, value string) {
+		if value == "" {
+			delete(root, key)
+		} else {
+			root[key] = value
+		}
+	}
+	setOrDelete("limits.read", read)
+	setOrDelete("limits.write", write)
+	delete(root, "limits.max")
+	inst.Devices["root"] = root
+
+	log.Printf("[LXD Provider] Atualizando limites de I/O para %s (read: %q, write: %q)", name, read, write)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar atualização de limites de I/O: %w", err)
+	}
+
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao aplicar limites de I/O: %w", err)
+	}
+
+	log.Printf("[LXD Provider] Limites de I/O atualizados para %s", name)
+	return nil
+}
+
+// ImageAliasExists valida a imagem contra a allowlist e confirma que o alias existe no LXD.
+func (s *InstanceService) ImageAliasExists(alias string) error {
+	if !service.IsImageAllowed(strings.TrimSuffix(alias, "-vm")) {
+		return fmt.Errorf("%w: %s", service.ErrImageNotAllowed, alias)
+	}
+	if _, _, err := s.server.GetImageAlias(alias); err != nil {
+		return fmt.Errorf("IMAGEM NÃO ENCONTRADA: O alias '%s' não existe no LXD. Rode o script de preload.", alias)
+	}
+	return nil
+}
+
 // CreateInstance cria um novo container ou VM a partir de uma imagem LOCAL com suporte a Cloud-Init.
 func (s *InstanceService) CreateInstance(name string, imageAlias string, instanceType string, limits map[string]string, userData string) error {
+	return s.CreateInstanceWithProgress(name, imageAlias, instanceType, limits, userData, "", nil, nil)
+}
+
+// CreateInstanceWithProgress é igual a CreateInstance, reportando o progresso via callback (pode ser nil).
+// pool vazio usa DefaultStoragePool. operation (pode ser nil) recebe o ID da
+// operação de criação no LXD assim que ela começa, para permitir cancelá-la.
+func (s *InstanceService) CreateInstanceWithProgress(name string, imageAlias string, instanceType string, limits map[string]string, userData string, pool string, progress ProgressFunc, operation OperationFunc) error {
+	report := func(percent int, message string) {
+		if progress != nil {
+			progress(percent, message)
+		}
+	}
+
 	// 1. Lock check
 	if _, busy := s.locks.LoadOrStore(name, true); busy {
 		return fmt.Errorf("LOCKED: %s está ocupado", name)
@@ -399,9 +596,8 @@ func (s *InstanceService) CreateInstance(name string, imageAlias string, instanc
 	}
 
 	// Validação: Verificar se a imagem existe ANTES de tentar
-	_, _, err := s.server.GetImageAlias(finalAlias)
-	if err != nil {
-		return fmt.Errorf("IMAGEM NÃO ENCONTRADA: O alias '%s' não existe no LXD. Rode o script de preload.", finalAlias)
+	if err := s.ImageAliasExists(finalAlias); err != nil {
+		return err
 	}
 
 	// 3. Configuração
@@ -431,7 +627,7 @@ func (s *InstanceService) CreateInstance(name string, imageAlias string, instanc
 		InstancePut: api.InstancePut{
 			Config:   config,
 			Devices: map[string]map[string]string{
-				"root": {"type": "disk", "path": "/", "pool": "axion"},
+				"root": {"type": "disk", "path": "/", "pool": poolOrDefault(pool)},
 				"eth0": {"type": "nic", "name": "eth0", "network": "axion-br"},
 			},
 			Profiles: []string{"default"},
@@ -441,10 +637,15 @@ func (s *InstanceService) CreateInstance(name string, imageAlias string, instanc
 	log.Printf("[Create] Iniciando criação de %s (%s) com imagem '%s'...", name, instanceType, finalAlias)
 
 	// 4. Execução Assíncrona
+	report(0, "creating instance")
 	op, err := s.server.CreateInstance(req)
 	if err != nil {
 		return fmt.Errorf("LXD recusou a request: %w", err)
 	}
+	if operation != nil {
+		operation(op.Get().ID)
+	}
+	trackProgress(op, progress)
 
 	// 5. Esperar a Operação (Aqui que a VM demora 10s+)
 	log.Printf("[Create] Aguardando operação do LXD...")
@@ -461,6 +662,7 @@ func (s *InstanceService) CreateInstance(name string, imageAlias string, instanc
 
 	// 7. Auto-Start: Iniciar a instância imediatamente
 	log.Printf("[Create] Iniciando boot de %s...", name)
+	report(95, "starting instance")
 	reqState := api.InstanceStatePut{
 		Action:  "start",
 		Timeout: -1,
@@ -479,8 +681,11 @@ func (s *InstanceService) CreateInstance(name string, imageAlias string, instanc
 	return nil
 }
 
-// CreateInstanceWithISO creates a new VM with an ISO file for installation
-func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string, instanceType string, limits map[string]string, userData string, isoPath string) error {
+// CreateInstanceWithISO creates a new VM with an ISO file for installation.
+// An empty pool uses DefaultStoragePool.
+// rootSize define o tamanho do disco vazio (ex: "20GB"); vazio usa o padrão do pool.
+// operation, se não for nil, recebe o ID da operação de criação.
+func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string, instanceType string, limits map[string]string, userData string, isoPath string, pool string, rootSize string, operation OperationFunc) error {
 	// 1. Lock check
 	if _, busy := s.locks.LoadOrStore(name, true); busy {
 		return fmt.Errorf("LOCKED: %s está ocupado", name)
@@ -520,6 +725,20 @@ func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string,
 		config["cloud-init.network-config"] = "version: 2\nethernets:\n  all-interfaces:\n    match:\n      name: \"e*\"\n    dhcp4: true"
 	}
 
+	rootDevice := map[string]string{
+		"type": "disk",
+		"path": "/",
+		"pool": poolOrDefault(pool),
+	}
+	if rootSize != "" {
+		rootDevice["size"] = rootSize
+	}
+
+	// ISO como disco de boot. Sem "pool": source é um caminho do host, com pool
+	// o LXD procuraria um volume custom com esse nome.
+	isoDevice := ISODeviceConfig(isoPath)
+	isoDevice["type"] = "disk"
+
 	req := api.InstancesPost{
 		Name: name,
 		Type: api.InstanceType(instanceType), // Vital for VM
@@ -529,23 +748,14 @@ func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string,
 		InstancePut: api.InstancePut{
 			Config: config,
 			Devices: map[string]map[string]string{
-				"root": {
-					"type": "disk",
-					"path": "/",
-					"pool": "axion",
-				},
+				"root": rootDevice,
 				"eth0": {
 					"type":    "nic",
 					"name":    "eth0",
 					"network": "axion-br",
 				},
 				// Add ISO device as bootable disk
-				"iso": {
-					"type":    "disk",
-					"source":  isoPath,       // Path to the ISO file
-					"pool":    "axion",       // Use the default storage pool
-					"boot.priority": "10",    // High boot priority for ISO
-				},
+				ISODeviceName: isoDevice,
 			},
 			Profiles: []string{"default"},
 		},
@@ -558,6 +768,9 @@ func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string,
 	if err != nil {
 		return fmt.Errorf("LXD recusou a request para VM com ISO: %w", err)
 	}
+	if operation != nil {
+		operation(op.Get().ID)
+	}
 
 	// 6. Esperar a Operação
 	log.Printf("[Create] Aguardando operação do LXD...")
@@ -591,49 +804,11 @@ func (s *InstanceService) CreateInstanceWithISO(name string, imageAlias string,
 	log.Printf("[Create] Sucesso confirmado para VM com ISO: %s", name)
 	return nil
 }
-func (s *InstanceService) DeleteInstance(name string) error {
-	if _, busy := s.locks.LoadOrStore(name, true); busy {
-		return fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
-	}
-	defer s.locks.Delete(name)
 
-	log.Printf("[LXD Provider] Iniciando exclusão de '%s'", name)
-
-	inst, _, err := s.server.GetInstance(name)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			return nil
-		}
-		return fmt.Errorf("falha ao verificar container: %w", err)
-	}
-
-	if strings.ToUpper(inst.Status) == "RUNNING" {
-		log.Printf("[LXD Provider] Parando '%s' antes da exclusão...", name)
-		req := api.InstanceStatePut{
-			Action:  "stop",
-			Timeout: -1,
-			Force:   true,
-		}
-		op, err := s.server.UpdateInstanceState(name, req, "")
-		if err != nil {
-			return fmt.Errorf("falha ao parar container: %w", err)
-		}
-		if err := op.Wait(); err != nil {
-			return fmt.Errorf("erro ao aguardar parada do container: %w", err)
-		}
-	}
-
-	op, err := s.server.DeleteInstance(name)
-	if err != nil {
-		return fmt.Errorf("falha ao solicitar exclusão: %w", err)
-	}
-
-	if err := op.Wait(); err != nil {
-		return fmt.Errorf("erro durante a exclusão do container: %w", err)
-	}
-
-	log.Printf("[LXD Provider] Container '%s' excluído com sucesso", name)
-	return nil
+// DeleteInstance para a instância à força e a exclui
+func (s *InstanceService) DeleteInstance(name string) error {
+	_, err := s.DeleteInstanceGraceful(name, 0)
+	return err
 }
 
 // --- Snapshot Management ---
@@ -753,15 +928,15 @@ func (s *InstanceService) AddProxyDevice(instanceName string, hostPort int, cont
 	}
 	defer s.locks.Delete(instanceName)
 
-	if err := s.CheckPortAvailability(hostPort); err != nil {
-		return err
+	if !IsPortProtocol(protocol) {
+		return fmt.Errorf("protocolo inválido: %s. Use 'tcp' ou 'udp'", protocol)
 	}
 
-	if protocol != "tcp" && protocol != "udp" {
-		return fmt.Errorf("protocolo inválido: %s. Use 'tcp' ou 'udp'", protocol)
+	if err := s.CheckPortAvailability(hostPort, protocol); err != nil {
+		return err
 	}
 
-	deviceName := fmt.Sprintf("proxy-%d", hostPort)
+	deviceName := proxyDeviceName(hostPort, protocol)
 	log.Printf("[LXD Provider] Adicionando Port Forward: Host:%d -> Container:%d (%s)", hostPort, containerPort, protocol)
 
 	inst, etag, err := s.server.GetInstance(instanceName)
@@ -843,13 +1018,15 @@ func (s *InstanceService) AddProxyDevice(instanceName string, hostPort int, cont
 	return nil
 }
 
-func (s *InstanceService) RemoveProxyDevice(instanceName string, hostPort int) error {
+// RemoveProxyDevice remove o redirecionamento de hostPort no protocolo dado,
+// sem tocar na regra do outro protocolo na mesma porta.
+func (s *InstanceService) RemoveProxyDevice(instanceName string, hostPort int, protocol string) error {
 	if _, busy := s.locks.LoadOrStore(instanceName, true); busy {
 		return fmt.Errorf("LOCKED: container '%s' já está sendo operado", instanceName)
 	}
 	defer s.locks.Delete(instanceName)
 
-	deviceName := fmt.Sprintf("proxy-%d", hostPort)
+	deviceName := proxyDeviceName(hostPort, protocol)
 	log.Printf("[LXD Provider] Removendo Port Forward: %s", deviceName)
 
 	inst, etag, err := s.server.GetInstance(instanceName)
@@ -858,7 +1035,7 @@ func (s *InstanceService) RemoveProxyDevice(instanceName string, hostPort int) e
 	}
 
 	if _, exists := inst.Devices[deviceName]; !exists {
-		return fmt.Errorf("porta %d não encontrada (device %s)", hostPort, deviceName)
+		return fmt.Errorf("%w: %d/%s (device %s)", ErrPortNotFound, hostPort, protocol, deviceName)
 	}
 
 	delete(inst.Devices, deviceName)
@@ -887,8 +1064,16 @@ func (s *InstanceService) RemoveProxyDevice(instanceName string, hostPort int) e
 
 // --- File System (Explorer) ---
 
-// ListFiles lista arquivos e diretórios em um caminho específico.
-func (s *InstanceService) ListFiles(instanceName string, path string) ([]FileEntry, error) {
+// ListFiles lista uma página do diretório, ordenada com diretórios primeiro e
+// depois por nome, para que offset/limit sejam estáveis entre chamadas
+// (limit 0 devolve tudo a partir de offset). Symlinks aparecem como tal (com
+// o alvo) e não são seguidos na listagem.
+func (s *InstanceService) ListFiles(instanceName string, path string, offset, limit int) (*FilePage, error) {
+	path, err := s.resolveExplorerPath(instanceName, path, true)
+	if err != nil {
+		return nil, err
+	}
+
 	// First, check if the path itself is a directory
 	content, resp, err := s.server.GetInstanceFile(instanceName, path)
 	if err != nil {
@@ -903,31 +1088,26 @@ func (s *InstanceService) ListFiles(instanceName string, path string) ([]FileEnt
 		return nil, fmt.Errorf("o caminho '%s' não é um diretório (tipo retornado: %s)", path, resp.Type)
 	}
 
-	var entries []FileEntry
-	for _, entryName := range resp.Entries {
-		entryPath := path
-		if entryPath == "/" {
-			entryPath += entryName
-		} else {
-			entryPath += "/" + entryName
-		}
-
-		_, entryResp, err := s.server.GetInstanceFile(instanceName, entryPath) // get info for each entry
-		if err != nil {
-			log.Printf("Warning: Failed to get info for '%s': %v", entryPath, err)
-			// If we can't get info for an entry, we can default to file or skip. Skipping for now.
-			continue
-		}
-		entries = append(entries, FileEntry{
-			Name: entryName,
-			Type: entryResp.Type,
-		})
+	// Um único find traz o tipo de todas as entradas; sem ele (ex.: BusyBox
+	// ou VM sem agente) cada entrada é consultada pela API de arquivos
+	entries, err := s.listDirectory(instanceName, path)
+	if err != nil {
+		entries = s.lstatEntries(instanceName, path, resp.Entries)
 	}
-	return entries, nil
+
+	sortFileEntries(entries)
+	page, hasMore := pageFileEntries(entries, offset, limit)
+	return &FilePage{Path: path, Entries: page, Total: len(entries), HasMore: hasMore}, nil
 }
 
-// DownloadFile baixa o conteúdo de um arquivo.
+// DownloadFile baixa o conteúdo de um arquivo. Symlinks são seguidos apenas
+// enquanto o alvo ficar dentro de ExplorerRoot.
 func (s *InstanceService) DownloadFile(instanceName string, path string) (io.ReadCloser, int64, error) {
+	path, err := s.resolveExplorerPath(instanceName, path, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	content, resp, err := s.server.GetInstanceFile(instanceName, path)
 	if err != nil {
 		return nil, 0, fmt.Errorf("falha ao baixar arquivo: %w", err)
@@ -971,11 +1151,17 @@ func (s *InstanceService) UploadFile(instanceName string, path string, content i
 	return nil
 }
 
-// DeleteFile deleta um arquivo ou diretório.
+// DeleteFile deleta um arquivo ou diretório. Um symlink é removido sem tocar
+// no alvo; diretórios intermediários não podem sair de ExplorerRoot.
 func (s *InstanceService) DeleteFile(instanceName string, path string) error {
+	path, err := s.resolveExplorerPath(instanceName, path, false)
+	if err != nil {
+		return err
+	}
+
 	log.Printf("[LXD Provider] Deletando arquivo '%s:%s'", instanceName, path)
 
-	err := s.server.DeleteInstanceFile(instanceName, path)
+	err = s.server.DeleteInstanceFile(instanceName, path)
 	if err != nil {
 		return fmt.Errorf("falha ao deletar arquivo: %w", err)
 	}
@@ -998,11 +1184,94 @@ func (s *InstanceService) GetInstanceLog(instanceName string) (string, error) {
 	return string(content), nil
 }
 
+// ErrConsoleLogUnavailable indica que a instância não expõe um console log (ex.: container sem ring buffer)
+var ErrConsoleLogUnavailable = errors.New("console log not available for this instance")
+
+// GetConsoleLog retorna o ring buffer do console (boot) da instância.
+// Para VMs é a saída serial desde o último boot; containers só têm o buffer
+// quando o LXD/liblxc suporta, caso contrário retorna ErrConsoleLogUnavailable.
+func (s *InstanceService) GetConsoleLog(instanceName string) (string, error) {
+	inst, _, err := s.server.GetInstance(instanceName)
+	if err != nil {
+		return "", fmt.Errorf("falha ao obter instância '%s': %w", instanceName, err)
+	}
+
+	logFile, err := s.server.GetInstanceConsoleLog(instanceName, &lxd.InstanceConsoleLogArgs{})
+	if err != nil {
+		if inst.Type != string(api.InstanceTypeVM) {
+			return "", fmt.Errorf("%w: %v", ErrConsoleLogUnavailable, err)
+		}
+		return "", fmt.Errorf("falha ao obter console log de '%s': %w", instanceName, err)
+	}
+	defer logFile.Close()
+
+	content, err := io.ReadAll(logFile)
+	if err != nil {
+		return "", fmt.Errorf("falha ao ler console log de '%s': %w", instanceName, err)
+	}
+
+	return string(content), nil
+}
+
+// InstanceConfig é a visão completa (sem curadoria) do que o LXD sabe sobre a instância.
+type InstanceConfig struct {
+	Name            string                       `json:"name"`
+	Type            string                       `json:"type"`
+	Status          string                       `json:"status"`
+	Architecture    string                       `json:"architecture"`
+	Profiles        []string                     `json:"profiles"`
+	Config          map[string]string            `json:"config"`
+	Devices         map[string]map[string]string `json:"devices"`
+	ExpandedConfig  map[string]string            `json:"expanded_config"`
+	ExpandedDevices map[string]map[string]string `json:"expanded_devices"`
+	Ephemeral       bool                         `json:"ephemeral"`
+	Stateful        bool                         `json:"stateful"`
+	Location        string                       `json:"location,omitempty"`
+	ETag            string                       `json:"etag"`
+}
+
+// GetInstanceConfig devolve config, devices, expanded devices e profiles exatamente
+// como retornados pela API do LXD. Nada é omitido ou mascarado.
+func (s *InstanceService) GetInstanceConfig(name string) (*InstanceConfig, error) {
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstanceConfig{
+		Name:            inst.Name,
+		Type:            inst.Type,
+		Status:          inst.Status,
+		Architecture:    inst.Architecture,
+		Profiles:        inst.Profiles,
+		Config:          inst.Config,
+		Devices:         inst.Devices,
+		ExpandedConfig:  inst.ExpandedConfig,
+		ExpandedDevices: inst.ExpandedDevices,
+		Ephemeral:       inst.Ephemeral,
+		Stateful:        inst.Stateful,
+		Location:        inst.Location,
+		ETag:            etag,
+	}, nil
+}
+
 // Server returns the underlying LXD server client
 func (s *InstanceService) Server() lxd.InstanceServer {
 	return s.server
 }
 
+// InstanceExists consulta o LXD; erros diferentes de "not found" são devolvidos
+func (s *InstanceService) InstanceExists(name string) (bool, error) {
+	_, _, err := s.server.GetInstance(name)
+	if err == nil {
+		return true, nil
+	}
+	if api.StatusErrorCheck(err, http.StatusNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
 // GetInstanceState wraps the LXD server's GetInstanceState method
 func (s *InstanceService) GetInstanceState(instanceName string) (*api.InstanceState, string, error) {
 	return s.server.GetInstanceState(instanceName)
@@ -0,0 +1,87 @@
+package lxc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// CloneInstance copia source para target no mesmo servidor (cópia do LXD:
+// disco, config e devices; MAC e demais chaves volatile são regeradas). Com
+// copySnapshots os snapshots de source vão junto. O clone fica parado.
+// Os redirecionamentos de porta (proxy-<porta>) ficam só na origem, já que a
+// porta do host é dela, e o eth0 do clone recebe address no lugar do IP fixo
+// da origem ("" usa o endereço dinâmico). operation, se não for nil, recebe o
+// ID da operação de cópia.
+func (s *InstanceService) CloneInstance(source, target string, copySnapshots bool, address string, operation OperationFunc) error {
+	if _, busy := s.locks.LoadOrStore(target, true); busy {
+		return fmt.Errorf("LOCKED: %s está ocupado", target)
+	}
+	defer s.locks.Delete(target)
+
+	inst, _, err := s.server.GetInstance(source)
+	if err != nil {
+		return fmt.Errorf("falha ao obter instância de origem '%s': %w", source, err)
+	}
+	inst.Devices = cloneDevices(inst, address)
+
+	log.Printf("[LXD Provider] Clonando '%s' em '%s' (snapshots: %v)", source, target, copySnapshots)
+
+	op, err := s.server.CopyInstance(s.server, *inst, &lxd.InstanceCopyArgs{
+		Name:         target,
+		InstanceOnly: !copySnapshots,
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar cópia de '%s': %w", source, err)
+	}
+	if operation != nil {
+		if remote, err := op.GetTarget(); err == nil && remote != nil {
+			operation(remote.ID)
+		}
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro durante a cópia de '%s' para '%s': %w", source, target, err)
+	}
+
+	log.Printf("[LXD Provider] Clone '%s' criado a partir de '%s'", target, source)
+	return nil
+}
+
+// cloneDevices devolve os devices locais de inst para o clone: sem os proxies
+// de porta e com o ipv4.address do eth0 trocado por address. Um eth0 herdado
+// do profile só é sobrescrito quando há endereço a fixar.
+func cloneDevices(inst *api.Instance, address string) map[string]map[string]string {
+	devices := make(map[string]map[string]string, len(inst.Devices))
+	for name, device := range inst.Devices {
+		if device["type"] == "proxy" && strings.HasPrefix(name, "proxy-") {
+			continue
+		}
+		copied := make(map[string]string, len(device))
+		for k, v := range device {
+			copied[k] = v
+		}
+		devices[name] = copied
+	}
+
+	eth0, ok := devices["eth0"]
+	if !ok {
+		expanded, found := inst.ExpandedDevices["eth0"]
+		if !found || expanded["type"] != "nic" || address == "" {
+			return devices
+		}
+		eth0 = make(map[string]string, len(expanded)+1)
+		for k, v := range expanded {
+			eth0[k] = v
+		}
+		devices["eth0"] = eth0
+	}
+	if address == "" {
+		delete(eth0, "ipv4.address")
+	} else {
+		eth0["ipv4.address"] = address
+	}
+	return devices
+}
@@ -0,0 +1,52 @@
+package lxc
+
+import (
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestCloneDevices(t *testing.T) {
+	inst := &api.Instance{
+		Devices: map[string]map[string]string{
+			"eth0":          {"type": "nic", "network": "axion-br", "ipv4.address": "10.0.0.5"},
+			"proxy-8080":    {"type": "proxy", "listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:80"},
+			"proxy-udp-53":  {"type": "proxy", "listen": "udp:0.0.0.0:53", "connect": "udp:127.0.0.1:53"},
+			"gpu0":          {"type": "gpu"},
+			"proxy-sidecar": {"type": "disk", "source": "/srv/shared", "path": "/data"},
+		},
+	}
+
+	got := cloneDevices(inst, "10.0.0.9")
+	for _, name := range []string{"proxy-8080", "proxy-udp-53"} {
+		if _, ok := got[name]; ok {
+			t.Errorf("port device %s copied to the clone", name)
+		}
+	}
+	if _, ok := got["gpu0"]; !ok {
+		t.Error("gpu0 dropped")
+	}
+	if _, ok := got["proxy-sidecar"]; !ok {
+		t.Error("non-proxy device named proxy-* dropped")
+	}
+	if got["eth0"]["ipv4.address"] != "10.0.0.9" {
+		t.Errorf("eth0 = %v, want the clone's address", got["eth0"])
+	}
+	if inst.Devices["eth0"]["ipv4.address"] != "10.0.0.5" {
+		t.Error("source devices modified")
+	}
+
+	if got := cloneDevices(inst, ""); got["eth0"]["ipv4.address"] != "" {
+		t.Errorf("eth0 = %v, want no fixed address", got["eth0"])
+	}
+
+	inherited := &api.Instance{
+		ExpandedDevices: map[string]map[string]string{"eth0": {"type": "nic", "network": "axion-br"}},
+	}
+	if got := cloneDevices(inherited, ""); len(got) != 0 {
+		t.Errorf("profile eth0 overridden without an address: %v", got)
+	}
+	if got := cloneDevices(inherited, "10.0.0.9"); got["eth0"]["ipv4.address"] != "10.0.0.9" || got["eth0"]["network"] != "axion-br" {
+		t.Errorf("eth0 = %v, want the profile NIC with the clone's address", got["eth0"])
+	}
+}
@@ -0,0 +1,202 @@
+package lxc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CLOUD-INIT
+// ============================================================================
+
+const userDataKey = "user.user-data"
+
+// Estados do cloud-init gravados em Instance.CloudInitStatus
+const (
+	CloudInitRunning  = "running"
+	CloudInitDone     = "done"
+	CloudInitError    = "error"
+	CloudInitDisabled = "disabled" // imagem sem cloud-init (ou desativado): pronta de imediato
+	// CloudInitProvisioningFailed é gravado no lugar de CloudInitError depois
+	// da criação: a instância roda, mas o provisionamento não terminou
+	CloudInitProvisioningFailed = "provisioning-failed"
+)
+
+// CloudInitFailedCode é o error_code dos jobs cuja instância falhou no cloud-init
+const CloudInitFailedCode = "cloud_init_failed"
+
+// Log lido quando o cloud-init termina em erro, e quantas linhas do final guardar
+const (
+	cloudInitLogPath  = "/var/log/cloud-init.log"
+	CloudInitLogLines = 50
+)
+
+const cloudInitPollInterval = 5 * time.Second
+
+// ReapplyCloudInit grava um novo user-data na instância e força o cloud-init a
+// rodar de novo: limpa o estado (clean --logs) e reinicia a instância.
+// ATENÇÃO: módulos "per-instance" (usuários, pacotes, runcmd...) serão executados
+// novamente, o que pode sobrescrever alterações feitas manualmente.
+func (s *InstanceService) ReapplyCloudInit(name string, userData string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está processando um comando. Tente novamente em alguns segundos", name)
+	}
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		s.locks.Delete(name)
+		return fmt.Errorf("falha ao obter configuração atual de %s: %w", name, err)
+	}
+
+	inst.Config[userDataKey] = userData
+	wasRunning := strings.EqualFold(inst.Status, "Running")
+
+	log.Printf("[LXD Provider] Atualizando user-data de %s para reaplicar cloud-init", name)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err == nil {
+		err = op.Wait()
+	}
+	// O lock é liberado antes do restart, que adquire o seu próprio
+	s.locks.Delete(name)
+	if err != nil {
+		return fmt.Errorf("falha ao gravar user-data: %w", err)
+	}
+
+	// O clean precisa rodar dentro da instância, então ela tem que estar ligada
+	if !wasRunning {
+		if err := s.UpdateInstanceState(name, "start"); err != nil {
+			return fmt.Errorf("falha ao iniciar instância para o clean: %w", err)
+		}
+		if err := s.waitForExec(name, 60*time.Second); err != nil {
+			return err
+		}
+	}
+
+	result, err := s.ExecCommand(name, []string{"cloud-init", "clean", "--logs"})
+	if err != nil {
+		return fmt.Errorf("falha ao executar cloud-init clean: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("cloud-init clean retornou %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	if err := s.UpdateInstanceState(name, "restart"); err != nil {
+		return fmt.Errorf("falha ao reiniciar instância: %w", err)
+	}
+
+	log.Printf("[LXD Provider] Cloud-init reaplicado em %s (reboot solicitado)", name)
+	return nil
+}
+
+// CloudInitFailure indica que a instância foi criada mas o cloud-init terminou
+// em erro. Log traz o final de /var/log/cloud-init.log (vazio se ilegível).
+type CloudInitFailure struct {
+	Instance string
+	Log      string
+}
+
+func (e *CloudInitFailure) Error() string {
+	if e.Log == "" {
+		return fmt.Sprintf("cloud-init falhou em %s (log indisponível)", e.Instance)
+	}
+	return fmt.Sprintf("cloud-init falhou em %s; final de %s:\n%s", e.Instance, cloudInitLogPath, e.Log)
+}
+
+// CloudInitLogTail devolve as últimas lines linhas de /var/log/cloud-init.log
+func (s *InstanceService) CloudInitLogTail(ctx context.Context, name string, lines int) (string, error) {
+	result, err := s.ExecCommandContext(ctx, name, []string{"tail", "-n", strconv.Itoa(lines), cloudInitLogPath})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("tail %s retornou %d: %s", cloudInitLogPath, result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return strings.TrimRight(result.Stdout, "\n"), nil
+}
+
+// waitForExec aguarda até o agente/init da instância aceitar comandos.
+// VMs recém-ligadas levam alguns segundos até o lxd-agent responder.
+func (s *InstanceService) waitForExec(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := s.ExecCommand(name, []string{"true"}); err == nil {
+			return nil
+		} else if time.Now().After(deadline) {
+			return fmt.Errorf("instância %s não respondeu a tempo: %w", name, err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// WaitCloudInit acompanha o "cloud-init status" até o provisionamento terminar
+// e devolve o estado final. Se ctx expirar antes, devolve CloudInitRunning
+// junto com o erro de ctx.
+func (s *InstanceService) WaitCloudInit(ctx context.Context, name string, progress ProgressFunc) (string, error) {
+	if err := s.waitForExec(name, 60*time.Second); err != nil {
+		return "", err
+	}
+
+	if progress != nil {
+		progress(97, "waiting for cloud-init")
+	}
+
+	for {
+		result, err := s.ExecCommandContext(ctx, name, []string{"cloud-init", "status"})
+		if ctx.Err() != nil {
+			return CloudInitRunning, ctx.Err()
+		}
+		if err != nil {
+			return "", fmt.Errorf("falha ao consultar cloud-init em %s: %w", name, err)
+		}
+
+		if status := parseCloudInitStatus(result.Stdout, result.ExitCode); status != CloudInitRunning {
+			log.Printf("[LXD Provider] Cloud-init em %s terminou: %s", name, status)
+			if progress != nil {
+				progress(99, "cloud-init "+status)
+			}
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return CloudInitRunning, ctx.Err()
+		case <-time.After(cloudInitPollInterval):
+		}
+	}
+}
+
+// parseCloudInitStatus interpreta a saída de "cloud-init status" ("status: done").
+// Exit code 127 (comando inexistente) significa imagem sem cloud-init.
+func parseCloudInitStatus(stdout string, exitCode int) string {
+	if exitCode == 127 {
+		return CloudInitDisabled
+	}
+
+	for _, line := range strings.Split(stdout, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "status:")
+		if !ok {
+			continue
+		}
+		switch value = strings.TrimSpace(value); {
+		case value == "done" || value == "degraded done":
+			return CloudInitDone
+		case value == "error" || value == "degraded error":
+			return CloudInitError
+		case value == "disabled":
+			return CloudInitDisabled
+		default: // "running", "not started"
+			return CloudInitRunning
+		}
+	}
+
+	// Sem linha de status: versões antigas só sinalizam pelo exit code
+	if exitCode == 1 {
+		return CloudInitError
+	}
+	return CloudInitRunning
+}
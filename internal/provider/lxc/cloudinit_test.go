@@ -0,0 +1,26 @@
+package lxc
+
+import "testing"
+
+func TestParseCloudInitStatus(t *testing.T) {
+	cases := []struct {
+		stdout   string
+		exitCode int
+		want     string
+	}{
+		{stdout: "status: done\n", want: CloudInitDone},
+		{stdout: "status: degraded done\n", exitCode: 2, want: CloudInitDone},
+		{stdout: "status: running\n", want: CloudInitRunning},
+		{stdout: "status: not started\n", want: CloudInitRunning},
+		{stdout: "status: error\n", exitCode: 1, want: CloudInitError},
+		{stdout: "status: disabled\n", want: CloudInitDisabled},
+		{stdout: "", exitCode: 127, want: CloudInitDisabled},
+		{stdout: "", exitCode: 1, want: CloudInitError},
+	}
+
+	for _, tc := range cases {
+		if got := parseCloudInitStatus(tc.stdout, tc.exitCode); got != tc.want {
+			t.Errorf("parseCloudInitStatus(%q, %d) = %q, want %q", tc.stdout, tc.exitCode, got, tc.want)
+		}
+	}
+}
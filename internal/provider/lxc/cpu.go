@@ -0,0 +1,75 @@
+package lxc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// CPU ALLOWANCE / PRIORITY
+// ============================================================================
+
+// Chaves de CPU ponderada do LXD. limits.cpu limita a quantidade de núcleos;
+// estas dividem o tempo de CPU sem fixar núcleos, permitindo oversubscription.
+const (
+	CPUAllowanceKey = "limits.cpu.allowance"
+	CPUPriorityKey  = "limits.cpu.priority"
+)
+
+// Valores efetivos quando as chaves não estão definidas (padrão do LXD)
+const (
+	DefaultCPUAllowance = "100%"
+	DefaultCPUPriority  = 10
+)
+
+// ValidateCPUAllowance aceita "50%" (fatia relativa, 1-100) ou "25ms/100ms"
+// (cota fixa por período, em milissegundos).
+func ValidateCPUAllowance(allowance string) error {
+	if pct, ok := strings.CutSuffix(allowance, "%"); ok {
+		n, err := strconv.Atoi(pct)
+		if err != nil || n < 1 || n > 100 {
+			return fmt.Errorf("allowance %q: percentual deve estar entre 1%% e 100%%", allowance)
+		}
+		return nil
+	}
+
+	quota, period, ok := strings.Cut(allowance, "/")
+	if !ok {
+		return fmt.Errorf("allowance %q: use \"50%%\" ou \"25ms/100ms\"", allowance)
+	}
+	for _, part := range []string{quota, period} {
+		ms, isMs := strings.CutSuffix(part, "ms")
+		n, err := strconv.Atoi(ms)
+		if !isMs || err != nil || n < 1 {
+			return fmt.Errorf("allowance %q: cota e período devem ser milissegundos positivos (ex.: 25ms/100ms)", allowance)
+		}
+	}
+	return nil
+}
+
+// ValidateCPUPriority aceita a prioridade do LXD, de 0 (menor) a 10 (maior).
+func ValidateCPUPriority(priority int) error {
+	if priority < 0 || priority > 10 {
+		return fmt.Errorf("priority deve estar entre 0 e 10, recebido %d", priority)
+	}
+	return nil
+}
+
+// validateCPUConfig checa os valores das chaves de CPU ponderada presentes
+// em config. Valor vazio remove a chave e é sempre aceito.
+func validateCPUConfig(config map[string]string) error {
+	if v := config[CPUAllowanceKey]; v != "" {
+		if err := ValidateCPUAllowance(v); err != nil {
+			return err
+		}
+	}
+	if v := config[CPUPriorityKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("priority %q não é um inteiro", v)
+		}
+		return ValidateCPUPriority(n)
+	}
+	return nil
+}
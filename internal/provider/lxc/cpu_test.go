@@ -0,0 +1,32 @@
+package lxc
+
+import "testing"
+
+func TestValidateCPUAllowance(t *testing.T) {
+	for _, v := range []string{"50%", "1%", "100%", "25ms/100ms", "200ms/100ms"} {
+		if err := ValidateCPUAllowance(v); err != nil {
+			t.Errorf("%q: unexpected error: %v", v, err)
+		}
+	}
+	for _, v := range []string{"", "0%", "150%", "50", "25ms", "25/100", "0ms/100ms", "25ms/100s", "-5ms/10ms"} {
+		if err := ValidateCPUAllowance(v); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestValidateRawConfigChecksCPUValues(t *testing.T) {
+	if err := ValidateRawConfig(map[string]string{CPUAllowanceKey: "50%", CPUPriorityKey: "3"}); err != nil {
+		t.Fatalf("valid CPU keys rejected: %v", err)
+	}
+	// Empty values unset the keys
+	if err := ValidateRawConfig(map[string]string{CPUAllowanceKey: "", CPUPriorityKey: ""}); err != nil {
+		t.Fatalf("empty CPU keys rejected: %v", err)
+	}
+	if err := ValidateRawConfig(map[string]string{CPUAllowanceKey: "half"}); err == nil {
+		t.Error("invalid allowance accepted")
+	}
+	if err := ValidateRawConfig(map[string]string{CPUPriorityKey: "11"}); err == nil {
+		t.Error("out of range priority accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package lxc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Caminho de desligamento seguido por DeleteInstanceGraceful
+const (
+	ShutdownGraceful      = "graceful"       // a instância desligou sozinha dentro do prazo
+	ShutdownForced        = "forced"         // force pedido (ou prazo zero): parada imediata
+	ShutdownTimeoutForced = "timeout_forced" // o prazo esgotou e a parada foi forçada
+	ShutdownNotRunning    = "not_running"    // já estava parada, nada a desligar
+)
+
+// DeleteInstanceGraceful pede um desligamento limpo (ACPI/SIGPWR) e espera até
+// grace antes de forçar a parada; depois exclui a instância. grace <= 0 força
+// direto. Retorna o caminho seguido; instância inexistente não é erro.
+func (s *InstanceService) DeleteInstanceGraceful(name string, grace time.Duration) (string, error) {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return "", fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
+	}
+	defer s.locks.Delete(name)
+
+	log.Printf("[LXD Provider] Iniciando exclusão de '%s'", name)
+
+	inst, _, err := s.server.GetInstance(name)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ShutdownNotRunning, nil
+		}
+		return "", fmt.Errorf("falha ao verificar container: %w", err)
+	}
+
+	shutdown := ShutdownNotRunning
+	if strings.ToUpper(inst.Status) == "RUNNING" {
+		shutdown, err = s.shutdown(name, grace)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	op, err := s.server.DeleteInstance(name)
+	if err != nil {
+		return "", fmt.Errorf("falha ao solicitar exclusão: %w", err)
+	}
+
+	if err := op.Wait(); err != nil {
+		return "", fmt.Errorf("erro durante a exclusão do container: %w", err)
+	}
+
+	log.Printf("[LXD Provider] Container '%s' excluído com sucesso (desligamento: %s)", name, shutdown)
+	return shutdown, nil
+}
+
+// shutdown tenta a parada limpa dentro de grace e, se a instância continuar
+// rodando, força. O chamador já detém o lock da instância.
+func (s *InstanceService) shutdown(name string, grace time.Duration) (string, error) {
+	if grace > 0 {
+		seconds := int((grace + time.Second - 1) / time.Second)
+		log.Printf("[LXD Provider] Desligando '%s' (prazo de %ds)...", name, seconds)
+		err := s.stop(name, seconds, false)
+		if err == nil {
+			return ShutdownGraceful, nil
+		}
+
+		// Prazo esgotado ou desligamento recusado: só força se ainda estiver rodando
+		inst, _, getErr := s.server.GetInstance(name)
+		if getErr != nil {
+			return "", fmt.Errorf("falha ao verificar container: %w", getErr)
+		}
+		if strings.ToUpper(inst.Status) != "RUNNING" {
+			return ShutdownGraceful, nil
+		}
+		log.Printf("[LXD Provider] '%s' não desligou em %ds, forçando parada: %v", name, seconds, err)
+		if err := s.stop(name, -1, true); err != nil {
+			return "", err
+		}
+		return ShutdownTimeoutForced, nil
+	}
+
+	log.Printf("[LXD Provider] Parando '%s' à força antes da exclusão...", name)
+	if err := s.stop(name, -1, true); err != nil {
+		return "", err
+	}
+	return ShutdownForced, nil
+}
+
+func (s *InstanceService) stop(name string, timeout int, force bool) error {
+	op, err := s.server.UpdateInstanceState(name, api.InstanceStatePut{
+		Action:  "stop",
+		Timeout: timeout,
+		Force:   force,
+	}, "")
+	if err != nil {
+		return fmt.Errorf("falha ao parar container: %w", err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao aguardar parada do container: %w", err)
+	}
+	return nil
+}
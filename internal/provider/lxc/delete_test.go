@@ -0,0 +1,88 @@
+package lxc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+type doneOp struct {
+	lxd.Operation
+	err error
+}
+
+func (o doneOp) Wait() error { return o.err }
+
+// stopServer simula uma instância que ignora (ou não) o desligamento limpo
+type stopServer struct {
+	lxd.InstanceServer
+	status      string
+	ignoreClean bool
+	stops       []api.InstanceStatePut
+	deleted     bool
+}
+
+func (s *stopServer) GetInstance(name string) (*api.Instance, string, error) {
+	if s.status == "" {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "not found")
+	}
+	return &api.Instance{Name: name, Status: s.status}, "", nil
+}
+
+func (s *stopServer) UpdateInstanceState(name string, req api.InstanceStatePut, etag string) (lxd.Operation, error) {
+	s.stops = append(s.stops, req)
+	if !req.Force && s.ignoreClean {
+		return doneOp{err: errors.New("context deadline exceeded")}, nil
+	}
+	s.status = "Stopped"
+	return doneOp{}, nil
+}
+
+func (s *stopServer) DeleteInstance(name string) (lxd.Operation, error) {
+	s.deleted = true
+	return doneOp{}, nil
+}
+
+func TestDeleteInstanceGraceful(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      string
+		ignoreClean bool
+		grace       time.Duration
+		want        string
+		stops       int
+	}{
+		{name: "clean shutdown", status: "Running", grace: 30 * time.Second, want: ShutdownGraceful, stops: 1},
+		{name: "grace exceeded", status: "Running", ignoreClean: true, grace: time.Second, want: ShutdownTimeoutForced, stops: 2},
+		{name: "force", status: "Running", want: ShutdownForced, stops: 1},
+		{name: "already stopped", status: "Stopped", grace: 30 * time.Second, want: ShutdownNotRunning},
+	}
+
+	for _, tc := range cases {
+		server := &stopServer{status: tc.status, ignoreClean: tc.ignoreClean}
+		s := &InstanceService{server: server}
+
+		got, err := s.DeleteInstanceGraceful("db", tc.grace)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: shutdown = %q, want %q", tc.name, got, tc.want)
+		}
+		if len(server.stops) != tc.stops {
+			t.Errorf("%s: %d stop requests, want %d", tc.name, len(server.stops), tc.stops)
+		}
+		if !server.deleted {
+			t.Errorf("%s: instance not deleted", tc.name)
+		}
+	}
+
+	s := &InstanceService{server: &stopServer{}}
+	if got, err := s.DeleteInstanceGraceful("gone", time.Second); err != nil || got != ShutdownNotRunning {
+		t.Errorf("missing instance: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,156 @@
+package lxc
+
+import (
+	"fmt"
+	"log"
+)
+
+// ============================================================================
+// DEVICE PASSTHROUGH
+// ============================================================================
+
+// AddDevice anexa (ou substitui) um device na instância. É idempotente: se o
+// device já existe com a mesma configuração, nada é alterado.
+func (s *InstanceService) AddDevice(name string, deviceName string, deviceType string, config map[string]string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("falha ao obter instância '%s': %w", name, err)
+	}
+
+	device := make(map[string]string, len(config)+1)
+	for k, v := range config {
+		device[k] = v
+	}
+	device["type"] = deviceType
+
+	if existing, ok := inst.Devices[deviceName]; ok && sameDevice(existing, device) {
+		return nil
+	}
+
+	if inst.Devices == nil {
+		inst.Devices = make(map[string]map[string]string)
+	}
+	inst.Devices[deviceName] = device
+
+	log.Printf("[LXD Provider] Anexando device %s (%s) em %s", deviceName, deviceType, name)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar inclusão do device: %w", err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao anexar device %s: %w", deviceName, err)
+	}
+	return nil
+}
+
+// ISODeviceName é o device de boot criado por CreateInstanceWithISO. Removê-lo
+// (DELETE /instances/:name/devices/iso) desanexa o instalador depois da instalação.
+const ISODeviceName = "iso"
+
+// ISODeviceConfig devolve a configuração (sem "type") do disco que expõe um ISO
+// do host como dispositivo de boot prioritário.
+func ISODeviceConfig(isoPath string) map[string]string {
+	return map[string]string{
+		"source":        isoPath,
+		"boot.priority": "10",
+	}
+}
+
+// RemoveDevice remove um device da instância. Remover um device inexistente não é erro.
+func (s *InstanceService) RemoveDevice(name string, deviceName string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("falha ao obter instância '%s': %w", name, err)
+	}
+
+	if _, ok := inst.Devices[deviceName]; !ok {
+		return nil
+	}
+	delete(inst.Devices, deviceName)
+
+	log.Printf("[LXD Provider] Removendo device %s de %s", deviceName, name)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar remoção do device: %w", err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao remover device %s: %w", deviceName, err)
+	}
+	return nil
+}
+
+func sameDevice(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SetNICAddress fixa o IPv4 do NIC (ipv4.address) para o DHCP do bridge
+// entregar esse endereço; "" volta ao endereço dinâmico. Como em
+// UpdateRootDiskLimits, um NIC herdado do profile é sobrescrito na instância.
+// A instância em execução recebe o novo IP na renovação do lease.
+func (s *InstanceService) SetNICAddress(name string, nic string, address string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("falha ao obter instância '%s': %w", name, err)
+	}
+
+	device, ok := inst.Devices[nic]
+	if !ok {
+		expanded, found := inst.ExpandedDevices[nic]
+		if !found || expanded["type"] != "nic" {
+			return fmt.Errorf("instância %s não possui o NIC %s", name, nic)
+		}
+		device = make(map[string]string, len(expanded)+1)
+		for k, v := range expanded {
+			device[k] = v
+		}
+	}
+
+	if device["ipv4.address"] == address {
+		return nil
+	}
+	if address == "" {
+		delete(device, "ipv4.address")
+	} else {
+		device["ipv4.address"] = address
+	}
+	if inst.Devices == nil {
+		inst.Devices = make(map[string]map[string]string)
+	}
+	inst.Devices[nic] = device
+
+	log.Printf("[LXD Provider] Alterando IPv4 de %s/%s para %q", name, nic, address)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar alteração do NIC: %w", err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao reconfigurar NIC %s: %w", nic, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package lxc
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"aexon/internal/events"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// LXD EVENT FORWARDING
+// ============================================================================
+
+// lifecycleActions são as ações de ciclo de vida repassadas ao barramento.
+// Snapshots, backups, logs e afins ficam de fora para não gerar ruído.
+var lifecycleActions = map[string]bool{
+	"instance-created":   true,
+	"instance-deleted":   true,
+	"instance-started":   true,
+	"instance-stopped":   true,
+	"instance-shutdown":  true,
+	"instance-restarted": true,
+	"instance-paused":    true,
+	"instance-resumed":   true,
+	"instance-renamed":   true,
+	"instance-updated":   true,
+}
+
+const (
+	eventStreamMinBackoff = 1 * time.Second
+	eventStreamMaxBackoff = 30 * time.Second
+)
+
+// LifecycleEvent é o payload publicado para eventos de ciclo de vida vindos do LXD.
+type LifecycleEvent struct {
+	Action    string `json:"action"`
+	Instance  string `json:"instance"`
+	Location  string `json:"location,omitempty"`
+	Requestor string `json:"requestor,omitempty"`
+}
+
+// ForwardEvents escuta o stream de eventos do LXD e publica os eventos de ciclo
+// de vida das instâncias no GlobalBus. Reconecta automaticamente (com backoff)
+// quando o stream cai. Bloqueia até o ctx ser cancelado.
+func (s *InstanceService) ForwardEvents(ctx context.Context) {
+	backoff := eventStreamMinBackoff
+
+	for ctx.Err() == nil {
+		connectedAt := time.Now()
+		err := s.listenEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Uma conexão que durou bastante zera o backoff
+		if time.Since(connectedAt) > eventStreamMaxBackoff {
+			backoff = eventStreamMinBackoff
+		}
+
+		log.Printf("[LXD Events] Stream encerrado (%v). Reconectando em %v", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > eventStreamMaxBackoff {
+			backoff = eventStreamMaxBackoff
+		}
+	}
+}
+
+// listenEvents mantém uma única conexão com o stream até ela cair ou o ctx ser cancelado.
+func (s *InstanceService) listenEvents(ctx context.Context) error {
+	listener, err := s.server.GetEvents()
+	if err != nil {
+		return err
+	}
+	defer listener.Disconnect()
+
+	if _, err := listener.AddHandler([]string{api.EventTypeLifecycle}, publishLifecycleEvent); err != nil {
+		return err
+	}
+
+	log.Println("[LXD Events] Conectado ao stream de eventos")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- listener.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-done:
+		return err
+	}
+}
+
+func publishLifecycleEvent(evt api.Event) {
+	var lifecycle api.EventLifecycle
+	if err := json.Unmarshal(evt.Metadata, &lifecycle); err != nil {
+		return
+	}
+
+	if !lifecycleActions[lifecycle.Action] {
+		return
+	}
+
+	name := lifecycle.Name
+	if name == "" {
+		// Source tem o formato /1.0/instances/<nome>[?project=...]
+		name = path.Base(strings.SplitN(lifecycle.Source, "?", 2)[0])
+	}
+
+	payload := LifecycleEvent{
+		Action:   lifecycle.Action,
+		Instance: name,
+		Location: evt.Location,
+	}
+	if lifecycle.Requestor != nil {
+		payload.Requestor = lifecycle.Requestor.Username
+	}
+
+	events.Publish(events.Event{
+		Type:      events.InstanceLifecycle,
+		Target:    name,
+		Payload:   payload,
+		Timestamp: evt.Timestamp.Unix(),
+	})
+}
@@ -0,0 +1,167 @@
+package lxc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+	"github.com/canonical/lxd/shared/cancel"
+)
+
+// ============================================================================
+// EXPORT
+// ============================================================================
+
+// exportBackupTTL garante que o LXD apague o backup temporário mesmo se o
+// processo morrer antes do cleanup.
+const exportBackupTTL = 6 * time.Hour
+
+// ExportInstance cria um backup temporário no LXD e copia o tarball (gzip)
+// direto para w, sem gravar nada no disco do Axion. O backup é removido ao
+// final. ctx cancela tanto a criação quanto o download.
+func (s *InstanceService) ExportInstance(ctx context.Context, name string, w io.Writer) error {
+	backupName := fmt.Sprintf("axion-export-%d", time.Now().UnixNano())
+
+	op, err := s.server.CreateInstanceBackup(name, api.InstanceBackupsPost{
+		Name:                 backupName,
+		ExpiresAt:            time.Now().Add(exportBackupTTL),
+		CompressionAlgorithm: "gzip",
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar export de '%s': %w", name, err)
+	}
+	if err := op.WaitContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			op.Cancel()
+		}
+		return fmt.Errorf("falha ao gerar export de '%s': %w", name, err)
+	}
+
+	defer func() {
+		if delOp, err := s.server.DeleteInstanceBackup(name, backupName); err != nil {
+			log.Printf("[LXD Provider] Falha ao remover backup temporário %s/%s: %v", name, backupName, err)
+		} else if err := delOp.Wait(); err != nil {
+			log.Printf("[LXD Provider] Falha ao remover backup temporário %s/%s: %v", name, backupName, err)
+		}
+	}()
+
+	canceler := cancel.NewHTTPRequestCanceller()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			canceler.Cancel()
+		case <-stop:
+		}
+	}()
+
+	_, err = s.server.GetInstanceBackupFile(name, backupName, &lxd.BackupFileRequest{
+		BackupFile: streamWriter{w},
+		Canceler:   canceler,
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao transferir export de '%s': %w", name, err)
+	}
+	return nil
+}
+
+// streamWriter adapta um io.Writer ao io.WriteSeeker exigido pelo cliente do
+// LXD, que só escreve sequencialmente no download de backups.
+type streamWriter struct {
+	io.Writer
+}
+
+func (streamWriter) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("export stream is not seekable")
+}
+
+// ImportInstance cria a instância name a partir de um tarball de export lido
+// de r (já decifrado). pool vazio usa o pool padrão. ctx cancela a operação.
+func (s *InstanceService) ImportInstance(ctx context.Context, name string, pool string, r io.Reader) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: %s está ocupado", name)
+	}
+	defer s.locks.Delete(name)
+
+	op, err := s.server.CreateInstanceFromBackup(lxd.InstanceBackupArgs{
+		BackupFile: r,
+		PoolName:   poolOrDefault(pool),
+		Name:       name,
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao enviar backup de '%s': %w", name, err)
+	}
+	if err := op.WaitContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			op.Cancel()
+		}
+		return fmt.Errorf("falha ao restaurar '%s': %w", name, err)
+	}
+	return nil
+}
+
+// importUnsafeKey diz se uma chave de config trazida por um backup daria à
+// instância privilégios sobre o host. O export pode vir de qualquer lugar, então
+// essas chaves nunca são restauradas.
+func importUnsafeKey(key string) bool {
+	switch key {
+	case "security.privileged", "security.nesting", "linux.kernel_modules":
+		return true
+	}
+	for _, prefix := range []string{"raw.", "security.idmap.", "security.syscalls.", "linux.sysctl."} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeImportedInstance remove da instância importada (ainda parada) as
+// chaves de importUnsafeKey e os devices que expõem o host. Interfaces de rede
+// e discos de storage pool (sem caminho do host) ficam; os demais só ficam se
+// allowDevice aceitar. Retorna o que foi removido, em ordem.
+func (s *InstanceService) SanitizeImportedInstance(name string, allowDevice func(device map[string]string) error) ([]string, error) {
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao obter instância importada '%s': %w", name, err)
+	}
+
+	var removed []string
+	for key := range inst.Config {
+		if importUnsafeKey(key) {
+			delete(inst.Config, key)
+			removed = append(removed, "config "+key)
+		}
+	}
+	for devName, dev := range inst.Devices {
+		if dev["type"] == "nic" || (dev["type"] == "disk" && dev["pool"] != "") {
+			continue
+		}
+		if err := allowDevice(dev); err != nil {
+			delete(inst.Devices, devName)
+			removed = append(removed, "device "+devName)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	sort.Strings(removed)
+
+	op, err := s.server.UpdateInstance(name, inst.Writable(), etag)
+	if err == nil {
+		err = op.Wait()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao limpar instância importada '%s': %w", name, err)
+	}
+	log.Printf("[LXD Provider] Import de '%s': removidos %v", name, removed)
+	return removed, nil
+}
@@ -0,0 +1,71 @@
+package lxc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// importServer devolve a instância importada e guarda o que for gravado
+type importServer struct {
+	lxd.InstanceServer
+	inst    api.Instance
+	updated *api.InstancePut
+}
+
+func (s *importServer) GetInstance(name string) (*api.Instance, string, error) {
+	inst := s.inst
+	return &inst, "etag", nil
+}
+
+func (s *importServer) UpdateInstance(name string, put api.InstancePut, etag string) (lxd.Operation, error) {
+	s.updated = &put
+	return doneOp{}, nil
+}
+
+func TestSanitizeImportedInstance(t *testing.T) {
+	server := &importServer{inst: api.Instance{
+		Name: "restored",
+		Config: map[string]string{
+			"limits.cpu":          "2",
+			"security.privileged": "true",
+			"raw.lxc":             "lxc.apparmor.profile=unconfined",
+		},
+		Devices: map[string]map[string]string{
+			"root": {"type": "disk", "path": "/", "pool": "default"},
+			"eth0": {"type": "nic", "network": "lxdbr0"},
+			"host": {"type": "disk", "source": "/", "path": "/host"},
+			"kmsg": {"type": "unix-char", "path": "/dev/kmsg"},
+			"gpu0": {"type": "gpu"},
+		},
+	}}
+	allow := func(device map[string]string) error {
+		if device["type"] == "gpu" {
+			return nil
+		}
+		return errors.New("not allowed")
+	}
+
+	removed, err := (&InstanceService{server: server}).SanitizeImportedInstance("restored", allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"config raw.lxc", "config security.privileged", "device host", "device kmsg"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if server.updated == nil || server.updated.Config["limits.cpu"] != "2" || len(server.updated.Devices) != 3 {
+		t.Errorf("unexpected update: %+v", server.updated)
+	}
+
+	// Nothing unsafe: nothing is written
+	server.updated = nil
+	server.inst.Config = map[string]string{"limits.cpu": "2"}
+	server.inst.Devices = map[string]map[string]string{"eth0": {"type": "nic"}}
+	if removed, err := (&InstanceService{server: server}).SanitizeImportedInstance("restored", allow); err != nil || removed != nil || server.updated != nil {
+		t.Errorf("clean instance touched: %v %v %+v", removed, err, server.updated)
+	}
+}
@@ -0,0 +1,408 @@
+package lxc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// FILE EXPLORER: LISTAGEM
+// ============================================================================
+
+// listTimeout limita o find que lista um diretório inteiro
+const listTimeout = 30 * time.Second
+
+// FilePage é uma página da listagem de um diretório
+type FilePage struct {
+	Path    string      `json:"path"`
+	Entries []FileEntry `json:"entries"`
+	Total   int         `json:"total"`
+	HasMore bool        `json:"has_more"`
+}
+
+// listDirectory lista o diretório com um único find, separando os campos por
+// NUL para aceitar qualquer nome de arquivo.
+func (s *InstanceService) listDirectory(instanceName, dir string) ([]FileEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	result, err := s.ExecCommandContext(ctx, instanceName, []string{
+		"find", dir, "-mindepth", "1", "-maxdepth", "1", "-printf", `%y\0%f\0%l\0`,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("find retornou %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return parseFindEntries(result.Stdout)
+}
+
+// parseFindEntries lê a saída de find -printf '%y\0%f\0%l\0'. Tipos que o
+// explorer não distingue (sockets, dispositivos, fifos) aparecem como "file".
+func parseFindEntries(out string) ([]FileEntry, error) {
+	fields := strings.Split(out, "\x00")
+	if len(fields) > 0 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields)%3 != 0 {
+		return nil, fmt.Errorf("saída do find inesperada (%d campos)", len(fields))
+	}
+
+	entries := make([]FileEntry, 0, len(fields)/3)
+	for i := 0; i < len(fields); i += 3 {
+		entry := FileEntry{Name: fields[i+1], Type: "file"}
+		switch fields[i] {
+		case "d":
+			entry.Type = "directory"
+		case "l":
+			entry.Type = "symlink"
+			entry.Target = fields[i+2]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// lstatEntries consulta o tipo de cada entrada pela API de arquivos do LXD.
+// Entradas que falham são omitidas.
+func (s *InstanceService) lstatEntries(instanceName, dir string, names []string) []FileEntry {
+	lstat := s.lstat(instanceName)
+	entries := make([]FileEntry, 0, len(names))
+	for _, name := range names {
+		entryType, target, err := lstat(path.Join(dir, name))
+		if err != nil {
+			log.Printf("Warning: Failed to get info for '%s': %v", path.Join(dir, name), err)
+			continue
+		}
+		entries = append(entries, FileEntry{Name: name, Type: entryType, Target: target})
+	}
+	return entries
+}
+
+// sortFileEntries ordena diretórios primeiro e depois por nome
+func sortFileEntries(entries []FileEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		iDir, jDir := entries[i].Type == "directory", entries[j].Type == "directory"
+		if iDir != jDir {
+			return iDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// pageFileEntries recorta [offset, offset+limit) e informa se há mais depois
+func pageFileEntries(entries []FileEntry, offset, limit int) ([]FileEntry, bool) {
+	if offset >= len(entries) {
+		return []FileEntry{}, false
+	}
+	entries = entries[offset:]
+	if limit > 0 && len(entries) > limit {
+		return entries[:limit], true
+	}
+	return entries, false
+}
+
+// ============================================================================
+// FILE EXPLORER: SYMLINKS
+// ============================================================================
+
+// maxSymlinkHops segue o limite do kernel (MAXSYMLINKS) para detectar loops.
+const maxSymlinkHops = 40
+
+// ErrPathEscapesRoot indica um caminho que, depois de resolver os symlinks,
+// sai da raiz permitida do explorer.
+var ErrPathEscapesRoot = errors.New("path escapes the file explorer root")
+
+// DefaultExplorerRoot deixa o explorer ver a instância inteira
+const DefaultExplorerRoot = "/"
+
+var (
+	explorerRootMu sync.RWMutex
+	explorerRoot   = DefaultExplorerRoot
+)
+
+// SetExplorerRoot define a raiz do explorer (da configuração, na inicialização)
+func SetExplorerRoot(root string) {
+	explorerRootMu.Lock()
+	defer explorerRootMu.Unlock()
+	explorerRoot = path.Clean("/" + root)
+}
+
+// ExplorerRoot é a raiz que o explorer pode acessar dentro da instância
+func ExplorerRoot() string {
+	explorerRootMu.RLock()
+	defer explorerRootMu.RUnlock()
+	return explorerRoot
+}
+
+// withinRoot informa se p (já limpo e absoluto) está dentro de root.
+func withinRoot(root, p string) bool {
+	if root == "/" {
+		return true
+	}
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+// lstatFunc devolve o tipo de um caminho sem segui-lo e, para symlinks, o alvo.
+type lstatFunc func(p string) (fileType string, target string, err error)
+
+// resolveInRoot resolve todos os symlinks de p componente a componente, como o
+// kernel faria, e falha se o caminho (ou qualquer salto intermediário) sair de
+// root. Com followLast=false o último componente não é seguido (delete remove
+// o link, não o alvo).
+func resolveInRoot(root, p string, followLast bool, lstat lstatFunc) (string, error) {
+	p = path.Clean("/" + p)
+	if !withinRoot(root, p) {
+		return "", ErrPathEscapesRoot
+	}
+
+	hops := 0
+	resolved := "/"
+	pending := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for len(pending) > 0 {
+		comp := pending[0]
+		pending = pending[1:]
+		if comp == "" || comp == "." {
+			continue
+		}
+
+		next := path.Join(resolved, comp)
+		if comp == ".." || (!followLast && len(pending) == 0) {
+			resolved = next
+			if !withinRoot(root, resolved) {
+				return "", ErrPathEscapesRoot
+			}
+			continue
+		}
+
+		fileType, target, err := lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if fileType != "symlink" {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", p)
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(resolved, target)
+		}
+		target = path.Clean(target)
+		if !withinRoot(root, target) {
+			return "", fmt.Errorf("%w: %s -> %s", ErrPathEscapesRoot, next, target)
+		}
+
+		// Reinicia a partir do alvo com o restante do caminho
+		resolved = "/"
+		pending = append(strings.Split(strings.TrimPrefix(target, "/"), "/"), pending...)
+	}
+
+	if !withinRoot(root, resolved) {
+		return "", ErrPathEscapesRoot
+	}
+	return resolved, nil
+}
+
+// lstat consulta o LXD sem seguir o último componente: para symlinks o LXD
+// devolve o tipo "symlink" e o alvo como conteúdo.
+func (s *InstanceService) lstat(instanceName string) lstatFunc {
+	return func(p string) (string, string, error) {
+		content, resp, err := s.server.GetInstanceFile(instanceName, p)
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return "", "", fmt.Errorf("caminho '%s': %w", p, os.ErrNotExist)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("falha ao ler caminho '%s': %w", p, err)
+		}
+		if content == nil {
+			return resp.Type, "", nil
+		}
+		defer content.Close()
+
+		if resp.Type != "symlink" {
+			return resp.Type, "", nil
+		}
+		target, err := io.ReadAll(io.LimitReader(content, 4096))
+		if err != nil {
+			return "", "", fmt.Errorf("falha ao ler symlink '%s': %w", p, err)
+		}
+		return resp.Type, strings.TrimSpace(string(target)), nil
+	}
+}
+
+// resolveExplorerPath aplica a política de symlinks do explorer a um caminho.
+func (s *InstanceService) resolveExplorerPath(instanceName, p string, followLast bool) (string, error) {
+	return resolveInRoot(ExplorerRoot(), p, followLast, s.lstat(instanceName))
+}
+
+// ============================================================================
+// FILE EXPLORER: UPLOAD
+// ============================================================================
+
+// ErrInvalidUploadTarget indica um destino de upload inutilizável (ex.: um
+// diretório onde deveria haver arquivo, ou o contrário).
+var ErrInvalidUploadTarget = errors.New("invalid upload target")
+
+// ErrUploadParentMissing indica que o diretório de destino não existe e o
+// upload não pediu a criação dos diretórios intermediários.
+var ErrUploadParentMissing = errors.New("upload directory does not exist")
+
+// UploadRequest descreve onde gravar um upload. Path pode ser um diretório
+// existente (o arquivo entra com Filename) ou o caminho completo do arquivo;
+// terminar em "/" força a leitura como diretório.
+type UploadRequest struct {
+	Path     string
+	Filename string
+	// Parents cria os diretórios ausentes, como mkdir -p
+	Parents bool
+}
+
+// uploadPlan é o resultado de planUpload: diretórios a criar, em ordem, e o
+// caminho final do arquivo.
+type uploadPlan struct {
+	Mkdirs []string
+	Target string
+}
+
+// planUpload decide onde o upload será gravado sem alterar nada: resolve a
+// parte existente de req.Path com a política de symlinks do explorer e
+// rejeita qualquer destino fora de root.
+func planUpload(root string, req UploadRequest, lstat lstatFunc) (uploadPlan, error) {
+	p := path.Clean("/" + req.Path)
+	if !withinRoot(root, p) {
+		return uploadPlan{}, ErrPathEscapesRoot
+	}
+	wantDir := strings.HasSuffix(req.Path, "/")
+
+	// Maior prefixo existente de p; o que sobra são componentes a criar
+	comps := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	if p == "/" {
+		comps = nil
+	}
+	var base, baseType string
+	var missing []string
+	for i := len(comps); ; i-- {
+		prefix := "/" + strings.Join(comps[:i], "/")
+		if !withinRoot(root, prefix) {
+			// Nem a raiz do explorer existe
+			return uploadPlan{}, fmt.Errorf("%w: %s", ErrUploadParentMissing, root)
+		}
+		resolved, err := resolveInRoot(root, prefix, true, lstat)
+		if err == nil {
+			fileType, _, err := lstat(resolved)
+			if err != nil {
+				return uploadPlan{}, err
+			}
+			base, baseType, missing = resolved, fileType, comps[i:]
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || i == 0 {
+			return uploadPlan{}, err
+		}
+	}
+
+	var plan uploadPlan
+	switch {
+	case len(missing) == 0 && baseType == "directory":
+		if !validUploadName(req.Filename) {
+			return uploadPlan{}, fmt.Errorf("%w: %s is a directory and the upload has no usable file name", ErrInvalidUploadTarget, base)
+		}
+		plan.Target = path.Join(base, req.Filename)
+	case len(missing) == 0:
+		if wantDir {
+			return uploadPlan{}, fmt.Errorf("%w: %s is not a directory", ErrInvalidUploadTarget, base)
+		}
+		plan.Target = base
+	case baseType != "directory":
+		return uploadPlan{}, fmt.Errorf("%w: %s is not a directory", ErrInvalidUploadTarget, base)
+	default:
+		dirs := missing[:len(missing)-1]
+		plan.Target = path.Join(base, strings.Join(missing, "/"))
+		if wantDir {
+			dirs = missing
+			if !validUploadName(req.Filename) {
+				return uploadPlan{}, fmt.Errorf("%w: the upload has no usable file name", ErrInvalidUploadTarget)
+			}
+			plan.Target = path.Join(plan.Target, req.Filename)
+		}
+		if len(dirs) > 0 && !req.Parents {
+			return uploadPlan{}, fmt.Errorf("%w: %s", ErrUploadParentMissing, path.Join(base, dirs[0]))
+		}
+		dir := base
+		for _, d := range dirs {
+			dir = path.Join(dir, d)
+			plan.Mkdirs = append(plan.Mkdirs, dir)
+		}
+	}
+
+	// O próprio arquivo pode existir como symlink ou diretório
+	fileType, _, err := lstat(plan.Target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return uploadPlan{}, err
+	case fileType == "directory":
+		return uploadPlan{}, fmt.Errorf("%w: %s is a directory", ErrInvalidUploadTarget, plan.Target)
+	case fileType == "symlink":
+		resolved, err := resolveInRoot(root, plan.Target, true, lstat)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return uploadPlan{}, err
+		}
+		if err == nil {
+			plan.Target = resolved
+		}
+	}
+
+	if !withinRoot(root, plan.Target) {
+		return uploadPlan{}, ErrPathEscapesRoot
+	}
+	return plan, nil
+}
+
+// validUploadName aceita só um nome simples de arquivo, sem diretórios
+func validUploadName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+// UploadFileTo grava um upload no destino decidido por planUpload, criando os
+// diretórios intermediários quando pedido, e devolve o caminho gravado.
+func (s *InstanceService) UploadFileTo(instanceName string, req UploadRequest, content io.ReadSeeker) (string, error) {
+	plan, err := planUpload(ExplorerRoot(), req, s.lstat(instanceName))
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range plan.Mkdirs {
+		log.Printf("[LXD Provider] Criando diretório '%s:%s'", instanceName, dir)
+		err := s.server.CreateInstanceFile(instanceName, dir, lxd.InstanceFileArgs{
+			Type: "directory",
+			Mode: 0755,
+		})
+		if err != nil {
+			return "", fmt.Errorf("falha ao criar diretório '%s': %w", dir, err)
+		}
+	}
+
+	if err := s.UploadFile(instanceName, plan.Target, content); err != nil {
+		return "", err
+	}
+	return plan.Target, nil
+}
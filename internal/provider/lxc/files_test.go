@@ -0,0 +1,173 @@
+package lxc
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func fakeFS(entries map[string]string) lstatFunc {
+	return func(p string) (string, string, error) {
+		v, ok := entries[p]
+		if !ok {
+			return "", "", os.ErrNotExist
+		}
+		if v == "dir" || v == "file" {
+			return map[string]string{"dir": "directory", "file": "file"}[v], "", nil
+		}
+		return "symlink", v, nil
+	}
+}
+
+func TestResolveInRoot(t *testing.T) {
+	fs := fakeFS(map[string]string{
+		"/srv":              "dir",
+		"/srv/app":          "dir",
+		"/srv/app/data.txt": "file",
+		"/srv/app/current":  "data.txt",
+		"/srv/app/shadow":   "/etc/shadow",
+		"/srv/app/up":       "../../etc",
+		"/srv/app/loop":     "loop",
+		"/srv/app/etc":      "/etc",
+	})
+
+	got, err := resolveInRoot("/srv", "/srv/app/current", true, fs)
+	if err != nil || got != "/srv/app/data.txt" {
+		t.Fatalf("relative link inside root = %q, %v", got, err)
+	}
+
+	for _, p := range []string{"/srv/app/shadow", "/srv/app/up/passwd", "/srv/app/etc/shadow", "/etc/shadow", "/srv/../etc"} {
+		if _, err := resolveInRoot("/srv", p, true, fs); !errors.Is(err, ErrPathEscapesRoot) {
+			t.Errorf("%s: expected ErrPathEscapesRoot, got %v", p, err)
+		}
+	}
+
+	// Deleting a link removes the link itself, so the last component is not followed
+	got, err = resolveInRoot("/srv", "/srv/app/shadow", false, fs)
+	if err != nil || got != "/srv/app/shadow" {
+		t.Errorf("delete of escaping link = %q, %v; want the link path", got, err)
+	}
+
+	if _, err := resolveInRoot("/srv", "/srv/app/loop", true, fs); err == nil {
+		t.Error("symlink loop should fail")
+	}
+}
+
+func TestPlanUpload(t *testing.T) {
+	fs := fakeFS(map[string]string{
+		"/":                 "dir",
+		"/srv":              "dir",
+		"/srv/app":          "dir",
+		"/srv/app/data.txt": "file",
+		"/srv/app/link":     "data.txt",
+		"/srv/app/out":      "/etc",
+		"/srv/app/sub":      "dir",
+		"/etc":              "dir",
+	})
+
+	cases := []struct {
+		name   string
+		req    UploadRequest
+		target string
+		mkdirs []string
+		err    error
+	}{
+		{name: "existing directory", req: UploadRequest{Path: "/srv/app", Filename: "a.txt"}, target: "/srv/app/a.txt"},
+		{name: "existing directory with slash", req: UploadRequest{Path: "/srv/app/", Filename: "a.txt"}, target: "/srv/app/a.txt"},
+		{name: "new file in existing directory", req: UploadRequest{Path: "/srv/app/new.txt", Filename: "ignored"}, target: "/srv/app/new.txt"},
+		{name: "overwrite existing file", req: UploadRequest{Path: "/srv/app/data.txt"}, target: "/srv/app/data.txt"},
+		{name: "symlink to file inside root", req: UploadRequest{Path: "/srv/app/link"}, target: "/srv/app/data.txt"},
+		{name: "directory upload keeps name", req: UploadRequest{Path: "/srv/app/sub", Filename: "a.txt"}, target: "/srv/app/sub/a.txt"},
+		{name: "missing parent", req: UploadRequest{Path: "/srv/app/x/y/a.txt"}, err: ErrUploadParentMissing},
+		{name: "missing parent with flag", req: UploadRequest{Path: "/srv/app/x/y/a.txt", Parents: true}, target: "/srv/app/x/y/a.txt", mkdirs: []string{"/srv/app/x", "/srv/app/x/y"}},
+		{name: "new directory with slash", req: UploadRequest{Path: "/srv/app/x/", Filename: "a.txt", Parents: true}, target: "/srv/app/x/a.txt", mkdirs: []string{"/srv/app/x"}},
+		{name: "file as directory", req: UploadRequest{Path: "/srv/app/data.txt/", Filename: "a.txt"}, err: ErrInvalidUploadTarget},
+		{name: "below a file", req: UploadRequest{Path: "/srv/app/data.txt/a.txt", Parents: true}, err: ErrInvalidUploadTarget},
+		{name: "directory without file name", req: UploadRequest{Path: "/srv/app"}, err: ErrInvalidUploadTarget},
+		{name: "file name with directories", req: UploadRequest{Path: "/srv/app", Filename: "../a.txt"}, err: ErrInvalidUploadTarget},
+		{name: "dot dot escape", req: UploadRequest{Path: "/srv/../etc/passwd"}, err: ErrPathEscapesRoot},
+		{name: "symlink escape", req: UploadRequest{Path: "/srv/app/out/passwd"}, err: ErrPathEscapesRoot},
+		{name: "outside root", req: UploadRequest{Path: "/etc/passwd"}, err: ErrPathEscapesRoot},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := planUpload("/srv", tc.req, fs)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("got %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if plan.Target != tc.target {
+				t.Errorf("target = %q, want %q", plan.Target, tc.target)
+			}
+			if strings.Join(plan.Mkdirs, ",") != strings.Join(tc.mkdirs, ",") {
+				t.Errorf("mkdirs = %v, want %v", plan.Mkdirs, tc.mkdirs)
+			}
+		})
+	}
+}
+
+func TestParseFindEntries(t *testing.T) {
+	out := "d\x00logs\x00\x00f\x00tab\tname\x00\x00l\x00current\x00../releases/v2\x00s\x00agent.sock\x00\x00"
+
+	entries, err := parseFindEntries(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileEntry{
+		{Name: "logs", Type: "directory"},
+		{Name: "tab\tname", Type: "file"},
+		{Name: "current", Type: "symlink", Target: "../releases/v2"},
+		{Name: "agent.sock", Type: "file"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	if _, err := parseFindEntries("d\x00truncated"); err == nil {
+		t.Error("expected an error for truncated output")
+	}
+}
+
+func TestFileListingPagesAreStable(t *testing.T) {
+	entries := []FileEntry{
+		{Name: "b.txt", Type: "file"},
+		{Name: "z", Type: "directory"},
+		{Name: "a.txt", Type: "file"},
+		{Name: "link", Type: "symlink"},
+		{Name: "etc", Type: "directory"},
+	}
+	sortFileEntries(entries)
+
+	var names []string
+	for offset := 0; ; offset += 2 {
+		page, hasMore := pageFileEntries(entries, offset, 2)
+		for _, e := range page {
+			names = append(names, e.Name)
+		}
+		if !hasMore {
+			break
+		}
+	}
+	if got, want := strings.Join(names, ","), "etc,z,a.txt,b.txt,link"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if page, hasMore := pageFileEntries(entries, 10, 2); len(page) != 0 || hasMore {
+		t.Errorf("offset past the end: %v, %v", page, hasMore)
+	}
+	if page, hasMore := pageFileEntries(entries, 0, 0); len(page) != len(entries) || hasMore {
+		t.Errorf("limit 0 should return everything: %d entries, has_more %v", len(page), hasMore)
+	}
+}
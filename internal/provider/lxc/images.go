@@ -0,0 +1,193 @@
+package lxc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"aexon/internal/service"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// IMAGE CACHE
+// ============================================================================
+
+// DefaultImageRemote é o remote usado quando a imagem não traz prefixo ("ubuntu/22.04").
+const DefaultImageRemote = "images"
+
+// ImageRemotes são os servidores simplestreams aceitos por PullImage.
+var ImageRemotes = map[string]string{
+	"images": "https://images.lxd.canonical.com",
+	"ubuntu": "https://cloud-images.ubuntu.com/releases",
+}
+
+// CachedImage resume uma imagem do store local do LXD.
+type CachedImage struct {
+	Fingerprint string     `json:"fingerprint"`
+	Aliases     []string   `json:"aliases"`
+	Description string     `json:"description"`
+	Type        string     `json:"type"`
+	SizeBytes   int64      `json:"size_bytes"`
+	UploadedAt  time.Time  `json:"uploaded_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"` // nil se nunca usada
+}
+
+// ParseImageRef separa "remote:alias" e devolve o alias local usado na
+// criação: VMs usam o sufixo "-vm", como em CreateInstanceWithProgress.
+func ParseImageRef(image string, instanceType string) (remote, alias, localAlias string, err error) {
+	remote, alias = DefaultImageRemote, strings.TrimSpace(image)
+	if r, a, ok := strings.Cut(alias, ":"); ok {
+		remote, alias = r, a
+	}
+	if alias == "" {
+		return "", "", "", fmt.Errorf("imagem vazia")
+	}
+	if _, ok := ImageRemotes[remote]; !ok {
+		return "", "", "", fmt.Errorf("remote desconhecido: %q", remote)
+	}
+
+	localAlias = alias
+	if instanceType == "virtual-machine" {
+		localAlias = alias + "-vm"
+	}
+	return remote, alias, localAlias, nil
+}
+
+// PullImage copia uma imagem remota para o store local do LXD sob o alias
+// usado na criação, para que o primeiro create não precise baixá-la.
+// Se o alias local já aponta para a versão atual, nada é baixado.
+func (s *InstanceService) PullImage(ctx context.Context, image string, instanceType string, progress ProgressFunc) (string, error) {
+	report := func(percent int, message string) {
+		if progress != nil {
+			progress(percent, message)
+		}
+	}
+
+	remote, alias, localAlias, err := ParseImageRef(image, instanceType)
+	if err != nil {
+		return "", err
+	}
+	if !service.IsImageAllowed(alias) {
+		return "", fmt.Errorf("%w: %s", service.ErrImageNotAllowed, alias)
+	}
+
+	imageType := "container"
+	if instanceType == "virtual-machine" {
+		imageType = "virtual-machine"
+	}
+
+	source, err := lxd.ConnectSimpleStreams(ImageRemotes[remote], nil)
+	if err != nil {
+		return "", fmt.Errorf("falha ao conectar ao remote %s: %w", remote, err)
+	}
+	defer source.Disconnect()
+
+	entry, _, err := source.GetImageAliasType(imageType, alias)
+	if err != nil {
+		return "", fmt.Errorf("imagem %s:%s não encontrada: %w", remote, alias, err)
+	}
+
+	if local, _, err := s.server.GetImageAlias(localAlias); err == nil && local.Target == entry.Target {
+		report(100, "image already cached")
+		return entry.Target, nil
+	}
+
+	img, _, err := source.GetImage(entry.Target)
+	if err != nil {
+		return "", fmt.Errorf("falha ao obter imagem %s: %w", entry.Target, err)
+	}
+
+	// Um alias local antigo impediria a cópia de registrar o novo
+	if _, _, err := s.server.GetImageAlias(localAlias); err == nil {
+		if err := s.server.DeleteImageAlias(localAlias); err != nil {
+			return "", fmt.Errorf("falha ao substituir alias %s: %w", localAlias, err)
+		}
+	}
+
+	log.Printf("[Images] Baixando %s:%s (%s) como %s", remote, alias, entry.Target, localAlias)
+	report(0, "downloading image")
+
+	op, err := s.server.CopyImage(source, *img, &lxd.ImageCopyArgs{
+		Aliases: []api.ImageAlias{{Name: localAlias}},
+		Type:    imageType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("falha ao iniciar download de %s: %w", alias, err)
+	}
+
+	if progress != nil {
+		_, err := op.AddHandler(func(o api.Operation) {
+			raw, ok := o.Metadata["download_progress"].(string)
+			if !ok {
+				return
+			}
+			if m := progressPercentRegex.FindStringSubmatch(raw); m != nil {
+				pct, _ := strconv.Atoi(m[1])
+				report(pct*99/100, fmt.Sprintf("downloading image %d%%", pct))
+			}
+		})
+		if err != nil {
+			log.Printf("[Images] Progresso indisponível para %s: %v", alias, err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- op.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("falha ao baixar %s: %w", alias, err)
+		}
+	case <-ctx.Done():
+		op.CancelTarget()
+		return "", fmt.Errorf("download de %s cancelado: %w", alias, ctx.Err())
+	}
+
+	report(100, "image cached")
+	return entry.Target, nil
+}
+
+// ListCachedImages lista as imagens do store local, mais recentemente usadas primeiro.
+func (s *InstanceService) ListCachedImages() ([]CachedImage, error) {
+	images, err := s.server.GetImages()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar imagens: %w", err)
+	}
+
+	out := make([]CachedImage, 0, len(images))
+	for _, img := range images {
+		cached := CachedImage{
+			Fingerprint: img.Fingerprint,
+			Aliases:     []string{},
+			Description: img.Properties["description"],
+			Type:        img.Type,
+			SizeBytes:   img.Size,
+			UploadedAt:  img.UploadedAt,
+		}
+		for _, a := range img.Aliases {
+			cached.Aliases = append(cached.Aliases, a.Name)
+		}
+		if !img.LastUsedAt.IsZero() && img.LastUsedAt.Unix() > 0 {
+			lastUsed := img.LastUsedAt
+			cached.LastUsedAt = &lastUsed
+		}
+		out = append(out, cached)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].LastUsedAt, out[j].LastUsedAt
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.After(*b)
+	})
+	return out, nil
+}
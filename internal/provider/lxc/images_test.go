@@ -0,0 +1,35 @@
+package lxc
+
+import "testing"
+
+func TestParseImageRef(t *testing.T) {
+	cases := []struct {
+		image, instanceType  string
+		remote, alias, local string
+		wantErr              bool
+	}{
+		{image: "ubuntu/22.04", remote: "images", alias: "ubuntu/22.04", local: "ubuntu/22.04"},
+		{image: "images:alpine/3.19", remote: "images", alias: "alpine/3.19", local: "alpine/3.19"},
+		{image: "ubuntu:22.04", instanceType: "virtual-machine", remote: "ubuntu", alias: "22.04", local: "22.04-vm"},
+		{image: "unknown:debian/12", wantErr: true},
+		{image: "images:", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		remote, alias, local, err := ParseImageRef(tc.image, tc.instanceType)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseImageRef(%q) expected error", tc.image)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseImageRef(%q) unexpected error: %v", tc.image, err)
+			continue
+		}
+		if remote != tc.remote || alias != tc.alias || local != tc.local {
+			t.Errorf("ParseImageRef(%q) = %q, %q, %q; want %q, %q, %q",
+				tc.image, remote, alias, local, tc.remote, tc.alias, tc.local)
+		}
+	}
+}
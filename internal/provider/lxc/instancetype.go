@@ -0,0 +1,47 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tipos de instância aceitos na criação
+const (
+	InstanceTypeContainer = "container"
+	InstanceTypeVM        = "virtual-machine"
+)
+
+// ErrVirtualizationUnsupported indica um host LXD que não cria máquinas virtuais
+// (sem KVM ou sem o driver qemu).
+var ErrVirtualizationUnsupported = errors.New("o host não suporta máquinas virtuais")
+
+// ValidateInstanceType aceita container e virtual-machine; vazio vale container.
+func ValidateInstanceType(instanceType string) error {
+	switch instanceType {
+	case "", InstanceTypeContainer, InstanceTypeVM:
+		return nil
+	}
+	return fmt.Errorf("tipo %q inválido (use %s ou %s)", instanceType, InstanceTypeContainer, InstanceTypeVM)
+}
+
+// CheckInstanceType confirma que o servidor cria instâncias do tipo pedido,
+// segundo os instance_types que o próprio LXD anuncia no ambiente.
+func (s *InstanceService) CheckInstanceType(instanceType string) error {
+	if instanceType == "" {
+		instanceType = InstanceTypeContainer
+	}
+
+	server, _, err := s.server.GetServer()
+	if err != nil {
+		return fmt.Errorf("falha ao consultar o servidor LXD: %w", err)
+	}
+	for _, supported := range server.Environment.InstanceTypes {
+		if supported == instanceType {
+			return nil
+		}
+	}
+	if instanceType == InstanceTypeVM {
+		return ErrVirtualizationUnsupported
+	}
+	return fmt.Errorf("o host não suporta instâncias do tipo %s", instanceType)
+}
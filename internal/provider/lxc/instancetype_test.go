@@ -0,0 +1,48 @@
+package lxc
+
+import (
+	"errors"
+	"testing"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+type envServer struct {
+	lxd.InstanceServer
+	instanceTypes []string
+}
+
+func (e *envServer) GetServer() (*api.Server, string, error) {
+	server := &api.Server{}
+	server.Environment.InstanceTypes = e.instanceTypes
+	return server, "", nil
+}
+
+func TestValidateInstanceType(t *testing.T) {
+	for _, valid := range []string{"", InstanceTypeContainer, InstanceTypeVM} {
+		if err := ValidateInstanceType(valid); err != nil {
+			t.Errorf("%q: unexpected error: %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"vm", "Container", "lxc"} {
+		if err := ValidateInstanceType(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+func TestCheckInstanceType(t *testing.T) {
+	containersOnly := &InstanceService{server: &envServer{instanceTypes: []string{"container"}}}
+	if err := containersOnly.CheckInstanceType(""); err != nil {
+		t.Errorf("container: unexpected error: %v", err)
+	}
+	if err := containersOnly.CheckInstanceType(InstanceTypeVM); !errors.Is(err, ErrVirtualizationUnsupported) {
+		t.Errorf("vm without kvm: got %v, want ErrVirtualizationUnsupported", err)
+	}
+
+	both := &InstanceService{server: &envServer{instanceTypes: []string{"container", "virtual-machine"}}}
+	if err := both.CheckInstanceType(InstanceTypeVM); err != nil {
+		t.Errorf("vm: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package lxc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// LOG EXPORT
+// ============================================================================
+
+// DefaultLogPaths são os arquivos lidos em instâncias sem systemd quando
+// nenhum caminho é configurado (Debian/Ubuntu e Alpine/RHEL).
+var DefaultLogPaths = []string{"/var/log/syslog", "/var/log/messages"}
+
+// ErrLogUnitUnsupported indica filtro por unit numa instância sem journald.
+var ErrLogUnitUnsupported = errors.New("filtro por unit exige systemd/journald na instância")
+
+// ErrNoLogSource indica que a instância não tem journald nem arquivo de log legível.
+var ErrNoLogSource = errors.New("nenhuma fonte de log encontrada na instância")
+
+// LogExportRequest é a janela (e, com journald, a unit) a exportar.
+type LogExportRequest struct {
+	Since time.Time
+	Until time.Time
+	Unit  string
+	// Paths são os arquivos candidatos sem systemd; vazio usa DefaultLogPaths
+	Paths []string
+}
+
+// ExportLogs escreve em w as linhas de log da instância dentro da janela, sem
+// bufferizar a saída. Com systemd usa journalctl --since/--until; sem ele lê
+// o primeiro arquivo legível de req.Paths e filtra as linhas pelo timestamp.
+func (s *InstanceService) ExportLogs(ctx context.Context, name string, req LogExportRequest, w io.Writer) error {
+	systemd, err := s.ExecCommandContext(ctx, name, []string{"test", "-d", "/run/systemd/system"})
+	if err != nil {
+		return err
+	}
+
+	if systemd.ExitCode == 0 {
+		cmd := []string{"journalctl", "--no-pager", "--utc", "-o", "short-iso",
+			"--since", req.Since.UTC().Format("2006-01-02 15:04:05") + " UTC",
+			"--until", req.Until.UTC().Format("2006-01-02 15:04:05") + " UTC"}
+		if req.Unit != "" {
+			cmd = append(cmd, "-u", req.Unit)
+		}
+		return s.execStream(ctx, name, cmd, w)
+	}
+
+	if req.Unit != "" {
+		return ErrLogUnitUnsupported
+	}
+
+	paths := req.Paths
+	if len(paths) == 0 {
+		paths = DefaultLogPaths
+	}
+	for _, path := range paths {
+		readable, err := s.ExecCommandContext(ctx, name, []string{"test", "-r", path})
+		if err != nil {
+			return err
+		}
+		if readable.ExitCode != 0 {
+			continue
+		}
+
+		pr, pw := io.Pipe()
+		filterDone := make(chan error, 1)
+		go func() {
+			filterDone <- filterLogLines(pr, w, req.Since, req.Until)
+			pr.Close()
+		}()
+		err = s.execStream(ctx, name, []string{"cat", path}, pw)
+		pw.CloseWithError(err)
+		if filterErr := <-filterDone; err == nil {
+			err = filterErr
+		}
+		return err
+	}
+	return ErrNoLogSource
+}
+
+// execStream executa cmd copiando o stdout para w à medida que chega.
+func (s *InstanceService) execStream(ctx context.Context, name string, cmd []string, w io.Writer) error {
+	var stderr bytes.Buffer
+	args := lxd.InstanceExecArgs{
+		Stdin:    bytes.NewReader(nil),
+		Stdout:   w,
+		Stderr:   &stderr,
+		DataDone: make(chan bool),
+	}
+
+	op, err := s.server.ExecInstance(name, api.InstanceExecPost{
+		Command:   cmd,
+		WaitForWS: true,
+	}, &args)
+	if err != nil {
+		return fmt.Errorf("falha ao executar %s em '%s': %w", cmd[0], name, err)
+	}
+	if err := op.WaitContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			op.Cancel()
+			return fmt.Errorf("execução em '%s' interrompida: %w", name, ctx.Err())
+		}
+		return fmt.Errorf("erro durante execução em '%s': %w", name, err)
+	}
+	<-args.DataDone
+
+	if code, ok := op.Get().Metadata["return"].(float64); ok && code != 0 {
+		return fmt.Errorf("%s retornou %d: %s", cmd[0], int(code), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// filterLogLines copia de r para w as linhas com timestamp em [since, until].
+// Linhas sem timestamp reconhecível (continuações) seguem a decisão da
+// linha anterior.
+func filterLogLines(r io.Reader, w io.Writer, since, until time.Time) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	include := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ts, ok := parseLogTimestamp(line, until); ok {
+			include = !ts.Before(since) && !ts.After(until)
+		}
+		if !include {
+			continue
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseLogTimestamp reconhece o início de linhas de syslog: RFC 3339
+// (rsyslog com alta precisão) ou o formato clássico "Jan _2 15:04:05", que não
+// tem ano nem fuso: assume UTC e o ano de ref, voltando um ano se cair depois de ref.
+func parseLogTimestamp(line string, ref time.Time) (time.Time, bool) {
+	if field, _, ok := strings.Cut(line, " "); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return ts, true
+		}
+	}
+
+	const classic = "Jan _2 15:04:05"
+	if len(line) < len(classic) {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(classic, line[:len(classic)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	ref = ref.UTC()
+	ts = time.Date(ref.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, time.UTC)
+	if ts.After(ref.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts, true
+}
@@ -0,0 +1,59 @@
+package lxc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogTimestamp(t *testing.T) {
+	ref := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		line string
+		want time.Time
+		ok   bool
+	}{
+		{line: "2025-01-10T08:30:00.123456+00:00 host sshd[1]: accepted", want: time.Date(2025, 1, 10, 8, 30, 0, 123456000, time.UTC), ok: true},
+		{line: "Jan  9 23:59:59 host kernel: eth0 up", want: time.Date(2025, 1, 9, 23, 59, 59, 0, time.UTC), ok: true},
+		// December lines read in January belong to the previous year
+		{line: "Dec 31 22:00:00 host cron[2]: job", want: time.Date(2024, 12, 31, 22, 0, 0, 0, time.UTC), ok: true},
+		{line: "    at java.lang.Thread.run", ok: false},
+	}
+
+	for _, tc := range cases {
+		got, ok := parseLogTimestamp(tc.line, ref)
+		if ok != tc.ok {
+			t.Errorf("%q: ok = %v, want %v", tc.line, ok, tc.ok)
+			continue
+		}
+		if ok && !got.Equal(tc.want) {
+			t.Errorf("%q: got %s, want %s", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestFilterLogLines(t *testing.T) {
+	input := strings.Join([]string{
+		"Jan 10 07:59:59 host app: before",
+		"Jan 10 08:00:00 host app: start",
+		"    continuation of start",
+		"Jan 10 09:00:00 host app: end",
+		"Jan 10 09:00:01 host app: after",
+		"    continuation of after",
+	}, "\n")
+
+	since := time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	if err := filterLogLines(strings.NewReader(input), &out, since, until); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Jan 10 08:00:00 host app: start\n    continuation of start\nJan 10 09:00:00 host app: end\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,189 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// MIGRATION
+// ============================================================================
+
+// Modo efetivamente usado por MigrateInstance
+const (
+	MigrationLive = "live" // estado em memória transferido, sem desligar
+	MigrationCold = "cold" // parada, cópia do disco e partida no destino
+)
+
+// MigrationStopGrace é o prazo do desligamento limpo antes da cópia a frio
+const MigrationStopGrace = 30 * time.Second
+
+// ErrMigrationTargetExists indica que o destino já tem uma instância com o nome.
+var ErrMigrationTargetExists = errors.New("o remote de destino já tem uma instância com esse nome")
+
+// MigrateInstance move name deste servidor para target (outro remote),
+// levando snapshots. Com live e a instância rodando tenta a migração com
+// estado; se o LXD recusar (sem CRIU, VM sem migration.stateful), cai para a
+// migração a frio. A origem só é excluída depois que a instância estiver de pé
+// no destino: em qualquer falha anterior a cópia parcial é removida e a origem
+// volta ao estado inicial. Retorna o modo usado.
+func (s *InstanceService) MigrateInstance(target *InstanceService, name string, live bool, progress ProgressFunc) (string, error) {
+	if progress == nil {
+		progress = func(int, string) {}
+	}
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return "", fmt.Errorf("LOCKED: container '%s' já está sendo operado", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, _, err := s.server.GetInstance(name)
+	if err != nil {
+		return "", fmt.Errorf("falha ao obter instância '%s': %w", name, err)
+	}
+	if exists, err := target.InstanceExists(name); err != nil {
+		return "", fmt.Errorf("falha ao consultar o destino: %w", err)
+	} else if exists {
+		return "", ErrMigrationTargetExists
+	}
+
+	running := strings.ToUpper(inst.Status) == "RUNNING"
+
+	if live && running && liveMigratable(inst) {
+		progress(10, "live migration")
+		err := s.copyTo(target, inst, true, progress)
+		if err == nil {
+			if err := s.removeSource(name); err != nil {
+				return MigrationLive, err
+			}
+			log.Printf("[LXD Provider] '%s' migrada com estado", name)
+			return MigrationLive, nil
+		}
+		log.Printf("[LXD Provider] Migração com estado de '%s' falhou, tentando a frio: %v", name, err)
+		if err := target.discardCopy(name); err != nil {
+			return "", err
+		}
+	}
+
+	if running {
+		progress(10, "stopping instance")
+		if _, err := s.shutdown(name, MigrationStopGrace); err != nil {
+			return "", err
+		}
+	}
+
+	// Daqui em diante qualquer falha devolve a origem ao estado inicial
+	restore := func(cause error) error {
+		if err := target.discardCopy(name); err != nil {
+			return fmt.Errorf("%w; %v", cause, err)
+		}
+		if running {
+			if err := s.start(name); err != nil {
+				return fmt.Errorf("%w; falha ao religar a origem: %v", cause, err)
+			}
+		}
+		return cause
+	}
+
+	progress(20, "transferring")
+	inst.Status = "Stopped"
+	if err := s.copyTo(target, inst, false, progress); err != nil {
+		return "", restore(err)
+	}
+	if running {
+		progress(90, "starting on target")
+		if err := target.start(name); err != nil {
+			return "", restore(fmt.Errorf("falha ao iniciar '%s' no destino: %w", name, err))
+		}
+	}
+
+	if err := s.removeSource(name); err != nil {
+		return MigrationCold, err
+	}
+	log.Printf("[LXD Provider] '%s' migrada a frio", name)
+	return MigrationCold, nil
+}
+
+// liveMigratable diz se vale tentar a migração com estado: VMs só migram
+// assim com migration.stateful; containers dependem do CRIU no host, o que
+// só se descobre tentando.
+func liveMigratable(inst *api.Instance) bool {
+	if inst.Type == "virtual-machine" {
+		return inst.Config["migration.stateful"] == "true"
+	}
+	return true
+}
+
+// copyTo copia inst para target (modo pull: o destino busca da origem).
+func (s *InstanceService) copyTo(target *InstanceService, inst *api.Instance, live bool, progress ProgressFunc) error {
+	op, err := target.server.CopyInstance(s.server, *inst, &lxd.InstanceCopyArgs{
+		Name: inst.Name,
+		Live: live,
+	})
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar migração de '%s': %w", inst.Name, err)
+	}
+
+	_, err = op.AddHandler(func(o api.Operation) {
+		for _, key := range []string{"fs_progress", "progress"} {
+			if raw, ok := o.Metadata[key].(string); ok {
+				progress(50, "transferring "+raw)
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("[LXD Provider] Progresso indisponível para a migração: %v", err)
+	}
+
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro durante a migração de '%s': %w", inst.Name, err)
+	}
+	return nil
+}
+
+// removeSource exclui a origem depois da migração. Se falhar, a instância
+// está de pé no destino e a cópia antiga fica para remoção manual.
+func (s *InstanceService) removeSource(name string) error {
+	if inst, _, err := s.server.GetInstance(name); err == nil && strings.ToUpper(inst.Status) == "RUNNING" {
+		if err := s.stop(name, -1, true); err != nil {
+			return fmt.Errorf("instância migrada, mas a origem não parou: %w", err)
+		}
+	}
+	op, err := s.server.DeleteInstance(name)
+	if err == nil {
+		err = op.Wait()
+	}
+	if err != nil {
+		return fmt.Errorf("instância migrada, mas a origem não foi excluída: %w", err)
+	}
+	return nil
+}
+
+// discardCopy remove uma cópia parcial deixada por uma migração que falhou.
+func (s *InstanceService) discardCopy(name string) error {
+	exists, err := s.InstanceExists(name)
+	if err != nil || !exists {
+		return err
+	}
+	op, err := s.server.DeleteInstance(name)
+	if err == nil {
+		err = op.Wait()
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao remover cópia parcial de '%s' no destino: %w", name, err)
+	}
+	return nil
+}
+
+func (s *InstanceService) start(name string) error {
+	op, err := s.server.UpdateInstanceState(name, api.InstanceStatePut{Action: "start", Timeout: -1}, "")
+	if err != nil {
+		return fmt.Errorf("falha ao iniciar container: %w", err)
+	}
+	return op.Wait()
+}
@@ -0,0 +1,129 @@
+package lxc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+type remoteOp struct {
+	lxd.RemoteOperation
+	err error
+}
+
+func (o remoteOp) AddHandler(func(api.Operation)) (*lxd.EventTarget, error) { return nil, nil }
+func (o remoteOp) Wait() error                                              { return o.err }
+
+// migrateServer guarda instâncias em memória; como destino, CopyInstance
+// pode falhar deixando uma cópia parcial
+type migrateServer struct {
+	lxd.InstanceServer
+	instances map[string]*api.Instance
+	failLive  bool
+	failCopy  bool
+}
+
+func newMigrateServer(instances ...api.Instance) *migrateServer {
+	s := &migrateServer{instances: map[string]*api.Instance{}}
+	for i := range instances {
+		s.instances[instances[i].Name] = &instances[i]
+	}
+	return s
+}
+
+func (s *migrateServer) GetInstance(name string) (*api.Instance, string, error) {
+	inst, ok := s.instances[name]
+	if !ok {
+		return nil, "", api.StatusErrorf(http.StatusNotFound, "not found")
+	}
+	copied := *inst
+	return &copied, "", nil
+}
+
+func (s *migrateServer) UpdateInstanceState(name string, req api.InstanceStatePut, etag string) (lxd.Operation, error) {
+	if req.Action == "start" {
+		s.instances[name].Status = "Running"
+	} else {
+		s.instances[name].Status = "Stopped"
+	}
+	return doneOp{}, nil
+}
+
+func (s *migrateServer) DeleteInstance(name string) (lxd.Operation, error) {
+	delete(s.instances, name)
+	return doneOp{}, nil
+}
+
+func (s *migrateServer) CopyInstance(source lxd.InstanceServer, inst api.Instance, args *lxd.InstanceCopyArgs) (lxd.RemoteOperation, error) {
+	if args.Live && s.failLive {
+		return remoteOp{err: errors.New("criu not found")}, nil
+	}
+	inst.Status = "Stopped"
+	if args.Live {
+		inst.Status = "Running"
+	}
+	s.instances[inst.Name] = &inst
+	if s.failCopy {
+		return remoteOp{err: errors.New("connection reset")}, nil
+	}
+	return remoteOp{}, nil
+}
+
+func TestMigrateInstance(t *testing.T) {
+	cases := []struct {
+		name     string
+		live     bool
+		failLive bool
+		want     string
+	}{
+		{name: "cold", want: MigrationCold},
+		{name: "live", live: true, want: MigrationLive},
+		{name: "live unsupported falls back to cold", live: true, failLive: true, want: MigrationCold},
+	}
+
+	for _, tc := range cases {
+		source := newMigrateServer(api.Instance{Name: "web", Status: "Running"})
+		target := newMigrateServer()
+		target.failLive = tc.failLive
+
+		src := &InstanceService{server: source}
+		got, err := src.MigrateInstance(&InstanceService{server: target}, "web", tc.live, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: mode = %q, want %q", tc.name, got, tc.want)
+		}
+		if _, ok := source.instances["web"]; ok {
+			t.Errorf("%s: source not removed", tc.name)
+		}
+		if inst, ok := target.instances["web"]; !ok || inst.Status != "Running" {
+			t.Errorf("%s: instance not running on target: %+v", tc.name, inst)
+		}
+	}
+}
+
+func TestMigrateInstanceFailureKeepsSource(t *testing.T) {
+	source := newMigrateServer(api.Instance{Name: "web", Status: "Running"})
+	target := newMigrateServer()
+	target.failCopy = true
+
+	src := &InstanceService{server: source}
+	if _, err := src.MigrateInstance(&InstanceService{server: target}, "web", false, nil); err == nil {
+		t.Fatal("expected the failed copy to be reported")
+	}
+	if inst, ok := source.instances["web"]; !ok || inst.Status != "Running" {
+		t.Errorf("source not restored: %+v", inst)
+	}
+	if _, ok := target.instances["web"]; ok {
+		t.Error("partial copy left on target")
+	}
+
+	taken := newMigrateServer(api.Instance{Name: "web"})
+	if _, err := src.MigrateInstance(&InstanceService{server: taken}, "web", false, nil); !errors.Is(err, ErrMigrationTargetExists) {
+		t.Errorf("name taken on target: got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// OPERATIONS
+// ============================================================================
+
+// OperationFunc recebe o ID de uma operação do LXD assim que ela é criada.
+type OperationFunc func(operationID string)
+
+// ErrOperationNotFound indica operação inexistente, já expirada no LXD ou de
+// outra instância.
+var ErrOperationNotFound = errors.New("operação não encontrada")
+
+// ErrOperationNotCancellable indica operação que já terminou ou que o LXD
+// não permite cancelar (nem toda etapa aceita cancelamento).
+var ErrOperationNotCancellable = errors.New("operação não pode ser cancelada")
+
+// CancelOperation cancela a operação id do LXD, desde que ela pertença à
+// instância name. Devolve o estado em que a operação estava.
+func (s *InstanceService) CancelOperation(name, id string) (string, error) {
+	op, _, err := s.server.GetOperation(id)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return "", ErrOperationNotFound
+		}
+		return "", fmt.Errorf("falha ao consultar operação %s: %w", id, err)
+	}
+	if !operationTargets(op, name) {
+		return "", ErrOperationNotFound
+	}
+
+	if op.StatusCode.IsFinal() {
+		return op.Status, fmt.Errorf("%w: já terminou (%s)", ErrOperationNotCancellable, op.Status)
+	}
+	if !op.MayCancel {
+		return op.Status, fmt.Errorf("%w: o LXD não permite cancelar esta etapa", ErrOperationNotCancellable)
+	}
+
+	log.Printf("[LXD Provider] Cancelando operação %s de %s (%s)", id, name, op.Description)
+	if err := s.server.DeleteOperation(id); err != nil {
+		// Pode ter terminado entre a consulta e o cancelamento
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return op.Status, fmt.Errorf("%w: já terminou", ErrOperationNotCancellable)
+		}
+		return op.Status, fmt.Errorf("falha ao cancelar operação %s: %w", id, err)
+	}
+	return op.Status, nil
+}
+
+// operationTargets diz se a operação afeta a instância name, pelos recursos
+// que o LXD lista nela ("/1.0/instances/<name>" e afins).
+func operationTargets(op *api.Operation, name string) bool {
+	for _, kind := range []string{"instances", "containers", "virtual-machines"} {
+		for _, url := range op.Resources[kind] {
+			path, _, _ := strings.Cut(url, "?")
+			if path == "/1.0/"+kind+"/"+name || strings.HasPrefix(path, "/1.0/"+kind+"/"+name+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package lxc
+
+import (
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestOperationTargets(t *testing.T) {
+	op := &api.Operation{Resources: map[string][]string{
+		"instances": {"/1.0/instances/web?project=default"},
+	}}
+	if !operationTargets(op, "web") {
+		t.Error("operation on web not recognized")
+	}
+	if operationTargets(op, "we") || operationTargets(op, "web2") {
+		t.Error("operation matched another instance by prefix")
+	}
+
+	snapshot := &api.Operation{Resources: map[string][]string{
+		"containers": {"/1.0/containers/db/snapshots/snap0"},
+	}}
+	if !operationTargets(snapshot, "db") {
+		t.Error("snapshot operation on db not recognized")
+	}
+	if operationTargets(&api.Operation{}, "web") {
+		t.Error("operation without resources must not match")
+	}
+}
@@ -0,0 +1,147 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// Protocolos aceitos no port forwarding (proxy devices)
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// ErrPortNotFound indica que a instância não tem o redirecionamento pedido
+var ErrPortNotFound = errors.New("porta não encontrada")
+
+// PortRange é o intervalo de portas do host que o port forwarding pode usar
+type PortRange struct {
+	Min int
+	Max int
+}
+
+// DefaultPortRange é o intervalo usado quando nenhum é configurado
+var DefaultPortRange = PortRange{Min: 10000, Max: 60000}
+
+// ParsePortRange lê "20000-30000"
+func ParsePortRange(s string) (PortRange, error) {
+	lo, hi, ok := strings.Cut(strings.TrimSpace(s), "-")
+	minPort, errMin := strconv.Atoi(strings.TrimSpace(lo))
+	maxPort, errMax := strconv.Atoi(strings.TrimSpace(hi))
+	if !ok || errMin != nil || errMax != nil {
+		return PortRange{}, fmt.Errorf("intervalo %q deve ter o formato min-max", s)
+	}
+	r := PortRange{Min: minPort, Max: maxPort}
+	if r.Min < 1 || r.Max > 65535 || r.Min > r.Max {
+		return PortRange{}, fmt.Errorf("intervalo %q deve estar entre 1 e 65535, com min <= max", s)
+	}
+	return r, nil
+}
+
+// Contains informa se port está no intervalo
+func (r PortRange) Contains(port int) bool {
+	return port >= r.Min && port <= r.Max
+}
+
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+var (
+	portRangesMu sync.RWMutex
+	portRanges   = map[string]PortRange{ProtocolTCP: DefaultPortRange, ProtocolUDP: DefaultPortRange}
+)
+
+// SetPortRanges define os intervalos por protocolo (da configuração, na inicialização)
+func SetPortRanges(tcp, udp PortRange) {
+	portRangesMu.Lock()
+	defer portRangesMu.Unlock()
+	portRanges = map[string]PortRange{ProtocolTCP: tcp, ProtocolUDP: udp}
+}
+
+// PortRangeFor devolve o intervalo permitido para o protocolo
+func PortRangeFor(protocol string) PortRange {
+	portRangesMu.RLock()
+	defer portRangesMu.RUnlock()
+	if r, ok := portRanges[protocol]; ok {
+		return r
+	}
+	return DefaultPortRange
+}
+
+// IsPortProtocol informa se p é um protocolo de port forwarding suportado
+func IsPortProtocol(p string) bool {
+	return p == ProtocolTCP || p == ProtocolUDP
+}
+
+// proxyDeviceName é o device do redirecionamento. TCP mantém o nome antigo
+// (proxy-<porta>) para as regras já existentes; UDP ganha o próprio nome para
+// que as duas regras convivam na mesma porta do host.
+func proxyDeviceName(hostPort int, protocol string) string {
+	if protocol == ProtocolUDP {
+		return fmt.Sprintf("proxy-udp-%d", hostPort)
+	}
+	return fmt.Sprintf("proxy-%d", hostPort)
+}
+
+// proxyListens informa se o listen de um proxy device ("udp:0.0.0.0:5353")
+// ocupa hostPort no protocolo dado, qualquer que seja o endereço.
+func proxyListens(listen, protocol string, hostPort int) bool {
+	proto, port, ok := proxyAddress(listen)
+	return ok && proto == protocol && port == hostPort
+}
+
+// ProxyPort é uma porta do host ocupada por um proxy device
+type ProxyPort struct {
+	Instance      string
+	HostPort      int
+	Protocol      string
+	ContainerPort int
+}
+
+// ListProxyPorts lista as portas do host ocupadas pelos proxy devices de
+// todas as instâncias (containers e VMs), inclusive os criados fora do Axion.
+func (s *InstanceService) ListProxyPorts() ([]ProxyPort, error) {
+	instances, err := s.server.GetInstancesFull(api.InstanceTypeAny)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar proxy devices: %w", err)
+	}
+	return proxyPorts(instances), nil
+}
+
+func proxyPorts(instances []api.InstanceFull) []ProxyPort {
+	var ports []ProxyPort
+	for _, inst := range instances {
+		for _, dev := range inst.Devices {
+			if dev["type"] != "proxy" {
+				continue
+			}
+			protocol, hostPort, ok := proxyAddress(dev["listen"])
+			_, containerPort, connectOK := proxyAddress(dev["connect"])
+			if !ok || !connectOK || !IsPortProtocol(protocol) {
+				continue
+			}
+			ports = append(ports, ProxyPort{Instance: inst.Name, HostPort: hostPort, Protocol: protocol, ContainerPort: containerPort})
+		}
+	}
+	return ports
+}
+
+// proxyAddress separa "tcp:0.0.0.0:8080" em protocolo e porta
+func proxyAddress(addr string) (string, int, bool) {
+	proto, rest, ok := strings.Cut(addr, ":")
+	idx := strings.LastIndex(rest, ":")
+	if !ok || idx < 0 {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(rest[idx+1:])
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+	return proto, port, true
+}
@@ -0,0 +1,85 @@
+package lxc
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestProxyListens(t *testing.T) {
+	cases := []struct {
+		listen   string
+		protocol string
+		port     int
+		want     bool
+	}{
+		{listen: "tcp:0.0.0.0:10022", protocol: "tcp", port: 10022, want: true},
+		{listen: "tcp:0.0.0.0:10022", protocol: "udp", port: 10022, want: false},
+		{listen: "udp:10.0.10.1:15353", protocol: "udp", port: 15353, want: true},
+		{listen: "tcp:0.0.0.0:100220", protocol: "tcp", port: 10022, want: false},
+		{listen: "unix:/run/app.sock", protocol: "tcp", port: 10022, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := proxyListens(tc.listen, tc.protocol, tc.port); got != tc.want {
+			t.Errorf("proxyListens(%q, %q, %d) = %v, want %v", tc.listen, tc.protocol, tc.port, got, tc.want)
+		}
+	}
+}
+
+func TestProxyDeviceNameKeepsTCPName(t *testing.T) {
+	if got := proxyDeviceName(10022, ProtocolTCP); got != "proxy-10022" {
+		t.Errorf("tcp device = %q, want proxy-10022", got)
+	}
+	if got := proxyDeviceName(10022, ProtocolUDP); got != "proxy-udp-10022" {
+		t.Errorf("udp device = %q, want proxy-udp-10022", got)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	r, err := ParsePortRange("20000-30000")
+	if err != nil || r != (PortRange{Min: 20000, Max: 30000}) {
+		t.Fatalf("got %+v, %v", r, err)
+	}
+	if !r.Contains(20000) || !r.Contains(30000) || r.Contains(30001) {
+		t.Errorf("Contains should include both ends only")
+	}
+
+	for _, bad := range []string{"20000", "30000-20000", "0-100", "1-70000", "a-b"} {
+		if _, err := ParsePortRange(bad); err == nil {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+}
+
+func TestProxyPorts(t *testing.T) {
+	instances := []api.InstanceFull{
+		{Instance: api.Instance{Name: "web", Devices: map[string]map[string]string{
+			"proxy-10080":     {"type": "proxy", "listen": "tcp:0.0.0.0:10080", "connect": "tcp:127.0.0.1:80"},
+			"proxy-udp-15353": {"type": "proxy", "listen": "udp:10.0.10.1:15353", "connect": "udp:10.0.10.5:53", "nat": "true"},
+			"eth0":            {"type": "nic", "network": "axion-br"},
+		}}},
+		{Instance: api.Instance{Name: "manual", Devices: map[string]map[string]string{
+			"ssh":    {"type": "proxy", "listen": "tcp:0.0.0.0:2222", "connect": "tcp:127.0.0.1:22"},
+			"socket": {"type": "proxy", "listen": "unix:/run/app.sock", "connect": "unix:/run/app.sock"},
+		}}},
+	}
+
+	got := map[string]ProxyPort{}
+	for _, p := range proxyPorts(instances) {
+		got[p.Instance+"/"+p.Protocol+"/"+strconv.Itoa(p.HostPort)] = p
+	}
+	if len(got) != 3 {
+		t.Fatalf("ports = %v, want 3", got)
+	}
+	if p := got["web/tcp/10080"]; p.ContainerPort != 80 {
+		t.Errorf("web tcp = %+v", p)
+	}
+	if p := got["web/udp/15353"]; p.ContainerPort != 53 {
+		t.Errorf("web udp = %+v", p)
+	}
+	if _, ok := got["manual/tcp/2222"]; !ok {
+		t.Error("proxy not created by Axion missed")
+	}
+}
@@ -0,0 +1,98 @@
+package lxc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// PROCESSES
+// ============================================================================
+
+// Process é uma linha da tabela de processos da instância.
+type Process struct {
+	PID        int     `json:"pid"`
+	User       string  `json:"user"`
+	CPUPercent float64 `json:"cpu_percent"`
+	MemPercent float64 `json:"mem_percent"`
+	Command    string  `json:"command"`
+}
+
+// AllowedSignals são os sinais aceitos por KillProcess.
+var AllowedSignals = map[string]bool{
+	"TERM": true,
+	"KILL": true,
+	"INT":  true,
+	"HUP":  true,
+	"QUIT": true,
+	"USR1": true,
+	"USR2": true,
+	"STOP": true,
+	"CONT": true,
+}
+
+// NormalizeSignal aceita "TERM", "SIGTERM" ou "term" e devolve o nome canônico.
+func NormalizeSignal(signal string) (string, error) {
+	sig := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signal)), "SIG")
+	if sig == "" {
+		sig = "TERM"
+	}
+	if !AllowedSignals[sig] {
+		return "", fmt.Errorf("sinal não permitido: %q", signal)
+	}
+	return sig, nil
+}
+
+// ListProcesses executa ps dentro da instância e devolve a lista estruturada.
+func (s *InstanceService) ListProcesses(name string) ([]Process, error) {
+	result, err := s.ExecCommand(name, []string{"ps", "-eo", "pid=,user=,pcpu=,pmem=,args="})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("ps retornou %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	return parseProcessList(result.Stdout), nil
+}
+
+// parseProcessList interpreta a saída de "ps -eo pid=,user=,pcpu=,pmem=,args=".
+// Linhas malformadas são ignoradas.
+func parseProcessList(out string) []Process {
+	processes := []Process{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(fields[2], 64)
+		mem, _ := strconv.ParseFloat(fields[3], 64)
+
+		processes = append(processes, Process{
+			PID:        pid,
+			User:       fields[1],
+			CPUPercent: cpu,
+			MemPercent: mem,
+			Command:    strings.Join(fields[4:], " "),
+		})
+	}
+	return processes
+}
+
+// KillProcess envia um sinal (já normalizado) a um processo da instância.
+func (s *InstanceService) KillProcess(name string, pid int, signal string) error {
+	result, err := s.ExecCommand(name, []string{"kill", "-s", signal, strconv.Itoa(pid)})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("kill retornou %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
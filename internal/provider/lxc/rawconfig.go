@@ -0,0 +1,129 @@
+package lxc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ============================================================================
+// RAW CONFIG
+// ============================================================================
+
+// RawConfigKeys são as chaves aceitas em raw_config, além das user.* livres.
+// Ficam de fora as que elevam privilégios (security.privileged, nesting,
+// idmap, syscalls, raw.*) e as que a cota global controla (limits.cpu,
+// limits.memory), ajustadas só pelos campos próprios da API.
+var RawConfigKeys = []string{
+	CPUAllowanceKey,
+	CPUPriorityKey,
+	"limits.processes",
+	"limits.memory.swap",
+	"limits.memory.swap.priority",
+	"limits.disk.priority",
+	"limits.network.priority",
+	"boot.autostart",
+	"boot.autostart.delay",
+	"boot.autostart.priority",
+	"boot.host_shutdown_timeout",
+	"boot.stop.priority",
+	"security.protection.delete",
+	"security.secureboot",
+}
+
+// rawConfigUserPrefix são metadados do usuário, sem efeito no host
+const rawConfigUserPrefix = "user."
+
+// rawConfigReserved são chaves user.* que o Axion já controla por campos próprios.
+var rawConfigReserved = map[string]bool{
+	"user.user-data":      true,
+	"user.network-config": true,
+	"user.vendor-data":    true,
+}
+
+// ValidateRawConfig rejeita chaves fora de RawConfigKeys e de user.*, ou
+// reservadas. Todas as chaves inválidas são listadas na mensagem, em ordem.
+// Valores das chaves de CPU ponderada também são validados.
+func ValidateRawConfig(config map[string]string) error {
+	var invalid []string
+	for key := range config {
+		if !rawConfigAllowed(key) {
+			invalid = append(invalid, key)
+		}
+	}
+	if len(invalid) == 0 {
+		return validateCPUConfig(config)
+	}
+
+	sort.Strings(invalid)
+	return fmt.Errorf("chaves não permitidas em raw_config: %s (aceitas: %s e %s*)",
+		strings.Join(invalid, ", "), strings.Join(RawConfigKeys, " "), rawConfigUserPrefix)
+}
+
+func rawConfigAllowed(key string) bool {
+	if strings.HasPrefix(key, rawConfigUserPrefix) {
+		return len(key) > len(rawConfigUserPrefix) && !rawConfigReserved[key]
+	}
+	for _, allowed := range RawConfigKeys {
+		if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateInstanceConfig mescla chaves de configuração na instância, sem validar.
+// Chamadores devem passar o mapa por ValidateRawConfig antes.
+func (s *InstanceService) UpdateInstanceConfig(name string, config map[string]string) error {
+	if _, busy := s.locks.LoadOrStore(name, true); busy {
+		return fmt.Errorf("LOCKED: container '%s' já está processando um comando. Tente novamente em alguns segundos", name)
+	}
+	defer s.locks.Delete(name)
+
+	inst, etag, err := s.server.GetInstance(name)
+	if err != nil {
+		return fmt.Errorf("falha ao obter configuração atual de %s: %w", name, err)
+	}
+
+	if inst.Config == nil {
+		inst.Config = make(map[string]string)
+	}
+	for k, v := range config {
+		inst.Config[k] = v
+	}
+
+	req := api.InstancePut{
+		Config:       inst.Config,
+		Devices:      inst.Devices,
+		Description:  inst.Description,
+		Profiles:     inst.Profiles,
+		Ephemeral:    inst.Ephemeral,
+		Architecture: inst.Architecture,
+	}
+
+	log.Printf("[LXD Provider] Aplicando raw_config em %s (%d chaves)", name, len(config))
+
+	op, err := s.server.UpdateInstance(name, req, etag)
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar atualização de configuração: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("erro ao aplicar configuração: %w", err)
+		}
+		return nil
+	case <-time.After(30 * time.Second):
+		return fmt.Errorf("TIMEOUT: atualização de configuração demorou muito")
+	}
+}
@@ -0,0 +1,41 @@
+package lxc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRawConfig(t *testing.T) {
+	ok := map[string]string{
+		"limits.processes":           "500",
+		"limits.cpu.priority":        "5",
+		"boot.autostart":             "false",
+		"security.protection.delete": "true",
+		"user.app-version":           "1.2",
+	}
+	if err := ValidateRawConfig(ok); err != nil {
+		t.Fatalf("expected allowed keys to pass: %v", err)
+	}
+
+	bad := map[string]string{
+		"volatile.eth0.hwaddr":    "x",
+		"raw.lxc":                 "lxc.apparmor.profile=unconfined",
+		"user.user-data":          "#cloud-config",
+		"user.":                   "x",
+		"security.privileged":     "true",
+		"security.nesting":        "true",
+		"security.idmap.isolated": "false",
+		"security.syscalls.deny":  "",
+		"limits.cpu":              "64",
+		"limits.memory":           "512GB",
+	}
+	err := ValidateRawConfig(bad)
+	if err == nil {
+		t.Fatal("expected disallowed keys to be rejected")
+	}
+	for key := range bad {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %q: %v", key, err)
+		}
+	}
+}
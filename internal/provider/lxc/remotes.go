@@ -0,0 +1,176 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ============================================================================
+// MULTI-REMOTE (FLEET)
+// ============================================================================
+
+// RemoteConfig é um remote LXD adicional, identificado por nome.
+type RemoteConfig struct {
+	Name string
+	ConnectionConfig
+}
+
+// Remote é um remote da frota. Client é nil quando a conexão inicial falhou
+// (ConnectErr diz o motivo); o remote segue listado como inacessível.
+type Remote struct {
+	Name       string
+	Client     *InstanceService
+	ConnectErr error
+}
+
+// RemoteWarning descreve um remote que não respondeu a uma listagem.
+type RemoteWarning struct {
+	Remote string `json:"remote"`
+	Error  string `json:"error"`
+}
+
+// ErrInstanceNotOnAnyRemote indica instância que nenhum remote acessível conhece.
+var ErrInstanceNotOnAnyRemote = errors.New("instância não encontrada em nenhum remote")
+
+// Fleet agrega vários remotes LXD. O primeiro é o primário: recebe as
+// criações e atende instâncias que ainda não existem em nenhum remote.
+type Fleet struct {
+	remotes []Remote
+	// location guarda em qual remote cada instância foi vista por último
+	location sync.Map
+}
+
+// NewFleet monta a frota com o cliente primário e os remotes adicionais.
+func NewFleet(primaryName string, primary *InstanceService, extra ...Remote) *Fleet {
+	remotes := append([]Remote{{Name: primaryName, Client: primary}}, extra...)
+	return &Fleet{remotes: remotes}
+}
+
+// ConnectRemotes conecta cada remote configurado. Falhas não interrompem a
+// inicialização: o remote entra na frota como inacessível.
+func ConnectRemotes(configs []RemoteConfig) []Remote {
+	remotes := make([]Remote, 0, len(configs))
+	for _, cfg := range configs {
+		client, err := NewClientWithConfig(cfg.ConnectionConfig)
+		if err != nil {
+			log.Printf("[LXD Fleet] Remote %s (%s) inacessível: %v", cfg.Name, cfg.URL, err)
+		}
+		remotes = append(remotes, Remote{Name: cfg.Name, Client: client, ConnectErr: err})
+	}
+	return remotes
+}
+
+// Remotes devolve os remotes na ordem configurada.
+func (f *Fleet) Remotes() []Remote {
+	return append([]Remote(nil), f.remotes...)
+}
+
+// Primary é o cliente do remote primário.
+func (f *Fleet) Primary() *InstanceService {
+	return f.remotes[0].Client
+}
+
+// ListInstances lista as instâncias de todos os remotes em paralelo,
+// marcando cada uma com o nome do remote. Remotes inacessíveis não impedem o
+// resultado: entram em warnings e suas instâncias ficam de fora.
+func (f *Fleet) ListInstances() ([]InstanceMetric, []RemoteWarning) {
+	type result struct {
+		instances []InstanceMetric
+		err       error
+	}
+	results := make([]result, len(f.remotes))
+
+	var wg sync.WaitGroup
+	for i, remote := range f.remotes {
+		if remote.Client == nil {
+			results[i].err = fmt.Errorf("sem conexão: %v", remote.ConnectErr)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, client *InstanceService) {
+			defer wg.Done()
+			results[i].instances, results[i].err = client.ListInstances()
+		}(i, remote.Client)
+	}
+	wg.Wait()
+
+	all := []InstanceMetric{}
+	warnings := []RemoteWarning{}
+	for i, r := range results {
+		name := f.remotes[i].Name
+		if r.err != nil {
+			warnings = append(warnings, RemoteWarning{Remote: name, Error: r.err.Error()})
+			continue
+		}
+		for _, inst := range r.instances {
+			inst.Remote = name
+			f.location.Store(inst.Name, name)
+			all = append(all, inst)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, warnings
+}
+
+// ForInstance devolve o cliente do remote que hospeda a instância. Usa o
+// último remote em que ela foi vista e, se não souber ou ela tiver saído de
+// lá, consulta os remotes em ordem.
+func (f *Fleet) ForInstance(name string) (*InstanceService, string, error) {
+	if cached, ok := f.location.Load(name); ok {
+		if remote, ok := f.remote(cached.(string)); ok && remote.Client != nil {
+			if exists, err := remote.Client.InstanceExists(name); err == nil && exists {
+				return remote.Client, remote.Name, nil
+			}
+		}
+		f.location.Delete(name)
+	}
+
+	for _, remote := range f.remotes {
+		if remote.Client == nil {
+			continue
+		}
+		exists, err := remote.Client.InstanceExists(name)
+		if err != nil {
+			log.Printf("[LXD Fleet] Erro ao procurar %s no remote %s: %v", name, remote.Name, err)
+			continue
+		}
+		if exists {
+			f.location.Store(name, remote.Name)
+			return remote.Client, remote.Name, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrInstanceNotOnAnyRemote, name)
+}
+
+// ErrRemoteUnavailable indica remote desconhecido ou sem conexão.
+var ErrRemoteUnavailable = errors.New("remote indisponível")
+
+// Client devolve o cliente do remote pelo nome.
+func (f *Fleet) Client(remote string) (*InstanceService, error) {
+	r, ok := f.remote(remote)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s não está configurado", ErrRemoteUnavailable, remote)
+	}
+	if r.Client == nil {
+		return nil, fmt.Errorf("%w: %s sem conexão: %v", ErrRemoteUnavailable, remote, r.ConnectErr)
+	}
+	return r.Client, nil
+}
+
+// Relocate registra que a instância passou a viver em remote (migração).
+func (f *Fleet) Relocate(name, remote string) {
+	f.location.Store(name, remote)
+}
+
+func (f *Fleet) remote(name string) (Remote, bool) {
+	for _, r := range f.remotes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Remote{}, false
+}
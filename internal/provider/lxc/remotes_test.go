@@ -0,0 +1,94 @@
+package lxc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+// fakeServer responde só às chamadas usadas pela Fleet; o resto entra em pânico
+type fakeServer struct {
+	lxd.InstanceServer
+	instances []string
+	down      bool
+}
+
+func (f *fakeServer) GetInstancesFull(api.InstanceType) ([]api.InstanceFull, error) {
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	var out []api.InstanceFull
+	for _, name := range f.instances {
+		out = append(out, api.InstanceFull{Instance: api.Instance{Name: name, Status: "Running", Config: map[string]string{}}})
+	}
+	return out, nil
+}
+
+func (f *fakeServer) GetInstanceState(string) (*api.InstanceState, string, error) {
+	return nil, "", errors.New("no state in tests")
+}
+
+func (f *fakeServer) GetInstance(name string) (*api.Instance, string, error) {
+	if f.down {
+		return nil, "", errors.New("connection refused")
+	}
+	for _, n := range f.instances {
+		if n == name {
+			return &api.Instance{Name: name}, "", nil
+		}
+	}
+	return nil, "", api.StatusErrorf(http.StatusNotFound, "not found")
+}
+
+func TestFleetListsAllRemotesWithPartialResults(t *testing.T) {
+	eu := &InstanceService{server: &fakeServer{instances: []string{"web", "db"}}}
+	us := &InstanceService{server: &fakeServer{instances: []string{"api"}}}
+	down := &InstanceService{server: &fakeServer{down: true}}
+
+	fleet := NewFleet("eu", eu,
+		Remote{Name: "us", Client: us},
+		Remote{Name: "asia", Client: down},
+		Remote{Name: "offline", ConnectErr: errors.New("dial timeout")},
+	)
+
+	instances, warnings := fleet.ListInstances()
+
+	want := map[string]string{"api": "us", "db": "eu", "web": "eu"}
+	if len(instances) != len(want) {
+		t.Fatalf("got %d instances, want %d: %+v", len(instances), len(want), instances)
+	}
+	for _, inst := range instances {
+		if want[inst.Name] != inst.Remote {
+			t.Errorf("%s tagged with remote %q, want %q", inst.Name, inst.Remote, want[inst.Name])
+		}
+	}
+
+	if len(warnings) != 2 || warnings[0].Remote != "asia" || warnings[1].Remote != "offline" {
+		t.Errorf("warnings = %+v, want asia and offline", warnings)
+	}
+}
+
+func TestFleetRoutesToTheHostingRemote(t *testing.T) {
+	eu := &InstanceService{server: &fakeServer{instances: []string{"web"}}}
+	us := &InstanceService{server: &fakeServer{instances: []string{"api"}}}
+	fleet := NewFleet("eu", eu, Remote{Name: "us", Client: us})
+
+	client, remote, err := fleet.ForInstance("api")
+	if err != nil || client != us || remote != "us" {
+		t.Fatalf("api routed to %q (%v), want us", remote, err)
+	}
+
+	if _, _, err := fleet.ForInstance("missing"); !errors.Is(err, ErrInstanceNotOnAnyRemote) {
+		t.Errorf("missing instance: got %v, want ErrInstanceNotOnAnyRemote", err)
+	}
+
+	// A instância migrou de remote: o cache antigo não pode prevalecer
+	us.server.(*fakeServer).instances = nil
+	eu.server.(*fakeServer).instances = append(eu.server.(*fakeServer).instances, "api")
+	if _, remote, _ := fleet.ForInstance("api"); remote != "eu" {
+		t.Errorf("moved instance routed to %q, want eu", remote)
+	}
+}
@@ -0,0 +1,41 @@
+package lxc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ErrInstanceRunning indica uma operação que o LXD só aceita com a instância parada
+var ErrInstanceRunning = errors.New("instância está rodando")
+
+// RenameInstance renomeia oldName para newName no LXD. O LXD só renomeia
+// instâncias paradas: rodando, devolve ErrInstanceRunning sem tocar nela.
+func (s *InstanceService) RenameInstance(oldName, newName string) error {
+	if _, busy := s.locks.LoadOrStore(oldName, true); busy {
+		return fmt.Errorf("LOCKED: %s está ocupado", oldName)
+	}
+	defer s.locks.Delete(oldName)
+
+	inst, _, err := s.server.GetInstance(oldName)
+	if err != nil {
+		return fmt.Errorf("falha ao obter instância '%s': %w", oldName, err)
+	}
+	if !strings.EqualFold(inst.Status, "Stopped") {
+		return fmt.Errorf("%w: pare '%s' (status %s) antes de renomear", ErrInstanceRunning, oldName, inst.Status)
+	}
+
+	op, err := s.server.RenameInstance(oldName, api.InstancePost{Name: newName})
+	if err != nil {
+		return fmt.Errorf("falha ao solicitar renomeação de '%s': %w", oldName, err)
+	}
+	if err := op.Wait(); err != nil {
+		return fmt.Errorf("erro ao renomear '%s' para '%s': %w", oldName, newName, err)
+	}
+
+	log.Printf("[LXD Provider] '%s' renomeada para '%s'", oldName, newName)
+	return nil
+}
@@ -0,0 +1,43 @@
+package lxc
+
+import (
+	"errors"
+	"testing"
+
+	lxd "github.com/canonical/lxd/client"
+	"github.com/canonical/lxd/shared/api"
+)
+
+type renameServer struct {
+	lxd.InstanceServer
+	status  string
+	renamed string
+}
+
+func (s *renameServer) GetInstance(name string) (*api.Instance, string, error) {
+	return &api.Instance{Name: name, Status: s.status}, "", nil
+}
+
+func (s *renameServer) RenameInstance(name string, req api.InstancePost) (lxd.Operation, error) {
+	s.renamed = req.Name
+	return doneOp{}, nil
+}
+
+func TestRenameInstanceRequiresStoppedInstance(t *testing.T) {
+	running := &renameServer{status: "Running"}
+	err := (&InstanceService{server: running}).RenameInstance("web", "web-old")
+	if !errors.Is(err, ErrInstanceRunning) {
+		t.Errorf("running instance: got %v, want ErrInstanceRunning", err)
+	}
+	if running.renamed != "" {
+		t.Error("running instance must not be renamed")
+	}
+
+	stopped := &renameServer{status: "Stopped"}
+	if err := (&InstanceService{server: stopped}).RenameInstance("web", "web-old"); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.renamed != "web-old" {
+		t.Errorf("renamed to %q, want web-old", stopped.renamed)
+	}
+}
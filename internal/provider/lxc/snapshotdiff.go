@@ -0,0 +1,198 @@
+package lxc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// SNAPSHOT DIFF
+// ============================================================================
+
+// ErrSnapshotDiffUnsupported indica um driver de storage cujos snapshots não
+// ficam acessíveis como diretório no host (zfs, lvm, ceph...) ou uma VM.
+var ErrSnapshotDiffUnsupported = errors.New("snapshot diff not supported for this pool")
+
+// ErrInvalidSnapshotName indica um nome de snapshot que sairia do diretório
+// de snapshots da instância ao montar o caminho no host.
+var ErrInvalidSnapshotName = errors.New("invalid snapshot name")
+
+// snapshotDiffDrivers são os drivers com snapshots montados como diretórios
+var snapshotDiffDrivers = map[string]bool{"dir": true, "btrfs": true}
+
+// MaxSnapshotDiffChanges limita o tamanho da resposta; além disso Truncated=true
+const MaxSnapshotDiffChanges = 10000
+
+// SnapshotDiff lista os caminhos que mudaram entre dois snapshots.
+type SnapshotDiff struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Added     []string `json:"added"`
+	Modified  []string `json:"modified"`
+	Deleted   []string `json:"deleted"`
+	Truncated bool     `json:"truncated"`
+}
+
+// DefaultLXDDir é o diretório de dados do LXD instalado via snap
+const DefaultLXDDir = "/var/snap/lxd/common/lxd"
+
+var (
+	lxdDirMu sync.RWMutex
+	lxdDir   = DefaultLXDDir
+)
+
+// SetLXDDir define o diretório de dados do LXD no host (da configuração, na
+// inicialização)
+func SetLXDDir(dir string) {
+	lxdDirMu.Lock()
+	defer lxdDirMu.Unlock()
+	lxdDir = dir
+}
+
+// LXDDir é o diretório de dados do LXD no host
+func LXDDir() string {
+	lxdDirMu.RLock()
+	defer lxdDirMu.RUnlock()
+	return lxdDir
+}
+
+// DiffSnapshots compara o rootfs de dois snapshots de um container lendo os
+// diretórios dos snapshots no host. Só funciona com o control plane no mesmo
+// host do LXD e pools dir/btrfs; nos demais retorna ErrSnapshotDiffUnsupported.
+func (s *InstanceService) DiffSnapshots(ctx context.Context, name, from, to string) (*SnapshotDiff, error) {
+	// Os nomes viram caminhos no host: nada de barras nem ".."
+	for _, snap := range []string{from, to} {
+		if snap == "" || snap == "." || strings.Contains(snap, "/") || strings.Contains(snap, "..") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSnapshotName, snap)
+		}
+	}
+
+	inst, _, err := s.server.GetInstance(name)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar instância %s: %w", name, err)
+	}
+	if inst.Type != "container" {
+		return nil, fmt.Errorf("%w: instance type %s", ErrSnapshotDiffUnsupported, inst.Type)
+	}
+
+	poolName := inst.ExpandedDevices["root"]["pool"]
+	pool, _, err := s.server.GetStoragePool(poolName)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar pool %s: %w", poolName, err)
+	}
+	if !snapshotDiffDrivers[pool.Driver] {
+		return nil, fmt.Errorf("%w: driver %s", ErrSnapshotDiffUnsupported, pool.Driver)
+	}
+
+	for _, snap := range []string{from, to} {
+		if _, _, err := s.server.GetInstanceSnapshot(name, snap); err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", snap, err)
+		}
+	}
+
+	base := filepath.Join(LXDDir(), "storage-pools", poolName, "containers-snapshots", name)
+	diff, err := diffTrees(ctx, filepath.Join(base, from, "rootfs"), filepath.Join(base, to, "rootfs"), MaxSnapshotDiffChanges)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: snapshot não acessível no host (%v)", ErrSnapshotDiffUnsupported, err)
+		}
+		return nil, err
+	}
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+type fileSig struct {
+	mode    fs.FileMode
+	size    int64
+	modTime time.Time
+	link    string
+}
+
+// diffTrees compara duas árvores por tipo/permissão, tamanho, mtime e alvo de
+// symlink, sem ler o conteúdo dos arquivos.
+func diffTrees(ctx context.Context, fromRoot, toRoot string, limit int) (*SnapshotDiff, error) {
+	before, err := scanTree(ctx, fromRoot)
+	if err != nil {
+		return nil, err
+	}
+	after, err := scanTree(ctx, toRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{Added: []string{}, Modified: []string{}, Deleted: []string{}}
+	for path, sig := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, path)
+		case old != sig:
+			diff.Modified = append(diff.Modified, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			diff.Deleted = append(diff.Deleted, path)
+		}
+	}
+
+	// Ordena antes de cortar para que o resultado truncado seja estável
+	remaining := limit
+	for _, list := range []*[]string{&diff.Added, &diff.Modified, &diff.Deleted} {
+		sort.Strings(*list)
+		if len(*list) > remaining {
+			*list = (*list)[:remaining]
+			diff.Truncated = true
+		}
+		remaining -= len(*list)
+	}
+	return diff, nil
+}
+
+func scanTree(ctx context.Context, root string) (map[string]fileSig, error) {
+	if _, err := os.Lstat(root); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]fileSig)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sig := fileSig{mode: info.Mode()}
+		// mtime e tamanho de diretórios mudam com qualquer entrada; o que
+		// importa para o diff são as entradas em si
+		if !info.IsDir() {
+			sig.size = info.Size()
+			sig.modTime = info.ModTime()
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			sig.link, _ = os.Readlink(path)
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		out["/"+filepath.ToSlash(rel)] = sig
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,67 @@
+package lxc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffTrees(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	write := func(root, rel, content string, mtime time.Time) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write(from, "etc/hosts", "127.0.0.1 localhost", old)
+	write(to, "etc/hosts", "127.0.0.1 localhost", old)
+	write(from, "etc/motd", "hello", old)
+	write(to, "etc/motd", "hello, world", time.Now())
+	write(from, "tmp/gone", "x", old)
+	write(to, "var/log/new.log", "y", old)
+
+	diff, err := diffTrees(context.Background(), from, to, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"/var", "/var/log", "/var/log/new.log"}; !reflect.DeepEqual(diff.Added, want) {
+		t.Errorf("Added = %v, want %v", diff.Added, want)
+	}
+	if want := []string{"/etc/motd"}; !reflect.DeepEqual(diff.Modified, want) {
+		t.Errorf("Modified = %v, want %v", diff.Modified, want)
+	}
+	if want := []string{"/tmp", "/tmp/gone"}; !reflect.DeepEqual(diff.Deleted, want) {
+		t.Errorf("Deleted = %v, want %v", diff.Deleted, want)
+	}
+
+	limited, err := diffTrees(context.Background(), from, to, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !limited.Truncated {
+		t.Error("expected Truncated with a limit of 2")
+	}
+}
+
+func TestDiffSnapshotsRejectsPathNames(t *testing.T) {
+	s := &InstanceService{}
+	for _, pair := range [][2]string{{"../../../../etc", "snap1"}, {"snap1", "a/b"}, {"..", "snap1"}, {"snap1", ""}} {
+		if _, err := s.DiffSnapshots(context.Background(), "web-1", pair[0], pair[1]); !errors.Is(err, ErrInvalidSnapshotName) {
+			t.Errorf("DiffSnapshots(%q, %q) err = %v, want ErrInvalidSnapshotName", pair[0], pair[1], err)
+		}
+	}
+}
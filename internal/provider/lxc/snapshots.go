@@ -0,0 +1,36 @@
+package lxc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AutoSnapshotPrefix é reservado aos snapshots criados pelo agendador de
+// backups; a rotação de retenção apaga tudo que começa com ele.
+const AutoSnapshotPrefix = "auto-"
+
+// MaxSnapshotNameLength limita o nome do snapshot
+const MaxSnapshotNameLength = 63
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// ValidateSnapshotName aplica as regras de nome do LXD antes de criar o job:
+// letras, números e hífens (sem hífen nas pontas), sem barras e com até
+// MaxSnapshotNameLength caracteres. Nomes auto-* são recusados para que o
+// pruner não apague snapshots do usuário.
+func ValidateSnapshotName(name string) error {
+	if name == "" {
+		return fmt.Errorf("nome do snapshot é obrigatório")
+	}
+	if len(name) > MaxSnapshotNameLength {
+		return fmt.Errorf("nome do snapshot excede %d caracteres", MaxSnapshotNameLength)
+	}
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("nome do snapshot inválido %q: use apenas letras, números e hífens", name)
+	}
+	if strings.HasPrefix(strings.ToLower(name), AutoSnapshotPrefix) {
+		return fmt.Errorf("o prefixo %q é reservado aos snapshots automáticos", AutoSnapshotPrefix)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package lxc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSnapshotName(t *testing.T) {
+	valid := []string{"before-upgrade", "v2", "Release-2024-01-01", strings.Repeat("a", MaxSnapshotNameLength)}
+	for _, name := range valid {
+		if err := ValidateSnapshotName(name); err != nil {
+			t.Errorf("%q should be valid: %v", name, err)
+		}
+	}
+
+	invalid := []string{"", "a/b", "with space", "-lead", "trail-", "dot.name", "auto-backup-1", "AUTO-x", strings.Repeat("a", MaxSnapshotNameLength+1)}
+	for _, name := range invalid {
+		if err := ValidateSnapshotName(name); err == nil {
+			t.Errorf("%q should be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package lxc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// ============================================================================
+// STORAGE POOLS
+// ============================================================================
+
+// DefaultStoragePool é o pool usado quando a criação não escolhe um.
+const DefaultStoragePool = "axion"
+
+// StoragePool resume um pool do LXD com capacidade e uso em bytes.
+// Total/Used ficam zerados quando o driver não reporta recursos.
+type StoragePool struct {
+	Name        string `json:"name"`
+	Driver      string `json:"driver"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+	TotalBytes  uint64 `json:"total_bytes"`
+	UsedBytes   uint64 `json:"used_bytes"`
+}
+
+func poolOrDefault(pool string) string {
+	if pool == "" {
+		return DefaultStoragePool
+	}
+	return pool
+}
+
+// ListStoragePools lista os pools do LXD ordenados por nome.
+func (s *InstanceService) ListStoragePools() ([]StoragePool, error) {
+	pools, err := s.server.GetStoragePools()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao listar storage pools: %w", err)
+	}
+
+	out := make([]StoragePool, 0, len(pools))
+	for _, p := range pools {
+		pool := StoragePool{
+			Name:        p.Name,
+			Driver:      p.Driver,
+			Status:      p.Status,
+			Description: p.Description,
+			Default:     p.Name == DefaultStoragePool,
+		}
+		if res, err := s.server.GetStoragePoolResources(p.Name); err == nil {
+			pool.TotalBytes = res.Space.Total
+			pool.UsedBytes = res.Space.Used
+		} else {
+			log.Printf("[LXD Provider] Sem recursos para o pool %s: %v", p.Name, err)
+		}
+		out = append(out, pool)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// StoragePoolExists confirma que o pool existe no LXD.
+func (s *InstanceService) StoragePoolExists(name string) (bool, error) {
+	names, err := s.server.GetStoragePoolNames()
+	if err != nil {
+		return false, fmt.Errorf("falha ao listar storage pools: %w", err)
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
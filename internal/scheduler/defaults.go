@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"aexon/internal/db"
+)
+
+// ImportDefaults are the backup settings given to instances imported from LXD
+// by RunStartupSync.
+type ImportDefaults struct {
+	BackupEnabled   bool
+	BackupSchedule  string
+	BackupRetention int
+}
+
+// LoadImportDefaults reads AXION_IMPORT_BACKUP_ENABLED (default false),
+// AXION_IMPORT_BACKUP_SCHEDULE (default @daily) and
+// AXION_IMPORT_BACKUP_RETENTION (default 7). The schedule is parsed here so
+// that a bad value fails at startup instead of breaking every import.
+func LoadImportDefaults() (ImportDefaults, error) {
+	d := ImportDefaults{
+		BackupEnabled:   false,
+		BackupSchedule:  "@daily",
+		BackupRetention: 7,
+	}
+
+	if raw := os.Getenv("AXION_IMPORT_BACKUP_ENABLED"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return d, fmt.Errorf("AXION_IMPORT_BACKUP_ENABLED: %w", err)
+		}
+		d.BackupEnabled = enabled
+	}
+
+	if raw := os.Getenv("AXION_IMPORT_BACKUP_SCHEDULE"); raw != "" {
+		d.BackupSchedule = raw
+	}
+	if _, err := db.GetNextRunTime(d.BackupSchedule); err != nil {
+		return d, fmt.Errorf("AXION_IMPORT_BACKUP_SCHEDULE: %w", err)
+	}
+
+	if raw := os.Getenv("AXION_IMPORT_BACKUP_RETENTION"); raw != "" {
+		retention, err := strconv.Atoi(raw)
+		if err != nil || retention < 1 {
+			return d, fmt.Errorf("AXION_IMPORT_BACKUP_RETENTION: must be a positive integer, got %q", raw)
+		}
+		d.BackupRetention = retention
+	}
+
+	return d, nil
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+	"aexon/internal/types"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// AutoBackupPrefix names the snapshots taken by the backup schedule. Only
+// these are subject to retention; manual snapshots are never pruned.
+const AutoBackupPrefix = lxc.AutoSnapshotPrefix + "backup-"
+
+// AutoBackupName is the snapshot name for a scheduled run at t
+func AutoBackupName(t time.Time) string {
+	return AutoBackupPrefix + t.UTC().Format("2006-01-02-15-04-05")
+}
+
+// SnapshotsToPrune returns the scheduled snapshots the retention policy does
+// not keep, oldest first. A nil or simple policy keeps the newest retention
+// ones; a gfs policy keeps the ones gfsKeep selects.
+func SnapshotsToPrune(snapshots []api.InstanceSnapshot, retention int, policy *types.RetentionPolicy) []api.InstanceSnapshot {
+	var autoBackups []api.InstanceSnapshot
+	for _, snap := range snapshots {
+		if strings.HasPrefix(snap.Name, AutoBackupPrefix) {
+			autoBackups = append(autoBackups, snap)
+		}
+	}
+
+	sort.Slice(autoBackups, func(i, j int) bool {
+		return autoBackups[i].CreatedAt.Before(autoBackups[j].CreatedAt)
+	})
+
+	if policy == nil || policy.Mode != types.RetentionGFS {
+		if len(autoBackups) <= retention {
+			return nil
+		}
+		return autoBackups[:len(autoBackups)-retention]
+	}
+
+	times := make([]time.Time, len(autoBackups))
+	for i, snap := range autoBackups {
+		times[i] = snap.CreatedAt
+	}
+	var prune []api.InstanceSnapshot
+	for i, keep := range gfsKeep(times, *policy) {
+		if !keep {
+			prune = append(prune, autoBackups[i])
+		}
+	}
+	return prune
+}
+
+// gfsLevels are the GFS periods, each with its count in the policy and the
+// key shared by every timestamp of the same period (UTC)
+var gfsLevels = []struct {
+	count  func(types.RetentionPolicy) int
+	period func(time.Time) string
+}{
+	{
+		count:  func(p types.RetentionPolicy) int { return p.Hourly },
+		period: func(t time.Time) string { return t.Format("2006-01-02T15") },
+	},
+	{
+		count:  func(p types.RetentionPolicy) int { return p.Daily },
+		period: func(t time.Time) string { return t.Format("2006-01-02") },
+	},
+	{
+		count: func(p types.RetentionPolicy) int { return p.Weekly },
+		period: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+	},
+	{
+		count:  func(p types.RetentionPolicy) int { return p.Monthly },
+		period: func(t time.Time) string { return t.Format("2006-01") },
+	},
+}
+
+// gfsKeep marks which of times the policy keeps. For each level it walks from
+// the newest timestamp and keeps the first one of every period it enters,
+// until the level's count is used up; a timestamp kept by any level survives.
+func gfsKeep(times []time.Time, policy types.RetentionPolicy) []bool {
+	newestFirst := make([]int, len(times))
+	for i := range newestFirst {
+		newestFirst[i] = i
+	}
+	sort.SliceStable(newestFirst, func(a, b int) bool {
+		return times[newestFirst[a]].After(times[newestFirst[b]])
+	})
+
+	keep := make([]bool, len(times))
+	for _, level := range gfsLevels {
+		remaining := level.count(policy)
+		last := ""
+		for _, i := range newestFirst {
+			if remaining == 0 {
+				break
+			}
+			period := level.period(times[i].UTC())
+			if period == last {
+				continue
+			}
+			last = period
+			keep[i] = true
+			remaining--
+		}
+	}
+	return keep
+}
+
+// SnapshotPolicyPreview is what the backup schedule of an instance will do
+type SnapshotPolicyPreview struct {
+	NextRuns []time.Time `json:"next_runs"`
+	// Prune lists the existing snapshots the next run deletes
+	Prune []api.InstanceSnapshot `json:"prune"`
+}
+
+// PreviewSnapshotPolicy computes the next count runs of schedule after now and
+// which of snapshots the first of them prunes under retention and policy, the
+// same way the scheduled job does, without creating or deleting anything.
+func PreviewSnapshotPolicy(schedule string, retention int, policy *types.RetentionPolicy, snapshots []api.InstanceSnapshot, now time.Time, count int) (SnapshotPolicyPreview, error) {
+	preview := SnapshotPolicyPreview{NextRuns: []time.Time{}, Prune: []api.InstanceSnapshot{}}
+
+	runs, err := db.GetNextRunTimes(schedule, now, count)
+	if err != nil {
+		return preview, err
+	}
+	preview.NextRuns = append(preview.NextRuns, runs...)
+	if len(runs) == 0 {
+		return preview, nil
+	}
+
+	// The job prunes right after taking its snapshot, so count that one in
+	pending := api.InstanceSnapshot{Name: AutoBackupName(runs[0]), CreatedAt: runs[0]}
+	for _, snap := range SnapshotsToPrune(append(snapshots[:len(snapshots):len(snapshots)], pending), retention, policy) {
+		if snap.Name != pending.Name {
+			preview.Prune = append(preview.Prune, snap)
+		}
+	}
+	return preview, nil
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"aexon/internal/types"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+func TestPreviewSnapshotPolicy(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := func(name string, age time.Duration) api.InstanceSnapshot {
+		return api.InstanceSnapshot{Name: name, CreatedAt: now.Add(-age)}
+	}
+	snapshots := []api.InstanceSnapshot{
+		snap(AutoBackupPrefix+"c", 24*time.Hour),
+		snap(AutoBackupPrefix+"a", 72*time.Hour),
+		snap("before-upgrade", 96*time.Hour), // Manual: never pruned
+		snap(AutoBackupPrefix+"b", 48*time.Hour),
+	}
+
+	preview, err := PreviewSnapshotPolicy("@daily", 2, nil, snapshots, now, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRuns := []time.Time{
+		time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+	if len(preview.NextRuns) != len(wantRuns) {
+		t.Fatalf("next runs = %v, want %v", preview.NextRuns, wantRuns)
+	}
+	for i := range wantRuns {
+		if !preview.NextRuns[i].Equal(wantRuns[i]) {
+			t.Errorf("run %d = %s, want %s", i, preview.NextRuns[i], wantRuns[i])
+		}
+	}
+
+	// Next run adds a fourth auto backup; keeping 2 drops the two oldest
+	if len(preview.Prune) != 2 || preview.Prune[0].Name != AutoBackupPrefix+"a" || preview.Prune[1].Name != AutoBackupPrefix+"b" {
+		t.Errorf("prune = %+v, want %sa and %sb", preview.Prune, AutoBackupPrefix, AutoBackupPrefix)
+	}
+	if len(snapshots) != 4 {
+		t.Errorf("preview modified the snapshot list")
+	}
+}
+
+func TestPreviewSnapshotPolicyWithoutSchedule(t *testing.T) {
+	preview, err := PreviewSnapshotPolicy("", 1, nil, []api.InstanceSnapshot{{Name: AutoBackupPrefix + "a"}}, time.Now(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.NextRuns) != 0 || len(preview.Prune) != 0 {
+		t.Errorf("no schedule must neither run nor prune: %+v", preview)
+	}
+}
+
+func TestSnapshotsToPruneGFS(t *testing.T) {
+	// Hourly backups from 2025-03-01 00:00 to 2025-03-03 23:00 (Saturday to Monday)
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	var snapshots []api.InstanceSnapshot
+	for h := 0; h < 72; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		snapshots = append(snapshots, api.InstanceSnapshot{Name: AutoBackupName(ts), CreatedAt: ts})
+	}
+	snapshots = append(snapshots, api.InstanceSnapshot{Name: "manual", CreatedAt: start})
+
+	policy := &types.RetentionPolicy{Mode: types.RetentionGFS, Hourly: 3, Daily: 2, Weekly: 2, Monthly: 1}
+	kept := map[string]bool{}
+	for _, snap := range snapshots {
+		kept[snap.Name] = true
+	}
+	prune := SnapshotsToPrune(snapshots, 7, policy)
+	for _, snap := range prune {
+		delete(kept, snap.Name)
+	}
+
+	at := func(day, hour int) string {
+		return AutoBackupName(time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC))
+	}
+	want := map[string]bool{
+		"manual":  true, // Never pruned
+		at(3, 23): true, // Newest: hourly, daily, weekly (W10) and monthly
+		at(3, 22): true, // Hourly
+		at(3, 21): true, // Hourly
+		at(2, 23): true, // Daily for March 2, weekly for W09
+	}
+	if len(kept) != len(want) {
+		t.Errorf("kept %d snapshots, want %d: %v", len(kept), len(want), kept)
+	}
+	for name := range want {
+		if !kept[name] {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+
+	for i := 1; i < len(prune); i++ {
+		if prune[i].CreatedAt.Before(prune[i-1].CreatedAt) {
+			t.Fatal("prune list should be oldest first")
+		}
+	}
+}
+
+func TestGFSKeepSkipsEmptyPeriods(t *testing.T) {
+	// Gaps do not consume a slot: monthly 3 keeps January, March and April
+	times := []time.Time{
+		time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	keep := gfsKeep(times, types.RetentionPolicy{Mode: types.RetentionGFS, Monthly: 3})
+	want := []bool{false, true, true, true, false}
+	for i := range want {
+		if keep[i] != want[i] {
+			t.Errorf("%s: keep = %v, want %v", times[i].Format("2006-01-02"), keep[i], want[i])
+		}
+	}
+}
@@ -1,16 +1,14 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
 	"log"
-	"sort"
-	"strings"
 	"time"
 	"aexon/internal/db"
 	"aexon/internal/provider/lxc"
 	"aexon/internal/types"
 	"github.com/robfig/cron/v3"
-	"github.com/canonical/lxd/shared/api"
 )
 
 type BackupScheduler struct {
@@ -59,41 +57,46 @@ func (s *BackupScheduler) SyncJobs() {
 func (s *BackupScheduler) AddInstanceJob(instance types.Instance) {
 	log.Printf("Scheduling backup for instance %s with schedule %s", instance.Name, instance.BackupSchedule)
 	_, err := s.cron.AddFunc(instance.BackupSchedule, func() {
-		log.Printf("Running backup for instance %s", instance.Name)
-		snapshotName := "auto-backup-" + time.Now().UTC().Format("2006-01-02-15-04-05")
-		if err := s.lxcClient.CreateSnapshot(instance.Name, snapshotName); err != nil {
-			log.Printf("Error creating snapshot for instance %s: %v", instance.Name, err)
-			return
-		}
+		s.runBackup(instance)
+	})
+	if err != nil {
+		log.Printf("Error scheduling backup for instance %s: %v", instance.Name, err)
+	}
+}
 
-		snapshots, err := s.lxcClient.ListSnapshots(instance.Name)
-		if err != nil {
-			log.Printf("Error listing snapshots for instance %s: %v", instance.Name, err)
-			return
-		}
+// runBackup takes one scheduled snapshot of instance and prunes the old ones
+func (s *BackupScheduler) runBackup(instance types.Instance) {
+	// Runs that fire while backups are paused are skipped, not queued
+	if backupsPaused() {
+		log.Printf("Backups are paused; skipping scheduled backup for instance %s", instance.Name)
+		return
+	}
 
-		var autoBackups []api.InstanceSnapshot
-		for _, snap := range snapshots {
-			if strings.HasPrefix(snap.Name, "auto-backup-") {
-				autoBackups = append(autoBackups, snap)
-			}
-		}
+	log.Printf("Running backup for instance %s", instance.Name)
+	snapshotName := AutoBackupName(time.Now())
+	if err := s.lxcClient.CreateSnapshot(instance.Name, snapshotName); err != nil {
+		log.Printf("Error creating snapshot for instance %s: %v", instance.Name, err)
+		return
+	}
 
-		if len(autoBackups) > instance.BackupRetention {
-			sort.Slice(autoBackups, func(i, j int) bool {
-				return autoBackups[i].CreatedAt.Before(autoBackups[j].CreatedAt)
-			})
-
-			for i := 0; i < len(autoBackups)-instance.BackupRetention; i++ {
-				log.Printf("Deleting old backup %s for instance %s", autoBackups[i].Name, instance.Name)
-				if err := s.lxcClient.DeleteSnapshot(instance.Name, autoBackups[i].Name); err != nil {
-					log.Printf("Error deleting snapshot %s for instance %s: %v", autoBackups[i].Name, instance.Name, err)
-				}
-			}
-		}
-	})
+	snapshots, err := s.lxcClient.ListSnapshots(instance.Name)
 	if err != nil {
-		log.Printf("Error scheduling backup for instance %s: %v", instance.Name, err)
+		log.Printf("Error listing snapshots for instance %s: %v", instance.Name, err)
+		return
+	}
+
+	// Read at run time so policy changes apply without rescheduling
+	policy, err := db.GetInstanceRetentionPolicy(instance.Name)
+	if err != nil {
+		log.Printf("Error reading retention policy for instance %s, skipping prune: %v", instance.Name, err)
+		return
+	}
+
+	for _, snap := range SnapshotsToPrune(snapshots, instance.BackupRetention, policy) {
+		log.Printf("Deleting old backup %s for instance %s", snap.Name, instance.Name)
+		if err := s.lxcClient.DeleteSnapshot(instance.Name, snap.Name); err != nil {
+			log.Printf("Error deleting snapshot %s for instance %s: %v", snap.Name, instance.Name, err)
+		}
 	}
 }
 
@@ -103,4 +106,19 @@ func (s *BackupScheduler) ReloadInstance(name string) {
 		s.cron.Remove(entry.ID)
 	}
 	s.SyncJobs()
-}
\ No newline at end of file
+}
+
+// backupsPaused reads the global pause flag set by POST /admin/backups/pause.
+// If it cannot be read the backup runs: missing a backup is worse than taking
+// one during a pause. A variable so tests can stub the settings lookup.
+var backupsPaused = func() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state, err := db.NewSettingsRepository(db.GetService()).GetBackupPauseState(ctx)
+	if err != nil {
+		log.Printf("Error reading backup pause state, running backup anyway: %v", err)
+		return false
+	}
+	return state.Paused
+}
@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"testing"
+
+	"aexon/internal/types"
+)
+
+func TestRunBackupSkipsWhilePaused(t *testing.T) {
+	prev := backupsPaused
+	t.Cleanup(func() { backupsPaused = prev })
+
+	checked := 0
+	backupsPaused = func() bool {
+		checked++
+		return true
+	}
+
+	// No LXD client: a run that ignored the pause would panic on the snapshot
+	s := NewBackupScheduler(nil, nil)
+	s.runBackup(types.Instance{Name: "web-1", BackupRetention: 7})
+
+	if checked != 1 {
+		t.Errorf("pause flag read %d times, want 1", checked)
+	}
+}
@@ -2,97 +2,251 @@ package scheduler
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"aexon/internal/db"
 	"aexon/internal/provider/lxc"
 	"aexon/internal/types"
+
+	"github.com/canonical/lxd/shared/api"
+)
+
+// ErrNotInLXD is returned by SyncInstance when LXD has no instance with that name
+var ErrNotInLXD = errors.New("instance not found in LXD")
+
+// DefaultSyncConcurrency is how many instances the startup sync reconciles at once
+const DefaultSyncConcurrency = 8
+
+// ErrSyncInProgress is returned by RunStartupSync while another sync runs
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// SyncReport summarizes one RunStartupSync pass. Errors holds one entry per
+// failed instance, or the LXD listing error when nothing could be synced.
+type SyncReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Imported   []string
+	Updated    []string
+	Failed     []string
+	Errors     []error
+}
+
+// MarshalJSON renders the errors as their messages
+func (r *SyncReport) MarshalJSON() ([]byte, error) {
+	errs := make([]string, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err.Error()
+	}
+	return json.Marshal(struct {
+		StartedAt  time.Time `json:"started_at"`
+		FinishedAt time.Time `json:"finished_at"`
+		Imported   []string  `json:"imported"`
+		Updated    []string  `json:"updated"`
+		Failed     []string  `json:"failed"`
+		Errors     []string  `json:"errors"`
+	}{r.StartedAt, r.FinishedAt, nonNil(r.Imported), nonNil(r.Updated), nonNil(r.Failed), errs})
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+var (
+	syncMu   sync.Mutex
+	lastSync atomic.Pointer[SyncReport]
 )
 
-// RunStartupSync synchronizes instances from the LXD provider to the database.
-func RunStartupSync(dbConn *sql.DB, lxd *lxc.InstanceService) {
+// LastSyncReport returns the report of the last finished sync, nil before the first
+func LastSyncReport() *SyncReport {
+	return lastSync.Load()
+}
+
+// RunStartupSync synchronizes instances from the LXD provider to the database,
+// reconciling up to concurrency instances at a time, and returns what it did;
+// the report is also kept for LastSyncReport. Only one sync runs at a time.
+// Imported instances get the backup settings in defaults (see LoadImportDefaults).
+func RunStartupSync(dbConn *sql.DB, lxd *lxc.InstanceService, defaults ImportDefaults, concurrency int) (*SyncReport, error) {
+	if !syncMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer syncMu.Unlock()
+	return runSync(lxd, defaults, concurrency), nil
+}
+
+// StartSync runs RunStartupSync in the background, for callers that cannot
+// wait for it; the outcome is read with LastSyncReport. Returns
+// ErrSyncInProgress right away when a sync is already running.
+func StartSync(lxd *lxc.InstanceService, defaults ImportDefaults, concurrency int) error {
+	if !syncMu.TryLock() {
+		return ErrSyncInProgress
+	}
+	go func() {
+		defer syncMu.Unlock()
+		runSync(lxd, defaults, concurrency)
+	}()
+	return nil
+}
+
+// runSync does the work of RunStartupSync; the caller holds syncMu
+func runSync(lxd *lxc.InstanceService, defaults ImportDefaults, concurrency int) *SyncReport {
 	log.Println("[Sync] Starting LXD to DB synchronization...")
+	report := &SyncReport{StartedAt: time.Now().UTC()}
+	defer func() {
+		report.FinishedAt = time.Now().UTC()
+		lastSync.Store(report)
+	}()
 
 	lxdInstances, err := lxd.ListInstances()
 	if err != nil {
 		log.Printf("[Sync] ERROR: Failed to list instances from LXD: %v", err)
-		return
+		report.Errors = append(report.Errors, fmt.Errorf("list instances: %w", err))
+		return report
 	}
 
-	for _, lxdInstance := range lxdInstances {
-		dbInstance, err := db.GetInstance(lxdInstance.Name)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				// Instance does not exist in DB, let's import it.
-				log.Printf("[Sync] Importing new instance '%s' from LXD to database...", lxdInstance.Name)
-
-				newInstance := &types.Instance{
-					Name:            lxdInstance.Name,
-					Image:           lxdInstance.Config["volatile.base_image"],
-					Limits:          lxdInstance.Config,
-					Type:            lxdInstance.Type,
-					BackupSchedule:  "@daily", // Default value
-					BackupRetention: 7,        // Default value
-					BackupEnabled:   false,    // Default value
-				}
-
-				if err := db.CreateInstance(newInstance); err != nil {
-					log.Printf("[Sync] ERROR: Failed to import instance '%s': %v", lxdInstance.Name, err)
-				} else {
-					log.Printf("[Sync] Imported instance '%s' successfully.", lxdInstance.Name)
-				}
-			} else {
-				log.Printf("[Sync] ERROR: Failed to query instance '%s' from DB: %v", lxdInstance.Name, err)
-			}
-		} else {
-			// Instance exists in DB, update its status and IP addresses
-			log.Printf("[Sync] Updating existing instance '%s' with LXD status...", lxdInstance.Name)
-
-			// Update the instance in the database with current status and IP addresses from LXD
-			instanceState, _, stateErr := lxd.GetInstanceState(lxdInstance.Name)
-			if stateErr != nil {
-				log.Printf("[Sync] Warning: Could not get state for instance '%s': %v", lxdInstance.Name, stateErr)
-				// Still update the status from the list if we can't get the state
-				dbInstance.Limits["status"] = strings.ToUpper(lxdInstance.Status)
-			} else {
-				// Extract IP addresses from the state
-				var ipv4, ipv6 string
-
-				if eth0, ok := instanceState.Network["eth0"]; ok {
-					// Find IPv4 and IPv6 addresses
-					for _, addr := range eth0.Addresses {
-						if addr.Family == "inet" {
-							ipv4 = addr.Address
-						} else if addr.Family == "inet6" && ipv6 == "" {
-							ipv6 = addr.Address
-						}
-					}
-				}
-
-				// Update the limits map with the IP addresses
-				if ipv4 != "" {
-					dbInstance.Limits["volatile.ipv4"] = ipv4
-				} else {
-					delete(dbInstance.Limits, "volatile.ipv4") // Remove if not available
-				}
-				if ipv6 != "" {
-					dbInstance.Limits["volatile.ipv6"] = ipv6
-				} else {
-					delete(dbInstance.Limits, "volatile.ipv6") // Remove if not available
-				}
-
-				// Update status with normalized uppercase value
-				dbInstance.Limits["status"] = strings.ToUpper(instanceState.Status)
-			}
+	var mu sync.Mutex
+	forEachConcurrent(lxdInstances, concurrency, func(lxdInstance lxc.InstanceMetric) {
+		imported, err := syncOne(lxd, lxdInstance, defaults)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, lxdInstance.Name)
+			report.Errors = append(report.Errors, err)
+		case imported:
+			report.Imported = append(report.Imported, lxdInstance.Name)
+		default:
+			report.Updated = append(report.Updated, lxdInstance.Name)
+		}
+	})
+	sort.Strings(report.Imported)
+	sort.Strings(report.Updated)
+	sort.Strings(report.Failed)
+
+	log.Printf("[Sync] Synchronization finished: %d imported, %d updated, %d failed.",
+		len(report.Imported), len(report.Updated), len(report.Failed))
+	return report
+}
 
-			// Update the instance in the database
-			if err := db.UpdateInstanceStatusAndLimits(dbInstance.Name, dbInstance.Limits); err != nil {
-				log.Printf("[Sync] ERROR: Failed to update instance '%s': %v", lxdInstance.Name, err)
-			} else {
-				log.Printf("[Sync] Updated instance '%s' with current status and IPs.", lxdInstance.Name)
+// syncOne imports an instance missing from the DB or records its observed
+// state, reporting whether it was imported. It only touches its own instance,
+// so calls may run concurrently.
+func syncOne(lxd *lxc.InstanceService, lxdInstance lxc.InstanceMetric, defaults ImportDefaults) (bool, error) {
+	dbInstance, err := db.GetInstance(lxdInstance.Name)
+	if err != nil {
+		if !errors.Is(err, db.ErrInstanceNotFound) {
+			log.Printf("[Sync] ERROR: Failed to query instance '%s' from DB: %v", lxdInstance.Name, err)
+			return false, fmt.Errorf("query %s: %w", lxdInstance.Name, err)
+		}
+
+		// Instance does not exist in DB, let's import it.
+		log.Printf("[Sync] Importing new instance '%s' from LXD to database...", lxdInstance.Name)
+
+		newInstance := &types.Instance{
+			Name:  lxdInstance.Name,
+			Image: lxdInstance.Config["volatile.base_image"],
+			// A copy: the LXD config map is not shared with the stored instance
+			Limits:          db.DurableLimits(lxdInstance.Config),
+			Type:            lxdInstance.Type,
+			BackupSchedule:  defaults.BackupSchedule,
+			BackupRetention: defaults.BackupRetention,
+			BackupEnabled:   defaults.BackupEnabled,
+		}
+
+		if err := db.CreateInstance(newInstance); err != nil {
+			log.Printf("[Sync] ERROR: Failed to import instance '%s': %v", lxdInstance.Name, err)
+			return false, fmt.Errorf("import %s: %w", lxdInstance.Name, err)
+		}
+		log.Printf("[Sync] Imported instance '%s' successfully.", lxdInstance.Name)
+		return true, nil
+	}
+
+	// Instance exists in DB: record its observed status and IPs in the
+	// volatile store. The limits column holds configuration only.
+	instanceState, _, stateErr := lxd.GetInstanceState(lxdInstance.Name)
+	if stateErr != nil {
+		log.Printf("[Sync] Warning: Could not get state for instance '%s': %v", lxdInstance.Name, stateErr)
+		// Still update the status from the list if we can't get the state
+		status := types.ClassifyStatus(strings.ToUpper(lxdInstance.Status), dbInstance.DesiredState)
+		if err := db.UpdateInstanceVolatileStatus(dbInstance.Name, status); err != nil {
+			log.Printf("[Sync] ERROR: Failed to update instance '%s': %v", lxdInstance.Name, err)
+			return false, fmt.Errorf("update %s: %w", lxdInstance.Name, err)
+		}
+		return false, nil
+	}
+
+	observed := observedState(instanceState, dbInstance.DesiredState)
+	if err := db.UpdateInstanceVolatile(dbInstance.Name, observed); err != nil {
+		log.Printf("[Sync] ERROR: Failed to update instance '%s': %v", lxdInstance.Name, err)
+		return false, fmt.Errorf("update %s: %w", lxdInstance.Name, err)
+	}
+	return false, nil
+}
+
+// forEachConcurrent calls fn for every item with at most concurrency calls in
+// flight and returns when all are done. concurrency < 1 means 1.
+func forEachConcurrent[T any](items []T, concurrency int, fn func(T)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// SyncInstance is the single-instance version of RunStartupSync for an
+// instance already in the database: it re-reads the state from LXD and records
+// the observed status and IPs in the volatile store. Returns ErrNotInLXD when
+// LXD does not know the instance.
+func SyncInstance(lxd *lxc.InstanceService, dbInstance *types.Instance) error {
+	instanceState, _, err := lxd.GetInstanceState(dbInstance.Name)
+	if err != nil {
+		if api.StatusErrorCheck(err, http.StatusNotFound) {
+			return fmt.Errorf("%w: %s", ErrNotInLXD, dbInstance.Name)
+		}
+		return fmt.Errorf("get state of %s: %w", dbInstance.Name, err)
+	}
+
+	return db.UpdateInstanceVolatile(dbInstance.Name, observedState(instanceState, dbInstance.DesiredState))
+}
+
+// observedState extracts the status and the first eth0 IPv4/IPv6 addresses
+// from an LXD state. A stop nobody asked for is a crash.
+func observedState(state *api.InstanceState, desiredState string) db.VolatileState {
+	observed := db.VolatileState{
+		Status: types.ClassifyStatus(strings.ToUpper(state.Status), desiredState),
+	}
+	if eth0, ok := state.Network["eth0"]; ok {
+		for _, addr := range eth0.Addresses {
+			if addr.Family == "inet" && observed.IPv4 == "" {
+				observed.IPv4 = addr.Address
+			} else if addr.Family == "inet6" && observed.IPv6 == "" {
+				observed.IPv6 = addr.Address
 			}
 		}
 	}
-	log.Println("[Sync] Synchronization finished.")
+	return observed
 }
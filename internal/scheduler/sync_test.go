@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForEachConcurrentBoundsInFlight(t *testing.T) {
+	const total, concurrency = 5000, 8
+
+	items := make([]int, total)
+	for i := range items {
+		items[i] = i
+	}
+
+	var (
+		mu       sync.Mutex
+		seen     = make(map[int]int, total)
+		inFlight int32
+		maxSeen  int32
+	)
+	forEachConcurrent(items, concurrency, func(i int) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxSeen)
+			if n <= m || atomic.CompareAndSwapInt32(&maxSeen, m, n) {
+				break
+			}
+		}
+		if i%100 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		mu.Lock()
+		seen[i]++
+		mu.Unlock()
+		atomic.AddInt32(&inFlight, -1)
+	})
+
+	if len(seen) != total {
+		t.Fatalf("processed %d distinct items, want %d", len(seen), total)
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Fatalf("item %d processed %d times", i, n)
+		}
+	}
+	if maxSeen > concurrency {
+		t.Errorf("max in flight = %d, want <= %d", maxSeen, concurrency)
+	}
+}
+
+func TestForEachConcurrentClampsConcurrency(t *testing.T) {
+	var count int32
+	forEachConcurrent([]string{"a", "b", "c"}, 0, func(string) {
+		atomic.AddInt32(&count, 1)
+	})
+	if count != 3 {
+		t.Errorf("processed %d items, want 3", count)
+	}
+}
+
+func TestSyncReportJSON(t *testing.T) {
+	report := &SyncReport{
+		Imported: []string{"web"},
+		Failed:   []string{"db"},
+		Errors:   []error{errors.New("update db: connection refused")},
+	}
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"imported":["web"]`, `"updated":[]`, `"failed":["db"]`, `"errors":["update db: connection refused"]`} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %s in %s", want, out)
+		}
+	}
+}
+
+func TestSyncRejectsConcurrentRun(t *testing.T) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+
+	if err := StartSync(nil, ImportDefaults{}, 1); !errors.Is(err, ErrSyncInProgress) {
+		t.Errorf("StartSync during a sync: got %v", err)
+	}
+	if _, err := RunStartupSync(nil, nil, ImportDefaults{}, 1); !errors.Is(err, ErrSyncInProgress) {
+		t.Errorf("RunStartupSync during a sync: got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/types"
+	"aexon/internal/worker"
+
+	"github.com/google/uuid"
+)
+
+// TaskPollInterval is how often due scheduled tasks are looked up
+const TaskPollInterval = 30 * time.Second
+
+// RunTaskScheduler fires an exec job for every scheduled task that is due,
+// until ctx is canceled. A run missed while the control plane was down fires
+// once at startup; it is not replayed once per missed slot.
+func RunTaskScheduler(ctx context.Context, interval time.Duration) {
+	log.Println("[Tasks] Scheduled task runner started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fireDueTasks(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func fireDueTasks(ctx context.Context, now time.Time) {
+	repo := db.NewScheduledTaskRepository(db.GetService())
+
+	tasks, err := repo.ListDue(ctx, now)
+	if err != nil {
+		log.Printf("[Tasks] ERROR: Failed to list due tasks: %v", err)
+		return
+	}
+
+	for i := range tasks {
+		task := &tasks[i]
+
+		next, err := db.GetNextRunTime(task.Cron)
+		if err != nil {
+			log.Printf("[Tasks] Task %d has an invalid schedule %q: %v", task.ID, task.Cron, err)
+			continue
+		}
+
+		claimed, err := repo.Claim(ctx, task, next)
+		if err != nil {
+			log.Printf("[Tasks] ERROR: Failed to claim task %d: %v", task.ID, err)
+			continue
+		}
+		if !claimed {
+			continue // another control plane fired it
+		}
+
+		jobID, err := dispatchTaskJob(task)
+		if err != nil {
+			log.Printf("[Tasks] ERROR: Failed to create job for task %d: %v", task.ID, err)
+			continue
+		}
+		if err := repo.SetLastJob(ctx, task.ID, jobID); err != nil {
+			log.Printf("[Tasks] Failed to record job %s for task %d: %v", jobID, task.ID, err)
+		}
+		log.Printf("[Tasks] Task %d fired on %s (job %s)", task.ID, task.Instance, jobID)
+	}
+}
+
+func dispatchTaskJob(task *db.ScheduledTask) (string, error) {
+	timeout := task.Timeout
+	if timeout == "" {
+		timeout = worker.ExecDefaultTimeout.String()
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"command": task.Command,
+		"timeout": timeout,
+		"task_id": task.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	requestedBy := "scheduler"
+	if task.CreatedBy != "" {
+		requestedBy = "scheduler:" + task.CreatedBy
+	}
+
+	job := &db.Job{
+		ID:          uuid.New().String(),
+		Type:        types.JobTypeExec,
+		Target:      task.Instance,
+		Payload:     string(payload),
+		RequestedBy: &requestedBy,
+	}
+	if err := db.CreateJob(job); err != nil {
+		return "", err
+	}
+
+	worker.DispatchJob(job.ID)
+	return job.ID, nil
+}
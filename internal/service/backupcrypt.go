@@ -0,0 +1,353 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ============================================================================
+// BACKUP ENCRYPTION
+// ============================================================================
+
+// Encrypted backups are a header followed by the payload split in 64 KiB
+// chunks, each sealed with ChaCha20-Poly1305 under a per-artifact key (the
+// STREAM construction used by age). The nonce carries the chunk counter and a
+// final-chunk flag, so reordered, dropped or truncated chunks fail to open.
+//
+//	magic(9) | mode(1) | key id(8) | [ephemeral X25519 public key(32)] | salt(16)
+//
+// In x25519 mode the artifact key is derived from an ephemeral key agreed with
+// the instance's recipient public key, so only the private key holder can
+// restore. In symmetric mode it is derived from AXION_BACKUP_ENCRYPTION_KEY.
+
+const (
+	BackupEncryptionNone      = "none"
+	BackupEncryptionX25519    = "x25519"
+	BackupEncryptionSymmetric = "symmetric"
+
+	// BackupKeySize is the size of X25519 keys and of the symmetric key
+	BackupKeySize = 32
+
+	backupChunkSize = 64 * 1024
+	backupSaltSize  = 16
+	backupKeyIDSize = 8
+	backupKDFInfo   = "axion-backup-v1"
+)
+
+var backupMagic = []byte("AXIONENC\x01")
+
+var backupModeBytes = map[string]byte{
+	BackupEncryptionX25519:    1,
+	BackupEncryptionSymmetric: 2,
+}
+
+var (
+	// ErrBackupKeyRequired is returned when an encrypted backup is opened without the key it needs
+	ErrBackupKeyRequired = errors.New("backup is encrypted and no key was provided")
+	// ErrBackupKeyMismatch is returned when the key given is not the one the backup was encrypted for
+	ErrBackupKeyMismatch = errors.New("backup was encrypted for a different key")
+	// ErrBackupCorrupted is returned when a chunk fails authentication or the stream ends early
+	ErrBackupCorrupted = errors.New("encrypted backup is corrupted or truncated")
+)
+
+// BackupEncryption is the per-instance encryption setting. Recipient is the
+// base64 X25519 public key for x25519 mode and empty otherwise. The key pair is
+// generated by the client: the server only ever sees the public half.
+type BackupEncryption struct {
+	Mode      string `json:"mode"`
+	Recipient string `json:"recipient,omitempty"`
+}
+
+// Enabled reports whether backups are encrypted
+func (e BackupEncryption) Enabled() bool {
+	return e.Mode != "" && e.Mode != BackupEncryptionNone
+}
+
+// ValidateBackupEncryption checks the mode and, for x25519, the recipient key.
+// hasSymmetricKey tells whether the server has a symmetric key configured.
+func ValidateBackupEncryption(e BackupEncryption, hasSymmetricKey bool) error {
+	switch e.Mode {
+	case "", BackupEncryptionNone:
+		return nil
+	case BackupEncryptionX25519:
+		if _, err := DecodeBackupKey(e.Recipient); err != nil {
+			return fmt.Errorf("recipient: %w", err)
+		}
+		return nil
+	case BackupEncryptionSymmetric:
+		if e.Recipient != "" {
+			return errors.New("recipient is only used with x25519")
+		}
+		if !hasSymmetricKey {
+			return errors.New("symmetric mode requires AXION_BACKUP_ENCRYPTION_KEY on the server")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q (valid: %s, %s, %s)", e.Mode, BackupEncryptionNone, BackupEncryptionX25519, BackupEncryptionSymmetric)
+	}
+}
+
+// DecodeBackupKey decodes a base64 32-byte key
+func DecodeBackupKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(key) != BackupKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", BackupKeySize, len(key))
+	}
+	return key, nil
+}
+
+func backupKeyID(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:backupKeyIDSize]
+}
+
+func deriveBackupKey(secret, salt []byte, mode byte) (cipher.AEAD, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	kdf := hkdf.New(sha256.New, secret, salt, append([]byte(backupKDFInfo), mode))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+// NewEncryptingWriter returns a writer that encrypts everything written to it
+// into w. Nothing reaches w before the first chunk is sealed, so a caller can
+// still report an error if its source fails early. Close must be called to
+// seal the final chunk; it does not close w.
+func NewEncryptingWriter(w io.Writer, e BackupEncryption, symmetricKey []byte) (io.WriteCloser, error) {
+	mode, ok := backupModeBytes[e.Mode]
+	if !ok {
+		return nil, fmt.Errorf("backup encryption mode %q cannot encrypt", e.Mode)
+	}
+
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, backupMagic...), mode)
+	var secret []byte
+	switch e.Mode {
+	case BackupEncryptionX25519:
+		recipient, err := DecodeBackupKey(e.Recipient)
+		if err != nil {
+			return nil, fmt.Errorf("recipient: %w", err)
+		}
+		ephemeral := make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(ephemeral); err != nil {
+			return nil, err
+		}
+		ephemeralPub, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+		if secret, err = curve25519.X25519(ephemeral, recipient); err != nil {
+			return nil, err
+		}
+		header = append(append(header, backupKeyID(recipient)...), ephemeralPub...)
+	case BackupEncryptionSymmetric:
+		if len(symmetricKey) != BackupKeySize {
+			return nil, ErrBackupKeyRequired
+		}
+		secret = symmetricKey
+		header = append(header, backupKeyID(symmetricKey)...)
+	}
+	header = append(header, salt...)
+
+	aead, err := deriveBackupKey(secret, salt, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte // pending until the first chunk is written
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed backup encrypter")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, so the last
+		// chunk is always the one sealed by Close with the final flag
+		if len(e.buf) == backupChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):backupChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptingWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *encryptingWriter) seal(last bool) error {
+	out := e.aead.Seal(e.header, backupNonce(e.counter, last), e.buf, nil)
+	e.header = nil
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(out)
+	return err
+}
+
+func backupNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// IsEncryptedBackup peeks at r and reports whether it starts with the
+// encrypted backup header
+func IsEncryptedBackup(r *bufio.Reader) bool {
+	head, _ := r.Peek(len(backupMagic))
+	return bytes.Equal(head, backupMagic)
+}
+
+// NewDecryptingReader reads the header from r and returns a reader of the
+// plaintext. privateKey is the base64 X25519 private key for x25519 backups;
+// symmetricKey is the server key for symmetric ones. Without the needed key it
+// returns ErrBackupKeyRequired, with the wrong one ErrBackupKeyMismatch.
+func NewDecryptingReader(r io.Reader, privateKey string, symmetricKey []byte) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, backupChunkSize+chacha20poly1305.Overhead+1)
+
+	head := make([]byte, len(backupMagic)+1+backupKeyIDSize)
+	if _, err := io.ReadFull(br, head); err != nil || !bytes.Equal(head[:len(backupMagic)], backupMagic) {
+		return nil, errors.New("not an encrypted backup")
+	}
+	mode := head[len(backupMagic)]
+	keyID := head[len(backupMagic)+1:]
+
+	var secret []byte
+	switch mode {
+	case backupModeBytes[BackupEncryptionX25519]:
+		ephemeralPub := make([]byte, curve25519.PointSize)
+		if _, err := io.ReadFull(br, ephemeralPub); err != nil {
+			return nil, ErrBackupCorrupted
+		}
+		if privateKey == "" {
+			return nil, ErrBackupKeyRequired
+		}
+		priv, err := DecodeBackupKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("private key: %w", err)
+		}
+		pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(backupKeyID(pub), keyID) {
+			return nil, ErrBackupKeyMismatch
+		}
+		if secret, err = curve25519.X25519(priv, ephemeralPub); err != nil {
+			return nil, err
+		}
+	case backupModeBytes[BackupEncryptionSymmetric]:
+		if len(symmetricKey) != BackupKeySize {
+			return nil, ErrBackupKeyRequired
+		}
+		if !bytes.Equal(backupKeyID(symmetricKey), keyID) {
+			return nil, ErrBackupKeyMismatch
+		}
+		secret = symmetricKey
+	default:
+		return nil, fmt.Errorf("unknown backup encryption mode %d", mode)
+	}
+
+	salt := make([]byte, backupSaltSize)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return nil, ErrBackupCorrupted
+	}
+	aead, err := deriveBackupKey(secret, salt, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: br, aead: aead, chunk: make([]byte, backupChunkSize+aead.Overhead())}, nil
+}
+
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) next() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, peekErr := d.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+	if n < d.aead.Overhead() {
+		return ErrBackupCorrupted
+	}
+
+	plain, err := d.aead.Open(d.chunk[:0], backupNonce(d.counter, last), d.chunk[:n], nil)
+	if err != nil {
+		return ErrBackupCorrupted
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
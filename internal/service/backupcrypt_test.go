@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// generateBackupKeyPair stands in for the client, which owns the private key
+func generateBackupKeyPair() (publicKey, privateKey string, err error) {
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(priv); err != nil {
+		return "", "", err
+	}
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+func encryptBackup(t *testing.T, e BackupEncryption, symmetricKey, payload []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := NewEncryptingWriter(&out, e, symmetricKey)
+	if err != nil {
+		t.Fatalf("NewEncryptingWriter: %v", err)
+	}
+	// Uneven writes so chunk boundaries do not line up with Write calls
+	for len(payload) > 0 {
+		n := 7000
+		if n > len(payload) {
+			n = len(payload)
+		}
+		if _, err := w.Write(payload[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		payload = payload[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestBackupEncryptionRoundTrip(t *testing.T) {
+	pub, priv, err := generateBackupKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	symmetric := make([]byte, BackupKeySize)
+	rand.Read(symmetric)
+
+	for _, size := range []int{0, 1, backupChunkSize, 3*backupChunkSize + 17} {
+		payload := make([]byte, size)
+		rand.Read(payload)
+
+		cases := map[string]struct {
+			enc  BackupEncryption
+			priv string
+		}{
+			"x25519":    {enc: BackupEncryption{Mode: BackupEncryptionX25519, Recipient: pub}, priv: priv},
+			"symmetric": {enc: BackupEncryption{Mode: BackupEncryptionSymmetric}},
+		}
+		for name, tc := range cases {
+			sealed := encryptBackup(t, tc.enc, symmetric, payload)
+			if !IsEncryptedBackup(bufio.NewReader(bytes.NewReader(sealed))) {
+				t.Fatalf("%s/%d: header not recognized", name, size)
+			}
+
+			r, err := NewDecryptingReader(bytes.NewReader(sealed), tc.priv, symmetric)
+			if err != nil {
+				t.Fatalf("%s/%d: NewDecryptingReader: %v", name, size, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("%s/%d: ReadAll: %v", name, size, err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("%s/%d: plaintext mismatch (%d bytes, want %d)", name, size, len(got), len(payload))
+			}
+		}
+	}
+}
+
+func TestBackupDecryptionRequiresTheRightKey(t *testing.T) {
+	pub, _, _ := generateBackupKeyPair()
+	_, otherPriv, _ := generateBackupKeyPair()
+	sealed := encryptBackup(t, BackupEncryption{Mode: BackupEncryptionX25519, Recipient: pub}, nil, []byte("secret data"))
+
+	if _, err := NewDecryptingReader(bytes.NewReader(sealed), "", nil); !errors.Is(err, ErrBackupKeyRequired) {
+		t.Errorf("no key: got %v, want ErrBackupKeyRequired", err)
+	}
+	if _, err := NewDecryptingReader(bytes.NewReader(sealed), otherPriv, nil); !errors.Is(err, ErrBackupKeyMismatch) {
+		t.Errorf("wrong key: got %v, want ErrBackupKeyMismatch", err)
+	}
+}
+
+func TestBackupDecryptionDetectsTruncation(t *testing.T) {
+	symmetric := make([]byte, BackupKeySize)
+	payload := make([]byte, 2*backupChunkSize+100)
+	sealed := encryptBackup(t, BackupEncryption{Mode: BackupEncryptionSymmetric}, symmetric, payload)
+
+	// Cut exactly after the first full chunk: without the final flag it must not pass as complete
+	headerSize := len(backupMagic) + 1 + backupKeyIDSize + backupSaltSize
+	cut := sealed[:headerSize+backupChunkSize+16]
+
+	r, err := NewDecryptingReader(bytes.NewReader(cut), "", symmetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrBackupCorrupted) {
+		t.Errorf("truncated stream: got %v, want ErrBackupCorrupted", err)
+	}
+}
+
+func TestValidateBackupEncryption(t *testing.T) {
+	pub, _, _ := generateBackupKeyPair()
+
+	valid := []BackupEncryption{
+		{},
+		{Mode: BackupEncryptionNone},
+		{Mode: BackupEncryptionX25519, Recipient: pub},
+	}
+	for _, e := range valid {
+		if err := ValidateBackupEncryption(e, false); err != nil {
+			t.Errorf("%+v: unexpected error: %v", e, err)
+		}
+	}
+
+	invalid := []BackupEncryption{
+		{Mode: "gpg"},
+		{Mode: BackupEncryptionX25519, Recipient: "not-a-key"},
+		{Mode: BackupEncryptionSymmetric},
+	}
+	for _, e := range invalid {
+		if err := ValidateBackupEncryption(e, false); err == nil {
+			t.Errorf("%+v: expected an error", e)
+		}
+	}
+}
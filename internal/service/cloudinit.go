@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// ValidateCloudConfig checks that userData is a cloud-config document: it must
+// start with the #cloud-config header and parse as a YAML mapping.
+// Other cloud-init formats (scripts, MIME multipart) are not accepted.
+func ValidateCloudConfig(userData string) error {
+	trimmed := strings.TrimLeft(userData, " \t\r\n")
+	if trimmed == "" {
+		return errors.New("user_data is empty")
+	}
+
+	firstLine := strings.SplitN(trimmed, "\n", 2)[0]
+	if strings.TrimSpace(firstLine) != cloudConfigHeader {
+		return fmt.Errorf("user_data must start with %q", cloudConfigHeader)
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(trimmed), &doc); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	// A header-only document is valid (no-op); anything else must be a mapping
+	if doc == nil {
+		return nil
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return errors.New("cloud-config must be a YAML mapping at the top level")
+	}
+
+	return nil
+}
+
+// MergeCloudConfig deep-merges a user-supplied fragment into a base
+// cloud-config (typically a template):
+//   - mappings are merged recursively;
+//   - lists are concatenated, base first (runcmd, bootcmd, write_files...),
+//     with duplicate entries removed from "packages";
+//   - "users" entries with the same name are merged, new users are appended;
+//   - any other scalar in the fragment overrides the base.
+//
+// The fragment may omit the #cloud-config header. The result is validated
+// before being returned.
+func MergeCloudConfig(base, fragment string) (string, error) {
+	baseDoc, err := parseCloudConfigMap(base)
+	if err != nil {
+		return "", fmt.Errorf("base cloud-config: %w", err)
+	}
+	fragDoc, err := parseCloudConfigMap(fragment)
+	if err != nil {
+		return "", fmt.Errorf("user_data: %w", err)
+	}
+
+	merged := mergeMaps(baseDoc, fragDoc)
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", fmt.Errorf("marshal merged cloud-config: %w", err)
+	}
+
+	result := cloudConfigHeader + "\n" + string(out)
+	if err := ValidateCloudConfig(result); err != nil {
+		return "", fmt.Errorf("merged cloud-config is invalid: %w", err)
+	}
+	return result, nil
+}
+
+func parseCloudConfigMap(doc string) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if strings.TrimSpace(doc) == "" {
+		return out, nil
+	}
+	if err := yaml.Unmarshal([]byte(doc), &out); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return out, nil
+}
+
+func mergeMaps(base, overlay map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		out[k] = v
+	}
+
+	for k, v := range overlay {
+		existing, ok := out[k]
+		if !ok {
+			out[k] = v
+			continue
+		}
+
+		switch ov := v.(type) {
+		case map[string]interface{}:
+			if bv, ok := existing.(map[string]interface{}); ok {
+				out[k] = mergeMaps(bv, ov)
+				continue
+			}
+		case []interface{}:
+			if bv, ok := existing.([]interface{}); ok {
+				switch k {
+				case "users":
+					out[k] = mergeUsers(bv, ov)
+				case "packages":
+					out[k] = appendUnique(bv, ov)
+				default:
+					out[k] = append(append([]interface{}{}, bv...), ov...)
+				}
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// mergeUsers merges user entries by name; plain strings such as "default" are deduplicated
+func mergeUsers(base, overlay []interface{}) []interface{} {
+	out := append([]interface{}{}, base...)
+
+	for _, u := range overlay {
+		user, ok := u.(map[string]interface{})
+		name, _ := user["name"].(string)
+		if !ok || name == "" {
+			out = appendUnique(out, []interface{}{u})
+			continue
+		}
+
+		merged := false
+		for i, existing := range out {
+			if em, ok := existing.(map[string]interface{}); ok && em["name"] == name {
+				out[i] = mergeMaps(em, user)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			out = append(out, user)
+		}
+	}
+	return out
+}
+
+func appendUnique(base, overlay []interface{}) []interface{} {
+	out := append([]interface{}{}, base...)
+	seen := make(map[string]bool, len(out))
+	for _, v := range out {
+		seen[fmt.Sprint(v)] = true
+	}
+	for _, v := range overlay {
+		if key := fmt.Sprint(v); !seen[key] {
+			seen[key] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// EnvConfigPrefix keeps instance environment variables among the limits
+// under the keys LXD uses for them (environment.NAME)
+const EnvConfigPrefix = "environment."
+
+// EnvironmentFile is written by cloud-init with the instance environment;
+// root login shells source it
+const EnvironmentFile = "/etc/profile.d/axion-env.sh"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvName accepts shell variable names
+func ValidateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name %q", name)
+	}
+	return nil
+}
+
+// InstanceEnv returns the environment variables stored in limits
+func InstanceEnv(limits map[string]string) map[string]string {
+	env := map[string]string{}
+	for key, value := range limits {
+		if name, ok := strings.CutPrefix(key, EnvConfigPrefix); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// WithEnvironment merges into userData a write_files entry that exports env
+// from EnvironmentFile. Values are single-quoted for the shell, so a resolved
+// secret cannot break out of its assignment.
+func WithEnvironment(userData string, env map[string]string) (string, error) {
+	if len(env) == 0 {
+		return userData, nil
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var script strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&script, "export %s='%s'\n", name, strings.ReplaceAll(env[name], "'", `'\''`))
+	}
+	fragment, err := yaml.Marshal(map[string]interface{}{
+		"write_files": []interface{}{map[string]interface{}{
+			"path":        EnvironmentFile,
+			"permissions": "0600",
+			"content":     script.String(),
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return MergeCloudConfig(userData, string(fragment))
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateCloudConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		userData string
+		wantErr  bool
+	}{
+		{name: "mapping", userData: "#cloud-config\npackages:\n  - nginx\n"},
+		{name: "header only", userData: "#cloud-config\n"},
+		{name: "leading blank lines", userData: "\n\n  #cloud-config\nhostname: web\n"},
+		{name: "header with trailing spaces", userData: "#cloud-config  \r\nhostname: web\n"},
+		{name: "empty", userData: "", wantErr: true},
+		{name: "whitespace only", userData: " \n\t", wantErr: true},
+		{name: "missing header", userData: "packages:\n  - nginx\n", wantErr: true},
+		{name: "shell script", userData: "#!/bin/sh\necho hi\n", wantErr: true},
+		{name: "invalid YAML", userData: "#cloud-config\npackages: [nginx\n", wantErr: true},
+		{name: "top-level list", userData: "#cloud-config\n- nginx\n", wantErr: true},
+		{name: "top-level scalar", userData: "#cloud-config\nhello\n", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		err := ValidateCloudConfig(tc.userData)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestMergeCloudConfigWithTemplate(t *testing.T) {
+	base := `#cloud-config
+packages:
+  - curl
+runcmd:
+  - echo base
+users:
+  - name: axion
+    shell: /bin/bash
+`
+	fragment := `packages:
+  - curl
+  - htop
+runcmd:
+  - echo mine
+users:
+  - name: axion
+    groups: [docker]
+  - name: deploy
+timezone: UTC
+`
+
+	merged, err := MergeCloudConfig(base, fragment)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if err := ValidateCloudConfig(merged); err != nil {
+		t.Fatalf("merged document is not valid cloud-config: %v\n%s", err, merged)
+	}
+
+	var doc struct {
+		Packages []string                 `yaml:"packages"`
+		Runcmd   []string                 `yaml:"runcmd"`
+		Users    []map[string]interface{} `yaml:"users"`
+		Timezone string                   `yaml:"timezone"`
+	}
+	if err := yaml.Unmarshal([]byte(merged), &doc); err != nil {
+		t.Fatalf("unmarshal merged: %v", err)
+	}
+
+	if len(doc.Packages) != 2 {
+		t.Errorf("packages should be deduplicated, got %v", doc.Packages)
+	}
+	if len(doc.Runcmd) != 2 || doc.Runcmd[0] != "echo base" {
+		t.Errorf("runcmd should be concatenated base-first, got %v", doc.Runcmd)
+	}
+	if len(doc.Users) != 2 {
+		t.Fatalf("expected axion merged and deploy appended, got %v", doc.Users)
+	}
+	if doc.Users[0]["shell"] != "/bin/bash" || doc.Users[0]["groups"] == nil {
+		t.Errorf("axion user should keep shell and gain groups, got %v", doc.Users[0])
+	}
+	if doc.Timezone != "UTC" {
+		t.Errorf("scalar from fragment should be kept, got %q", doc.Timezone)
+	}
+}
+
+func TestMergeCloudConfigRejectsInvalidFragment(t *testing.T) {
+	if _, err := MergeCloudConfig("#cloud-config\n", "runcmd: [unclosed"); err == nil {
+		t.Error("expected error for invalid YAML fragment")
+	}
+}
+
+func TestWithEnvironment(t *testing.T) {
+	out, err := WithEnvironment("#cloud-config\nwrite_files:\n  - path: /etc/app.conf\n    content: x\n", map[string]string{
+		"DB_PASS": "it's secret",
+		"APP_ENV": "prod",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		WriteFiles []struct {
+			Path    string `yaml:"path"`
+			Content string `yaml:"content"`
+		} `yaml:"write_files"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.WriteFiles) != 2 || doc.WriteFiles[1].Path != EnvironmentFile {
+		t.Fatalf("unexpected write_files: %+v", doc.WriteFiles)
+	}
+	want := "export APP_ENV='prod'\nexport DB_PASS='it'\\''s secret'\n"
+	if doc.WriteFiles[1].Content != want {
+		t.Errorf("content = %q, want %q", doc.WriteFiles[1].Content, want)
+	}
+
+	if out, err := WithEnvironment("", nil); err != nil || out != "" {
+		t.Errorf("no env should leave user_data alone, got %q %v", out, err)
+	}
+	if ValidateEnvName("1BAD") == nil || ValidateEnvName("A-B") == nil || ValidateEnvName("GOOD_1") != nil {
+		t.Error("ValidateEnvName misclassified a name")
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"aexon/internal/types"
+)
+
+var (
+	deviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	hexIDPattern      = regexp.MustCompile(`^[0-9a-fA-F]{4}$`)
+	pciAddressPattern = regexp.MustCompile(`^([0-9a-fA-F]{4}:)?[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]$`)
+	proxyAddrPattern  = regexp.MustCompile(`^(tcp|udp):[^:]*:?.*:[0-9]+(-[0-9]+)?$`)
+)
+
+// reservedDeviceNames are managed by Axion itself and cannot be replaced through passthrough
+var reservedDeviceNames = map[string]bool{"root": true, "eth0": true}
+
+// deviceKeys lists the LXD config keys accepted for each device type
+var deviceKeys = map[string]map[string]bool{
+	types.DeviceTypeGPU: {
+		"gputype": true, "id": true, "pci": true, "vendorid": true, "productid": true,
+		"uid": true, "gid": true, "mode": true,
+	},
+	types.DeviceTypeUSB: {
+		"vendorid": true, "productid": true, "busnum": true, "devnum": true,
+		"uid": true, "gid": true, "mode": true, "required": true,
+	},
+	types.DeviceTypeDisk: {
+		"source": true, "path": true, "readonly": true, "required": true, "shift": true,
+	},
+	types.DeviceTypeProxy: {
+		"listen": true, "connect": true, "bind": true, "nat": true, "proxy_protocol": true,
+		"uid": true, "gid": true, "mode": true,
+	},
+}
+
+// DefaultDeviceTypes are the passthrough types enabled when none are configured.
+// disk and proxy reach into the host and must be enabled explicitly.
+var DefaultDeviceTypes = []string{types.DeviceTypeGPU, types.DeviceTypeUSB}
+
+// DevicePolicy is what passthrough may expose of the host: the device types
+// accepted and the host directories disk devices may be taken from. Anything
+// not listed is rejected.
+type DevicePolicy struct {
+	Types []string
+	// DiskSources are clean absolute directories; a disk source must be one
+	// of them or live below one. Empty disables disk passthrough.
+	DiskSources []string
+}
+
+// ParseDevicePolicy reads comma separated device types (empty means
+// DefaultDeviceTypes) and disk source directories.
+func ParseDevicePolicy(typeList, sourceList string) (DevicePolicy, error) {
+	var policy DevicePolicy
+	for _, t := range splitList(typeList) {
+		if _, ok := deviceKeys[t]; !ok {
+			return DevicePolicy{}, fmt.Errorf("unknown device type %q (supported: gpu, usb, disk, proxy)", t)
+		}
+		policy.Types = append(policy.Types, t)
+	}
+	if len(policy.Types) == 0 {
+		policy.Types = DefaultDeviceTypes
+	}
+	for _, dir := range splitList(sourceList) {
+		if !path.IsAbs(dir) || path.Clean(dir) != dir || dir == "/" {
+			return DevicePolicy{}, fmt.Errorf("disk source %q must be a clean absolute directory other than /", dir)
+		}
+		policy.DiskSources = append(policy.DiskSources, dir)
+	}
+	return policy, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// allowsType reports whether deviceType is enabled
+func (p DevicePolicy) allowsType(deviceType string) bool {
+	for _, t := range p.Types {
+		if t == deviceType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDeviceName checks a user supplied device name
+func ValidateDeviceName(name string) error {
+	if !deviceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid device name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	if reservedDeviceNames[name] || strings.HasPrefix(name, "proxy-") {
+		return fmt.Errorf("device name %q is reserved", name)
+	}
+	return nil
+}
+
+// ValidateDevice checks the config of a passthrough device against the rules
+// of its type and the policy. Unknown keys are rejected so raw LXD options
+// cannot be smuggled in.
+func ValidateDevice(deviceType string, config map[string]string, policy DevicePolicy) error {
+	allowed, ok := deviceKeys[deviceType]
+	if !ok || !policy.allowsType(deviceType) {
+		return fmt.Errorf("device type %q is not enabled (enabled: %s)", deviceType, strings.Join(policy.Types, ", "))
+	}
+	for key := range config {
+		if !allowed[key] {
+			return fmt.Errorf("config key %q is not allowed for %s devices", key, deviceType)
+		}
+	}
+
+	for _, key := range []string{"uid", "gid"} {
+		if v, ok := config[key]; ok {
+			if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+				return fmt.Errorf("%s must be a numeric id", key)
+			}
+		}
+	}
+	if v, ok := config["mode"]; ok {
+		if _, err := strconv.ParseUint(v, 8, 32); err != nil {
+			return fmt.Errorf("mode must be an octal permission (e.g. 0660)")
+		}
+	}
+	for _, key := range []string{"readonly", "required", "shift", "nat"} {
+		if v, ok := config[key]; ok {
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%s must be true or false", key)
+			}
+		}
+	}
+
+	switch deviceType {
+	case types.DeviceTypeGPU:
+		return validateGPU(config)
+	case types.DeviceTypeUSB:
+		return validateUSB(config)
+	case types.DeviceTypeDisk:
+		return validateDisk(config, policy.DiskSources)
+	case types.DeviceTypeProxy:
+		return validateProxy(config)
+	}
+	return nil
+}
+
+func validateGPU(config map[string]string) error {
+	if v, ok := config["gputype"]; ok && v != "physical" {
+		return fmt.Errorf("only physical GPU passthrough is supported")
+	}
+	if err := validateHexIDs(config); err != nil {
+		return err
+	}
+	if v, ok := config["pci"]; ok && !pciAddressPattern.MatchString(v) {
+		return fmt.Errorf("pci must be a PCI address like 0000:01:00.0")
+	}
+	if v, ok := config["id"]; ok {
+		if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return fmt.Errorf("id must be a numeric card id")
+		}
+	}
+	return nil
+}
+
+func validateUSB(config map[string]string) error {
+	if config["vendorid"] == "" {
+		return fmt.Errorf("vendorid is required for usb devices")
+	}
+	if err := validateHexIDs(config); err != nil {
+		return err
+	}
+	for _, key := range []string{"busnum", "devnum"} {
+		if v, ok := config[key]; ok {
+			if _, err := strconv.ParseUint(v, 10, 16); err != nil {
+				return fmt.Errorf("%s must be numeric", key)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHexIDs(config map[string]string) error {
+	for _, key := range []string{"vendorid", "productid"} {
+		if v, ok := config[key]; ok && !hexIDPattern.MatchString(v) {
+			return fmt.Errorf("%s must be a 4-digit hex id (e.g. 10de)", key)
+		}
+	}
+	return nil
+}
+
+func validateDisk(config map[string]string, sources []string) error {
+	source, target := config["source"], config["path"]
+	if source == "" || target == "" {
+		return fmt.Errorf("source and path are required for disk devices")
+	}
+	if !path.IsAbs(source) || path.Clean(source) != source {
+		return fmt.Errorf("source must be a clean absolute host path")
+	}
+	if !path.IsAbs(target) || path.Clean(target) != target || target == "/" {
+		return fmt.Errorf("path must be a clean absolute path other than /")
+	}
+	for _, dir := range sources {
+		if source == dir || strings.HasPrefix(source, dir+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("host path %s is outside the allowed disk sources", source)
+}
+
+func validateProxy(config map[string]string) error {
+	listen, connect := config["listen"], config["connect"]
+	if listen == "" || connect == "" {
+		return fmt.Errorf("listen and connect are required for proxy devices")
+	}
+	if !proxyAddrPattern.MatchString(listen) {
+		return fmt.Errorf("listen must look like tcp:<addr>:<port>")
+	}
+	if !proxyAddrPattern.MatchString(connect) {
+		return fmt.Errorf("connect must look like tcp:<addr>:<port>")
+	}
+	if strings.SplitN(listen, ":", 2)[0] != strings.SplitN(connect, ":", 2)[0] {
+		return fmt.Errorf("listen and connect must use the same protocol")
+	}
+	// bind=instance would let the instance reach services on the host
+	if v, ok := config["bind"]; ok && v != "host" {
+		return fmt.Errorf("bind must be host")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"aexon/internal/types"
+)
+
+func TestValidateDevice(t *testing.T) {
+	policy := DevicePolicy{
+		Types:       []string{types.DeviceTypeGPU, types.DeviceTypeDisk, types.DeviceTypeProxy},
+		DiskSources: []string{"/srv/shared"},
+	}
+
+	cases := []struct {
+		name    string
+		typ     string
+		config  map[string]string
+		wantErr bool
+	}{
+		{name: "gpu", typ: types.DeviceTypeGPU, config: map[string]string{"vendorid": "10de"}},
+		{name: "type not enabled", typ: types.DeviceTypeUSB, config: map[string]string{"vendorid": "10de"}, wantErr: true},
+		{name: "unknown type", typ: "unix-block", config: map[string]string{}, wantErr: true},
+		{name: "raw key", typ: types.DeviceTypeGPU, config: map[string]string{"raw.lxc": "x"}, wantErr: true},
+		{name: "disk under allowed source", typ: types.DeviceTypeDisk, config: map[string]string{"source": "/srv/shared/data", "path": "/data"}},
+		{name: "disk equal to allowed source", typ: types.DeviceTypeDisk, config: map[string]string{"source": "/srv/shared", "path": "/data"}},
+		{name: "disk outside allowed sources", typ: types.DeviceTypeDisk, config: map[string]string{"source": "/var/lib/docker", "path": "/data"}, wantErr: true},
+		{name: "disk sibling prefix", typ: types.DeviceTypeDisk, config: map[string]string{"source": "/srv/shared-other", "path": "/data"}, wantErr: true},
+		{name: "disk traversal", typ: types.DeviceTypeDisk, config: map[string]string{"source": "/srv/shared/../../etc", "path": "/data"}, wantErr: true},
+		{name: "proxy", typ: types.DeviceTypeProxy, config: map[string]string{"listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:80"}},
+		{name: "proxy bound in instance", typ: types.DeviceTypeProxy, config: map[string]string{"listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:22", "bind": "instance"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		err := ValidateDevice(tc.typ, tc.config, policy)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestParseDevicePolicy(t *testing.T) {
+	policy, err := ParseDevicePolicy("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Types) != len(DefaultDeviceTypes) || len(policy.DiskSources) != 0 {
+		t.Errorf("defaults = %+v", policy)
+	}
+	if err := ValidateDevice(types.DeviceTypeDisk, map[string]string{"source": "/srv", "path": "/data"}, policy); err == nil {
+		t.Error("disk passthrough should be off by default")
+	}
+
+	policy, err = ParseDevicePolicy("disk, proxy", "/srv/shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Types) != 2 || policy.DiskSources[0] != "/srv/shared" {
+		t.Errorf("parsed = %+v", policy)
+	}
+
+	for _, bad := range [][2]string{{"nic", ""}, {"", "/"}, {"", "relative"}, {"", "/srv/../etc"}} {
+		if _, err := ParseDevicePolicy(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseDevicePolicy(%q, %q) should fail", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// GIT CONFIG SOURCES
+// ============================================================================
+
+// An instance can take its user_data from a file in a Git repository
+// (config_repo/ref/path on create). The ref is shallow-fetched into a scratch
+// repository once, at create time, and the file is read from the fetched
+// commit, whose SHA is recorded on the instance. Later pushes to the ref do
+// not touch existing instances.
+
+const (
+	// MaxGitConfigSize caps the config file read from the repository
+	MaxGitConfigSize = 256 * 1024
+	// GitFetchTimeout bounds the whole fetch, including the network
+	GitFetchTimeout = 60 * time.Second
+)
+
+// What was wrong with a config source, reported in GitConfigError.Stage
+const (
+	GitStageRepo   = "repo"   // repository unreachable, missing or access denied
+	GitStageRef    = "ref"    // ref not found in the repository
+	GitStagePath   = "path"   // file not found at the ref (or not a file)
+	GitStageConfig = "config" // file found but not a valid cloud-config
+)
+
+// GitConfigErrorCode is the job error code of a fetch_config job that failed
+// on a GitConfigError: the source itself is wrong, so the job is not retried
+const GitConfigErrorCode = "config_source_invalid"
+
+// gitAllowedProtocols is passed as GIT_ALLOW_PROTOCOL so redirects and
+// submodule-style URLs cannot reach other transports; tests add "file".
+var gitAllowedProtocols = "https"
+
+// GitCredential authenticates HTTPS fetches to one host. Password is usually
+// an access token.
+type GitCredential struct {
+	Username string
+	Password string
+}
+
+// ParseGitCredentials reads AXION_GIT_CREDENTIALS: comma-separated
+// host=username:token entries, e.g. "github.com=x-access-token:ghp_...".
+func ParseGitCredentials(raw string) (map[string]GitCredential, error) {
+	creds := map[string]GitCredential{}
+	if raw == "" {
+		return creds, nil
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		host, userinfo, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		username, password, hasPassword := strings.Cut(userinfo, ":")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" || !hasPassword || username == "" || password == "" {
+			return nil, fmt.Errorf("entry %q must be host=username:token", redactCredentialEntry(entry))
+		}
+		if _, dup := creds[host]; dup {
+			return nil, fmt.Errorf("duplicate credentials for host %q", host)
+		}
+		creds[host] = GitCredential{Username: username, Password: password}
+	}
+	return creds, nil
+}
+
+// redactCredentialEntry keeps the host of a malformed entry for the error
+// message, never the token
+func redactCredentialEntry(entry string) string {
+	host, _, _ := strings.Cut(strings.TrimSpace(entry), "=")
+	return host + "=" + SecretMask
+}
+
+// GitConfigError is a config source that could not be used. Stage is one of
+// the GitStage* constants.
+type GitConfigError struct {
+	Stage string
+	Err   error
+}
+
+func (e *GitConfigError) Error() string {
+	return fmt.Sprintf("config_repo %s: %v", e.Stage, e.Err)
+}
+
+func (e *GitConfigError) Unwrap() error { return e.Err }
+
+// FetchGitConfig reads src.Path from src.Repo at src.Ref and validates it as
+// cloud-config. It returns the file and the commit SHA it was read from.
+// creds is looked up by the repository host. src must already be validated.
+func FetchGitConfig(ctx context.Context, src types.ConfigSource, creds map[string]GitCredential) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, GitFetchTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "axion-gitconfig-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create scratch repository: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := gitEnv(src.Repo, creds)
+	git := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = env
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("git %s timed out after %s", args[0], GitFetchTimeout)
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("%s", msg)
+			}
+			return "", err
+		}
+		return stdout.String(), nil
+	}
+
+	if _, err := git("init", "-q"); err != nil {
+		return "", "", fmt.Errorf("git init: %w", err)
+	}
+
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := git("fetch", "-q", "--depth=1", "--no-tags", "--", src.Repo, ref); err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "couldn't find remote ref") || strings.Contains(msg, "not our ref") {
+			return "", "", &GitConfigError{Stage: GitStageRef, Err: err}
+		}
+		return "", "", &GitConfigError{Stage: GitStageRepo, Err: err}
+	}
+
+	commit, err := git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	object := "FETCH_HEAD:" + path.Clean(src.Path)
+	if kind, err := git("cat-file", "-t", object); err != nil || strings.TrimSpace(kind) != "blob" {
+		return "", "", &GitConfigError{Stage: GitStagePath, Err: fmt.Errorf("%s is not a file at %s", src.Path, commit)}
+	}
+	size, err := git("cat-file", "-s", object)
+	if err != nil {
+		return "", "", fmt.Errorf("git cat-file: %w", err)
+	}
+	if n, _ := strconv.Atoi(strings.TrimSpace(size)); n > MaxGitConfigSize {
+		return "", "", &GitConfigError{Stage: GitStageConfig, Err: fmt.Errorf("%s is %d bytes, limit is %d", src.Path, n, MaxGitConfigSize)}
+	}
+	content, err := git("cat-file", "blob", object)
+	if err != nil {
+		return "", "", fmt.Errorf("git cat-file: %w", err)
+	}
+
+	if err := ValidateCloudConfig(content); err != nil {
+		return "", "", &GitConfigError{Stage: GitStageConfig, Err: fmt.Errorf("%s at %s: %w", src.Path, commit, err)}
+	}
+	return content, commit, nil
+}
+
+// gitEnv never prompts for credentials and ignores the host's git config.
+// Credentials go in an Authorization header through GIT_CONFIG_*, so they
+// are not visible in the process list.
+func gitEnv(repo string, creds map[string]GitCredential) []string {
+	env := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_ALLOW_PROTOCOL="+gitAllowedProtocols,
+	)
+	u, err := url.Parse(repo)
+	if err != nil {
+		return env
+	}
+	cred, ok := creds[strings.ToLower(u.Hostname())]
+	if !ok {
+		return env
+	}
+	token := base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))
+	return append(env,
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic "+token,
+	)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrImageNotAllowed is returned when an image is outside the configured allowlist
+var ErrImageNotAllowed = errors.New("image not allowed")
+
+// DefaultAllowedImages is the allowlist used when AXION_ALLOWED_IMAGES is unset
+var DefaultAllowedImages = []string{"ubuntu*", "alpine*"}
+
+var (
+	allowedImagesMu sync.RWMutex
+	allowedImages   = DefaultAllowedImages
+)
+
+// ParseAllowedImages reads AXION_ALLOWED_IMAGES, a comma separated list of
+// glob patterns (path.Match syntax). Remote images are written as
+// "remote:alias", so a pattern like "images:debian/*" allows a whole remote
+// path while plain patterns only match local aliases. Empty uses
+// DefaultAllowedImages.
+func ParseAllowedImages(raw string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return DefaultAllowedImages, nil
+	}
+	return patterns, nil
+}
+
+// SetAllowedImages sets the image allowlist (from config, at startup)
+func SetAllowedImages(patterns []string) {
+	allowedImagesMu.Lock()
+	defer allowedImagesMu.Unlock()
+	allowedImages = append([]string(nil), patterns...)
+}
+
+// AllowedImages returns the image allowlist patterns
+func AllowedImages() []string {
+	allowedImagesMu.RLock()
+	defer allowedImagesMu.RUnlock()
+	return append([]string(nil), allowedImages...)
+}
+
+// IsImageAllowed reports whether image matches at least one allowlist pattern
+func IsImageAllowed(image string) bool {
+	if image == "" {
+		return false
+	}
+	for _, pattern := range AllowedImages() {
+		if ok, err := path.Match(pattern, image); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
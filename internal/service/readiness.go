@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// READINESS PROBES
+// ============================================================================
+
+// ProbeExec runs a command inside the instance and returns its exit code
+type ProbeExec func(ctx context.Context, command []string) (int, error)
+
+// CheckReadiness runs one attempt of probe against the instance at ip (tcp
+// and http) or through exec. A nil error means ready.
+func CheckReadiness(ctx context.Context, probe types.ReadinessProbe, ip string, exec ProbeExec) error {
+	probe = probe.WithDefaults()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(probe.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	switch probe.Type {
+	case types.ProbeTCP:
+		if ip == "" {
+			return errors.New("instance has no IP address")
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(probe.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case types.ProbeHTTP:
+		if ip == "" {
+			return errors.New("instance has no IP address")
+		}
+		url := "http://" + net.JoinHostPort(ip, strconv.Itoa(probe.Port)) + probe.Path
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		// Redirects are a valid answer: the service is up
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("GET %s returned %d", probe.Path, resp.StatusCode)
+		}
+		return nil
+
+	case types.ProbeExec:
+		if exec == nil {
+			return errors.New("exec probes need an LXD connection")
+		}
+		code, err := exec(ctx, probe.Command)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("%s exited with %d", probe.Command[0], code)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown probe type %q", probe.Type)
+}
+
+// WaitReady repeats check every probe interval until it succeeds, the
+// probe's max wait elapses or ctx ends. It returns the last check error.
+func WaitReady(ctx context.Context, probe types.ReadinessProbe, check func(context.Context) error) error {
+	probe = probe.WithDefaults()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(probe.MaxWaitSeconds)*time.Second)
+	defer cancel()
+
+	interval := time.Duration(probe.IntervalSeconds) * time.Second
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("not ready after waiting: %w", err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"aexon/internal/types"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer healthy.Close()
+	host, portStr, _ := net.SplitHostPort(healthy.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// A closed port: listen, note the port, then close it
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	exitCode := func(code int) ProbeExec {
+		return func(context.Context, []string) (int, error) { return code, nil }
+	}
+
+	cases := []struct {
+		name  string
+		probe types.ReadinessProbe
+		exec  ProbeExec
+		ready bool
+	}{
+		{"tcp open", types.ReadinessProbe{Type: types.ProbeTCP, Port: port}, nil, true},
+		{"tcp closed", types.ReadinessProbe{Type: types.ProbeTCP, Port: closedPort}, nil, false},
+		{"http 204", types.ReadinessProbe{Type: types.ProbeHTTP, Port: port, Path: "/health"}, nil, true},
+		{"http 503", types.ReadinessProbe{Type: types.ProbeHTTP, Port: port}, nil, false},
+		{"exec 0", types.ReadinessProbe{Type: types.ProbeExec, Command: []string{"true"}}, exitCode(0), true},
+		{"exec 1", types.ReadinessProbe{Type: types.ProbeExec, Command: []string{"false"}}, exitCode(1), false},
+	}
+	for _, tc := range cases {
+		err := CheckReadiness(context.Background(), tc.probe, host, tc.exec)
+		if (err == nil) != tc.ready {
+			t.Errorf("%s: ready = %v (err %v), want %v", tc.name, err == nil, err, tc.ready)
+		}
+	}
+}
+
+func TestWaitReadyRetriesUntilReady(t *testing.T) {
+	probe := types.ReadinessProbe{Type: types.ProbeExec, IntervalSeconds: 1, MaxWaitSeconds: 10}
+
+	calls := 0
+	err := WaitReady(context.Background(), probe, func(context.Context) error {
+		calls++
+		if calls < 2 {
+			return errors.New("booting")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("err = %v after %d calls, want ready on the second", err, calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WaitReady(ctx, probe, func(context.Context) error { return errors.New("down") }); err == nil {
+		t.Error("a probe that never passes must report not ready")
+	}
+}
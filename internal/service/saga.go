@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Saga records the completed steps of a multi-step operation together with
+// their compensations, so a failure part-way through can undo what was done.
+type Saga struct {
+	name  string
+	steps []sagaStep
+}
+
+type sagaStep struct {
+	name string
+	undo func(ctx context.Context) error
+}
+
+// RollbackStep is the outcome of one compensation
+type RollbackStep struct {
+	Step  string `json:"step"`
+	Error string `json:"error,omitempty"`
+}
+
+// RollbackReport lists the compensations that ran, most recent step first
+type RollbackReport []RollbackStep
+
+// NewSaga starts an empty saga; name is only used for logging
+func NewSaga(name string) *Saga {
+	return &Saga{name: name}
+}
+
+// Completed registers a finished step and how to undo it. A nil undo marks a
+// step with nothing to compensate.
+func (s *Saga) Completed(step string, undo func(ctx context.Context) error) {
+	s.steps = append(s.steps, sagaStep{name: step, undo: undo})
+}
+
+// Rollback runs the compensations in reverse order. A failing compensation is
+// recorded and the remaining ones still run. The saga is empty afterwards.
+func (s *Saga) Rollback(ctx context.Context) RollbackReport {
+	report := RollbackReport{}
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if step.undo == nil {
+			continue
+		}
+
+		result := RollbackStep{Step: step.name}
+		if err := step.undo(ctx); err != nil {
+			result.Error = err.Error()
+			log.Printf("[Saga %s] Compensation for %q failed: %v", s.name, step.name, err)
+		} else {
+			log.Printf("[Saga %s] Compensated %q", s.name, step.name)
+		}
+		report = append(report, result)
+	}
+	s.steps = nil
+	return report
+}
+
+// OK reports whether every compensation succeeded
+func (r RollbackReport) OK() bool {
+	for _, step := range r {
+		if step.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// String summarizes the rollback for job error details, e.g.
+// "rolled back: create_instance, allocate_ip" or "... (failed: allocate_ip: timeout)"
+func (r RollbackReport) String() string {
+	if len(r) == 0 {
+		return "nothing to roll back"
+	}
+
+	names := make([]string, 0, len(r))
+	var failed []string
+	for _, step := range r {
+		names = append(names, step.Step)
+		if step.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", step.Step, step.Error))
+		}
+	}
+
+	summary := "rolled back: " + strings.Join(names, ", ")
+	if len(failed) > 0 {
+		summary += " (failed: " + strings.Join(failed, "; ") + ")"
+	}
+	return summary
+}
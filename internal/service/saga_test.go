@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSagaRollbackRunsInReverseAndContinuesPastFailures(t *testing.T) {
+	var order []string
+	undo := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	saga := NewSaga("test")
+	saga.Completed("allocate_ip", undo("allocate_ip", nil))
+	saga.Completed("create_vm", undo("create_vm", errors.New("timeout")))
+	saga.Completed("insert_row", nil)
+
+	report := saga.Rollback(context.Background())
+
+	if len(order) != 2 || order[0] != "create_vm" || order[1] != "allocate_ip" {
+		t.Fatalf("compensations ran as %v, want [create_vm allocate_ip]", order)
+	}
+	if report.OK() {
+		t.Error("report should not be OK when a compensation failed")
+	}
+	want := "rolled back: create_vm, allocate_ip (failed: create_vm: timeout)"
+	if got := report.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if again := saga.Rollback(context.Background()); len(again) != 0 {
+		t.Errorf("second rollback ran %d compensations, want 0", len(again))
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ============================================================================
+// SECRETS
+// ============================================================================
+
+// Secrets are referenced by name in user_data and env values as
+// {{secret:name}}. The stored config keeps the reference; the value is
+// decrypted only when a job pushes the config to LXD or an AxHV VM reads its
+// cloud-init seed, and is masked as *** anywhere it could be echoed back.
+// Values are sealed with XChaCha20-Poly1305 under AXION_SECRETS_KEY, with the
+// secret name as associated data so a ciphertext cannot be moved to another name.
+
+// SecretMask replaces resolved secret values in logs and API responses
+const SecretMask = "***"
+
+var (
+	// ErrSecretNotFound is returned when a reference names a secret that does not exist
+	ErrSecretNotFound = errors.New("secret not found")
+	// ErrSecretsDisabled is returned when secrets are used without AXION_SECRETS_KEY
+	ErrSecretsDisabled = errors.New("secrets are disabled: AXION_SECRETS_KEY is not set")
+)
+
+var (
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	secretRefPattern  = regexp.MustCompile(`\{\{\s*secret:([A-Za-z0-9_.-]{1,64})\s*\}\}`)
+)
+
+// ValidateSecretName accepts 1-64 letters, digits, '_', '-' and '.'
+func ValidateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("secret name %q must be 1-64 characters of letters, digits, '_', '-' or '.'", name)
+	}
+	return nil
+}
+
+// SecretRefs returns the distinct secret names referenced in text, in order of appearance
+func SecretRefs(text string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range secretRefPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// ExpandSecrets replaces every {{secret:name}} in text with lookup(name) and
+// returns the values used, so callers can mask them later.
+func ExpandSecrets(text string, lookup func(name string) (string, error)) (string, []string, error) {
+	names := SecretRefs(text)
+	if len(names) == 0 {
+		return text, nil, nil
+	}
+
+	values := make(map[string]string, len(names))
+	used := make([]string, 0, len(names))
+	for _, name := range names {
+		value, err := lookup(name)
+		if err != nil {
+			return "", nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+		values[name] = value
+		used = append(used, value)
+	}
+
+	expanded := secretRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		return values[secretRefPattern.FindStringSubmatch(ref)[1]]
+	})
+	return expanded, used, nil
+}
+
+// MaskSecrets replaces every occurrence of values in text with SecretMask.
+// Longer values go first so a value containing another is masked whole.
+func MaskSecrets(text string, values []string) string {
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, v := range sorted {
+		text = strings.ReplaceAll(text, v, SecretMask)
+	}
+	return text
+}
+
+// DecodeSecretsKey decodes AXION_SECRETS_KEY, a base64 32-byte key like the
+// backup encryption key
+func DecodeSecretsKey(encoded string) ([]byte, error) {
+	return DecodeBackupKey(encoded)
+}
+
+// SecretBox seals and opens secret values
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox takes the 32-byte AXION_SECRETS_KEY
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts value for name: nonce | ciphertext
+func (b *SecretBox) Seal(name, value string) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(value)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+// Open decrypts a value sealed for name
+func (b *SecretBox) Open(name string, sealed []byte) (string, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return "", errors.New("sealed secret is too short")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plain, err := b.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt secret %q (wrong AXION_SECRETS_KEY?)", name)
+	}
+	return string(plain), nil
+}
+
+// SecretSource reads sealed values and their creator; db.SecretRepository
+// implements it and returns sql.ErrNoRows for unknown names.
+type SecretSource interface {
+	GetCiphertext(ctx context.Context, name string) ([]byte, string, error)
+	ListCiphertexts(ctx context.Context) (map[string][]byte, error)
+}
+
+// SecretStore resolves and masks secret references. A nil store means secrets
+// are disabled: text without references passes through, references fail.
+type SecretStore struct {
+	box    *SecretBox
+	source SecretSource
+}
+
+func NewSecretStore(box *SecretBox, source SecretSource) *SecretStore {
+	return &SecretStore{box: box, source: source}
+}
+
+// Box returns the cipher used to seal new values (nil when disabled)
+func (s *SecretStore) Box() *SecretBox {
+	if s == nil {
+		return nil
+	}
+	return s.box
+}
+
+// Check fails if text references a secret that cannot be resolved or that
+// owner did not create (an empty owner, i.e. an admin, may use any), without
+// decrypting anything. Jobs resolve later without a user, so every request
+// that stores or queues user_data must pass this check first.
+func (s *SecretStore) Check(ctx context.Context, text string, owner string) error {
+	names := SecretRefs(text)
+	if len(names) == 0 {
+		return nil
+	}
+	if s == nil {
+		return ErrSecretsDisabled
+	}
+	for _, name := range names {
+		_, createdBy, err := s.source.GetCiphertext(ctx, name)
+		// Someone else's secret is reported as missing, not as forbidden
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && owner != "" && createdBy != owner) {
+			return fmt.Errorf("secret %q: %w", name, ErrSecretNotFound)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve expands the references in text and returns the values used
+func (s *SecretStore) Resolve(ctx context.Context, text string) (string, []string, error) {
+	if len(SecretRefs(text)) == 0 {
+		return text, nil, nil
+	}
+	if s == nil {
+		return "", nil, ErrSecretsDisabled
+	}
+	return ExpandSecrets(text, func(name string) (string, error) {
+		sealed, _, err := s.source.GetCiphertext(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrSecretNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return s.box.Open(name, sealed)
+	})
+}
+
+// Values decrypts every stored secret, for masking text that may contain
+// resolved values (e.g. the LXD config). nil when disabled.
+func (s *SecretStore) Values(ctx context.Context) ([]string, error) {
+	if s == nil {
+		return nil, nil
+	}
+	sealed, err := s.source.ListCiphertexts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(sealed))
+	for name, ciphertext := range sealed {
+		if value, err := s.box.Open(name, ciphertext); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// Mask hides every stored secret value found in text
+func (s *SecretStore) Mask(ctx context.Context, text string) (string, error) {
+	values, err := s.Values(ctx)
+	if err != nil {
+		return "", err
+	}
+	return MaskSecrets(text, values), nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memorySecrets holds secrets created by "alice"
+type memorySecrets map[string][]byte
+
+func (m memorySecrets) GetCiphertext(_ context.Context, name string) ([]byte, string, error) {
+	if v, ok := m[name]; ok {
+		return v, "alice", nil
+	}
+	return nil, "", sql.ErrNoRows
+}
+
+func (m memorySecrets) ListCiphertexts(context.Context) (map[string][]byte, error) {
+	return m, nil
+}
+
+func newTestSecretStore(t *testing.T, values map[string]string) *SecretStore {
+	t.Helper()
+	key := make([]byte, 32)
+	rand.Read(key)
+	box, err := NewSecretBox(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := memorySecrets{}
+	for name, value := range values {
+		sealed, err := box.Seal(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(sealed), value) {
+			t.Fatalf("sealed %s contains the plaintext", name)
+		}
+		source[name] = sealed
+	}
+	return NewSecretStore(box, source)
+}
+
+func TestSecretRefs(t *testing.T) {
+	text := "a: {{secret:db_pass}}\nb: {{ secret:api.key }}\nc: {{secret:db_pass}}\nd: {{secret:bad name}}"
+	got := SecretRefs(text)
+	if strings.Join(got, ",") != "db_pass,api.key" {
+		t.Errorf("refs = %v, want [db_pass api.key]", got)
+	}
+}
+
+func TestSecretStoreResolveAndMask(t *testing.T) {
+	store := newTestSecretStore(t, map[string]string{"db_pass": "hunter2", "token": "hunter2-long"})
+	ctx := context.Background()
+
+	userData := "#cloud-config\nwrite_files:\n  - content: '{{secret:db_pass}} {{secret:token}}'\n"
+	resolved, values, err := store.Resolve(ctx, userData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resolved, "'hunter2 hunter2-long'") || strings.Contains(resolved, "{{") {
+		t.Fatalf("unexpected resolution:\n%s", resolved)
+	}
+
+	if masked := MaskSecrets("failed: "+resolved, values); strings.Contains(masked, "hunter2") {
+		t.Errorf("MaskSecrets left a value behind: %s", masked)
+	}
+	masked, err := store.Mask(ctx, resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(masked, "'*** ***'") {
+		t.Errorf("Mask = %q, want both values masked whole", masked)
+	}
+
+	if _, _, err := store.Resolve(ctx, "{{secret:missing}}"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("missing secret: got %v, want ErrSecretNotFound", err)
+	}
+	if err := store.Check(ctx, "{{secret:missing}}", ""); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Check: got %v, want ErrSecretNotFound", err)
+	}
+	if err := store.Check(ctx, userData, "alice"); err != nil {
+		t.Errorf("Check by the owner: %v", err)
+	}
+	if err := store.Check(ctx, userData, "mallory"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Check by another user: got %v, want ErrSecretNotFound", err)
+	}
+}
+
+func TestSecretCiphertextIsBoundToName(t *testing.T) {
+	key := make([]byte, 32)
+	box, _ := NewSecretBox(key)
+	sealed, _ := box.Seal("a", "value")
+	if _, err := box.Open("b", sealed); err == nil {
+		t.Error("a ciphertext sealed for one name must not open under another")
+	}
+}
+
+func TestNilSecretStore(t *testing.T) {
+	var store *SecretStore
+	ctx := context.Background()
+
+	if out, _, err := store.Resolve(ctx, "plain"); err != nil || out != "plain" {
+		t.Errorf("text without references should pass through, got %q, %v", out, err)
+	}
+	if _, _, err := store.Resolve(ctx, "{{secret:x}}"); !errors.Is(err, ErrSecretsDisabled) {
+		t.Errorf("got %v, want ErrSecretsDisabled", err)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StackMember is one instance of a stack and the members it must boot after
+type StackMember struct {
+	Name      string
+	DependsOn []string
+}
+
+// StackBootOrder returns the member names ordered so every member comes after
+// its dependencies. Members with no ordering between them keep the order they
+// were declared in. Duplicate names, unknown dependencies and cycles are errors.
+func StackBootOrder(members []StackMember) ([]string, error) {
+	declared := make(map[string]bool, len(members))
+	for _, m := range members {
+		if m.Name == "" {
+			return nil, fmt.Errorf("stack member without a name")
+		}
+		if declared[m.Name] {
+			return nil, fmt.Errorf("instance %q is declared twice", m.Name)
+		}
+		declared[m.Name] = true
+	}
+	for _, m := range members {
+		for _, dep := range m.DependsOn {
+			if !declared[dep] {
+				return nil, fmt.Errorf("instance %q depends on %q, which is not part of the stack", m.Name, dep)
+			}
+		}
+	}
+
+	placed := make(map[string]bool, len(members))
+	order := make([]string, 0, len(members))
+	for len(order) < len(members) {
+		progressed := false
+		for _, m := range members {
+			if placed[m.Name] || !dependenciesPlaced(m, placed) {
+				continue
+			}
+			placed[m.Name] = true
+			order = append(order, m.Name)
+			progressed = true
+			break
+		}
+		if !progressed {
+			var blocked []string
+			for _, m := range members {
+				if !placed[m.Name] {
+					blocked = append(blocked, m.Name)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(blocked, ", "))
+		}
+	}
+	return order, nil
+}
+
+func dependenciesPlaced(m StackMember, placed map[string]bool) bool {
+	for _, dep := range m.DependsOn {
+		if !placed[dep] {
+			return false
+		}
+	}
+	return true
+}
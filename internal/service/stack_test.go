@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStackBootOrder(t *testing.T) {
+	members := []StackMember{
+		{Name: "web", DependsOn: []string{"api"}},
+		{Name: "db"},
+		{Name: "api", DependsOn: []string{"db", "cache"}},
+		{Name: "cache"},
+	}
+
+	order, err := StackBootOrder(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"db", "cache", "api", "web"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("got %v, want %v", order, want)
+	}
+}
+
+func TestStackBootOrderRejectsInvalidGraphs(t *testing.T) {
+	cases := map[string][]StackMember{
+		"duplicate": {{Name: "db"}, {Name: "db"}},
+		"unknown":   {{Name: "web", DependsOn: []string{"db"}}},
+		"self":      {{Name: "db", DependsOn: []string{"db"}}},
+		"cycle": {
+			{Name: "a", DependsOn: []string{"c"}},
+			{Name: "b", DependsOn: []string{"a"}},
+			{Name: "c", DependsOn: []string{"b"}},
+			{Name: "d"},
+		},
+	}
+
+	for name, members := range cases {
+		if _, err := StackBootOrder(members); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrStorageFull is returned by HealthCheck when free space is below the minimum
+var ErrStorageFull = errors.New("storage is full")
+
+// StorageHealth is the state of the storage directory found by HealthCheck
+type StorageHealth struct {
+	Path       string `json:"path"`
+	Writable   bool   `json:"writable"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+// HealthCheck verifies that the storage directory exists, accepts writes (a
+// probe file is written and removed) and keeps at least minFree bytes
+// available. The returned health is filled as far as the checks got.
+func (s *StorageService) HealthCheck(minFree uint64) (*StorageHealth, error) {
+	health := &StorageHealth{Path: s.storageDir}
+
+	info, err := os.Stat(s.storageDir)
+	if err != nil {
+		return health, fmt.Errorf("storage directory unavailable: %w", err)
+	}
+	if !info.IsDir() {
+		return health, fmt.Errorf("storage path %s is not a directory", s.storageDir)
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(s.storageDir, &fs); err != nil {
+		return health, fmt.Errorf("failed to stat storage filesystem: %w", err)
+	}
+	health.FreeBytes = fs.Bavail * uint64(fs.Bsize)
+	health.TotalBytes = fs.Blocks * uint64(fs.Bsize)
+
+	probe, err := os.CreateTemp(s.storageDir, ".healthcheck-*")
+	if err != nil {
+		return health, fmt.Errorf("storage is not writable: %w", err)
+	}
+	_, writeErr := probe.Write([]byte("ok"))
+	closeErr := probe.Close()
+	removeErr := os.Remove(probe.Name())
+	if err := errors.Join(writeErr, closeErr, removeErr); err != nil {
+		return health, fmt.Errorf("storage is not writable: %w", err)
+	}
+	health.Writable = true
+
+	if health.FreeBytes < minFree {
+		return health, fmt.Errorf("%w: %d bytes free, %d required", ErrStorageFull, health.FreeBytes, minFree)
+	}
+	return health, nil
+}
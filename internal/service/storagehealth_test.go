@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageHealthCheck(t *testing.T) {
+	s := &StorageService{storageDir: t.TempDir()}
+
+	health, err := s.HealthCheck(0)
+	if err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	if !health.Writable || health.TotalBytes == 0 {
+		t.Errorf("unexpected health: %+v", health)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(s.storageDir, ".healthcheck-*")); len(matches) > 0 {
+		t.Errorf("probe file left behind: %v", matches)
+	}
+
+	if _, err := s.HealthCheck(math.MaxUint64); !errors.Is(err, ErrStorageFull) {
+		t.Errorf("got %v, want ErrStorageFull", err)
+	}
+}
+
+func TestStorageHealthCheckMissingDirectory(t *testing.T) {
+	s := &StorageService{storageDir: filepath.Join(t.TempDir(), "missing")}
+
+	health, err := s.HealthCheck(0)
+	if err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+	if health.Writable {
+		t.Error("missing directory reported as writable")
+	}
+}
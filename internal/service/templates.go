@@ -1,5 +1,11 @@
 package service
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
 type Template struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -10,7 +16,40 @@ type Template struct {
 	CloudConfig string `json:"-"` // O YAML do cloud-init (não enviar no JSON de lista)
 }
 
+// builtinTemplates é montada uma única vez: os cloud-configs são strings grandes
+var builtinTemplates = buildTemplates()
+
+// GetTemplates devolve uma cópia da lista de templates embutidos
 func GetTemplates() []Template {
+	out := make([]Template, len(builtinTemplates))
+	copy(out, builtinTemplates)
+	return out
+}
+
+// GetTemplateByID procura um template pelo ID. Hoje só existem os embutidos;
+// quando houver templates de usuário no banco, a busca deve consultá-los também.
+func GetTemplateByID(id string) (Template, bool) {
+	for _, t := range builtinTemplates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
+
+// TemplatesETag identifica um conjunto de templates (embutidos + do usuário).
+// O hash cobre todos os campos, inclusive o cloud-config que não vai no JSON
+// da lista, e a ordem; adicionar, remover ou editar um template muda o ETag.
+func TemplatesETag(templates []Template) string {
+	h := sha256.New()
+	for _, t := range templates {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%d\x00%s\x00\x01",
+			t.ID, t.Name, t.Icon, t.Description, t.MinCPU, t.MinRAM, t.CloudConfig)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+func buildTemplates() []Template {
 	return []Template{
 		{
 			ID:          "docker-host",
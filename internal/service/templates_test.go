@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestTemplatesETagTracksTheSet(t *testing.T) {
+	builtin := GetTemplates()
+	base := TemplatesETag(builtin)
+
+	if again := TemplatesETag(GetTemplates()); again != base {
+		t.Errorf("ETag not stable: %s != %s", again, base)
+	}
+
+	withUser := append(GetTemplates(), Template{ID: "my-app", Name: "My App", CloudConfig: "#cloud-config\n"})
+	if TemplatesETag(withUser) == base {
+		t.Error("adding a template must change the ETag")
+	}
+
+	edited := GetTemplates()
+	edited[0].CloudConfig += "\nruncmd: [true]\n"
+	if TemplatesETag(edited) == base {
+		t.Error("changing a cloud-config must change the ETag")
+	}
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ConfigSource aponta o arquivo de um repositório Git usado como user_data
+// na criação (GitOps). Commit é o SHA efetivamente lido, gravado na instância
+// para saber exatamente o que foi implantado.
+type ConfigSource struct {
+	Repo   string `json:"repo"`
+	Ref    string `json:"ref,omitempty"` // branch, tag ou SHA; vazio usa o HEAD do remoto
+	Path   string `json:"path"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Validate exige repo https:// sem credenciais na URL (elas vêm de
+// AXION_GIT_CREDENTIALS), ref sem caracteres proibidos pelo git e path
+// relativo à raiz do repositório.
+func (s ConfigSource) Validate() error {
+	u, err := url.Parse(s.Repo)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("config_repo deve ser uma URL https://")
+	}
+	if u.User != nil {
+		return fmt.Errorf("config_repo não pode conter credenciais; use AXION_GIT_CREDENTIALS")
+	}
+
+	if s.Ref != "" && (strings.HasPrefix(s.Ref, "-") || strings.Contains(s.Ref, "..") ||
+		strings.ContainsAny(s.Ref, " \t\n~^:?*[\\")) {
+		return fmt.Errorf("ref inválida %q", s.Ref)
+	}
+
+	if s.Path == "" {
+		return fmt.Errorf("path é obrigatório com config_repo")
+	}
+	clean := path.Clean(s.Path)
+	if path.IsAbs(s.Path) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("path %q deve ser um arquivo relativo à raiz do repositório", s.Path)
+	}
+	return nil
+}
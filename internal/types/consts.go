@@ -11,14 +11,22 @@ const (
 	JobCanceled   JobStatus = "CANCELED"
 )
 
+// IsTerminal indica se o job não muda mais de estado.
+func (s JobStatus) IsTerminal() bool {
+	return s == JobCompleted || s == JobFailed || s == JobCanceled
+}
+
 // JobType define os tipos de ações suportadas.
 type JobType string
 
 const (
 	JobTypeStateChange    JobType = "state_change"
 	JobTypeUpdateLimits   JobType = "update_limits"
+	JobTypeUpdateIOLimits JobType = "update_io_limits"
 	JobTypeCreateInstance JobType = "create_instance"
 	JobTypeDeleteInstance JobType = "delete_instance"
+	JobTypeCloneInstance  JobType = "clone_instance"
+	JobTypeRenameInstance JobType = "rename_instance"
 
 	// Snapshot Jobs
 	JobTypeCreateSnapshot  JobType = "create_snapshot"
@@ -28,6 +36,24 @@ const (
 	// Port Forwarding Jobs
 	JobTypeAddPort    JobType = "add_port"
 	JobTypeRemovePort JobType = "remove_port"
+
+	// Cloud-Init Jobs
+	JobTypeReapplyCloudInit JobType = "reapply_cloud_init"
+	JobTypeFetchConfig      JobType = "fetch_config"    // lê o user_data do config_repo (GitOps)
+	JobTypeWaitCloudInit    JobType = "wait_cloud_init" // acompanha o cloud-init de uma instância recém-criada
+
+	// Device Passthrough Jobs
+	JobTypeAddDevice    JobType = "add_device"
+	JobTypeRemoveDevice JobType = "remove_device"
+
+	// Exec Jobs
+	JobTypeExec JobType = "exec"
+
+	// Image Jobs
+	JobTypePullImage JobType = "pull_image"
+
+	// Migration Jobs
+	JobTypeMigrateInstance JobType = "migrate_instance"
 )
 
 // Constantes de retry
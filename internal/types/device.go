@@ -0,0 +1,21 @@
+package types
+
+import "time"
+
+// Tipos de device suportados pelo passthrough.
+const (
+	DeviceTypeGPU   = "gpu"
+	DeviceTypeUSB   = "usb"
+	DeviceTypeDisk  = "disk"
+	DeviceTypeProxy = "proxy"
+)
+
+// InstanceDevice é um device de host anexado a uma instância (persistido e
+// reconciliado com o LXD no startup).
+type InstanceDevice struct {
+	Instance  string            `json:"instance"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Config    map[string]string `json:"config"`
+	CreatedAt time.Time         `json:"created_at"`
+}
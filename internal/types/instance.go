@@ -12,11 +12,42 @@ type InstanceBackupInfo struct {
 	LastStatus string     `json:"last_status,omitempty"` // "completed", "failed"
 }
 
+// Estados de instância usados na detecção de crash
+const (
+	StatusRunning = "RUNNING"
+	StatusStopped = "STOPPED"
+	StatusCrashed = "CRASHED"
+)
+
+// DesiredStateForAction devolve o estado desejado após uma ação de usuário.
+// pause/resume não mudam a intenção (false).
+func DesiredStateForAction(action string) (string, bool) {
+	switch action {
+	case "start", "reboot", "restart":
+		return StatusRunning, true
+	case "stop":
+		return StatusStopped, true
+	}
+	return "", false
+}
+
+// ClassifyStatus distingue parada intencional de crash: uma instância parada
+// cujo último estado pedido era RUNNING é reportada como CRASHED. Estado
+// pedido desconhecido ("", instâncias anteriores ao desired_state) nunca é crash.
+func ClassifyStatus(observed, desired string) string {
+	if observed == StatusStopped && desired == StatusRunning {
+		return StatusCrashed
+	}
+	return observed
+}
+
 type Instance struct {
 	Name               string              `json:"name"`
 	Image              string              `json:"image"`
-	Status             string              `json:"status"`    // RUNNING, STOPPED, etc. (from AxHV)
-	IpAddress          string              `json:"ipAddress"` // From ip_leases table
+	Description        string              `json:"description"`
+	Status             string              `json:"status"`                  // RUNNING, STOPPED, CRASHED, etc. (from AxHV)
+	DesiredState       string              `json:"desired_state,omitempty"` // Último estado pedido pelo usuário
+	IpAddress          string              `json:"ipAddress"`               // From ip_leases table
 	Limits             map[string]string   `json:"limits"`
 	UserData           string              `json:"user_data"`
 	Type               string              `json:"type"`
@@ -24,9 +55,17 @@ type Instance struct {
 	BackupRetention    int                 `json:"backup_retention"`
 	BackupEnabled      bool                `json:"backup_enabled"`
 	BackupInfo         *InstanceBackupInfo `json:"backup_info,omitempty"`
-	Node               string              `json:"node"`                 // Ex: "pve-01" ou "lxd-node-1"
-	CPUCount           int                 `json:"cpu_count"`            // Quantidade de vCPUs
-	DiskUsage          int64               `json:"disk_usage"`           // Bytes usados
-	DiskLimit          int64               `json:"disk_limit"`           // Bytes totais (tamanho do disco)
-	BandwidthLimitMbps int                 `json:"bandwidth_limit_mbps"` // 0 = unlimited
+	Node               string              `json:"node"`                        // Ex: "pve-01" ou "lxd-node-1"
+	CPUCount           int                 `json:"cpu_count"`                   // Quantidade de vCPUs
+	CPUAllowance       string              `json:"cpu_allowance,omitempty"`     // limits.cpu.allowance efetivo
+	CPUPriority        *int                `json:"cpu_priority,omitempty"`      // limits.cpu.priority efetivo (0-10)
+	DiskUsage          int64               `json:"disk_usage"`                  // Bytes usados
+	DiskLimit          int64               `json:"disk_limit"`                  // Bytes totais (tamanho do disco)
+	BandwidthLimitMbps int                 `json:"bandwidth_limit_mbps"`        // 0 = unlimited
+	Devices            []InstanceDevice    `json:"devices,omitempty"`           // Passthrough devices
+	StoragePool        string              `json:"storage_pool,omitempty"`      // Vazio = pool padrão do provider
+	CloudInitStatus    string              `json:"cloud_init_status,omitempty"` // running, done, provisioning-failed, disabled
+	Owner              string              `json:"owner,omitempty"`             // Usuário que criou a instância
+	ReadinessProbe     *ReadinessProbe     `json:"-"`                           // Gravada na criação; lida via GetReadiness
+	ConfigSource       *ConfigSource       `json:"config_source,omitempty"`     // Repositório Git do user_data, com o commit usado
 }
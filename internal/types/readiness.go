@@ -0,0 +1,93 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tipos de readiness probe
+const (
+	ProbeTCP  = "tcp"
+	ProbeHTTP = "http"
+	ProbeExec = "exec"
+)
+
+// Padrões e limites da readiness probe, em segundos
+const (
+	DefaultProbeInterval = 5
+	DefaultProbeTimeout  = 2
+	DefaultProbeMaxWait  = 300
+	maxProbeMaxWait      = 3600
+)
+
+// ReadinessProbe define quando uma instância está pronta para uso: porta TCP
+// aceitando conexões, GET HTTP com status 2xx/3xx ou comando saindo com 0.
+// É avaliada depois da criação e de cada start/restart.
+type ReadinessProbe struct {
+	Type    string   `json:"type"`
+	Port    int      `json:"port,omitempty"`    // tcp e http
+	Path    string   `json:"path,omitempty"`    // http; padrão "/"
+	Command []string `json:"command,omitempty"` // exec
+
+	IntervalSeconds int `json:"interval_seconds,omitempty"` // Entre tentativas
+	TimeoutSeconds  int `json:"timeout_seconds,omitempty"`  // Por tentativa
+	MaxWaitSeconds  int `json:"max_wait_seconds,omitempty"` // Desiste e marca não pronta
+}
+
+// Readiness é o último resultado da probe de uma instância. Ready nil
+// significa ainda não avaliada (ou sem probe).
+type Readiness struct {
+	Probe     *ReadinessProbe `json:"probe"`
+	Ready     *bool           `json:"ready"`
+	CheckedAt *time.Time      `json:"checked_at,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
+
+// WithDefaults preenche intervalo, timeout, espera máxima e path ausentes.
+func (p ReadinessProbe) WithDefaults() ReadinessProbe {
+	if p.IntervalSeconds == 0 {
+		p.IntervalSeconds = DefaultProbeInterval
+	}
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = DefaultProbeTimeout
+	}
+	if p.MaxWaitSeconds == 0 {
+		p.MaxWaitSeconds = DefaultProbeMaxWait
+	}
+	if p.Type == ProbeHTTP && p.Path == "" {
+		p.Path = "/"
+	}
+	return p
+}
+
+// Validate confere os campos exigidos pelo tipo e os tempos.
+func (p ReadinessProbe) Validate() error {
+	switch p.Type {
+	case ProbeTCP, ProbeHTTP:
+		if p.Port < 1 || p.Port > 65535 {
+			return fmt.Errorf("probe %s exige port entre 1 e 65535", p.Type)
+		}
+		if p.Type == ProbeHTTP && p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			return fmt.Errorf("path da probe http deve começar com /")
+		}
+	case ProbeExec:
+		if len(p.Command) == 0 || strings.TrimSpace(p.Command[0]) == "" {
+			return fmt.Errorf("probe exec exige command")
+		}
+	default:
+		return fmt.Errorf("tipo de probe inválido %q (use %s, %s ou %s)", p.Type, ProbeTCP, ProbeHTTP, ProbeExec)
+	}
+
+	if p.IntervalSeconds < 0 || p.TimeoutSeconds < 0 || p.MaxWaitSeconds < 0 {
+		return fmt.Errorf("tempos da probe não podem ser negativos")
+	}
+	p = p.WithDefaults()
+	if p.TimeoutSeconds > p.IntervalSeconds {
+		return fmt.Errorf("timeout_seconds (%d) não pode passar de interval_seconds (%d)", p.TimeoutSeconds, p.IntervalSeconds)
+	}
+	if p.MaxWaitSeconds > maxProbeMaxWait {
+		return fmt.Errorf("max_wait_seconds não pode passar de %d", maxProbeMaxWait)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package types
+
+import "fmt"
+
+// Modos de retenção dos backups agendados
+const (
+	RetentionSimple = "simple" // mantém os N mais recentes (backup_retention)
+	RetentionGFS    = "gfs"    // avô-pai-filho: um por hora/dia/semana/mês
+)
+
+// maxRetentionBucket limita cada contagem GFS (10 anos de mensais, por exemplo)
+const maxRetentionBucket = 1000
+
+// RetentionPolicy escolhe quais backups agendados sobrevivem ao prune. No modo
+// gfs cada contagem guarda o backup mais recente de cada um dos últimos N
+// períodos (hora, dia, semana ISO, mês, em UTC) que têm backup; um mesmo
+// backup pode valer por vários períodos. No modo simple as contagens são
+// ignoradas e vale backup_retention.
+type RetentionPolicy struct {
+	Mode    string `json:"mode"`
+	Hourly  int    `json:"hourly,omitempty"`
+	Daily   int    `json:"daily,omitempty"`
+	Weekly  int    `json:"weekly,omitempty"`
+	Monthly int    `json:"monthly,omitempty"`
+}
+
+// Validate confere o modo e, no gfs, as contagens.
+func (p RetentionPolicy) Validate() error {
+	switch p.Mode {
+	case RetentionSimple:
+		return nil
+	case RetentionGFS:
+	default:
+		return fmt.Errorf("modo de retenção inválido %q (use %s ou %s)", p.Mode, RetentionSimple, RetentionGFS)
+	}
+
+	counts := []struct {
+		name string
+		n    int
+	}{{"hourly", p.Hourly}, {"daily", p.Daily}, {"weekly", p.Weekly}, {"monthly", p.Monthly}}
+	total := 0
+	for _, c := range counts {
+		if c.n < 0 || c.n > maxRetentionBucket {
+			return fmt.Errorf("%s deve estar entre 0 e %d", c.name, maxRetentionBucket)
+		}
+		total += c.n
+	}
+	if total == 0 {
+		return fmt.Errorf("retenção gfs exige ao menos uma contagem (hourly, daily, weekly ou monthly)")
+	}
+	return nil
+}
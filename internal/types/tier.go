@@ -0,0 +1,92 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TierPolicy define os limites de recursos aplicados a uma instância de acordo
+// com o plano do usuário. Zero em qualquer campo significa "sem limite".
+type TierPolicy struct {
+	Name             string `json:"name"`
+	MaxTcpPorts      int    `json:"max_tcp_ports"`
+	MaxUdpPorts      int    `json:"max_udp_ports"`
+	MaxBandwidthMbps int    `json:"max_bandwidth_mbps"`
+	MaxVcpu          int    `json:"max_vcpu"`
+	MaxMemoryMiB     int    `json:"max_memory_mib"`
+	MaxDiskGB        int    `json:"max_disk_gb"`
+}
+
+const (
+	PlanFree = "free"
+)
+
+// FreeTierPolicy são os limites do plano gratuito (os antigos valores fixos do mapper).
+func FreeTierPolicy() TierPolicy {
+	return TierPolicy{
+		Name:        PlanFree,
+		MaxTcpPorts: 3,
+		MaxUdpPorts: 1,
+	}
+}
+
+// UnlimitedTierPolicy não impõe nenhum limite (planos pagos e admins).
+func UnlimitedTierPolicy() TierPolicy {
+	return TierPolicy{Name: "unlimited"}
+}
+
+// TierPolicies mapeia o plano do usuário para a sua política. Planos ausentes
+// do mapa não têm limite; "free" ausente usa FreeTierPolicy.
+type TierPolicies map[string]TierPolicy
+
+// DefaultTierPolicies são as políticas usadas sem AXION_TIER_POLICIES.
+func DefaultTierPolicies() TierPolicies {
+	return TierPolicies{PlanFree: FreeTierPolicy()}
+}
+
+// ParseTierPolicies lê AXION_TIER_POLICIES, um objeto JSON de plano para
+// limites, ex.: {"free":{"max_tcp_ports":3},"pro":{"max_vcpu":8}}. Os campos
+// usam as tags JSON de TierPolicy; vazio usa DefaultTierPolicies.
+func ParseTierPolicies(raw string) (TierPolicies, error) {
+	policies := DefaultTierPolicies()
+	if strings.TrimSpace(raw) == "" {
+		return policies, nil
+	}
+
+	var parsed map[string]TierPolicy
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	for plan, policy := range parsed {
+		if plan == "" {
+			return nil, fmt.Errorf("nome de plano vazio")
+		}
+		if policy.MaxTcpPorts < 0 || policy.MaxUdpPorts < 0 || policy.MaxBandwidthMbps < 0 ||
+			policy.MaxVcpu < 0 || policy.MaxMemoryMiB < 0 || policy.MaxDiskGB < 0 {
+			return nil, fmt.Errorf("plano %q: limites não podem ser negativos", plan)
+		}
+		policy.Name = plan
+		policies[plan] = policy
+	}
+	return policies, nil
+}
+
+// ForPlan resolve a política a partir do plano do usuário. Plano vazio conta
+// como "free"; um plano sem política configurada é pago e não tem limites.
+func (p TierPolicies) ForPlan(plan string) TierPolicy {
+	if plan == "" {
+		plan = PlanFree
+	}
+	if policy, ok := p[plan]; ok {
+		return policy
+	}
+	if plan == PlanFree {
+		return FreeTierPolicy()
+	}
+	policy := UnlimitedTierPolicy()
+	policy.Name = plan
+	return policy
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -89,3 +90,38 @@ func ParseMemoryToBytes(memStr string) int64 {
 		return 0
 	}
 }
+
+var diskSizeRegex = regexp.MustCompile(`^(\d+)(MB|M|GB|G|TB|T)?$`)
+
+// ParseDiskToGB converte tamanhos de disco como "20", "20GB", "20480MB" ou "1TB" para GB.
+// Valores sem unidade são interpretados como GB; MB é arredondado para cima.
+// Ao contrário dos parsers acima, retorna erro para entradas inválidas em vez de 0,
+// para que o chamador não caia silenciosamente num default.
+func ParseDiskToGB(diskStr string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(diskStr))
+
+	matches := diskSizeRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid disk size %q (expected e.g. 20, 20GB, 20480MB)", diskStr)
+	}
+
+	val, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid disk size %q: %w", diskStr, err)
+	}
+
+	var gb int64
+	switch matches[2] {
+	case "TB", "T":
+		gb = val * 1024
+	case "GB", "G", "":
+		gb = val
+	case "MB", "M":
+		gb = (val + 1023) / 1024
+	}
+
+	if gb <= 0 {
+		return 0, fmt.Errorf("disk size must be greater than zero, got %q", diskStr)
+	}
+	return gb, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// MaxRetryInterval limita o intervalo entre tentativas em Retry
+const MaxRetryInterval = 30 * time.Second
+
+// Retry executa fn até ela ter sucesso ou as tentativas acabarem, dobrando o
+// intervalo a cada falha (até MaxRetryInterval). Retorna o último erro.
+// what identifica a operação nos logs.
+func Retry(ctx context.Context, what string, attempts int, interval time.Duration, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return err
+		}
+
+		log.Printf("[Retry] %s falhou (tentativa %d/%d): %v. Nova tentativa em %v", what, attempt, attempts, err, interval)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > MaxRetryInterval {
+			interval = MaxRetryInterval
+		}
+	}
+}
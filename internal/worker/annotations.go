@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/events"
+)
+
+// ============================================================================
+// ANOTAÇÃO DE EVENTOS
+// ============================================================================
+
+// annotationTTL é quanto tempo os metadados de uma instância ficam em cache.
+// Eventos de progresso chegam em rajadas; o banco é consultado uma vez por janela.
+const annotationTTL = 30 * time.Second
+
+// annotationFailureTTL segura novas consultas depois de uma falha do banco,
+// para um banco fora do ar não ser consultado a cada evento
+const annotationFailureTTL = 5 * time.Second
+
+// loadAnnotations lê tipo, dono e tags; variável para os testes trocarem o banco
+var loadAnnotations = db.GetInstanceAnnotations
+
+// goAnnotate roda a atualização do cache; variável para os testes a tornarem síncrona
+var goAnnotate = func(refresh func()) { go refresh() }
+
+type cachedMeta struct {
+	meta    *events.InstanceMeta
+	expires time.Time
+}
+
+var (
+	annotationMu         sync.Mutex
+	annotationCache      = map[string]cachedMeta{}
+	annotationRefreshing = map[string]bool{}
+)
+
+// annotateEvent é o events.Enricher do worker: devolve tipo, node, dono e tags
+// da instância sem bloquear quem publica. Só responde do cache; uma entrada
+// ausente ou vencida é atualizada em segundo plano, e até lá o evento sai com
+// a anotação anterior (ou nenhuma). Instâncias desconhecidas também ficam em
+// cache (nil), para não consultar o banco a cada evento.
+func annotateEvent(name string) *events.InstanceMeta {
+	annotationMu.Lock()
+	cached, ok := annotationCache[name]
+	if ok && time.Now().Before(cached.expires) {
+		annotationMu.Unlock()
+		return cached.meta
+	}
+	refresh := !annotationRefreshing[name]
+	annotationRefreshing[name] = true
+	annotationMu.Unlock()
+
+	if refresh {
+		goAnnotate(func() { refreshAnnotation(name) })
+	}
+	return cached.meta
+}
+
+// refreshAnnotation consulta os metadados de name e atualiza o cache
+func refreshAnnotation(name string) {
+	meta, err := instanceMeta(name)
+	now := time.Now()
+	entry := cachedMeta{meta: meta, expires: now.Add(annotationTTL)}
+	if err != nil {
+		log.Printf("[Worker] Falha ao ler metadados de %s para eventos: %v", name, err)
+		entry.expires = now.Add(annotationFailureTTL)
+	}
+
+	annotationMu.Lock()
+	defer annotationMu.Unlock()
+	if err != nil {
+		// A anotação anterior continua valendo até a próxima tentativa
+		entry.meta = annotationCache[name].meta
+	}
+	for key, e := range annotationCache {
+		if now.After(e.expires) {
+			delete(annotationCache, key)
+		}
+	}
+	annotationCache[name] = entry
+	delete(annotationRefreshing, name)
+}
+
+func instanceMeta(name string) (*events.InstanceMeta, error) {
+	annotations, err := loadAnnotations(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &events.InstanceMeta{
+		Type:  annotations.Type,
+		Owner: annotations.Owner,
+		Tags:  annotations.Tags,
+	}
+	if len(meta.Tags) > events.MaxEventTags {
+		meta.Tags = meta.Tags[:events.MaxEventTags]
+	}
+	if fleet != nil {
+		if _, remote, err := fleet.ForInstance(name); err == nil {
+			meta.Node = remote
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"aexon/internal/db"
+	"aexon/internal/events"
+)
+
+func TestAnnotateEventCachesAndBoundsTags(t *testing.T) {
+	calls := 0
+	prevLoad, prevGo := loadAnnotations, goAnnotate
+	t.Cleanup(func() {
+		loadAnnotations, goAnnotate = prevLoad, prevGo
+		annotationCache = map[string]cachedMeta{}
+	})
+	goAnnotate = func(refresh func()) { refresh() }
+	loadAnnotations = func(name string) (*db.InstanceAnnotations, error) {
+		calls++
+		if name == "ghost" {
+			return nil, sql.ErrNoRows
+		}
+		var tags []string
+		for i := 0; i < events.MaxEventTags+5; i++ {
+			tags = append(tags, fmt.Sprintf("tag-%d", i))
+		}
+		return &db.InstanceAnnotations{Type: "virtual-machine", Owner: "alice", Tags: tags}, nil
+	}
+
+	// A miss never waits for the database: the cache is filled for the next event
+	if meta := annotateEvent("web"); meta != nil {
+		t.Fatalf("miss answered synchronously: %+v", meta)
+	}
+	for i := 0; i < 3; i++ {
+		meta := annotateEvent("web")
+		if meta == nil || meta.Type != "virtual-machine" || meta.Owner != "alice" {
+			t.Fatalf("unexpected meta: %+v", meta)
+		}
+		if len(meta.Tags) != events.MaxEventTags {
+			t.Errorf("got %d tags, want %d", len(meta.Tags), events.MaxEventTags)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times, want 1 (cached)", calls)
+	}
+
+	annotateEvent("ghost")
+	if meta := annotateEvent("ghost"); meta != nil {
+		t.Errorf("unknown instance annotated: %+v", meta)
+	}
+	if calls != 2 {
+		t.Errorf("unknown instance not cached: %d loader calls", calls)
+	}
+}
+
+func TestAnnotateEventCachesFailures(t *testing.T) {
+	calls := 0
+	prevLoad, prevGo := loadAnnotations, goAnnotate
+	t.Cleanup(func() {
+		loadAnnotations, goAnnotate = prevLoad, prevGo
+		annotationCache = map[string]cachedMeta{}
+	})
+	goAnnotate = func(refresh func()) { refresh() }
+	loadAnnotations = func(string) (*db.InstanceAnnotations, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	}
+
+	for i := 0; i < 5; i++ {
+		if meta := annotateEvent("web"); meta != nil {
+			t.Fatalf("failed load annotated the event: %+v", meta)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times while the failure is cached, want 1", calls)
+	}
+}
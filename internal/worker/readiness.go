@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+	"aexon/internal/service"
+	"aexon/internal/types"
+)
+
+// ============================================================================
+// READINESS
+// ============================================================================
+
+// readinessChecks guarda o cancelamento da avaliação em curso por instância:
+// um novo start/restart substitui a avaliação anterior.
+var readinessChecks sync.Map
+
+// EvaluateReadiness avalia em segundo plano a readiness probe da instância,
+// se houver, sem prender quem chama. Enquanto a probe não passa, ready = false.
+// Sem lxcClient (nil), probes exec falham; tcp e http funcionam.
+func EvaluateReadiness(lxcClient *lxc.InstanceService, name string) {
+	readiness, err := db.GetInstanceReadiness(name)
+	if err != nil {
+		log.Printf("[Worker] Falha ao ler readiness probe de %s: %v", name, err)
+		return
+	}
+	if readiness.Probe == nil {
+		return
+	}
+	probe := *readiness.Probe
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if previous, loaded := readinessChecks.Swap(name, cancel); loaded {
+		previous.(context.CancelFunc)()
+	}
+	setReady(name, false, "waiting for readiness probe")
+
+	go func() {
+		defer func() {
+			readinessChecks.CompareAndDelete(name, cancel)
+			cancel()
+		}()
+
+		var exec service.ProbeExec
+		if lxcClient != nil {
+			exec = func(ctx context.Context, command []string) (int, error) {
+				result, err := lxcClient.ExecCommandContext(ctx, name, command)
+				if err != nil {
+					return 0, err
+				}
+				return result.ExitCode, nil
+			}
+		}
+		err := service.WaitReady(ctx, probe, func(ctx context.Context) error {
+			// O IP pode mudar entre tentativas (DHCP, reip)
+			ip := ""
+			if inst, err := db.GetInstance(name); err == nil {
+				ip = inst.IpAddress
+			}
+			return service.CheckReadiness(ctx, probe, ip, exec)
+		})
+		if ctx.Err() == context.Canceled {
+			return // Substituída por uma avaliação mais nova
+		}
+		if err != nil {
+			log.Printf("[Worker] %s não ficou pronta: %v", name, err)
+			setReady(name, false, err.Error())
+			return
+		}
+		setReady(name, true, "")
+	}()
+}
+
+// CancelReadiness interrompe a avaliação em curso da instância, se houver
+// (ex.: a probe foi removida).
+func CancelReadiness(name string) {
+	if previous, loaded := readinessChecks.LoadAndDelete(name); loaded {
+		previous.(context.CancelFunc)()
+	}
+}
+
+// markNotReady encerra a avaliação em curso quando a instância para.
+func markNotReady(name, reason string) {
+	CancelReadiness(name)
+	readiness, err := db.GetInstanceReadiness(name)
+	if err != nil || readiness.Probe == nil {
+		return
+	}
+	setReady(name, false, reason)
+}
+
+func setReady(name string, ready bool, message string) {
+	if err := db.SetInstanceReady(name, ready, message); err != nil {
+		log.Printf("[Worker] Falha ao registrar readiness de %s: %v", name, err)
+	}
+}
+
+// readinessAfterAction aplica o efeito de uma ação de estado na readiness
+func readinessAfterAction(lxcClient *lxc.InstanceService, name, action string) {
+	switch state, _ := types.DesiredStateForAction(action); state {
+	case types.StatusRunning:
+		EvaluateReadiness(lxcClient, name)
+	case types.StatusStopped:
+		markNotReady(name, "instance stopped")
+	}
+}
@@ -3,9 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
 	"aexon/internal/db"
@@ -20,8 +24,182 @@ var JobQueue chan string
 // Timeout aumentado para suportar criações (download de imagem)
 const JobTimeout = 5 * time.Minute
 
-func Init(numWorkers int, lxcClient *lxc.InstanceService) {
+// Limites dos jobs de exec: o timeout do comando nunca passa do timeout do job
+const (
+	ExecDefaultTimeout = 60 * time.Second
+	ExecMaxTimeout     = JobTimeout
+	execOutputLimit    = 64 << 10 // bytes guardados de stdout/stderr cada
+)
+
+// Prazo do desligamento limpo antes de excluir; o teto deixa folga dentro do
+// timeout do job para a parada forçada e a exclusão
+const (
+	DeleteDefaultGrace = 30 * time.Second
+	DeleteMaxGrace     = 3 * time.Minute
+)
+
+// JobTypeOptions descreve comportamentos opcionais de cada tipo de job
+type JobTypeOptions struct {
+	// ReportsProgress indica que o handler publica progresso (0-100) durante a execução
+	ReportsProgress bool
+	// Dedupe reaproveita um job pendente/em execução com o mesmo tipo e alvo
+	// em vez de criar outro (ex.: duplo clique em "stop")
+	Dedupe bool
+	// DedupeByPayload exige também payload idêntico (stop e start não se fundem)
+	DedupeByPayload bool
+	// NoRetry marca a primeira falha como definitiva (ações não idempotentes)
+	NoRetry bool
+	// Timeout substitui JobTimeout para jobs que levam mais tempo (downloads)
+	Timeout time.Duration
+}
+
+// JobTypeRegistry lista as opções por tipo. Tipos ausentes usam o valor zero.
+var JobTypeRegistry = map[types.JobType]JobTypeOptions{
+	types.JobTypeCreateInstance:   {ReportsProgress: true, NoRetry: true}, // o rollback apaga o registro feito pela API
+	types.JobTypeDeleteInstance:   {Dedupe: true},
+	types.JobTypeStateChange:      {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeUpdateLimits:     {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeUpdateIOLimits:   {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeReapplyCloudInit: {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeFetchConfig:      {Dedupe: true},
+	types.JobTypeWaitCloudInit:    {ReportsProgress: true, Dedupe: true},
+	types.JobTypeAddDevice:        {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeRemoveDevice:     {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeExec:             {NoRetry: true},
+	types.JobTypePullImage:        {ReportsProgress: true, Dedupe: true, DedupeByPayload: true, Timeout: 30 * time.Minute},
+	types.JobTypeCloneInstance:    {Timeout: 30 * time.Minute},
+	types.JobTypeRenameInstance:   {Dedupe: true, DedupeByPayload: true},
+	types.JobTypeAddPort:          {NoRetry: true}, // a falha libera a porta reservada
+	types.JobTypeMigrateInstance:  {ReportsProgress: true, NoRetry: true, Timeout: 2 * time.Hour},
+}
+
+// jobTimeout devolve o tempo máximo de execução de um tipo de job
+func jobTimeout(jobType types.JobType) time.Duration {
+	if t := JobTypeRegistry[jobType].Timeout; t > 0 {
+		return t
+	}
+	return JobTimeout
+}
+
+// progressReporter devolve um callback que persiste o progresso e publica job_update,
+// ou nil quando o tipo do job não reporta progresso.
+func progressReporter(job *db.Job) lxc.ProgressFunc {
+	if !JobTypeRegistry[job.Type].ReportsProgress {
+		return nil
+	}
+
+	var mu sync.Mutex
+	last := -1
+
+	return func(percent int, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// O LXD emite eventos com frequência; só propaga mudanças reais
+		if percent <= last {
+			return
+		}
+		last = percent
+
+		if err := db.UpdateJobProgress(job.ID, percent, message); err != nil {
+			log.Printf("[Worker] Erro ao salvar progresso do job %s: %v", job.ID, err)
+		}
+
+		snapshot := *job
+		snapshot.Progress = percent
+		snapshot.ProgressMessage = message
+		events.Publish(events.Event{
+			Type:      events.JobUpdate,
+			JobID:     job.ID,
+			Target:    job.Target,
+			Payload:   &snapshot,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+}
+
+// operationRecorder devolve um callback que grava no job o ID da operação do
+// LXD em curso, usado por POST /instances/:name/operations/:id/cancel.
+func operationRecorder(job *db.Job) lxc.OperationFunc {
+	return func(operationID string) {
+		job.LXDOperation = operationID
+		if err := db.SetJobOperation(job.ID, operationID); err != nil {
+			log.Printf("[Worker] Erro ao salvar operação LXD do job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Acesso aos jobs usado por processJob; variáveis para os testes trocarem o banco
+var (
+	markJobStarted   = db.MarkJobStarted
+	getJob           = db.GetJob
+	markJobFailed    = db.MarkJobFailed
+	markJobCompleted = db.MarkJobCompleted
+	setJobErrorCode  = db.SetJobErrorCode
+	executeJob       = executeLogic
+)
+
+// PanicError é a falha registrada quando o handler de um job entra em pânico
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// runJob executa o handler convertendo um pânico em *PanicError, para que o job
+// seja marcado como falho em vez de derrubar o worker ou ficar preso em running.
+func runJob(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return executeJob(ctx, job, lxcClient)
+}
+
+// secrets resolve as referências {{secret:nome}} do user_data; nil desativa
+var secrets *service.SecretStore
+
+// fleet, quando há vários remotes LXD, direciona cada job ao remote que
+// hospeda a instância alvo; nil usa sempre o cliente passado a Init.
+var fleet *lxc.Fleet
+
+// SetFleet ativa o roteamento multi-remote. Chamar antes de Init.
+func SetFleet(f *lxc.Fleet) {
+	fleet = f
+}
+
+// gitCredentials autentica os jobs fetch_config em repositórios privados
+var gitCredentials map[string]service.GitCredential
+
+// SetGitCredentials define as credenciais de AXION_GIT_CREDENTIALS. Chamar antes de Init.
+func SetGitCredentials(creds map[string]service.GitCredential) {
+	gitCredentials = creds
+}
+
+// clientFor escolhe o cliente LXD do job. Instâncias que nenhum remote
+// conhece (ex.: a criação) ficam com o primário.
+func clientFor(job *db.Job, primary *lxc.InstanceService) *lxc.InstanceService {
+	if fleet == nil {
+		return primary
+	}
+	client, remote, err := fleet.ForInstance(job.Target)
+	if err != nil {
+		return primary
+	}
+	if client != primary {
+		log.Printf("[Worker] Job %s direcionado ao remote %s", job.ID, remote)
+	}
+	return client
+}
+
+func Init(numWorkers int, lxcClient *lxc.InstanceService, secretStore *service.SecretStore) {
 	JobQueue = make(chan string, 100)
+	secrets = secretStore
+	events.SetEnricher(annotateEvent)
 
 	if err := db.RecoverStuckJobs(); err != nil {
 		log.Printf("[Worker System] Erro ao recuperar jobs: %v", err)
@@ -37,20 +215,66 @@ func DispatchJob(jobID string) {
 	JobQueue <- jobID
 }
 
+// ErrJobCanceled é o resultado de um job interrompido por CancelJob
+var ErrJobCanceled = errors.New("job cancelado")
+
+// runningJobs guarda o cancelamento do contexto de cada job em execução
+// neste processo (jobID -> context.CancelFunc).
+var runningJobs sync.Map
+
+// CancelJob sinaliza o cancelamento ao job em execução. O status CANCELED já
+// deve estar gravado no banco; retorna false se o job não está rodando aqui
+// (pendente, já concluído ou ainda entre tentativas).
+func CancelJob(jobID string) bool {
+	cancel, ok := runningJobs.Load(jobID)
+	if ok {
+		cancel.(context.CancelFunc)()
+	}
+	return ok
+}
+
+// jobCanceled retorna ErrJobCanceled se o job foi cancelado. Verificado entre
+// as etapas de operações longas; o timeout do job não conta como cancelamento.
+func jobCanceled(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ErrJobCanceled
+	}
+	return nil
+}
+
 func worker(id int, lxcClient *lxc.InstanceService) {
 	log.Printf("[Worker %d] Pronto", id)
 	for jobID := range JobQueue {
-		processJob(id, jobID, lxcClient)
+		processJobSafely(id, jobID, lxcClient)
 	}
 }
 
+// processJobSafely impede que um pânico fora do handler (ex.: no registro do
+// resultado) encerre a goroutine do worker.
+func processJobSafely(workerID int, jobID string, lxcClient *lxc.InstanceService) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Worker %d] Pânico ao processar job %s: %v\n%s", workerID, jobID, r, debug.Stack())
+		}
+	}()
+	processJob(workerID, jobID, lxcClient)
+}
+
 func processJob(workerID int, jobID string, lxcClient *lxc.InstanceService) {
-	if err := db.MarkJobStarted(jobID); err != nil {
+	if err := markJobStarted(jobID); err != nil {
+		if errors.Is(err, db.ErrJobNotPending) {
+			// Cancelado enquanto esperava na fila (ou no intervalo do retry)
+			log.Printf("[Worker %d] Job %s não está mais pendente, ignorando", workerID, jobID)
+			if job, err := getJob(jobID); err == nil && job.Status == types.JobCanceled {
+				undoCanceledJob(job, clientFor(job, lxcClient))
+			}
+			return
+		}
 		log.Printf("[Worker %d] Erro ao iniciar job %s: %v", workerID, jobID, err)
 		return
 	}
 
-	job, err := db.GetJob(jobID)
+	job, err := getJob(jobID)
 	if err != nil {
 		log.Printf("[Worker %d] Erro ao ler job %s: %v", workerID, jobID, err)
 		return
@@ -67,20 +291,69 @@ func processJob(workerID int, jobID string, lxcClient *lxc.InstanceService) {
 	log.Printf("[Worker %d] Executando Job %s (%s em %s) - Tentativa %d/%d",
 		workerID, job.ID, job.Type, job.Target, job.AttemptCount, types.MaxRetries)
 
-	ctx, cancel := context.WithTimeout(context.Background(), JobTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout(job.Type))
 	defer cancel()
+	runningJobs.Store(job.ID, cancel)
+	defer runningJobs.Delete(job.ID)
 
-	execErr := executeLogic(ctx, job, lxcClient)
+	execErr := runJob(ctx, job, clientFor(job, lxcClient))
+
+	// Cancelado pela API: o status já foi gravado, não há falha nem retry
+	canceled := func() {
+		log.Printf("[Worker %d] Job %s CANCELADO", workerID, job.ID)
+		undoCanceledJob(job, clientFor(job, lxcClient))
+		updatedJob, _ := getJob(jobID)
+		events.Publish(events.Event{
+			Type:      events.JobUpdate,
+			JobID:     updatedJob.ID,
+			Target:    updatedJob.Target,
+			Payload:   updatedJob,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+	if jobCanceled(ctx) != nil {
+		canceled()
+		return
+	}
 
 	if execErr != nil {
+		// O log do cloud-init é da instância, não do LXD: não é classificado
+		var cloudInitErr *lxc.CloudInitFailure
+		isCloudInit := errors.As(execErr, &cloudInitErr)
+		if !isCloudInit {
+			execErr = lxc.ClassifyCapacityError(execErr, "")
+		}
 		log.Printf("[Worker %d] Job %s FALHOU: %v", workerID, job.ID, execErr)
 
-		isFatal := job.AttemptCount >= types.MaxRetries
-		if err := db.MarkJobFailed(job.ID, execErr.Error(), isFatal); err != nil {
+		// Um pânico se repetiria na nova tentativa, falta de capacidade não
+		// some em segundos, a instância com cloud-init falho já existe e um
+		// config_repo inválido não se corrige sozinho: a falha já é definitiva
+		var panicErr *PanicError
+		var capErr *lxc.CapacityError
+		var gitErr *service.GitConfigError
+		errorCode := ""
+		if errors.As(execErr, &capErr) {
+			errorCode = capErr.Code
+		} else if isCloudInit {
+			errorCode = lxc.CloudInitFailedCode
+		} else if errors.As(execErr, &gitErr) {
+			errorCode = service.GitConfigErrorCode
+		}
+		isFatal := job.AttemptCount >= types.MaxRetries || JobTypeRegistry[job.Type].NoRetry || errors.As(execErr, &panicErr) || errorCode != ""
+		if err := markJobFailed(job.ID, execErr.Error(), isFatal); errors.Is(err, db.ErrJobNotRunning) {
+			// Cancelado entre o fim da execução e o registro da falha
+			canceled()
+			return
+		} else if err != nil {
 			log.Printf("[Worker %d] Erro ao atualizar status de falha: %v", workerID, err)
 		}
+		if errorCode != "" {
+			if err := setJobErrorCode(job.ID, errorCode); err != nil {
+				log.Printf("[Worker %d] Erro ao gravar código de erro do job %s: %v", workerID, job.ID, err)
+			}
+		}
 
-		updatedJob, _ := db.GetJob(jobID)
+		updatedJob, _ := getJob(jobID)
 		events.Publish(events.Event{
 			Type:      events.JobUpdate,
 			JobID:     updatedJob.ID,
@@ -103,11 +376,14 @@ func processJob(workerID int, jobID string, lxcClient *lxc.InstanceService) {
 
 	} else {
 		log.Printf("[Worker %d] Job %s CONCLUÍDO", workerID, job.ID)
-		if err := db.MarkJobCompleted(job.ID); err != nil {
+		if err := markJobCompleted(job.ID); errors.Is(err, db.ErrJobNotRunning) {
+			canceled()
+			return
+		} else if err != nil {
 			log.Printf("[Worker %d] Erro ao concluir job: %v", workerID, err)
 		}
 
-		updatedJob, _ := db.GetJob(jobID)
+		updatedJob, _ := getJob(jobID)
 		events.Publish(events.Event{
 			Type:      events.JobUpdate,
 			JobID:     updatedJob.ID,
@@ -118,10 +394,453 @@ func processJob(workerID int, jobID string, lxcClient *lxc.InstanceService) {
 	}
 }
 
+// resolveEnv resolve os secrets das variáveis de ambiente (environment.*) em
+// limits, no lugar, acumulando os valores usados em values para o mascaramento.
+func resolveEnv(ctx context.Context, limits map[string]string, values *[]string) error {
+	for key, value := range limits {
+		if !strings.HasPrefix(key, service.EnvConfigPrefix) {
+			continue
+		}
+		resolved, used, err := secrets.Resolve(ctx, value)
+		if err != nil {
+			return err
+		}
+		limits[key] = resolved
+		*values = append(*values, used...)
+	}
+	return nil
+}
+
+// maskSecretsInError esconde valores de secrets que o LXD possa ecoar num erro,
+// já que a mensagem vai para o job e para os logs.
+func maskSecretsInError(err error, values []string) error {
+	if err == nil || len(values) == 0 {
+		return err
+	}
+	return errors.New(service.MaskSecrets(err.Error(), values))
+}
+
+// ExecJobResult é o resultado guardado em job.result por um job de exec
+type ExecJobResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// runExec executa o comando com o timeout pedido e guarda a saída no job.
+// Exit code diferente de zero falha o job, mas a saída continua disponível.
+func runExec(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, command []string, timeout string) error {
+	limit := ExecDefaultTimeout
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("timeout inválido: %v", err)
+		}
+		limit = d
+	}
+	if limit > ExecMaxTimeout {
+		limit = ExecMaxTimeout
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	out, err := lxcClient.ExecCommandContext(execCtx, job.Target, command)
+	if err != nil {
+		return err
+	}
+
+	result := ExecJobResult{ExitCode: out.ExitCode}
+	result.Stdout, result.Truncated = truncateOutput(out.Stdout)
+	var truncated bool
+	result.Stderr, truncated = truncateOutput(out.Stderr)
+	result.Truncated = result.Truncated || truncated
+
+	if err := db.SetJobResult(job.ID, result); err != nil {
+		return fmt.Errorf("falha ao salvar resultado: %v", err)
+	}
+
+	if out.ExitCode != 0 {
+		return fmt.Errorf("comando terminou com código %d", out.ExitCode)
+	}
+	return nil
+}
+
+// DeleteJobResult é o resultado salvo nos jobs de exclusão
+type DeleteJobResult struct {
+	Shutdown     string `json:"shutdown"` // lxc.Shutdown*
+	GraceSeconds int    `json:"grace_seconds"`
+}
+
+// runDelete desliga a instância (limpo, salvo force), exclui do LXD, libera o
+// lease de IP e apaga o registro, e grava o caminho seguido no resultado do job
+func runDelete(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, force bool, grace string) error {
+	limit := DeleteDefaultGrace
+	if grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil {
+			return fmt.Errorf("prazo de desligamento inválido: %v", err)
+		}
+		limit = d
+	}
+	if limit > DeleteMaxGrace {
+		limit = DeleteMaxGrace
+	}
+	if force {
+		limit = 0
+	}
+
+	shutdown, err := lxcClient.DeleteInstanceGraceful(job.Target, limit)
+	if err != nil {
+		return err
+	}
+
+	// A instância já saiu do LXD: o banco é limpo mesmo que o job seja cancelado
+	ctx = context.WithoutCancel(ctx)
+	if err := db.GetService().ReleaseIP(ctx, job.Target); err != nil {
+		return fmt.Errorf("instância excluída, mas o IP não foi liberado: %w", err)
+	}
+	if err := db.NewInstanceRepository(db.GetService()).Delete(ctx, job.Target); err != nil && !errors.Is(err, db.ErrInstanceNotFound) {
+		return fmt.Errorf("instância excluída, mas o registro não foi removido: %w", err)
+	}
+
+	result := DeleteJobResult{Shutdown: shutdown, GraceSeconds: int(limit / time.Second)}
+	if err := db.SetJobResult(job.ID, result); err != nil {
+		log.Printf("[Worker] Falha ao salvar resultado do job %s: %v", job.ID, err)
+	}
+	return nil
+}
+
+// truncateOutput mantém o final da saída, onde costumam estar os erros
+func truncateOutput(s string) (string, bool) {
+	if len(s) <= execOutputLimit {
+		return s, false
+	}
+	return s[len(s)-execOutputLimit:], true
+}
+
+// cloudInitMargin reserva parte do timeout do job para concluir o registro
+const cloudInitMargin = 15 * time.Second
+
+// CloudInitJobResult é o resultado salvo no job de criação quando o
+// cloud-init falha
+type CloudInitJobResult struct {
+	CloudInitStatus string `json:"cloud_init_status"`
+	CloudInitLog    string `json:"cloud_init_log,omitempty"`
+}
+
+// waitCloudInit acompanha o provisionamento depois da criação. O resultado fica
+// em cloud_init_status ("running" se o tempo do job acabar antes do cloud-init,
+// sem falhar o job). Se o cloud-init terminar em erro, a instância é marcada
+// provisioning-failed, o final do log vai para o resultado do job e a falha
+// volta como *lxc.CloudInitFailure.
+func waitCloudInit(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, name string, secretValues []string) error {
+	waitCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithDeadline(ctx, deadline.Add(-cloudInitMargin))
+		defer cancel()
+	}
+
+	setStatus := func(status string) {
+		if err := db.SetInstanceCloudInitStatus(name, status); err != nil {
+			log.Printf("[Worker] Falha ao registrar cloud-init de %s: %v", name, err)
+		}
+	}
+
+	setStatus(lxc.CloudInitRunning)
+	status, err := lxcClient.WaitCloudInit(waitCtx, name, progressReporter(job))
+	if err != nil {
+		log.Printf("[Worker] Cloud-init de %s não concluiu: %v", name, err)
+	}
+	if status != lxc.CloudInitError {
+		if status != "" {
+			setStatus(status)
+		}
+		return nil
+	}
+
+	setStatus(lxc.CloudInitProvisioningFailed)
+	// O log pode repetir o user-data, com os segredos já resolvidos
+	tail, err := lxcClient.CloudInitLogTail(ctx, name, lxc.CloudInitLogLines)
+	if err != nil {
+		log.Printf("[Worker] Falha ao ler log do cloud-init de %s: %v", name, err)
+	}
+	tail = service.MaskSecrets(tail, secretValues)
+
+	result := CloudInitJobResult{CloudInitStatus: lxc.CloudInitProvisioningFailed, CloudInitLog: tail}
+	if err := db.SetJobResult(job.ID, result); err != nil {
+		log.Printf("[Worker] Falha ao salvar resultado do job %s: %v", job.ID, err)
+	}
+	return &lxc.CloudInitFailure{Instance: name, Log: tail}
+}
+
+// runFetchConfig busca o user_data do config_repo da instância, junta o
+// template e grava o resultado com o commit lido. Até lá o seed do cloud-init
+// responde 503 e a VM tenta de novo; falhas definitivas ficam no job.
+func runFetchConfig(ctx context.Context, name, templateID, secretOwner string) error {
+	inst, err := db.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if inst.ConfigSource == nil {
+		return fmt.Errorf("instância '%s' não tem config_repo", name)
+	}
+
+	userData, commit, err := service.FetchGitConfig(ctx, *inst.ConfigSource, gitCredentials)
+	if err != nil {
+		return err
+	}
+	if templateID != "" {
+		template, ok := service.GetTemplateByID(templateID)
+		if !ok {
+			return fmt.Errorf("template '%s' não encontrado", templateID)
+		}
+		if userData, err = service.MergeCloudConfig(template.CloudConfig, userData); err != nil {
+			return fmt.Errorf("user_data do repositório não combina com o template: %w", err)
+		}
+	}
+	if err := secrets.Check(ctx, userData, secretOwner); err != nil {
+		return err
+	}
+
+	source := *inst.ConfigSource
+	source.Commit = commit
+	return db.SetInstanceGitConfig(name, userData, source)
+}
+
+// portProtocol trata o protocolo vazio dos jobs de porta antigos como TCP
+func portProtocol(protocol string) string {
+	if protocol == "" {
+		return lxc.ProtocolTCP
+	}
+	return protocol
+}
+
+// releasePort devolve a porta do host ao intervalo. Falhar aqui só deixa a
+// porta reservada; o job segue com o próprio resultado.
+// undoCanceledJob desfaz o que um job cancelado deixou pela metade. Só
+// add_port precisa: a porta foi reservada pela API e ficaria presa; se o proxy
+// device chegou a ser criado, ele é removido antes de liberar a reserva.
+func undoCanceledJob(job *db.Job, lxcClient *lxc.InstanceService) {
+	if job.Type != types.JobTypeAddPort {
+		return
+	}
+	var payload struct {
+		HostPort int    `json:"host_port"`
+		Protocol string `json:"protocol"`
+	}
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return
+	}
+	protocol := portProtocol(payload.Protocol)
+	if lxcClient != nil {
+		if err := lxcClient.RemoveProxyDevice(job.Target, payload.HostPort, protocol); err != nil && !errors.Is(err, lxc.ErrPortNotFound) {
+			log.Printf("[Worker] Job %s cancelado: porta %d/%s mantida, falha ao remover o proxy: %v", job.ID, payload.HostPort, protocol, err)
+			return
+		}
+	}
+	releasePort(payload.HostPort, protocol)
+}
+
+func releasePort(hostPort int, protocol string) {
+	if err := db.ReleasePortForward(hostPort, protocol); err != nil {
+		log.Printf("[Worker] Falha ao liberar porta %d/%s: %v", hostPort, protocol, err)
+	}
+}
+
+// recordDesiredState guarda a intenção do usuário para a detecção de crash.
+// Falhar aqui não invalida a ação já executada.
+func recordDesiredState(name, action string) {
+	state, ok := types.DesiredStateForAction(action)
+	if !ok {
+		return
+	}
+	if err := db.SetInstanceDesiredState(name, state); err != nil {
+		log.Printf("[Worker] Falha ao registrar estado desejado de %s: %v", name, err)
+	}
+}
+
+// runCreateSaga executa a criação e, se ela falhar, desfaz o que ficou para
+// trás em ordem reversa: a instância parcial no LXD (ex.: o start pós-criação
+// falhou), o registro no banco e o lease de IP. O registro e o lease são
+// sempre do próprio create (a API e runClone garantem que o nome estava
+// livre); a instância do LXD só é removida se não existia antes. O resultado
+// do rollback vai para o erro do job.
+func runCreateSaga(lxcClient *lxc.InstanceService, name string, create func() error) error {
+	existed, checkErr := lxcClient.InstanceExists(name)
+
+	err := create()
+	if err == nil {
+		return nil
+	}
+
+	saga := service.NewSaga("create " + name)
+	saga.Completed("allocate_ip", func(ctx context.Context) error {
+		return db.GetService().ReleaseIP(ctx, name)
+	})
+	saga.Completed("persist_instance", func(ctx context.Context) error {
+		err := db.NewInstanceRepository(db.GetService()).Delete(ctx, name)
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			return nil
+		}
+		return err
+	})
+	// Sem saber o estado anterior não dá para afirmar que a instância é nossa
+	if checkErr == nil && !existed {
+		if exists, _ := lxcClient.InstanceExists(name); exists {
+			saga.Completed("create_instance", func(ctx context.Context) error {
+				return lxcClient.DeleteInstance(name)
+			})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), JobTimeout)
+	defer cancel()
+	return fmt.Errorf("%w; %s", err, saga.Rollback(ctx))
+}
+
+// runClone copia job.Target para target e registra o clone no banco com a
+// imagem, os limites e o tipo da origem. O clone ganha o próprio lease de IP.
+// Se algo falhar, runCreateSaga remove o clone do LXD, o registro e o lease
+// para o retry começar do zero.
+func runClone(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, target string, copySnapshots bool) error {
+	repo := db.NewInstanceRepository(db.GetService())
+	if exists, err := repo.Exists(ctx, target); err != nil {
+		return fmt.Errorf("falha ao verificar nome do clone: %w", err)
+	} else if exists {
+		return fmt.Errorf("instância '%s' já existe", target)
+	}
+
+	source, err := repo.Get(ctx, job.Target)
+	if err != nil {
+		return fmt.Errorf("falha ao ler instância de origem '%s': %w", job.Target, err)
+	}
+
+	return runCreateSaga(lxcClient, target, func() error {
+		ip, err := db.GetService().AllocateIP(ctx, target)
+		if err != nil {
+			return fmt.Errorf("falha ao reservar IP do clone '%s': %w", target, err)
+		}
+		if err := lxcClient.CloneInstance(job.Target, target, copySnapshots, ip, operationRecorder(job)); err != nil {
+			return err
+		}
+		if err := jobCanceled(ctx); err != nil {
+			return err
+		}
+		clone := types.Instance{
+			Name:        target,
+			Image:       source.Image,
+			Description: source.Description,
+			Limits:      source.Limits,
+			UserData:    source.UserData,
+			Type:        source.Type,
+			StoragePool: source.StoragePool,
+			// Mesmo disco, mesmo user_data: o clone vem do mesmo commit
+			ConfigSource: source.ConfigSource,
+		}
+		if job.RequestedBy != nil {
+			clone.Owner = *job.RequestedBy
+		}
+		if err := repo.Create(ctx, &clone); err != nil {
+			return fmt.Errorf("falha ao registrar clone '%s': %w", target, err)
+		}
+		return nil
+	})
+}
+
+// runRename renomeia job.Target no LXD e depois no banco (linha, lease de IP
+// e jobs pendentes). Se o banco falhar, o nome antigo volta no LXD.
+func runRename(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, newName string) error {
+	if err := lxcClient.RenameInstance(job.Target, newName); err != nil {
+		return err
+	}
+
+	repo := db.NewInstanceRepository(db.GetService())
+	if err := repo.Rename(ctx, job.Target, newName); err != nil {
+		saga := service.NewSaga("rename " + job.Target)
+		saga.Completed("rename_instance", func(ctx context.Context) error {
+			return lxcClient.RenameInstance(newName, job.Target)
+		})
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), JobTimeout)
+		defer cancel()
+		return fmt.Errorf("falha ao renomear no banco: %w; %s", err, saga.Rollback(rollbackCtx))
+	}
+	return nil
+}
+
+// runMigrate move job.Target para o remote targetRemote. A origem só sai
+// depois que a instância está de pé no destino. Com networkID o lease de IP
+// passa para essa rede (o destino está em outro segmento) e a NIC é fixada no
+// novo endereço; se a NIC não aceitar, o lease antigo volta.
+func runMigrate(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService, targetRemote string, live bool, networkID string) error {
+	if fleet == nil {
+		return errors.New("migração exige mais de um remote LXD configurado")
+	}
+	target, err := fleet.Client(targetRemote)
+	if err != nil {
+		return err
+	}
+	if target == lxcClient {
+		return fmt.Errorf("'%s' já está no remote %s", job.Target, targetRemote)
+	}
+
+	progress := progressReporter(job)
+	mode, err := lxcClient.MigrateInstance(target, job.Target, live, progress)
+	if err != nil {
+		return err
+	}
+	fleet.Relocate(job.Target, targetRemote)
+	log.Printf("[Worker] '%s' migrada para %s (%s)", job.Target, targetRemote, mode)
+
+	if networkID != "" {
+		if err := rehomeIP(ctx, target, job.Target, networkID); err != nil {
+			return fmt.Errorf("instância migrada para %s, mas o IP não foi trocado: %w", targetRemote, err)
+		}
+	}
+	if progress != nil {
+		progress(100, "migrated ("+mode+")")
+	}
+	return nil
+}
+
+// rehomeIP troca o lease de name por um endereço de networkID e fixa eth0 nele.
+func rehomeIP(ctx context.Context, client *lxc.InstanceService, name, networkID string) error {
+	change, err := db.GetService().ReassignIP(ctx, name, networkID, "")
+	if err != nil {
+		return err
+	}
+	if err := client.SetNICAddress(name, "eth0", change.IP); err != nil {
+		rbCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		var rbErr error
+		if change.OldIP != "" {
+			rbErr = db.GetService().RestoreIP(rbCtx, name, change.OldIP)
+		} else {
+			rbErr = db.GetService().ReleaseIP(rbCtx, name)
+		}
+		if rbErr != nil {
+			return fmt.Errorf("%w; falha ao restaurar o lease: %v", err, rbErr)
+		}
+		return err
+	}
+	log.Printf("[Worker] '%s' passou de %s para %s", name, change.OldIP, change.IP)
+	return nil
+}
+
 func executeLogic(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService) error {
 	errChan := make(chan error, 1)
 
 	go func() {
+		// O recover de runJob só cobre a goroutine que chama: um pânico aqui
+		// derrubaria o processo inteiro
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+
 		var err error
 		switch job.Type {
 		case types.JobTypeStateChange:
@@ -132,30 +851,60 @@ func executeLogic(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceServi
 				err = fmt.Errorf("payload inválido: %v", e)
 			} else {
 				err = lxcClient.UpdateInstanceState(job.Target, payload.Action)
+				if err == nil {
+					recordDesiredState(job.Target, payload.Action)
+					readinessAfterAction(lxcClient, job.Target, payload.Action)
+				}
 			}
 
 		case types.JobTypeUpdateLimits:
 			var payload struct {
-				Memory string `json:"memory"`
-				CPU    string `json:"cpu"`
+				Memory string            `json:"memory"`
+				CPU    string            `json:"cpu"`
+				Config map[string]string `json:"config"` // raw_config já validado pela API
 			}
 			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
 				err = fmt.Errorf("payload inválido: %v", e)
 			} else {
-				err = lxcClient.UpdateInstanceLimits(job.Target, payload.Memory, payload.CPU)
+				if payload.Memory != "" || payload.CPU != "" {
+					err = lxcClient.UpdateInstanceLimits(job.Target, payload.Memory, payload.CPU)
+				}
+				if err == nil && len(payload.Config) > 0 {
+					if err = lxc.ValidateRawConfig(payload.Config); err == nil {
+						err = lxcClient.UpdateInstanceConfig(job.Target, payload.Config)
+					}
+				}
+			}
+
+		case types.JobTypeUpdateIOLimits:
+			var payload struct {
+				Read  string `json:"read"`
+				Write string `json:"write"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = lxcClient.UpdateRootDiskLimits(job.Target, payload.Read, payload.Write)
 			}
 
 		case types.JobTypeCreateInstance:
 			var payload struct {
-				Name     string            `json:"name"`
-				Image    string            `json:"image"`
-				Limits   map[string]string `json:"limits"`
-				UserData string            `json:"user_data"` // Adicionado suporte a user_data
-				Type     string            `json:"type"`      // Instance type: "container" or "virtual-machine"
-				ISOImage string            `json:"iso_image"` // Nome do arquivo ISO para boot customizado (opcional)
+				Name        string            `json:"name"`
+				Image       string            `json:"image"`
+				Limits      map[string]string `json:"limits"`
+				UserData    string            `json:"user_data"`    // Adicionado suporte a user_data
+				Type        string            `json:"type"`         // Instance type: "container" or "virtual-machine"
+				ISOImage    string            `json:"iso_image"`    // Nome do arquivo ISO para boot customizado (opcional)
+				StoragePool string            `json:"storage_pool"` // Vazio usa o pool padrão
+				RootSize    string            `json:"root_size"`    // Disco vazio da instalação por ISO (ex: "20GB")
 			}
+			var secretValues []string
 			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
 				err = fmt.Errorf("payload inválido: %v", e)
+			} else if payload.UserData, secretValues, err = secrets.Resolve(ctx, payload.UserData); err != nil {
+				err = fmt.Errorf("falha ao resolver secrets: %w", err)
+			} else if err = resolveEnv(ctx, payload.Limits, &secretValues); err != nil {
+				err = fmt.Errorf("falha ao resolver secrets do env: %w", err)
 			} else {
 				// If Type is empty, default to "container"
 				instanceType := payload.Type
@@ -163,23 +912,88 @@ func executeLogic(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceServi
 					instanceType = "container"
 				}
 
-				// If ISOImage is provided, create VM with ISO boot
-				if payload.ISOImage != "" {
-					// Get the full ISO path using the storage service
-					storageService, errStorage := service.NewStorageService()
-					if errStorage != nil {
-						err = fmt.Errorf("failed to initialize storage service: %v", errStorage)
-					} else {
+				err = runCreateSaga(lxcClient, payload.Name, func() error {
+					if err := jobCanceled(ctx); err != nil {
+						return err
+					}
+					var err error
+					// If ISOImage is provided, create VM with ISO boot
+					if payload.ISOImage != "" {
+						// Get the full ISO path using the storage service
+						storageService, errStorage := service.NewStorageService()
+						if errStorage != nil {
+							return fmt.Errorf("failed to initialize storage service: %v", errStorage)
+						}
 						isoPath := storageService.GetISOPath(payload.ISOImage)
-						err = lxcClient.CreateInstanceWithISO(payload.Name, payload.Image, instanceType, payload.Limits, payload.UserData, isoPath)
+						err = lxcClient.CreateInstanceWithISO(payload.Name, payload.Image, instanceType, payload.Limits, payload.UserData, isoPath, payload.StoragePool, payload.RootSize, operationRecorder(job))
+					} else {
+						err = lxcClient.CreateInstanceWithProgress(payload.Name, payload.Image, instanceType, payload.Limits, payload.UserData, payload.StoragePool, progressReporter(job), operationRecorder(job))
 					}
-				} else {
-					err = lxcClient.CreateInstance(payload.Name, payload.Image, instanceType, payload.Limits, payload.UserData)
-				}
+					// Cancelado durante a criação: o saga remove a instância recém-criada
+					if err == nil {
+						err = jobCanceled(ctx)
+					}
+					return err
+				})
+			}
+			// O pool é conhecido aqui: a mensagem de pool cheio pode nomeá-lo
+			err = lxc.ClassifyCapacityError(maskSecretsInError(err, secretValues), payload.StoragePool)
+			if err == nil {
+				err = jobCanceled(ctx)
+			}
+			// Instalação por ISO não tem sistema nem cloud-init para esperar
+			if err == nil && payload.ISOImage == "" {
+				err = waitCloudInit(ctx, job, lxcClient, payload.Name, secretValues)
+			}
+			if err == nil {
+				EvaluateReadiness(lxcClient, payload.Name)
 			}
 
 		case types.JobTypeDeleteInstance:
-			err = lxcClient.DeleteInstance(job.Target)
+			var payload struct {
+				Force bool   `json:"force"`
+				Grace string `json:"grace"`
+			}
+			if job.Payload != "" {
+				if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+					err = fmt.Errorf("payload inválido: %v", e)
+					break
+				}
+			}
+			err = runDelete(ctx, job, lxcClient, payload.Force, payload.Grace)
+
+		case types.JobTypeCloneInstance:
+			var payload struct {
+				TargetName    string `json:"target_name"`
+				CopySnapshots bool   `json:"copy_snapshots"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = runClone(ctx, job, lxcClient, payload.TargetName, payload.CopySnapshots)
+			}
+
+		case types.JobTypeRenameInstance:
+			var payload struct {
+				NewName string `json:"new_name"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = runRename(ctx, job, lxcClient, payload.NewName)
+			}
+
+		case types.JobTypeMigrateInstance:
+			var payload struct {
+				TargetRemote string `json:"target_remote"`
+				Live         bool   `json:"live"`
+				NetworkID    string `json:"network_id"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = runMigrate(ctx, job, lxcClient, payload.TargetRemote, payload.Live, payload.NetworkID)
+			}
 
 		// --- Snapshot Operations ---
 		case types.JobTypeCreateSnapshot:
@@ -222,17 +1036,114 @@ func executeLogic(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceServi
 			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
 				err = fmt.Errorf("payload inválido: %v", e)
 			} else {
-				err = lxcClient.AddProxyDevice(job.Target, payload.HostPort, payload.ContainerPort, payload.Protocol)
+				protocol := portProtocol(payload.Protocol)
+				err = lxcClient.AddProxyDevice(job.Target, payload.HostPort, payload.ContainerPort, protocol)
+				if err != nil {
+					releasePort(payload.HostPort, protocol)
+				}
 			}
 
 		case types.JobTypeRemovePort:
 			var payload struct {
-				HostPort int `json:"host_port"`
+				HostPort int    `json:"host_port"`
+				Protocol string `json:"protocol"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				protocol := portProtocol(payload.Protocol)
+				err = lxcClient.RemoveProxyDevice(job.Target, payload.HostPort, protocol)
+				// Sem o device a reserva não tem mais o que proteger
+				if err == nil || errors.Is(err, lxc.ErrPortNotFound) {
+					releasePort(payload.HostPort, protocol)
+				}
+			}
+
+		// --- Cloud-Init ---
+		case types.JobTypeReapplyCloudInit:
+			var payload struct {
+				UserData string `json:"user_data"`
+			}
+			var secretValues []string
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else if payload.UserData, secretValues, err = secrets.Resolve(ctx, payload.UserData); err != nil {
+				err = fmt.Errorf("falha ao resolver secrets: %w", err)
+			} else {
+				err = maskSecretsInError(lxcClient.ReapplyCloudInit(job.Target, payload.UserData), secretValues)
+			}
+
+		case types.JobTypeWaitCloudInit:
+			// O user-data veio do seed com os secrets resolvidos; o log pode
+			// repetir qualquer um deles
+			var secretValues []string
+			if secretValues, err = secrets.Values(ctx); err != nil {
+				err = fmt.Errorf("falha ao ler secrets: %w", err)
+			} else if err = waitCloudInit(ctx, job, lxcClient, job.Target, secretValues); err == nil {
+				EvaluateReadiness(lxcClient, job.Target)
+			}
+
+		case types.JobTypeFetchConfig:
+			var payload struct {
+				TemplateID  string `json:"template_id"`
+				SecretOwner string `json:"secret_owner"` // vazio (admin) pode usar qualquer secret
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = runFetchConfig(ctx, job.Target, payload.TemplateID, payload.SecretOwner)
+			}
+
+		// --- Devices ---
+		case types.JobTypeAddDevice:
+			var payload struct {
+				Name   string            `json:"name"`
+				Type   string            `json:"type"`
+				Config map[string]string `json:"config"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = lxcClient.AddDevice(job.Target, payload.Name, payload.Type, payload.Config)
+			}
+
+		case types.JobTypeRemoveDevice:
+			var payload struct {
+				Name string `json:"name"`
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				err = lxcClient.RemoveDevice(job.Target, payload.Name)
+			}
+
+		// --- Exec ---
+		case types.JobTypeExec:
+			var payload struct {
+				Command []string `json:"command"`
+				Timeout string   `json:"timeout"`
 			}
 			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
 				err = fmt.Errorf("payload inválido: %v", e)
 			} else {
-				err = lxcClient.RemoveProxyDevice(job.Target, payload.HostPort)
+				err = runExec(ctx, job, lxcClient, payload.Command, payload.Timeout)
+			}
+
+		case types.JobTypePullImage:
+			var payload struct {
+				Image string `json:"image"`
+				Type  string `json:"type"` // "container" (padrão) ou "virtual-machine"
+			}
+			if e := json.Unmarshal([]byte(job.Payload), &payload); e != nil {
+				err = fmt.Errorf("payload inválido: %v", e)
+			} else {
+				var fingerprint string
+				fingerprint, err = lxcClient.PullImage(ctx, payload.Image, payload.Type, progressReporter(job))
+				if err == nil {
+					if e := db.SetJobResult(job.ID, map[string]string{"fingerprint": fingerprint}); e != nil {
+						log.Printf("[Worker] Falha ao salvar resultado do job %s: %v", job.ID, e)
+					}
+				}
 			}
 
 		default:
@@ -245,6 +1156,9 @@ func executeLogic(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceServi
 	case err := <-errChan:
 		return err
 	case <-ctx.Done():
-		return fmt.Errorf("timeout de execução (%s)", JobTimeout)
+		if err := jobCanceled(ctx); err != nil {
+			return err
+		}
+		return fmt.Errorf("timeout de execução (%s)", jobTimeout(job.Type))
 	}
 }
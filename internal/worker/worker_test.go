@@ -0,0 +1,263 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aexon/internal/db"
+	"aexon/internal/provider/lxc"
+	"aexon/internal/types"
+)
+
+// fakeJobs guarda os jobs em memória no lugar do banco
+type fakeJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*db.Job
+	done chan string
+}
+
+func installFakeJobs(t *testing.T, jobs ...*db.Job) *fakeJobs {
+	t.Helper()
+	f := &fakeJobs{jobs: make(map[string]*db.Job), done: make(chan string, len(jobs))}
+	for _, j := range jobs {
+		f.jobs[j.ID] = j
+	}
+
+	prevStarted, prevGet, prevFailed, prevCompleted, prevErrorCode, prevExecute := markJobStarted, getJob, markJobFailed, markJobCompleted, setJobErrorCode, executeJob
+	t.Cleanup(func() {
+		markJobStarted, getJob, markJobFailed, markJobCompleted, setJobErrorCode, executeJob = prevStarted, prevGet, prevFailed, prevCompleted, prevErrorCode, prevExecute
+	})
+
+	markJobStarted = func(id string) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		j := f.jobs[id]
+		if j.Status != "" && j.Status != types.JobPending {
+			return db.ErrJobNotPending
+		}
+		j.Status = types.JobInProgress
+		j.AttemptCount++
+		return nil
+	}
+	getJob = func(id string) (*db.Job, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		snapshot := *f.jobs[id]
+		return &snapshot, nil
+	}
+	markJobFailed = func(id, msg string, isFatal bool) error {
+		err := f.finish(id, func(j *db.Job) {
+			j.Status = types.JobFailed
+			if !isFatal {
+				j.Status = types.JobPending
+			}
+			j.Error = &msg
+		})
+		f.done <- id
+		return err
+	}
+	setJobErrorCode = func(id, code string) error {
+		return f.update(id, func(j *db.Job) { j.ErrorCode = code })
+	}
+	markJobCompleted = func(id string) error {
+		err := f.finish(id, func(j *db.Job) { j.Status = types.JobCompleted })
+		f.done <- id
+		return err
+	}
+	return f
+}
+
+func (f *fakeJobs) update(id string, fn func(*db.Job)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f.jobs[id])
+	return nil
+}
+
+// finish aplica fn só a jobs IN_PROGRESS, como o WHERE de MarkCompleted/MarkFailed
+func (f *fakeJobs) finish(id string, fn func(*db.Job)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.jobs[id].Status != types.JobInProgress {
+		return db.ErrJobNotRunning
+	}
+	fn(f.jobs[id])
+	return nil
+}
+
+func TestWorkerSurvivesPanickingJob(t *testing.T) {
+	f := installFakeJobs(t,
+		&db.Job{ID: "boom", Type: types.JobTypeStateChange, Target: "c1", Payload: `{"action":"start"}`},
+		&db.Job{ID: "next", Type: types.JobTypeStateChange, Target: "c2"},
+	)
+	// "boom" passa pelo handler real de executeLogic, que roda em outra
+	// goroutine e entra em pânico ao usar o cliente LXD nil
+	executeJob = func(ctx context.Context, job *db.Job, lxcClient *lxc.InstanceService) error {
+		if job.ID == "boom" {
+			return executeLogic(ctx, job, lxcClient)
+		}
+		return nil
+	}
+
+	prevQueue := JobQueue
+	JobQueue = make(chan string, 2)
+	t.Cleanup(func() { JobQueue = prevQueue })
+
+	// Um único worker: "next" só roda se ele sobreviver a "boom"
+	go worker(0, nil)
+	defer close(JobQueue)
+	DispatchJob("boom")
+	DispatchJob("next")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-f.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("worker stopped processing jobs")
+		}
+	}
+
+	boom, _ := getJob("boom")
+	if boom.Status != types.JobFailed {
+		t.Fatalf("panicking job status = %s, want %s", boom.Status, types.JobFailed)
+	}
+	if boom.Error == nil || !strings.Contains(*boom.Error, "nil pointer dereference") || !strings.Contains(*boom.Error, "goroutine") {
+		t.Errorf("error should carry the panic message and stack, got %v", boom.Error)
+	}
+
+	next, _ := getJob("next")
+	if next.Status != types.JobCompleted {
+		t.Errorf("following job status = %s, want %s", next.Status, types.JobCompleted)
+	}
+}
+
+func TestCapacityFailureIsFinalAndClassified(t *testing.T) {
+	f := installFakeJobs(t, &db.Job{ID: "full", Type: types.JobTypeCreateSnapshot, Target: "c1"})
+	executeJob = func(context.Context, *db.Job, *lxc.InstanceService) error {
+		return errors.New("Create instance snapshot: write: no space left on device")
+	}
+	var fatal bool
+	failed := markJobFailed
+	markJobFailed = func(id, msg string, isFatal bool) error {
+		fatal = isFatal
+		return failed(id, msg, isFatal)
+	}
+
+	processJob(0, "full", nil)
+	<-f.done
+
+	if !fatal {
+		t.Error("capacity failure should not be retried")
+	}
+	job, _ := getJob("full")
+	if job.ErrorCode != lxc.CapacityStoragePoolFull {
+		t.Errorf("error_code = %q, want %q", job.ErrorCode, lxc.CapacityStoragePoolFull)
+	}
+	if job.Error == nil || !strings.Contains(*job.Error, "storage pool is full") {
+		t.Errorf("error should carry the friendly message, got %v", job.Error)
+	}
+}
+
+func TestCloudInitFailureIsFinalAndNotClassifiedAsCapacity(t *testing.T) {
+	f := installFakeJobs(t, &db.Job{ID: "ci", Type: types.JobTypeCreateInstance, Target: "web"})
+	executeJob = func(context.Context, *db.Job, *lxc.InstanceService) error {
+		// O log da instância cita falta de espaço, mas o LXD não recusou nada
+		return &lxc.CloudInitFailure{Instance: "web", Log: "apt-get: write: no space left on device"}
+	}
+	var fatal bool
+	failed := markJobFailed
+	markJobFailed = func(id, msg string, isFatal bool) error {
+		fatal = isFatal
+		return failed(id, msg, isFatal)
+	}
+
+	processJob(0, "ci", nil)
+	<-f.done
+
+	if !fatal {
+		t.Error("cloud-init failure should not be retried")
+	}
+	job, _ := getJob("ci")
+	if job.ErrorCode != lxc.CloudInitFailedCode {
+		t.Errorf("error_code = %q, want %q", job.ErrorCode, lxc.CloudInitFailedCode)
+	}
+	if job.Error == nil || !strings.Contains(*job.Error, "no space left on device") {
+		t.Errorf("error should carry the cloud-init log, got %v", job.Error)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	f := installFakeJobs(t, &db.Job{ID: "long", Type: types.JobTypeCreateInstance, Target: "web"})
+	started := make(chan struct{})
+	executeJob = func(ctx context.Context, job *db.Job, _ *lxc.InstanceService) error {
+		close(started)
+		<-ctx.Done()
+		return jobCanceled(ctx)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		processJob(0, "long", nil)
+		close(finished)
+	}()
+	<-started
+
+	// A API grava o status antes de sinalizar o worker
+	f.update("long", func(j *db.Job) { j.Status = types.JobCanceled })
+	if !CancelJob("long") {
+		t.Fatal("running job not found")
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not observe the cancellation")
+	}
+	select {
+	case <-f.done:
+		t.Error("canceled job should not be marked failed or completed")
+	default:
+	}
+	if job, _ := getJob("long"); job.Status != types.JobCanceled {
+		t.Errorf("status = %s, want %s", job.Status, types.JobCanceled)
+	}
+	if CancelJob("long") {
+		t.Error("finished job still registered as running")
+	}
+}
+
+func TestCanceledPendingJobIsSkipped(t *testing.T) {
+	installFakeJobs(t, &db.Job{ID: "queued", Type: types.JobTypeStateChange, Target: "c1", Status: types.JobCanceled})
+	executeJob = func(context.Context, *db.Job, *lxc.InstanceService) error {
+		t.Error("canceled job should not run")
+		return nil
+	}
+
+	processJob(0, "queued", nil)
+
+	if job, _ := getJob("queued"); job.Status != types.JobCanceled {
+		t.Errorf("status = %s, want %s", job.Status, types.JobCanceled)
+	}
+}
+
+func TestJobCanceledAfterExecutionStaysCanceled(t *testing.T) {
+	for _, execErr := range []error{nil, errors.New("LOCKED: container ocupado")} {
+		f := installFakeJobs(t, &db.Job{ID: "late", Type: types.JobTypeStateChange, Target: "c1"})
+		executeJob = func(context.Context, *db.Job, *lxc.InstanceService) error {
+			// A API cancela depois que o trabalho terminou, antes do registro
+			f.update("late", func(j *db.Job) { j.Status = types.JobCanceled })
+			return execErr
+		}
+
+		processJob(0, "late", nil)
+		<-f.done
+
+		if job, _ := getJob("late"); job.Status != types.JobCanceled {
+			t.Errorf("execErr %v: status = %s, want %s", execErr, job.Status, types.JobCanceled)
+		}
+	}
+}
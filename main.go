@@ -2,16 +2,23 @@ package main
 
 import (
 	"aexon/internal/auth"
+	"aexon/internal/config"
+	"bufio"
 	"context"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,14 +28,25 @@ import (
 
 	"aexon/internal/api"
 	"aexon/internal/db"
+	"aexon/internal/events"
+	"aexon/internal/monitor"
+	"aexon/internal/pagination"
 	"aexon/internal/provider/axhv"
 	"aexon/internal/provider/axhv/pb"
+	"aexon/internal/provider/lxc"
 	"aexon/internal/scheduler"
 	"aexon/internal/service"
 	"aexon/internal/types"
 	"aexon/internal/utils"
+	"aexon/internal/worker"
 
+	lxdapi "github.com/canonical/lxd/shared/api"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // ============================================================================
@@ -37,39 +55,48 @@ import (
 
 type ErrorCode int
 
+// Error codes are part of the API: each one is pinned so adding a code never
+// renumbers the others. New codes take the next free value of their range.
 const (
 	// Client Errors (1000-1999)
-	ErrCodeInvalidJSON ErrorCode = iota + 1000
-	ErrCodeMissingField
-	ErrCodeInvalidPath
-	ErrCodeInvalidFileType
-	ErrCodeFileTooLarge
-	ErrCodeInstanceNotFound
-	ErrCodeSnapshotNotFound
-	ErrCodeNetworkNotFound
-	ErrCodeISONotFound
-	ErrCodeTemplateNotFound
-	ErrCodeInvalidQuota
-	ErrCodeInsufficientResources
+	ErrCodeInvalidJSON           ErrorCode = 1000
+	ErrCodeMissingField          ErrorCode = 1001
+	ErrCodeInvalidPath           ErrorCode = 1002
+	ErrCodeInvalidFileType       ErrorCode = 1003
+	ErrCodeFileTooLarge          ErrorCode = 1004
+	ErrCodeInstanceNotFound      ErrorCode = 1005
+	ErrCodeSnapshotNotFound      ErrorCode = 1006
+	ErrCodeNetworkNotFound       ErrorCode = 1007
+	ErrCodeISONotFound           ErrorCode = 1008
+	ErrCodeTemplateNotFound      ErrorCode = 1009
+	ErrCodeInvalidQuota          ErrorCode = 1010
+	ErrCodeInsufficientResources ErrorCode = 1011
+	ErrCodeGroupNotFound         ErrorCode = 1012
+	ErrCodeImageNotAllowed       ErrorCode = 1013
+	ErrCodeInvalidNetworkConfig  ErrorCode = 1014
+	ErrCodeConflict              ErrorCode = 1015 // name taken, operation already running, state does not allow it
+	ErrCodeNotFound              ErrorCode = 1016 // resources without a dedicated code
+	ErrCodeStackNotFound         ErrorCode = 1017
 
 	// Server Errors (2000-2999)
-	ErrCodeDatabaseFailure ErrorCode = iota + 2000
-	ErrCodeLXDConnectionFailed
-	ErrCodeJobCreationFailed
-	ErrCodeInstanceCreationFailed
-	ErrCodeSnapshotFailed
-	ErrCodeFileOperationFailed
-	ErrCodeNetworkOperationFailed
-	ErrCodeStorageOperationFailed
-	ErrCodeMetricsFetchFailed
-	ErrCodeBackupFailed
-	ErrCodeWorkerDispatchFailed
-	ErrCodeUnknownError
+	ErrCodeDatabaseFailure        ErrorCode = 2012
+	ErrCodeLXDConnectionFailed    ErrorCode = 2013
+	ErrCodeJobCreationFailed      ErrorCode = 2014
+	ErrCodeInstanceCreationFailed ErrorCode = 2015
+	ErrCodeSnapshotFailed         ErrorCode = 2016
+	ErrCodeFileOperationFailed    ErrorCode = 2017
+	ErrCodeNetworkOperationFailed ErrorCode = 2018
+	ErrCodeStorageOperationFailed ErrorCode = 2019
+	ErrCodeMetricsFetchFailed     ErrorCode = 2020
+	ErrCodeBackupFailed           ErrorCode = 2021
+	ErrCodeWorkerDispatchFailed   ErrorCode = 2022
+	ErrCodeUnknownError           ErrorCode = 2023
+	ErrCodeHypervisorFailed       ErrorCode = 2024 // AxHV unreachable or the RPC failed
 
 	// Infrastructure Errors (3000-3999)
-	ErrCodeInitializationFailed ErrorCode = iota + 3000
-	ErrCodeShutdownFailed
-	ErrCodeConfigurationInvalid
+	ErrCodeInitializationFailed ErrorCode = 3024
+	ErrCodeShutdownFailed       ErrorCode = 3025
+	ErrCodeConfigurationInvalid ErrorCode = 3026
 )
 
 type AppError struct {
@@ -136,6 +163,20 @@ func ErrJobCreation(err error) *AppError {
 	return NewError(ErrCodeJobCreationFailed, "job creation failed", err, 500, true)
 }
 
+func ErrGroupNotFound(id string) *AppError {
+	return NewError(ErrCodeGroupNotFound, "group not found", nil, 404, false).
+		WithContext("group_id", id)
+}
+
+func ErrStackNotFound(id string) *AppError {
+	return NewError(ErrCodeStackNotFound, "stack not found", nil, 404, false).
+		WithContext("stack_id", id)
+}
+
+func ErrLXDUnavailable() *AppError {
+	return NewError(ErrCodeLXDConnectionFailed, "LXD provider not available on this node", nil, 503, false)
+}
+
 // ============================================================================
 // REQUEST/RESPONSE TYPES
 // ============================================================================
@@ -145,25 +186,81 @@ type InstanceActionRequest struct {
 }
 
 type InstanceLimitsRequest struct {
-	VCPU      int `json:"vcpu"`
-	MemoryMiB int `json:"memory_mib"`
+	VCPU      int               `json:"vcpu"`
+	MemoryMiB int               `json:"memory_mib"`
+	RawConfig map[string]string `json:"raw_config"` // Extra LXD config keys, see lxc.RawConfigKeys
+	// Weighted CPU shares instead of core pinning; "" removes the allowance
+	CPUAllowance *string `json:"cpu_allowance"` // "50%" or "25ms/100ms"
+	CPUPriority  *int    `json:"cpu_priority"`  // 0 (lowest) to 10
+}
+
+// IOLimitsRequest sets per-direction disk limits; 0/"" means unlimited.
+// LXD takes either IOPS or bytes/s per direction, not both.
+type IOLimitsRequest struct {
+	ReadIOPS  int    `json:"read_iops"`
+	WriteIOPS int    `json:"write_iops"`
+	ReadBPS   string `json:"read_bps"`
+	WriteBPS  string `json:"write_bps"`
 }
 
 type CreateInstanceRequest struct {
-	Name       string            `json:"name" binding:"required"`
-	Image      string            `json:"image" binding:"required"`
-	Limits     map[string]string `json:"limits"`
-	UserData   string            `json:"user_data"`
-	Type       string            `json:"type"`
-	TemplateID string            `json:"template_id"`
-	ISOImage   string            `json:"iso_image"`
-	NetworkID  string            `json:"network_id"`
-	Password   string            `json:"password"` // Root password for VM
+	Name        string            `json:"name" binding:"required"`
+	Image       string            `json:"image"` // Required unless boot_iso is set
+	Description string            `json:"description"`
+	Limits      map[string]string `json:"limits"`
+	UserData    string            `json:"user_data"`
+	Type        string            `json:"type"` // "container" (default); "virtual-machine" only with boot_iso
+	TemplateID  string            `json:"template_id"`
+	ISOImage    string            `json:"iso_image"`
+	NetworkID   string            `json:"network_id"`
+	Password    string            `json:"password"`     // Root password for VM
+	StoragePool string            `json:"storage_pool"` // LXD pool, only with boot_iso; empty uses the default pool
+	ReuseIP     bool              `json:"reuse_ip"`     // Reclaim the IP a deleted instance of the same name had, if still free
+	RawConfig   map[string]string `json:"raw_config"`   // Extra LXD config keys, see lxc.RawConfigKeys
+	BootISO     string            `json:"boot_iso"`     // Installer flow: blank VM booting this uploaded ISO, no image
+	BlankDisk   string            `json:"blank_disk"`   // Root disk size for boot_iso, e.g. "20GB"
 	// Direct resource fields (preferred over parsing from Limits)
 	VCPU               int `json:"vcpu"`
 	MemoryMiB          int `json:"memory_mib"`
 	DiskSizeGB         int `json:"disk_size_gb"`
 	BandwidthLimitMbps int `json:"bandwidth_limit_mbps"`
+	// ReadinessProbe decides when the instance is ready (see GET /instances/:name/ready)
+	ReadinessProbe *types.ReadinessProbe `json:"readiness_probe"`
+	// GitOps: user_data is read from Path in ConfigRepo at Ref by a
+	// fetch_config job queued on create (see service.FetchGitConfig)
+	ConfigRepo string `json:"config_repo"`
+	Ref        string `json:"ref"`
+	Path       string `json:"path"`
+	// Env is exported to the instance; values may reference {{secret:name}}
+	Env map[string]string `json:"env"`
+}
+
+// configSource returns the Git source of user_data, or nil without config_repo
+func (r CreateInstanceRequest) configSource() *types.ConfigSource {
+	if r.ConfigRepo == "" {
+		return nil
+	}
+	return &types.ConfigSource{Repo: r.ConfigRepo, Ref: r.Ref, Path: r.Path}
+}
+
+type ReadinessProbeRequest struct {
+	Probe *types.ReadinessProbe `json:"readiness_probe"` // null removes the probe
+}
+
+type CloneInstanceRequest struct {
+	TargetName    string `json:"target_name" binding:"required"`
+	CopySnapshots bool   `json:"copy_snapshots"`
+}
+
+type RenameInstanceRequest struct {
+	NewName string `json:"new_name" binding:"required"`
+}
+
+// MigrateInstanceRequest moves an instance to another LXD remote
+type MigrateInstanceRequest struct {
+	TargetRemote string `json:"target_remote" binding:"required"`
+	Live         bool   `json:"live"`       // falls back to a cold move when unsupported
+	NetworkID    string `json:"network_id"` // re-homes the IP lease when the target is on another network
 }
 
 type SnapshotRequest struct {
@@ -171,15 +268,94 @@ type SnapshotRequest struct {
 }
 
 type AddPortRequest struct {
-	HostPort      int    `json:"host_port" binding:"required"`
+	HostPort      int    `json:"host_port"` // 0 picks a free port from the configured range
 	ContainerPort int    `json:"container_port" binding:"required"`
-	Protocol      string `json:"protocol" binding:"required"`
+	Protocol      string `json:"protocol" binding:"required"` // tcp or udp
 }
 
 type BackupConfigRequest struct {
 	Enabled   bool   `json:"enabled"`
 	Schedule  string `json:"schedule"`
 	Retention int    `json:"retention"`
+	// Encryption of exported backup artifacts; nil keeps the current setting
+	Encryption *service.BackupEncryption `json:"encryption"`
+	// RetentionPolicy switches pruning to GFS buckets; nil keeps the current
+	// policy and {"mode": "simple"} goes back to the retention count
+	RetentionPolicy *types.RetentionPolicy `json:"retention_policy"`
+}
+
+type CreateGroupRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Members     []string `json:"members"`
+}
+
+// StackRequest creates several instances as a unit (POST /stacks)
+type StackRequest struct {
+	Name      string                 `json:"name" binding:"required"`
+	NetworkID string                 `json:"network_id"` // Used by members that do not set their own
+	Tags      []string               `json:"tags"`
+	Instances []StackInstanceRequest `json:"instances" binding:"required,min=1,dive"`
+}
+
+type StackInstanceRequest struct {
+	CreateInstanceRequest
+	DependsOn []string `json:"depends_on"` // Members created (and booted) before this one
+}
+
+type CloudInitReapplyRequest struct {
+	UserData string `json:"user_data" binding:"required"`
+}
+
+type KillProcessRequest struct {
+	Signal string `json:"signal"` // default TERM
+}
+
+type AddDeviceRequest struct {
+	Name   string            `json:"name"` // optional, generated from the type when empty
+	Type   string            `json:"type" binding:"required"`
+	Config map[string]string `json:"config"`
+}
+
+type ExecRequest struct {
+	Command []string `json:"command" binding:"required"`
+	Timeout string   `json:"timeout"` // e.g. "60s"; defaults to 60s
+}
+
+// ScheduledTaskRequest creates or replaces a scheduled command
+type ScheduledTaskRequest struct {
+	Cron    string   `json:"cron" binding:"required"` // e.g. "0 3 * * *" or "@daily", UTC
+	Command []string `json:"command" binding:"required"`
+	Timeout string   `json:"timeout"`
+	Enabled *bool    `json:"enabled"` // defaults to true
+}
+
+type ExpandNetworkRequest struct {
+	CIDR string `json:"cidr" binding:"required"`
+}
+
+// SecretRequest creates or rotates a secret
+type SecretRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Value string `json:"value" binding:"required"`
+}
+
+// ReIPRequest moves an instance to network_id; without ip the first free
+// address is taken
+type ReIPRequest struct {
+	NetworkID string `json:"network_id" binding:"required"`
+	IP        string `json:"ip"`
+}
+
+// PreviewAllocationRequest selects what to preview; both fields are optional.
+// Name matters because released IPs stay held for their previous owner.
+type PreviewAllocationRequest struct {
+	NetworkID string `json:"network_id"`
+	Name      string `json:"name"`
+}
+
+type GroupMemberRequest struct {
+	Instance string `json:"instance" binding:"required"`
 }
 
 type CreateNetworkRequest struct {
@@ -244,17 +420,48 @@ func (m *Metrics) Snapshot() map[string]interface{} {
 // ============================================================================
 
 type Handlers struct {
+	cfg             *config.Config
 	axhvClient      *axhv.Client
+	lxcClient       *lxc.InstanceService // nil quando o LXD não está disponível
+	fleet           *lxc.Fleet           // Todos os remotes LXD; nil junto com lxcClient
 	backupScheduler *scheduler.BackupScheduler
 	metrics         *Metrics
+	secrets         *service.SecretStore // nil sem AXION_SECRETS_KEY
+	prometheus      http.Handler         // GET /metrics
 }
 
-func NewHandlers(axhvClient *axhv.Client, backupScheduler *scheduler.BackupScheduler) *Handlers {
-	return &Handlers{
+func NewHandlers(cfg *config.Config, axhvClient *axhv.Client, lxcClient *lxc.InstanceService, fleet *lxc.Fleet, backupScheduler *scheduler.BackupScheduler, secrets *service.SecretStore) *Handlers {
+	h := &Handlers{
+		cfg:             cfg,
 		axhvClient:      axhvClient,
+		lxcClient:       lxcClient,
+		fleet:           fleet,
 		backupScheduler: backupScheduler,
 		metrics:         NewMetrics(),
+		secrets:         secrets,
+	}
+
+	var lister monitor.InstanceLister
+	if fleet != nil {
+		lister = h.listFleetInstances
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(monitor.NewPrometheusCollector(lister, func(ctx context.Context) ([]db.JobCount, error) {
+		return db.NewJobRepository(db.GetService()).CountByTypeAndStatus(ctx)
+	}))
+	h.prometheus = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	return h
+}
+
+// listFleetInstances lists the instances of every reachable remote; it only
+// fails when no remote answers.
+func (h *Handlers) listFleetInstances() ([]lxc.InstanceMetric, error) {
+	instances, warnings := h.fleet.ListInstances()
+	if len(warnings) > 0 && len(warnings) == len(h.fleet.Remotes()) {
+		return nil, fmt.Errorf("no LXD remote reachable: %s", warnings[0].Error)
 	}
+	return instances, nil
 }
 
 // Middleware para métricas e error handling
@@ -295,13 +502,96 @@ func (h *Handlers) writeError(c *gin.Context, appErr *AppError) {
 	c.JSON(appErr.HTTPStatus, response)
 }
 
+// instanceClient returns the LXD client of the remote hosting the instance.
+// Instances no remote knows stay on the primary, which reports them missing.
+func (h *Handlers) instanceClient(name string) *lxc.InstanceService {
+	if h.fleet == nil || len(h.fleet.Remotes()) < 2 {
+		return h.lxcClient
+	}
+	client, _, err := h.fleet.ForInstance(name)
+	if err != nil {
+		return h.lxcClient
+	}
+	return client
+}
+
+// requireLXD writes a 503 and returns false when the LXD provider is not connected
+func (h *Handlers) requireLXD(c *gin.Context) bool {
+	if h.lxcClient == nil {
+		h.writeError(c, ErrLXDUnavailable())
+		return false
+	}
+	return true
+}
+
+// resolveTierPolicy returns the configured resource policy for the requesting
+// user's plan. Admins are never limited; unknown users fall back to the free tier.
+func (h *Handlers) resolveTierPolicy(c *gin.Context) types.TierPolicy {
+	if c.GetString("role") == "admin" {
+		return types.UnlimitedTierPolicy()
+	}
+
+	id, err := strconv.Atoi(c.GetString("user_id"))
+	if err != nil {
+		return h.cfg.TierPolicies.ForPlan(types.PlanFree)
+	}
+	user, err := db.NewUserRepository(db.GetService()).GetByID(c.Request.Context(), id)
+	if err != nil || user == nil {
+		return h.cfg.TierPolicies.ForPlan(types.PlanFree)
+	}
+	return h.cfg.TierPolicies.ForPlan(user.Plan)
+}
+
+// dispatchJob persists an async job for the worker pool and queues it
+func (h *Handlers) dispatchJob(c *gin.Context, jobType types.JobType, target string, payload interface{}) (*db.Job, *AppError) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, ErrJobCreation(err)
+	}
+
+	job := &db.Job{
+		ID:      uuid.New().String(),
+		Type:    jobType,
+		Target:  target,
+		Payload: string(data),
+	}
+	if username := c.GetString("username"); username != "" {
+		job.RequestedBy = &username
+	}
+
+	// Identical operations already queued or running are reused instead of repeated
+	if opts := worker.JobTypeRegistry[jobType]; opts.Dedupe {
+		repo := db.NewJobRepository(db.GetService())
+		existing, created, err := repo.CreateDeduplicated(c.Request.Context(), job, opts.DedupeByPayload)
+		if err != nil {
+			return nil, ErrJobCreation(err)
+		}
+		if !created {
+			c.Header("X-Job-Deduplicated", "true")
+			return existing, nil
+		}
+		worker.DispatchJob(job.ID)
+		h.metrics.RecordJob()
+		return job, nil
+	}
+
+	if err := db.CreateJob(job); err != nil {
+		return nil, ErrJobCreation(err)
+	}
+
+	worker.DispatchJob(job.ID)
+	h.metrics.RecordJob()
+
+	return job, nil
+}
+
 // Instance Handlers
 func (h *Handlers) GetInstance(c *gin.Context) {
 	name := c.Param("name")
 
 	instance, err := db.GetInstance(name)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, db.ErrInstanceNotFound) {
 			h.writeError(c, ErrInstanceNotFound(name))
 			return
 		}
@@ -324,8 +614,23 @@ func (h *Handlers) GetInstance(c *gin.Context) {
 			}
 		}
 	}
+	instance.Status = types.ClassifyStatus(instance.Status, instance.DesiredState)
+
+	// 2. Hardware specs and devices
+	h.populateInstanceDetail(c.Request.Context(), instance)
+
+	// Ensure IP is set (fetched from DB join now)
+	// Gateway is hardcoded for MVP
+	// We can inject "guest_gateway" into limits or a specific field if needed,
+	// but frontend can also infer it (x.1). For now let's just return what we have.
+
+	c.JSON(200, instance)
+}
 
-	// 2. Populate Hardware Specs from Limits (for frontend simplicity)
+// populateInstanceDetail fills the fields of the instance detail derived from
+// its limits and devices
+func (h *Handlers) populateInstanceDetail(ctx context.Context, instance *types.Instance) {
+	// Populate Hardware Specs from Limits (for frontend simplicity)
 	// Limits map example: {"limits.cpu": "1", "limits.memory": "512MB", "limits.disk": "10GB"}
 	// We handle standard keys
 	if val, ok := instance.Limits["limits.cpu"]; ok {
@@ -338,6 +643,17 @@ func (h *Handlers) GetInstance(c *gin.Context) {
 		}
 	}
 
+	// Weighted CPU: unset keys report LXD's effective defaults
+	instance.CPUAllowance = lxc.DefaultCPUAllowance
+	if val := instance.Limits[lxc.CPUAllowanceKey]; val != "" {
+		instance.CPUAllowance = val
+	}
+	priority := lxc.DefaultCPUPriority
+	if val, err := strconv.Atoi(instance.Limits[lxc.CPUPriorityKey]); err == nil {
+		priority = val
+	}
+	instance.CPUPriority = &priority
+
 	// Disk limit parsing (simplified)
 	if val, ok := instance.Limits["limits.disk"]; ok {
 		// Expect "10GB" -> 10 * 1024 * 1024 * 1024
@@ -351,10 +667,76 @@ func (h *Handlers) GetInstance(c *gin.Context) {
 		}
 	}
 
-	// Ensure IP is set (fetched from DB join now)
-	// Gateway is hardcoded for MVP
-	// We can inject "guest_gateway" into limits or a specific field if needed,
-	// but frontend can also infer it (x.1). For now let's just return what we have.
+	// Passthrough devices
+	if devices, err := db.NewDeviceRepository(db.GetService()).List(ctx, instance.Name); err == nil {
+		instance.Devices = devices
+	} else {
+		log.Printf("Error listing devices for %s: %v", instance.Name, err)
+	}
+}
+
+// GetLastSync returns the report of the last LXD to DB sync (startup or
+// POST /admin/sync): which instances were imported, updated or failed.
+func (h *Handlers) GetLastSync(c *gin.Context) {
+	report := scheduler.LastSyncReport()
+	if report == nil {
+		h.writeError(c, NewError(ErrCodeNotFound, "no sync has run yet", nil, 404, false))
+		return
+	}
+	c.JSON(200, report)
+}
+
+// TriggerSync starts a full LXD to DB sync in the background; its report is
+// read from GET /admin/sync/last once finished.
+func (h *Handlers) TriggerSync(c *gin.Context) {
+	if !h.requireLXD(c) {
+		return
+	}
+	if err := scheduler.StartSync(h.lxcClient, h.cfg.ImportDefaults, h.cfg.SyncConcurrency); err != nil {
+		h.writeError(c, NewError(ErrCodeConflict, "a sync is already running", err, 409, true))
+		return
+	}
+	c.JSON(202, gin.H{"status": "started"})
+}
+
+// SyncInstance re-reads one instance from LXD and records its status and IPs,
+// the targeted version of the startup sync for an instance known to have
+// drifted. Returns the refreshed detail.
+func (h *Handlers) SyncInstance(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	instance, err := db.GetInstance(name)
+	if err != nil {
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	if err := scheduler.SyncInstance(h.lxcClient, instance); err != nil {
+		if errors.Is(err, scheduler.ErrNotInLXD) {
+			h.writeError(c, NewError(ErrCodeInstanceNotFound, "instance not found in LXD", err, 404, false).
+				WithContext("instance", name))
+			return
+		}
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to read instance state", err, 502, true).
+			WithContext("instance", name))
+		return
+	}
+
+	// Re-read so the response carries the volatile state just recorded
+	instance, err = db.GetInstance(name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	h.populateInstanceDetail(c.Request.Context(), instance)
 
 	c.JSON(200, instance)
 }
@@ -380,30 +762,55 @@ func parseSizeToBytes(s string) int64 {
 	return val * multiplier
 }
 
+// ListInstances supports ?limit=&offset=&sort=&order= (see db.InstanceListing);
+// X-Total-Count carries the number of instances across all pages
 func (h *Handlers) ListInstances(c *gin.Context) {
-	instances, err := db.ListInstances()
+	page, ok := h.parsePage(c, db.InstanceListing)
+	if !ok {
+		return
+	}
+
+	repo := db.NewInstanceRepository(db.GetService())
+	instances, err := repo.ListPage(c.Request.Context(), page)
 	if err != nil {
 		log.Printf("Error listing instances: %v", err)
 		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
+	total, err := repo.Count(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+
+	// With several LXD remotes, node names the remote hosting each instance;
+	// unreachable remotes are listed in X-Unreachable-Remotes
+	if h.fleet != nil && len(h.fleet.Remotes()) > 1 {
+		live, warnings := h.fleet.ListInstances()
+		hosts := make(map[string]string, len(live))
+		for _, inst := range live {
+			hosts[inst.Name] = inst.Remote
+		}
+		for i := range instances {
+			instances[i].Node = hosts[instances[i].Name]
+		}
+		if len(warnings) > 0 {
+			unreachable := make([]string, len(warnings))
+			for i, w := range warnings {
+				unreachable[i] = w.Remote
+			}
+			c.Header("X-Unreachable-Remotes", strings.Join(unreachable, ","))
+		}
+	}
 
 	// Get live status from AxHV daemon
-	if h.axhvClient != nil {
-		axhvVMs, err := h.axhvClient.ListVms(c.Request.Context())
-		if err == nil && axhvVMs != nil {
-			// Build a map of running VMs
-			runningVMs := make(map[string]bool)
-			for _, vm := range axhvVMs.Vms {
-				runningVMs[vm.Id] = true
-			}
-			// Update instance statuses
-			for i := range instances {
-				if runningVMs[instances[i].Name] {
-					instances[i].Status = "RUNNING"
-				} else {
-					instances[i].Status = "STOPPED"
-				}
+	if runningVMs := h.runningVMs(c.Request.Context()); runningVMs != nil {
+		for i := range instances {
+			if runningVMs[instances[i].Name] {
+				instances[i].Status = "RUNNING"
+			} else {
+				instances[i].Status = types.ClassifyStatus("STOPPED", instances[i].DesiredState)
 			}
 		}
 	}
@@ -417,6 +824,26 @@ func (h *Handlers) CreateInstance(c *gin.Context) {
 		h.writeError(c, ErrInvalidJSON(err))
 		return
 	}
+	h.applyImageDefaults(c.Request.Context(), &req)
+
+	// Run the same checks exposed by POST /instances/validate
+	if problems := h.validateCreateRequest(c.Request.Context(), req, h.instanceCapOwner(c)); len(problems) > 0 {
+		h.writeError(c, problems[0])
+		return
+	}
+
+	if req.BootISO != "" {
+		h.createFromISO(c, req)
+		return
+	}
+
+	// GitOps: the repository file stands in for user_data. The fetch can
+	// outlast the request, so a fetch_config job reads it (template merge
+	// included) and the seed holds the VM's user-data until it lands.
+	source := req.configSource()
+	if source != nil && !h.requireLXD(c) {
+		return
+	}
 
 	// Validate and merge template
 	enhancedUserData, appErr := h.processTemplate(req)
@@ -424,46 +851,164 @@ func (h *Handlers) CreateInstance(c *gin.Context) {
 		h.writeError(c, appErr)
 		return
 	}
+	if err := h.checkSecrets(c, enhancedUserData, req.Env); err != nil {
+		h.writeSecretError(c, err)
+		return
+	}
 
-	// Allocate IP using DB locking (IPAM)
-	var ip string
-	var err error
+	vm, appErr := h.provisionVM(c, req, enhancedUserData, source)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
 
-	if req.NetworkID != "" {
-		ip, err = db.GetService().AllocateInNetwork(c.Request.Context(), req.NetworkID, req.Name)
-	} else {
-		ip, err = db.GetService().AllocateIP(c.Request.Context(), req.Name)
+	h.metrics.RecordInstanceCreated()
+	response := gin.H{"status": "created", "ip": vm.IP, "vm_id": vm.VMID, "type": vm.Type}
+	if jobID := h.watchProvisioning(c, req.Name); jobID != "" {
+		response["cloud_init_job_id"] = jobID
+	}
+	if source != nil {
+		job, appErr := h.dispatchJob(c, types.JobTypeFetchConfig, req.Name, gin.H{
+			"template_id":  req.TemplateID,
+			"secret_owner": h.secretOwner(c),
+		})
+		response["config_source"] = source
+		if appErr != nil {
+			// The VM is up with the config it was created from; only the
+			// background refresh from the repository is lost
+			log.Printf("Failed to queue config fetch for %s: %v", req.Name, appErr)
+		} else {
+			response["config_job_id"] = job.ID
+		}
+	}
+	c.JSON(201, response)
+}
+
+// watchProvisioning follows a freshly created instance: a wait_cloud_init
+// job records cloud_init_status and then starts the readiness probe. Without
+// LXD there is no job system, so only the probe is started. Returns the job
+// ID, if any.
+func (h *Handlers) watchProvisioning(c *gin.Context, name string) string {
+	if h.lxcClient == nil {
+		worker.EvaluateReadiness(nil, name)
+		return ""
+	}
+	job, appErr := h.dispatchJob(c, types.JobTypeWaitCloudInit, name, gin.H{})
+	if appErr != nil {
+		// The instance exists; only the tracking is lost
+		log.Printf("Failed to queue cloud-init tracking for %s: %v", name, appErr)
+		worker.EvaluateReadiness(h.lxcClient, name)
+		return ""
+	}
+	return job.ID
+}
+
+// checkSecrets verifies the secret references of user_data and env values
+// (see service.SecretStore.Check)
+func (h *Handlers) checkSecrets(c *gin.Context, userData string, env map[string]string) error {
+	for _, text := range append([]string{userData}, envValues(env)...) {
+		if err := h.secrets.Check(c.Request.Context(), text, h.secretOwner(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func envValues(env map[string]string) []string {
+	values := make([]string, 0, len(env))
+	for _, v := range env {
+		values = append(values, v)
+	}
+	return values
+}
+
+// provisionedVM is an AxHV instance created by provisionVM. Rollback undoes
+// every step (VM, IP lease, database row) for callers that create several
+// instances as a unit.
+type provisionedVM struct {
+	IP   string
+	VMID string
+	Type string
+	saga *service.Saga
+}
+
+func (p *provisionedVM) Rollback(ctx context.Context) service.RollbackReport {
+	return p.saga.Rollback(ctx)
+}
+
+// provisionVM allocates the IP, creates the VM on AxHV and persists the
+// instance. req must already be validated; source, when set, is the Git
+// config user_data came from. On failure the completed steps are rolled back
+// and reported in the error context.
+func (h *Handlers) provisionVM(c *gin.Context, req CreateInstanceRequest, enhancedUserData string, source *types.ConfigSource) (*provisionedVM, *AppError) {
+	// Allocate IP using DB locking (IPAM). A previous instance with the same
+	// name gets its address back when recreated within the grace window.
+	ip, err := db.GetService().ReclaimIP(c.Request.Context(), req.Name, req.NetworkID, req.ReuseIP)
+
+	if err == nil && ip == "" {
+		if req.NetworkID != "" {
+			ip, err = db.GetService().AllocateInNetwork(c.Request.Context(), req.NetworkID, req.Name)
+		} else {
+			ip, err = db.GetService().AllocateIP(c.Request.Context(), req.Name)
+		}
 	}
 
 	if err != nil {
 		log.Printf("IP Allocation failed for %s: %v", req.Name, err)
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, "failed to allocate IP", err, 500, false))
-		return
+		return nil, NewError(ErrCodeInstanceCreationFailed, "failed to allocate IP", err, 500, false)
 	}
 
-	// Defer release logic in case of failure later (requires careful handling of success path)
-	// We will release if we panic or return early with error.
-	success := false
+	// Every completed step registers its undo; a later failure (or panic)
+	// compensates them in reverse order and reports what was rolled back.
+	saga := service.NewSaga("create " + req.Name)
+	saga.Completed("allocate_ip", func(ctx context.Context) error {
+		return db.GetService().ReleaseIP(ctx, req.Name)
+	})
+	fail := func(appErr *AppError) (*provisionedVM, *AppError) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return nil, appErr.WithContext("rollback", saga.Rollback(ctx))
+	}
 	defer func() {
-		if !success {
-			db.GetService().ReleaseIP(context.Background(), req.Name)
+		if r := recover(); r != nil {
+			saga.Rollback(context.Background())
+			panic(r)
 		}
 	}()
 
+	instanceType := req.Type
+	if instanceType == "" {
+		instanceType = lxc.InstanceTypeContainer
+	}
+
 	// Create Instance object for DB storage
 	instance := types.Instance{
 		Name:            req.Name,
 		Image:           req.Image,
-		Limits:          req.Limits,
+		Description:     req.Description,
+		Limits:          mergeRawConfig(req.Limits, req.RawConfig),
 		UserData:        enhancedUserData,
-		Type:            req.Type,
+		Type:            instanceType,
 		BackupSchedule:  "@daily",
 		BackupRetention: 7,
 		BackupEnabled:   false,
+		StoragePool:     req.StoragePool,
+		Owner:           c.GetString("username"),
+		ReadinessProbe:  req.ReadinessProbe,
+		ConfigSource:    source,
+	}
+	if len(req.Env) > 0 {
+		if instance.Limits == nil {
+			instance.Limits = make(map[string]string)
+		}
+		for name, value := range req.Env {
+			instance.Limits[service.EnvConfigPrefix+name] = value
+		}
 	}
 
 	// Map to Protobuf - use V2 if direct values provided, else legacy
-	gateway := "172.16.0.1" // Gateway is constant for now
+	gateway := axhv.GuestGateway
+	policy := h.resolveTierPolicy(c)
 	var pbReq *pb.CreateVmRequest
 
 	if req.VCPU > 0 || req.MemoryMiB > 0 || req.DiskSizeGB > 0 {
@@ -480,13 +1025,19 @@ func (h *Handlers) CreateInstance(c *gin.Context) {
 			req.Limits,
 			req.Password,
 		)
+		if err == nil {
+			err = axhv.ApplyTierPolicy(pbReq, policy)
+		}
 	} else {
 		// Legacy: parse from limits strings
-		pbReq, err = axhv.MapCreateRequest(instance, ip, gateway)
+		pbReq, err = axhv.MapCreateRequest(instance, ip, gateway, policy)
 	}
 	if err != nil {
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, "failed to map request", err, 400, false))
-		return
+		return fail(NewError(ErrCodeInstanceCreationFailed, "failed to map request", err, 400, false))
+	}
+	// AxHV has no user_data field: the VM fetches it from the seed at boot
+	if h.cfg.SeedURL != "" {
+		pbReq.BootArgs = axhv.SeedBootArgs(h.cfg.SeedURL, req.Name)
 	}
 
 	// Call AxHV gRPC
@@ -494,67 +1045,309 @@ func (h *Handlers) CreateInstance(c *gin.Context) {
 	grpcResp, err := h.axhvClient.CreateVm(c.Request.Context(), pbReq)
 	if err != nil {
 		log.Printf("[ERROR] AxHV CreateVm failed: %v", err)
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, "AxHV RPC failed", err, 502, true))
-		return
+		return fail(NewError(ErrCodeInstanceCreationFailed, "AxHV RPC failed", err, 502, true))
 	}
 
 	if !grpcResp.Success {
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, fmt.Sprintf("AxHV Error: %s", grpcResp.Message), nil, 400, false))
-		return
+		return fail(NewError(ErrCodeInstanceCreationFailed, fmt.Sprintf("AxHV Error: %s", grpcResp.Message), nil, 400, false))
 	}
+	saga.Completed("create_vm", func(ctx context.Context) error {
+		resp, err := h.axhvClient.DeleteVm(ctx, req.Name)
+		if err != nil {
+			return err
+		}
+		if !resp.Success {
+			return errors.New(resp.Message)
+		}
+		return nil
+	})
 
 	// Persist to DB
 	// Note: We already allocated the IP which updated the ip_leases table with instance_name.
 	// We should also insert into instances table as before.
-	// Update limits with the allocated IP and bandwidth to keep consistency
+	// Update limits with the bandwidth to keep consistency; the IP lives in ip_leases
 	if instance.Limits == nil {
 		instance.Limits = make(map[string]string)
 	}
-	instance.Limits["volatile.ip_address"] = ip
 	instance.Limits["bandwidth_limit_mbps"] = strconv.Itoa(int(pbReq.BandwidthLimitMbps))
+	// Persist effective sizing so quota accounting sees V2 (direct value) creates too
+	instance.Limits["limits.cpu"] = strconv.Itoa(int(pbReq.Vcpu))
+	instance.Limits["limits.memory"] = fmt.Sprintf("%dMB", pbReq.MemoryMib)
 
 	if err := db.CreateInstance(&instance); err != nil {
-		// If DB fails, we should try to cleanup the VM? Ideally yes.
-		// For now, fail
-		h.writeError(c, ErrDatabaseFailure(err))
-		return
+		return fail(ErrDatabaseFailure(err))
 	}
+	saga.Completed("persist_instance", func(ctx context.Context) error {
+		return db.DeleteInstance(req.Name)
+	})
 
-	success = true
-	h.metrics.RecordInstanceCreated()
-
-	c.JSON(201, gin.H{"status": "created", "ip": ip, "vm_id": grpcResp.VmId})
+	return &provisionedVM{IP: ip, VMID: grpcResp.VmId, Type: instanceType, saga: saga}, nil
 }
 
-func (h *Handlers) DeleteInstance(c *gin.Context) {
-	name := c.Param("name")
+// createFromISO provisions a blank virtual machine that boots an uploaded
+// installer ISO. There is no image rootfs: LXD creates an empty disk of
+// blank_disk through a job. The ISO is recorded as the "iso" passthrough
+// device, so DELETE /instances/:name/devices/iso detaches it once the OS is
+// installed. The VM takes its address from DHCP and the sync records it.
+func (h *Handlers) createFromISO(c *gin.Context, req CreateInstanceRequest) {
+	if !h.requireLXD(c) {
+		return
+	}
 
-	// Call AxHV gRPC
-	grpcResp, err := h.axhvClient.DeleteVm(c.Request.Context(), name)
+	storageService, err := service.NewStorageService()
 	if err != nil {
-		h.writeError(c, NewError(ErrCodeInstanceNotFound, "AxHV RPC failed", err, 502, true))
+		h.writeError(c, NewError(ErrCodeStorageOperationFailed, "storage init failed", err, 500, false))
 		return
 	}
+	isoPath := storageService.GetISOPath(req.BootISO)
 
-	if !grpcResp.Success {
-		// If it's just "not found", we might want to proceed to delete from DB anyway
-		log.Printf("AxHV Delete Warn: %s", grpcResp.Message)
+	diskGB, _ := utils.ParseDiskToGB(req.BlankDisk) // checked by validateBootISO
+	rootSize := fmt.Sprintf("%dGB", diskGB)
+
+	cpu, ram := h.requestedResources(req)
+	lxdLimits := mergeRawConfig(map[string]string{
+		"limits.cpu":    strconv.Itoa(cpu),
+		"limits.memory": fmt.Sprintf("%dMB", ram),
+	}, req.RawConfig)
+
+	limits := make(map[string]string, len(lxdLimits)+1)
+	for k, v := range lxdLimits {
+		limits[k] = v
 	}
+	limits["disk"] = rootSize
 
-	// Release IP
-	if err := db.GetService().ReleaseIP(c.Request.Context(), name); err != nil {
-		log.Printf("Error releasing IP for %s: %v", name, err)
+	instance := types.Instance{
+		Name:            req.Name,
+		Image:           "iso:" + req.BootISO,
+		Description:     req.Description,
+		Limits:          limits,
+		Type:            "virtual-machine",
+		BackupSchedule:  "@daily",
+		BackupRetention: 7,
+		BackupEnabled:   false,
+		StoragePool:     req.StoragePool,
+		Owner:           c.GetString("username"),
+		ReadinessProbe:  req.ReadinessProbe,
 	}
 
-	if err := db.DeleteInstance(name); err != nil {
-		h.writeError(c, ErrDatabaseFailure(err))
+	saga := service.NewSaga("create " + req.Name)
+	fail := func(appErr *AppError) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		h.writeError(c, appErr.WithContext("rollback", saga.Rollback(ctx)))
+	}
+
+	if err := db.CreateInstance(&instance); err != nil {
+		fail(ErrDatabaseFailure(err))
 		return
 	}
+	// Deleting the row cascades to the ISO device recorded below
+	saga.Completed("persist_instance", func(ctx context.Context) error {
+		return db.DeleteInstance(req.Name)
+	})
 
-	h.metrics.RecordInstanceDeleted()
+	device := &types.InstanceDevice{
+		Instance: req.Name,
+		Name:     lxc.ISODeviceName,
+		Type:     "disk",
+		Config:   lxc.ISODeviceConfig(isoPath),
+	}
+	if err := db.NewDeviceRepository(db.GetService()).Create(c.Request.Context(), device); err != nil {
+		fail(ErrDatabaseFailure(err))
+		return
+	}
 
-	c.JSON(200, gin.H{"status": "deleted"})
-}
+	job, appErr := h.dispatchJob(c, types.JobTypeCreateInstance, req.Name, gin.H{
+		"name":         req.Name,
+		"type":         "virtual-machine",
+		"limits":       lxdLimits,
+		"iso_image":    req.BootISO,
+		"storage_pool": req.StoragePool,
+		"root_size":    rootSize,
+	})
+	if appErr != nil {
+		fail(appErr)
+		return
+	}
+
+	h.metrics.RecordInstanceCreated()
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "boot_device": lxc.ISODeviceName})
+}
+
+// ValidateInstance runs every create-time check without allocating or provisioning anything
+func (h *Handlers) ValidateInstance(c *gin.Context) {
+	var req CreateInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	h.applyImageDefaults(c.Request.Context(), &req)
+
+	problems := h.validateCreateRequest(c.Request.Context(), req, h.instanceCapOwner(c))
+	if _, appErr := h.processTemplate(req); appErr != nil {
+		problems = append(problems, appErr)
+	}
+
+	if len(problems) == 0 {
+		c.JSON(200, gin.H{"ok": true})
+		return
+	}
+
+	list := make([]gin.H, 0, len(problems))
+	for _, p := range problems {
+		item := gin.H{"code": p.Code, "error": p.Message}
+		if len(p.Context) > 0 {
+			item["context"] = p.Context
+		}
+		if p.Err != nil {
+			item["details"] = p.Err.Error()
+		}
+		list = append(list, item)
+	}
+	c.JSON(200, gin.H{"ok": false, "problems": list})
+}
+
+// DeleteInstance shuts the VM down cleanly, waiting up to the configured
+// grace period, then deletes it. ?force=true skips the shutdown.
+func (h *Handlers) DeleteInstance(c *gin.Context) {
+	name := c.Param("name")
+
+	// Instances that live in LXD (ISO installs, clones, imports) are not known
+	// to AxHV; a delete_instance job shuts them down and removes them
+	if h.lxcClient != nil {
+		exists, err := h.instanceClient(name).InstanceExists(name)
+		if err != nil {
+			log.Printf("Could not check LXD for %s, deleting through AxHV: %v", name, err)
+		} else if exists {
+			grace := h.deleteGrace(c)
+			job, appErr := h.dispatchJob(c, types.JobTypeDeleteInstance, name, gin.H{
+				"force": grace <= 0,
+				"grace": grace.String(),
+			})
+			if appErr != nil {
+				h.writeError(c, appErr)
+				return
+			}
+			c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID})
+			return
+		}
+	}
+
+	shutdown, appErr := h.destroyInstance(c.Request.Context(), name, h.deleteGrace(c))
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "deleted", "shutdown": shutdown})
+}
+
+// deleteGrace is how long a delete waits for a clean shutdown: zero with
+// ?force=true, AXION_DELETE_GRACE otherwise
+func (h *Handlers) deleteGrace(c *gin.Context) time.Duration {
+	if force, _ := strconv.ParseBool(c.Query("force")); force {
+		return 0
+	}
+	return h.cfg.DeleteGracePeriod
+}
+
+// destroyInstance shuts the VM down (see shutdownForDelete), deletes it on
+// AxHV, releases its IP and removes the instance from the database. It
+// returns the shutdown path taken, one of the lxc.Shutdown* values.
+func (h *Handlers) destroyInstance(ctx context.Context, name string, grace time.Duration) (string, *AppError) {
+	shutdown := h.shutdownForDelete(ctx, name, grace)
+
+	// Once the VM is going away the rest runs to completion even if the
+	// client hangs up, so AxHV and the database do not disagree
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), destroyTimeout)
+	defer cancel()
+
+	// Call AxHV gRPC. A VM AxHV no longer knows still has its lease and row
+	// cleaned up below; only a name unknown to the database too is a 404.
+	onAxHV := true
+	grpcResp, err := h.axhvClient.DeleteVm(ctx, name)
+	switch {
+	case status.Code(err) == codes.NotFound:
+		log.Printf("AxHV has no VM %s, cleaning up the database", name)
+		onAxHV = false
+	case err != nil:
+		return "", NewError(ErrCodeHypervisorFailed, "AxHV RPC failed", err, 502, true).
+			WithContext("instance", name)
+	case !grpcResp.Success:
+		log.Printf("AxHV Delete Warn: %s", grpcResp.Message)
+		onAxHV = false
+	}
+
+	// Release IP
+	if err := db.GetService().ReleaseIP(ctx, name); err != nil {
+		log.Printf("Error releasing IP for %s: %v", name, err)
+	}
+
+	if err := db.DeleteInstance(name); err != nil {
+		// Unknown to both AxHV and the database
+		if errors.Is(err, db.ErrInstanceNotFound) && !onAxHV {
+			return "", ErrInstanceNotFound(name)
+		}
+		if !errors.Is(err, db.ErrInstanceNotFound) {
+			return "", ErrDatabaseFailure(err)
+		}
+	}
+
+	h.metrics.RecordInstanceDeleted()
+	log.Printf("Instance %s deleted (shutdown: %s)", name, shutdown)
+	return shutdown, nil
+}
+
+// destroyTimeout bounds the AxHV delete and the cleanup after it, which run
+// after the shutdown grace and still have to fit in the server's WriteTimeout
+const destroyTimeout = 5 * time.Second
+
+// shutdownForDelete asks AxHV to stop the VM and polls until it is gone from
+// the running list or grace expires; DeleteVm then kills whatever is left.
+// A zero grace skips the stop so the delete is immediate.
+func (h *Handlers) shutdownForDelete(ctx context.Context, name string, grace time.Duration) string {
+	if grace <= 0 {
+		return lxc.ShutdownForced
+	}
+	if running := h.runningVMs(ctx); running != nil && !running[name] {
+		return lxc.ShutdownNotRunning
+	}
+
+	// executeAction records the desired STOPPED state, so the crash
+	// reconciler does not restart the VM while it shuts down
+	resp, err := h.executeAction(ctx, name, "stop")
+	if err != nil || !resp.Success {
+		log.Printf("Clean shutdown of %s failed, forcing delete: %v", name, stopFailure(resp, err))
+		return lxc.ShutdownForced
+	}
+
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if running := h.runningVMs(ctx); running != nil && !running[name] {
+			return lxc.ShutdownGraceful
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			log.Printf("Instance %s did not stop within %s, forcing delete", name, grace)
+			return lxc.ShutdownTimeoutForced
+		case <-ctx.Done():
+			return lxc.ShutdownTimeoutForced
+		}
+	}
+}
+
+// stopFailure picks the reason a stop action did not succeed
+func stopFailure(resp *pb.VmResponse, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.Message
+}
 
 func (h *Handlers) UpdateInstanceState(c *gin.Context) {
 	name := c.Param("name")
@@ -565,11 +1358,39 @@ func (h *Handlers) UpdateInstanceState(c *gin.Context) {
 		return
 	}
 
+	if !isValidAction(req.Action) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid action", nil, 400, false))
+		return
+	}
+
+	resp, err := h.executeAction(c.Request.Context(), name, req.Action)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, "AxHV RPC failed", err, 502, true))
+		return
+	}
+
+	if !resp.Success {
+		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, resp.Message, nil, 400, false))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "executed", "action": req.Action})
+}
+
+func isValidAction(action string) bool {
+	switch action {
+	case "start", "stop", "reboot", "pause", "resume":
+		return true
+	}
+	return false
+}
+
+// executeAction sends a state action to AxHV and, on success, records the
+// resulting desired state. Callers must validate the action first.
+func (h *Handlers) executeAction(ctx context.Context, name, action string) (*pb.VmResponse, error) {
 	var resp *pb.VmResponse
 	var err error
-	ctx := c.Request.Context()
-
-	switch req.Action {
+	switch action {
 	case "start":
 		resp, err = h.axhvClient.StartVm(ctx, name)
 	case "stop":
@@ -581,21 +1402,65 @@ func (h *Handlers) UpdateInstanceState(c *gin.Context) {
 	case "resume":
 		resp, err = h.axhvClient.ResumeVm(ctx, name)
 	default:
-		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid action", nil, 400, false))
-		return
+		return nil, fmt.Errorf("invalid action: %s", action)
+	}
+
+	if err == nil && resp.Success {
+		if state, ok := types.DesiredStateForAction(action); ok {
+			if dbErr := db.NewInstanceRepository(db.GetService()).SetDesiredState(ctx, name, state); dbErr != nil {
+				log.Printf("Error recording desired state for %s: %v", name, dbErr)
+			}
+		}
+	}
+	return resp, err
+}
+
+// restartCrashedInstances restarts instances that are stopped although their
+// desired state is RUNNING. Enabled with AXION_RESTART_CRASHED=true.
+func (h *Handlers) restartCrashedInstances(ctx context.Context) {
+	runningVMs := h.runningVMs(ctx)
+	if runningVMs == nil {
+		return // AxHV unavailable: every instance would look crashed
 	}
 
+	instances, err := db.ListInstances()
 	if err != nil {
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, "AxHV RPC failed", err, 502, true))
+		log.Printf("⚠ Crash reconciliation skipped: %v", err)
 		return
 	}
 
-	if !resp.Success {
-		h.writeError(c, NewError(ErrCodeInstanceCreationFailed, resp.Message, nil, 400, false))
-		return
+	for _, inst := range instances {
+		if ctx.Err() != nil {
+			return
+		}
+		if runningVMs[inst.Name] || types.ClassifyStatus("STOPPED", inst.DesiredState) != types.StatusCrashed {
+			continue
+		}
+
+		log.Printf("Instance %s crashed (desired RUNNING), restarting", inst.Name)
+		resp, err := h.executeAction(ctx, inst.Name, "start")
+		if err != nil {
+			log.Printf("⚠ Restart of crashed instance %s failed: %v", inst.Name, err)
+		} else if !resp.Success {
+			log.Printf("⚠ Restart of crashed instance %s failed: %s", inst.Name, resp.Message)
+		}
 	}
+}
 
-	c.JSON(200, gin.H{"status": "executed", "action": req.Action})
+// runningVMs returns the set of VM ids AxHV currently reports, or nil if unavailable
+func (h *Handlers) runningVMs(ctx context.Context) map[string]bool {
+	if h.axhvClient == nil {
+		return nil
+	}
+	axhvVMs, err := h.axhvClient.ListVms(ctx)
+	if err != nil || axhvVMs == nil {
+		return nil
+	}
+	running := make(map[string]bool, len(axhvVMs.Vms))
+	for _, vm := range axhvVMs.Vms {
+		running[vm.Id] = true
+	}
+	return running
 }
 
 func (h *Handlers) UpdateInstanceLimits(c *gin.Context) {
@@ -608,17 +1473,43 @@ func (h *Handlers) UpdateInstanceLimits(c *gin.Context) {
 	}
 
 	// Validate at least one field is provided
-	if req.VCPU <= 0 && req.MemoryMiB <= 0 {
-		h.writeError(c, NewError(ErrCodeMissingField, "at least vcpu or memory_mib required", nil, 400, false))
+	if req.VCPU <= 0 && req.MemoryMiB <= 0 && len(req.RawConfig) == 0 && req.CPUAllowance == nil && req.CPUPriority == nil {
+		h.writeError(c, NewError(ErrCodeMissingField, "at least vcpu, memory_mib, cpu_allowance, cpu_priority or raw_config required", nil, 400, false))
 		return
 	}
+	if err := lxc.ValidateRawConfig(req.RawConfig); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid raw_config", err, 400, false))
+		return
+	}
+
+	cpuConfig := map[string]string{}
+	if req.CPUAllowance != nil {
+		if *req.CPUAllowance != "" {
+			if err := lxc.ValidateCPUAllowance(*req.CPUAllowance); err != nil {
+				h.writeError(c, NewError(ErrCodeInvalidQuota, "invalid cpu_allowance", err, 400, false))
+				return
+			}
+		}
+		cpuConfig[lxc.CPUAllowanceKey] = *req.CPUAllowance
+	}
+	if req.CPUPriority != nil {
+		if err := lxc.ValidateCPUPriority(*req.CPUPriority); err != nil {
+			h.writeError(c, NewError(ErrCodeInvalidQuota, "invalid cpu_priority", err, 400, false))
+			return
+		}
+		cpuConfig[lxc.CPUPriorityKey] = strconv.Itoa(*req.CPUPriority)
+	}
 
 	// Get current instance from DB
 	instance, err := db.GetInstance(name)
-	if err != nil {
+	if errors.Is(err, db.ErrInstanceNotFound) {
 		h.writeError(c, ErrInstanceNotFound(name))
 		return
 	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
 
 	// Update limits map
 	if instance.Limits == nil {
@@ -630,165 +1521,2810 @@ func (h *Handlers) UpdateInstanceLimits(c *gin.Context) {
 	if req.MemoryMiB > 0 {
 		instance.Limits["limits.memory"] = fmt.Sprintf("%dMB", req.MemoryMiB)
 	}
+	instance.Limits = mergeRawConfig(instance.Limits, req.RawConfig)
+	for key, value := range cpuConfig {
+		if value == "" {
+			delete(instance.Limits, key)
+		} else {
+			instance.Limits[key] = value
+		}
+	}
 
 	// Save to DB
-	if err := db.UpdateInstanceStatusAndLimits(name, instance.Limits); err != nil {
+	if err := db.UpdateInstanceLimits(name, instance.Limits); err != nil {
 		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
 
-	// Note: Hot-resize via AxHV is not yet implemented
-	// Changes will take effect on next VM restart
-	c.JSON(200, gin.H{
+	response := gin.H{
 		"status":  "updated",
 		"message": "Limits updated. Changes will take effect on next restart.",
 		"limits": gin.H{
 			"vcpu":       req.VCPU,
 			"memory_mib": req.MemoryMiB,
 		},
-	})
+	}
+
+	// raw_config and CPU shares are LXD configuration: apply them live when LXD is connected
+	if len(cpuConfig) > 0 {
+		response["cpu"] = cpuConfig
+	}
+	liveConfig := mergeRawConfig(req.RawConfig, cpuConfig)
+	if len(liveConfig) > 0 && h.lxcClient != nil {
+		job, appErr := h.dispatchJob(c, types.JobTypeUpdateLimits, name, gin.H{"config": liveConfig})
+		if appErr != nil {
+			h.writeError(c, appErr)
+			return
+		}
+		response["job_id"] = job.ID
+		if len(req.RawConfig) > 0 {
+			response["raw_config"] = req.RawConfig
+		}
+	}
+
+	// Note: Hot-resize via AxHV is not yet implemented
+	// Changes will take effect on next VM restart
+	c.JSON(200, response)
 }
 
-func (h *Handlers) UpdateBackupConfig(c *gin.Context) {
+// mergeRawConfig overlays validated raw_config keys on the instance limits,
+// which are passed to LXD as the instance config
+func mergeRawConfig(limits, raw map[string]string) map[string]string {
+	if len(raw) == 0 {
+		return limits
+	}
+	merged := make(map[string]string, len(limits)+len(raw))
+	for k, v := range limits {
+		merged[k] = v
+	}
+	for k, v := range raw {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (h *Handlers) UpdateIOLimits(c *gin.Context) {
 	name := c.Param("name")
-	var req BackupConfigRequest
+	var req IOLimitsRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.writeError(c, ErrInvalidJSON(err))
 		return
 	}
 
-	if err := db.UpdateInstanceBackupConfig(name, req.Enabled, req.Schedule, req.Retention); err != nil {
+	read, err := ioLimitValue(req.ReadIOPS, req.ReadBPS)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidQuota, "invalid read limit", err, 400, false))
+		return
+	}
+	write, err := ioLimitValue(req.WriteIOPS, req.WriteBPS)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidQuota, "invalid write limit", err, 400, false))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	instance, err := db.GetInstance(name)
+	if errors.Is(err, db.ErrInstanceNotFound) {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+	if err != nil {
 		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
 
-	h.backupScheduler.ReloadInstance(name)
-	c.JSON(200, gin.H{"status": "updated"})
-}
+	if instance.Limits == nil {
+		instance.Limits = make(map[string]string)
+	}
+	for key, value := range map[string]string{"limits.read": read, "limits.write": write} {
+		if value == "" {
+			delete(instance.Limits, key)
+		} else {
+			instance.Limits[key] = value
+		}
+	}
 
-// Snapshot Handlers - NOT IMPLEMENTED IN AxHV
-func (h *Handlers) ListSnapshots(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Snapshots not supported in AxHV v2"})
-}
+	if err := db.UpdateInstanceLimits(name, instance.Limits); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
 
-func (h *Handlers) CreateSnapshot(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Snapshots not supported in AxHV v2"})
-}
+	job, appErr := h.dispatchJob(c, types.JobTypeUpdateIOLimits, name, gin.H{"read": read, "write": write})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
 
-func (h *Handlers) RestoreSnapshot(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Snapshots not supported in AxHV v2"})
+	c.JSON(202, gin.H{
+		"status": "accepted",
+		"job_id": job.ID,
+		"limits": gin.H{
+			"limits.read":  read,
+			"limits.write": write,
+		},
+	})
 }
 
-func (h *Handlers) DeleteSnapshot(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Snapshots not supported in AxHV v2"})
+// ioLimitValue builds an LXD disk limit ("1000iops", "50MB" or "" for unlimited)
+func ioLimitValue(iops int, bps string) (string, error) {
+	bps = strings.ToUpper(strings.TrimSpace(bps))
+	if iops < 0 {
+		return "", fmt.Errorf("iops must be >= 0, got %d", iops)
+	}
+	if bps == "0" {
+		bps = ""
+	}
+	if iops > 0 && bps != "" {
+		return "", errors.New("set either iops or bytes per second for a direction, not both")
+	}
+	if iops > 0 {
+		return fmt.Sprintf("%diops", iops), nil
+	}
+	if bps != "" {
+		if utils.ParseMemoryToBytes(bps) <= 0 {
+			return "", fmt.Errorf("invalid size %q (expected e.g. 50MB)", bps)
+		}
+		return bps, nil
+	}
+	return "", nil
 }
 
-// Port Management Handlers
-func (h *Handlers) AddPort(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Port forwarding management not supported in AxHV v2"})
-}
+func (h *Handlers) UpdateBackupConfig(c *gin.Context) {
+	name := c.Param("name")
+	var req BackupConfigRequest
 
-func (h *Handlers) RemovePort(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "Port forwarding management not supported in AxHV v2"})
-}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
 
-// File System Handlers - NOT IMPLEMENTED IN AxHV
-func (h *Handlers) ListFiles(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
-}
+	if appErr := validateBackupConfig(req); appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
 
-func (h *Handlers) DownloadFile(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
-}
+	if req.Encryption != nil {
+		if err := service.ValidateBackupEncryption(*req.Encryption, h.cfg.BackupEncryptionKey != nil); err != nil {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid backup encryption", err, 422, false).
+				WithContext("mode", req.Encryption.Mode))
+			return
+		}
+	}
 
-func (h *Handlers) UploadFile(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
-}
+	if err := db.UpdateInstanceBackupConfig(name, req.Enabled, req.Schedule, req.Retention); err != nil {
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
 
-func (h *Handlers) DeleteFile(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
+	if req.Encryption != nil {
+		repo := db.NewInstanceRepository(db.GetService())
+		if err := repo.SetBackupEncryption(c.Request.Context(), name, req.Encryption.Mode, req.Encryption.Recipient); err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+	}
+	if req.RetentionPolicy != nil {
+		repo := db.NewInstanceRepository(db.GetService())
+		if err := repo.SetRetentionPolicy(c.Request.Context(), name, req.RetentionPolicy); err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+	}
+
+	// Without LXD there is no scheduler; the saved settings apply once it runs
+	if h.backupScheduler != nil {
+		h.backupScheduler.ReloadInstance(name)
+	}
+	c.JSON(200, gin.H{"status": "updated"})
 }
 
-// Job Handlers
-func (h *Handlers) ListJobs(c *gin.Context) {
-	jobs, err := db.ListRecentJobs(50)
+// validateBackupConfig rejects what the instances table constraints would,
+// and schedules the cron parser cannot read, before anything is written
+func validateBackupConfig(req BackupConfigRequest) *AppError {
+	if req.Retention < 1 {
+		return NewError(ErrCodeInvalidJSON, "retention must be at least 1", nil, 422, false).
+			WithContext("field", "retention").WithContext("retention", req.Retention)
+	}
+	if req.Enabled && strings.TrimSpace(req.Schedule) == "" {
+		return NewError(ErrCodeMissingField, "schedule is required when backups are enabled", nil, 422, false).
+			WithContext("field", "schedule")
+	}
+	if _, err := db.GetNextRunTime(req.Schedule); err != nil {
+		return NewError(ErrCodeInvalidJSON, "invalid cron expression", err, 422, false).
+			WithContext("field", "schedule").WithContext("schedule", req.Schedule)
+	}
+	if req.RetentionPolicy != nil {
+		if err := req.RetentionPolicy.Validate(); err != nil {
+			return NewError(ErrCodeInvalidJSON, "invalid retention policy", err, 422, false).
+				WithContext("field", "retention_policy")
+		}
+	}
+	return nil
+}
+
+// PreviewSnapshotPolicy shows the next scheduled backup snapshots of an
+// instance and which existing ones the next run would prune under its
+// retention (count or GFS policy), without creating or deleting anything.
+func (h *Handlers) PreviewSnapshotPolicy(c *gin.Context) {
+	name := c.Param("name")
+
+	count := 5
+	if raw := c.Query("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 50 {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "count must be between 1 and 50", err, 400, false))
+			return
+		}
+		count = n
+	}
+
+	instance, err := db.GetInstance(name)
+	if err != nil {
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+		} else {
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+	snapshots, err := h.instanceClient(name).ListSnapshots(name)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to list snapshots", err, 502, true).
+			WithContext("instance", name))
+		return
+	}
+
+	// A disabled schedule never runs, so it never prunes either
+	schedule := ""
+	if instance.BackupEnabled {
+		schedule = instance.BackupSchedule
+	}
+	policy, err := db.NewInstanceRepository(db.GetService()).GetRetentionPolicy(c.Request.Context(), name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	preview, err := scheduler.PreviewSnapshotPolicy(schedule, instance.BackupRetention, policy, snapshots, time.Now().UTC(), count)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeConfigurationInvalid, "stored backup schedule is invalid", err, 422, false).
+			WithContext("schedule", instance.BackupSchedule))
+		return
+	}
+
+	pause, err := db.NewSettingsRepository(db.GetService()).GetBackupPauseState(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"instance":         name,
+		"enabled":          instance.BackupEnabled,
+		"schedule":         instance.BackupSchedule,
+		"retention":        instance.BackupRetention,
+		"retention_policy": retentionPolicyOrSimple(policy),
+		"paused":           pause.Paused,
+		"next_runs":        preview.NextRuns,
+		"prune":            preview.Prune,
+	})
+}
+
+// retentionPolicyOrSimple reports a missing policy as the simple mode it means
+func retentionPolicyOrSimple(policy *types.RetentionPolicy) *types.RetentionPolicy {
+	if policy == nil {
+		return &types.RetentionPolicy{Mode: types.RetentionSimple}
+	}
+	return policy
+}
+
+// ReapplyCloudInit replaces the stored user_data and queues a job that pushes it
+// to LXD and re-runs cloud-init (clean + reboot) inside the instance.
+func (h *Handlers) ReapplyCloudInit(c *gin.Context) {
+	name := c.Param("name")
+	var req CloudInitReapplyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if err := service.ValidateCloudConfig(req.UserData); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid cloud-config", err, 400, false))
+		return
+	}
+	if err := h.secrets.Check(c.Request.Context(), req.UserData, h.secretOwner(c)); err != nil {
+		h.writeSecretError(c, err)
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	repo := db.NewInstanceRepository(db.GetService())
+	if exists, err := repo.Exists(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	if err := repo.UpdateUserData(c.Request.Context(), name, req.UserData); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeReapplyCloudInit, name, gin.H{"user_data": req.UserData})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{
+		"status":  "accepted",
+		"job_id":  job.ID,
+		"warning": "cloud-init will run again from scratch and the instance will reboot; per-instance modules (users, packages, runcmd) re-execute and may overwrite manual changes",
+	})
+}
+
+// ExecInstance queues a one-shot command; stdout, stderr and the exit code
+// end up in the job result (GET /jobs/:id)
+func (h *Handlers) ExecInstance(c *gin.Context) {
+	name := c.Param("name")
+	var req ExecRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if len(req.Command) == 0 || strings.TrimSpace(req.Command[0]) == "" {
+		h.writeError(c, NewError(ErrCodeMissingField, "command is required", nil, 400, false))
+		return
+	}
+
+	timeout, appErr := execTimeout(req.Timeout)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	if exists, err := db.NewInstanceRepository(db.GetService()).Exists(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeExec, name, gin.H{
+		"command": req.Command,
+		"timeout": timeout.String(),
+	})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID})
+}
+
+// execTimeout parses an exec timeout; empty means worker.ExecDefaultTimeout
+func execTimeout(raw string) (time.Duration, *AppError) {
+	if raw == "" {
+		return worker.ExecDefaultTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > worker.ExecMaxTimeout {
+		return 0, NewError(ErrCodeInvalidJSON,
+			fmt.Sprintf("timeout must be a duration between 1s and %s", worker.ExecMaxTimeout), err, 400, false)
+	}
+	return d, nil
+}
+
+// ============================================================================
+// SCHEDULED TASKS
+// ============================================================================
+
+func (h *Handlers) ListScheduledTasks(c *gin.Context) {
+	tasks, err := db.NewScheduledTaskRepository(db.GetService()).List(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, gin.H{"tasks": tasks})
+}
+
+// CreateScheduledTask schedules a command in the instance. Each run is an
+// exec job, visible in the job history.
+func (h *Handlers) CreateScheduledTask(c *gin.Context) {
+	name := c.Param("name")
+	task, appErr := bindScheduledTask(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	if exists, err := db.NewInstanceRepository(db.GetService()).Exists(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	task.Instance = name
+	task.CreatedBy = c.GetString("username")
+	if err := db.NewScheduledTaskRepository(db.GetService()).Create(c.Request.Context(), task); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(201, task)
+}
+
+func (h *Handlers) UpdateScheduledTask(c *gin.Context) {
+	existing, ok := h.scheduledTaskFromPath(c)
+	if !ok {
+		return
+	}
+	task, appErr := bindScheduledTask(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	task.ID = existing.ID
+	if err := db.NewScheduledTaskRepository(db.GetService()).Update(c.Request.Context(), task); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, task)
+}
+
+func (h *Handlers) DeleteScheduledTask(c *gin.Context) {
+	task, ok := h.scheduledTaskFromPath(c)
+	if !ok {
+		return
+	}
+	if err := db.NewScheduledTaskRepository(db.GetService()).Delete(c.Request.Context(), task.ID); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, gin.H{"status": "deleted", "id": task.ID})
+}
+
+// scheduledTaskFromPath loads the :id task, checking it belongs to :name
+func (h *Handlers) scheduledTaskFromPath(c *gin.Context) (*db.ScheduledTask, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid task id", err, 400, false))
+		return nil, false
+	}
+
+	task, err := db.NewScheduledTaskRepository(db.GetService()).Get(c.Request.Context(), id)
+	if err == nil && task.Instance != c.Param("name") {
+		err = db.ErrScheduledTaskNotFound
+	}
+	if errors.Is(err, db.ErrScheduledTaskNotFound) {
+		h.writeError(c, NewError(ErrCodeNotFound, "scheduled task not found", err, 404, false))
+		return nil, false
+	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return nil, false
+	}
+	return task, true
+}
+
+func bindScheduledTask(c *gin.Context) (*db.ScheduledTask, *AppError) {
+	var req ScheduledTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, ErrInvalidJSON(err)
+	}
+
+	if len(req.Command) == 0 || strings.TrimSpace(req.Command[0]) == "" {
+		return nil, NewError(ErrCodeMissingField, "command is required", nil, 400, false)
+	}
+	if _, err := db.GetNextRunTime(req.Cron); err != nil {
+		return nil, NewError(ErrCodeInvalidJSON, "invalid cron expression", err, 422, false).
+			WithContext("cron", req.Cron)
+	}
+	timeout, appErr := execTimeout(req.Timeout)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &db.ScheduledTask{
+		Cron:    req.Cron,
+		Command: req.Command,
+		Timeout: timeout.String(),
+		Enabled: enabled,
+	}, nil
+}
+
+// GetInstanceReadiness reports whether the instance passed its readiness
+// probe. Without a probe, ready is null and only the instance status applies.
+func (h *Handlers) GetInstanceReadiness(c *gin.Context) {
+	name := c.Param("name")
+
+	readiness, err := db.NewInstanceRepository(db.GetService()).GetReadiness(c.Request.Context(), name)
+	if errors.Is(err, db.ErrInstanceNotFound) {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"instance":   name,
+		"ready":      readiness.Ready,
+		"checked_at": readiness.CheckedAt,
+		"message":    readiness.Message,
+		"probe":      readiness.Probe,
+	})
+}
+
+// SetReadinessProbe replaces (or with a null body field, removes) the
+// readiness probe of an instance and evaluates the new one right away.
+func (h *Handlers) SetReadinessProbe(c *gin.Context) {
+	name := c.Param("name")
+	var req ReadinessProbeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	if req.Probe != nil {
+		if err := req.Probe.Validate(); err != nil {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid readiness_probe", err, 400, false))
+			return
+		}
+	}
+
+	err := db.NewInstanceRepository(db.GetService()).SetReadinessProbe(c.Request.Context(), name, req.Probe)
+	if errors.Is(err, db.ErrInstanceNotFound) {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	if req.Probe != nil {
+		worker.EvaluateReadiness(h.lxcClient, name)
+	} else {
+		worker.CancelReadiness(name)
+	}
+	c.JSON(200, gin.H{"instance": name, "probe": req.Probe})
+}
+
+// CancelOperation aborts a running LXD operation of the instance, such as the
+// image download of a create job (see the job's lxd_operation). Operations
+// that already finished or that LXD cannot cancel are reported with 409.
+func (h *Handlers) CancelOperation(c *gin.Context) {
+	name := c.Param("name")
+	id := c.Param("id")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	previous, err := h.instanceClient(name).CancelOperation(name, id)
+	switch {
+	case errors.Is(err, lxc.ErrOperationNotFound):
+		h.writeError(c, NewError(ErrCodeNotFound, "operation not found for this instance", err, 404, false).
+			WithContext("instance", name).WithContext("operation", id))
+		return
+	case errors.Is(err, lxc.ErrOperationNotCancellable):
+		h.writeError(c, NewError(ErrCodeConflict, "operation cannot be cancelled", err, 409, false).
+			WithContext("operation", id).WithContext("status", previous))
+		return
+	case err != nil:
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to cancel operation", err, 502, true).
+			WithContext("instance", name).WithContext("operation", id))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"instance":        name,
+		"operation":       id,
+		"previous_status": previous,
+		"status":          "cancelled",
+	})
+}
+
+// Process Handlers
+func (h *Handlers) ListProcesses(c *gin.Context) {
+	name := c.Param("name")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	processes, err := h.instanceClient(name).ListProcesses(name)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to list processes", err, 502, true).
+			WithContext("instance", name))
+		return
+	}
+
+	c.JSON(200, processes)
+}
+
+func (h *Handlers) KillProcess(c *gin.Context) {
+	name := c.Param("name")
+
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil || pid <= 0 {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid pid", err, 400, false))
+		return
+	}
+	if pid == 1 {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "refusing to signal the instance init process; use the state action instead", nil, 400, false))
+		return
+	}
+
+	var req KillProcessRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.writeError(c, ErrInvalidJSON(err))
+			return
+		}
+	}
+
+	signal, err := lxc.NormalizeSignal(req.Signal)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid signal", err, 400, false).
+			WithContext("allowed", lxcSignalNames()))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	if err := h.instanceClient(name).KillProcess(name, pid, signal); err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to signal process", err, 502, false).
+			WithContext("instance", name).
+			WithContext("pid", pid))
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "signaled", "pid": pid, "signal": signal})
+}
+
+func lxcSignalNames() []string {
+	names := make([]string, 0, len(lxc.AllowedSignals))
+	for sig := range lxc.AllowedSignals {
+		names = append(names, sig)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddDevice validates and persists a passthrough device, then attaches it through a job
+func (h *Handlers) AddDevice(c *gin.Context) {
+	name := c.Param("name")
+	var req AddDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+	if err := service.ValidateDevice(req.Type, req.Config, h.cfg.DevicePolicy); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid device", err, 400, false).
+			WithContext("type", req.Type))
+		return
+	}
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("%s-%s", req.Type, uuid.New().String()[:8])
+	}
+	if err := service.ValidateDeviceName(req.Name); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid device name", err, 400, false))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(ctx, name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	device := &types.InstanceDevice{Instance: name, Name: req.Name, Type: req.Type, Config: req.Config}
+	if err := db.NewDeviceRepository(db.GetService()).Create(ctx, device); err != nil {
+		if errors.Is(err, db.ErrDeviceExists) {
+			h.writeError(c, NewError(ErrCodeConflict, "device already exists", err, 409, false).
+				WithContext("instance", name).
+				WithContext("device", req.Name))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeAddDevice, name, gin.H{"name": device.Name, "type": device.Type, "config": device.Config})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "device": device})
+}
+
+// RemoveDevice forgets a passthrough device and detaches it through a job
+func (h *Handlers) RemoveDevice(c *gin.Context) {
+	name := c.Param("name")
+	deviceName := c.Param("device")
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	if err := db.NewDeviceRepository(db.GetService()).Delete(c.Request.Context(), name, deviceName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, NewError(ErrCodeInvalidPath, "device not found", nil, 404, false).
+				WithContext("instance", name).
+				WithContext("device", deviceName))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeRemoveDevice, name, gin.H{"name": deviceName})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID})
+}
+
+// reconcileDevices re-attaches persisted devices that are missing from LXD,
+// e.g. after an instance was recreated or edited outside Axion.
+func reconcileDevices(ctx context.Context, lxcClient *lxc.InstanceService) {
+	devices, err := db.NewDeviceRepository(db.GetService()).ListAll(ctx)
+	if err != nil {
+		log.Printf("⚠ Device reconciliation skipped: %v", err)
+		return
+	}
+
+	for _, device := range devices {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := lxcClient.AddDevice(device.Instance, device.Name, device.Type, device.Config); err != nil {
+			log.Printf("⚠ Device reconciliation failed for %s/%s: %v", device.Instance, device.Name, err)
+		}
+	}
+	log.Printf("✓ Reconciled %d passthrough devices", len(devices))
+}
+
+// Snapshot Handlers
+
+// ListSnapshots lists the instance's snapshots as LXD reports them
+func (h *Handlers) ListSnapshots(c *gin.Context) {
+	name := c.Param("name")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	snapshots, ok := h.instanceSnapshots(c, name)
+	if !ok {
+		return
+	}
+
+	list := make([]gin.H, 0, len(snapshots))
+	for _, snap := range snapshots {
+		list = append(list, gin.H{
+			"name":       snap.Name,
+			"created_at": snap.CreatedAt,
+			"stateful":   snap.Stateful,
+			"size_bytes": snap.Size,
+		})
+	}
+	c.JSON(200, gin.H{"instance": name, "snapshots": list})
+}
+
+// instanceSnapshots reads the instance's snapshots, writing the error
+// response when the instance is unknown or LXD fails
+func (h *Handlers) instanceSnapshots(c *gin.Context, name string) ([]lxdapi.InstanceSnapshot, bool) {
+	snapshots, err := h.instanceClient(name).ListSnapshots(name)
+	if err != nil {
+		if lxdapi.StatusErrorCheck(err, http.StatusNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+		} else {
+			h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to list snapshots", err, 502, true).
+				WithContext("instance", name))
+		}
+		return nil, false
+	}
+	return snapshots, true
+}
+
+// CreateSnapshot validates the name synchronously, so LXD's naming rules fail
+// with a 422 here instead of deep in the worker, then queues the snapshot.
+func (h *Handlers) CreateSnapshot(c *gin.Context) {
+	name := c.Param("name")
+	var req SnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if err := lxc.ValidateSnapshotName(req.Name); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid snapshot name", err, 422, false).
+			WithContext("snapshot", req.Name))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	if _, err := db.GetInstance(name); err != nil {
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+		} else {
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeCreateSnapshot, name, gin.H{"snapshot_name": req.Name})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "snapshot": req.Name})
+}
+
+// DiffSnapshots lists the paths added, modified or deleted between two
+// snapshots: GET /instances/:name/snapshots/diff?from=snapA&to=snapB
+func (h *Handlers) DiffSnapshots(c *gin.Context) {
+	name := c.Param("name")
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		h.writeError(c, NewError(ErrCodeMissingField, "from and to are required", nil, 400, false))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	diff, err := h.instanceClient(name).DiffSnapshots(c.Request.Context(), name, from, to)
+	if errors.Is(err, lxc.ErrInvalidSnapshotName) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid snapshot name", err, 422, false).
+			WithContext("from", from).
+			WithContext("to", to))
+		return
+	}
+	if errors.Is(err, lxc.ErrSnapshotDiffUnsupported) {
+		h.writeError(c, NewError(ErrCodeSnapshotFailed, "snapshot diff not supported for this pool", err, 422, false).
+			WithContext("instance", name))
+		return
+	}
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeSnapshotFailed, "failed to diff snapshots", err, 500, true).
+			WithContext("from", from).
+			WithContext("to", to))
+		return
+	}
+
+	c.JSON(200, diff)
+}
+
+// RestoreSnapshot queues a restore_snapshot job; a running instance is
+// stopped before its disk is rolled back.
+func (h *Handlers) RestoreSnapshot(c *gin.Context) {
+	h.dispatchSnapshotJob(c, types.JobTypeRestoreSnapshot)
+}
+
+// DeleteSnapshot queues a delete_snapshot job. Automatic (auto-*) snapshots
+// can be deleted too.
+func (h *Handlers) DeleteSnapshot(c *gin.Context) {
+	h.dispatchSnapshotJob(c, types.JobTypeDeleteSnapshot)
+}
+
+// dispatchSnapshotJob checks that :snap exists on the instance, so a typo
+// fails with a 404 instead of a failed job, then queues jobType for it
+func (h *Handlers) dispatchSnapshotJob(c *gin.Context, jobType types.JobType) {
+	name, snap := c.Param("name"), c.Param("snap")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	snapshots, ok := h.instanceSnapshots(c, name)
+	if !ok {
+		return
+	}
+	found := false
+	for _, s := range snapshots {
+		if s.Name == snap {
+			found = true
+			break
+		}
+	}
+	if !found {
+		h.writeError(c, NewError(ErrCodeSnapshotNotFound, "snapshot not found", nil, 404, false).
+			WithContext("instance", name).
+			WithContext("snapshot", snap))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, jobType, name, gin.H{"snapshot_name": snap})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "snapshot": snap})
+}
+
+// Port Management Handlers
+
+// AddPort queues an add_port job forwarding a host port to the instance
+// through an LXD proxy device. Protocol is tcp or udp; the same host port can
+// carry one rule of each. The host port must be inside the configured range
+// for the protocol (422 otherwise); host_port 0 takes the lowest free one.
+// The port is claimed before the job runs, so concurrent requests never get
+// the same port.
+func (h *Handlers) AddPort(c *gin.Context) {
+	name := c.Param("name")
+	var req AddPortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	req.Protocol = strings.ToLower(req.Protocol)
+	if !lxc.IsPortProtocol(req.Protocol) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "protocol must be tcp or udp", nil, 400, false).
+			WithContext("protocol", req.Protocol))
+		return
+	}
+	if req.HostPort < 0 || req.ContainerPort < 1 || req.ContainerPort > 65535 {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid port", nil, 400, false).
+			WithContext("host_port", req.HostPort).WithContext("container_port", req.ContainerPort))
+		return
+	}
+	allowed := lxc.PortRangeFor(req.Protocol)
+	if req.HostPort != 0 && !allowed.Contains(req.HostPort) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "host_port outside the allowed range", nil, 422, false).
+			WithContext("host_port", req.HostPort).WithContext("protocol", req.Protocol).
+			WithContext("allowed_range", allowed.String()))
+		return
+	}
+
+	if !h.requireLXD(c) || !h.requireInstance(c, name) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	ports := db.NewPortForwardRepository(db.GetService())
+	forward := &db.PortForward{HostPort: req.HostPort, Protocol: req.Protocol, InstanceName: name, ContainerPort: req.ContainerPort}
+	switch err := ports.Reserve(ctx, forward, allowed.Min, allowed.Max); {
+	case errors.Is(err, db.ErrPortInUse):
+		h.writeError(c, NewError(ErrCodeConflict, "host port already in use", err, 409, false).
+			WithContext("host_port", req.HostPort).WithContext("protocol", req.Protocol))
+		return
+	case errors.Is(err, db.ErrNoFreePort):
+		h.writeError(c, NewError(ErrCodeInsufficientResources, "no free host port in the allowed range", err, 409, false).
+			WithContext("protocol", req.Protocol).WithContext("allowed_range", allowed.String()))
+		return
+	case err != nil:
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	req.HostPort = forward.HostPort
+
+	job, appErr := h.dispatchJob(c, types.JobTypeAddPort, name, req)
+	if appErr != nil {
+		if err := ports.Release(context.Background(), forward.HostPort, forward.Protocol); err != nil {
+			log.Printf("[API] Failed to release host port %d/%s: %v", forward.HostPort, forward.Protocol, err)
+		}
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "host_port": req.HostPort, "protocol": req.Protocol})
+}
+
+// adoptProxyPorts claims in port_forwards the host ports of proxy devices
+// created before the table existed (or by hand), so automatic picks skip them
+func adoptProxyPorts(lxcClient *lxc.InstanceService) {
+	found, err := lxcClient.ListProxyPorts()
+	if err != nil {
+		log.Printf("[Ports] Failed to list existing port forwards: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	repo := db.NewPortForwardRepository(db.GetService())
+	adopted := 0
+	for _, p := range found {
+		ok, err := repo.Adopt(ctx, db.PortForward{HostPort: p.HostPort, Protocol: p.Protocol, InstanceName: p.Instance, ContainerPort: p.ContainerPort})
+		if err != nil {
+			log.Printf("[Ports] Failed to adopt %d/%s of %s: %v", p.HostPort, p.Protocol, p.Instance, err)
+			continue
+		}
+		if ok {
+			adopted++
+		}
+	}
+	if adopted > 0 {
+		log.Printf("[Ports] Adopted %d existing port forwards", adopted)
+	}
+}
+
+// RemovePort queues a remove_port job. ?protocol=udp removes the udp rule of
+// the host port; the default is tcp, leaving the other protocol untouched.
+func (h *Handlers) RemovePort(c *gin.Context) {
+	name := c.Param("name")
+	hostPort, err := strconv.Atoi(c.Param("host_port"))
+	if err != nil || hostPort < 1 || hostPort > 65535 {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid host_port", err, 400, false).
+			WithContext("host_port", c.Param("host_port")))
+		return
+	}
+	protocol := strings.ToLower(c.DefaultQuery("protocol", lxc.ProtocolTCP))
+	if !lxc.IsPortProtocol(protocol) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "protocol must be tcp or udp", nil, 400, false).
+			WithContext("protocol", protocol))
+		return
+	}
+
+	if !h.requireLXD(c) || !h.requireInstance(c, name) {
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeRemovePort, name, gin.H{"host_port": hostPort, "protocol": protocol})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "host_port": hostPort, "protocol": protocol})
+}
+
+// requireInstance writes a 404 and returns false when name is not a known instance
+func (h *Handlers) requireInstance(c *gin.Context, name string) bool {
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(c.Request.Context(), name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return false
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return false
+	}
+	return true
+}
+
+// File System Handlers
+
+// File listings are paged so huge directories (node_modules, /var/log) stay
+// usable; ?limit= defaults to defaultFileListLimit and is capped at maxFileListLimit
+const (
+	defaultFileListLimit = 1000
+	maxFileListLimit     = 5000
+)
+
+// ListFiles returns one page of ?path= (default "/"), directories first and
+// then by name, with has_more when entries remain past ?offset=+?limit=
+func (h *Handlers) ListFiles(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	dir := c.DefaultQuery("path", "/")
+	offset, limit := 0, defaultFileListLimit
+	if raw := c.Query("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "offset must be a non-negative integer", err, 400, false).
+				WithContext("offset", raw))
+			return
+		}
+		offset = v
+	}
+	if raw := c.Query("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "limit must be a positive integer", err, 400, false).
+				WithContext("limit", raw))
+			return
+		}
+		limit = min(v, maxFileListLimit)
+	}
+
+	page, err := h.instanceClient(name).ListFiles(name, dir, offset, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, lxc.ErrPathEscapesRoot):
+			h.writeError(c, NewError(ErrCodeInvalidPath, "path is outside the allowed root", err, 403, false).
+				WithContext("path", dir))
+		case errors.Is(err, os.ErrNotExist):
+			h.writeError(c, NewError(ErrCodeInvalidPath, "path not found", err, 404, false).
+				WithContext("path", dir))
+		default:
+			h.writeError(c, NewError(ErrCodeFileOperationFailed, "failed to list directory", err, 502, true).
+				WithContext("instance", name))
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"instance": name,
+		"path":     page.Path,
+		"entries":  page.Entries,
+		"offset":   offset,
+		"limit":    limit,
+		"total":    page.Total,
+		"has_more": page.HasMore,
+	})
+}
+
+func (h *Handlers) DownloadFile(c *gin.Context) {
+	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
+}
+
+// UploadFile writes the multipart "file" field into an LXD instance. ?path= is
+// either an existing directory, which receives the upload under its own name,
+// or the full file path; a trailing "/" always means a directory. Missing
+// directories are only created with ?create_parents=true. Returns the path written.
+func (h *Handlers) UploadFile(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	target := c.Query("path")
+	if target == "" {
+		h.writeError(c, NewError(ErrCodeMissingField, "path is required", nil, 400, false).
+			WithContext("field", "path"))
+		return
+	}
+	parents, _ := strconv.ParseBool(c.Query("create_parents"))
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeMissingField, "multipart field 'file' is required", err, 400, false).
+			WithContext("field", "file"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeFileOperationFailed, "failed to read upload", err, 500, false))
+		return
+	}
+	defer file.Close()
+
+	written, err := h.instanceClient(name).UploadFileTo(name, lxc.UploadRequest{
+		Path:     target,
+		Filename: filepath.Base(header.Filename),
+		Parents:  parents,
+	}, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, lxc.ErrPathEscapesRoot):
+			h.writeError(c, NewError(ErrCodeInvalidPath, "path is outside the allowed root", err, 403, false).
+				WithContext("path", target))
+		case errors.Is(err, lxc.ErrUploadParentMissing):
+			h.writeError(c, NewError(ErrCodeInvalidPath, "destination directory does not exist", err, 422, false).
+				WithContext("path", target).
+				WithContext("hint", "set create_parents=true to create it"))
+		case errors.Is(err, lxc.ErrInvalidUploadTarget):
+			h.writeError(c, NewError(ErrCodeInvalidPath, "invalid upload destination", err, 422, false).
+				WithContext("path", target))
+		default:
+			h.writeError(c, NewError(ErrCodeFileOperationFailed, "upload failed", err, 502, true).
+				WithContext("instance", name))
+		}
+		return
+	}
+
+	c.JSON(201, gin.H{"instance": name, "path": written, "size": header.Size})
+}
+
+func (h *Handlers) DeleteFile(c *gin.Context) {
+	c.JSON(501, gin.H{"error": "File operations not supported in AxHV v2"})
+}
+
+// Job Handlers
+// ListJobs supports ?limit=&offset=&sort=&order= (see db.JobListing); the
+// default is the 50 most recent jobs
+func (h *Handlers) ListJobs(c *gin.Context) {
+	page, ok := h.parsePage(c, db.JobListing)
+	if !ok {
+		return
+	}
+
+	repo := db.NewJobRepository(db.GetService())
+	jobs, err := repo.ListPage(c.Request.Context(), page)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	total, err := repo.Count(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(200, jobs)
+}
+
+// parsePage reads the pagination query parameters for resource, answering
+// 400 when one is invalid
+func (h *Handlers) parsePage(c *gin.Context, resource pagination.Resource) (pagination.Params, bool) {
+	page, err := pagination.Parse(c.Request.URL.Query(), resource)
+	if err != nil {
+		appErr := NewError(ErrCodeInvalidJSON, "invalid pagination", err, 400, false)
+		var perr *pagination.Error
+		if errors.As(err, &perr) && perr.Param == "sort" {
+			appErr = appErr.WithContext("sortable", resource.SortNames())
+		}
+		h.writeError(c, appErr)
+		return pagination.Params{}, false
+	}
+	return page, true
+}
+
+// maxJobWait caps ?wait= on GET /jobs/:id
+const maxJobWait = 60 * time.Second
+
+// GetJob returns a job. With ?wait=30s it long-polls: the request blocks until
+// the job reaches a terminal state or the wait (capped at maxJobWait) elapses,
+// then returns the current state either way.
+func (h *Handlers) GetJob(c *gin.Context) {
+	id := c.Param("id")
+
+	var wait time.Duration
+	if raw := c.Query("wait"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid wait", err, 400, false).
+				WithContext("wait", raw))
+			return
+		}
+		wait = min(d, maxJobWait)
+	}
+
+	// Subscribe before reading so an update between the read and the wait is not missed
+	var updates <-chan events.Event
+	if wait > 0 {
+		var unsubscribe func()
+		updates, unsubscribe = events.Subscribe(func(evt events.Event) bool {
+			return evt.Type == events.JobUpdate && evt.JobID == id
+		}, 8)
+		defer unsubscribe()
+	}
+
+	job, err := db.GetJob(id)
+	// Scoped tokens only see jobs of their instances; same 404 as a missing job
+	if err != nil || !auth.AllowsInstance(c, job.Target) {
+		h.writeError(c, NewError(ErrCodeInstanceNotFound, "job not found", err, 404, false))
+		return
+	}
+
+	if wait > 0 && !job.Status.IsTerminal() {
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(wait + 10*time.Second))
+
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+	poll:
+		for !job.Status.IsTerminal() {
+			select {
+			case <-updates:
+			case <-timeout.C:
+				if latest, err := db.GetJob(id); err == nil {
+					job = latest
+				}
+				break poll
+			case <-c.Request.Context().Done():
+				return
+			}
+			if latest, err := db.GetJob(id); err == nil {
+				job = latest
+			}
+		}
+	}
+
+	c.JSON(200, job)
+}
+
+// CancelJob moves a pending or running job to CANCELED. A pending job is
+// skipped when a worker dequeues it; a running one has its context cancelled
+// and its LXD operation, if any, aborted. Finished jobs are reported with 409.
+func (h *Handlers) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+
+	job, err := db.GetJob(id)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeNotFound, "job not found", err, 404, false))
+		return
+	}
+
+	reason := "canceled"
+	if username := c.GetString("username"); username != "" {
+		reason = "canceled by " + username
+	}
+	repo := db.NewJobRepository(db.GetService())
+	err = repo.MarkCanceled(c.Request.Context(), id, reason)
+	if errors.Is(err, db.ErrJobFinished) {
+		// Re-read: the job may have finished after the first read
+		if current, getErr := db.GetJob(id); getErr == nil {
+			job = current
+		}
+		h.writeError(c, NewError(ErrCodeConflict, "job already finished", err, 409, false).
+			WithContext("job_id", id).WithContext("status", string(job.Status)))
+		return
+	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	updated, err := db.GetJob(id)
+	if err != nil {
+		updated = job
+	}
+	if worker.CancelJob(id) {
+		// The worker publishes the update once the job goroutine stops
+		if updated.LXDOperation != "" && h.lxcClient != nil {
+			if _, err := h.instanceClient(updated.Target).CancelOperation(updated.Target, updated.LXDOperation); err != nil {
+				log.Printf("[API] Failed to cancel LXD operation %s of job %s: %v", updated.LXDOperation, id, err)
+			}
+		}
+	} else {
+		events.Publish(events.Event{
+			Type:      events.JobUpdate,
+			JobID:     updated.ID,
+			Target:    updated.Target,
+			Payload:   updated,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+
+	c.JSON(200, gin.H{
+		"job_id":          id,
+		"previous_status": job.Status,
+		"status":          types.JobCanceled,
+	})
+}
+
+// PauseBackups stops every scheduled backup until ResumeBackups. Runs that
+// would have fired meanwhile are skipped, not queued.
+func (h *Handlers) PauseBackups(c *gin.Context) {
+	h.setBackupsPaused(c, true)
+}
+
+func (h *Handlers) ResumeBackups(c *gin.Context) {
+	h.setBackupsPaused(c, false)
+}
+
+func (h *Handlers) setBackupsPaused(c *gin.Context, paused bool) {
+	repo := db.NewSettingsRepository(db.GetService())
+	if err := repo.SetBackupsPaused(c.Request.Context(), paused, c.GetString("username")); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	action := "resumed"
+	if paused {
+		action = "paused"
+	}
+	log.Printf("[Backup] Scheduled backups %s by %s", action, c.GetString("username"))
+	h.GetBackupStatus(c)
+}
+
+func (h *Handlers) GetBackupStatus(c *gin.Context) {
+	state, err := db.NewSettingsRepository(db.GetService()).GetBackupPauseState(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, state)
+}
+
+// GetRetention reports the effective cleanup windows applied by maintenance
+func (h *Handlers) GetRetention(c *gin.Context) {
+	jobs := h.cfg.JobRetention
+	c.JSON(200, gin.H{
+		"jobs": gin.H{
+			"completed": jobs.Completed.String(),
+			"failed":    jobs.Failed.String(),
+			"canceled":  jobs.Canceled.String(),
+		},
+		"metrics": gin.H{
+			"raw":    db.MetricsRawRetention.String(),
+			"hourly": db.MetricsHourlyRetention.String(),
+			"daily":  db.MetricsDailyRetention.String(),
+		},
+	})
+}
+
+// AuditIPAM reports drift between ip_leases, the instances table and, when
+// connected, the live LXD state. It never modifies anything.
+func (h *Handlers) AuditIPAM(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	leases, err := db.GetService().ListAllocatedLeases(ctx)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	instances, err := db.ListInstances()
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	observed, err := db.NewInstanceRepository(db.GetService()).ListVolatile(ctx)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	input := db.IPAMAuditInput{Leases: leases, StoredIPs: make(map[string]string, len(instances))}
+	for _, inst := range instances {
+		input.StoredIPs[inst.Name] = observed[inst.Name].IPv4
+	}
+
+	var lxdErr string
+	if h.fleet != nil {
+		live, warnings := h.fleet.ListInstances()
+		if len(warnings) > 0 {
+			// Still useful without LXD: report the DB-only view
+			problems := make([]string, len(warnings))
+			for i, w := range warnings {
+				problems[i] = w.Remote + ": " + w.Error
+			}
+			lxdErr = strings.Join(problems, "; ")
+		}
+		if len(warnings) < len(h.fleet.Remotes()) {
+			input.LiveIPs = make(map[string]string, len(live))
+			for _, inst := range live {
+				input.LiveIPs[inst.Name] = inst.Config["volatile.ip_address"]
+			}
+		}
+	}
+
+	report := db.AuditIPAM(input)
+	if lxdErr != "" {
+		c.JSON(200, gin.H{"report": report, "lxd_error": lxdErr})
+		return
+	}
+	c.JSON(200, gin.H{"report": report})
+}
+
+// ListRemotes lists the configured LXD remotes and whether they connected
+func (h *Handlers) ListRemotes(c *gin.Context) {
+	if !h.requireLXD(c) {
+		return
+	}
+
+	remotes := []gin.H{}
+	for i, r := range h.fleet.Remotes() {
+		entry := gin.H{"name": r.Name, "primary": i == 0, "connected": r.Client != nil}
+		if r.ConnectErr != nil {
+			entry["error"] = r.ConnectErr.Error()
+		}
+		remotes = append(remotes, entry)
+	}
+	c.JSON(200, remotes)
+}
+
+// ListRemoteInstances lists the live instances of every LXD remote, each
+// tagged with its remote. Unreachable remotes are reported in warnings and the
+// instances of the others are still returned.
+func (h *Handlers) ListRemoteInstances(c *gin.Context) {
+	if !h.requireLXD(c) {
+		return
+	}
+
+	instances, warnings := h.fleet.ListInstances()
+	if len(warnings) == len(h.fleet.Remotes()) {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "no LXD remote reachable", nil, 502, true).
+			WithContext("warnings", warnings))
+		return
+	}
+	c.JSON(200, gin.H{"instances": instances, "warnings": warnings})
+}
+
+// Template Handlers
+
+// templatesCacheControl lets clients reuse the list briefly, then revalidate
+// with If-None-Match. private: user templates will make it per-user.
+const templatesCacheControl = "private, max-age=60, must-revalidate"
+
+func (h *Handlers) ListTemplates(c *gin.Context) {
+	templates := service.GetTemplates()
+
+	etag := service.TemplatesETag(templates)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", templatesCacheControl)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(304)
+		return
+	}
+
+	c.JSON(200, templates)
+}
+
+// etagMatches implements the If-None-Match comparison: a list of (possibly
+// weak) tags or "*"
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Metrics Handlers
+func (h *Handlers) GetInstanceMetrics(c *gin.Context) {
+	name := c.Param("name")
+
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("step") != "" {
+		h.queryInstanceMetrics(c, name)
+		return
+	}
+
+	stats, err := h.axhvClient.GetVmStats(c.Request.Context(), name)
+	if err != nil {
+		log.Printf("Error fetching metrics for %s: %v", name, err)
+		// Return zeros if VM is stopped or metrics unavailable
+		c.JSON(200, gin.H{
+			"cpu_usage_us":         0,
+			"memory_used_bytes":    0,
+			"net_rx_bytes":         0,
+			"net_tx_bytes":         0,
+			"disk_allocated_bytes": 0,
+		})
+		return
+	}
+
+	// Return explicit JSON with snake_case field names
+	c.JSON(200, gin.H{
+		"cpu_usage_us":         stats.CpuUsageUs,
+		"memory_used_bytes":    stats.MemoryUsedBytes,
+		"net_rx_bytes":         stats.NetRxBytes,
+		"net_tx_bytes":         stats.NetTxBytes,
+		"disk_allocated_bytes": stats.DiskAllocatedBytes,
+	})
+}
+
+// defaultMetricsQueryWindow is the metrics query window when ?from is omitted
+const defaultMetricsQueryWindow = time.Hour
+
+// queryInstanceMetrics serves GET /instances/:name/metrics?from=&to=&step=:
+// stored samples averaged per step as parallel arrays for charting. step is a
+// Go duration ("5m") or seconds; it is widened when the window would exceed
+// db.MetricsQueryMaxPoints and the step actually used is returned.
+func (h *Handlers) queryInstanceMetrics(c *gin.Context, name string) {
+	from, to, appErr := parseTimeWindow(c, defaultMetricsQueryWindow)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	var step time.Duration
+	if raw := c.Query("step"); raw != "" {
+		var err error
+		if step, err = time.ParseDuration(raw); err != nil {
+			seconds, convErr := strconv.Atoi(raw)
+			if convErr != nil {
+				h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid step (expected a duration like 5m or seconds)", err, 400, false))
+				return
+			}
+			step = time.Duration(seconds) * time.Second
+		}
+		if step <= 0 {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "step must be positive", nil, 400, false))
+			return
+		}
+	}
+	step = db.MetricsQueryStep(from, to, step)
+
+	buckets, err := db.NewMetricsRepository(db.GetService()).Query(c.Request.Context(), name, from, to, step)
+	if err != nil {
+		log.Printf("Error querying metrics for %s: %v", name, err)
+		h.writeError(c, NewError(ErrCodeMetricsFetchFailed, "failed to query metrics", err, 500, true))
+		return
+	}
+
+	timestamps := make([]string, len(buckets))
+	cpu := make([]float64, len(buckets))
+	memory := make([]int64, len(buckets))
+	disk := make([]int64, len(buckets))
+	for i, b := range buckets {
+		timestamps[i] = b.Timestamp.Format(time.RFC3339)
+		cpu[i] = b.CPUPercent
+		memory[i] = b.MemoryUsage
+		disk[i] = b.DiskUsage
+	}
+
+	c.JSON(200, gin.H{
+		"instance":     name,
+		"from":         from.Format(time.RFC3339),
+		"to":           to.Format(time.RFC3339),
+		"step_seconds": int64(step / time.Second),
+		"timestamps":   timestamps,
+		"cpu_percent":  cpu,
+		"memory_usage": memory,
+		"disk_usage":   disk,
+	})
+}
+
+func (h *Handlers) GetInstanceMetricsHistory(c *gin.Context) {
+	name := c.Param("name")
+	rangeParam := c.DefaultQuery("range", "1h")
+
+	day := 24 * time.Hour
+	rangeMap := map[string]time.Duration{
+		"1h":  time.Hour,
+		"24h": day,
+		"7d":  7 * day,
+		"30d": 30 * day,
+		"90d": 90 * day,
+		"1y":  365 * day,
+	}
+
+	span, ok := rangeMap[rangeParam]
+	if !ok {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid range parameter", nil, 400, false).
+			WithContext("valid_ranges", []string{"1h", "24h", "7d", "30d", "90d", "1y"}))
+		return
+	}
+
+	// Long ranges are served from the hourly/daily rollups
+	resolution := db.MetricsResolutionFor(span)
+	c.Header("X-Metrics-Resolution", resolution)
+
+	repo := db.NewMetricsRepository(db.GetService())
+	metrics, err := repo.GetSeries(c.Request.Context(), name, time.Now().UTC().Add(-span), resolution)
+	if err != nil {
+		log.Printf("Error fetching history for %s: %v", name, err)
+		h.writeError(c, NewError(ErrCodeMetricsFetchFailed, "failed to fetch history", err, 500, true))
+		return
+	}
+
+	c.JSON(200, metrics)
+}
+
+// defaultUsageWindow is the usage report window when ?from is omitted
+const defaultUsageWindow = 30 * 24 * time.Hour
+
+// InstanceUsage adds allocation-based CPU and RAM hours to the measured totals.
+// Allocation is the instance's current limits; deleted instances report 0.
+type InstanceUsage struct {
+	db.UsageTotals
+	VCPU       int     `json:"vcpu"`
+	MemoryGB   float64 `json:"memory_gb"`
+	CPUHours   float64 `json:"cpu_hours"`
+	RAMGBHours float64 `json:"ram_gb_hours"`
+}
+
+// parseUsageWindow reads ?from=&to= (RFC 3339). to defaults to now and from
+// to defaultUsageWindow before to.
+func parseUsageWindow(c *gin.Context) (time.Time, time.Time, *AppError) {
+	return parseTimeWindow(c, defaultUsageWindow)
+}
+
+// parseTimeWindow reads ?from=&to= (RFC 3339). to defaults to now and from
+// to span before to.
+func parseTimeWindow(c *gin.Context, span time.Duration) (time.Time, time.Time, *AppError) {
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, NewError(ErrCodeInvalidJSON, "invalid to (expected RFC 3339)", err, 400, false)
+		}
+		to = t.UTC()
+	}
+
+	from := to.Add(-span)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, NewError(ErrCodeInvalidJSON, "invalid from (expected RFC 3339)", err, 400, false)
+		}
+		from = t.UTC()
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, NewError(ErrCodeInvalidJSON, "from must be before to", nil, 400, false)
+	}
+	return from, to, nil
+}
+
+// usageFor computes the report of every instance in the window ("" = all)
+func (h *Handlers) usageFor(ctx context.Context, from, to time.Time, instanceName string) ([]InstanceUsage, error) {
+	totals, err := db.NewMetricsRepository(db.GetService()).Usage(ctx, from, to, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := db.NewInstanceRepository(db.GetService()).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]map[string]string, len(instances))
+	for _, inst := range instances {
+		limits[inst.Name] = inst.Limits
+	}
+
+	report := make([]InstanceUsage, 0, len(totals))
+	for _, t := range totals {
+		u := InstanceUsage{UsageTotals: t}
+		if l, ok := limits[t.InstanceName]; ok {
+			u.VCPU = h.parseCPU(l)
+			u.MemoryGB = float64(h.parseMemory(l)) / 1024
+		}
+		u.CPUHours = float64(u.VCPU) * t.RunningHours
+		u.RAMGBHours = u.MemoryGB * t.RunningHours
+		report = append(report, u)
+	}
+	return report, nil
+}
+
+// GetInstanceUsage reports the resource-hours consumed by one instance.
+// See db.UsageTotals for how partial hours and stopped periods are counted.
+func (h *Handlers) GetInstanceUsage(c *gin.Context) {
+	name := c.Param("name")
+	from, to, appErr := parseUsageWindow(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(c.Request.Context(), name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	report, err := h.usageFor(c.Request.Context(), from, to, name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	usage := InstanceUsage{UsageTotals: db.UsageTotals{InstanceName: name}}
+	if len(report) > 0 {
+		usage = report[0]
+	}
+	c.JSON(200, gin.H{"from": from, "to": to, "usage": usage})
+}
+
+// OwnerUsage groups the usage of the instances created by one user
+type OwnerUsage struct {
+	Owner          string          `json:"owner"`
+	Instances      []InstanceUsage `json:"instances"`
+	RunningHours   float64         `json:"running_hours"`
+	CPUHours       float64         `json:"cpu_hours"`
+	RAMGBHours     float64         `json:"ram_gb_hours"`
+	DiskGBHours    float64         `json:"disk_gb_hours"`
+	NetworkRxBytes int64           `json:"network_rx_bytes"`
+	NetworkTxBytes int64           `json:"network_tx_bytes"`
+}
+
+// GetFleetUsage reports usage for every instance grouped by owner. Instances
+// without an owner (imported or deleted) are grouped under "".
+func (h *Handlers) GetFleetUsage(c *gin.Context) {
+	from, to, appErr := parseUsageWindow(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	report, err := h.usageFor(c.Request.Context(), from, to, "")
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	byOwner := map[string]*OwnerUsage{}
+	owners := []string{}
+	for _, u := range report {
+		group, ok := byOwner[u.Owner]
+		if !ok {
+			group = &OwnerUsage{Owner: u.Owner}
+			byOwner[u.Owner] = group
+			owners = append(owners, u.Owner)
+		}
+		group.Instances = append(group.Instances, u)
+		group.RunningHours += u.RunningHours
+		group.CPUHours += u.CPUHours
+		group.RAMGBHours += u.RAMGBHours
+		group.DiskGBHours += u.DiskGBHours
+		group.NetworkRxBytes += u.NetworkRxBytes
+		group.NetworkTxBytes += u.NetworkTxBytes
+	}
+
+	sort.Strings(owners)
+	groups := make([]OwnerUsage, 0, len(owners))
+	for _, owner := range owners {
+		groups = append(groups, *byOwner[owner])
+	}
+	c.JSON(200, gin.H{"from": from, "to": to, "owners": groups})
+}
+
+// GetReportSnapshot captures the limits, latest usage, IP, node and status of
+// every instance at one point in time, as JSON or ?format=csv. With
+// ?persist=true the report is also stored for later comparison.
+func (h *Handlers) GetReportSnapshot(c *gin.Context) {
+	format, appErr := reportFormat(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	node, err := os.Hostname()
+	if err != nil {
+		node = "local"
+	}
+
+	repo := db.NewReportRepository(db.GetService())
+	report, err := repo.Capture(c.Request.Context(), c.GetString("username"), node)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	if c.Query("persist") == "true" {
+		if err := repo.Save(c.Request.Context(), report); err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+	}
+
+	h.writeReport(c, report, format)
+}
+
+// ListReportSnapshots lists the stored reports, newest first
+func (h *Handlers) ListReportSnapshots(c *gin.Context) {
+	reports, err := db.NewReportRepository(db.GetService()).List(c.Request.Context(), 100)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, reports)
+}
+
+// GetStoredReport returns a persisted report in the same formats as GetReportSnapshot
+func (h *Handlers) GetStoredReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid report id", err, 400, false))
+		return
+	}
+	format, appErr := reportFormat(c)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	report, err := db.NewReportRepository(db.GetService()).Get(c.Request.Context(), id)
+	if errors.Is(err, db.ErrReportNotFound) {
+		h.writeError(c, NewError(ErrCodeNotFound, "report not found", err, 404, false).
+			WithContext("id", id))
+		return
+	}
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	h.writeReport(c, report, format)
+}
+
+func reportFormat(c *gin.Context) (string, *AppError) {
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json", "csv":
+		return format, nil
+	default:
+		return "", NewError(ErrCodeInvalidJSON, "format must be json or csv", nil, 400, false).
+			WithContext("format", format)
+	}
+}
+
+func (h *Handlers) writeReport(c *gin.Context, report *db.UsageReport, format string) {
+	if format == "json" {
+		c.JSON(200, report)
+		return
+	}
+
+	filename := "axion-report-" + report.GeneratedAt.Format("20060102T150405Z") + ".csv"
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(200)
+	if err := report.WriteCSV(c.Writer); err != nil {
+		log.Printf("[Report] Error writing CSV report: %v", err)
+	}
+}
+
+// GetConsoleLog returns the boot/console ring buffer captured by LXD
+func (h *Handlers) GetConsoleLog(c *gin.Context) {
+	name := c.Param("name")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	content, err := h.instanceClient(name).GetConsoleLog(name)
+	if errors.Is(err, lxc.ErrConsoleLogUnavailable) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "console log not available for this instance type", err, 422, false).
+			WithContext("instance", name))
+		return
+	}
+	if lxdapi.StatusErrorCheck(err, http.StatusNotFound) {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to fetch console log", err, 502, true).
+			WithContext("instance", name))
+		return
+	}
+
+	if c.Query("format") == "text" {
+		c.String(200, content)
+		return
+	}
+	c.JSON(200, gin.H{"instance": name, "log": content})
+}
+
+// GetInstanceIP returns the IP lease of an instance with the network it came from
+func (h *Handlers) GetInstanceIP(c *gin.Context) {
+	name := c.Param("name")
+	ctx := c.Request.Context()
+
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(ctx, name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	lease, err := db.GetService().GetInstanceLease(ctx, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, NewError(ErrCodeNetworkNotFound, "instance has no IP lease", nil, 404, false).
+				WithContext("instance", name))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	c.JSON(200, gin.H{"instance": name, "lease": lease})
+}
+
+// ReIPInstance replaces the primary address of an instance (network renumbering).
+// The lease is swapped in one transaction, then eth0 is pinned to the new IP;
+// if the NIC cannot be reconfigured the old lease is restored.
+func (h *Handlers) ReIPInstance(c *gin.Context) {
+	name := c.Param("name")
+	ctx := c.Request.Context()
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	var req ReIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(ctx, name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	change, err := db.GetService().ReassignIP(ctx, name, req.NetworkID, req.IP)
+	if err != nil {
+		var fieldErr *db.NetworkFieldError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.writeError(c, NewError(ErrCodeNetworkNotFound, "network not found", nil, 404, false).
+				WithContext("network_id", req.NetworkID))
+		case errors.As(err, &fieldErr):
+			h.writeError(c, NewError(ErrCodeInvalidNetworkConfig, "cannot assign IP", err, 422, false).
+				WithContext("field", fieldErr.Field))
+		case errors.Is(err, db.ErrPoolFull):
+			h.writeError(c, NewError(ErrCodeInsufficientResources, "no IP addresses available in network", err, 409, false).
+				WithContext("network_id", req.NetworkID))
+		default:
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	if err := h.instanceClient(name).SetNICAddress(name, "eth0", change.IP); err != nil {
+		// Not the request context: the client may be gone, the lease must still go back
+		rbCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var rbErr error
+		if change.OldIP != "" {
+			rbErr = db.GetService().RestoreIP(rbCtx, name, change.OldIP)
+		} else {
+			rbErr = db.GetService().ReleaseIP(rbCtx, name)
+		}
+		rollback := "restored " + change.OldIP
+		if rbErr != nil {
+			log.Printf("Re-IP rollback failed for %s: %v", name, rbErr)
+			rollback = "failed: " + rbErr.Error()
+		}
+		h.writeError(c, NewError(ErrCodeNetworkOperationFailed, "failed to reconfigure instance NIC", err, 502, true).
+			WithContext("instance", name).
+			WithContext("rollback", rollback))
+		return
+	}
+
+	c.JSON(200, gin.H{"instance": name, "ip": change.IP, "network_id": change.NetworkID, "previous_ip": change.OldIP})
+}
+
+// exportHeartbeat is how often a running export pushes the write deadline
+// forward; the server's WriteTimeout would otherwise cut long downloads.
+const exportHeartbeat = 15 * time.Second
+
+// exportResponseWriter sends the download headers with the first byte, so an
+// export that fails before producing data can still answer with a JSON error.
+type exportResponseWriter struct {
+	c           *gin.Context
+	filename    string
+	contentType string
+	mu          sync.Mutex
+	started     bool
+}
+
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+		w.c.Status(200)
+	}
+	return w.c.Writer.Write(p)
+}
+
+// keepAlive pushes the write deadline forward and flushes every
+// exportHeartbeat until stop is called
+func (w *exportResponseWriter) keepAlive() (rc *http.ResponseController, stop func()) {
+	rc = http.NewResponseController(w.c.Writer)
+	rc.SetWriteDeadline(time.Now().Add(2 * exportHeartbeat))
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(exportHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rc.SetWriteDeadline(time.Now().Add(2 * exportHeartbeat))
+				w.flush()
+			}
+		}
+	}()
+	return rc, func() { close(done) }
+}
+
+func (w *exportResponseWriter) flush() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started {
+		w.c.Writer.Flush()
+	}
+	return w.started
+}
+
+// DownloadInstanceExport streams a full LXD export (instance + snapshots, gzip
+// tarball) straight to the client without staging it on disk
+func (h *Handlers) DownloadInstanceExport(c *gin.Context) {
+	name := c.Param("name")
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	repo := db.NewInstanceRepository(db.GetService())
+	if exists, err := repo.Exists(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	var encryption service.BackupEncryption
+	var err error
+	if encryption.Mode, encryption.Recipient, err = repo.GetBackupEncryption(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	w := &exportResponseWriter{
+		c:           c,
+		filename:    fmt.Sprintf("%s-%s.tar.gz", name, time.Now().UTC().Format("20060102-150405")),
+		contentType: "application/gzip",
+	}
+
+	// Encrypted exports carry their encryption metadata in the artifact header,
+	// which is what POST /instances/import reads to decrypt them
+	var out io.Writer = w
+	var encrypter io.WriteCloser
+	if encryption.Enabled() {
+		encrypter, err = service.NewEncryptingWriter(w, encryption, h.cfg.BackupEncryptionKey)
+		if err != nil {
+			h.writeError(c, NewError(ErrCodeBackupFailed, "backup encryption failed", err, 500, false).
+				WithContext("mode", encryption.Mode))
+			return
+		}
+		w.filename += ".axenc"
+		w.contentType = "application/octet-stream"
+		c.Header("X-Axion-Backup-Encryption", encryption.Mode)
+		out = encrypter
+	}
+
+	// Keep the connection alive while LXD builds the backup and while it streams
+	rc, stop := w.keepAlive()
+	defer stop()
+
+	log.Printf("Export of %s started", name)
+	err = h.instanceClient(name).ExportInstance(c.Request.Context(), name, out)
+	if err == nil && encrypter != nil {
+		err = encrypter.Close()
+	}
+	if err != nil {
+		log.Printf("Export of %s failed: %v", name, err)
+		if !w.flush() {
+			h.writeError(c, NewError(ErrCodeBackupFailed, "export failed", err, 502, true).
+				WithContext("instance", name))
+			return
+		}
+		// Headers are gone: dropping the connection is the only way to tell
+		// the client the download is truncated
+		if conn, _, err := rc.Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	log.Printf("Export of %s finished", name)
+}
+
+// maxLogExportWindow bounds ?from= to ?to= on the log export
+const maxLogExportWindow = 31 * 24 * time.Hour
+
+// ExportInstanceLogs streams the instance's log lines between ?from= and ?to=
+// (RFC 3339; to defaults to now) as a download. Instances with systemd are read
+// through journalctl, optionally for one ?unit=; others from the first
+// readable file of AXION_LOG_EXPORT_PATHS, filtered by timestamp.
+func (h *Handlers) ExportInstanceLogs(c *gin.Context) {
+	name := c.Param("name")
+
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "from must be an RFC 3339 timestamp", err, 400, false))
+		return
+	}
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "to must be an RFC 3339 timestamp", err, 400, false))
+			return
+		}
+	}
+	if !from.Before(to) || to.Sub(from) > maxLogExportWindow {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid time range", nil, 400, false).
+			WithContext("from", from).
+			WithContext("to", to).
+			WithContext("max_window", maxLogExportWindow.String()))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	if exists, err := db.NewInstanceRepository(db.GetService()).Exists(c.Request.Context(), name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+
+	w := &exportResponseWriter{
+		c:           c,
+		filename:    fmt.Sprintf("%s-logs-%s-%s.log", name, from.UTC().Format("20060102T150405"), to.UTC().Format("20060102T150405")),
+		contentType: "text/plain; charset=utf-8",
+	}
+	rc, stop := w.keepAlive()
+	defer stop()
+
+	req := lxc.LogExportRequest{Since: from, Until: to, Unit: c.Query("unit"), Paths: h.cfg.LogExportPaths}
+	if err := h.instanceClient(name).ExportLogs(c.Request.Context(), name, req, w); err != nil {
+		log.Printf("Log export of %s failed: %v", name, err)
+		if !w.flush() {
+			status := 502
+			if errors.Is(err, lxc.ErrLogUnitUnsupported) || errors.Is(err, lxc.ErrNoLogSource) {
+				status = 422
+			}
+			h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "log export failed", err, status, status == 502).
+				WithContext("instance", name))
+			return
+		}
+		if conn, _, err := rc.Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	// An empty window still answers with an (empty) file
+	if !w.flush() {
+		w.Write(nil)
+	}
+}
+
+// ImportInstance restores an export (plain or encrypted) uploaded as the
+// request body into a new instance ?name=, optionally on ?pool=. Encrypted
+// exports are refused unless the key they were encrypted for is available:
+// the private key in X-Axion-Backup-Key for x25519, the server key for symmetric.
+// Privileged config and host devices outside the device policy are stripped
+// from the imported instance and listed in "removed".
+func (h *Handlers) ImportInstance(c *gin.Context) {
+	name := c.Query("name")
+	pool := c.Query("pool")
+	if name == "" {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "name is required", nil, 400, false))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := db.NewInstanceRepository(db.GetService())
+	if exists, err := repo.Exists(ctx, name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if exists {
+		h.writeError(c, NewError(ErrCodeConflict, "instance name already in use", nil, 409, false).
+			WithContext("name", name))
+		return
+	}
+	if appErr := h.checkInstanceCount(ctx, h.instanceCapOwner(c), 1); appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+	if pool != "" {
+		if appErr := h.validateStoragePool(pool); appErr != nil {
+			h.writeError(c, appErr)
+			return
+		}
+	}
+
+	body := bufio.NewReader(c.Request.Body)
+	var artifact io.Reader = body
+	if service.IsEncryptedBackup(body) {
+		plain, err := service.NewDecryptingReader(body, c.GetHeader("X-Axion-Backup-Key"), h.cfg.BackupEncryptionKey)
+		if err != nil {
+			h.writeError(c, NewError(ErrCodeBackupFailed, "cannot decrypt backup", err, 422, false).
+				WithContext("instance", name))
+			return
+		}
+		artifact = plain
+	}
+
+	if err := h.lxcClient.ImportInstance(ctx, name, pool, artifact); err != nil {
+		h.writeError(c, NewError(ErrCodeBackupFailed, "import failed", err, 502, false).
+			WithContext("instance", name))
+		return
+	}
+
+	saga := service.NewSaga("import " + name)
+	saga.Completed("import_instance", func(ctx context.Context) error {
+		return h.lxcClient.DeleteInstance(name)
+	})
+	fail := func(appErr *AppError) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		h.writeError(c, appErr.WithContext("rollback", saga.Rollback(ctx)))
+	}
+
+	// The export may come from anywhere: drop privileges and host devices it carries
+	removed, err := h.lxcClient.SanitizeImportedInstance(name, func(device map[string]string) error {
+		config := make(map[string]string, len(device))
+		for k, v := range device {
+			if k != "type" {
+				config[k] = v
+			}
+		}
+		return service.ValidateDevice(device["type"], config, h.cfg.DevicePolicy)
+	})
+	if err != nil {
+		fail(NewError(ErrCodeLXDConnectionFailed, "failed to sanitize imported instance", err, 502, true))
+		return
+	}
+
+	imported, err := h.lxcClient.GetInstanceConfig(name)
+	if err != nil {
+		fail(NewError(ErrCodeLXDConnectionFailed, "failed to read imported instance", err, 502, true))
+		return
+	}
+
+	defaults := h.cfg.ImportDefaults
+	instance := types.Instance{
+		Name:            name,
+		Image:           imported.Config["volatile.base_image"],
+		Limits:          imported.Config,
+		Type:            imported.Type,
+		BackupSchedule:  defaults.BackupSchedule,
+		BackupRetention: defaults.BackupRetention,
+		BackupEnabled:   defaults.BackupEnabled,
+		StoragePool:     pool,
+		Owner:           c.GetString("username"),
+	}
+	if err := db.CreateInstance(&instance); err != nil {
+		fail(ErrDatabaseFailure(err))
+		return
+	}
+
+	h.metrics.RecordInstanceCreated()
+
+	c.JSON(201, gin.H{"status": "imported", "name": name, "removed": removed})
+}
+
+// CloneInstance queues an LXD copy of an instance under target_name, with its
+// snapshots when copy_snapshots is set. The clone keeps the provisioned disk,
+// so cloud-init does not have to run again, but gets its own IP lease and
+// none of the source's port forwards. It counts against the instance caps
+// and the global CPU/RAM quota with the source's limits.
+func (h *Handlers) CloneInstance(c *gin.Context) {
+	name := c.Param("name")
+	var req CloneInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := db.NewInstanceRepository(db.GetService())
+	source, err := repo.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, db.ErrInstanceNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+		} else {
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	if exists, err := repo.Exists(ctx, req.TargetName); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if exists {
+		h.writeError(c, NewError(ErrCodeConflict, "instance name already in use", nil, 409, false).
+			WithContext("name", req.TargetName))
+		return
+	}
+	if appErr := h.checkInstanceCount(ctx, h.instanceCapOwner(c), 1); appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+	if appErr := h.checkGlobalQuota(ctx, h.parseCPU(source.Limits), h.parseMemory(source.Limits)); appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeCloneInstance, name, gin.H{
+		"target_name":    req.TargetName,
+		"copy_snapshots": req.CopySnapshots,
+	})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "source": name, "target": req.TargetName})
+}
+
+// MigrateInstance queues a migrate_instance job moving an instance to
+// target_remote, live when requested and supported by the instance, cold
+// otherwise. The source is only deleted once the instance runs on the target;
+// a failed migration leaves it where it was. Progress is reported on the job.
+func (h *Handlers) MigrateInstance(c *gin.Context) {
+	name := c.Param("name")
+	var req MigrateInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+	if h.fleet == nil || len(h.fleet.Remotes()) < 2 {
+		h.writeError(c, NewError(ErrCodeConflict, "migration requires more than one LXD remote", nil, 409, false))
+		return
+	}
+	if _, err := h.fleet.Client(req.TargetRemote); err != nil {
+		remotes := []string{}
+		for _, r := range h.fleet.Remotes() {
+			remotes = append(remotes, r.Name)
+		}
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "target remote unavailable", err, 422, false).
+			WithContext("target_remote", req.TargetRemote).
+			WithContext("remotes", remotes))
+		return
+	}
+	if !h.requireInstance(c, name) {
+		return
+	}
+
+	_, current, err := h.fleet.ForInstance(name)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInstanceNotFound, "instance not found on any LXD remote", err, 404, false).
+			WithContext("instance", name))
+		return
+	}
+	if current == req.TargetRemote {
+		h.writeError(c, NewError(ErrCodeConflict, "instance is already on the target remote", nil, 409, false).
+			WithContext("remote", current))
+		return
+	}
+
+	if req.NetworkID != "" {
+		if _, err := db.GetService().GetNetworkDetails(c.Request.Context(), req.NetworkID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				h.writeError(c, NewError(ErrCodeNetworkNotFound, "network not found", nil, 404, false).
+					WithContext("network_id", req.NetworkID))
+			} else {
+				h.writeError(c, ErrDatabaseFailure(err))
+			}
+			return
+		}
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeMigrateInstance, name, gin.H{
+		"target_remote": req.TargetRemote,
+		"live":          req.Live,
+		"network_id":    req.NetworkID,
+	})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "source_remote": current, "target_remote": req.TargetRemote})
+}
+
+// RenameInstance queues a rename_instance job. LXD only renames stopped
+// instances, so a running one fails the job; the IP lease and pending jobs
+// move to the new name with the instance row.
+func (h *Handlers) RenameInstance(c *gin.Context) {
+	name := c.Param("name")
+	var req RenameInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	if req.NewName == name {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "new_name must differ from the current name", nil, 400, false).
+			WithContext("name", name))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	repo := db.NewInstanceRepository(db.GetService())
+	if exists, err := repo.Exists(ctx, name); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if !exists {
+		h.writeError(c, ErrInstanceNotFound(name))
+		return
+	}
+	if exists, err := repo.Exists(ctx, req.NewName); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	} else if exists {
+		h.writeError(c, NewError(ErrCodeConflict, "instance name already in use", nil, 409, false).
+			WithContext("name", req.NewName))
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypeRenameInstance, name, gin.H{"new_name": req.NewName})
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "name": name, "new_name": req.NewName})
+}
+
+// CloudInitSeed serves the NoCloud seed an AxHV VM reads at boot (see
+// axhv.SeedBootArgs): meta-data, user-data and an empty vendor-data. Only the
+// VM itself may read it, so the peer address must be the instance's IP lease.
+// Secret references in user_data and env are resolved here, on the way out.
+// With a config_repo, user-data is held (503) until fetch_config stores it.
+func (h *Handlers) CloudInitSeed(c *gin.Context) {
+	name := c.Param("name")
+	file := c.Param("file")
+
+	instance, err := db.NewInstanceRepository(db.GetService()).Get(c.Request.Context(), name)
+	if err != nil || instance.IpAddress == "" || strings.Split(instance.IpAddress, "/")[0] != c.RemoteIP() {
+		// The same answer for unknown instances and foreign peers
+		c.String(404, "not found\n")
+		return
+	}
+
+	switch file {
+	case "meta-data":
+		c.String(200, "instance-id: %s\nlocal-hostname: %s\n", name, name)
+	case "vendor-data":
+		c.String(200, "")
+	case "user-data":
+		if instance, err = h.awaitGitConfig(c.Request.Context(), instance); err != nil {
+			log.Printf("[Seed] %s: %v", name, err)
+			c.Header("Retry-After", strconv.Itoa(int(seedRetryAfter.Seconds())))
+			c.String(503, "seed unavailable\n")
+			return
+		}
+		userData, _, err := h.secrets.Resolve(c.Request.Context(), instance.UserData)
+		if err != nil {
+			log.Printf("[Seed] %s: failed to resolve user_data secrets: %v", name, err)
+			c.String(503, "seed unavailable\n")
+			return
+		}
+		env := service.InstanceEnv(instance.Limits)
+		for key, value := range env {
+			if env[key], _, err = h.secrets.Resolve(c.Request.Context(), value); err != nil {
+				log.Printf("[Seed] %s: failed to resolve env %s: %v", name, key, err)
+				c.String(503, "seed unavailable\n")
+				return
+			}
+		}
+		if userData, err = service.WithEnvironment(userData, env); err != nil {
+			log.Printf("[Seed] %s: failed to add env to user_data: %v", name, err)
+			c.String(503, "seed unavailable\n")
+			return
+		}
+		c.Data(200, "text/plain; charset=utf-8", []byte(userData))
+	default:
+		c.String(404, "not found\n")
+	}
+}
+
+// How long a user-data request waits for a pending config_repo fetch, well
+// inside the server's WriteTimeout, and the retry hint sent when it gives up
+const (
+	seedConfigWait = 10 * time.Second
+	seedRetryAfter = 5 * time.Second
+)
+
+// awaitGitConfig returns the instance once the fetch_config job has stored
+// the config_repo user_data (the source then has a Commit). Instances without
+// a config_repo are returned as they are.
+func (h *Handlers) awaitGitConfig(ctx context.Context, instance *types.Instance) (*types.Instance, error) {
+	ctx, cancel := context.WithTimeout(ctx, seedConfigWait)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	repo := db.NewInstanceRepository(db.GetService())
+	for instance.ConfigSource != nil && instance.ConfigSource.Commit == "" {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("config_repo not fetched yet")
+		case <-ticker.C:
+		}
+		next, err := repo.Get(ctx, instance.Name)
+		if err != nil {
+			return nil, err
+		}
+		instance = next
+	}
+	return instance, nil
+}
+
+// GetInstanceConfig returns the raw LXD config, devices and profiles of an instance (admin only)
+func (h *Handlers) GetInstanceConfig(c *gin.Context) {
+	name := c.Param("name")
+	if !h.requireLXD(c) {
+		return
+	}
+
+	cfg, err := h.instanceClient(name).GetInstanceConfig(name)
+	if err != nil {
+		if lxdapi.StatusErrorCheck(err, http.StatusNotFound) {
+			h.writeError(c, ErrInstanceNotFound(name))
+			return
+		}
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to fetch instance config", err, 502, true).
+			WithContext("instance", name))
+		return
+	}
+
+	// LXD holds user_data with secrets resolved; never echo the values
+	values, err := h.secrets.Values(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	for _, config := range []map[string]string{cfg.Config, cfg.ExpandedConfig} {
+		for k, v := range config {
+			config[k] = service.MaskSecrets(v, values)
+		}
+	}
+
+	c.JSON(200, cfg)
+}
+
+// ============================================================================
+// SECRETS
+// ============================================================================
+
+// secretOwner limits secret access to the caller's own secrets; admins ("") reach all
+func (h *Handlers) secretOwner(c *gin.Context) string {
+	if c.GetString("role") == "admin" {
+		return ""
+	}
+	return c.GetString("username")
+}
+
+// writeSecretError maps a failed {{secret:name}} check to a response
+func (h *Handlers) writeSecretError(c *gin.Context, err error) {
+	h.writeError(c, secretError(err))
+}
+
+func secretError(err error) *AppError {
+	switch {
+	case errors.Is(err, service.ErrSecretsDisabled):
+		return NewError(ErrCodeConfigurationInvalid, "secrets are not enabled on this server", err, 422, false).
+			WithContext("hint", "set AXION_SECRETS_KEY")
+	case errors.Is(err, service.ErrSecretNotFound):
+		return NewError(ErrCodeInvalidJSON, "user_data references an unknown secret", err, 422, false)
+	default:
+		return ErrDatabaseFailure(err)
+	}
+}
+
+// PutSecret stores (or rotates) an encrypted secret. The value is never
+// returned by the API; user_data references it as {{secret:name}}.
+func (h *Handlers) PutSecret(c *gin.Context) {
+	var req SecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	if err := service.ValidateSecretName(req.Name); err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid secret name", err, 422, false).
+			WithContext("field", "name"))
+		return
+	}
+
+	box := h.secrets.Box()
+	if box == nil {
+		h.writeSecretError(c, service.ErrSecretsDisabled)
+		return
+	}
+	sealed, err := box.Seal(req.Name, req.Value)
 	if err != nil {
-		h.writeError(c, ErrDatabaseFailure(err))
+		h.writeError(c, NewError(ErrCodeConfigurationInvalid, "failed to encrypt secret", err, 500, false))
 		return
 	}
-	c.JSON(200, jobs)
-}
 
-func (h *Handlers) GetJob(c *gin.Context) {
-	id := c.Param("id")
-	job, err := db.GetJob(id)
+	created, err := db.NewSecretRepository(db.GetService()).Put(c.Request.Context(), req.Name, sealed, c.GetString("username"), h.secretOwner(c))
 	if err != nil {
-		h.writeError(c, NewError(ErrCodeInstanceNotFound, "job not found", err, 404, false))
+		if errors.Is(err, db.ErrSecretOwned) {
+			h.writeError(c, NewError(ErrCodeConflict, "secret name is already in use", err, 409, false).
+				WithContext("name", req.Name))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
-	c.JSON(200, job)
-}
 
-// Template Handlers
-func (h *Handlers) ListTemplates(c *gin.Context) {
-	templates := service.GetTemplates()
-	c.JSON(200, templates)
+	status := 200
+	if created {
+		status = 201
+	}
+	c.JSON(status, gin.H{"name": req.Name, "created": created, "reference": "{{secret:" + req.Name + "}}"})
 }
 
-// Metrics Handlers
-func (h *Handlers) GetInstanceMetrics(c *gin.Context) {
-	name := c.Param("name")
-
-	stats, err := h.axhvClient.GetVmStats(c.Request.Context(), name)
+// ListSecrets returns the caller's secret names, never their values
+func (h *Handlers) ListSecrets(c *gin.Context) {
+	secrets, err := db.NewSecretRepository(db.GetService()).List(c.Request.Context(), h.secretOwner(c))
 	if err != nil {
-		log.Printf("Error fetching metrics for %s: %v", name, err)
-		// Return zeros if VM is stopped or metrics unavailable
-		c.JSON(200, gin.H{
-			"cpu_usage_us":         0,
-			"memory_used_bytes":    0,
-			"net_rx_bytes":         0,
-			"net_tx_bytes":         0,
-			"disk_allocated_bytes": 0,
-		})
+		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
-
-	// Return explicit JSON with snake_case field names
-	c.JSON(200, gin.H{
-		"cpu_usage_us":         stats.CpuUsageUs,
-		"memory_used_bytes":    stats.MemoryUsedBytes,
-		"net_rx_bytes":         stats.NetRxBytes,
-		"net_tx_bytes":         stats.NetTxBytes,
-		"disk_allocated_bytes": stats.DiskAllocatedBytes,
-	})
+	c.JSON(200, secrets)
 }
 
-func (h *Handlers) GetInstanceMetricsHistory(c *gin.Context) {
+// DeleteSecret removes a secret. Instances already created keep the value
+// they were given; later references to the name fail.
+func (h *Handlers) DeleteSecret(c *gin.Context) {
 	name := c.Param("name")
-	rangeParam := c.DefaultQuery("range", "1h")
-
-	intervalMap := map[string]string{
-		"1h":  "1 hour",
-		"24h": "24 hours",
-		"7d":  "7 days",
-	}
-
-	interval, ok := intervalMap[rangeParam]
-	if !ok {
-		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid range parameter", nil, 400, false).
-			WithContext("valid_ranges", []string{"1h", "24h", "7d"}))
+	deleted, err := db.NewSecretRepository(db.GetService()).Delete(c.Request.Context(), name, h.secretOwner(c))
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
-
-	metrics, err := db.GetInstanceMetrics(name, interval)
-	if err != nil {
-		log.Printf("Error fetching history for %s: %v", name, err)
-		h.writeError(c, NewError(ErrCodeMetricsFetchFailed, "failed to fetch history", err, 500, true))
+	if !deleted {
+		c.JSON(404, gin.H{"error": "Secret not found"})
 		return
 	}
-
-	c.JSON(200, metrics)
+	c.JSON(200, gin.H{"status": "deleted"})
 }
 
 func (h *Handlers) GetInstanceLogs(c *gin.Context) {
@@ -809,6 +4345,97 @@ func (h *Handlers) GetMetrics(c *gin.Context) {
 	c.JSON(200, h.metrics.Snapshot())
 }
 
+// PrometheusMetrics serves the Prometheus exposition format (instance gauges
+// and job counters). With AXION_METRICS_TOKEN set the scrape must send it as
+// a bearer token.
+func (h *Handlers) PrometheusMetrics(c *gin.Context) {
+	if token := h.cfg.MetricsToken; token != "" {
+		scheme, sent, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.AbortWithStatus(401)
+			return
+		}
+	}
+	h.prometheus.ServeHTTP(c.Writer, c.Request)
+}
+
+// Health is the liveness probe: the process is up and serving HTTP
+func (h *Handlers) Health(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// Ready is the readiness probe. It fails with 503 when the database does not
+// answer or the ISO storage is missing, read-only or below
+// AXION_STORAGE_MIN_FREE_MB, so uploads are not accepted only to fail mid-stream.
+func (h *Handlers) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	ready := true
+	checks := gin.H{}
+
+	if err := db.GetService().HealthCheck(ctx); err != nil {
+		ready = false
+		checks["database"] = gin.H{"ok": false, "error": err.Error()}
+	} else {
+		checks["database"] = gin.H{"ok": true}
+	}
+
+	storage := gin.H{"ok": true}
+	storageService, err := service.NewStorageService()
+	if err == nil {
+		var health *service.StorageHealth
+		health, err = storageService.HealthCheck(uint64(h.cfg.StorageMinFreeMB) << 20)
+		storage["writable"] = health.Writable
+		storage["free_bytes"] = health.FreeBytes
+		storage["total_bytes"] = health.TotalBytes
+	}
+	if err != nil {
+		ready = false
+		storage["ok"] = false
+		storage["error"] = err.Error()
+	}
+	checks["storage"] = storage
+
+	status := 200
+	if !ready {
+		status = 503
+	}
+	c.JSON(status, gin.H{"ready": ready, "checks": checks})
+}
+
+// validateStoragePool checks a requested pool against the pools LXD reports
+func (h *Handlers) validateStoragePool(pool string) *AppError {
+	if h.lxcClient == nil {
+		return ErrLXDUnavailable().WithContext("storage_pool", pool)
+	}
+	exists, err := h.lxcClient.StoragePoolExists(pool)
+	if err != nil {
+		return NewError(ErrCodeLXDConnectionFailed, "failed to list storage pools", err, 502, true)
+	}
+	if !exists {
+		return NewError(ErrCodeInvalidJSON, "storage pool not found", nil, 422, false).
+			WithContext("storage_pool", pool)
+	}
+	return nil
+}
+
+// ListStoragePools returns the LXD storage pools with capacity and usage
+func (h *Handlers) ListStoragePools(c *gin.Context) {
+	if !h.requireLXD(c) {
+		return
+	}
+
+	pools, err := h.lxcClient.ListStoragePools()
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to list storage pools", err, 502, true))
+		return
+	}
+
+	c.JSON(200, gin.H{"default": lxc.DefaultStoragePool, "pools": pools})
+}
+
 // Storage/ISO Handlers
 func (h *Handlers) UploadISO(c *gin.Context) {
 	c.JSON(501, gin.H{"error": "ISO upload not supported in AxHV v2"})
@@ -875,37 +4502,411 @@ func (h *Handlers) processTemplate(req CreateInstanceRequest) (string, *AppError
 		return req.UserData, nil
 	}
 
-	templates := service.GetTemplates()
-	for _, template := range templates {
-		if template.ID == req.TemplateID {
-			reqCpu := h.parseCPU(req.Limits)
-			reqRam := h.parseMemory(req.Limits)
-
-			if reqCpu < template.MinCPU {
-				return "", NewError(ErrCodeInsufficientResources,
-					fmt.Sprintf("CPU insufficient for template %s", template.Name),
-					nil, 400, false).
-					WithContext("required", template.MinCPU).
-					WithContext("provided", reqCpu)
-			}
+	template, ok := service.GetTemplateByID(req.TemplateID)
+	if !ok {
+		return "", NewError(ErrCodeTemplateNotFound, "template not found", nil, 404, false).
+			WithContext("template_id", req.TemplateID)
+	}
 
-			if reqRam < int64(template.MinRAM) {
-				return "", NewError(ErrCodeInsufficientResources,
-					fmt.Sprintf("RAM insufficient for template %s", template.Name),
-					nil, 400, false).
-					WithContext("required", template.MinRAM).
-					WithContext("provided", reqRam)
-			}
+	reqCpu := h.parseCPU(req.Limits)
+	reqRam := h.parseMemory(req.Limits)
+
+	if reqCpu < template.MinCPU {
+		return "", NewError(ErrCodeInsufficientResources,
+			fmt.Sprintf("CPU insufficient for template %s", template.Name),
+			nil, 400, false).
+			WithContext("required", template.MinCPU).
+			WithContext("provided", reqCpu)
+	}
+
+	if reqRam < int64(template.MinRAM) {
+		return "", NewError(ErrCodeInsufficientResources,
+			fmt.Sprintf("RAM insufficient for template %s", template.Name),
+			nil, 400, false).
+			WithContext("required", template.MinRAM).
+			WithContext("provided", reqRam)
+	}
+
+	if req.UserData != "" {
+		merged, err := service.MergeCloudConfig(template.CloudConfig, req.UserData)
+		if err != nil {
+			return "", NewError(ErrCodeInvalidJSON, "user_data cannot be merged with template", err, 400, false).
+				WithContext("template_id", req.TemplateID)
+		}
+		return merged, nil
+	}
+	return template.CloudConfig, nil
+}
+
+// requestedResources resolves the vCPU/RAM a create request will consume,
+// preferring the direct fields over the legacy limits map.
+// applyImageDefaults fills the sizing the user omitted from the per-image
+// defaults (falling back to the global ones). Requests written with legacy
+// limit strings get the missing keys; everything else gets direct values.
+func (h *Handlers) applyImageDefaults(ctx context.Context, req *CreateInstanceRequest) {
+	defaults, _, err := db.NewImageDefaultsRepository(db.GetService()).Resolve(ctx, req.Image)
+	if err != nil {
+		log.Printf("Image defaults lookup failed for %s, using global defaults: %v", req.Image, err)
+	}
+
+	direct := req.VCPU > 0 || req.MemoryMiB > 0 || req.DiskSizeGB > 0
+	legacy := false
+	for _, key := range []string{"cpu", "memory", "disk"} {
+		if req.Limits[key] != "" {
+			legacy = true
+		}
+	}
 
-			if req.UserData != "" {
-				return template.CloudConfig + "\n" + req.UserData, nil
+	if legacy && !direct {
+		fill := map[string]string{
+			"cpu":    strconv.Itoa(defaults.CPU),
+			"memory": fmt.Sprintf("%dMB", defaults.MemoryMiB),
+			"disk":   fmt.Sprintf("%dGB", defaults.DiskGB),
+		}
+		for key, value := range fill {
+			if req.Limits[key] == "" {
+				req.Limits[key] = value
 			}
-			return template.CloudConfig, nil
 		}
+		return
+	}
+
+	if req.VCPU <= 0 {
+		req.VCPU = defaults.CPU
+	}
+	if req.MemoryMiB <= 0 {
+		req.MemoryMiB = defaults.MemoryMiB
+	}
+	if req.DiskSizeGB <= 0 {
+		req.DiskSizeGB = defaults.DiskGB
+	}
+}
+
+// PullImageRequest pre-pulls a remote image into the local LXD image store
+type PullImageRequest struct {
+	Image string `json:"image" binding:"required"` // "ubuntu/22.04" or "remote:alias"
+	Type  string `json:"type"`                     // "container" (default) or "virtual-machine"
+}
+
+// PullImage queues a job that copies an image into the local LXD store so the
+// first create from it does not wait for the download. Progress is reported
+// on the job; the job result holds the image fingerprint.
+func (h *Handlers) PullImage(c *gin.Context) {
+	var req PullImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	if req.Type == "" {
+		req.Type = "container"
+	}
+	if req.Type != "container" && req.Type != "virtual-machine" {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "type must be container or virtual-machine", nil, 400, false))
+		return
+	}
+
+	_, alias, localAlias, err := lxc.ParseImageRef(req.Image, req.Type)
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid image", err, 400, false).
+			WithContext("image", req.Image))
+		return
+	}
+	if !service.IsImageAllowed(alias) {
+		h.writeError(c, NewError(ErrCodeImageNotAllowed, "image not allowed", nil, 422, false).
+			WithContext("image", alias).
+			WithContext("allowed", service.AllowedImages()))
+		return
+	}
+
+	if !h.requireLXD(c) {
+		return
+	}
+
+	job, appErr := h.dispatchJob(c, types.JobTypePullImage, localAlias, req)
+	if appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+
+	c.JSON(202, gin.H{"status": "accepted", "job_id": job.ID, "alias": localAlias})
+}
+
+// ListCachedImages lists the images in the local LXD store with size and last use
+func (h *Handlers) ListCachedImages(c *gin.Context) {
+	if !h.requireLXD(c) {
+		return
+	}
+
+	images, err := h.lxcClient.ListCachedImages()
+	if err != nil {
+		h.writeError(c, NewError(ErrCodeLXDConnectionFailed, "failed to list cached images", err, 502, true))
+		return
+	}
+	c.JSON(200, gin.H{"images": images})
+}
+
+// GetImageDefaults returns the sizing a create request for this image gets when it omits limits
+func (h *Handlers) GetImageDefaults(c *gin.Context) {
+	image := c.Param("image")
+
+	defaults, matched, err := db.NewImageDefaultsRepository(db.GetService()).Resolve(c.Request.Context(), image)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	source := "global"
+	if matched {
+		source = "image"
+	}
+	c.JSON(200, gin.H{
+		"image":      image,
+		"source":     source,
+		"pattern":    defaults.Image,
+		"cpu":        defaults.CPU,
+		"memory_mib": defaults.MemoryMiB,
+		"disk_gb":    defaults.DiskGB,
+	})
+}
+
+func (h *Handlers) requestedResources(req CreateInstanceRequest) (int, int64) {
+	cpu := req.VCPU
+	if cpu <= 0 {
+		cpu = h.parseCPU(req.Limits)
+	}
+	ram := int64(req.MemoryMiB)
+	if ram <= 0 {
+		ram = h.parseMemory(req.Limits)
+	}
+	return cpu, ram
+}
+
+// validateCreateRequest collects every problem that would make a create fail.
+// Template requirements are checked separately by processTemplate. owner is
+// the user counted against the per-user instance cap; empty skips that cap.
+func (h *Handlers) validateCreateRequest(ctx context.Context, req CreateInstanceRequest, owner string) []*AppError {
+	var problems []*AppError
+
+	// 1. Name availability
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(ctx, req.Name)
+	if err != nil {
+		return append(problems, ErrDatabaseFailure(err))
+	}
+	if exists {
+		problems = append(problems, NewError(ErrCodeConflict, "instance name already in use", nil, 409, false).
+			WithContext("name", req.Name))
+	}
+
+	// 2. Image (allowlist first, then provider availability). An ISO install
+	// starts from a blank disk and has no image.
+	if req.BootISO != "" {
+		problems = append(problems, h.validateBootISO(req)...)
+	} else if req.Image == "" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "image is required", nil, 400, false))
+	} else if !service.IsImageAllowed(req.Image) {
+		problems = append(problems, NewError(ErrCodeImageNotAllowed, "image not allowed", nil, 422, false).
+			WithContext("image", req.Image).
+			WithContext("allowed", service.AllowedImages()))
+	} else if _, _, err := axhv.ResolveImage(req.Image); err != nil {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "image not available", err, 400, false).
+			WithContext("image", req.Image))
+	}
+
+	// Instance type. AxHV has no type field, so only the LXD ISO path can
+	// honor virtual-machine; there VMs need virtualization on the LXD host.
+	if err := lxc.ValidateInstanceType(req.Type); err != nil {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "invalid type", err, 400, false).
+			WithContext("type", req.Type))
+	} else if req.Type == lxc.InstanceTypeVM && req.BootISO == "" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "type virtual-machine requires boot_iso", nil, 422, false).
+			WithContext("type", req.Type))
+	} else if req.BootISO != "" && h.lxcClient != nil {
+		if err := h.lxcClient.CheckInstanceType(lxc.InstanceTypeVM); errors.Is(err, lxc.ErrVirtualizationUnsupported) {
+			problems = append(problems, NewError(ErrCodeInsufficientResources, "virtual machines are not supported on this host", err, 422, false).
+				WithContext("type", lxc.InstanceTypeVM))
+		} else if err != nil {
+			problems = append(problems, NewError(ErrCodeLXDConnectionFailed, "failed to check host capabilities", err, 502, true))
+		}
+	}
+
+	if req.ISOImage != "" {
+		if appErr := h.validateISO(req.ISOImage); appErr != nil {
+			problems = append(problems, appErr)
+		}
+	}
+
+	// Like type, the pool only reaches LXD on the boot_iso path; AxHV
+	// places the disk itself
+	if req.StoragePool != "" && req.BootISO == "" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "storage_pool requires boot_iso", nil, 422, false).
+			WithContext("storage_pool", req.StoragePool))
+	} else if req.StoragePool != "" {
+		if appErr := h.validateStoragePool(req.StoragePool); appErr != nil {
+			problems = append(problems, appErr)
+		}
+	}
+
+	if err := lxc.ValidateRawConfig(req.RawConfig); err != nil {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "invalid raw_config", err, 400, false))
+	}
+
+	if source := req.configSource(); source != nil {
+		if err := source.Validate(); err != nil {
+			problems = append(problems, NewError(ErrCodeInvalidJSON, "invalid config_repo", err, 400, false))
+		} else if req.UserData != "" || req.BootISO != "" {
+			problems = append(problems, NewError(ErrCodeInvalidJSON, "config_repo cannot be combined with user_data or boot_iso", nil, 400, false))
+		}
+	}
+
+	for name := range req.Env {
+		if err := service.ValidateEnvName(name); err != nil {
+			problems = append(problems, NewError(ErrCodeInvalidJSON, "invalid env", err, 400, false))
+		} else if req.BootISO != "" {
+			problems = append(problems, NewError(ErrCodeInvalidJSON, "env cannot be combined with boot_iso", nil, 400, false))
+			break
+		}
+	}
+
+	if req.ReadinessProbe != nil {
+		if err := req.ReadinessProbe.Validate(); err != nil {
+			problems = append(problems, NewError(ErrCodeInvalidJSON, "invalid readiness_probe", err, 400, false))
+		}
+	}
+
+	if disk, ok := req.Limits["disk"]; ok && disk != "" && req.DiskSizeGB <= 0 {
+		if _, err := utils.ParseDiskToGB(disk); err != nil {
+			problems = append(problems, NewError(ErrCodeInvalidQuota, "invalid disk size", err, 400, false).
+				WithContext("disk", disk))
+		}
+	}
+
+	// 3. Quota
+	cpu, ram := h.requestedResources(req)
+	if appErr := h.checkGlobalQuota(ctx, cpu, ram); appErr != nil {
+		problems = append(problems, appErr)
+	}
+	if appErr := h.checkInstanceCount(ctx, owner, 1); appErr != nil {
+		problems = append(problems, appErr)
+	}
+
+	// 4. Network capacity
+	free, err := db.GetService().FreeIPCount(ctx, req.NetworkID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		problems = append(problems, NewError(ErrCodeNetworkNotFound, "network not found", nil, 404, false).
+			WithContext("network_id", req.NetworkID))
+	case err != nil:
+		problems = append(problems, ErrDatabaseFailure(err))
+	case free == 0:
+		problems = append(problems, NewError(ErrCodeInsufficientResources, "no IP addresses available", nil, 409, false).
+			WithContext("network_id", req.NetworkID))
+	}
+
+	return problems
+}
+
+// checkGlobalQuota applies the provider-wide CPU/RAM ceiling
+// (AXION_QUOTA_CPU / AXION_QUOTA_RAM_MB) against the sizes recorded for
+// existing instances. Without a ceiling configured nothing is checked.
+func (h *Handlers) checkGlobalQuota(ctx context.Context, cpu int, ramMB int64) *AppError {
+	maxCPU, maxRAM := h.cfg.QuotaCPU, int64(h.cfg.QuotaRAMMB)
+	if maxCPU == 0 && maxRAM == 0 {
+		return nil
+	}
+
+	instances, err := db.NewInstanceRepository(db.GetService()).List(ctx)
+	if err != nil {
+		return ErrDatabaseFailure(err)
+	}
+
+	usedCPU := 0
+	var usedRAM int64
+	for _, inst := range instances {
+		usedCPU += h.parseCPU(inst.Limits)
+		usedRAM += h.parseMemory(inst.Limits)
+	}
+
+	if maxCPU > 0 && usedCPU+cpu > maxCPU {
+		return ErrQuotaExceeded(fmt.Sprintf("CPU: %d in use + %d requested > %d", usedCPU, cpu, maxCPU))
+	}
+	if maxRAM > 0 && usedRAM+ramMB > maxRAM {
+		return ErrQuotaExceeded(fmt.Sprintf("RAM: %dMB in use + %dMB requested > %dMB", usedRAM, ramMB, maxRAM))
+	}
+	return nil
+}
+
+// instanceCapOwner returns the user subject to the per-user instance cap.
+// Admins are not capped.
+func (h *Handlers) instanceCapOwner(c *gin.Context) string {
+	if c.GetString("role") == "admin" {
+		return ""
+	}
+	return c.GetString("username")
+}
+
+// checkInstanceCount applies AXION_MAX_INSTANCES (provider-wide) and
+// AXION_MAX_INSTANCES_PER_USER to a request creating count new instances.
+// Every create path must call it before allocating anything.
+func (h *Handlers) checkInstanceCount(ctx context.Context, owner string, count int) *AppError {
+	repo := db.NewInstanceRepository(db.GetService())
+
+	if limit := h.cfg.MaxInstances; limit > 0 {
+		current, err := repo.Count(ctx)
+		if err != nil {
+			return ErrDatabaseFailure(err)
+		}
+		if current+count > limit {
+			return NewError(ErrCodeInvalidQuota, "instance limit reached", nil, 409, false).
+				WithContext("scope", "global").
+				WithContext("count", current).
+				WithContext("limit", limit)
+		}
+	}
+
+	if limit := h.cfg.MaxInstancesPerUser; limit > 0 && owner != "" {
+		current, err := repo.CountByOwner(ctx, owner)
+		if err != nil {
+			return ErrDatabaseFailure(err)
+		}
+		if current+count > limit {
+			return NewError(ErrCodeInvalidQuota, "instance limit reached", nil, 409, false).
+				WithContext("scope", "user").
+				WithContext("count", current).
+				WithContext("limit", limit)
+		}
+	}
+
+	return nil
+}
+
+// validateBootISO checks the installer flow: a virtual machine with a blank
+// disk of the given size booting an uploaded ISO. Installers do not run
+// cloud-init, so user_data and templates are rejected rather than ignored.
+func (h *Handlers) validateBootISO(req CreateInstanceRequest) []*AppError {
+	var problems []*AppError
+
+	if req.Image != "" || req.ISOImage != "" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "boot_iso cannot be combined with image or iso_image", nil, 400, false))
+	}
+	if req.Type != "" && req.Type != "virtual-machine" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "boot_iso requires a virtual-machine", nil, 422, false).
+			WithContext("type", req.Type))
+	}
+	if req.UserData != "" || req.TemplateID != "" {
+		problems = append(problems, NewError(ErrCodeInvalidJSON, "boot_iso does not support user_data or template_id", nil, 422, false))
+	}
+
+	if req.BlankDisk == "" {
+		problems = append(problems, NewError(ErrCodeInvalidQuota, "blank_disk is required with boot_iso", nil, 400, false))
+	} else if _, err := utils.ParseDiskToGB(req.BlankDisk); err != nil {
+		problems = append(problems, NewError(ErrCodeInvalidQuota, "invalid disk size", err, 400, false).
+			WithContext("blank_disk", req.BlankDisk))
 	}
 
-	return "", NewError(ErrCodeTemplateNotFound, "template not found", nil, 404, false).
-		WithContext("template_id", req.TemplateID)
+	if appErr := h.validateISO(req.BootISO); appErr != nil {
+		problems = append(problems, appErr)
+	}
+	return problems
 }
 
 func (h *Handlers) validateISO(isoImage string) *AppError {
@@ -942,13 +4943,20 @@ func (h *Handlers) parseMemory(limits map[string]string) int64 {
 // ============================================================================
 
 type Application struct {
-	lxcClient       interface{} // Place holder, unused
+	cfg             *config.Config
+	lxcClient       *lxc.InstanceService // opcional; nil desativa os recursos via jobs
 	backupScheduler *scheduler.BackupScheduler
 	handlers        *Handlers
 	router          *gin.Engine
 	server          *http.Server
 	state           atomic.Uint32
 	wg              sync.WaitGroup
+	cancel          context.CancelFunc // encerra os serviços de background
+
+	// Listener de admin/métricas, usado só quando AXION_ADMIN_ADDR está definido
+	adminAddr   string
+	adminRouter *gin.Engine
+	adminServer *http.Server
 }
 
 const (
@@ -958,10 +4966,18 @@ const (
 	stateStopped  uint32 = 3
 )
 
-func NewApplication() (*Application, error) {
+func NewApplication(cfg *config.Config) (*Application, error) {
+	// Dependencies may come up in parallel (compose/Kubernetes): wait for them
+	// within the configured retry budget instead of crash-looping
+	ctx := context.Background()
+
 	// Initialize database
-	if _, err := db.InitService(nil); err != nil {
-		return nil, fmt.Errorf("database initialization failed: %w", err)
+	err := utils.Retry(ctx, "database connection", cfg.ConnectAttempts, cfg.ConnectInterval, func() error {
+		_, err := db.InitService(cfg.DB)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("database initialization failed after %d attempts: %w", cfg.ConnectAttempts, err)
 	}
 	log.Println("✓ Database initialized")
 
@@ -972,6 +4988,15 @@ func NewApplication() (*Application, error) {
 	log.Println("✓ Database migrations applied")
 
 	// Initialize AxHV client
+	axhv.SetImagePolicy(cfg.ImagePolicy)
+	service.SetAllowedImages(cfg.AllowedImages)
+	lxc.SetPortRanges(cfg.TCPPortRange, cfg.UDPPortRange)
+	lxc.SetExplorerRoot(cfg.FileExplorerRoot)
+	lxc.SetLXDDir(cfg.LXDDir)
+	api.SetRecordingConfig(cfg.TerminalRecording)
+	if cfg.ImagePolicy.Fallback == axhv.ImageFallbackLenient {
+		log.Printf("⚠ AXION_IMAGE_FALLBACK=lenient: unmapped images boot %s", cfg.ImagePolicy.DefaultRootfs)
+	}
 	// Prefer AxHV socket default
 	axhvClient, err := axhv.NewClient("", "", "")
 	if err != nil {
@@ -979,7 +5004,7 @@ func NewApplication() (*Application, error) {
 	}
 	log.Println("✓ AxHV connection established")
 	// Initialize auth service
-	auth.Init(nil) // Uses default config
+	auth.Init(cfg.Auth)
 
 	// Seed DB with admin if empty
 	go func() {
@@ -992,26 +5017,73 @@ func NewApplication() (*Application, error) {
 		}
 	}()
 
-	// Initialize workers - DISABLED
-	// worker.Init(2, lxcClient)
-	// log.Println("✓ Worker pool initialized")
+	// Secrets store: without AXION_SECRETS_KEY it stays nil and references are rejected
+	var secrets *service.SecretStore
+	if cfg.SecretsKey != nil {
+		box, err := service.NewSecretBox(cfg.SecretsKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AXION_SECRETS_KEY: %w", err)
+		}
+		secrets = service.NewSecretStore(box, db.NewSecretRepository(db.GetService()))
+		log.Println("✓ Secrets store enabled")
+	}
+
+	// LXD é opcional: sem ele, os recursos baseados em jobs respondem 503.
+	// Só é aguardado (e fatal) quando exigido pela configuração.
+	lxdAttempts := 1
+	if cfg.LXDRequired {
+		lxdAttempts = cfg.ConnectAttempts
+	}
+	var lxcClient *lxc.InstanceService
+	err = utils.Retry(ctx, "LXD connection", lxdAttempts, cfg.ConnectInterval, func() error {
+		var err error
+		lxcClient, err = lxc.NewClientWithConfig(cfg.LXD)
+		return err
+	})
+	if err != nil && cfg.LXDRequired {
+		return nil, fmt.Errorf("LXD connection failed after %d attempts: %w", lxdAttempts, err)
+	}
+	// The worker pool is what runs queued jobs (cloud-init reapply,
+	// snapshots, ports...), so it starts with the LXD connection it drives;
+	// without LXD those endpoints answer 503 rather than queue jobs that
+	// would stay PENDING forever
+	var fleet *lxc.Fleet
+	if err != nil {
+		log.Printf("⚠ LXD unavailable, job-based features disabled: %v", err)
+		lxcClient = nil
+	} else {
+		// Extra remotes that fail to connect stay listed as unreachable
+		fleet = lxc.NewFleet(cfg.LXDRemoteName, lxcClient, lxc.ConnectRemotes(cfg.LXDRemotes)...)
+		if len(cfg.LXDRemotes) > 0 {
+			worker.SetFleet(fleet)
+			log.Printf("✓ LXD fleet: %d remotes", len(cfg.LXDRemotes)+1)
+		}
+		worker.SetGitCredentials(cfg.GitCredentials)
+		worker.Init(cfg.Workers, lxcClient, secrets)
+		log.Println("✓ Worker pool initialized")
+		go adoptProxyPorts(lxcClient)
+	}
 
 	// Initialize API broadcaster
 	api.InitBroadcaster()
 	log.Println("✓ API broadcaster initialized")
 
-	// Initialize backup scheduler - DISABLED (Legacy)
-	// backupScheduler := scheduler.NewBackupScheduler(db.GetService().GetRawDB(), lxcClient)
-	// log.Println("✓ Backup scheduler initialized")
-	var backupScheduler *scheduler.BackupScheduler // nil
+	// Scheduled backups are LXD snapshots, pruned by count or GFS policy
+	var backupScheduler *scheduler.BackupScheduler
+	if lxcClient != nil {
+		backupScheduler = scheduler.NewBackupScheduler(db.GetService().GetRawDB(), lxcClient)
+		log.Println("✓ Backup scheduler initialized")
+	}
 
 	// Initialize handlers
-	handlers := NewHandlers(axhvClient, backupScheduler)
+	handlers := NewHandlers(cfg, axhvClient, lxcClient, fleet, backupScheduler, secrets)
 
 	app := &Application{
-		lxcClient:       nil, // REMOVED
+		cfg:             cfg,
+		lxcClient:       lxcClient,
 		backupScheduler: backupScheduler,
 		handlers:        handlers,
+		adminAddr:       cfg.AdminAddr,
 	}
 	app.state.Store(stateCreated)
 
@@ -1034,45 +5106,137 @@ func (a *Application) setupRouter() {
 		c.Next()
 	})
 
+	// Terminal recordings (registered before the group variable shadows the api package)
+	api.RegisterTerminalRecordingRoutes(r.Group("/api/v1"))
+
 	api := r.Group("/api/v1")
 	h := a.handlers
 
+	// Admin and metrics routes move to their own listener (e.g. 127.0.0.1:8501)
+	// when AXION_ADMIN_ADDR is set; otherwise they stay on the public API.
+	admin := api
+	if a.adminAddr != "" {
+		a.adminRouter = gin.Default()
+		admin = a.adminRouter.Group("/api/v1")
+	}
+
+	// Prometheus scrape endpoint, outside /api/v1 and JWT auth
+	if a.adminRouter != nil {
+		a.adminRouter.GET("/metrics", h.PrometheusMetrics)
+	} else {
+		r.GET("/metrics", h.PrometheusMetrics)
+	}
+
+	// cloud-init seed read by AxHV VMs at boot, outside /api/v1 and JWT auth
+	r.GET("/seed/:name/:file", h.CloudInitSeed)
+
+	// Probes (unauthenticated)
+	api.GET("/health", h.Health)
+	api.GET("/ready", h.Ready)
+
 	// Auth
 	api.POST("/login", auth.LoginHandler)
 	api.POST("/register", auth.RegisterHandler)
 	api.POST("/refresh", auth.RefreshTokenHandler)
 	api.POST("/revoke", auth.RevokeTokenHandler)
-	api.GET("/auth/metrics", auth.GetAuthMetricsHandler)
+	api.POST("/tokens", auth.AuthMiddleware(), auth.IssueTokenHandler)
+	admin.GET("/auth/metrics", auth.GetAuthMetricsHandler)
+	api.POST("/users/:id/password", auth.PasswordChangeMiddleware(), auth.ChangePasswordHandler)
 
 	// Instances
 	api.GET("/instances", auth.AuthMiddleware(), h.ListInstances)
 	api.POST("/instances", auth.AuthMiddleware(), h.CreateInstance)
+	api.POST("/instances/validate", auth.AuthMiddleware(), h.ValidateInstance)
 	api.GET("/instances/:name", auth.AuthMiddleware(), h.GetInstance)
 	api.DELETE("/instances/:name", auth.AuthMiddleware(), h.DeleteInstance)
 	api.POST("/instances/:name/action", auth.AuthMiddleware(), h.UpdateInstanceState)
 	api.PUT("/instances/:name/limits", auth.AuthMiddleware(), h.UpdateInstanceLimits)
 	api.PUT("/instances/:name/backup", auth.AuthMiddleware(), h.UpdateBackupConfig)
-
-	// Snapshots (Stubbed)
+	api.GET("/instances/:name/snapshot-policy/preview", auth.AuthMiddleware(), h.PreviewSnapshotPolicy)
+	api.POST("/instances/:name/operations/:id/cancel", auth.AuthMiddleware(), h.CancelOperation)
+	api.GET("/instances/:name/ready", auth.AuthMiddleware(), h.GetInstanceReadiness)
+	api.PUT("/instances/:name/readiness-probe", auth.AuthMiddleware(), h.SetReadinessProbe)
+	api.POST("/instances/:name/io-limits", auth.AuthMiddleware(), h.UpdateIOLimits)
+	api.POST("/instances/:name/cloud-init/reapply", auth.AuthMiddleware(), h.ReapplyCloudInit)
+	api.POST("/instances/:name/sync", auth.AuthMiddleware(), h.SyncInstance)
+	api.POST("/instances/:name/exec", auth.AuthMiddleware(), h.ExecInstance)
+
+	// Processes
+	api.GET("/instances/:name/processes", auth.AuthMiddleware(), h.ListProcesses)
+	api.POST("/instances/:name/processes/:pid/kill", auth.AuthMiddleware(), h.KillProcess)
+
+	// Devices
+	api.POST("/instances/:name/devices", auth.AuthMiddleware(), auth.RequireRole("admin"), h.AddDevice)
+	api.DELETE("/instances/:name/devices/:device", auth.AuthMiddleware(), auth.RequireRole("admin"), h.RemoveDevice)
+
+	// Snapshots
 	api.GET("/instances/:name/snapshots", auth.AuthMiddleware(), h.ListSnapshots)
 	api.POST("/instances/:name/snapshots", auth.AuthMiddleware(), h.CreateSnapshot)
+	api.GET("/instances/:name/snapshots/diff", auth.AuthMiddleware(), h.DiffSnapshots)
 	api.POST("/instances/:name/snapshots/:snap/restore", auth.AuthMiddleware(), h.RestoreSnapshot)
 	api.DELETE("/instances/:name/snapshots/:snap", auth.AuthMiddleware(), h.DeleteSnapshot)
 
-	// Files (Stubbed)
+	// Clone, rename and migrate
+	api.POST("/instances/:name/clone", auth.AuthMiddleware(), h.CloneInstance)
+	api.PUT("/instances/:name/rename", auth.AuthMiddleware(), h.RenameInstance)
+	api.POST("/instances/:name/migrate", auth.AuthMiddleware(), auth.RequireRole("admin"), h.MigrateInstance)
+
+	// Ports
+	api.POST("/instances/:name/ports", auth.AuthMiddleware(), h.AddPort)
+	api.DELETE("/instances/:name/ports/:host_port", auth.AuthMiddleware(), h.RemovePort)
+
+	// Network
+	api.GET("/instances/:name/ip", auth.AuthMiddleware(), h.GetInstanceIP)
+	api.POST("/instances/:name/reip", auth.AuthMiddleware(), h.ReIPInstance)
+
+	// Files
 	api.GET("/instances/:name/files", auth.AuthMiddleware(), h.ListFiles)
 	api.GET("/instances/:name/file", auth.AuthMiddleware(), h.DownloadFile)
 	api.POST("/instances/:name/files", auth.AuthMiddleware(), h.UploadFile)
 	api.DELETE("/instances/:name/files", auth.AuthMiddleware(), h.DeleteFile)
 
-	// Metrics
+	// Metrics and usage
 	api.GET("/instances/:name/metrics", auth.AuthMiddleware(), h.GetInstanceMetrics)
 	api.GET("/instances/:name/metrics/history", auth.AuthMiddleware(), h.GetInstanceMetricsHistory)
+	api.GET("/instances/:name/usage", auth.AuthMiddleware(), h.GetInstanceUsage)
+	api.GET("/usage", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetFleetUsage)
+
+	// Logs
 	api.GET("/instances/:name/logs", auth.AuthMiddleware(), h.GetInstanceLogs)
+	api.GET("/instances/:name/logs/export", auth.AuthMiddleware(), h.ExportInstanceLogs)
+	api.GET("/instances/:name/console/log", auth.AuthMiddleware(), h.GetConsoleLog)
+
+	// Config, export and import
+	api.GET("/instances/:name/config", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetInstanceConfig)
+	api.GET("/instances/:name/export/download", auth.AuthMiddleware(), h.DownloadInstanceExport)
+	api.POST("/instances/import", auth.AuthMiddleware(), auth.RequireRole("admin"), h.ImportInstance)
+
+	// Remotes
+	api.GET("/remotes", auth.AuthMiddleware(), auth.RequireRole("admin"), h.ListRemotes)
+	api.GET("/remotes/instances", auth.AuthMiddleware(), auth.RequireRole("admin"), h.ListRemoteInstances)
+
+	// Reports
+	api.GET("/report/snapshot", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetReportSnapshot)
+	api.GET("/report/snapshots", auth.AuthMiddleware(), auth.RequireRole("admin"), h.ListReportSnapshots)
+	api.GET("/report/snapshots/:id", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetStoredReport)
+
+	// Secrets
+	api.GET("/secrets", auth.AuthMiddleware(), h.ListSecrets)
+	api.POST("/secrets", auth.AuthMiddleware(), h.PutSecret)
+	api.DELETE("/secrets/:name", auth.AuthMiddleware(), h.DeleteSecret)
+
+	// Scheduled tasks
+	api.GET("/instances/:name/tasks", auth.AuthMiddleware(), h.ListScheduledTasks)
+	api.POST("/instances/:name/tasks", auth.AuthMiddleware(), h.CreateScheduledTask)
+	api.PUT("/instances/:name/tasks/:id", auth.AuthMiddleware(), h.UpdateScheduledTask)
+	api.DELETE("/instances/:name/tasks/:id", auth.AuthMiddleware(), h.DeleteScheduledTask)
 
 	// Cluster
 	api.GET("/cluster", auth.AuthMiddleware(), h.GetClusterMembers)
 
+	// Storage
+	api.GET("/storage/pools", auth.AuthMiddleware(), h.ListStoragePools)
+
 	// ISOs
 	api.GET("/isos", auth.AuthMiddleware(), h.ListISOs)
 	api.POST("/isos", auth.AuthMiddleware(), h.UploadISO)
@@ -1081,186 +5245,919 @@ func (a *Application) setupRouter() {
 	// Jobs
 	api.GET("/jobs", auth.AuthMiddleware(), h.ListJobs)
 	api.GET("/jobs/:id", auth.AuthMiddleware(), h.GetJob)
+	api.DELETE("/jobs/:id", auth.AuthMiddleware(), h.CancelJob)
+
+	// Admin
+	admin.GET("/admin/retention", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetRetention)
+	admin.GET("/admin/ipam/audit", auth.AuthMiddleware(), auth.RequireRole("admin"), h.AuditIPAM)
+	admin.POST("/admin/backups/pause", auth.AuthMiddleware(), auth.RequireRole("admin"), h.PauseBackups)
+	admin.POST("/admin/backups/resume", auth.AuthMiddleware(), auth.RequireRole("admin"), h.ResumeBackups)
+	admin.GET("/admin/backups/status", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetBackupStatus)
+	admin.POST("/admin/sync", auth.AuthMiddleware(), auth.RequireRole("admin"), h.TriggerSync)
+	admin.GET("/admin/sync/last", auth.AuthMiddleware(), auth.RequireRole("admin"), h.GetLastSync)
 
 	// Templates
 	api.GET("/templates", auth.AuthMiddleware(), h.ListTemplates)
 
+	// Images
+	api.GET("/images/:image/defaults", auth.AuthMiddleware(), h.GetImageDefaults)
+	api.POST("/images/pull", auth.AuthMiddleware(), auth.RequireRole("admin"), h.PullImage)
+	api.GET("/images/cache", auth.AuthMiddleware(), h.ListCachedImages)
+
 	// App Metrics
-	api.GET("/metrics", auth.AuthMiddleware(), h.GetMetrics)
+	admin.GET("/metrics", auth.AuthMiddleware(), h.GetMetrics)
 
 	// Admin Networks
 	api.GET("/networks", auth.AuthMiddleware(), h.ListNetworks)
 	api.POST("/networks", auth.AuthMiddleware(), h.CreateNetwork)
 	api.GET("/networks/:id", auth.AuthMiddleware(), h.GetNetwork)
 	api.DELETE("/networks/:id", auth.AuthMiddleware(), h.DeleteNetwork)
+	api.POST("/networks/:id/expand", auth.AuthMiddleware(), h.ExpandNetwork)
+	api.POST("/networks/allocate/preview", auth.AuthMiddleware(), h.PreviewAllocation)
+
+	// Groups
+	api.GET("/groups", auth.AuthMiddleware(), h.ListGroups)
+	api.POST("/groups", auth.AuthMiddleware(), h.CreateGroup)
+	api.GET("/groups/:id", auth.AuthMiddleware(), h.GetGroup)
+	api.DELETE("/groups/:id", auth.AuthMiddleware(), h.DeleteGroup)
+	api.POST("/groups/:id/members", auth.AuthMiddleware(), h.AddGroupMember)
+	api.DELETE("/groups/:id/members/:instance", auth.AuthMiddleware(), h.RemoveGroupMember)
+	api.POST("/groups/:id/state", auth.AuthMiddleware(), h.UpdateGroupState)
+
+	// Stacks
+	api.GET("/stacks", auth.AuthMiddleware(), h.ListStacks)
+	api.POST("/stacks", auth.AuthMiddleware(), h.CreateStack)
+	api.GET("/stacks/:id", auth.AuthMiddleware(), h.GetStack)
+	api.DELETE("/stacks/:id", auth.AuthMiddleware(), h.DeleteStack)
+}
+
+func (a *Application) Start() error {
+	if !a.state.CompareAndSwap(stateCreated, stateRunning) {
+		return errors.New("application already started")
+	}
+
+	// Reconcile LXD into the database before serving; the report stays
+	// available at GET /admin/sync/last
+	if a.lxcClient != nil {
+		report, err := scheduler.RunStartupSync(db.GetService().GetRawDB(), a.lxcClient, a.cfg.ImportDefaults, a.cfg.SyncConcurrency)
+		if err != nil {
+			log.Printf("⚠ Startup sync skipped: %v", err)
+		} else {
+			log.Printf("✓ Startup sync completed in %s with %d workers (%d imported, %d updated, %d failed)",
+				report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond), a.cfg.SyncConcurrency,
+				len(report.Imported), len(report.Updated), len(report.Failed))
+		}
+	}
+
+	// Start background services
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	if a.lxcClient != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.lxcClient.ForwardEvents(ctx)
+		}()
+		log.Println("✓ LXD event forwarding started")
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			reconcileDevices(ctx, a.lxcClient)
+		}()
+
+		// Scheduled tasks run as exec jobs, which need LXD
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			scheduler.RunTaskScheduler(ctx, scheduler.TaskPollInterval)
+		}()
+
+		// Metrics history and ResourceAlert events come from LXD samples
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			monitor.StartHistoricalCollector(ctx, db.NewMetricsRepository(db.GetService()), a.lxcClient, a.cfg.Alerts)
+		}()
+		log.Println("✓ Historical collector started")
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := db.RunMaintenance(ctx, db.GetService(), a.cfg.JobRetention); err != nil {
+					log.Printf("[Maintenance] Error during maintenance: %v", err)
+				}
+			}
+		}
+	}()
+	log.Println("✓ Database maintenance scheduled (every 24h)")
+
+	if a.cfg.RestartCrashed {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			ticker := time.NewTicker(30 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					a.handlers.restartCrashedInstances(ctx)
+				}
+			}
+		}()
+		log.Println("✓ Crashed instance auto-restart enabled")
+	}
+
+	// Start backup scheduler
+	if a.backupScheduler != nil {
+		a.backupScheduler.SyncJobs()
+		log.Println("✓ Backup scheduler started")
+	}
+
+	// Setup router
+	a.setupRouter()
+
+	// Start HTTP server
+	a.server = &http.Server{
+		Addr:              a.cfg.ListenAddr,
+		Handler:           a.router,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    1 << 20, // 1MB
+	}
+
+	go func() {
+		log.Printf("✓ HTTP server starting on %s", a.cfg.ListenAddr)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	if a.adminRouter != nil {
+		a.adminServer = &http.Server{
+			Addr:              a.adminAddr,
+			Handler:           a.adminRouter,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			MaxHeaderBytes:    1 << 20, // 1MB
+		}
+
+		go func() {
+			log.Printf("✓ Admin server starting on %s", a.adminAddr)
+			if err := a.adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Admin server error: %v", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (a *Application) Shutdown(ctx context.Context) error {
+	if !a.state.CompareAndSwap(stateRunning, stateStopping) {
+		return errors.New("application not running")
+	}
+
+	log.Println("Initiating graceful shutdown...")
+
+	var errs []error
+
+	// 1. Stop accepting new HTTP requests
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+	}
+	log.Println("✓ HTTP server stopped")
+
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("admin http shutdown: %w", err))
+		}
+		log.Println("✓ Admin server stopped")
+	}
+
+	// 2. Stop backup scheduler
+	if a.backupScheduler != nil {
+		a.backupScheduler.Stop()
+		log.Println("✓ Backup scheduler stopped")
+	}
+
+	// 3. Wait for background services
+	if a.cancel != nil {
+		a.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✓ Background services stopped")
+	case <-ctx.Done():
+		log.Println("⚠ Background services shutdown timeout")
+		errs = append(errs, ctx.Err())
+	}
+
+	// 4. Close database
+	if err := db.GetService().Close(); err != nil {
+		errs = append(errs, fmt.Errorf("database close: %w", err))
+	}
+	log.Println("✓ Database closed")
+
+	a.state.Store(stateStopped)
+
+	// Print final metrics
+	metrics := a.handlers.metrics.Snapshot()
+	log.Printf("Final metrics: %+v", metrics)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown errors: %v", errs)
+	}
+
+	return nil
+}
+
+// ============================================================================
+// NETWORK HANDLERS
+// ============================================================================
+
+func (h *Handlers) ListNetworks(c *gin.Context) {
+	stats, err := db.GetService().GetNetworksWithStats(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch networks", "details": err.Error()})
+		return
+	}
+	c.JSON(200, stats)
+}
+
+func (h *Handlers) CreateNetwork(c *gin.Context) {
+	var req db.Network
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	// Validate (Simple check)
+	if req.Name == "" || req.CIDR == "" {
+		c.JSON(400, gin.H{"error": "Missing required fields"})
+		return
+	}
+
+	// Without a gateway the first host address (.1) is used. It is stored as
+	// given, so a later CIDR expansion does not move it.
+	if req.Gateway == "" {
+		gateway, err := db.DefaultGateway(req.CIDR)
+		if err != nil {
+			h.writeError(c, NewError(ErrCodeInvalidNetworkConfig, "invalid network configuration", err, 422, false).
+				WithContext("field", "cidr"))
+			return
+		}
+		req.Gateway = gateway
+	}
+
+	if err := db.ValidateNetwork(req); err != nil {
+		var fieldErr *db.NetworkFieldError
+		if errors.As(err, &fieldErr) {
+			h.writeError(c, NewError(ErrCodeInvalidNetworkConfig, "invalid network configuration", err, 422, false).
+				WithContext("field", fieldErr.Field))
+			return
+		}
+		h.writeError(c, NewError(ErrCodeInvalidNetworkConfig, "invalid network configuration", err, 422, false))
+		return
+	}
+
+	if err := db.GetService().CreateNetwork(c.Request.Context(), req); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create network", "details": err.Error()})
+		return
+	}
+
+	c.JSON(201, gin.H{"status": "created", "gateway": req.Gateway})
+}
+
+func (h *Handlers) GetNetwork(c *gin.Context) {
+	id := c.Param("id")
+	details, err := db.GetService().GetNetworkDetails(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"error": "Network not found"})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to fetch network details", "details": err.Error()})
+		return
+	}
+	c.JSON(200, details)
+}
+
+// ExpandNetwork widens a pool's CIDR without recreating it, e.g. a full /24 to a /23
+func (h *Handlers) ExpandNetwork(c *gin.Context) {
+	id := c.Param("id")
+	var req ExpandNetworkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	network, err := db.GetService().ExpandNetwork(c.Request.Context(), id, req.CIDR)
+	if err != nil {
+		var fieldErr *db.NetworkFieldError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.writeError(c, NewError(ErrCodeNetworkNotFound, "network not found", nil, 404, false).
+				WithContext("network_id", id))
+		case errors.As(err, &fieldErr):
+			h.writeError(c, NewError(ErrCodeInvalidNetworkConfig, "cannot expand network", err, 422, false).
+				WithContext("field", fieldErr.Field))
+		default:
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	c.JSON(200, network)
+}
+
+// PreviewAllocation reports the IP and network a create would get, without reserving it
+func (h *Handlers) PreviewAllocation(c *gin.Context) {
+	var req PreviewAllocationRequest
+	// The body is optional: no network_id previews the automatic pool selection
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.writeError(c, ErrInvalidJSON(err))
+			return
+		}
+	}
+
+	preview, err := db.GetService().PreviewIP(c.Request.Context(), req.NetworkID, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.writeError(c, NewError(ErrCodeNetworkNotFound, "network not found", nil, 404, false).
+				WithContext("network_id", req.NetworkID))
+		case errors.Is(err, db.ErrPoolFull):
+			h.writeError(c, NewError(ErrCodeInsufficientResources, "no IP addresses available in any pool", err, 409, false).
+				WithContext("reason", "POOL_FULL"))
+		default:
+			h.writeError(c, ErrDatabaseFailure(err))
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"ip":       preview.IP,
+		"network":  preview.Network,
+		"reserved": false,
+	})
+}
+
+func (h *Handlers) DeleteNetwork(c *gin.Context) {
+	id := c.Param("id")
+	err := db.GetService().DeleteNetwork(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"error": "Network not found"})
+			return
+		}
+		// Check for specific error string "active IP allocations"
+		// Ideally use custom error types, but string matching is fast for now
+		if err.Error() == "network has active IP allocations" ||
+			(len(err.Error()) > 10 && err.Error()[:11] == "network has") {
+			c.JSON(409, gin.H{"error": err.Error()}) // Conflict
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to delete network", "details": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "deleted"})
+}
+
+// ============================================================================
+// GROUP HANDLERS
+// ============================================================================
+
+func (h *Handlers) ListGroups(c *gin.Context) {
+	groups, err := db.NewGroupRepository(db.GetService()).List(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, groups)
 }
 
-func (a *Application) Start() error {
-	if !a.state.CompareAndSwap(stateCreated, stateRunning) {
-		return errors.New("application already started")
+func (h *Handlers) CreateGroup(c *gin.Context) {
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	instances := db.NewInstanceRepository(db.GetService())
+	for _, member := range req.Members {
+		exists, err := instances.Exists(ctx, member)
+		if err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+		if !exists {
+			h.writeError(c, ErrInstanceNotFound(member))
+			return
+		}
 	}
 
-	// Run startup sync
-	// scheduler.RunStartupSync(db.GetService().GetRawDB(), a.lxcClient)
-	// log.Println("✓ Startup sync completed")
+	repo := db.NewGroupRepository(db.GetService())
+	group := &db.Group{Name: req.Name, Description: req.Description}
+	if err := repo.Create(ctx, group); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
 
-	// Start background services
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		// monitor.StartHistoricalCollector(db.GetService().GetRawDB(), a.lxcClient)
-	}()
-	log.Println("✓ Historical collector started (DISABLED)")
+	for _, member := range req.Members {
+		if err := repo.AddMember(ctx, group.ID, member); err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+	}
+	group.Members = req.Members
+	if group.Members == nil {
+		group.Members = []string{}
+	}
 
-	// Start backup scheduler
-	// a.backupScheduler.Start()
-	// a.backupScheduler.SyncJobs()
-	// log.Println("✓ Backup scheduler started")
+	c.JSON(201, group)
+}
 
-	// Setup router
-	a.setupRouter()
+// GetGroup returns the group with the live state of each member
+func (h *Handlers) GetGroup(c *gin.Context) {
+	id := c.Param("id")
 
-	// Start HTTP server
-	a.server = &http.Server{
-		Addr:              ":8500",
-		Handler:           a.router,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
-		MaxHeaderBytes:    1 << 20, // 1MB
+	group, err := db.NewGroupRepository(db.GetService()).Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrGroupNotFound(id))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
 	}
 
-	go func() {
-		log.Println("✓ HTTP server starting on :8500")
-		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("Server error: %v", err)
+	runningVMs := h.runningVMs(c.Request.Context())
+	members := make([]gin.H, 0, len(group.Members))
+	for _, name := range group.Members {
+		status := "UNKNOWN"
+		if runningVMs != nil {
+			status = "STOPPED"
+			if runningVMs[name] {
+				status = "RUNNING"
+			}
 		}
-	}()
+		members = append(members, gin.H{"name": name, "status": status})
+	}
 
-	return nil
+	c.JSON(200, gin.H{
+		"id":          group.ID,
+		"name":        group.Name,
+		"description": group.Description,
+		"created_at":  group.CreatedAt,
+		"members":     members,
+	})
 }
 
-func (a *Application) Shutdown(ctx context.Context) error {
-	if !a.state.CompareAndSwap(stateRunning, stateStopping) {
-		return errors.New("application not running")
+func (h *Handlers) DeleteGroup(c *gin.Context) {
+	id := c.Param("id")
+	if err := db.NewGroupRepository(db.GetService()).Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrGroupNotFound(id))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
 	}
+	c.JSON(200, gin.H{"status": "deleted"})
+}
 
-	log.Println("Initiating graceful shutdown...")
+func (h *Handlers) AddGroupMember(c *gin.Context) {
+	id := c.Param("id")
+	var req GroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
 
-	var errs []error
+	ctx := c.Request.Context()
+	exists, err := db.NewInstanceRepository(db.GetService()).Exists(ctx, req.Instance)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if !exists {
+		h.writeError(c, ErrInstanceNotFound(req.Instance))
+		return
+	}
 
-	// 1. Stop accepting new HTTP requests
-	if err := a.server.Shutdown(ctx); err != nil {
-		errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+	repo := db.NewGroupRepository(db.GetService())
+	if _, err := repo.Get(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrGroupNotFound(id))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
 	}
-	log.Println("✓ HTTP server stopped")
 
-	// 2. Stop backup scheduler
-	// Note: Add Stop() method to scheduler if available
-	log.Println("✓ Backup scheduler stopped")
+	if err := repo.AddMember(ctx, id, req.Instance); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, gin.H{"status": "added", "instance": req.Instance})
+}
 
-	// 3. Wait for background services
-	done := make(chan struct{})
-	go func() {
-		a.wg.Wait()
-		close(done)
-	}()
+func (h *Handlers) RemoveGroupMember(c *gin.Context) {
+	id := c.Param("id")
+	instance := c.Param("instance")
 
-	select {
-	case <-done:
-		log.Println("✓ Background services stopped")
-	case <-ctx.Done():
-		log.Println("⚠ Background services shutdown timeout")
-		errs = append(errs, ctx.Err())
+	if err := db.NewGroupRepository(db.GetService()).RemoveMember(c.Request.Context(), id, instance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, NewError(ErrCodeGroupNotFound, "instance is not a member of this group", nil, 404, false).
+				WithContext("group_id", id).
+				WithContext("instance", instance))
+			return
+		}
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
 	}
+	c.JSON(200, gin.H{"status": "removed", "instance": instance})
+}
 
-	// 4. Close database
-	if err := db.GetService().Close(); err != nil {
-		errs = append(errs, fmt.Errorf("database close: %w", err))
+// UpdateGroupState fans a state action out to every member concurrently and
+// reports the per-instance outcome. A failing member does not abort the others.
+func (h *Handlers) UpdateGroupState(c *gin.Context) {
+	id := c.Param("id")
+	var req InstanceActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
 	}
-	log.Println("✓ Database closed")
 
-	a.state.Store(stateStopped)
+	if !isValidAction(req.Action) {
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid action", nil, 400, false))
+		return
+	}
 
-	// Print final metrics
-	metrics := a.handlers.metrics.Snapshot()
-	log.Printf("Final metrics: %+v", metrics)
+	ctx := c.Request.Context()
+	members, err := db.NewGroupRepository(db.GetService()).ListMembers(ctx, id)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if len(members) == 0 {
+		if _, err := db.NewGroupRepository(db.GetService()).Get(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrGroupNotFound(id))
+			return
+		}
+	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("shutdown errors: %v", errs)
+	results := make([]gin.H, len(members))
+	var wg sync.WaitGroup
+	for i, name := range members {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			result := gin.H{"instance": name, "success": true}
+			resp, err := h.executeAction(ctx, name, req.Action)
+			if err != nil {
+				result["success"] = false
+				result["error"] = err.Error()
+			} else if !resp.Success {
+				result["success"] = false
+				result["error"] = resp.Message
+			}
+			results[i] = result
+		}(i, name)
 	}
+	wg.Wait()
 
-	return nil
+	failed := 0
+	for _, r := range results {
+		if r["success"] == false {
+			failed++
+		}
+	}
+
+	status := 200
+	if failed > 0 {
+		status = 207
+	}
+	c.JSON(status, gin.H{
+		"action":    req.Action,
+		"total":     len(members),
+		"succeeded": len(members) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
 }
 
 // ============================================================================
-// NETWORK HANDLERS
+// STACK HANDLERS
 // ============================================================================
 
-func (h *Handlers) ListNetworks(c *gin.Context) {
-	stats, err := db.GetService().GetNetworksWithStats(c.Request.Context())
+// stackInstance is a member provisioned while creating a stack
+type stackInstance struct {
+	name string
+	vm   *provisionedVM
+}
+
+// CreateStack validates every member, then creates them in dependency order.
+// If any member fails, the members already created are rolled back (VM, IP
+// lease and database row) in reverse order and nothing of the stack remains.
+func (h *Handlers) CreateStack(c *gin.Context) {
+	var req StackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.writeError(c, ErrInvalidJSON(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	members := make([]service.StackMember, len(req.Instances))
+	byName := make(map[string]StackInstanceRequest, len(req.Instances))
+	for i := range req.Instances {
+		inst := &req.Instances[i]
+		if inst.BootISO != "" {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "boot_iso is not supported in stacks", nil, 422, false).
+				WithContext("instance", inst.Name))
+			return
+		}
+		if inst.NetworkID == "" {
+			inst.NetworkID = req.NetworkID
+		}
+		h.applyImageDefaults(ctx, &inst.CreateInstanceRequest)
+		members[i] = service.StackMember{Name: inst.Name, DependsOn: inst.DependsOn}
+		byName[inst.Name] = *inst
+	}
+
+	order, err := service.StackBootOrder(members)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch networks", "details": err.Error()})
+		h.writeError(c, NewError(ErrCodeInvalidJSON, "invalid stack", err, 422, false))
 		return
 	}
-	c.JSON(200, stats)
-}
 
-func (h *Handlers) CreateNetwork(c *gin.Context) {
-	var req db.Network
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "Invalid request"})
+	repo := db.NewStackRepository(db.GetService())
+	exists, err := repo.Exists(ctx, req.Name)
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	if exists {
+		h.writeError(c, NewError(ErrCodeConflict, "stack name already in use", nil, 409, false).
+			WithContext("name", req.Name))
 		return
 	}
 
-	// Validate (Simple check)
-	if req.Name == "" || req.CIDR == "" || req.Gateway == "" {
-		c.JSON(400, gin.H{"error": "Missing required fields"})
+	// Every member is checked before anything is allocated; quotas and IP
+	// capacity are checked for the stack as a whole.
+	owner := h.instanceCapOwner(c)
+	userData := make(map[string]string, len(req.Instances))
+	perNetwork := map[string]int{}
+	totalCPU, totalRAM := 0, int64(0)
+	for _, inst := range req.Instances {
+		if problems := h.validateCreateRequest(ctx, inst.CreateInstanceRequest, owner); len(problems) > 0 {
+			h.writeError(c, problems[0].WithContext("instance", inst.Name))
+			return
+		}
+		if inst.ConfigRepo != "" {
+			h.writeError(c, NewError(ErrCodeInvalidJSON, "config_repo is not supported in stacks", nil, 400, false).
+				WithContext("instance", inst.Name))
+			return
+		}
+		enhanced, appErr := h.processTemplate(inst.CreateInstanceRequest)
+		if appErr != nil {
+			h.writeError(c, appErr.WithContext("instance", inst.Name))
+			return
+		}
+		if err := h.checkSecrets(c, enhanced, inst.Env); err != nil {
+			h.writeError(c, secretError(err).WithContext("instance", inst.Name))
+			return
+		}
+		userData[inst.Name] = enhanced
+
+		cpu, ram := h.requestedResources(inst.CreateInstanceRequest)
+		totalCPU += cpu
+		totalRAM += ram
+		perNetwork[inst.NetworkID]++
+	}
+	if appErr := h.checkGlobalQuota(ctx, totalCPU, totalRAM); appErr != nil {
+		h.writeError(c, appErr)
+		return
+	}
+	if appErr := h.checkInstanceCount(ctx, owner, len(req.Instances)); appErr != nil {
+		h.writeError(c, appErr)
 		return
 	}
+	for networkID, needed := range perNetwork {
+		free, err := db.GetService().FreeIPCount(ctx, networkID)
+		if err != nil {
+			h.writeError(c, ErrDatabaseFailure(err))
+			return
+		}
+		if free < needed {
+			h.writeError(c, NewError(ErrCodeInsufficientResources, "not enough IP addresses for the stack", nil, 409, false).
+				WithContext("network_id", networkID).
+				WithContext("free", free).
+				WithContext("needed", needed))
+			return
+		}
+	}
 
-	if err := db.GetService().CreateNetwork(c.Request.Context(), req); err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create network", "details": err.Error()})
+	created := make([]stackInstance, 0, len(order))
+	for _, name := range order {
+		vm, appErr := h.provisionVM(c, byName[name].CreateInstanceRequest, userData[name], nil)
+		if appErr != nil {
+			h.writeError(c, appErr.WithContext("instance", name).
+				WithContext("stack_rollback", rollbackStack(created)))
+			return
+		}
+		created = append(created, stackInstance{name: name, vm: vm})
+	}
+
+	stack := &db.Stack{
+		Name:      req.Name,
+		NetworkID: req.NetworkID,
+		Tags:      req.Tags,
+		Owner:     c.GetString("username"),
+	}
+	for i, name := range order {
+		stack.Members = append(stack.Members, db.StackMember{
+			Instance:  name,
+			Position:  i,
+			DependsOn: byName[name].DependsOn,
+		})
+	}
+	if err := repo.Create(ctx, stack); err != nil {
+		h.writeError(c, ErrDatabaseFailure(err).WithContext("stack_rollback", rollbackStack(created)))
 		return
 	}
 
-	c.JSON(201, gin.H{"status": "created"})
+	instances := make([]gin.H, 0, len(created))
+	for _, inst := range created {
+		h.metrics.RecordInstanceCreated()
+		entry := gin.H{"name": inst.name, "ip": inst.vm.IP, "vm_id": inst.vm.VMID, "type": inst.vm.Type}
+		if jobID := h.watchProvisioning(c, inst.name); jobID != "" {
+			entry["cloud_init_job_id"] = jobID
+		}
+		instances = append(instances, entry)
+	}
+
+	c.JSON(201, gin.H{
+		"id":         stack.ID,
+		"name":       stack.Name,
+		"tags":       stack.Tags,
+		"boot_order": order,
+		"instances":  instances,
+	})
 }
 
-func (h *Handlers) GetNetwork(c *gin.Context) {
+// rollbackStack undoes the created members, last created first
+func rollbackStack(created []stackInstance) []gin.H {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report := make([]gin.H, 0, len(created))
+	for i := len(created) - 1; i >= 0; i-- {
+		report = append(report, gin.H{
+			"instance": created[i].name,
+			"rollback": created[i].vm.Rollback(ctx),
+		})
+	}
+	return report
+}
+
+func (h *Handlers) ListStacks(c *gin.Context) {
+	stacks, err := db.NewStackRepository(db.GetService()).List(c.Request.Context())
+	if err != nil {
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+	c.JSON(200, stacks)
+}
+
+// GetStack returns the stack with the live state and readiness of each member.
+// The combined status is running, stopped, degraded (some running) or unknown.
+func (h *Handlers) GetStack(c *gin.Context) {
 	id := c.Param("id")
-	details, err := db.GetService().GetNetworkDetails(c.Request.Context(), id)
+	ctx := c.Request.Context()
+
+	stack, err := db.NewStackRepository(db.GetService()).Get(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(404, gin.H{"error": "Network not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrStackNotFound(id))
 			return
 		}
-		c.JSON(500, gin.H{"error": "Failed to fetch network details", "details": err.Error()})
+		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
-	c.JSON(200, details)
+
+	runningVMs := h.runningVMs(ctx)
+	instances := db.NewInstanceRepository(db.GetService())
+	members := make([]gin.H, 0, len(stack.Members))
+	running := 0
+	for _, m := range stack.Members {
+		status := "UNKNOWN"
+		if runningVMs != nil {
+			status = "STOPPED"
+			if runningVMs[m.Instance] {
+				status = "RUNNING"
+				running++
+			}
+		}
+		member := gin.H{
+			"name":       m.Instance,
+			"position":   m.Position,
+			"depends_on": m.DependsOn,
+			"status":     status,
+		}
+		if readiness, err := instances.GetReadiness(ctx, m.Instance); err == nil && readiness.Probe != nil {
+			member["ready"] = readiness.Ready
+		}
+		members = append(members, member)
+	}
+
+	status := "degraded"
+	switch {
+	case runningVMs == nil:
+		status = "unknown"
+	case running == len(members):
+		status = "running"
+	case running == 0:
+		status = "stopped"
+	}
+
+	c.JSON(200, gin.H{
+		"id":         stack.ID,
+		"name":       stack.Name,
+		"network_id": stack.NetworkID,
+		"tags":       stack.Tags,
+		"owner":      stack.Owner,
+		"created_at": stack.CreatedAt,
+		"status":     status,
+		"members":    members,
+	})
 }
 
-func (h *Handlers) DeleteNetwork(c *gin.Context) {
+// DeleteStack tears the members down in reverse boot order, releasing their
+// IPs; members get the clean shutdown of DELETE /instances/:name within a
+// single grace period. It stops at the first failure so no member outlives
+// one it depends on; the stack keeps the remaining members and the delete
+// can be retried.
+func (h *Handlers) DeleteStack(c *gin.Context) {
 	id := c.Param("id")
-	err := db.GetService().DeleteNetwork(c.Request.Context(), id)
+	ctx := c.Request.Context()
+	repo := db.NewStackRepository(db.GetService())
+
+	stack, err := repo.Get(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(404, gin.H{"error": "Network not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			h.writeError(c, ErrStackNotFound(id))
 			return
 		}
-		// Check for specific error string "active IP allocations"
-		// Ideally use custom error types, but string matching is fast for now
-		if err.Error() == "network has active IP allocations" ||
-			(len(err.Error()) > 10 && err.Error()[:11] == "network has") {
-			c.JSON(409, gin.H{"error": err.Error()}) // Conflict
+		h.writeError(c, ErrDatabaseFailure(err))
+		return
+	}
+
+	// The members share one grace period so the request fits in the
+	// server's WriteTimeout however large the stack is
+	deadline := time.Now().Add(h.deleteGrace(c))
+	deleted := make([]string, 0, len(stack.Members))
+	for i := len(stack.Members) - 1; i >= 0; i-- {
+		name := stack.Members[i].Instance
+		if _, appErr := h.destroyInstance(ctx, name, time.Until(deadline)); appErr != nil {
+			h.writeError(c, appErr.WithContext("stack_id", id).
+				WithContext("instance", name).
+				WithContext("deleted", deleted))
 			return
 		}
-		c.JSON(500, gin.H{"error": "Failed to delete network", "details": err.Error()})
+		deleted = append(deleted, name)
+	}
+
+	if err := repo.Delete(ctx, id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.writeError(c, ErrDatabaseFailure(err))
 		return
 	}
-	c.JSON(200, gin.H{"status": "deleted"})
+	c.JSON(200, gin.H{"status": "deleted", "deleted": deleted})
 }
 
 // ============================================================================
@@ -1280,7 +6177,13 @@ func main() {
 ║              AXION CONTROL PLANE - STARTING                    ║
 ╚════════════════════════════════════════════════════════════════╝`)
 
-	app, err := NewApplication()
+	// Load and validate configuration before touching anything
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+
+	app, err := NewApplication(cfg)
 	if err != nil {
 		log.Fatalf("[FATAL] Application initialization failed: %v", err)
 	}
@@ -1293,10 +6196,6 @@ func main() {
 ╔════════════════════════════════════════════════════════════════╗
 ║              AXION CONTROL PLANE - RUNNING                     ║
 ╠════════════════════════════════════════════════════════════════╣
-║  Address:         :8500                                        ║
-║  Workers:         2                                            ║
-║  Database:        axion.db                                     ║
-╠════════════════════════════════════════════════════════════════╣
 ║  API Documentation:                                            ║
 ║    GET  /health              - Health check                    ║
 ║    GET  /metrics             - System metrics                  ║
@@ -1306,6 +6205,9 @@ func main() {
 ║    GET  /ws/terminal/:name   - WebSocket terminal              ║
 ╚════════════════════════════════════════════════════════════════╝`)
 
+	log.Printf("Address: %s | Workers: %d | Database: %s@%s:%d/%s",
+		cfg.ListenAddr, cfg.Workers, cfg.DB.User, cfg.DB.Host, cfg.DB.Port, cfg.DB.Database)
+
 	// Graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)